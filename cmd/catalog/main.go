@@ -0,0 +1,619 @@
+//-----------------------------------------------------------------------------
+/*
+
+Shape Catalog Generator
+
+Render each of the parametric shapes in the sdf package into an HTML gallery.
+Each entry shows a thumbnail image and the Go code used to generate it.
+
+This is also a smoke test for the shape generators. Any generator that
+panics or returns nil is reported, and the program exits with an error.
+
+*/
+//-----------------------------------------------------------------------------
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	. "github.com/deadsy/sdfx/sdf"
+)
+
+//-----------------------------------------------------------------------------
+
+// entry is a single catalog entry.
+type entry struct {
+	Name    string      // shape name
+	Code    string      // Go code to generate the shape
+	sdf2    func() SDF2 // 2d shape generator
+	sdf3    func() SDF3 // 3d shape generator
+	Image   string      // thumbnail image filename
+	Error   string      // generator error
+	Section string      // catalog section
+}
+
+var entries = []entry{
+	// 3D primitives
+	{
+		Name: "Box3D",
+		Code: `Box3D(V3{10, 20, 30}, 2)`,
+		sdf3: func() SDF3 { return Box3D(V3{10, 20, 30}, 2) },
+	},
+	{
+		Name: "Sphere3D",
+		Code: `Sphere3D(10)`,
+		sdf3: func() SDF3 { return Sphere3D(10) },
+	},
+	{
+		Name: "Cylinder3D",
+		Code: `Cylinder3D(20, 5, 1)`,
+		sdf3: func() SDF3 { return Cylinder3D(20, 5, 1) },
+	},
+	{
+		Name: "Capsule3D",
+		Code: `Capsule3D(3, 20)`,
+		sdf3: func() SDF3 { return Capsule3D(3, 20) },
+	},
+	{
+		Name: "Cone3D",
+		Code: `Cone3D(20, 8, 3, 1)`,
+		sdf3: func() SDF3 { return Cone3D(20, 8, 3, 1) },
+	},
+	{
+		Name: "Torus3D",
+		Code: `Torus3D(8, 2)`,
+		sdf3: func() SDF3 { return Torus3D(8, 2) },
+	},
+	{
+		Name: "Ellipsoid3D",
+		Code: `Ellipsoid3D(V3{10, 6, 4})`,
+		sdf3: func() SDF3 { return Ellipsoid3D(V3{10, 6, 4}) },
+	},
+	{
+		Name: "RoundedCone3D",
+		Code: `RoundedCone3D(20, 6, 3)`,
+		sdf3: func() SDF3 { return RoundedCone3D(20, 6, 3) },
+	},
+	{
+		Name: "Pyramid3D",
+		Code: `Pyramid3D(16, 12)`,
+		sdf3: func() SDF3 { return Pyramid3D(16, 12) },
+	},
+	{
+		Name: "Tetrahedron3D",
+		Code: `Tetrahedron3D(5, 0.5)`,
+		sdf3: func() SDF3 { return Tetrahedron3D(5, 0.5) },
+	},
+	{
+		Name: "Octahedron3D",
+		Code: `Octahedron3D(8, 0.5)`,
+		sdf3: func() SDF3 { return Octahedron3D(8, 0.5) },
+	},
+	{
+		Name: "Dodecahedron3D",
+		Code: `Dodecahedron3D(10, 1)`,
+		sdf3: func() SDF3 { return Dodecahedron3D(10, 1) },
+	},
+	{
+		Name: "Icosahedron3D",
+		Code: `Icosahedron3D(10, 1)`,
+		sdf3: func() SDF3 { return Icosahedron3D(10, 1) },
+	},
+	{
+		Name: "SuperQuadric3D",
+		Code: `SuperQuadric3D(0.3, 0.3, V3{20, 16, 10})`,
+		sdf3: func() SDF3 { return SuperQuadric3D(0.3, 0.3, V3{20, 16, 10}) },
+	},
+	{
+		Name: "MultiCylinder3D",
+		Code: `MultiCylinder3D(5, 2, V2Set{{0, 0}, {10, 0}, {0, 10}, {10, 10}})`,
+		sdf3: func() SDF3 { return MultiCylinder3D(5, 2, V2Set{{0, 0}, {10, 0}, {0, 10}, {10, 10}}) },
+	},
+	// 3D shapes
+	{
+		Name: "CounterBoredHole3D",
+		Code: `CounterBoredHole3D(20, 3, 5, 4)`,
+		sdf3: func() SDF3 { return CounterBoredHole3D(20, 3, 5, 4) },
+	},
+	{
+		Name: "ChamferedHole3D",
+		Code: `ChamferedHole3D(20, 3, 2)`,
+		sdf3: func() SDF3 { return ChamferedHole3D(20, 3, 2) },
+	},
+	{
+		Name: "CounterSunkHole3D",
+		Code: `CounterSunkHole3D(20, 3)`,
+		sdf3: func() SDF3 { return CounterSunkHole3D(20, 3) },
+	},
+	{
+		Name: "HexHead3D",
+		Code: `HexHead3D(10, 6, "tb")`,
+		sdf3: func() SDF3 { return HexHead3D(10, 6, "tb") },
+	},
+	{
+		Name: "KnurledHead3D",
+		Code: `KnurledHead3D(10, 8, 2)`,
+		sdf3: func() SDF3 { return KnurledHead3D(10, 8, 2) },
+	},
+	{
+		Name: "Knurl3D",
+		Code: `Knurl3D(20, 8, 2, 0.6, DtoR(45))`,
+		sdf3: func() SDF3 { return Knurl3D(20, 8, 2, 0.6, DtoR(45)) },
+	},
+	{
+		Name: "Washer3D",
+		Code: `Washer3D(&WasherParms{
+	Thickness:   2,
+	InnerRadius: 4,
+	OuterRadius: 8,
+	Remove:      0.3,
+})`,
+		sdf3: func() SDF3 {
+			return Washer3D(&WasherParms{
+				Thickness:   2,
+				InnerRadius: 4,
+				OuterRadius: 8,
+				Remove:      0.3,
+			})
+		},
+	},
+	{
+		Name: "Standoff3D",
+		Code: `Standoff3D(&StandoffParms{
+	PillarHeight:   15,
+	PillarDiameter: 6,
+	HoleDepth:      10,
+	HoleDiameter:   2.4,
+	NumberWebs:     4,
+	WebHeight:      8,
+	WebDiameter:    14,
+	WebWidth:       2,
+})`,
+		sdf3: func() SDF3 {
+			return Standoff3D(&StandoffParms{
+				PillarHeight:   15,
+				PillarDiameter: 6,
+				HoleDepth:      10,
+				HoleDiameter:   2.4,
+				NumberWebs:     4,
+				WebHeight:      8,
+				WebDiameter:    14,
+				WebWidth:       2,
+			})
+		},
+	},
+	{
+		Name: "TruncRectPyramid3D",
+		Code: `TruncRectPyramid3D(&TruncRectPyramidParms{
+	Size:        V3{30, 20, 10},
+	BaseAngle:   DtoR(60),
+	BaseRadius:  4,
+	RoundRadius: 1,
+})`,
+		sdf3: func() SDF3 {
+			return TruncRectPyramid3D(&TruncRectPyramidParms{
+				Size:        V3{30, 20, 10},
+				BaseAngle:   DtoR(60),
+				BaseRadius:  4,
+				RoundRadius: 1,
+			})
+		},
+	},
+	{
+		Name: "Bolt",
+		Code: `Bolt(&BoltParms{
+	Thread:      "M8x1.25",
+	Style:       "hex",
+	TotalLength: 20,
+	ShankLength: 5,
+})`,
+		sdf3: func() SDF3 {
+			s, _ := Bolt(&BoltParms{
+				Thread:      "M8x1.25",
+				Style:       "hex",
+				TotalLength: 20,
+				ShankLength: 5,
+			})
+			return s
+		},
+	},
+	{
+		Name: "Nut",
+		Code: `Nut(&NutParms{
+	Thread: "M8x1.25",
+	Style:  "knurl",
+})`,
+		sdf3: func() SDF3 {
+			s, _ := Nut(&NutParms{
+				Thread: "M8x1.25",
+				Style:  "knurl",
+			})
+			return s
+		},
+	},
+	{
+		Name: "Screw3D",
+		Code: `Screw3D(ISOThread(5, 1, "external"), 20, 1, 1)`,
+		sdf3: func() SDF3 { return Screw3D(ISOThread(5, 1, "external"), 20, 1, 1) },
+	},
+	{
+		Name: "LeftHandScrew3D",
+		Code: `LeftHandScrew3D(ISOThread(5, 1, "external"), 20, 1, 1)`,
+		sdf3: func() SDF3 { return LeftHandScrew3D(ISOThread(5, 1, "external"), 20, 1, 1) },
+	},
+	{
+		Name: "TaperedScrew3D",
+		Code: `TaperedScrew3D(NPTThread(5, 1), 20, DtoR(1.79), 1, 1)`,
+		sdf3: func() SDF3 {
+			s, _ := TaperedScrew3D(NPTThread(5, 1), 20, DtoR(1.79), 1, 1)
+			return s
+		},
+	},
+	{
+		Name: "MakeBoltCircle3D",
+		Code: `MakeBoltCircle3D(5, 2, 10, 6)`,
+		sdf3: func() SDF3 { return MakeBoltCircle3D(5, 2, 10, 6) },
+	},
+	{
+		Name: "PanelBox3D",
+		Code: `Union3D(PanelBox3D(&PanelBoxParms{
+	Size:       V3{50, 30, 60},
+	Wall:       2.5,
+	Panel:      3,
+	Rounding:   5,
+	FrontInset: 5,
+	BackInset:  5,
+	Clearance:  0.05,
+	Hole:       2,
+	SideTabs:   "TbtbT",
+})...)`,
+		sdf3: func() SDF3 {
+			return Union3D(PanelBox3D(&PanelBoxParms{
+				Size:       V3{50, 30, 60},
+				Wall:       2.5,
+				Panel:      3,
+				Rounding:   5,
+				FrontInset: 5,
+				BackInset:  5,
+				Clearance:  0.05,
+				Hole:       2,
+				SideTabs:   "TbtbT",
+			})...)
+		},
+	},
+	// 2D primitives
+	{
+		Name: "Circle2D",
+		Code: `Circle2D(10)`,
+		sdf2: func() SDF2 { return Circle2D(10) },
+	},
+	{
+		Name: "Box2D",
+		Code: `Box2D(V2{20, 10}, 2)`,
+		sdf2: func() SDF2 { return Box2D(V2{20, 10}, 2) },
+	},
+	{
+		Name: "Line2D",
+		Code: `Line2D(20, 2)`,
+		sdf2: func() SDF2 { return Line2D(20, 2) },
+	},
+	{
+		Name: "Slot2D",
+		Code: `Slot2D(20, 4)`,
+		sdf2: func() SDF2 { return Slot2D(20, 4) },
+	},
+	{
+		Name: "ArcSlot2D",
+		Code: `ArcSlot2D(10, 3, DtoR(120))`,
+		sdf2: func() SDF2 { return ArcSlot2D(10, 3, DtoR(120)) },
+	},
+	{
+		Name: "Polygon2D",
+		Code: `Polygon2D(Nagon(6, 10))`,
+		sdf2: func() SDF2 { return Polygon2D(Nagon(6, 10)) },
+	},
+	{
+		Name: "CubicSpline2D",
+		Code: `CubicSpline2D([]V2{{-10, -10}, {-5, 5}, {5, -5}, {10, 10}})`,
+		sdf2: func() SDF2 { return CubicSpline2D([]V2{{-10, -10}, {-5, 5}, {5, -5}, {10, 10}}) },
+	},
+	{
+		Name: "ArcSpiral2D",
+		Code: `ArcSpiral2D(1.0, 2.0, 0, 4*Tau, 0.8)`,
+		sdf2: func() SDF2 { return ArcSpiral2D(1.0, 2.0, 0, 4*Tau, 0.8) },
+	},
+	// 2D shapes
+	{
+		Name: "InvoluteGear",
+		Code: `InvoluteGear(20, 1, DtoR(20), 0, 0, 3, 7)`,
+		sdf2: func() SDF2 { return InvoluteGear(20, 1, DtoR(20), 0, 0, 3, 7) },
+	},
+	{
+		Name: "GearRack2D",
+		Code: `GearRack2D(10, 1, DtoR(20), 0, 2)`,
+		sdf2: func() SDF2 { return GearRack2D(10, 1, DtoR(20), 0, 2) },
+	},
+	{
+		Name: "FlatFlankCam2D",
+		Code: `FlatFlankCam2D(20, 10, 5)`,
+		sdf2: func() SDF2 { return FlatFlankCam2D(20, 10, 5) },
+	},
+	{
+		Name: "ThreeArcCam2D",
+		Code: `ThreeArcCam2D(20, 10, 5, 30)`,
+		sdf2: func() SDF2 { return ThreeArcCam2D(20, 10, 5, 30) },
+	},
+	{
+		Name: "NewFlange1",
+		Code: `NewFlange1(20, 10, 5)`,
+		sdf2: func() SDF2 { return NewFlange1(20, 10, 5) },
+	},
+	{
+		Name: "MakeBoltCircle2D",
+		Code: `MakeBoltCircle2D(2, 10, 6)`,
+		sdf2: func() SDF2 { return MakeBoltCircle2D(2, 10, 6) },
+	},
+	{
+		Name: "Panel2D",
+		Code: `Panel2D(&PanelParms{
+	Size:         V2{60, 40},
+	CornerRadius: 5,
+	HoleDiameter: 3,
+	HoleMargin:   [4]float64{5, 5, 5, 5},
+	HolePattern:  [4]string{"xx", "x", "xx", "x"},
+})`,
+		sdf2: func() SDF2 {
+			return Panel2D(&PanelParms{
+				Size:         V2{60, 40},
+				CornerRadius: 5,
+				HoleDiameter: 3,
+				HoleMargin:   [4]float64{5, 5, 5, 5},
+				HolePattern:  [4]string{"xx", "x", "xx", "x"},
+			})
+		},
+	},
+	{
+		Name: "FingerButton2D",
+		Code: `FingerButton2D(&FingerButtonParms{
+	Width:  15,
+	Gap:    2,
+	Length: 20,
+})`,
+		sdf2: func() SDF2 {
+			return FingerButton2D(&FingerButtonParms{
+				Width:  15,
+				Gap:    2,
+				Length: 20,
+			})
+		},
+	},
+	// thread profiles
+	{
+		Name: "ISOThread",
+		Code: `ISOThread(5, 1, "external")`,
+		sdf2: func() SDF2 { return ISOThread(5, 1, "external") },
+	},
+	{
+		Name: "AcmeThread",
+		Code: `AcmeThread(5, 1)`,
+		sdf2: func() SDF2 { return AcmeThread(5, 1) },
+	},
+	{
+		Name: "TrapezoidalThread",
+		Code: `TrapezoidalThread(5, 1)`,
+		sdf2: func() SDF2 { return TrapezoidalThread(5, 1) },
+	},
+	{
+		Name: "NPTThread",
+		Code: `NPTThread(5, 1)`,
+		sdf2: func() SDF2 { return NPTThread(5, 1) },
+	},
+	{
+		Name: "WhitworthThread",
+		Code: `WhitworthThread(5, 1)`,
+		sdf2: func() SDF2 { return WhitworthThread(5, 1) },
+	},
+	{
+		Name: "ButtressThread",
+		Code: `ButtressThread(5, 1, 0.6, DtoR(7), DtoR(45))`,
+		sdf2: func() SDF2 { return ButtressThread(5, 1, 0.6, DtoR(7), DtoR(45)) },
+	},
+	{
+		Name: "ANSIButtressThread",
+		Code: `ANSIButtressThread(5, 1)`,
+		sdf2: func() SDF2 { return ANSIButtressThread(5, 1) },
+	},
+	{
+		Name: "PlasticButtressThread",
+		Code: `PlasticButtressThread(5, 1)`,
+		sdf2: func() SDF2 { return PlasticButtressThread(5, 1) },
+	},
+	{
+		Name: "KnurlProfile",
+		Code: `KnurlProfile(10, 2, 0.6)`,
+		sdf2: func() SDF2 { return KnurlProfile(10, 2, 0.6) },
+	},
+}
+
+//-----------------------------------------------------------------------------
+
+// image2d renders a filled thumbnail image of an SDF2.
+func image2d(s SDF2, pixels int) (image.Image, error) {
+	bb := s.BoundingBox()
+	// make the box square and add a margin
+	size := bb.Size().MaxComponent() * 1.1
+	bb = NewBox2(bb.Center(), V2{size, size})
+	m, err := NewMap2(bb, V2i{pixels, pixels}, true)
+	if err != nil {
+		return nil, err
+	}
+	// the boundary width is about one pixel
+	edge := size / float64(pixels)
+	img := image.NewGray(image.Rect(0, 0, pixels, pixels))
+	for x := 0; x < pixels; x++ {
+		for y := 0; y < pixels; y++ {
+			d := s.Evaluate(m.ToV2(V2i{x, y}))
+			c := uint8(255)
+			if Abs(d) < edge {
+				c = 0
+			} else if d < 0 {
+				c = 160
+			}
+			img.SetGray(x, y, color.Gray{c})
+		}
+	}
+	return img, nil
+}
+
+// image3d renders a ray marched thumbnail image of an SDF3.
+func image3d(s SDF3, pixels int) (image.Image, error) {
+	cam := DefaultCamera3(s.BoundingBox())
+	return RayMarchImage(s, cam, V2i{pixels, pixels}), nil
+}
+
+// savePNG saves an image as a PNG file.
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+// render renders the thumbnail image for a catalog entry.
+func (e *entry) render(dir string, pixels int) (err error) {
+	// a broken generator shouldn't stop the catalog
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	var img image.Image
+	if e.sdf3 != nil {
+		e.Section = "3D"
+		s := e.sdf3()
+		if s == nil {
+			return fmt.Errorf("nil SDF3")
+		}
+		img, err = image3d(s, pixels)
+	} else {
+		e.Section = "2D"
+		s := e.sdf2()
+		if s == nil {
+			return fmt.Errorf("nil SDF2")
+		}
+		img, err = image2d(s, pixels)
+	}
+	if err != nil {
+		return err
+	}
+	e.Image = e.Name + ".png"
+	return savePNG(filepath.Join(dir, e.Image), img)
+}
+
+//-----------------------------------------------------------------------------
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>sdfx shape catalog</title>
+<style>
+body { font-family: sans-serif; background: #f0f0f0; }
+.grid { display: flex; flex-wrap: wrap; }
+.entry { background: #fff; margin: 8px; padding: 8px; width: {{.Width}}px; }
+.entry h3 { margin: 4px 0; font-size: 14px; }
+.entry pre { font-size: 11px; overflow-x: auto; background: #f8f8f8; padding: 4px; }
+.error { color: #c00000; }
+</style>
+</head>
+<body>
+<h1>sdfx shape catalog</h1>
+{{range .Sections}}
+<h2>{{.Name}}</h2>
+<div class="grid">
+{{range .Entries}}
+<div class="entry">
+<h3>{{.Name}}</h3>
+{{if .Error}}<p class="error">{{.Error}}</p>{{else}}<img src="{{.Image}}" alt="{{.Name}}">{{end}}
+<pre>{{.Code}}</pre>
+</div>
+{{end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+type section struct {
+	Name    string
+	Entries []entry
+}
+
+// writeIndex writes the catalog html file.
+func writeIndex(path string, pixels int, es []entry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sections := []section{{Name: "3D"}, {Name: "2D"}}
+	for _, e := range es {
+		for i := range sections {
+			if sections[i].Name == e.Section {
+				sections[i].Entries = append(sections[i].Entries, e)
+			}
+		}
+	}
+	return indexTemplate.Execute(f, struct {
+		Width    int
+		Sections []section
+	}{pixels, sections})
+}
+
+//-----------------------------------------------------------------------------
+
+func main() {
+	dir := flag.String("out", "html", "output directory")
+	pixels := flag.Int("size", 200, "thumbnail size (pixels)")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	failed := 0
+	for i := range entries {
+		e := &entries[i]
+		fmt.Printf("rendering %s\n", e.Name)
+		if err := e.render(*dir, *pixels); err != nil {
+			e.Error = err.Error()
+			fmt.Fprintf(os.Stderr, "%s: %s\n", e.Name, err)
+			failed++
+		}
+	}
+
+	path := filepath.Join(*dir, "index.html")
+	if err := writeIndex(path, *pixels, entries); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("wrote %s\n", path)
+
+	if failed != 0 {
+		fmt.Fprintf(os.Stderr, "%d shape generators failed\n", failed)
+		os.Exit(1)
+	}
+}
+
+//-----------------------------------------------------------------------------
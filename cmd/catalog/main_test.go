@@ -0,0 +1,117 @@
+//-----------------------------------------------------------------------------
+/*
+
+Catalog Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+
+// formatExpr returns the gofmt formatted text of an expression.
+func formatExpr(fset *token.FileSet, x ast.Expr) (string, error) {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, x); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// generatorExpr returns the expression built by a generator function.
+// The body is either "return <expr>" or "s, _ := <expr>; return s".
+func generatorExpr(f *ast.FuncLit) ast.Expr {
+	body := f.Body.List
+	switch len(body) {
+	case 1:
+		if r, ok := body[0].(*ast.ReturnStmt); ok && len(r.Results) == 1 {
+			return r.Results[0]
+		}
+	case 2:
+		a, ok := body[0].(*ast.AssignStmt)
+		if !ok || len(a.Lhs) != 2 || len(a.Rhs) != 1 {
+			return nil
+		}
+		r, ok := body[1].(*ast.ReturnStmt)
+		if !ok || len(r.Results) != 1 {
+			return nil
+		}
+		lhs, ok0 := a.Lhs[0].(*ast.Ident)
+		res, ok1 := r.Results[0].(*ast.Ident)
+		if ok0 && ok1 && lhs.Name == res.Name {
+			return a.Rhs[0]
+		}
+	}
+	return nil
+}
+
+// Test_Code checks the code snippet shown for each entry against the code that generates it.
+func Test_Code(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", nil, 0)
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	obj := f.Scope.Lookup("entries")
+	if obj == nil {
+		t.Fatal("FAIL no entries")
+	}
+	lit := obj.Decl.(*ast.ValueSpec).Values[0].(*ast.CompositeLit)
+	if len(lit.Elts) != len(entries) {
+		t.Fatalf("FAIL parsed %d entries, expected %d", len(lit.Elts), len(entries))
+	}
+	for i, elt := range lit.Elts {
+		name := entries[i].Name
+		var code string
+		var gen ast.Expr
+		for _, kv := range elt.(*ast.CompositeLit).Elts {
+			kv := kv.(*ast.KeyValueExpr)
+			switch kv.Key.(*ast.Ident).Name {
+			case "Code":
+				code, err = strconv.Unquote(kv.Value.(*ast.BasicLit).Value)
+				if err != nil {
+					t.Fatalf("FAIL %s: %s", name, err)
+				}
+			case "sdf2", "sdf3":
+				gen = generatorExpr(kv.Value.(*ast.FuncLit))
+				if gen == nil {
+					t.Errorf("FAIL %s: unexpected generator function body", name)
+				}
+			}
+		}
+		if gen == nil {
+			continue
+		}
+		// normalize both the snippet and the generator code with gofmt
+		cfset := token.NewFileSet()
+		x, err := parser.ParseExprFrom(cfset, name, code, 0)
+		if err != nil {
+			t.Errorf("FAIL %s: bad code snippet: %s", name, err)
+			continue
+		}
+		want, err := formatExpr(cfset, x)
+		if err != nil {
+			t.Fatalf("FAIL %s: %s", name, err)
+		}
+		got, err := formatExpr(fset, gen)
+		if err != nil {
+			t.Fatalf("FAIL %s: %s", name, err)
+		}
+		if got != want {
+			t.Errorf("FAIL %s: code snippet\n%s\ndoesn't match the generator\n%s", name, want, got)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,211 @@
+//-----------------------------------------------------------------------------
+/*
+
+Drawing Annotations
+
+Dimensions and labels that are rendered into DXF and SVG files alongside a
+2D profile, so a generated drawing can be used as a simple fabrication
+drawing.
+
+Annotations are built from lines (dimension lines, extension lines, leaders
+and arrow heads) and text. The measured values are taken from the given
+geometry, so the dimensions stay correct when the model parameters change.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"fmt"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+// annotationText is a text string in an annotation.
+type annotationText struct {
+	p     V2      // position (center of the text baseline)
+	angle float64 // baseline angle (radians)
+	h     float64 // text height
+	s     string  // text
+}
+
+// Annotations is a set of drawing annotations.
+type Annotations struct {
+	lines      []Line
+	texts      []annotationText
+	textHeight float64 // text height
+	arrowSize  float64 // arrow head length
+	precision  int     // number of decimal places for dimensions
+}
+
+// NewAnnotations returns an empty set of annotations. The text height also
+// sets the size of the arrow heads and the gaps around the dimension lines.
+func NewAnnotations(textHeight float64) *Annotations {
+	if textHeight <= 0 {
+		panic("textHeight <= 0")
+	}
+	return &Annotations{
+		textHeight: textHeight,
+		arrowSize:  textHeight,
+		precision:  2,
+	}
+}
+
+// SetPrecision sets the number of decimal places for dimension values.
+func (a *Annotations) SetPrecision(n int) *Annotations {
+	a.precision = n
+	return a
+}
+
+// line adds a line.
+func (a *Annotations) line(p0, p1 V2) {
+	a.lines = append(a.lines, Line{p0, p1})
+}
+
+// arrow adds an arrow head with the tip at p pointing in direction u.
+func (a *Annotations) arrow(p, u V2) {
+	b := p.Sub(u.MulScalar(a.arrowSize))
+	n := V2{-u.Y, u.X}.MulScalar(a.arrowSize / 6)
+	a.line(p, b.Add(n))
+	a.line(p, b.Sub(n))
+	a.line(b.Add(n), b.Sub(n))
+}
+
+// text adds text along a baseline direction, offset above the baseline. The
+// text is turned around if it would be upside down.
+func (a *Annotations) text(p, u V2, s string) {
+	if u.X < -epsilon || (Abs(u.X) <= epsilon && u.Y < 0) {
+		u = u.Neg()
+	}
+	n := V2{-u.Y, u.X}
+	p = p.Add(n.MulScalar(0.25 * a.textHeight))
+	a.texts = append(a.texts, annotationText{p, math.Atan2(u.Y, u.X), a.textHeight, s})
+}
+
+// value returns a formatted dimension value.
+func (a *Annotations) value(x float64) string {
+	return fmt.Sprintf("%.*f", a.precision, x)
+}
+
+// linear adds a linear dimension of the distance between p0 and p1 measured
+// along direction u. The dimension line is offset along the left normal of u.
+func (a *Annotations) linear(p0, p1, u V2, offset float64) {
+	n := V2{-u.Y, u.X}
+	// the dimension line is offset from the outermost point
+	c := Max(p0.Dot(n), p1.Dot(n)) + offset
+	if offset < 0 {
+		c = Min(p0.Dot(n), p1.Dot(n)) + offset
+	}
+	d0 := p0.Add(n.MulScalar(c - p0.Dot(n)))
+	d1 := p1.Add(n.MulScalar(c - p1.Dot(n)))
+	// extension lines, with a gap at the geometry and an overshoot
+	gap := 0.25 * a.textHeight
+	for _, x := range [][2]V2{{p0, d0}, {p1, d1}} {
+		v := x[1].Sub(x[0])
+		l := v.Length()
+		if l > gap {
+			v = v.DivScalar(l)
+			a.line(x[0].Add(v.MulScalar(gap)), x[1].Add(v.MulScalar(2*gap)))
+		}
+	}
+	// dimension line and arrows
+	a.line(d0, d1)
+	if v := d1.Sub(d0); v.Length() > tolerance {
+		v = v.Normalize()
+		a.arrow(d0, v.Neg())
+		a.arrow(d1, v)
+	}
+	a.text(d0.Add(d1).MulScalar(0.5), u, a.value(Abs(p1.Sub(p0).Dot(u))))
+}
+
+// Aligned adds a dimension of the distance between two points with the
+// dimension line parallel to the line between them. A positive offset puts
+// the dimension line on the left of the line from p0 to p1.
+func (a *Annotations) Aligned(p0, p1 V2, offset float64) {
+	u := p1.Sub(p0)
+	if u.Length() < tolerance {
+		return
+	}
+	a.linear(p0, p1, u.Normalize(), offset)
+}
+
+// Horizontal adds a dimension of the x distance between two points. A
+// positive offset puts the dimension line above the points.
+func (a *Annotations) Horizontal(p0, p1 V2, offset float64) {
+	a.linear(p0, p1, V2{1, 0}, offset)
+}
+
+// Vertical adds a dimension of the y distance between two points. A positive
+// offset puts the dimension line to the right of the points.
+func (a *Annotations) Vertical(p0, p1 V2, offset float64) {
+	a.linear(p0, p1, V2{0, -1}, offset)
+}
+
+// Radius adds a radius dimension of a circle or arc. The leader runs from the
+// center to the circle at the angle (radians).
+func (a *Annotations) Radius(center V2, radius, angle float64) {
+	u := PolarToXY(1, angle)
+	p := center.Add(u.MulScalar(radius))
+	a.line(center, p)
+	a.arrow(p, u)
+	a.text(center.Add(u.MulScalar(0.5*radius)), u, "R"+a.value(radius))
+}
+
+// Diameter adds a diameter dimension of a circle. The dimension line crosses
+// the circle through the center at the angle (radians).
+func (a *Annotations) Diameter(center V2, radius, angle float64) {
+	u := PolarToXY(1, angle)
+	p0 := center.Sub(u.MulScalar(radius))
+	p1 := center.Add(u.MulScalar(radius))
+	a.line(p0, p1)
+	a.arrow(p0, u.Neg())
+	a.arrow(p1, u)
+	a.text(center, u, "Ø"+a.value(2*radius))
+}
+
+// Label adds horizontal text centered on a point.
+func (a *Annotations) Label(p V2, s string) {
+	a.texts = append(a.texts, annotationText{p, 0, a.textHeight, s})
+}
+
+// Leader adds a label with a leader line from the label to a point.
+func (a *Annotations) Leader(p, label V2, s string) {
+	v := p.Sub(label)
+	if v.Length() > tolerance {
+		a.line(label, p)
+		a.arrow(p, v.Normalize())
+	}
+	a.Label(label, s)
+}
+
+// BoundingBox returns an estimate of the bounding box of the annotations.
+func (a *Annotations) BoundingBox() Box2 {
+	var bb Box2
+	first := true
+	include := func(p V2) {
+		if first {
+			bb = Box2{p, p}
+			first = false
+		}
+		bb = bb.Include(p)
+	}
+	for _, l := range a.lines {
+		include(l[0])
+		include(l[1])
+	}
+	for _, t := range a.texts {
+		// assume the characters are about as wide as they are high
+		w := 0.5 * t.h * float64(len([]rune(t.s)))
+		u := PolarToXY(1, t.angle)
+		n := V2{-u.Y, u.X}.MulScalar(t.h)
+		include(t.p.Sub(u.MulScalar(w)))
+		include(t.p.Add(u.MulScalar(w)))
+		include(t.p.Sub(u.MulScalar(w)).Add(n))
+		include(t.p.Add(u.MulScalar(w)).Add(n))
+	}
+	return bb
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,198 @@
+//-----------------------------------------------------------------------------
+/*
+
+Bitmap Images
+
+Convert a monochrome bitmap (E.g. a pixel art logo or a scanned sketch) into
+an SDF2 so it can be extruded.
+
+Each pixel is inside or outside the shape depending on its brightness. The
+signed distance from each pixel center to the nearest pixel of the other kind
+is found with an exact euclidean distance transform. The boundary is half a
+pixel from the centers of the pixels on either side of it. The distance is
+bilinearly interpolated between pixel centers.
+
+See: Felzenszwalb & Huttenlocher, "Distance Transforms of Sampled Functions"
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"image"
+	"math"
+	"os"
+)
+
+//-----------------------------------------------------------------------------
+
+// bitmapMargin is the number of outside pixels added around the image.
+const bitmapMargin = 2
+
+// edtInf is the initial distance of pixels that aren't set.
+const edtInf = 1e20
+
+// edt1 is the 1D squared euclidean distance transform of f (in place).
+// v and z are work buffers of length len(f) and len(f) + 1.
+func edt1(f []float64, v []int, z []float64) {
+	n := len(f)
+	// the lower envelope of the parabolas rooted at each sample
+	parabola := func(q, r int) float64 {
+		return ((f[q] + float64(q*q)) - (f[r] + float64(r*r))) / float64(2*q-2*r)
+	}
+	k := 0
+	v[0] = 0
+	z[0] = math.Inf(-1)
+	z[1] = math.Inf(1)
+	for q := 1; q < n; q++ {
+		s := parabola(q, v[k])
+		for s <= z[k] {
+			k--
+			s = parabola(q, v[k])
+		}
+		k++
+		v[k] = q
+		z[k] = s
+		z[k+1] = math.Inf(1)
+	}
+	d := make([]float64, n)
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		r := v[k]
+		d[q] = float64((q-r)*(q-r)) + f[r]
+	}
+	copy(f, d)
+}
+
+// edt2 returns the euclidean distance from each pixel to the nearest set
+// pixel of an nx by ny bitmap (row major).
+func edt2(set []bool, nx, ny int) []float64 {
+	f := make([]float64, nx*ny)
+	for i := range f {
+		if !set[i] {
+			f[i] = edtInf
+		}
+	}
+	m := nx
+	if ny > m {
+		m = ny
+	}
+	v := make([]int, m)
+	z := make([]float64, m+1)
+	col := make([]float64, ny)
+	for i := 0; i < nx; i++ {
+		for j := 0; j < ny; j++ {
+			col[j] = f[j*nx+i]
+		}
+		edt1(col, v, z)
+		for j := 0; j < ny; j++ {
+			f[j*nx+i] = col[j]
+		}
+	}
+	for j := 0; j < ny; j++ {
+		edt1(f[j*nx:(j+1)*nx], v, z)
+	}
+	for i := range f {
+		f[i] = math.Sqrt(f[i])
+	}
+	return f
+}
+
+//-----------------------------------------------------------------------------
+
+// BitmapSDF2 is an SDF2 made from a bitmap image.
+type BitmapSDF2 struct {
+	g  grid2 // distance at the pixel centers
+	bb Box2
+}
+
+// ImageSDF2 returns an SDF2 for a bitmap image. Pixels with a brightness
+// (0 is black, 1 is white) less than the threshold are inside the shape.
+// Transparent pixels are treated as white. Each pixel is a square of the
+// given size, and the image is centered on the origin with the top of the
+// image at +y.
+func ImageSDF2(img image.Image, threshold, pixelSize float64) (SDF2, error) {
+	if pixelSize <= 0 {
+		return nil, errors.New("pixelSize <= 0")
+	}
+	r := img.Bounds()
+	if r.Dx() < 1 || r.Dy() < 1 {
+		return nil, errors.New("empty image")
+	}
+	s := BitmapSDF2{}
+	nx := r.Dx() + 2*bitmapMargin
+	ny := r.Dy() + 2*bitmapMargin
+	inside := make([]bool, nx*ny)
+	outside := make([]bool, nx*ny)
+	count := 0
+	for j := 0; j < ny; j++ {
+		for i := 0; i < nx; i++ {
+			x, y := i-bitmapMargin, j-bitmapMargin
+			in := false
+			if x >= 0 && x < r.Dx() && y >= 0 && y < r.Dy() {
+				cr, cg, cb, ca := img.At(r.Min.X+x, r.Min.Y+y).RGBA()
+				// composite over white
+				w := 65535 - ca
+				l := (0.299*float64(cr+w) + 0.587*float64(cg+w) + 0.114*float64(cb+w)) / 65535
+				in = l < threshold
+			}
+			inside[j*nx+i] = in
+			outside[j*nx+i] = !in
+			if in {
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return nil, errors.New("no pixels are inside the threshold")
+	}
+	// distance from the pixel centers to the boundary
+	din := edt2(inside, nx, ny)
+	dout := edt2(outside, nx, ny)
+	d := make([]float64, nx*ny)
+	for i := range d {
+		if inside[i] {
+			d[i] = (0.5 - dout[i]) * pixelSize
+		} else {
+			d[i] = (din[i] - 0.5) * pixelSize
+		}
+	}
+	w := 0.5 * float64(nx-1) * pixelSize
+	h := 0.5 * float64(ny-1) * pixelSize
+	s.g = grid2{d, nx, ny, pixelSize, Box2{V2{-w, -h}, V2{w, h}}}
+	w = 0.5 * float64(r.Dx()) * pixelSize
+	h = 0.5 * float64(r.Dy()) * pixelSize
+	s.bb = Box2{V2{-w, -h}, V2{w, h}}
+	return &s, nil
+}
+
+// LoadImageSDF2 returns an SDF2 for a bitmap image file.
+func LoadImageSDF2(path string, threshold, pixelSize float64) (SDF2, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return ImageSDF2(img, threshold, pixelSize)
+}
+
+// Evaluate returns the minimum distance to a bitmap SDF2.
+func (s *BitmapSDF2) Evaluate(p V2) float64 {
+	return s.g.evaluate(p)
+}
+
+// BoundingBox returns the bounding box for a bitmap SDF2.
+func (s *BitmapSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
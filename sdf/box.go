@@ -58,6 +58,28 @@ func (a Box2) Extend(b Box2) Box2 {
 	return Box2{a.Min.Min(b.Min), a.Max.Max(b.Max)}
 }
 
+// Include returns a box that encloses a 3d box and a point.
+func (a Box3) Include(v V3) Box3 {
+	return Box3{a.Min.Min(v), a.Max.Max(v)}
+}
+
+// Include returns a box that encloses a 2d box and a point.
+func (a Box2) Include(v V2) Box2 {
+	return Box2{a.Min.Min(v), a.Max.Max(v)}
+}
+
+// Contains returns true if a point is within a 3d box.
+func (a Box3) Contains(v V3) bool {
+	return a.Min.X <= v.X && a.Min.Y <= v.Y && a.Min.Z <= v.Z &&
+		v.X <= a.Max.X && v.Y <= a.Max.Y && v.Z <= a.Max.Z
+}
+
+// Contains returns true if a point is within a 2d box.
+func (a Box2) Contains(v V2) bool {
+	return a.Min.X <= v.X && a.Min.Y <= v.Y &&
+		v.X <= a.Max.X && v.Y <= a.Max.Y
+}
+
 //-----------------------------------------------------------------------------
 
 // Translate translates a 3d box.
@@ -0,0 +1,348 @@
+//-----------------------------------------------------------------------------
+/*
+
+Polygon Clipping
+
+Boolean operations (union, intersection, difference, xor) on polygons with an
+explicit polygon result. Union2D etc. combine distance functions, these return
+the vertices of the result so it can be exported (E.g. to DXF) as clean closed
+polylines.
+
+The operands are sets of closed contours with an even-odd fill rule, so holes
+can be given as contours inside other contours with either orientation.
+
+1) All the edges of both operands are split where they intersect or touch.
+2) Each split edge is kept if the result of the operation differs on either
+side of it, and is oriented with the inside of the result on its left.
+3) The kept edges are linked into closed contours.
+
+The result contours are counter-clockwise for outer boundaries and clockwise
+for holes.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"math"
+	"sort"
+)
+
+//-----------------------------------------------------------------------------
+
+// ClipOp is a polygon boolean operation.
+type ClipOp int
+
+// Polygon boolean operations.
+const (
+	ClipUnion        ClipOp = iota // a or b
+	ClipIntersection               // a and b
+	ClipDifference                 // a and not b
+	ClipXor                        // a or b, but not both
+)
+
+// apply returns the result of the boolean operation.
+func (op ClipOp) apply(a, b bool) bool {
+	switch op {
+	case ClipUnion:
+		return a || b
+	case ClipIntersection:
+		return a && b
+	case ClipDifference:
+		return a && !b
+	case ClipXor:
+		return a != b
+	}
+	panic("unknown clip operation")
+}
+
+//-----------------------------------------------------------------------------
+
+// clipEdge is a polygon edge being split.
+type clipEdge struct {
+	a, b  V2        // end points
+	split []float64 // split parameters (0..1)
+	pts   []V2      // split points
+}
+
+// clipPool merges vertices that are within a tolerance of each other.
+type clipPool struct {
+	eps  float64
+	cell map[[2]int64][]int
+	v    []V2
+}
+
+// key returns the grid cell for a point.
+func (p *clipPool) key(v V2) [2]int64 {
+	return [2]int64{int64(math.Floor(v.X / p.eps)), int64(math.Floor(v.Y / p.eps))}
+}
+
+// add returns the index of a pooled vertex.
+func (p *clipPool) add(v V2) int {
+	k := p.key(v)
+	for i := k[0] - 1; i <= k[0]+1; i++ {
+		for j := k[1] - 1; j <= k[1]+1; j++ {
+			for _, idx := range p.cell[[2]int64{i, j}] {
+				if p.v[idx].Sub(v).Length() <= p.eps {
+					return idx
+				}
+			}
+		}
+	}
+	idx := len(p.v)
+	p.v = append(p.v, v)
+	p.cell[k] = append(p.cell[k], idx)
+	return idx
+}
+
+// clipInside returns true if a point is inside a set of contours (even-odd).
+func clipInside(contours [][]V2, p V2) bool {
+	inside := false
+	for _, c := range contours {
+		n := len(c)
+		for i := 0; i < n; i++ {
+			a, b := c[i], c[(i+1)%n]
+			if (a.Y > p.Y) != (b.Y > p.Y) {
+				x := a.X + (p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y)
+				if p.X < x {
+					inside = !inside
+				}
+			}
+		}
+	}
+	return inside
+}
+
+// splitEdges finds the intersections between two edges.
+func splitEdges(e0, e1 *clipEdge, eps float64) {
+	d0 := e0.b.Sub(e0.a)
+	d1 := e1.b.Sub(e1.a)
+	den := d0.Cross(d1)
+	w := e1.a.Sub(e0.a)
+	// split an edge at a point on it
+	onEdge := func(e *clipEdge, p V2) {
+		d := e.b.Sub(e.a)
+		l2 := d.Length2()
+		t := p.Sub(e.a).Dot(d) / l2
+		if t <= 0 || t >= 1 {
+			return
+		}
+		if e.a.Add(d.MulScalar(t)).Sub(p).Length() <= eps {
+			e.split = append(e.split, t)
+			e.pts = append(e.pts, p)
+		}
+	}
+	// end points of one edge on the other edge (touching and overlapping edges)
+	onEdge(e0, e1.a)
+	onEdge(e0, e1.b)
+	onEdge(e1, e0.a)
+	onEdge(e1, e0.b)
+	if Abs(den) < epsilon*d0.Length()*d1.Length() {
+		// parallel
+		return
+	}
+	// proper crossing
+	t0 := w.Cross(d1) / den
+	t1 := w.Cross(d0) / den
+	if t0 > 0 && t0 < 1 && t1 > 0 && t1 < 1 {
+		p := e0.a.Add(d0.MulScalar(t0))
+		e0.split = append(e0.split, t0)
+		e0.pts = append(e0.pts, p)
+		e1.split = append(e1.split, t1)
+		e1.pts = append(e1.pts, p)
+	}
+}
+
+// ClipPolygons returns the result of a boolean operation on two sets of
+// polygon contours.
+func ClipPolygons(a, b [][]V2, op ClipOp) [][]V2 {
+	a = clipContours(a)
+	b = clipContours(b)
+	// scale dependent tolerance
+	var bb Box2
+	first := true
+	for _, c := range append(append([][]V2{}, a...), b...) {
+		for _, v := range c {
+			if first {
+				bb = Box2{v, v}
+				first = false
+			}
+			bb = bb.Include(v)
+		}
+	}
+	if first {
+		return nil
+	}
+	eps := 1e-9 * Max(bb.Size().MaxComponent(), 1)
+
+	// 1) split the edges
+	var edges []*clipEdge
+	for _, c := range append(append([][]V2{}, a...), b...) {
+		for i := range c {
+			edges = append(edges, &clipEdge{a: c[i], b: c[(i+1)%len(c)]})
+		}
+	}
+	for i := range edges {
+		for j := i + 1; j < len(edges); j++ {
+			splitEdges(edges[i], edges[j], eps)
+		}
+	}
+	pool := clipPool{eps: eps, cell: make(map[[2]int64][]int)}
+	type segment struct{ a, b int }
+	seen := make(map[segment]bool)
+	var segs []segment
+	for _, e := range edges {
+		idx := make([]int, len(e.split))
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.Slice(idx, func(i, j int) bool { return e.split[idx[i]] < e.split[idx[j]] })
+		pts := []int{pool.add(e.a)}
+		for _, i := range idx {
+			pts = append(pts, pool.add(e.pts[i]))
+		}
+		pts = append(pts, pool.add(e.b))
+		for i := 0; i < len(pts)-1; i++ {
+			s := segment{pts[i], pts[i+1]}
+			if s.a == s.b {
+				continue
+			}
+			// coincident edges are only needed once
+			if s.a > s.b {
+				s.a, s.b = s.b, s.a
+			}
+			if !seen[s] {
+				seen[s] = true
+				segs = append(segs, s)
+			}
+		}
+	}
+
+	// 2) keep the edges with the result on one side only
+	next := make(map[int][]int)
+	count := 0
+	for _, s := range segs {
+		p0, p1 := pool.v[s.a], pool.v[s.b]
+		d := p1.Sub(p0)
+		m := p0.Add(d.MulScalar(0.5))
+		n := V2{-d.Y, d.X}.Normalize().MulScalar(100 * eps)
+		pl, pr := m.Add(n), m.Sub(n)
+		left := op.apply(clipInside(a, pl), clipInside(b, pl))
+		right := op.apply(clipInside(a, pr), clipInside(b, pr))
+		if left == right {
+			continue
+		}
+		if left {
+			next[s.a] = append(next[s.a], s.b)
+		} else {
+			next[s.b] = append(next[s.b], s.a)
+		}
+		count++
+	}
+
+	// 3) link the edges into contours
+	var result [][]V2
+	for count > 0 {
+		// find a start vertex with an unused edge
+		start := -1
+		for v, x := range next {
+			if len(x) > 0 && (start < 0 || v < start) {
+				start = v
+			}
+		}
+		var contour []int
+		v := start
+		prev := V2{}
+		for {
+			out := next[v]
+			if len(out) == 0 {
+				break
+			}
+			// at a shared vertex take the sharpest left turn, so touching
+			// contours are kept apart
+			k := 0
+			if len(out) > 1 && len(contour) > 0 {
+				din := pool.v[v].Sub(prev)
+				best := math.Inf(-1)
+				for i, w := range out {
+					dout := pool.v[w].Sub(pool.v[v])
+					turn := math.Atan2(din.Cross(dout), din.Dot(dout))
+					if turn > best {
+						best, k = turn, i
+					}
+				}
+			}
+			w := out[k]
+			next[v] = append(out[:k], out[k+1:]...)
+			count--
+			contour = append(contour, v)
+			prev = pool.v[v]
+			v = w
+			if v == start {
+				break
+			}
+		}
+		if c := clipSimplify(pool.v, contour, eps); len(c) >= 3 {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// clipContours removes closing vertices and degenerate contours.
+func clipContours(contours [][]V2) [][]V2 {
+	var out [][]V2
+	for _, c := range contours {
+		n := len(c)
+		if n > 1 && c[0].Equals(c[n-1], tolerance) {
+			n--
+		}
+		if n >= 3 {
+			out = append(out, c[:n])
+		}
+	}
+	return out
+}
+
+// clipSimplify returns the contour vertices without collinear vertices.
+func clipSimplify(v []V2, contour []int, eps float64) []V2 {
+	n := len(contour)
+	var out []V2
+	for i := range contour {
+		p0 := v[contour[(i+n-1)%n]]
+		p1 := v[contour[i]]
+		p2 := v[contour[(i+1)%n]]
+		d0 := p1.Sub(p0)
+		d1 := p2.Sub(p1)
+		if Abs(d0.Cross(d1)) <= eps*(d0.Length()+d1.Length()) && d0.Dot(d1) > 0 {
+			continue
+		}
+		out = append(out, p1)
+	}
+	return out
+}
+
+//-----------------------------------------------------------------------------
+
+// PolygonUnion returns the union of two sets of polygon contours.
+func PolygonUnion(a, b [][]V2) [][]V2 {
+	return ClipPolygons(a, b, ClipUnion)
+}
+
+// PolygonIntersection returns the intersection of two sets of polygon contours.
+func PolygonIntersection(a, b [][]V2) [][]V2 {
+	return ClipPolygons(a, b, ClipIntersection)
+}
+
+// PolygonDifference returns a - b for two sets of polygon contours.
+func PolygonDifference(a, b [][]V2) [][]V2 {
+	return ClipPolygons(a, b, ClipDifference)
+}
+
+// PolygonXor returns the exclusive or of two sets of polygon contours.
+func PolygonXor(a, b [][]V2) [][]V2 {
+	return ClipPolygons(a, b, ClipXor)
+}
+
+//-----------------------------------------------------------------------------
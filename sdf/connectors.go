@@ -1,5 +1,7 @@
 package sdf
 
+import "sort"
+
 // Connector3d stores the information needed to connector to another part
 type Connector3d struct {
 	Position V3
@@ -70,7 +72,7 @@ func (s *SDF3WithConnectors) Connect(parentConnector string, child Connectorized
 	s2.bb = s.BoundingBox().Extend(transformedChild.BoundingBox())
 	s2.min = Min
 
-	s2.connectors = s.Connectors()
+	s2.connectors = copyConnectors(s.Connectors())
 	return &s2
 }
 
@@ -121,13 +123,16 @@ func (s *UnionConnectorizedSDF3) Connect(parentConnector string, child Connector
 
 	s2 := UnionConnectorizedSDF3{}
 
-	s2.sdf = append(s.sdf, transformedChild)
+	// copy the sdf slice so multiple connections to s don't share storage
+	s2.sdf = make([]SDF3, 0, len(s.sdf)+1)
+	s2.sdf = append(s2.sdf, s.sdf...)
+	s2.sdf = append(s2.sdf, transformedChild)
 
 	// work out the bounding box
 	s2.bb = s.BoundingBox().Extend(transformedChild.BoundingBox())
 	s2.min = Min
 
-	s2.connectors = s.Connectors()
+	s2.connectors = copyConnectors(s.Connectors())
 	return &s2
 }
 
@@ -141,3 +146,25 @@ func (s *UnionConnectorizedSDF3) Connectors() map[string]Connector3d {
 	return s.connectors
 
 }
+
+// copyConnectors returns a copy of a connector map.
+func copyConnectors(m map[string]Connector3d) map[string]Connector3d {
+	c := make(map[string]Connector3d, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// ConnectorNames returns the sorted connector names of an SDF3.
+// Use this rather than ranging over the connector map when the
+// iteration order matters (e.g. deterministic output).
+func ConnectorNames(s ConnectorizedSDF3) []string {
+	m := s.Connectors()
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,109 @@
+//-----------------------------------------------------------------------------
+/*
+
+Cycloidal Drives
+
+A cycloidal reducer has a disk with N-1 lobes that rolls around a ring of N
+pins. The disk is driven by an eccentric on the input shaft, for each turn of
+the input the disk turns backwards by one lobe, a reduction of N-1:1.
+
+The disk profile is the curve offset by the pin radius from an epitrochoid.
+See: https://en.wikipedia.org/wiki/Cycloidal_drive
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+// CycloidalParms defines the parameters for a cycloidal drive.
+type CycloidalParms struct {
+	Lobes           int     // number of disk lobes (the ring has Lobes + 1 pins)
+	PinCircleRadius float64 // radius of the circle through the pin centers
+	PinRadius       float64 // radius of the pins
+	Eccentricity    float64 // offset of the disk center from the ring center
+	Facets          int     // number of facets per lobe (0 for a default)
+}
+
+// validate checks the cycloidal drive parameters.
+func (k *CycloidalParms) validate() error {
+	if k.Lobes < 2 {
+		return errors.New("lobes < 2")
+	}
+	if k.PinCircleRadius <= 0 || k.PinRadius <= 0 || k.Eccentricity <= 0 {
+		return errors.New("cycloidal dimensions must be > 0")
+	}
+	n := float64(k.Lobes + 1)
+	if k.Eccentricity*n >= k.PinCircleRadius {
+		return errors.New("eccentricity is too large for the pin circle radius")
+	}
+	if k.PinRadius >= k.PinCircleRadius*math.Sin(Pi/n) {
+		return errors.New("pins overlap")
+	}
+	return nil
+}
+
+// CycloidalDisk2D returns the 2D profile of a cycloidal drive disk. The disk
+// is centered on the origin with a lobe on the positive x axis. In the drive
+// the disk center is offset by the eccentricity from the pin ring center.
+func CycloidalDisk2D(k *CycloidalParms) (SDF2, error) {
+	if err := k.validate(); err != nil {
+		return nil, err
+	}
+	facets := k.Facets
+	if facets <= 0 {
+		facets = 64
+	}
+	n := float64(k.Lobes + 1) // number of pins
+	r := k.PinCircleRadius
+	rr := k.PinRadius
+	e := k.Eccentricity
+	m := facets * k.Lobes
+	v := make([]V2, m)
+	for i := range v {
+		t := Tau * float64(i) / float64(m)
+		psi := math.Atan2(math.Sin((1-n)*t), r/(e*n)-math.Cos((1-n)*t))
+		v[i] = V2{
+			r*math.Cos(t) - rr*math.Cos(t+psi) - e*math.Cos(n*t),
+			-r*math.Sin(t) + rr*math.Sin(t+psi) + e*math.Sin(n*t),
+		}
+	}
+	// check for cusps (the pin radius is too large for the lobe curvature)
+	for i := range v {
+		a := v[(i+m-1)%m]
+		b := v[i]
+		c := v[(i+1)%m]
+		if b.Sub(a).Dot(c.Sub(b)) < 0 {
+			return nil, errors.New("pin radius is too large, the disk profile has cusps")
+		}
+	}
+	return Polygon2D(v), nil
+}
+
+// CycloidalPinRing2D returns the 2D profile of the pin ring for a cycloidal
+// drive, an annulus from the pin circle to the outer radius with the pins
+// protruding inwards. The ring is centered on the origin with a pin on the
+// positive x axis.
+func CycloidalPinRing2D(k *CycloidalParms, outerRadius float64) (SDF2, error) {
+	if err := k.validate(); err != nil {
+		return nil, err
+	}
+	if outerRadius <= k.PinCircleRadius+k.PinRadius {
+		return nil, errors.New("outer radius is too small")
+	}
+	n := k.Lobes + 1
+	pins := make(V2Set, n)
+	for i := range pins {
+		pins[i] = PolarToXY(k.PinCircleRadius, Tau*float64(i)/float64(n))
+	}
+	ring := Difference2D(Circle2D(outerRadius), Circle2D(k.PinCircleRadius))
+	return Union2D(ring, MultiCircle2D(k.PinRadius, pins)), nil
+}
+
+//-----------------------------------------------------------------------------
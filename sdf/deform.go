@@ -0,0 +1,452 @@
+//-----------------------------------------------------------------------------
+/*
+
+Domain Deformations
+
+These operators deform an existing SDF3 by mapping the evaluation point back
+into the space of the undeformed SDF3. The mapping stretches space, so the
+distance returned by the undeformed SDF3 is scaled down by the maximum stretch
+to keep it a conservative (lower bound) distance. Otherwise marching cubes and
+ray marching can step over thin features of the deformed surface.
+
+The wraps map 2d artwork (e.g. text) onto a curved surface and emboss or
+engrave it. The artwork is extruded into a flat layer, and the layer is
+evaluated in surface coordinates: distance along the surface and height above
+the surface.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+// Bend
+
+// BendSDF3 is an SDF3 bent around a cylindrical axis.
+type BendSDF3 struct {
+	sdf     SDF3
+	radius  float64 // bend radius (signed)
+	t, n, a V3      // bend direction, bend center direction, bend axis
+	k       float64 // distance scaling factor
+	bb      Box3
+}
+
+// Bend3D bends an SDF3 around a cylinder. The x-axis of the SDF3 is wrapped
+// around a circle of the given radius. The axis of the cylinder is parallel to
+// axis (which must be perpendicular to the x-axis) and passes through the point
+// radius * (x cross axis). E.g. with axis = +y, a negative radius wraps a plaque
+// in the xy plane around the outside of a cylinder below it, and a positive radius
+// curls the ends of the plaque upwards. The SDF3 must not cross the cylinder axis,
+// and the length of the SDF3 along x should be less than 2 * Pi * radius.
+func Bend3D(sdf SDF3, radius float64, axis V3) SDF3 {
+	if radius == 0 {
+		panic("radius == 0")
+	}
+	a := axis.Normalize()
+	t := V3{1, 0, 0}
+	if Abs(a.Dot(t)) > tolerance {
+		panic("axis is not perpendicular to the x-axis")
+	}
+	s := BendSDF3{}
+	s.sdf = sdf
+	s.radius = radius
+	s.t = t
+	s.a = a
+	s.n = t.Cross(a)
+	// the range of the undeformed sdf in the (t, n, a) frame
+	var x0, x1, h0, h1, w0, w1 float64
+	for i, v := range sdf.BoundingBox().Vertices() {
+		x, h, w := v.Dot(s.t), v.Dot(s.n), v.Dot(s.a)
+		if i == 0 {
+			x0, x1, h0, h1, w0, w1 = x, x, h, h, w, w
+			continue
+		}
+		x0, x1 = Min(x0, x), Max(x1, x)
+		h0, h1 = Min(h0, h), Max(h1, h)
+		w0, w1 = Min(w0, w), Max(w1, w)
+	}
+	// radius of the sdf about the bend axis (relative to the bend radius)
+	r0 := (radius - h1) / radius
+	r1 := (radius - h0) / radius
+	if Min(r0, r1) <= 0 {
+		panic("the bend axis crosses the sdf")
+	}
+	// The bend compresses space on the inside of the bend, so scale the
+	// distance by the minimum compression.
+	s.k = Min(1, Min(r0, r1))
+	// work out the bounding box of the bent annular sector
+	phi0, phi1 := x0/radius, x1/radius
+	if phi0 > phi1 {
+		phi0, phi1 = phi1, phi0
+	}
+	angles := []float64{phi0, phi1}
+	for k := math.Ceil(phi0 / (0.5 * Pi)); k*0.5*Pi < phi1; k++ {
+		angles = append(angles, k*0.5*Pi)
+	}
+	var bb Box3
+	for i, phi := range angles {
+		sin, cos := math.Sincos(phi)
+		for j, r := range []float64{r0 * radius, r1 * radius} {
+			x := r * sin
+			h := radius - r*cos
+			for k, w := range []float64{w0, w1} {
+				p := s.t.MulScalar(x).Add(s.n.MulScalar(h)).Add(s.a.MulScalar(w))
+				if i == 0 && j == 0 && k == 0 {
+					bb = Box3{p, p}
+				} else {
+					bb = bb.Include(p)
+				}
+			}
+		}
+	}
+	s.bb = bb
+	return &s
+}
+
+// BendAngle3D bends an SDF3 around a cylinder (see Bend3D) so that its length
+// along the x-axis is bent through the given angle (radians).
+func BendAngle3D(sdf SDF3, angle float64, axis V3) SDF3 {
+	if angle == 0 {
+		panic("angle == 0")
+	}
+	bb := sdf.BoundingBox()
+	return Bend3D(sdf, bb.Size().X/angle, axis)
+}
+
+// Evaluate returns the minimum distance to a bent SDF3.
+func (s *BendSDF3) Evaluate(p V3) float64 {
+	x, h, w := p.Dot(s.t), p.Dot(s.n), p.Dot(s.a)
+	// radius and angle about the bend axis
+	sign := Sign(s.radius)
+	r := sign * math.Sqrt(x*x+(h-s.radius)*(h-s.radius))
+	phi := math.Atan2(sign*x, sign*(s.radius-h))
+	// unbent position
+	q := s.t.MulScalar(s.radius * phi).Add(s.n.MulScalar(s.radius - r)).Add(s.a.MulScalar(w))
+	return s.sdf.Evaluate(q) * s.k
+}
+
+// BoundingBox returns the bounding box of a bent SDF3.
+func (s *BendSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Taper
+
+// TaperSDF3 is an SDF3 tapered along the z-axis.
+type TaperSDF3 struct {
+	sdf    SDF3
+	z0, z1 float64 // z range of the taper
+	s0, s1 float64 // xy scale at z0 and z1
+	smin   float64 // minimum xy scale
+	slope  float64 // rate of change of the xy scale
+	l      float64 // maximum xy radius of the undeformed sdf
+	bb     Box3
+}
+
+// Taper3D tapers an SDF3 along the z-axis. The xy scale (about the z-axis)
+// changes linearly from 1 at the bottom of the SDF3 bounding box to scale at
+// the top of the bounding box.
+func Taper3D(sdf SDF3, scale float64) SDF3 {
+	if scale <= 0 {
+		panic("scale <= 0")
+	}
+	s := TaperSDF3{}
+	s.sdf = sdf
+	bb := sdf.BoundingBox()
+	s.z0, s.z1 = bb.Min.Z, bb.Max.Z
+	s.s0, s.s1 = 1, scale
+	s.smin = Min(s.s0, s.s1)
+	s.l = V2{Max(-bb.Min.X, bb.Max.X), Max(-bb.Min.Y, bb.Max.Y)}.Length()
+	if s.z1 > s.z0 {
+		s.slope = Abs(s.s1-s.s0) / (s.z1 - s.z0)
+	}
+	// work out the bounding box
+	bb0 := Box3{bb.Min.Mul(V3{s.s0, s.s0, 1}), bb.Max.Mul(V3{s.s0, s.s0, 1})}
+	bb1 := Box3{bb.Min.Mul(V3{s.s1, s.s1, 1}), bb.Max.Mul(V3{s.s1, s.s1, 1})}
+	s.bb = bb0.Extend(bb1)
+	return &s
+}
+
+// scale returns the xy scale at height z.
+func (s *TaperSDF3) scale(z float64) float64 {
+	if s.z1 <= s.z0 {
+		return s.s0
+	}
+	t := Clamp((z-s.z0)/(s.z1-s.z0), 0, 1)
+	return s.s0 + t*(s.s1-s.s0)
+}
+
+// Evaluate returns the minimum distance to a tapered SDF3.
+func (s *TaperSDF3) Evaluate(p V3) float64 {
+	k := s.scale(p.Z)
+	q := V3{p.X / k, p.Y / k, p.Z}
+	// The xy scaling changes the xy distances by up to 1/smin, and the slope
+	// of the taper tilts the surface by an amount that grows with the radius.
+	// Scale the distance by the maximum stretch.
+	l := Max(s.l, V2{q.X, q.Y}.Length()) * s.slope / s.smin
+	return s.sdf.Evaluate(q) / math.Sqrt(Max(1, 1/(s.smin*s.smin))+l*l)
+}
+
+// BoundingBox returns the bounding box of a tapered SDF3.
+func (s *TaperSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Shear
+
+// ShearSDF3 is an SDF3 sheared along the z-axis.
+type ShearSDF3 struct {
+	sdf   SDF3
+	shear V2      // xy displacement per unit of z
+	k     float64 // distance scaling factor
+	bb    Box3
+}
+
+// Shear3D shears an SDF3 along the z-axis. Each point is displaced in xy by
+// shear * z, e.g. shear = (1, 0) leans the SDF3 at 45 degrees towards +x.
+func Shear3D(sdf SDF3, shear V2) SDF3 {
+	s := ShearSDF3{}
+	s.sdf = sdf
+	s.shear = shear
+	// the maximum stretch (largest singular value) of a shear
+	c := shear.Length()
+	s.k = 0.5 * (c + math.Sqrt(c*c+4))
+	// work out the bounding box
+	var bb Box3
+	for i, v := range sdf.BoundingBox().Vertices() {
+		p := V3{v.X + shear.X*v.Z, v.Y + shear.Y*v.Z, v.Z}
+		if i == 0 {
+			bb = Box3{p, p}
+		} else {
+			bb = bb.Include(p)
+		}
+	}
+	s.bb = bb
+	return &s
+}
+
+// Evaluate returns the minimum distance to a sheared SDF3.
+func (s *ShearSDF3) Evaluate(p V3) float64 {
+	q := V3{p.X - s.shear.X*p.Z, p.Y - s.shear.Y*p.Z, p.Z}
+	return s.sdf.Evaluate(q) / s.k
+}
+
+// BoundingBox returns the bounding box of a sheared SDF3.
+func (s *ShearSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Wrap
+
+// WrapSDF3 is a flat layer wrapped onto a curved surface.
+type WrapSDF3 struct {
+	sdf    SDF3          // flat layer, z is the height above the surface
+	unwrap func(p V3) V3 // surface coordinates of a point
+	h0, h1 float64       // height range of the layer
+	k      float64       // distance scaling factor
+	bb     Box3
+}
+
+// Evaluate returns the minimum distance to a wrapped layer.
+func (s *WrapSDF3) Evaluate(p V3) float64 {
+	q := s.unwrap(p)
+	// The height above (or below) the layer is a distance bound. The surface
+	// coordinates are stretched more and more away from the surface, so only
+	// use the layer distance near the layer (within its thickness).
+	h := Max(s.h0-q.Z, q.Z-s.h1)
+	t := s.h1 - s.h0
+	return Max(h, Min(s.sdf.Evaluate(q)*s.k, 2*t-h))
+}
+
+// BoundingBox returns the bounding box of a wrapped layer.
+func (s *WrapSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+// newWrap returns a wrap of the emboss layer for 2d artwork, and the lowest
+// height at which the layer distance is used.
+func newWrap(art SDF2, depth float64) (*WrapSDF3, float64) {
+	s := WrapSDF3{}
+	s.sdf = embossLayer(art, depth)
+	if depth > 0 {
+		s.h0, s.h1 = 0, depth
+	} else {
+		s.h0, s.h1 = depth, -depth
+	}
+	return &s, 2*s.h0 - s.h1
+}
+
+// CylinderWrap3D wraps 2d artwork around a cylinder of the given radius (on
+// the z-axis) and embosses (depth > 0) or engraves (depth < 0) it onto an SDF3.
+// The artwork origin is placed at (radius, 0, 0), the artwork x-axis goes
+// counter-clockwise around the cylinder and the y-axis is along z, so the
+// artwork reads correctly from outside the cylinder.
+func CylinderWrap3D(
+	sdf SDF3, // SDF3 to be embossed
+	art SDF2, // 2d artwork
+	radius float64, // radius of the cylinder
+	depth float64, // height of the artwork (> 0 emboss, < 0 engrave)
+) (SDF3, error) {
+	if radius <= 0 {
+		return nil, errors.New("radius <= 0")
+	}
+	abb := art.BoundingBox()
+	if abb.Max.X-abb.Min.X > Tau*radius {
+		return nil, errors.New("artwork is wider than the cylinder circumference")
+	}
+	s, hmin := newWrap(art, depth)
+	if radius+hmin <= 0 {
+		return nil, errors.New("depth is too large for the radius")
+	}
+	s.unwrap = func(p V3) V3 {
+		r := math.Sqrt(p.X*p.X + p.Y*p.Y)
+		return V3{radius * math.Atan2(p.Y, p.X), p.Z, r - radius}
+	}
+	// the circumference is stretched inside the cylinder
+	s.k = (radius + hmin) / radius
+	// work out the bounding box
+	r := radius + s.h1
+	s.bb = Box3{V3{-r, -r, abb.Min.Y}, V3{r, r, abb.Max.Y}}
+	return emboss(sdf, s, depth), nil
+}
+
+// SphereWrap3D wraps 2d artwork onto a sphere of the given radius (centered
+// on the origin) and embosses (depth > 0) or engraves (depth < 0) it onto an
+// SDF3, e.g. for domed caps. The artwork origin is placed at the top of the
+// sphere (0, 0, radius) and distances from the origin of the artwork are
+// preserved (an azimuthal equidistant projection). Seen from above, the artwork
+// x-axis is along x and the y-axis is along y. The artwork must fit on the
+// upper hemisphere.
+func SphereWrap3D(
+	sdf SDF3, // SDF3 to be embossed
+	art SDF2, // 2d artwork
+	radius float64, // radius of the sphere
+	depth float64, // height of the artwork (> 0 emboss, < 0 engrave)
+) (SDF3, error) {
+	if radius <= 0 {
+		return nil, errors.New("radius <= 0")
+	}
+	// the maximum angle (from the top) of the artwork
+	var phi float64
+	for _, v := range art.BoundingBox().Vertices() {
+		phi = Max(phi, v.Length()/radius)
+	}
+	if phi > 0.5*Pi {
+		return nil, errors.New("artwork doesn't fit on the hemisphere")
+	}
+	s, hmin := newWrap(art, depth)
+	if radius+hmin <= 0 {
+		return nil, errors.New("depth is too large for the radius")
+	}
+	s.unwrap = func(p V3) V3 {
+		// angles from the z-axis and about the z-axis
+		phi := math.Atan2(math.Sqrt(p.X*p.X+p.Y*p.Y), p.Z)
+		sin, cos := math.Sincos(math.Atan2(p.Y, p.X))
+		return V3{radius * phi * cos, radius * phi * sin, p.Length() - radius}
+	}
+	// The circles about the z-axis are stretched inside the sphere, and by the
+	// projection (more so further from the top). Use the stretch half way from
+	// the artwork to the bottom, beyond that the artwork is far away.
+	x := 0.5 * (phi + Pi)
+	s.k = (radius + hmin) / radius * math.Sin(x) / x
+	// work out the bounding box
+	sin, cos := math.Sincos(phi)
+	r0, r1 := radius+s.h0, radius+s.h1
+	s.bb = Box3{V3{-r1 * sin, -r1 * sin, r0 * cos}, V3{r1 * sin, r1 * sin, r1}}
+	return emboss(sdf, s, depth), nil
+}
+
+// ConeWrap3D wraps 2d artwork onto a cone and embosses (depth > 0) or engraves
+// (depth < 0) it onto an SDF3, e.g. for funnels. The cone is the surface of
+// Cone3D(height, r0, r1, 0). The artwork origin is placed at the middle of the
+// cone on the x-axis, the artwork x-axis goes counter-clockwise around the cone
+// and the y-axis goes up the slope of the cone. The cone is unrolled onto the
+// plane of the artwork, so distances on the artwork are preserved. The artwork
+// must not reach the apex of the cone.
+func ConeWrap3D(
+	sdf SDF3, // SDF3 to be embossed
+	art SDF2, // 2d artwork
+	height float64, // height of the cone
+	r0 float64, // radius of the cone at -height/2
+	r1 float64, // radius of the cone at +height/2
+	depth float64, // height of the artwork (> 0 emboss, < 0 engrave)
+) (SDF3, error) {
+	if height <= 0 {
+		return nil, errors.New("height <= 0")
+	}
+	if r0 < 0 || r1 < 0 {
+		return nil, errors.New("radius < 0")
+	}
+	if r0 == r1 {
+		return CylinderWrap3D(sdf, art, r0, depth)
+	}
+	// radius at z = 0, slope (dr/dz), slope and normal directions
+	rm := 0.5 * (r0 + r1)
+	t := (r1 - r0) / height
+	l := math.Sqrt(1 + t*t)
+	u := V2{t, 1}.DivScalar(l)
+	n := V2{1, -t}.DivScalar(l)
+	// +1 if the apex is below, -1 if it is above
+	sign := Sign(t)
+	// The unrolled cone is a sector of a circle centered on the apex. The
+	// angles about the z-axis are scaled by sin(half angle of the cone).
+	sinA := Abs(t) / l
+	s0 := rm / sinA
+	// the artwork bounding box in unrolled coordinates (relative to the apex)
+	abb := art.BoundingBox()
+	var bb Box2
+	if sign > 0 {
+		bb = Box2{V2{abb.Min.X, s0 + abb.Min.Y}, V2{abb.Max.X, s0 + abb.Max.Y}}
+	} else {
+		bb = Box2{V2{abb.Min.X, s0 - abb.Max.Y}, V2{abb.Max.X, s0 - abb.Min.Y}}
+	}
+	if bb.Min.Y <= 0 {
+		return nil, errors.New("artwork reaches the apex of the cone")
+	}
+	// distance range of the artwork from the apex
+	dmin := V2{Clamp(0, bb.Min.X, bb.Max.X), bb.Min.Y}.Length()
+	var dmax float64
+	for _, v := range bb.Vertices() {
+		dmax = Max(dmax, v.Length())
+	}
+	s, hmin := newWrap(art, depth)
+	// The circles about the z-axis are stretched inside the cone, more so
+	// nearer the apex. Use the stretch half way from the artwork to the apex,
+	// beyond that the artwork is far away.
+	c := 0.5 * dmin * sinA
+	if c+hmin/l <= 0 {
+		return nil, errors.New("depth is too large for the cone")
+	}
+	s.k = Min(1, (c+hmin/l)/c)
+	s.unwrap = func(p V3) V3 {
+		w := V2{math.Sqrt(p.X*p.X+p.Y*p.Y) - rm, p.Z}
+		// distance from the apex (along the slope) and angle in the unrolled cone
+		d := s0 + sign*w.Dot(u)
+		sin, cos := math.Sincos(math.Atan2(p.Y, p.X) * sinA)
+		return V3{d * sin, sign * (d*cos - s0), w.Dot(n)}
+	}
+	// work out the bounding box
+	var x, z0, z1 float64
+	for i, d := range []float64{dmin, dmax} {
+		for j, h := range []float64{s.h0, s.h1} {
+			v := V2{rm, 0}.Add(u.MulScalar(sign * (d - s0))).Add(n.MulScalar(h))
+			if i == 0 && j == 0 {
+				x, z0, z1 = v.X, v.Y, v.Y
+				continue
+			}
+			x, z0, z1 = Max(x, v.X), Min(z0, v.Y), Max(z1, v.Y)
+		}
+	}
+	s.bb = Box3{V3{-x, -x, z0}, V3{x, x, z1}}
+	return emboss(sdf, s, depth), nil
+}
+
+//-----------------------------------------------------------------------------
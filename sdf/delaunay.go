@@ -15,6 +15,8 @@ package sdf
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"sort"
 )
 
@@ -28,6 +30,11 @@ func (t TriangleI) ToTriangle2(p []V2) Triangle2 {
 	return Triangle2{p[t[0]], p[t[1]], p[t[2]]}
 }
 
+// ToTriangle3 given vertex indices and the vertex array, return the triangle with real vertices.
+func (t TriangleI) ToTriangle3(p []V3) *Triangle3 {
+	return NewTriangle3(p[t[0]], p[t[1]], p[t[2]])
+}
+
 // TriangleIByIndex sorts triangles by index.
 type TriangleIByIndex []TriangleI
 
@@ -75,6 +82,62 @@ func (t *TriangleI) Canonical() {
 // TriangleISet is a set of triangles defined by vertice indices.
 type TriangleISet []TriangleI
 
+// check returns an error if a triangle set has a vertex index that is not
+// in a vertex set of size n.
+func (ts TriangleISet) check(n int) error {
+	for _, t := range ts {
+		for _, i := range t {
+			if i < 0 || i >= n {
+				return fmt.Errorf("bad vertex index %d", i)
+			}
+		}
+	}
+	return nil
+}
+
+// Edges returns the edges of a triangle set. Each edge is returned once, with
+// the lowest index first.
+func (ts TriangleISet) Edges() []EdgeI {
+	seen := make(map[EdgeI]bool)
+	var edges []EdgeI
+	for _, t := range ts {
+		for j := 0; j < 3; j++ {
+			e := EdgeI{t[j], t[(j+1)%3]}.undirected()
+			if !seen[e] {
+				seen[e] = true
+				edges = append(edges, e)
+			}
+		}
+	}
+	return edges
+}
+
+// Mesh returns the triangle mesh of a triangle set with 3d vertices.
+func (ts TriangleISet) Mesh(vs []V3) ([]*Triangle3, error) {
+	if err := ts.check(len(vs)); err != nil {
+		return nil, err
+	}
+	mesh := make([]*Triangle3, len(ts))
+	for i, t := range ts {
+		mesh[i] = t.ToTriangle3(vs)
+	}
+	return mesh, nil
+}
+
+// Lift returns 3d vertices for a 2d vertex set, with the z values given by a
+// height function (z = 0 if it is nil).
+func (vs V2Set) Lift(height func(p V2) float64) V3Set {
+	out := make(V3Set, len(vs))
+	for i, p := range vs {
+		z := 0.0
+		if height != nil {
+			z = height(p)
+		}
+		out[i] = V3{p.X, p.Y, z}
+	}
+	return out
+}
+
 // Canonical converts a triangle set to it's canonical form.
 // This common form is used to facilitate comparison
 // between the results of different implementations.
@@ -122,17 +185,17 @@ func (vs V2Set) SuperTriangle() (Triangle2, error) {
 	var p V2
 	var k float64
 
-	if len(vs) == 1 {
-		// a single point
-		p := vs[0]
-		k = p.MaxComponent() * 0.125
+	b := Box2{vs.Min(), vs.Max()}
+	if size := b.Size().MaxComponent(); size > 0 {
+		p = b.Center()
+		k = size * 2.0
+	} else {
+		// a single point (or repeated copies of it)
+		p = vs[0]
+		k = p.Abs().MaxComponent() * 0.125
 		if k == 0 {
 			k = 1
 		}
-	} else {
-		b := Box2{vs.Min(), vs.Max()}
-		p = b.Center()
-		k = b.Size().MaxComponent() * 2.0
 	}
 
 	// Note: super triangles should be large enough to avoid having the circumcenter of
@@ -202,136 +265,272 @@ func (t Triangle2) Circumcenter() (V2, error) {
 
 // InCircumcircle return inside == true if the point is inside the circumcircle of the triangle.
 // Returns done == true if the vertex and the subsequent x-ordered vertices are outside the circumcircle.
+// The inside test is exact (see inCircle), a point on the circumcircle is outside.
 func (t Triangle2) InCircumcircle(p V2) (inside, done bool) {
-	c, err := t.Circumcenter()
-	if err != nil {
+	o := orient2d(t[0], t[1], t[2])
+	if o == 0 {
+		// degenerate triangle
 		inside = false
 		done = true
 		return
 	}
 
+	// is the point within the circumcircle?
+	ic := inCircle(t[0], t[1], t[2], p)
+	if o < 0 {
+		ic = -ic
+	}
+	inside = ic > 0
+
+	c, err := t.Circumcenter()
+	if err != nil {
+		return
+	}
+
 	// radius squared of circumcircle
 	dx := t[0].X - c.X
 	dy := t[0].Y - c.Y
 	r2 := dx*dx + dy*dy
 
-	// distance squared from circumcenter to point
+	// x distance from circumcenter to point
 	dx = p.X - c.X
-	dy = p.Y - c.Y
-	d2 := dx*dx + dy*dy
-
-	// is the point within the circumcircle?
-	inside = d2-r2 <= epsilon
 
 	// If this vertex has an x-value beyond the circumcenter and the distance based on the x-delta
 	// is greater than the circumradius, then this triangle is done for this and all subsequent vertices
 	// since the vertex list has been sorted by x-value.
-	done = (dx > 0) && (dx*dx > r2)
+	done = !inside && (dx > 0) && (dx*dx > r2)
 
 	return
 }
 
 //-----------------------------------------------------------------------------
 
-// Delaunay2d returns the delaunay triangulation of a 2d point set.
-func (vs V2Set) Delaunay2d() (TriangleISet, error) {
-
-	// number of vertices
-	n := len(vs)
+// delaunayMesh is a counter-clockwise triangulation with the triangle
+// adjacency, for inserting points with the Bowyer-Watson algorithm. Each edge
+// of the convex hull has a ghost triangle on its outside, made with a vertex
+// at infinity, so points outside the hull are inserted like points inside it.
+type delaunayMesh struct {
+	vs   []V2
+	inf  int         // index of the vertex at infinity
+	t    []TriangleI // triangle vertices
+	nb   [][3]int    // neighbor across the edge t[i]-t[i+1], -1 for none
+	dead []bool      // the triangle has been removed
+	last int         // start of the next point location walk
+}
 
-	// sort the vertices by x value
-	sort.Sort(V2SetByX(vs))
+// addTriangle adds a triangle and returns its index.
+func (m *delaunayMesh) addTriangle(t TriangleI) int {
+	m.t = append(m.t, t)
+	m.nb = append(m.nb, [3]int{-1, -1, -1})
+	m.dead = append(m.dead, false)
+	return len(m.t) - 1
+}
 
-	// work out the super triangle
-	t, err := vs.SuperTriangle()
-	if err != nil {
-		return nil, err
+// ghost returns the position of the vertex at infinity in the k-th triangle,
+// or -1 if the triangle is finite.
+func (m *delaunayMesh) ghost(k int) int {
+	for i, v := range m.t[k] {
+		if v == m.inf {
+			return i
+		}
 	}
+	return -1
+}
 
-	// add the super triangle to the vertex set
-	vs = append(vs, t[:]...)
-
-	// allocate the triangles
-	k := (2 * n) + 1
-	ts := make([]TriangleI, 0, k)
-	done := make([]bool, 0, k)
-
-	// set the super triangle as the 0th triangle
-	ts = append(ts, TriangleI{n, n + 1, n + 2})
-	done = append(done, false)
+// conflict returns true if a point is inside the circumcircle of the k-th
+// triangle. The circumcircle of a ghost triangle is the open half plane
+// outside its hull edge, plus the inside of the hull edge.
+func (m *delaunayMesh) conflict(k int, p V2) bool {
+	t := m.t[k]
+	if g := m.ghost(k); g >= 0 {
+		a, b := m.vs[t[(g+1)%3]], m.vs[t[(g+2)%3]]
+		d := orient2d(a, b, p)
+		return d > 0 || (d == 0 && onSegment(a, b, p) && p != a && p != b)
+	}
+	return inCircle(m.vs[t[0]], m.vs[t[1]], m.vs[t[2]], p) > 0
+}
 
-	// Add the vertices one at a time into the mesh
-	// Note: we don't iterate over the super triangle vertices
-	for i := 0; i < n; i++ {
-		v := vs[i]
+// locate returns a finite triangle containing the point, or a ghost
+// triangle in conflict with it for a point outside the hull. It walks from
+// the last triangle towards the point, so points that are inserted near each
+// other are found quickly.
+func (m *delaunayMesh) locate(p V2) int {
+	t := m.last
+	if g := m.ghost(t); g >= 0 {
+		if m.conflict(t, p) {
+			return t
+		}
+		// step across the hull edge
+		t = m.nb[t][(g+1)%3]
+	}
+	for n := 0; n < len(m.t); n++ {
+		next := -1
+		for k := 0; k < 3; k++ {
+			// rotate the first edge that is tested to avoid walking in a cycle
+			i := (k + n) % 3
+			a, b := m.vs[m.t[t][i]], m.vs[m.t[t][(i+1)%3]]
+			if orient2d(a, b, p) < 0 {
+				next = m.nb[t][i]
+				break
+			}
+		}
+		if next < 0 {
+			return t
+		}
+		if m.ghost(next) >= 0 {
+			// p is outside the hull edge
+			return next
+		}
+		t = next
+	}
+	// no luck walking, search all the triangles
+	for i, t := range m.t {
+		if m.dead[i] {
+			continue
+		}
+		if m.ghost(i) >= 0 {
+			if m.conflict(i, p) {
+				return i
+			}
+		} else if orient2d(m.vs[t[0]], m.vs[t[1]], p) >= 0 &&
+			orient2d(m.vs[t[1]], m.vs[t[2]], p) >= 0 && orient2d(m.vs[t[2]], m.vs[t[0]], p) >= 0 {
+			return i
+		}
+	}
+	return m.last
+}
 
-		// Create the edge buffer.
-		// If the vertex lies inside the circumcircle of the triangle
-		// then the three edges of that triangle are added to the edge
-		// buffer and that triangle is removed.
-		es := make([]EdgeI, 0, 64)
-		nt := len(ts)
-		for j := 0; j < nt; j++ {
-			if done[j] {
+// insert adds the i-th vertex to the triangulation. The triangles with the
+// vertex in their circumcircle are removed, and the cavity is filled with
+// triangles from the vertex to the cavity boundary.
+func (m *delaunayMesh) insert(i int) {
+	p := m.vs[i]
+	t0 := m.locate(p)
+	for _, v := range m.t[t0] {
+		if v != m.inf && m.vs[v] == p {
+			// duplicate vertex
+			return
+		}
+	}
+	// find the cavity
+	cavity := []int{t0}
+	m.dead[t0] = true
+	for k := 0; k < len(cavity); k++ {
+		for _, n := range m.nb[cavity[k]] {
+			if n < 0 || m.dead[n] {
 				continue
 			}
-
-			t := ts[j].ToTriangle2(vs)
-			inside, complete := t.InCircumcircle(v)
-			done[j] = complete
-
-			if inside {
-				// add the triangle edges to the edge set
-				es = append(es, EdgeI{ts[j][0], ts[j][1]})
-				es = append(es, EdgeI{ts[j][1], ts[j][2]})
-				es = append(es, EdgeI{ts[j][2], ts[j][0]})
-				// remove the triangle (copy in the tail)
-				ts[j] = ts[nt-1]
-				done[j] = done[nt-1]
-				nt--
-				j--
+			if m.conflict(n, p) {
+				m.dead[n] = true
+				cavity = append(cavity, n)
 			}
 		}
-
-		// re-size the triangle/done sets
-		ts = ts[:nt]
-		done = done[:nt]
-
-		// Tag duplicate edges for removal.
-		for j := 0; j < len(es)-1; j++ {
-			for k := j + 1; k < len(es); k++ {
-				if (es[j][0] == es[k][1] && es[j][1] == es[k][0]) ||
-					(es[j][1] == es[k][1] && es[j][0] == es[k][0]) {
-					es[j] = EdgeI{-1, -1}
-					es[k] = EdgeI{-1, -1}
+	}
+	// triangles from the cavity boundary edges to the vertex
+	start := make(map[int]int)
+	var added []int
+	for _, c := range cavity {
+		for j := 0; j < 3; j++ {
+			n := m.nb[c][j]
+			if n >= 0 && m.dead[n] {
+				continue
+			}
+			a, b := m.t[c][j], m.t[c][(j+1)%3]
+			k := m.addTriangle(TriangleI{a, b, i})
+			m.nb[k][0] = n
+			if n >= 0 {
+				// the outside neighbor now borders the new triangle
+				for e := 0; e < 3; e++ {
+					if m.nb[n][e] == c {
+						m.nb[n][e] = k
+					}
 				}
 			}
+			start[a] = k
+			added = append(added, k)
 		}
+	}
+	// link the new triangles to each other
+	for _, k := range added {
+		k1 := start[m.t[k][1]]
+		m.nb[k][1] = k1
+		m.nb[k1][2] = k
+	}
+	m.last = added[0]
+}
 
-		// Form new triangles for the current point skipping over any duplicate edges.
-		for _, e := range es {
-			if e[0] < 0 || e[1] < 0 {
-				continue
-			}
-			ts = append(ts, TriangleI{e[0], e[1], i})
-			done = append(done, false)
+// Delaunay2d returns the delaunay triangulation of a 2d point set.
+// The vertex set is sorted by x value and the triangles have a
+// counter-clockwise winding. Duplicate vertices are ignored. If the
+// vertices are all collinear there are no triangles.
+func (vs V2Set) Delaunay2d() (TriangleISet, error) {
+
+	// number of vertices
+	n := len(vs)
+	if n == 0 {
+		return nil, errors.New("no vertices")
+	}
+
+	// sort the vertices by x value
+	sort.Sort(V2SetByX(vs))
+
+	// the first triangle is any 3 vertices that are not collinear
+	a, b, c := 0, -1, -1
+	for i := 1; i < n && b < 0; i++ {
+		if vs[i] != vs[a] {
+			b = i
+		}
+	}
+	for i := b + 1; b >= 0 && i < n && c < 0; i++ {
+		if orient2d(vs[a], vs[b], vs[i]) != 0 {
+			c = i
 		}
 	}
+	if c < 0 {
+		return TriangleISet{}, nil
+	}
+	m := &delaunayMesh{vs: append(vs[:n:n], V2{}), inf: n}
+	t := TriangleI{a, b, c}.ccw(vs)
+	m.addTriangle(t)
+	// the ghost triangles on the hull edges
+	for j := 0; j < 3; j++ {
+		m.addTriangle(TriangleI{t[(j+1)%3], t[j], n})
+	}
+	for j := 0; j < 3; j++ {
+		m.nb[0][j] = 1 + j
+		m.nb[1+j] = [3]int{0, 1 + (j+2)%3, 1 + (j+1)%3}
+	}
 
-	// remove any triangles with vertices from the super triangle
-	nt := len(ts)
-	for j := 0; j < nt; j++ {
-		t := ts[j]
-		if t[0] >= n || t[1] >= n || t[2] >= n {
-			// remove the triangle (copy in the tail)
-			ts[j] = ts[nt-1]
-			nt--
-			j--
+	// Insert the vertices in columns, alternately up and down each column, so
+	// each vertex is near the previous one and is found with a short walk.
+	cols := int(math.Sqrt(float64(n))/2) + 1
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	for c := 0; c < cols; c++ {
+		col := order[c*n/cols : (c+1)*n/cols]
+		up := c%2 == 0
+		sort.Slice(col, func(i, j int) bool {
+			if up {
+				return vs[col[i]].Y < vs[col[j]].Y
+			}
+			return vs[col[i]].Y > vs[col[j]].Y
+		})
+	}
+	for _, i := range order {
+		if i != a && i != b && i != c {
+			m.insert(i)
 		}
 	}
 
-	// re-size the triangle set
-	ts = ts[:nt]
+	// the finite triangles
+	ts := make([]TriangleI, 0, 2*n)
+	for i, t := range m.t {
+		if !m.dead[i] && m.ghost(i) < 0 {
+			ts = append(ts, t)
+		}
+	}
 
 	// done
 	return ts, nil
@@ -346,8 +545,11 @@ func (vs V2Set) Delaunay2dSlow() (TriangleISet, error) {
 
 	// number of vertices
 	n := len(vs)
+	if n == 0 {
+		return nil, errors.New("no vertices")
+	}
 	if n < 3 {
-		return nil, errors.New("number of vertices < 3")
+		return TriangleISet{}, nil
 	}
 
 	// map the 2d points onto a 3d parabola
@@ -366,6 +568,14 @@ func (vs V2Set) Delaunay2dSlow() (TriangleISet, error) {
 
 		t := TriangleI{c[0], c[1], c[2]}
 
+		// skip collinear (or repeated) vertices
+		if orient2d(vs[t[0]], vs[t[1]], vs[t[2]]) == 0 {
+			if !NextCombination(n, c) {
+				break
+			}
+			continue
+		}
+
 		p0 := vs[t[0]].ToV3(z[t[0]])
 		p1 := vs[t[1]].ToV3(z[t[1]])
 		p2 := vs[t[2]].ToV3(z[t[2]])
@@ -411,3 +621,350 @@ func (vs V2Set) Delaunay2dSlow() (TriangleISet, error) {
 }
 
 //-----------------------------------------------------------------------------
+// Constrained Delaunay Triangulation
+
+// ccw returns the triangle with a counter-clockwise winding.
+func (t TriangleI) ccw(vs []V2) TriangleI {
+	if orient2d(vs[t[0]], vs[t[1]], vs[t[2]]) < 0 {
+		t[1], t[2] = t[2], t[1]
+	}
+	return t
+}
+
+// undirected returns the edge with the lowest index first.
+func (e EdgeI) undirected() EdgeI {
+	if e[0] > e[1] {
+		return EdgeI{e[1], e[0]}
+	}
+	return e
+}
+
+// crosses returns true if the segment a-b crosses the segment c-d at a
+// point that is not an end point of either segment.
+func crosses(a, b, c, d V2) bool {
+	return orient2d(a, b, c)*orient2d(a, b, d) < 0 && orient2d(c, d, a)*orient2d(c, d, b) < 0
+}
+
+// third returns the vertex of a triangle that is not on the edge u-v.
+func (t TriangleI) third(u, v int) int {
+	for _, x := range t {
+		if x != u && x != v {
+			return x
+		}
+	}
+	return -1
+}
+
+// cdt is a counter-clockwise triangulation with the triangle adjacency, for
+// adding constrained edges.
+type cdt struct {
+	vs      []V2
+	ts      []TriangleI
+	tri     map[EdgeI]int  // triangle by directed edge
+	fixed   map[EdgeI]bool // constrained edges (lowest index first)
+	track   bool           // record the changed triangles
+	changed []int          // triangles changed (if track)
+}
+
+// newCDT returns a cdt for counter-clockwise triangles.
+func newCDT(vs []V2, ts []TriangleI) *cdt {
+	c := &cdt{
+		vs:    vs,
+		ts:    ts,
+		tri:   make(map[EdgeI]int),
+		fixed: make(map[EdgeI]bool),
+	}
+	for i, t := range ts {
+		for j := 0; j < 3; j++ {
+			c.tri[EdgeI{t[j], t[(j+1)%3]}] = i
+		}
+	}
+	return c
+}
+
+// setTriangle replaces the i-th triangle.
+func (c *cdt) setTriangle(i int, t TriangleI) {
+	old := c.ts[i]
+	for j := 0; j < 3; j++ {
+		if e := (EdgeI{old[j], old[(j+1)%3]}); c.tri[e] == i {
+			delete(c.tri, e)
+		}
+	}
+	c.ts[i] = t
+	for j := 0; j < 3; j++ {
+		c.tri[EdgeI{t[j], t[(j+1)%3]}] = i
+	}
+	if c.track {
+		c.changed = append(c.changed, i)
+	}
+}
+
+// flip replaces the edge u-v, the diagonal of the quadrilateral formed by
+// the two triangles on the edge, with the other diagonal. It returns false if
+// the quadrilateral isn't convex.
+func (c *cdt) flip(e EdgeI) (EdgeI, bool) {
+	u, v := e[0], e[1]
+	t0, ok0 := c.tri[EdgeI{u, v}]
+	t1, ok1 := c.tri[EdgeI{v, u}]
+	if !ok0 || !ok1 {
+		return e, false
+	}
+	w := c.ts[t0].third(u, v)
+	x := c.ts[t1].third(u, v)
+	if !crosses(c.vs[w], c.vs[x], c.vs[u], c.vs[v]) {
+		return e, false
+	}
+	c.setTriangle(t0, TriangleI{w, u, x})
+	c.setTriangle(t1, TriangleI{x, v, w})
+	return EdgeI{w, x}, true
+}
+
+// legalize flips the edges that are not delaunay, unless they are
+// constrained, until the triangulation is delaunay about the edges.
+func (c *cdt) legalize(edges []EdgeI) {
+	for n := 0; len(edges) > 0 && n < 16*len(c.ts); n++ {
+		e := edges[len(edges)-1]
+		edges = edges[:len(edges)-1]
+		if c.fixed[e.undirected()] {
+			continue
+		}
+		t0, ok0 := c.tri[e]
+		t1, ok1 := c.tri[EdgeI{e[1], e[0]}]
+		if !ok0 || !ok1 {
+			continue
+		}
+		u, v := e[0], e[1]
+		w := c.ts[t0].third(u, v)
+		x := c.ts[t1].third(u, v)
+		if inCircle(c.vs[u], c.vs[v], c.vs[w], c.vs[x]) <= 0 {
+			continue
+		}
+		if _, ok := c.flip(e); ok {
+			edges = append(edges, EdgeI{u, x}, EdgeI{x, v}, EdgeI{v, w}, EdgeI{w, u})
+		}
+	}
+}
+
+// insertEdge adds the constrained edge a-b. The edges crossing a-b are
+// flipped until none cross it, then the triangulation is made delaunay
+// about the new edges. If the edge passes through other vertices it is split
+// at these vertices, the constrained edges that were added are returned.
+func (c *cdt) insertEdge(a, b int) []EdgeI {
+	vs := c.vs
+	// split the edge at collinear vertices
+	ab := vs[b].Sub(vs[a])
+	l2 := ab.Length2()
+	for i, v := range vs {
+		if i == a || i == b {
+			continue
+		}
+		if Abs(orient2d(vs[a], vs[b], v)) > tolerance*l2 {
+			continue
+		}
+		if t := v.Sub(vs[a]).Dot(ab); t > 0 && t < l2 {
+			return append(c.insertEdge(a, i), c.insertEdge(i, b)...)
+		}
+	}
+	e := EdgeI{a, b}
+	c.fixed[e.undirected()] = true
+	if _, ok := c.tri[e]; ok {
+		return []EdgeI{e}
+	}
+	if _, ok := c.tri[EdgeI{b, a}]; ok {
+		return []EdgeI{e}
+	}
+	// the edges crossing a-b, constrained edges can't be crossed
+	var queue []EdgeI
+	for _, t := range c.ts {
+		for j := 0; j < 3; j++ {
+			u, v := t[j], t[(j+1)%3]
+			if u < v && !c.fixed[EdgeI{u, v}] && crosses(vs[a], vs[b], vs[u], vs[v]) {
+				queue = append(queue, EdgeI{u, v})
+			}
+		}
+	}
+	// flip the crossing edges, a non-convex quadrilateral is tried again later
+	var created []EdgeI
+	for n := 0; len(queue) > 0 && n < 16*len(c.ts); n++ {
+		x := queue[0]
+		queue = queue[1:]
+		y, ok := c.flip(x)
+		if !ok {
+			queue = append(queue, x)
+			continue
+		}
+		if crosses(vs[a], vs[b], vs[y[0]], vs[y[1]]) {
+			queue = append(queue, y)
+		} else {
+			created = append(created, y)
+		}
+	}
+	c.legalize(created)
+	return []EdgeI{e}
+}
+
+// constrainedDelaunay returns the constrained delaunay triangulation of a
+// point set, and the constrained edges (split at collinear vertices).
+func constrainedDelaunay(vs V2Set, edges []EdgeI) ([]TriangleI, []EdgeI, error) {
+	// delaunay triangulation, in terms of the original vertex indices
+	idx := make(map[V2]int)
+	for i, v := range vs {
+		if _, ok := idx[v]; ok {
+			return nil, nil, errors.New("duplicate vertices")
+		}
+		idx[v] = i
+	}
+	sorted := append(V2Set(nil), vs...)
+	dt, err := sorted.Delaunay2d()
+	if err != nil {
+		return nil, nil, err
+	}
+	ts := make([]TriangleI, len(dt))
+	for i, t := range dt {
+		for j := range t {
+			ts[i][j] = idx[sorted[t[j]]]
+		}
+		ts[i] = ts[i].ccw(vs)
+	}
+	// add the edges
+	c := newCDT(vs, ts)
+	var constrained []EdgeI
+	for _, e := range edges {
+		if e[0] < 0 || e[0] >= len(vs) || e[1] < 0 || e[1] >= len(vs) {
+			return nil, nil, fmt.Errorf("edge %v: bad vertex index", e)
+		}
+		if e[0] == e[1] {
+			continue
+		}
+		constrained = append(constrained, c.insertEdge(e[0], e[1])...)
+	}
+	return c.ts, constrained, nil
+}
+
+// ConstrainedDelaunay2d returns the constrained delaunay triangulation of a
+// 2d point set. The edges (pairs of vertex indices) are edges of the
+// triangulation, otherwise the triangles are as close to delaunay as the
+// edges allow. An edge passing through a vertex is split at the vertex. The
+// triangles have a counter-clockwise winding and cover the convex hull of the
+// points. The vertex set is not modified.
+func (vs V2Set) ConstrainedDelaunay2d(edges []EdgeI) (TriangleISet, error) {
+	ts, _, err := constrainedDelaunay(vs, edges)
+	return ts, err
+}
+
+// interiorTriangles returns true for the triangles that are inside an odd
+// number of the closed outlines formed by the edges.
+func interiorTriangles(ts []TriangleI, edges []EdgeI) []bool {
+	constrained := make(map[EdgeI]bool)
+	for _, e := range edges {
+		constrained[e.undirected()] = true
+	}
+	// triangles by directed edge
+	tri := make(map[EdgeI]int)
+	for i, t := range ts {
+		for j := 0; j < 3; j++ {
+			tri[EdgeI{t[j], t[(j+1)%3]}] = i
+		}
+	}
+	// flood fill from the outside, crossing an edge changes the depth
+	depth := make([]int, len(ts))
+	for i := range depth {
+		depth[i] = -1
+	}
+	var queue []int
+	fill := func(i, d int) {
+		if depth[i] < 0 {
+			depth[i] = d
+			queue = append(queue, i)
+		}
+	}
+	for i, t := range ts {
+		for j := 0; j < 3; j++ {
+			e := EdgeI{t[j], t[(j+1)%3]}
+			if _, ok := tri[EdgeI{e[1], e[0]}]; !ok {
+				// on the hull
+				if constrained[e.undirected()] {
+					fill(i, 1)
+				} else {
+					fill(i, 0)
+				}
+			}
+		}
+	}
+	// breadth first, so the outer regions get the lower depth
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		t := ts[i]
+		for j := 0; j < 3; j++ {
+			e := EdgeI{t[j], t[(j+1)%3]}
+			k, ok := tri[EdgeI{e[1], e[0]}]
+			if !ok {
+				continue
+			}
+			if constrained[e.undirected()] {
+				fill(k, depth[i]+1)
+			} else {
+				fill(k, depth[i])
+			}
+		}
+	}
+	inside := make([]bool, len(ts))
+	for i := range ts {
+		inside[i] = depth[i]%2 == 1
+	}
+	return inside
+}
+
+// outlineEdges returns the vertices and edges of closed outlines.
+func outlineEdges(outlines [][]V2) (V2Set, []EdgeI, error) {
+	var vs V2Set
+	var edges []EdgeI
+	idx := make(map[V2]int)
+	for _, outline := range outlines {
+		n := len(outline)
+		if n < 3 {
+			return nil, nil, errors.New("outline has less than 3 vertices")
+		}
+		vi := make([]int, n)
+		for i, v := range outline {
+			k, ok := idx[v]
+			if !ok {
+				k = len(vs)
+				idx[v] = k
+				vs = append(vs, v)
+			}
+			vi[i] = k
+		}
+		for i := range vi {
+			if e := (EdgeI{vi[i], vi[(i+1)%n]}); e[0] != e[1] {
+				edges = append(edges, e)
+			}
+		}
+	}
+	return vs, edges, nil
+}
+
+// Triangulate2d returns the constrained delaunay triangulation of the regions
+// enclosed by closed outlines, E.g. a polygon with holes. The regions are
+// inside an odd number of outlines. The outlines may be in any direction and
+// the triangles have a counter-clockwise winding.
+func Triangulate2d(outlines ...[]V2) (V2Set, TriangleISet, error) {
+	vs, edges, err := outlineEdges(outlines)
+	if err != nil {
+		return nil, nil, err
+	}
+	ts, constrained, err := constrainedDelaunay(vs, edges)
+	if err != nil {
+		return nil, nil, err
+	}
+	var inside []TriangleI
+	for i, in := range interiorTriangles(ts, constrained) {
+		if in {
+			inside = append(inside, ts[i])
+		}
+	}
+	return vs, inside, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,282 @@
+//-----------------------------------------------------------------------------
+/*
+
+Deterministic Output
+
+The same model and parameters should produce the same output files regardless
+of the platform they are rendered on.
+
+What is guaranteed: in deterministic mode the same model and parameters give
+byte-identical output files on the same platform (architecture, Go version and
+build), regardless of how the meshing work is scheduled.
+
+What isn't guaranteed: byte-identical output files across architectures (E.g.
+amd64 and arm64). That would need every SDF evaluation function, including
+user written ones, to avoid fused multiply-add and platform specific math
+routines, and there is no way to enforce that. Deterministic mode makes cross
+platform output match in practice, but a content-addressed cache shared between
+architectures should be keyed on the model and its parameters (see the metadata
+sidecar files), not on the output bytes.
+
+These are the things that get in the way of identical output:
+
+1) Fused multiply-add. The Go compiler is allowed to fuse x*y + z into a single
+FMA instruction on some architectures (arm64, ppc64le, s390x) but not others
+(amd64). The fused result is rounded once rather than twice, so the low bits
+differ. An explicit float64() conversion of a product forces the intermediate
+rounding and prevents fusion. This is done for the vector, matrix and mesh
+interpolation code that directly produces output coordinates, and for the
+distance scaling of transformed SDFs, which applies to every distance below
+the transform.
+
+2) Math library differences. Some math functions have architecture specific
+implementations that may differ in the last bit.
+
+3) Map iteration order. Go map iteration order is randomised, so anything
+that generates geometry from a map must iterate over sorted keys.
+
+Items 1 and 2 can't be fixed for every SDF evaluation function, but the
+resulting differences are at the ulp level. In deterministic mode the output
+coordinates are snapped to a fixed grid that is much coarser than these
+differences, so they almost always disappear. They can still show up: a
+coordinate within an ulp of a rounding boundary of the grid can snap either
+way, and a sampled distance within an ulp of zero can change sign, which
+changes the marching cubes topology for that cell.
+
+4) Output ordering. The order in which triangles/lines are generated depends
+on the meshing algorithm and may vary between runs if the meshing is done in
+parallel. In deterministic mode the output is buffered and sorted, so the
+ordering depends only on the output coordinates.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+)
+
+//-----------------------------------------------------------------------------
+
+// deterministicQuantum is the output coordinate grid size in deterministic mode (2^-16).
+const deterministicQuantum = 1.0 / 65536.0
+
+// deterministic is non-zero when deterministic output mode is enabled.
+// It is accessed atomically since renders may be running when it is changed.
+var deterministic int32
+
+// SetDeterministic enables/disables deterministic output mode.
+// When enabled, rendered output coordinates are snapped to a fixed grid of
+// 2^-16 model units so that platform specific floating point differences
+// (almost always) don't change the output files, and triangles/lines are
+// output in a stable sorted order. The output files are byte-identical on the
+// same platform. Across architectures they match in practice, but that isn't
+// guaranteed.
+// It is safe to call while rendering, each output file is written in a
+// single mode.
+func SetDeterministic(enable bool) {
+	var x int32
+	if enable {
+		x = 1
+	}
+	atomic.StoreInt32(&deterministic, x)
+}
+
+// Deterministic returns true if deterministic output mode is enabled.
+func Deterministic() bool {
+	return atomic.LoadInt32(&deterministic) != 0
+}
+
+//-----------------------------------------------------------------------------
+
+// quantize snaps a value to the deterministic output grid.
+func quantize(x float64) float64 {
+	y := math.Round(x/deterministicQuantum) * deterministicQuantum
+	if y == 0 {
+		return 0 // avoid -0
+	}
+	return y
+}
+
+// Quantize snaps a V3 to the deterministic output grid.
+func (a V3) Quantize() V3 {
+	return V3{quantize(a.X), quantize(a.Y), quantize(a.Z)}
+}
+
+// Quantize snaps a V2 to the deterministic output grid.
+func (a V2) Quantize() V2 {
+	return V2{quantize(a.X), quantize(a.Y)}
+}
+
+// quantizeTriangle returns a triangle snapped to the deterministic output grid.
+func quantizeTriangle(t *Triangle3) *Triangle3 {
+	return NewTriangle3(t.V[0].Quantize(), t.V[1].Quantize(), t.V[2].Quantize())
+}
+
+// quantizeLine returns a line snapped to the deterministic output grid.
+func quantizeLine(l *Line) *Line {
+	return &Line{l[0].Quantize(), l[1].Quantize()}
+}
+
+//-----------------------------------------------------------------------------
+
+// outputTriangles returns a triangle mesh ready for output (quantized and sorted in deterministic mode).
+func outputTriangles(mesh []*Triangle3) []*Triangle3 {
+	if !Deterministic() {
+		return mesh
+	}
+	return quantizeTriangles(mesh)
+}
+
+// quantizeTriangles returns a quantized and sorted copy of a triangle mesh.
+func quantizeTriangles(mesh []*Triangle3) []*Triangle3 {
+	out := make([]*Triangle3, len(mesh))
+	for i, t := range mesh {
+		out[i] = quantizeTriangle(t)
+	}
+	SortTriangles(out)
+	return out
+}
+
+// outputLines returns line segments ready for output (quantized and sorted in deterministic mode).
+func outputLines(mesh []*Line) []*Line {
+	if !Deterministic() {
+		return mesh
+	}
+	return quantizeLines(mesh)
+}
+
+// quantizeLines returns a quantized and sorted copy of a set of line segments.
+func quantizeLines(mesh []*Line) []*Line {
+	out := make([]*Line, len(mesh))
+	for i, l := range mesh {
+		out[i] = quantizeLine(l)
+	}
+	SortLines(out)
+	return out
+}
+
+// outputTriangleStream returns a stream of triangles ready for output.
+// In deterministic mode the input is buffered until it is closed and the
+// triangles are then output in sorted order.
+func outputTriangleStream(in <-chan *Triangle3) <-chan *Triangle3 {
+	if !Deterministic() {
+		return in
+	}
+	out := make(chan *Triangle3)
+	go func() {
+		var mesh []*Triangle3
+		for t := range in {
+			mesh = append(mesh, t)
+		}
+		for _, t := range quantizeTriangles(mesh) {
+			out <- t
+		}
+		close(out)
+	}()
+	return out
+}
+
+// outputLineStream returns a stream of line segments ready for output.
+// In deterministic mode the input is buffered until it is closed and the
+// line segments are then output in sorted order.
+func outputLineStream(in <-chan *Line) <-chan *Line {
+	if !Deterministic() {
+		return in
+	}
+	out := make(chan *Line)
+	go func() {
+		var mesh []*Line
+		for l := range in {
+			mesh = append(mesh, l)
+		}
+		for _, l := range quantizeLines(mesh) {
+			out <- l
+		}
+		close(out)
+	}()
+	return out
+}
+
+//-----------------------------------------------------------------------------
+// Stable Ordering
+
+// compareV3 returns -1, 0, 1 for a < b, a == b, a > b (lexicographic x, y, z).
+func compareV3(a, b V3) int {
+	switch {
+	case a.X != b.X:
+		return compareFloat(a.X, b.X)
+	case a.Y != b.Y:
+		return compareFloat(a.Y, b.Y)
+	default:
+		return compareFloat(a.Z, b.Z)
+	}
+}
+
+// compareV2 returns -1, 0, 1 for a < b, a == b, a > b (lexicographic x, y).
+func compareV2(a, b V2) int {
+	if a.X != b.X {
+		return compareFloat(a.X, b.X)
+	}
+	return compareFloat(a.Y, b.Y)
+}
+
+func compareFloat(a, b float64) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// canonical returns the triangle with the vertices rotated so the smallest
+// vertex is first. The winding order is unchanged.
+func (t *Triangle3) canonical() *Triangle3 {
+	i := 0
+	if compareV3(t.V[1], t.V[i]) < 0 {
+		i = 1
+	}
+	if compareV3(t.V[2], t.V[i]) < 0 {
+		i = 2
+	}
+	if i == 0 {
+		return t
+	}
+	return NewTriangle3(t.V[i], t.V[(i+1)%3], t.V[(i+2)%3])
+}
+
+// SortTriangles sorts a triangle mesh into a stable order that depends only
+// on the triangle vertices. The vertices of each triangle are rotated (with
+// the winding order unchanged) so the smallest vertex is first.
+func SortTriangles(mesh []*Triangle3) {
+	for i, t := range mesh {
+		mesh[i] = t.canonical()
+	}
+	sort.SliceStable(mesh, func(i, j int) bool {
+		a, b := mesh[i], mesh[j]
+		for k := 0; k < 3; k++ {
+			if c := compareV3(a.V[k], b.V[k]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// SortLines sorts line segments into a stable order that depends only on the line end points.
+func SortLines(mesh []*Line) {
+	sort.SliceStable(mesh, func(i, j int) bool {
+		a, b := mesh[i], mesh[j]
+		if c := compareV2(a[0], b[0]); c != 0 {
+			return c < 0
+		}
+		return compareV2(a[1], b[1]) < 0
+	})
+}
+
+//-----------------------------------------------------------------------------
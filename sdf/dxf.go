@@ -15,6 +15,7 @@ import (
 	"github.com/yofu/dxf"
 	"github.com/yofu/dxf/color"
 	"github.com/yofu/dxf/drawing"
+	"github.com/yofu/dxf/entity"
 	"github.com/yofu/dxf/table"
 )
 
@@ -31,6 +32,7 @@ func NewDXF(name string) *DXF {
 	d := dxf.NewDrawing()
 	d.AddLayer("Lines", dxf.DefaultColor, dxf.DefaultLineType, true)
 	d.AddLayer("Points", color.Red, table.LT_CONTINUOUS, true)
+	d.AddLayer("Dimensions", color.Blue, table.LT_CONTINUOUS, true)
 	return &DXF{
 		name:    name,
 		drawing: d,
@@ -62,11 +64,35 @@ func (d *DXF) Points(s V2Set, r float64) {
 	}
 }
 
+// Polyline adds a closed polyline to a dxf drawing object.
+func (d *DXF) Polyline(s V2Set) error {
+	d.drawing.ChangeLayer("Lines")
+	v := make([][]float64, len(s))
+	for i, p := range s {
+		v[i] = []float64{p.X, p.Y}
+	}
+	_, err := d.drawing.LwPolyline(true, v...)
+	return err
+}
+
 // Triangle adds a triangle to a dxf drawing object.
 func (d *DXF) Triangle(t Triangle2) {
 	d.Lines([]V2{t[0], t[1], t[2], t[0]})
 }
 
+// Annotations adds dimensions and labels to a dxf drawing object.
+func (d *DXF) Annotations(a *Annotations) {
+	d.drawing.ChangeLayer("Dimensions")
+	for _, l := range a.lines {
+		d.drawing.Line(l[0].X, l[0].Y, 0, l[1].X, l[1].Y, 0)
+	}
+	for _, t := range a.texts {
+		x, _ := d.drawing.Text(t.s, t.p.X, t.p.Y, 0, t.h)
+		x.Rotation = RtoD(t.angle)
+		x.Anchor(entity.CENTER_BOTTOM)
+	}
+}
+
 // Save writes a dxf drawing object to a file.
 func (d *DXF) Save() error {
 	err := d.drawing.SaveAs(d.name)
@@ -82,9 +108,11 @@ func (d *DXF) Save() error {
 func SaveDXF(path string, mesh []*Line) error {
 	d := NewDXF(path)
 	d.drawing.ChangeLayer("Lines")
+	mesh = outputLines(mesh)
 	for i := range mesh {
-		p0 := mesh[i][0]
-		p1 := mesh[i][1]
+		l := mesh[i]
+		p0 := l[0]
+		p1 := l[1]
 		d.drawing.Line(p0.X, p0.Y, 0, p1.X, p1.Y, 0)
 	}
 	err := d.Save()
@@ -94,6 +122,29 @@ func SaveDXF(path string, mesh []*Line) error {
 	return nil
 }
 
+// SaveAnnotatedDXF writes line segments and annotations to a DXF file.
+func SaveAnnotatedDXF(path string, mesh []*Line, a *Annotations) error {
+	d := NewDXF(path)
+	d.drawing.ChangeLayer("Lines")
+	for _, l := range outputLines(mesh) {
+		d.drawing.Line(l[0].X, l[0].Y, 0, l[1].X, l[1].Y, 0)
+	}
+	d.Annotations(a)
+	return d.Save()
+}
+
+// SaveTrianglesDXF writes the edges of a 2d triangle set to a DXF file.
+func SaveTrianglesDXF(path string, vs []V2, ts TriangleISet) error {
+	if err := ts.check(len(vs)); err != nil {
+		return err
+	}
+	var mesh []*Line
+	for _, e := range ts.Edges() {
+		mesh = append(mesh, &Line{vs[e[0]], vs[e[1]]})
+	}
+	return SaveDXF(path, mesh)
+}
+
 //-----------------------------------------------------------------------------
 
 // WriteDXF writes a stream of line segments to a DXF file.
@@ -109,7 +160,7 @@ func WriteDXF(wg *sync.WaitGroup, path string) (chan<- *Line, error) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for l := range c {
+		for l := range outputLineStream(c) {
 			p0 := l[0]
 			p1 := l[1]
 			d.drawing.Line(p0.X, p0.Y, 0, p1.X, p1.Y, 0)
@@ -0,0 +1,477 @@
+//-----------------------------------------------------------------------------
+/*
+
+DXF Load
+
+Read the 2D profiles of a DXF drawing so they can be extruded, revolved etc.
+
+LINE, ARC, CIRCLE, LWPOLYLINE (with arc bulges) and SPLINE entities are read.
+Curves are tessellated to a chord tolerance. Open entities are chained end to
+end into closed contours. Other entities (text, dimensions, block inserts, ...)
+are ignored.
+
+Holes are found by nesting: a contour inside an odd number of other contours
+is a hole.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// dxfGroup is a DXF group code/value pair.
+type dxfGroup struct {
+	code  int
+	value string
+}
+
+// float returns the group value as a float.
+func (g *dxfGroup) float() (float64, error) {
+	return strconv.ParseFloat(g.value, 64)
+}
+
+// int returns the group value as an integer.
+func (g *dxfGroup) int() (int, error) {
+	return strconv.Atoi(g.value)
+}
+
+// dxfEntities returns the entities in the ENTITIES section of a DXF file.
+// Each entity is a list of groups starting with the entity type group.
+func dxfEntities(r io.Reader) ([][]dxfGroup, error) {
+	scanner := bufio.NewScanner(r)
+	var groups []dxfGroup
+	n := 0
+	for scanner.Scan() {
+		n++
+		code, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad group code", n)
+		}
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("line %d: missing group value", n)
+		}
+		n++
+		groups = append(groups, dxfGroup{code, strings.TrimSpace(scanner.Text())})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	var entities [][]dxfGroup
+	inEntities := false
+	for i := 0; i < len(groups); i++ {
+		g := groups[i]
+		if g.code != 0 {
+			if inEntities && len(entities) > 0 {
+				k := len(entities) - 1
+				entities[k] = append(entities[k], g)
+			}
+			continue
+		}
+		switch g.value {
+		case "SECTION":
+			inEntities = i+1 < len(groups) && groups[i+1].code == 2 && groups[i+1].value == "ENTITIES"
+			i++
+		case "ENDSEC", "EOF":
+			inEntities = false
+		default:
+			if inEntities {
+				entities = append(entities, []dxfGroup{g})
+			}
+		}
+	}
+	return entities, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// arcSteps returns the number of line segments for an arc to be within a
+// chord tolerance.
+func arcSteps(radius, angle, tol float64) int {
+	n := 4.0
+	if tol < radius {
+		n = math.Ceil(Abs(angle) / (2 * math.Acos(1-tol/radius)))
+	}
+	return int(Clamp(n, 1, 4096))
+}
+
+// arcPoints returns the tessellated points of an arc.
+func arcPoints(center V2, radius, a0, sweep, tol float64) []V2 {
+	n := arcSteps(radius, sweep, tol)
+	p := make([]V2, n+1)
+	for i := range p {
+		a := a0 + sweep*float64(i)/float64(n)
+		p[i] = center.Add(V2{math.Cos(a), math.Sin(a)}.MulScalar(radius))
+	}
+	return p
+}
+
+// bulgePoints returns the tessellated points of a polyline arc segment, p0 is
+// not included. The bulge is tan(included angle/4), positive for a
+// counter-clockwise arc.
+func bulgePoints(p0, p1 V2, bulge, tol float64) []V2 {
+	v := p1.Sub(p0)
+	c := v.Length()
+	if bulge == 0 || c == 0 {
+		return []V2{p1}
+	}
+	// the center is on the chord bisector
+	n := V2{-v.Y, v.X}.DivScalar(c)
+	center := p0.Add(v.MulScalar(0.5)).Add(n.MulScalar(0.5 * c * (1 - bulge*bulge) / (2 * bulge)))
+	d := p0.Sub(center)
+	p := arcPoints(center, d.Length(), math.Atan2(d.Y, d.X), 4*math.Atan(bulge), tol)
+	// use the exact end point so the segments join up
+	p[len(p)-1] = p1
+	return p[1:]
+}
+
+// splinePoints returns the tessellated points of a spline entity.
+func splinePoints(degree int, knots []float64, ctrl []V2, weight []float64, fit []V2, tol float64) ([]V2, error) {
+	if len(ctrl) == 0 {
+		// no control points, approximate the curve with the fit points
+		if len(fit) < 2 {
+			return nil, errors.New("spline has no control points")
+		}
+		return fit, nil
+	}
+	if len(weight) != len(ctrl) {
+		weight = nil
+	}
+	n, err := NewNURBS(degree, ctrl, weight, knots)
+	if err != nil {
+		return nil, err
+	}
+	t0, t1 := n.Domain()
+	f := n.Point
+	// start with a few samples per knot span and subdivide until the
+	// mid-point is within tolerance of the chord
+	m := 4 * len(ctrl)
+	p := []V2{f(t0)}
+	var subdivide func(ta, tb float64, a, b V2, depth int)
+	subdivide = func(ta, tb float64, a, b V2, depth int) {
+		tm := 0.5 * (ta + tb)
+		c := f(tm)
+		e := c.Sub(a).Length()
+		if !a.Equals(b, 0) {
+			e = Abs(newLinePP(a, b).Distance(c))
+		}
+		if depth < 12 && e > tol {
+			subdivide(ta, tm, a, c, depth+1)
+			subdivide(tm, tb, c, b, depth+1)
+			return
+		}
+		p = append(p, b)
+	}
+	for i := 0; i < m; i++ {
+		ta := t0 + (t1-t0)*float64(i)/float64(m)
+		tb := t0 + (t1-t0)*float64(i+1)/float64(m)
+		subdivide(ta, tb, p[len(p)-1], f(tb), 0)
+	}
+	return p, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// dxfPath is a tessellated DXF entity.
+type dxfPath struct {
+	p      []V2
+	closed bool
+}
+
+// dxfEntityPath returns the tessellated path for an entity, or nil for an ignored
+// entity type.
+func dxfEntityPath(e []dxfGroup, tol float64) (*dxfPath, error) {
+	var x, y, r, a0, a1 []float64
+	var bulge []float64
+	var knots, weight []float64
+	var fx, fy []float64
+	flags, degree := 0, 0
+	for i := 1; i < len(e); i++ {
+		g := &e[i]
+		var err error
+		var v float64
+		switch g.code {
+		case 10, 20, 11, 21, 40, 41, 42, 50, 51:
+			v, err = g.float()
+		case 70:
+			flags, err = g.int()
+		case 71:
+			degree, err = g.int()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", e[0].value, err)
+		}
+		switch g.code {
+		case 10:
+			x = append(x, v)
+			// a bulge follows its vertex
+			bulge = append(bulge, 0)
+		case 20:
+			y = append(y, v)
+		case 11:
+			fx = append(fx, v)
+		case 21:
+			fy = append(fy, v)
+		case 40:
+			r = append(r, v)
+			knots = append(knots, v)
+		case 41:
+			weight = append(weight, v)
+		case 42:
+			if len(bulge) > 0 {
+				bulge[len(bulge)-1] = v
+			}
+		case 50:
+			a0 = append(a0, v)
+		case 51:
+			a1 = append(a1, v)
+		}
+	}
+	if len(x) != len(y) || len(fx) != len(fy) {
+		return nil, fmt.Errorf("%s: bad coordinates", e[0].value)
+	}
+	pts := make([]V2, len(x))
+	for i := range pts {
+		pts[i] = V2{x[i], y[i]}
+	}
+
+	switch e[0].value {
+	case "LINE":
+		if len(pts) != 1 || len(fx) != 1 {
+			return nil, errors.New("LINE: bad definition")
+		}
+		return &dxfPath{p: []V2{pts[0], {fx[0], fy[0]}}}, nil
+	case "CIRCLE":
+		if len(pts) != 1 || len(r) != 1 || r[0] <= 0 {
+			return nil, errors.New("CIRCLE: bad definition")
+		}
+		p := arcPoints(pts[0], r[0], 0, Tau, tol)
+		return &dxfPath{p: p[:len(p)-1], closed: true}, nil
+	case "ARC":
+		if len(pts) != 1 || len(r) != 1 || len(a0) != 1 || len(a1) != 1 || r[0] <= 0 {
+			return nil, errors.New("ARC: bad definition")
+		}
+		// counter-clockwise from the start to the end angle
+		start := DtoR(a0[0])
+		sweep := DtoR(a1[0]) - start
+		for sweep <= 0 {
+			sweep += Tau
+		}
+		return &dxfPath{p: arcPoints(pts[0], r[0], start, sweep, tol)}, nil
+	case "LWPOLYLINE":
+		if len(pts) < 2 {
+			return nil, errors.New("LWPOLYLINE: not enough vertices")
+		}
+		closed := flags&1 != 0
+		p := []V2{pts[0]}
+		for i := 0; i < len(pts); i++ {
+			j := i + 1
+			if j == len(pts) {
+				if !closed {
+					break
+				}
+				j = 0
+			}
+			p = append(p, bulgePoints(pts[i], pts[j], bulge[i], tol)...)
+		}
+		if closed {
+			p = p[:len(p)-1]
+		}
+		return &dxfPath{p: p, closed: closed}, nil
+	case "SPLINE":
+		fit := make([]V2, len(fx))
+		for i := range fit {
+			fit[i] = V2{fx[i], fy[i]}
+		}
+		p, err := splinePoints(degree, knots, pts, weight, fit, tol)
+		if err != nil {
+			return nil, fmt.Errorf("SPLINE: %s", err)
+		}
+		closed := flags&1 != 0
+		if closed && p[0].Equals(p[len(p)-1], tolerance) {
+			p = p[:len(p)-1]
+		}
+		return &dxfPath{p: p, closed: closed}, nil
+	}
+	return nil, nil
+}
+
+// chainPaths joins open paths end to end into closed contours.
+func chainPaths(paths []*dxfPath, tol float64) ([][]V2, error) {
+	var contours [][]V2
+	var open []*dxfPath
+	for _, x := range paths {
+		if x.closed || (len(x.p) > 2 && x.p[0].Equals(x.p[len(x.p)-1], tol)) {
+			p := x.p
+			if !x.closed {
+				p = p[:len(p)-1]
+			}
+			contours = append(contours, p)
+		} else {
+			open = append(open, x)
+		}
+	}
+	used := make([]bool, len(open))
+	for i := range open {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		p := append([]V2{}, open[i].p...)
+		for !p[0].Equals(p[len(p)-1], tol) {
+			// find the next path from the end of this one
+			end := p[len(p)-1]
+			found := false
+			for j := range open {
+				if used[j] {
+					continue
+				}
+				q := open[j].p
+				if q[len(q)-1].Equals(end, tol) {
+					q = reverseV2(q)
+				}
+				if q[0].Equals(end, tol) {
+					used[j] = true
+					p = append(p, q[1:]...)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("open contour at %v", end)
+			}
+		}
+		if len(p) > 3 {
+			contours = append(contours, p[:len(p)-1])
+		}
+	}
+	return contours, nil
+}
+
+// reverseV2 returns a reversed copy of a slice of points.
+func reverseV2(p []V2) []V2 {
+	r := make([]V2, len(p))
+	for i := range p {
+		r[len(p)-1-i] = p[i]
+	}
+	return r
+}
+
+//-----------------------------------------------------------------------------
+
+// LoadDXF reads the closed contours of the 2D profiles in a DXF file. Curves
+// are tessellated so they are within tol of the true curve.
+func LoadDXF(path string, tol float64) ([][]V2, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodeDXF(file, tol)
+}
+
+// DecodeDXF reads the closed contours of the 2D profiles in DXF format from an
+// io.Reader.
+func DecodeDXF(r io.Reader, tol float64) ([][]V2, error) {
+	if tol <= 0 {
+		return nil, errors.New("tolerance <= 0")
+	}
+	entities, err := dxfEntities(r)
+	if err != nil {
+		return nil, err
+	}
+	var paths []*dxfPath
+	for _, e := range entities {
+		x, err := dxfEntityPath(e, tol)
+		if err != nil {
+			return nil, err
+		}
+		if x != nil && len(x.p) >= 2 {
+			paths = append(paths, x)
+		}
+	}
+	// end points are joined if they are close, relative to the drawing size
+	var bb Box2
+	for i, x := range paths {
+		b := Box2{x.p[0], x.p[0]}.Include(x.p[len(x.p)-1])
+		if i == 0 {
+			bb = b
+		} else {
+			bb = bb.Extend(b)
+		}
+	}
+	contours, err := chainPaths(paths, 1e-6*Max(bb.Size().MaxComponent(), 1))
+	if err != nil {
+		return nil, err
+	}
+	if len(contours) == 0 {
+		return nil, errors.New("no closed contours")
+	}
+	return contours, nil
+}
+
+// LoadDXF2D returns an SDF2 for the 2D profiles in a DXF file.
+func LoadDXF2D(path string, tol float64) (SDF2, error) {
+	contours, err := LoadDXF(path, tol)
+	if err != nil {
+		return nil, err
+	}
+	return Contours2D(contours), nil
+}
+
+//-----------------------------------------------------------------------------
+
+// Contours2D returns an SDF2 for a set of closed contours. The contours should
+// not cross. Holes are found by nesting, a contour inside an odd number of
+// other contours is a hole, so the orientation of the contours doesn't matter.
+func Contours2D(contours [][]V2) SDF2 {
+	n := len(contours)
+	depth := make([]int, n)
+	for i := range contours {
+		for j := range contours {
+			if i != j && clipInside([][]V2{contours[j]}, contours[i][0]) {
+				depth[i]++
+			}
+		}
+	}
+	// outer contours first
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return depth[idx[i]] < depth[idx[j]] })
+	// The parent of a contour is the containing contour one level up. Each
+	// solid is the outer contour less its holes.
+	var solids []SDF2
+	for _, i := range idx {
+		if depth[i]%2 != 0 {
+			continue
+		}
+		var holes []SDF2
+		for _, j := range idx {
+			if depth[j] == depth[i]+1 && clipInside([][]V2{contours[i]}, contours[j][0]) {
+				holes = append(holes, Polygon2D(contours[j]))
+			}
+		}
+		s := Polygon2D(contours[i])
+		if len(holes) != 0 {
+			s = Difference2D(s, Union2D(holes...))
+		}
+		solids = append(solids, s)
+	}
+	return Union2D(solids...)
+}
+
+//-----------------------------------------------------------------------------
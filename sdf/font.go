@@ -0,0 +1,518 @@
+//-----------------------------------------------------------------------------
+/*
+
+Fonts
+
+Load TrueType (*.ttf), OpenType (*.otf) and font collection (*.ttc) files,
+from the file system or from font data (E.g. embedded with go:embed). The Go
+fonts are built in, so text can be rendered without a font file.
+
+TrueType fonts are read with the freetype package. OpenType fonts with CFF
+(PostScript) outlines and font collections are read with the sfnt package.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+//-----------------------------------------------------------------------------
+
+// Font is a TrueType or OpenType font.
+type Font struct {
+	tt   *truetype.Font // truetype font
+	sf   *sfnt.Font     // opentype font (if tt == nil)
+	ah   float64        // line height for an opentype font (font units)
+	gsub *gsubTable     // glyph substitutions (nil if none)
+	ch   float64        // cap height from the OS/2 table (font units)
+	// fonts for the characters that are missing from this font
+	fallback []*Font
+	// converted glyphs, shared by all the text rendered with this font
+	mu    sync.Mutex
+	cache map[fontIndex]SDF2
+}
+
+// fontIndex is the index of a glyph within a font.
+type fontIndex int
+
+//-----------------------------------------------------------------------------
+// TrueType Glyphs
+
+// pToV2 converts a truetype point to a V2
+func pToV2(p truetype.Point) V2 {
+	return V2{float64(p.X), float64(p.Y)}
+}
+
+// glyphCurve returns the SDF2 for the n-th curve of the glyph
+func glyphCurve(g *truetype.GlyphBuf, n int) (SDF2, bool) {
+	// get the start and end point
+	start := 0
+	if n != 0 {
+		start = g.Ends[n-1]
+	}
+	end := g.Ends[n] - 1
+
+	// build a bezier curve from the points
+	// work out the cw/ccw direction
+	b := NewBezier()
+	sum := 0.0
+	offPrev := false
+	vPrev := pToV2(g.Points[end])
+
+	for i := start; i <= end; i++ {
+		p := g.Points[i]
+		v := pToV2(p)
+		// is the point off/on the curve?
+		off := p.Flags&1 == 0
+		// do we have an implicit on point?
+		if off && offPrev {
+			// implicit on point at the midpoint of the 2 off points
+			b.AddV2(v.Add(vPrev).MulScalar(0.5))
+		}
+		// add the point
+		x := b.AddV2(v)
+		if off {
+			x.Mid()
+		}
+		// accumulate the cw/ccw direction
+		sum += (v.X - vPrev.X) * (v.Y + vPrev.Y)
+		// next point...
+		vPrev = v
+		offPrev = off
+	}
+	b.Close()
+
+	return Polygon2D(b.Polygon().Vertices()), sum > 0
+}
+
+// glyphConvert returns the SDF2 for a glyph
+func glyphConvert(g *truetype.GlyphBuf) SDF2 {
+	var s0 SDF2
+	for n := 0; n < len(g.Ends); n++ {
+		s1, cw := glyphCurve(g, n)
+		if cw {
+			s0 = Union2D(s0, s1)
+		} else {
+			s0 = Difference2D(s0, s1)
+		}
+	}
+	return s0
+}
+
+//-----------------------------------------------------------------------------
+// OpenType Glyphs
+
+// fixedToV2 converts an sfnt point (y down) to a V2 (y up).
+func fixedToV2(p fixed.Point26_6) V2 {
+	return V2{float64(p.X), -float64(p.Y)}
+}
+
+// sfntConvert returns the SDF2 for the outline segments of a glyph.
+func sfntConvert(segs []sfnt.Segment) SDF2 {
+	// build a bezier curve for each contour
+	var contours [][]V2
+	var b *Bezier
+	closeContour := func() {
+		if b != nil && len(b.vlist) > 1 {
+			b.Close()
+			contours = append(contours, b.Polygon().Vertices())
+		}
+		b = nil
+	}
+	for _, s := range segs {
+		switch s.Op {
+		case sfnt.SegmentOpMoveTo:
+			closeContour()
+			b = NewBezier()
+			b.AddV2(fixedToV2(s.Args[0]))
+		case sfnt.SegmentOpLineTo:
+			b.AddV2(fixedToV2(s.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			b.AddV2(fixedToV2(s.Args[0])).Mid()
+			b.AddV2(fixedToV2(s.Args[1]))
+		case sfnt.SegmentOpCubeTo:
+			b.AddV2(fixedToV2(s.Args[0])).Mid()
+			b.AddV2(fixedToV2(s.Args[1])).Mid()
+			b.AddV2(fixedToV2(s.Args[2]))
+		}
+	}
+	closeContour()
+	// TrueType and CFF outlines have opposite directions, so use the nesting
+	// depth of each contour rather than its direction. Contours inside an even
+	// number of other contours are filled, the others are holes. Working from
+	// the outside in keeps the filled islands within holes (E.g. ®).
+	depth := make([]int, len(contours))
+	levels := 0
+	for i, c := range contours {
+		for j := range contours {
+			if i != j && clipInside(contours[j:j+1], c[0]) {
+				depth[i]++
+			}
+		}
+		if depth[i] >= levels {
+			levels = depth[i] + 1
+		}
+	}
+	var s0 SDF2
+	for level := 0; level < levels; level++ {
+		var s1 []SDF2
+		for i, c := range contours {
+			if depth[i] == level {
+				s1 = append(s1, Polygon2D(c))
+			}
+		}
+		if level&1 == 0 {
+			s0 = Union2D(s0, Union2D(s1...))
+		} else {
+			s0 = Difference2D(s0, Union2D(s1...))
+		}
+	}
+	return s0
+}
+
+//-----------------------------------------------------------------------------
+
+// ppem returns the scale that gives glyph coordinates in font units.
+func (f *Font) ppem() fixed.Int26_6 {
+	if f.tt != nil {
+		return fixed.Int26_6(f.tt.FUnitsPerEm())
+	}
+	return fixed.Int26_6(f.sf.UnitsPerEm())
+}
+
+// index returns the glyph index for a rune, 0 is the missing glyph.
+func (f *Font) index(r rune) fontIndex {
+	if f.tt != nil {
+		return fontIndex(f.tt.Index(r))
+	}
+	i, err := f.sf.GlyphIndex(nil, r)
+	if err != nil {
+		return 0
+	}
+	return fontIndex(i)
+}
+
+// lookup returns the font (this font or a fallback font) with a glyph for a
+// rune, and the glyph index. If no font has the glyph the missing glyph of
+// this font is returned.
+func (f *Font) lookup(r rune) (*Font, fontIndex) {
+	if i := f.index(r); i != 0 {
+		return f, i
+	}
+	for _, x := range f.fallback {
+		if i := x.index(r); i != 0 {
+			return x, i
+		}
+	}
+	return f, 0
+}
+
+// advance returns the advance width of a glyph in font units.
+func (f *Font) advance(i fontIndex) float64 {
+	if f.tt != nil {
+		return float64(f.tt.HMetric(f.ppem(), truetype.Index(i)).AdvanceWidth)
+	}
+	a, err := f.sf.GlyphAdvance(nil, sfnt.GlyphIndex(i), f.ppem(), font.HintingNone)
+	if err != nil {
+		return 0
+	}
+	return float64(a)
+}
+
+// kern returns the kerning adjustment between two glyphs in font units.
+func (f *Font) kern(i0, i1 fontIndex) float64 {
+	if f.tt != nil {
+		return float64(f.tt.Kern(f.ppem(), truetype.Index(i0), truetype.Index(i1)))
+	}
+	k, err := f.sf.Kern(nil, sfnt.GlyphIndex(i0), sfnt.GlyphIndex(i1), f.ppem(), font.HintingNone)
+	if err != nil {
+		return 0
+	}
+	return float64(k)
+}
+
+// lineHeight returns the distance between lines of text in font units.
+func (f *Font) lineHeight() float64 {
+	if f.tt != nil {
+		return float64(f.tt.VMetric(f.ppem(), f.tt.Index('\n')).AdvanceHeight)
+	}
+	if f.ah > 0 {
+		return f.ah
+	}
+	m, err := f.sf.Metrics(nil, f.ppem(), font.HintingNone)
+	if err != nil || m.Ascent+m.Descent <= 0 {
+		return float64(f.ppem())
+	}
+	return float64(m.Ascent + m.Descent)
+}
+
+// capHeight returns the height of the capital letters in font units.
+func (f *Font) capHeight() (float64, error) {
+	if f.ch > 0 {
+		return f.ch, nil
+	}
+	// measure the letter H
+	s, err := f.glyph(f.index('H'))
+	if err != nil {
+		return 0, err
+	}
+	if s == nil {
+		return 0, errors.New("font has no cap height")
+	}
+	return s.BoundingBox().Max.Y, nil
+}
+
+// glyph returns the SDF2 for a glyph in font units, nil for an empty glyph.
+// Converting a glyph outline is slow, so the glyphs are cached.
+func (f *Font) glyph(i fontIndex) (SDF2, error) {
+	f.mu.Lock()
+	s, ok := f.cache[i]
+	f.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+	s, err := f.loadGlyph(i)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	if f.cache == nil {
+		f.cache = make(map[fontIndex]SDF2)
+	}
+	f.cache[i] = s
+	f.mu.Unlock()
+	return s, nil
+}
+
+// loadGlyph converts a glyph outline to an SDF2 in font units.
+func (f *Font) loadGlyph(i fontIndex) (SDF2, error) {
+	if f.tt != nil {
+		g := &truetype.GlyphBuf{}
+		err := g.Load(f.tt, f.ppem(), truetype.Index(i), font.HintingNone)
+		if err != nil {
+			return nil, err
+		}
+		return glyphConvert(g), nil
+	}
+	segs, err := f.sf.LoadGlyph(nil, sfnt.GlyphIndex(i), f.ppem(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return sfntConvert(segs), nil
+}
+
+//-----------------------------------------------------------------------------
+
+// fontTable returns the data for a table of the font at offset ofs in the
+// font data, nil if the font has no such table.
+func fontTable(b []byte, ofs int, tag string) []byte {
+	if ofs+12 > len(b) {
+		return nil
+	}
+	n := int(binary.BigEndian.Uint16(b[ofs+4:]))
+	for i := 0; i < n; i++ {
+		r := ofs + 12 + 16*i
+		if r+16 > len(b) {
+			return nil
+		}
+		if string(b[r:r+4]) != tag {
+			continue
+		}
+		t := int(binary.BigEndian.Uint32(b[r+8:]))
+		l := int(binary.BigEndian.Uint32(b[r+12:]))
+		if t < 0 || l < 0 || t+l > len(b) {
+			return nil
+		}
+		return b[t : t+l]
+	}
+	return nil
+}
+
+// typoHeight returns the typographic line height (ascender - descender) from
+// the OS/2 table of the font at offset ofs in the font data. This is the line
+// height the freetype package uses, so a font has the same size whether it
+// is read from a font file or a font collection.
+func typoHeight(b []byte, ofs int) float64 {
+	t := fontTable(b, ofs, "OS/2")
+	if len(t) < 72 {
+		return 0
+	}
+	ascender := int16(binary.BigEndian.Uint16(t[68:]))
+	descender := int16(binary.BigEndian.Uint16(t[70:]))
+	return float64(ascender) - float64(descender)
+}
+
+// osCapHeight returns the cap height from the OS/2 table of the font at
+// offset ofs in the font data, 0 if the table doesn't have it (version < 2).
+func osCapHeight(b []byte, ofs int) float64 {
+	t := fontTable(b, ofs, "OS/2")
+	if len(t) < 90 || binary.BigEndian.Uint16(t) < 2 {
+		return 0
+	}
+	return float64(int16(binary.BigEndian.Uint16(t[88:])))
+}
+
+// parseFont returns the fonts in TrueType, OpenType or font collection data.
+func parseFont(b []byte) ([]*Font, error) {
+	switch {
+	case bytes.HasPrefix(b, []byte("ttcf")):
+		c, err := sfnt.ParseCollection(b)
+		if err != nil {
+			return nil, err
+		}
+		fonts := make([]*Font, c.NumFonts())
+		for i := range fonts {
+			f, err := c.Font(i)
+			if err != nil {
+				return nil, err
+			}
+			ofs := int(binary.BigEndian.Uint32(b[12+4*i:]))
+			fonts[i] = &Font{
+				sf:   f,
+				ah:   typoHeight(b, ofs),
+				gsub: parseGSUB(fontTable(b, ofs, "GSUB")),
+				ch:   osCapHeight(b, ofs),
+			}
+		}
+		return fonts, nil
+	case bytes.HasPrefix(b, []byte("OTTO")):
+		// CFF outlines
+		f, err := sfnt.Parse(b)
+		if err != nil {
+			return nil, err
+		}
+		return []*Font{{
+			sf:   f,
+			ah:   typoHeight(b, 0),
+			gsub: parseGSUB(fontTable(b, 0, "GSUB")),
+			ch:   osCapHeight(b, 0),
+		}}, nil
+	}
+	f, err := truetype.Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	return []*Font{{
+		tt:   f,
+		gsub: parseGSUB(fontTable(b, 0, "GSUB")),
+		ch:   osCapHeight(b, 0),
+	}}, nil
+}
+
+// loadFonts returns the fonts in font data, there is at least one font.
+func loadFonts(b []byte) ([]*Font, error) {
+	fonts, err := parseFont(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(fonts) == 0 {
+		return nil, errors.New("no fonts in the collection")
+	}
+	return fonts, nil
+}
+
+// SetFallback sets the fonts, in order of preference, that are used for the
+// characters that are missing from a font (E.g. symbols or CJK characters).
+func (f *Font) SetFallback(fonts ...*Font) *Font {
+	f.fallback = nil
+	for _, x := range fonts {
+		if x != nil && x != f {
+			f.fallback = append(f.fallback, x)
+		}
+	}
+	return f
+}
+
+// LoadFont loads a TrueType (*.ttf) or OpenType (*.otf) font file. For a
+// font collection (*.ttc) the first font is returned.
+func LoadFont(fname string) (*Font, error) {
+	fonts, err := LoadFontCollection(fname)
+	if err != nil {
+		return nil, err
+	}
+	return fonts[0], nil
+}
+
+// LoadFontCollection loads all the fonts in a font collection (*.ttc) file.
+// A single font file is a collection of one font.
+func LoadFontCollection(fname string) ([]*Font, error) {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	return loadFonts(b)
+}
+
+// LoadFontFromBytes loads a font from font file data (E.g. from go:embed).
+// For a font collection the first font is returned.
+func LoadFontFromBytes(b []byte) (*Font, error) {
+	fonts, err := loadFonts(b)
+	if err != nil {
+		return nil, err
+	}
+	return fonts[0], nil
+}
+
+// LoadFontCollectionFromBytes loads all the fonts in font collection data.
+func LoadFontCollectionFromBytes(b []byte) ([]*Font, error) {
+	return loadFonts(b)
+}
+
+// LoadFontFromReader loads a font from a reader.
+func LoadFontFromReader(r io.Reader) (*Font, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return LoadFontFromBytes(b)
+}
+
+//-----------------------------------------------------------------------------
+// Embedded Fonts
+
+// embeddedFonts are the fonts that are built in. These are the Go fonts,
+// with a BSD style license. See: https://go.dev/blog/go-fonts
+var embeddedFonts = map[string][]byte{
+	"Go-Regular": goregular.TTF,
+	"Go-Bold":    gobold.TTF,
+	"Go-Mono":    gomono.TTF,
+}
+
+// EmbeddedFontNames returns the names of the built in fonts.
+func EmbeddedFontNames() []string {
+	var names []string
+	for k := range embeddedFonts {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EmbeddedFont returns a built in font, so no font file is needed.
+func EmbeddedFont(name string) (*Font, error) {
+	b, ok := embeddedFonts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown embedded font \"%s\"", name)
+	}
+	return LoadFontFromBytes(b)
+}
+
+//-----------------------------------------------------------------------------
@@ -8,7 +8,12 @@ Involute Gears
 
 package sdf
 
-import "math"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
 
 //-----------------------------------------------------------------------------
 
@@ -127,6 +132,442 @@ func InvoluteGear(
 	return Difference2D(Union2D(gear, root), ring)
 }
 
+//-----------------------------------------------------------------------------
+// Toothed Profiles
+
+// ToothedSDF2 is a 2d profile with rotationally repeated teeth (E.g. gears,
+// sprockets and pulleys).
+type ToothedSDF2 struct {
+	tooth []V2    // polyline for the boundary of a single tooth
+	poly  SDF2    // polygon for the tooth sector (inside/outside test)
+	root  float64 // radius of the root circle
+	pitch float64 // tooth to tooth angle
+	bb    Box2    // bounding box
+}
+
+// newToothed2D returns a toothed profile from the upper half of the tooth
+// boundary. The upper half is a polyline from the center of the tooth gap (at
+// an angle of Pi/teeth) to the center of the tooth (on the positive x axis).
+func newToothed2D(upper []V2, teeth int, rootRadius, outerRadius float64) *ToothedSDF2 {
+	s := ToothedSDF2{}
+	// mirror for the lower half of the tooth
+	n := len(upper)
+	s.tooth = make([]V2, 2*n-1)
+	for i, v := range upper {
+		s.tooth[i] = V2{v.X, -v.Y}
+		s.tooth[2*n-2-i] = v
+	}
+	// extend the root arc past the sector for a robust inside test
+	gap := Pi / float64(teeth)
+	e := 0.1 * gap
+	sector := []V2{{0, 0}, PolarToXY(rootRadius, -gap-e)}
+	sector = append(sector, s.tooth...)
+	sector = append(sector, PolarToXY(rootRadius, gap+e))
+	s.poly = Polygon2D(sector)
+	s.root = rootRadius
+	s.pitch = Tau / float64(teeth)
+	s.bb = Box2{V2{-outerRadius, -outerRadius}, V2{outerRadius, outerRadius}}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a toothed profile.
+func (s *ToothedSDF2) Evaluate(p V2) float64 {
+	// rotate the point into the sector of the first tooth
+	a := math.Atan2(p.Y, p.X)
+	k := math.Floor(a/s.pitch + 0.5)
+	p0 := Rotate(-k * s.pitch).MulPosition(p)
+	// the closest point may be on a neighbouring tooth
+	d2 := polylineDist2(p0, s.tooth)
+	d2 = Min(d2, polylineDist2(Rotate(s.pitch).MulPosition(p0), s.tooth))
+	d2 = Min(d2, polylineDist2(Rotate(-s.pitch).MulPosition(p0), s.tooth))
+	d := math.Sqrt(d2)
+	if p0.Length() < s.root || s.poly.Evaluate(p0) < 0 {
+		return -d
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a toothed profile.
+func (s *ToothedSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
+// segmentDist2 returns the squared distance from a point to a line segment.
+func segmentDist2(p, a, b V2) float64 {
+	ab := b.Sub(a)
+	ap := p.Sub(a)
+	t := 0.0
+	if l2 := ab.Length2(); l2 > 0 {
+		t = Clamp(ap.Dot(ab)/l2, 0, 1)
+	}
+	return ap.Sub(ab.MulScalar(t)).Length2()
+}
+
+// polylineDist2 returns the squared distance from a point to a polyline.
+func polylineDist2(p V2, v []V2) float64 {
+	d2 := math.MaxFloat64
+	for i := 0; i < len(v)-1; i++ {
+		d2 = Min(d2, segmentDist2(p, v[i], v[i+1]))
+	}
+	return d2
+}
+
+// arcV2 returns points on a circular arc.
+func arcV2(center V2, radius, a0, a1 float64) []V2 {
+	n := int(math.Ceil(Abs(a1-a0)/(Tau/256))) + 1
+	v := make([]V2, n+1)
+	for i := range v {
+		v[i] = center.Add(PolarToXY(radius, a0+(a1-a0)*float64(i)/float64(n)))
+	}
+	return v
+}
+
+//-----------------------------------------------------------------------------
+// Involute Gear with Profile Shift
+
+const involuteFacets = 32 // number of facets for an involute flank
+const filletFacets = 8    // number of facets for a root fillet
+
+// involuteFunc returns the involute function, inv(a) = tan(a) - a.
+func involuteFunc(a float64) float64 {
+	return math.Tan(a) - a
+}
+
+// InvoluteGear2D returns the 2D profile of an involute spur gear.
+// The profile shift (in units of module) moves the tooth away from (positive)
+// or towards (negative) the gear center, E.g. to avoid undercutting on gears
+// with few teeth. The backlash is the reduction in tooth thickness at the pitch
+// circle. The tooth roots are filleted with the root radius. The root is not
+// undercut, so check gears with few teeth for interference.
+// The first tooth is centered on the positive x axis.
+func InvoluteGear2D(
+	gearModule float64, // pitch circle diameter / number of gear teeth
+	numberTeeth int, // number of gear teeth
+	pressureAngle float64, // gear pressure angle (radians)
+	profileShift float64, // profile shift coefficient
+	backlash float64, // backlash expressed as per-tooth distance at pitch circumference
+	rootRadius float64, // radius of the root fillet
+) (SDF2, error) {
+	if gearModule <= 0 {
+		return nil, errors.New("gear module <= 0")
+	}
+	if numberTeeth < 3 {
+		return nil, errors.New("number of teeth < 3")
+	}
+	if pressureAngle <= 0 || pressureAngle >= DtoR(45) {
+		return nil, errors.New("bad pressure angle")
+	}
+	if backlash < 0 || rootRadius < 0 {
+		return nil, errors.New("backlash or root radius < 0")
+	}
+	z := float64(numberTeeth)
+	rp := gearModule * z / 2                  // pitch radius
+	rb := rp * math.Cos(pressureAngle)        // base radius
+	ra := rp + gearModule*(1+profileShift)    // outer radius
+	rf := rp - gearModule*(1.25-profileShift) // root radius
+	if rf <= 0 {
+		return nil, errors.New("root circle radius <= 0")
+	}
+	// half tooth thickness angle at the pitch circle
+	halfPitch := (gearModule*(Pi/2+2*profileShift*math.Tan(pressureAngle)) - backlash) / (2 * rp)
+	if halfPitch <= 0 {
+		return nil, errors.New("backlash is too large")
+	}
+	// half tooth thickness angle at radius r (on the involute)
+	halfAngle := func(r float64) float64 {
+		return halfPitch + involuteFunc(pressureAngle) - involuteFunc(math.Acos(rb/r))
+	}
+	// the tooth comes to a point if it gets too thin
+	if halfAngle(ra) < 0 {
+		lo, hi := Max(rb, rf), ra
+		for i := 0; i < 60; i++ {
+			mid := 0.5 * (lo + hi)
+			if halfAngle(mid) > 0 {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		ra = lo
+	}
+	if ra <= rf {
+		return nil, errors.New("no tooth above the root circle")
+	}
+
+	// upper tooth flank, from the root circle to the outer radius
+	var flank []V2
+	r0 := Max(rb, rf)
+	if rf < rb {
+		// radial flank below the base circle
+		flank = append(flank, PolarToXY(rf, halfAngle(rb)))
+	}
+	t0 := involuteTheta(rb, r0)
+	t1 := involuteTheta(rb, ra)
+	for i := 0; i <= involuteFacets; i++ {
+		r := rb * math.Sqrt(1+math.Pow(t0+(t1-t0)*float64(i)/involuteFacets, 2))
+		flank = append(flank, PolarToXY(r, halfAngle(r)))
+	}
+
+	// root fillet: the center is on the circle of radius rf + rootRadius, at
+	// the angle where it is rootRadius from the flank
+	gapAngle := Pi / z
+	var upper []V2
+	if rootRadius > 0 {
+		rc := rf + rootRadius
+		dist := func(a float64) float64 {
+			return math.Sqrt(polylineDist2(PolarToXY(rc, a), flank)) - rootRadius
+		}
+		lo, hi := math.Atan2(flank[0].Y, flank[0].X), gapAngle
+		if dist(hi) < 0 {
+			return nil, errors.New("root radius is too large")
+		}
+		for i := 0; i < 60; i++ {
+			mid := 0.5 * (lo + hi)
+			if dist(mid) < 0 {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		c := PolarToXY(rc, hi)
+		// tangent point on the flank
+		k := 0
+		best := math.MaxFloat64
+		for i := 0; i < len(flank)-1; i++ {
+			if d2 := segmentDist2(c, flank[i], flank[i+1]); d2 < best {
+				best, k = d2, i
+			}
+		}
+		ab := flank[k+1].Sub(flank[k])
+		t := Clamp(c.Sub(flank[k]).Dot(ab)/ab.Length2(), 0, 1)
+		tp := flank[k].Add(ab.MulScalar(t))
+		// root arc, fillet arc, remaining flank
+		upper = arcV2(V2{}, rf, gapAngle, hi)
+		a0 := hi + Pi
+		a1 := math.Atan2(tp.Y-c.Y, tp.X-c.X)
+		for a1 > a0+Pi {
+			a1 -= Tau
+		}
+		for a1 <= a0-Pi {
+			a1 += Tau
+		}
+		for i := 1; i < filletFacets; i++ {
+			upper = append(upper, c.Add(PolarToXY(rootRadius, a0+(a1-a0)*float64(i)/filletFacets)))
+		}
+		upper = append(upper, tp)
+		upper = append(upper, flank[k+1:]...)
+	} else {
+		upper = arcV2(V2{}, rf, gapAngle, math.Atan2(flank[0].Y, flank[0].X))
+		upper = append(upper, flank[1:]...)
+	}
+	// tip arc
+	tip := arcV2(V2{}, ra, halfAngle(ra), 0)
+	upper = append(upper, tip[1:]...)
+
+	return newToothed2D(upper, numberTeeth, rf, ra), nil
+}
+
+//-----------------------------------------------------------------------------
+// Roller Chain Sprockets
+
+// rollerChain is the pitch and roller diameter (mm) of a roller chain.
+type rollerChain struct {
+	pitch  float64
+	roller float64
+}
+
+// rollerChains are the ANSI (and motorcycle) roller chain sizes.
+var rollerChains = map[string]rollerChain{
+	"25":  {6.35, 3.30},
+	"35":  {9.525, 5.08},
+	"40":  {12.7, 7.92},
+	"41":  {12.7, 7.77},
+	"50":  {15.875, 10.16},
+	"60":  {19.05, 11.91},
+	"420": {12.7, 7.77},
+	"428": {12.7, 8.51},
+	"520": {15.875, 10.16},
+	"530": {15.875, 10.16},
+}
+
+// Sprocket2D returns the 2D profile of a sprocket for a roller chain size
+// (E.g. "25", "#40", "420"). The tooth form is the ISO 606 maximum tooth gap
+// form (the loosest fit to the chain). The outside diameter is the ANSI
+// outside diameter. The first tooth is centered on the positive x axis.
+func Sprocket2D(chain string, teeth int) (SDF2, error) {
+	c, ok := rollerChains[strings.TrimPrefix(chain, "#")]
+	if !ok {
+		return nil, fmt.Errorf("unknown roller chain size \"%s\"", chain)
+	}
+	if teeth < 6 {
+		return nil, errors.New("teeth < 6")
+	}
+	z := float64(teeth)
+	p := c.pitch
+	d1 := c.roller
+	gap := Pi / z
+	rp := p / (2 * math.Sin(gap))           // pitch radius
+	ra := 0.5 * p * (0.6 + 1/math.Tan(gap)) // outside radius
+	ri := 0.505*d1 + 0.069*math.Cbrt(d1)    // roller seating radius
+	re := 0.12 * d1 * (z + 2)               // tooth flank radius
+	alpha := DtoR(120 - 90/z)               // roller seating angle
+
+	// roller seating arc, from the center of the gap towards the tooth
+	seat := PolarToXY(rp, gap)
+	a0 := gap + Pi
+	a1 := a0 + 0.5*alpha
+	upper := arcV2(seat, ri, a0, a1)
+	e := upper[len(upper)-1]
+	// The flank is an arc tangent to the seating arc, with the center on the
+	// other side of the tooth.
+	f := e.Sub(seat.Sub(e).Normalize().MulScalar(re))
+	b0 := a1 - Pi
+	// find where the flank reaches the outside radius
+	flank := func(b float64) V2 {
+		return f.Add(PolarToXY(re, b))
+	}
+	lo, hi := b0, b0-Pi/2
+	for i := 0; i < 60; i++ {
+		mid := 0.5 * (lo + hi)
+		if x := flank(mid); x.Length() < ra && x.Y > 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	upper = append(upper, arcV2(f, re, b0, lo)[1:]...)
+	// tip arc
+	x := flank(lo)
+	if tip := math.Atan2(x.Y, x.X); tip > 0 {
+		upper = append(upper, arcV2(V2{}, ra, tip, 0)[1:]...)
+	}
+	return newToothed2D(upper, teeth, rp-ri, ra), nil
+}
+
+//-----------------------------------------------------------------------------
+// Timing Belt Pulleys
+
+// timingBelt defines the pulley groove for a timing belt (mm).
+type timingBelt struct {
+	pitch  float64 // tooth to tooth pitch
+	pld    float64 // pitch line differential (belt pitch line to pulley surface)
+	depth  float64 // groove depth
+	radius float64 // groove radius (curvilinear grooves)
+	width  float64 // groove bottom width (trapezoidal grooves)
+	angle  float64 // groove flank angle from radial (trapezoidal grooves, degrees)
+	rb, rt float64 // groove bottom and tip fillet radii
+}
+
+// timingBelts are the nominal pulley groove profiles for common timing belts.
+var timingBelts = map[string]timingBelt{
+	"GT2":   {pitch: 2, pld: 0.254, depth: 0.75, radius: 0.555, rt: 0.15},
+	"GT3":   {pitch: 3, pld: 0.381, depth: 1.14, radius: 0.85, rt: 0.25},
+	"GT5":   {pitch: 5, pld: 0.5715, depth: 1.93, radius: 1.44, rt: 0.4},
+	"HTD3M": {pitch: 3, pld: 0.381, depth: 1.22, radius: 0.91, rt: 0.3},
+	"HTD5M": {pitch: 5, pld: 0.5715, depth: 2.06, radius: 1.53, rt: 0.45},
+	"HTD8M": {pitch: 8, pld: 0.686, depth: 3.38, radius: 2.59, rt: 0.8},
+	"T5":    {pitch: 5, pld: 0.5, depth: 1.25, width: 1.75, angle: 25, rb: 0.4, rt: 0.6},
+	"T10":   {pitch: 10, pld: 1.0, depth: 2.6, width: 3.05, angle: 25, rb: 0.6, rt: 0.8},
+}
+
+// filletArc returns the points on the short arc from p0 to p1 centered on c.
+func filletArc(c, p0, p1 V2) []V2 {
+	a0 := math.Atan2(p0.Y-c.Y, p0.X-c.X)
+	a1 := math.Atan2(p1.Y-c.Y, p1.X-c.X)
+	for a1 > a0+Pi {
+		a1 -= Tau
+	}
+	for a1 <= a0-Pi {
+		a1 += Tau
+	}
+	return arcV2(c, p0.Sub(c).Length(), a0, a1)
+}
+
+// circleIntersect returns the intersection of two circles, the one to the
+// right of the line from c0 to c1.
+func circleIntersect(c0 V2, r0 float64, c1 V2, r1 float64) (V2, bool) {
+	v := c1.Sub(c0)
+	d := v.Length()
+	a := (r0*r0 - r1*r1 + d*d) / (2 * d)
+	h2 := r0*r0 - a*a
+	if h2 < 0 {
+		return V2{}, false
+	}
+	u := v.DivScalar(d)
+	return c0.Add(u.MulScalar(a)).Add(V2{u.Y, -u.X}.MulScalar(math.Sqrt(h2))), true
+}
+
+// TimingPulley2D returns the 2D profile of a pulley for a timing belt. The
+// belt is one of GT2, GT3, GT5, HTD3M, HTD5M, HTD8M, T5 or T10. The grooves
+// are the nominal groove profiles for the belt. The first tooth (the land
+// between two grooves) is centered on the positive x axis.
+func TimingPulley2D(belt string, teeth int) (SDF2, error) {
+	k, ok := timingBelts[strings.ToUpper(belt)]
+	if !ok {
+		return nil, fmt.Errorf("unknown timing belt \"%s\"", belt)
+	}
+	if teeth < 8 {
+		return nil, errors.New("teeth < 8")
+	}
+	z := float64(teeth)
+	gap := Pi / z
+	ro := k.pitch*z/Tau - k.pld // outside radius
+	ur := PolarToXY(1, gap)     // unit radial vector at the groove center
+	ut := V2{-ur.Y, ur.X}       // unit tangent vector (towards the gap)
+
+	var upper []V2
+	var fc V2 // tip fillet center
+	if k.radius > 0 {
+		// curvilinear groove
+		c := ur.MulScalar(ro - k.depth + k.radius)
+		var ok bool
+		fc, ok = circleIntersect(V2{}, ro-k.rt, c, k.radius+k.rt)
+		if !ok {
+			return nil, errors.New("bad groove profile")
+		}
+		t2 := c.Add(fc.Sub(c).Normalize().MulScalar(k.radius))
+		upper = filletArc(c, c.Sub(ur.MulScalar(k.radius)), ur.MulScalar(ro-k.depth).Sub(ut.MulScalar(k.radius)))
+		upper = append(upper, filletArc(c, upper[len(upper)-1], t2)[1:]...)
+		upper = append(upper, filletArc(fc, t2, fc.Normalize().MulScalar(ro))[1:]...)
+	} else {
+		// trapezoidal groove
+		b := ur.MulScalar(ro - k.depth)
+		bc := b.Sub(ut.MulScalar(0.5 * k.width))
+		beta := DtoR(k.angle)
+		d := ur.MulScalar(math.Cos(beta)).Sub(ut.MulScalar(math.Sin(beta))) // flank direction
+		n := V2{d.Y, -d.X}                                                  // flank normal (into the tooth)
+		if n.Dot(ut) > 0 {
+			n = n.Neg()
+		}
+		// bottom fillet: offset the bottom and flank lines into the groove
+		m := M22{ut.X, -d.X, ut.Y, -d.Y}
+		p := bc.Sub(n.MulScalar(k.rb)).Sub(b.Add(ur.MulScalar(k.rb)))
+		st := m.Inverse().MulPosition(p)
+		cb := b.Add(ur.MulScalar(k.rb)).Add(ut.MulScalar(st.X))
+		// tip fillet: offset the flank line into the tooth, intersect with
+		// the circle of radius ro - rt
+		q := bc.Add(n.MulScalar(k.rt))
+		r := ro - k.rt
+		qd := q.Dot(d)
+		disc := qd*qd - q.Length2() + r*r
+		if disc < 0 {
+			return nil, errors.New("bad groove profile")
+		}
+		fc = q.Add(d.MulScalar(-qd + math.Sqrt(disc)))
+		upper = []V2{b, cb.Sub(ur.MulScalar(k.rb))}
+		upper = append(upper, filletArc(cb, cb.Sub(ur.MulScalar(k.rb)), cb.Add(n.MulScalar(k.rb)))[1:]...)
+		upper = append(upper, filletArc(fc, fc.Sub(n.MulScalar(k.rt)), fc.Normalize().MulScalar(ro))...)
+	}
+	// the land between the grooves
+	t1 := upper[len(upper)-1]
+	a := math.Atan2(t1.Y, t1.X)
+	if a <= 0 {
+		return nil, errors.New("too few teeth for the groove profile")
+	}
+	upper = append(upper, arcV2(V2{}, ro, a, 0)[1:]...)
+	return newToothed2D(upper, teeth, ro-k.depth, ro), nil
+}
+
 //-----------------------------------------------------------------------------
 // 2D Gear Rack
 
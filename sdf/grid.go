@@ -0,0 +1,122 @@
+//-----------------------------------------------------------------------------
+/*
+
+Sampled Distance Fields
+
+Some SDF2s are expensive to evaluate (E.g. text with many curved glyphs) and
+the 3D operations built on them (extrusion, revolution) evaluate them many
+times while meshing. A grid SDF2 samples the distance field once on a uniform
+grid and bilinearly interpolates between the samples.
+
+The interpolated distance is exact at the grid points. Between them the error
+is small where the field is smooth, but sharp features smaller than the grid
+spacing (E.g. convex corners) are rounded off.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+
+// gridMargin is the number of grid cells added around the bounding box.
+const gridMargin = 2
+
+// grid2 is a distance field sampled on a uniform grid.
+type grid2 struct {
+	d      []float64 // distance at the grid points, row major with row 0 at +y
+	nx, ny int       // number of grid points
+	k      float64   // grid spacing
+	box    Box2      // the grid points
+}
+
+// evaluate returns the interpolated distance at a point.
+func (g *grid2) evaluate(p V2) float64 {
+	// the distance to the box of grid points
+	q := p.Clamp(g.box.Min, g.box.Max)
+	e := p.Sub(q).Length()
+	// grid coordinates, grid points are at integer values
+	x := (q.X - g.box.Min.X) / g.k
+	y := (g.box.Max.Y - q.Y) / g.k
+	x0 := Min(math.Floor(x), float64(g.nx-2))
+	y0 := Min(math.Floor(y), float64(g.ny-2))
+	fx, fy := x-x0, y-y0
+	i, j := int(x0), int(y0)
+	d0 := g.d[j*g.nx+i]*(1-fx) + g.d[j*g.nx+i+1]*fx
+	d1 := g.d[(j+1)*g.nx+i]*(1-fx) + g.d[(j+1)*g.nx+i+1]*fx
+	return d0*(1-fy) + d1*fy + e
+}
+
+//-----------------------------------------------------------------------------
+
+// GridSDF2 is an SDF2 sampled on a uniform grid.
+type GridSDF2 struct {
+	g  grid2
+	bb Box2
+}
+
+// Grid2D returns an SDF2 that is sampled from another SDF2 on a uniform grid
+// with the given number of cells on the longest side of the bounding box.
+// Evaluation is fast and independent of the cost of the sampled SDF2.
+func Grid2D(sdf SDF2, cells int) (SDF2, error) {
+	if cells < 1 {
+		return nil, errors.New("cells < 1")
+	}
+	bb := sdf.BoundingBox()
+	size := bb.Size()
+	k := size.MaxComponent() / float64(cells)
+	if k <= 0 {
+		return nil, errors.New("empty bounding box")
+	}
+	n := size.DivScalar(k).Ceil().ToV2i().AddScalar(1 + 2*gridMargin)
+	nx, ny := n[0], n[1]
+	// center the grid on the bounding box
+	w := 0.5 * float64(nx-1) * k
+	h := 0.5 * float64(ny-1) * k
+	c := bb.Center()
+	box := Box2{c.Sub(V2{w, h}), c.Add(V2{w, h})}
+	// sample the distance field, a row at a time
+	d := make([]float64, nx*ny)
+	rows := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range rows {
+				y := box.Max.Y - float64(j)*k
+				for i := 0; i < nx; i++ {
+					d[j*nx+i] = sdf.Evaluate(V2{box.Min.X + float64(i)*k, y})
+				}
+			}
+		}()
+	}
+	for j := 0; j < ny; j++ {
+		rows <- j
+	}
+	close(rows)
+	wg.Wait()
+	s := GridSDF2{}
+	s.g = grid2{d, nx, ny, k, box}
+	s.bb = bb
+	return &s, nil
+}
+
+// Evaluate returns the minimum distance to a grid SDF2.
+func (s *GridSDF2) Evaluate(p V2) float64 {
+	return s.g.evaluate(p)
+}
+
+// BoundingBox returns the bounding box for a grid SDF2.
+func (s *GridSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,621 @@
+//-----------------------------------------------------------------------------
+/*
+
+Half Edge Meshes
+
+An editable polygon mesh with adjacency information, for mesh processing
+(E.g. decimation, smoothing, repair and remeshing).
+
+Each face is a loop of half edges. A half edge starts at a vertex, and has the
+face on its left. The twin of a half edge is the half edge in the opposite
+direction on the adjacent face, so the faces around an edge, the edges around
+a face, and the faces and vertices around a vertex are all found without
+searching the mesh.
+
+The mesh must be manifold: an edge has at most 2 faces, and adjacent faces
+have the same orientation. Each vertex keeps one of its outgoing half edges.
+For a vertex on the boundary this is the boundary half edge, so walking around
+the vertex from it visits all the faces.
+
+Faces may have any number of vertices, the edge editing operations (flip,
+split and collapse) work on triangles. Deleted faces and vertices keep their
+indices, so the indices of the others don't change while editing.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import "fmt"
+
+//-----------------------------------------------------------------------------
+
+// halfEdge is a directed edge of a face.
+type halfEdge struct {
+	from int // start vertex, -1 if deleted
+	face int // face on the left
+	next int // next half edge around the face
+	prev int // previous half edge around the face
+	twin int // opposite half edge, -1 on a boundary
+}
+
+// heVertex is a mesh vertex.
+type heVertex struct {
+	p    V3
+	out  int  // an outgoing half edge, the boundary half edge if there is one
+	dead bool // the vertex has been deleted
+}
+
+// HalfEdgeMesh is an editable polygon mesh with adjacency information.
+type HalfEdgeMesh struct {
+	vertex []heVertex
+	edge   []halfEdge
+	face   []int // a half edge of each face, -1 if deleted
+}
+
+//-----------------------------------------------------------------------------
+
+// NewHalfEdgeMesh returns a half edge mesh of a triangle mesh. Vertices are
+// shared by position, degenerate triangles are removed.
+func NewHalfEdgeMesh(mesh []*Triangle3) (*HalfEdgeMesh, error) {
+	vertex, face := indexMesh(mesh)
+	faces := make([][]int, len(face))
+	for i := range face {
+		faces[i] = face[i][:]
+	}
+	return NewHalfEdgeMeshIndexed(vertex, faces)
+}
+
+// NewHalfEdgeMeshIndexed returns a half edge mesh of an indexed polygon mesh.
+// The face vertices are counter-clockwise.
+func NewHalfEdgeMeshIndexed(vertex []V3, face [][]int) (*HalfEdgeMesh, error) {
+	m := &HalfEdgeMesh{
+		vertex: make([]heVertex, len(vertex)),
+		face:   make([]int, len(face)),
+	}
+	for i, p := range vertex {
+		m.vertex[i] = heVertex{p: p, out: -1}
+	}
+	index := make(map[EdgeI]int)
+	for i, f := range face {
+		n := len(f)
+		if n < 3 {
+			return nil, fmt.Errorf("face %d has %d vertices", i, n)
+		}
+		k := len(m.edge)
+		m.face[i] = k
+		for j, v := range f {
+			if v < 0 || v >= len(vertex) {
+				return nil, fmt.Errorf("face %d has a bad vertex index %d", i, v)
+			}
+			e := EdgeI{v, f[(j+1)%n]}
+			if e[0] == e[1] {
+				return nil, fmt.Errorf("face %d is degenerate", i)
+			}
+			if _, ok := index[e]; ok {
+				return nil, fmt.Errorf("edge %v is non-manifold", e)
+			}
+			index[e] = k + j
+			m.edge = append(m.edge, halfEdge{
+				from: v,
+				face: i,
+				next: k + (j+1)%n,
+				prev: k + (j+n-1)%n,
+				twin: -1,
+			})
+			m.vertex[v].out = k + j
+		}
+	}
+	for e, i := range index {
+		if j, ok := index[EdgeI{e[1], e[0]}]; ok {
+			m.edge[i].twin = j
+		}
+	}
+	// the outgoing half edge of a boundary vertex is on the boundary
+	for i := range m.edge {
+		if m.edge[i].twin < 0 {
+			m.vertex[m.edge[i].from].out = i
+		}
+	}
+	return m, nil
+}
+
+//-----------------------------------------------------------------------------
+// Queries
+
+// Vertices returns the number of vertices (including deleted vertices).
+func (m *HalfEdgeMesh) Vertices() int {
+	return len(m.vertex)
+}
+
+// Faces returns the number of faces (including deleted faces).
+func (m *HalfEdgeMesh) Faces() int {
+	return len(m.face)
+}
+
+// Vertex returns the position of a vertex.
+func (m *HalfEdgeMesh) Vertex(v int) V3 {
+	return m.vertex[v].p
+}
+
+// SetVertex sets the position of a vertex.
+func (m *HalfEdgeMesh) SetVertex(v int, p V3) {
+	m.vertex[v].p = p
+}
+
+// VertexDeleted returns true if a vertex has been deleted.
+func (m *HalfEdgeMesh) VertexDeleted(v int) bool {
+	return m.vertex[v].dead
+}
+
+// FaceDeleted returns true if a face has been deleted.
+func (m *HalfEdgeMesh) FaceDeleted(f int) bool {
+	return m.face[f] < 0
+}
+
+// to returns the end vertex of a half edge.
+func (m *HalfEdgeMesh) to(e int) int {
+	return m.edge[m.edge[e].next].from
+}
+
+// FaceVertices returns the vertices of a face, counter-clockwise.
+func (m *HalfEdgeMesh) FaceVertices(f int) []int {
+	var vs []int
+	e := m.face[f]
+	for {
+		vs = append(vs, m.edge[e].from)
+		e = m.edge[e].next
+		if e == m.face[f] {
+			return vs
+		}
+	}
+}
+
+// FaceNeighbors returns the faces that share an edge with a face.
+func (m *HalfEdgeMesh) FaceNeighbors(f int) []int {
+	var fs []int
+	e := m.face[f]
+	for {
+		if t := m.edge[e].twin; t >= 0 {
+			fs = append(fs, m.edge[t].face)
+		}
+		e = m.edge[e].next
+		if e == m.face[f] {
+			return fs
+		}
+	}
+}
+
+// FaceNormal returns the unit normal of a face.
+func (m *HalfEdgeMesh) FaceNormal(f int) V3 {
+	// Newell's method, for non-planar polygons
+	var n V3
+	vs := m.FaceVertices(f)
+	for i, v := range vs {
+		n = n.Add(m.vertex[v].p.Cross(m.vertex[vs[(i+1)%len(vs)]].p))
+	}
+	return n.Normalize()
+}
+
+// outgoing returns the outgoing half edges of a vertex, counter-clockwise
+// about the face normals.
+func (m *HalfEdgeMesh) outgoing(v int) []int {
+	var es []int
+	start := m.vertex[v].out
+	if start < 0 || m.vertex[v].dead {
+		return nil
+	}
+	e := start
+	for {
+		es = append(es, e)
+		e = m.edge[m.edge[e].prev].twin
+		if e < 0 || e == start {
+			return es
+		}
+	}
+}
+
+// IsBoundaryVertex returns true if a vertex is on the boundary of the mesh.
+func (m *HalfEdgeMesh) IsBoundaryVertex(v int) bool {
+	e := m.vertex[v].out
+	return e >= 0 && m.edge[e].twin < 0
+}
+
+// VertexNeighbors returns the vertices joined to a vertex by an edge,
+// counter-clockwise.
+func (m *HalfEdgeMesh) VertexNeighbors(v int) []int {
+	es := m.outgoing(v)
+	var vs []int
+	for _, e := range es {
+		vs = append(vs, m.to(e))
+	}
+	if m.IsBoundaryVertex(v) {
+		// the start of the last incoming boundary half edge
+		vs = append(vs, m.edge[m.edge[es[len(es)-1]].prev].from)
+	}
+	return vs
+}
+
+// VertexFaces returns the faces about a vertex, counter-clockwise.
+func (m *HalfEdgeMesh) VertexFaces(v int) []int {
+	var fs []int
+	for _, e := range m.outgoing(v) {
+		fs = append(fs, m.edge[e].face)
+	}
+	return fs
+}
+
+// VertexNormal returns the area weighted normal of a vertex.
+func (m *HalfEdgeMesh) VertexNormal(v int) V3 {
+	var n V3
+	for _, e := range m.outgoing(v) {
+		a := m.vertex[v].p
+		b := m.vertex[m.to(e)].p
+		c := m.vertex[m.edge[m.edge[e].prev].from].p
+		n = n.Add(b.Sub(a).Cross(c.Sub(a)))
+	}
+	return n.Normalize()
+}
+
+// HalfEdge returns the half edge from vertex u to vertex v, or -1 if there
+// is no such edge.
+func (m *HalfEdgeMesh) HalfEdge(u, v int) int {
+	for _, e := range m.outgoing(u) {
+		if m.to(e) == v {
+			return e
+		}
+	}
+	return -1
+}
+
+// EdgeVertices returns the start and end vertices of a half edge.
+func (m *HalfEdgeMesh) EdgeVertices(e int) (int, int) {
+	return m.edge[e].from, m.to(e)
+}
+
+// Boundaries returns the boundary loops of the mesh. The vertices of each
+// loop are clockwise about the mesh (counter-clockwise about the hole).
+func (m *HalfEdgeMesh) Boundaries() [][]int {
+	var loops [][]int
+	done := make(map[int]bool)
+	for i := range m.edge {
+		if m.edge[i].from < 0 || m.edge[i].twin >= 0 || done[i] {
+			continue
+		}
+		// follow the boundary backwards, the hole is on the right
+		var loop []int
+		e := i
+		for !done[e] {
+			done[e] = true
+			loop = append(loop, m.to(e))
+			// the next boundary half edge ending at the start of e
+			x := m.edge[e].prev
+			for m.edge[x].twin >= 0 {
+				x = m.edge[m.edge[x].twin].prev
+			}
+			e = x
+		}
+		loops = append(loops, loop)
+	}
+	return loops
+}
+
+// IsClosed returns true if the mesh has no boundary.
+func (m *HalfEdgeMesh) IsClosed() bool {
+	for i := range m.edge {
+		if m.edge[i].from >= 0 && m.edge[i].twin < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Indexed returns the vertices and faces of the mesh, without the deleted
+// vertices and faces.
+func (m *HalfEdgeMesh) Indexed() ([]V3, [][]int) {
+	index := make([]int, len(m.vertex))
+	var vertex []V3
+	for i := range m.vertex {
+		index[i] = -1
+		if !m.vertex[i].dead {
+			index[i] = len(vertex)
+			vertex = append(vertex, m.vertex[i].p)
+		}
+	}
+	var face [][]int
+	for f := range m.face {
+		if m.face[f] < 0 {
+			continue
+		}
+		vs := m.FaceVertices(f)
+		for i := range vs {
+			vs[i] = index[vs[i]]
+		}
+		face = append(face, vs)
+	}
+	return vertex, face
+}
+
+// Triangles returns the triangles of the mesh. Polygon faces are split into
+// triangle fans.
+func (m *HalfEdgeMesh) Triangles() []*Triangle3 {
+	var mesh []*Triangle3
+	for f := range m.face {
+		if m.face[f] < 0 {
+			continue
+		}
+		vs := m.FaceVertices(f)
+		for i := 1; i < len(vs)-1; i++ {
+			mesh = append(mesh, NewTriangle3(m.vertex[vs[0]].p, m.vertex[vs[i]].p, m.vertex[vs[i+1]].p))
+		}
+	}
+	return mesh
+}
+
+//-----------------------------------------------------------------------------
+// Editing
+
+// isTriangle returns true if a face is a triangle.
+func (m *HalfEdgeMesh) isTriangle(f int) bool {
+	e := m.face[f]
+	return m.edge[m.edge[m.edge[e].next].next].next == e
+}
+
+// setTwin makes two half edges (either may be -1) twins.
+func (m *HalfEdgeMesh) setTwin(a, b int) {
+	if a >= 0 {
+		m.edge[a].twin = b
+	}
+	if b >= 0 {
+		m.edge[b].twin = a
+	}
+}
+
+// setOut sets the outgoing half edge of a vertex, moving it clockwise to the
+// boundary half edge if there is one.
+func (m *HalfEdgeMesh) setOut(v, e int) {
+	m.vertex[v].out = e
+	if e < 0 {
+		return
+	}
+	for x := e; ; {
+		t := m.edge[x].twin
+		if t < 0 {
+			m.vertex[v].out = x
+			return
+		}
+		x = m.edge[t].next
+		if x == e {
+			return
+		}
+	}
+}
+
+// FlipEdge replaces the edge of two triangles with the other diagonal of the
+// quadrilateral they form. It returns false if the edge can't be flipped.
+func (m *HalfEdgeMesh) FlipEdge(e int) bool {
+	t := m.edge[e].twin
+	if t < 0 || !m.isTriangle(m.edge[e].face) || !m.isTriangle(m.edge[t].face) {
+		return false
+	}
+	e1, e2 := m.edge[e].next, m.edge[e].prev
+	t1, t2 := m.edge[t].next, m.edge[t].prev
+	a, b := m.edge[e].from, m.edge[t].from
+	c, d := m.edge[e2].from, m.edge[t2].from
+	if c == d || m.HalfEdge(c, d) >= 0 || m.HalfEdge(d, c) >= 0 {
+		return false
+	}
+	f0, f1 := m.edge[e].face, m.edge[t].face
+	// (a, b, c) + (b, a, d) => (a, d, c) + (d, b, c)
+	m.edge[e].from, m.edge[t].from = d, c
+	m.link(f0, t1, e, e2)
+	m.link(f1, t2, e1, t)
+	if m.vertex[a].out == e {
+		m.setOut(a, t1)
+	}
+	if m.vertex[b].out == t {
+		m.setOut(b, e1)
+	}
+	return true
+}
+
+// link makes a triangle face from three half edges.
+func (m *HalfEdgeMesh) link(f, e0, e1, e2 int) {
+	es := [3]int{e0, e1, e2}
+	for i, e := range es {
+		m.edge[e].face = f
+		m.edge[e].next = es[(i+1)%3]
+		m.edge[e].prev = es[(i+2)%3]
+	}
+	m.face[f] = e0
+}
+
+// addEdge adds a half edge and returns its index.
+func (m *HalfEdgeMesh) addEdge(from int) int {
+	m.edge = append(m.edge, halfEdge{from: from, twin: -1})
+	return len(m.edge) - 1
+}
+
+// addFace adds a face and returns its index.
+func (m *HalfEdgeMesh) addFace() int {
+	m.face = append(m.face, -1)
+	return len(m.face) - 1
+}
+
+// SplitEdge splits an edge, and the triangles on each side of it, with a new
+// vertex at p. It returns the new vertex, or -1 if the faces are not
+// triangles.
+func (m *HalfEdgeMesh) SplitEdge(e int, p V3) int {
+	t := m.edge[e].twin
+	if !m.isTriangle(m.edge[e].face) || (t >= 0 && !m.isTriangle(m.edge[t].face)) {
+		return -1
+	}
+	v := len(m.vertex)
+	m.vertex = append(m.vertex, heVertex{p: p, out: -1})
+	// (a, b, c) => (a, v, c) + (v, b, c)
+	e1, e2 := m.edge[e].next, m.edge[e].prev
+	c := m.edge[e2].from
+	f0, g0 := m.edge[e].face, m.addFace()
+	x0, y0, z0 := m.addEdge(v), m.addEdge(v), m.addEdge(c)
+	m.link(f0, e, x0, e2)
+	m.link(g0, y0, e1, z0)
+	m.setTwin(x0, z0)
+	if t >= 0 {
+		// (b, a, d) => (b, v, d) + (v, a, d)
+		t1, t2 := m.edge[t].next, m.edge[t].prev
+		d := m.edge[t2].from
+		f1, g1 := m.edge[t].face, m.addFace()
+		x1, y1, z1 := m.addEdge(v), m.addEdge(v), m.addEdge(d)
+		m.link(f1, t, x1, t2)
+		m.link(g1, y1, t1, z1)
+		m.setTwin(x1, z1)
+		m.setTwin(e, y1)
+		m.setTwin(t, y0)
+	} else {
+		m.edge[y0].twin = -1
+	}
+	m.setOut(v, y0)
+	return v
+}
+
+// CollapseEdge collapses an edge of two triangles (or one on the boundary)
+// to a vertex at p. The start vertex of the edge is kept, the end vertex is
+// deleted. It returns false if the collapse would make the mesh
+// non-manifold.
+func (m *HalfEdgeMesh) CollapseEdge(e int, p V3) bool {
+	t := m.edge[e].twin
+	if !m.isTriangle(m.edge[e].face) || (t >= 0 && !m.isTriangle(m.edge[t].face)) {
+		return false
+	}
+	a, b := m.edge[e].from, m.to(e)
+	e1, e2 := m.edge[e].next, m.edge[e].prev
+	c := m.edge[e2].from
+	d := -1
+	if t >= 0 {
+		d = m.edge[m.edge[t].prev].from
+		if m.IsBoundaryVertex(a) && m.IsBoundaryVertex(b) {
+			// an interior edge between boundaries would pinch the mesh
+			return false
+		}
+	}
+	// link condition: the only shared neighbors are c and d
+	nb := make(map[int]bool)
+	for _, v := range m.VertexNeighbors(a) {
+		nb[v] = true
+	}
+	for _, v := range m.VertexNeighbors(b) {
+		if nb[v] && v != c && v != d {
+			return false
+		}
+	}
+	if t >= 0 && len(m.VertexNeighbors(a)) == 3 && len(m.VertexNeighbors(b)) == 3 {
+		// a tetrahedron
+		return false
+	}
+	if t < 0 && len(m.VertexNeighbors(a)) == 2 && len(m.VertexNeighbors(b)) == 2 {
+		// a single triangle
+		return false
+	}
+	bOut := m.outgoing(b)
+	// remove the (a, b, c) face, joining its other edges
+	o1, o2 := m.edge[e1].twin, m.edge[e2].twin
+	m.setTwin(o1, o2)
+	m.face[m.edge[e].face] = -1
+	dead := []int{e, e1, e2}
+	o3, o4 := -1, -1
+	if t >= 0 {
+		// remove the (b, a, d) face
+		t1, t2 := m.edge[t].next, m.edge[t].prev
+		o3, o4 = m.edge[t1].twin, m.edge[t2].twin
+		m.setTwin(o3, o4)
+		m.face[m.edge[t].face] = -1
+		dead = append(dead, t, t1, t2)
+	}
+	for _, x := range bOut {
+		m.edge[x].from = a
+	}
+	for _, x := range dead {
+		m.edge[x].from = -1
+	}
+	m.vertex[b].dead = true
+	m.vertex[b].out = -1
+	m.vertex[a].p = p
+	// update the outgoing half edges
+	live := func(x int) bool {
+		return x >= 0 && m.edge[x].from >= 0
+	}
+	outA := -1
+	for _, x := range append([]int{o2, o4}, bOut...) {
+		if live(x) && m.edge[x].from == a {
+			outA = x
+			break
+		}
+	}
+	m.setOut(a, outA)
+	// c (and d) are left with their other faces
+	m.fixOut(c, o1, o2)
+	if t >= 0 {
+		m.fixOut(d, o3, o4)
+	}
+	return true
+}
+
+// fixOut sets the outgoing half edge of a vertex after a face is removed.
+// o is an outgoing half edge, or i is an incoming half edge.
+func (m *HalfEdgeMesh) fixOut(v, o, i int) {
+	switch {
+	case o >= 0:
+		m.setOut(v, o)
+	case i >= 0:
+		m.setOut(v, m.edge[i].next)
+	default:
+		m.setOut(v, -1)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// check returns an error if the mesh connectivity is inconsistent.
+func (m *HalfEdgeMesh) check() error {
+	for i, x := range m.edge {
+		if x.from < 0 {
+			continue
+		}
+		if m.edge[x.next].prev != i || m.edge[x.prev].next != i {
+			return fmt.Errorf("half edge %d: bad next/prev", i)
+		}
+		if m.edge[x.next].face != x.face || m.face[x.face] < 0 {
+			return fmt.Errorf("half edge %d: bad face", i)
+		}
+		if x.twin >= 0 {
+			t := m.edge[x.twin]
+			if t.twin != i || t.from != m.to(i) || m.to(x.twin) != x.from {
+				return fmt.Errorf("half edge %d: bad twin", i)
+			}
+		}
+		if m.vertex[x.from].dead {
+			return fmt.Errorf("half edge %d: deleted vertex", i)
+		}
+	}
+	for v, x := range m.vertex {
+		if x.dead || x.out < 0 {
+			continue
+		}
+		if m.edge[x.out].from != v {
+			return fmt.Errorf("vertex %d: bad outgoing half edge", v)
+		}
+		// all the outgoing half edges are found
+		n := 0
+		for i := range m.edge {
+			if m.edge[i].from == v {
+				n++
+			}
+		}
+		if n != len(m.outgoing(v)) {
+			return fmt.Errorf("vertex %d: non-manifold", v)
+		}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,144 @@
+//-----------------------------------------------------------------------------
+/*
+
+Hatch and Fill Patterns
+
+Generate line patterns that fill an SDF2 region, E.g. for decorative
+engraving or CNC fill passes. The patterns are line segments, so they can be
+written with SaveDXF or SaveSVG.
+
+Hatch lines are found by sphere tracing along each line, so they are exact
+(to within the root finding tolerance) rather than limited to a grid
+resolution. Concentric fills are the contours of the inward offsets of the
+region.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import "math"
+
+//-----------------------------------------------------------------------------
+
+// hatchIterations is the number of bisection steps to find a boundary.
+const hatchIterations = 48
+
+// concentricSteps is the number of marching squares steps per fill spacing.
+const concentricSteps = 4
+
+//-----------------------------------------------------------------------------
+
+// hatchBoundary returns the position of the boundary between t0 and t1.
+func hatchBoundary(s SDF2, p0, u V2, t0, t1 float64, inside bool) float64 {
+	for i := 0; i < hatchIterations; i++ {
+		tm := 0.5 * (t0 + t1)
+		if (s.Evaluate(p0.Add(u.MulScalar(tm))) < 0) == inside {
+			t0 = tm
+		} else {
+			t1 = tm
+		}
+	}
+	return 0.5 * (t0 + t1)
+}
+
+// hatchLine returns the segments of a line (p0 + t * u, t0 <= t <= t1) that
+// are inside an SDF2.
+func hatchLine(s SDF2, p0, u V2, t0, t1, minStep float64) []*Line {
+	var lines []*Line
+	t := t0
+	d := s.Evaluate(p0.Add(u.MulScalar(t)))
+	inside := d < 0
+	start := t
+	for t < t1 {
+		tn := math.Min(t+math.Max(Abs(d), minStep), t1)
+		dn := s.Evaluate(p0.Add(u.MulScalar(tn)))
+		if (dn < 0) != inside {
+			tb := hatchBoundary(s, p0, u, t, tn, inside)
+			if inside {
+				lines = append(lines, &Line{p0.Add(u.MulScalar(start)), p0.Add(u.MulScalar(tb))})
+			} else {
+				start = tb
+			}
+			inside = !inside
+		}
+		t, d = tn, dn
+	}
+	if inside {
+		lines = append(lines, &Line{p0.Add(u.MulScalar(start)), p0.Add(u.MulScalar(t1))})
+	}
+	return lines
+}
+
+// HatchFill returns parallel lines that fill an SDF2. The lines are at an
+// angle (radians) to the x axis, the given spacing apart. Alternate lines run
+// in opposite directions so the segments form a zig-zag toolpath.
+func HatchFill(s SDF2, spacing, angle float64) []*Line {
+	if spacing <= 0 {
+		panic("spacing <= 0")
+	}
+	u := PolarToXY(1, angle) // line direction
+	n := V2{-u.Y, u.X}       // line normal
+	// the range of the bounding box along the line and the normal
+	v := s.BoundingBox().Vertices()
+	umin, umax := math.Inf(1), math.Inf(-1)
+	nmin, nmax := math.Inf(1), math.Inf(-1)
+	for _, p := range v {
+		umin = math.Min(umin, p.Dot(u))
+		umax = math.Max(umax, p.Dot(u))
+		nmin = math.Min(nmin, p.Dot(n))
+		nmax = math.Max(nmax, p.Dot(n))
+	}
+	minStep := 1e-3 * spacing
+	var lines []*Line
+	// the lines are on a grid with the origin between two lines
+	k0 := int(math.Floor(nmin/spacing - 0.5))
+	k1 := int(math.Ceil(nmax/spacing - 0.5))
+	reverse := false
+	for k := k0; k <= k1; k++ {
+		p0 := n.MulScalar((float64(k) + 0.5) * spacing)
+		x := hatchLine(s, p0, u, umin, umax, minStep)
+		if len(x) == 0 {
+			continue
+		}
+		if reverse {
+			// reverse the direction of alternate lines
+			for i, j := 0, len(x)-1; i < j; i, j = i+1, j-1 {
+				x[i], x[j] = x[j], x[i]
+			}
+			for _, l := range x {
+				l[0], l[1] = l[1], l[0]
+			}
+		}
+		lines = append(lines, x...)
+		reverse = !reverse
+	}
+	return lines
+}
+
+// CrossHatchFill returns two sets of perpendicular lines that fill an SDF2.
+func CrossHatchFill(s SDF2, spacing, angle float64) []*Line {
+	return append(HatchFill(s, spacing, angle), HatchFill(s, spacing, angle+0.5*Pi)...)
+}
+
+// ConcentricFill returns the contours of an SDF2 offset inwards in steps of
+// the spacing, starting half a spacing inside the boundary.
+func ConcentricFill(s SDF2, spacing float64) []*Line {
+	if spacing <= 0 {
+		panic("spacing <= 0")
+	}
+	step := spacing / concentricSteps
+	bb := s.BoundingBox()
+	bb = Box2{bb.Min.SubScalar(step), bb.Max.AddScalar(step)}
+	var lines []*Line
+	for k := 0; float64(k)*spacing < bb.Size().MaxComponent(); k++ {
+		x := marchingSquares(Offset2D(s, -(float64(k)+0.5)*spacing), bb, step)
+		if len(x) == 0 {
+			break
+		}
+		lines = append(lines, x...)
+	}
+	return lines
+}
+
+//-----------------------------------------------------------------------------
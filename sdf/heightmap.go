@@ -0,0 +1,180 @@
+//-----------------------------------------------------------------------------
+/*
+
+Height Maps
+
+Render a top-down (+Z) depth image of an SDF3 as a 16-bit gray scale PNG.
+The surface height is found by sphere tracing a ray down the z-axis at each
+pixel. Black is the bottom of the bounding box (or no surface) and white is
+the top of the bounding box.
+
+Height maps are used by CNC relief carving software and lithophane tools.
+
+A height map image can also be converted to an SDF3. The gray level of each
+pixel is the surface height, bilinearly interpolated between pixel centers.
+This is used for terrain models, lithophanes and textured plates.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+
+// HeightMap renders a 16-bit height map of an SDF3 viewed from +Z.
+// pixels is the number of pixels on the longest x/y axis of the bounding box.
+func HeightMap(s SDF3, pixels int) *image.Gray16 {
+	bb := s.BoundingBox()
+	size := bb.Size()
+	// square pixels
+	k := Max(size.X, size.Y) / float64(pixels)
+	nx := int(math.Ceil(size.X / k))
+	ny := int(math.Ceil(size.Y / k))
+	img := image.NewGray16(image.Rect(0, 0, nx, ny))
+	zmin, zmax := bb.Min.Z, bb.Max.Z
+	eps := size.MaxComponent() * 1e-4
+	rd := V3{0, 0, -1}
+	var wg sync.WaitGroup
+	for j := 0; j < ny; j++ {
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			// +y is at the top of the image
+			y := bb.Max.Y - (float64(j)+0.5)*k
+			for i := 0; i < nx; i++ {
+				x := bb.Min.X + (float64(i)+0.5)*k
+				ro := V3{x, y, zmax}
+				t, hit := RayMarch(s, ro, rd, eps)
+				if !hit {
+					continue
+				}
+				h := (ro.Z - t - zmin) / (zmax - zmin)
+				img.SetGray16(i, j, color.Gray16{uint16(math.Round(65535 * Clamp(h, 0, 1)))})
+			}
+		}(j)
+	}
+	wg.Wait()
+	return img
+}
+
+// RenderHeightMap renders a 16-bit height map of an SDF3 viewed from +Z to a PNG file.
+func RenderHeightMap(
+	s SDF3, // sdf3 to render
+	pixels int, // number of pixels on the longest x/y axis. e.g 1024
+	path string, // path to filename
+) error {
+	img := HeightMap(s, pixels)
+	bb := s.BoundingBox()
+	r := img.Bounds().Size()
+	fmt.Printf("rendering %s (%dx%d, z %.2f to %.2f)\n", path, r.X, r.Y, bb.Min.Z, bb.Max.Z)
+	return savePNG(path, img)
+}
+
+//-----------------------------------------------------------------------------
+// Height Map Terrain
+
+// HeightmapSDF3 is a height field solid.
+type HeightmapSDF3 struct {
+	h      []float64 // pixel heights, row major with row 0 at +y
+	nx, ny int       // image size
+	k      V2        // pixel size
+	size   V3        // size of the solid
+	slope  float64   // distance scaling for the maximum height gradient
+	bb     Box3
+}
+
+// Heightmap3D returns an SDF3 for a height field. The gray level of each image
+// pixel (black is 0, white is size.Z) is the height of the surface above z = 0.
+// The image covers size.X by size.Y centered on the origin, with the top of the
+// image at +y.
+func Heightmap3D(img image.Image, size V3) (SDF3, error) {
+	r := img.Bounds()
+	if r.Dx() < 2 || r.Dy() < 2 {
+		return nil, errors.New("height map image is too small")
+	}
+	if size.X <= 0 || size.Y <= 0 || size.Z <= 0 {
+		return nil, errors.New("size <= 0")
+	}
+	s := HeightmapSDF3{}
+	s.nx, s.ny = r.Dx(), r.Dy()
+	s.h = make([]float64, s.nx*s.ny)
+	for j := 0; j < s.ny; j++ {
+		for i := 0; i < s.nx; i++ {
+			c := color.Gray16Model.Convert(img.At(r.Min.X+i, r.Min.Y+j)).(color.Gray16)
+			s.h[j*s.nx+i] = size.Z * float64(c.Y) / 65535
+		}
+	}
+	s.k = V2{size.X / float64(s.nx), size.Y / float64(s.ny)}
+	s.size = size
+	// The interpolated surface has a gradient no larger than the maximum pixel
+	// to pixel slopes, so the height difference is a distance bound when
+	// scaled by the slope of the steepest possible surface.
+	var gx, gy float64
+	for j := 0; j < s.ny; j++ {
+		for i := 0; i < s.nx; i++ {
+			h := s.h[j*s.nx+i]
+			if i+1 < s.nx {
+				gx = Max(gx, Abs(s.h[j*s.nx+i+1]-h)/s.k.X)
+			}
+			if j+1 < s.ny {
+				gy = Max(gy, Abs(s.h[(j+1)*s.nx+i]-h)/s.k.Y)
+			}
+		}
+	}
+	s.slope = 1 / math.Sqrt(1+gx*gx+gy*gy)
+	s.bb = Box3{V3{-0.5 * size.X, -0.5 * size.Y, 0}, V3{0.5 * size.X, 0.5 * size.Y, size.Z}}
+	return &s, nil
+}
+
+// LoadHeightmap3D returns an SDF3 for a height field from an image file.
+func LoadHeightmap3D(path string, size V3) (SDF3, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return Heightmap3D(img, size)
+}
+
+// Height returns the interpolated height of the surface at a point.
+func (s *HeightmapSDF3) Height(p V2) float64 {
+	// pixel coordinates, pixel centers are at integer values
+	x := Clamp((p.X+0.5*s.size.X)/s.k.X-0.5, 0, float64(s.nx-1))
+	y := Clamp((0.5*s.size.Y-p.Y)/s.k.Y-0.5, 0, float64(s.ny-1))
+	x0 := Min(math.Floor(x), float64(s.nx-2))
+	y0 := Min(math.Floor(y), float64(s.ny-2))
+	fx, fy := x-x0, y-y0
+	i, j := int(x0), int(y0)
+	h0 := s.h[j*s.nx+i]*(1-fx) + s.h[j*s.nx+i+1]*fx
+	h1 := s.h[(j+1)*s.nx+i]*(1-fx) + s.h[(j+1)*s.nx+i+1]*fx
+	return h0*(1-fy) + h1*fy
+}
+
+// Evaluate returns the minimum distance to a height field.
+func (s *HeightmapSDF3) Evaluate(p V3) float64 {
+	d := (p.Z - s.Height(V2{p.X, p.Y})) * s.slope
+	// intersect with the bounding box
+	c := s.bb.Center()
+	return Max(d, sdfBox3d(p.Sub(c), s.bb.Size().MulScalar(0.5)))
+}
+
+// BoundingBox returns the bounding box for a height field.
+func (s *HeightmapSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,256 @@
+//-----------------------------------------------------------------------------
+/*
+
+Convex Hulls and Minkowski Sums
+
+Hull3D makes the (approximate) convex hull of a set of SDF3s, like the OpenSCAD
+hull() operation.
+
+The SDF3s are meshed and the convex hull is found by support point sampling:
+for a set of directions the support plane (the plane that touches the mesh
+with all of the mesh behind it) is found. The hull is the intersection of the
+half spaces behind the support planes. The directions are evenly spread over
+the unit sphere, plus the face normals of the mesh, so flat faces of the SDF3s
+become flat faces of the hull.
+
+The hull is slightly larger than the true convex hull at edges and corners
+that aren't on a mesh face. More mesh cells gives more directions, and a closer
+fit.
+
+Minkowski3D makes the Minkowski sum of an SDF3 and a convex SDF3, like the
+OpenSCAD minkowski() operation. The boundary point of the sum in the direction
+of a surface normal n is the boundary point of the first SDF3 with normal n
+plus the support point of the convex SDF3 in direction n. So the distance is
+found by evaluating the first SDF3 at p minus the support point for the normal,
+and iterating to refine the normal.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+// hullDirectionsPerCell is the number of support directions per mesh cell.
+const hullDirectionsPerCell = 8
+
+// HullSDF3 is a convex hull defined by support planes.
+type HullSDF3 struct {
+	normal []V3      // support plane normals
+	offset []float64 // support plane offsets
+	bb     Box3
+}
+
+// Hull3D returns the convex hull of one or more SDF3s.
+func Hull3D(
+	meshCells int, // number of cells on the longest axis of each SDF3, e.g. 100
+	sdf ...SDF3, // SDF3s to hull
+) (SDF3, error) {
+	ndirs := hullDirectionsPerCell * meshCells
+	// Sample the surfaces. The face normals are counted in about ndirs patches
+	// on the unit sphere.
+	q := math.Sqrt(float64(ndirs) / (4 * Pi))
+	normals := make(map[V3]map[V3]int)
+	index := make(map[V3]bool)
+	var vertex []V3
+	for _, s := range sdf {
+		if s == nil {
+			continue
+		}
+		for _, t := range RenderMesh(s, meshCells) {
+			for _, v := range t.V {
+				if !index[v] {
+					index[v] = true
+					vertex = append(vertex, v)
+				}
+			}
+			n := t.Normal()
+			if l := n.Length(); l == 0 || math.IsNaN(l) {
+				// degenerate triangle
+				continue
+			}
+			m := n.MulScalar(q)
+			k := V3{math.Round(m.X), math.Round(m.Y), math.Round(m.Z)}
+			if normals[k] == nil {
+				normals[k] = make(map[V3]int)
+			}
+			normals[k][n.Quantize()]++
+		}
+	}
+	if len(vertex) == 0 {
+		return nil, errors.New("no surface to hull")
+	}
+	// support directions: the axes (so the hull fits the bounding box), evenly
+	// spread directions, and the most common face normal of each patch (so
+	// flat faces of the SDF3s are flat faces of the hull)
+	dirs := []V3{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}}
+	dirs = append(dirs, fibonacciSphere(ndirs)...)
+	for _, patch := range normals {
+		var best V3
+		count := 0
+		for n, c := range patch {
+			if c > count || (c == count && compareV3(n, best) < 0) {
+				best, count = n, c
+			}
+		}
+		dirs = append(dirs, best.Normalize())
+	}
+	return hullPlanes(vertex, dirs), nil
+}
+
+// fibonacciSphere returns n directions evenly spread over the unit sphere.
+func fibonacciSphere(n int) []V3 {
+	dirs := make([]V3, n)
+	golden := Pi * (3 - math.Sqrt(5))
+	for i := range dirs {
+		z := 1 - (2*float64(i)+1)/float64(n)
+		r := math.Sqrt(1 - z*z)
+		sin, cos := math.Sincos(golden * float64(i))
+		dirs[i] = V3{r * cos, r * sin, z}
+	}
+	return dirs
+}
+
+// hullPlanes returns the convex hull of a set of points as the intersection of
+// the support planes in a set of directions.
+func hullPlanes(vertex []V3, dirs []V3) *HullSDF3 {
+	s := HullSDF3{}
+	s.normal = make([]V3, len(dirs))
+	s.offset = make([]float64, len(dirs))
+	for i, d := range dirs {
+		h := -math.MaxFloat64
+		for _, v := range vertex {
+			h = Max(h, v.Dot(d))
+		}
+		s.normal[i] = d
+		s.offset[i] = h
+	}
+	s.bb = Box3{vertex[0], vertex[0]}
+	for _, v := range vertex {
+		s.bb = s.bb.Include(v)
+	}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a convex hull.
+func (s *HullSDF3) Evaluate(p V3) float64 {
+	d := -math.MaxFloat64
+	for i, n := range s.normal {
+		d = Max(d, p.Dot(n)-s.offset[i])
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a convex hull.
+func (s *HullSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Minkowski Sum
+
+// minkowskiIterations is the number of steps in the search for the closest
+// point of the tool.
+const minkowskiIterations = 6
+
+// MinkowskiSDF3 is the Minkowski sum of an SDF3 and a convex SDF3.
+type MinkowskiSDF3 struct {
+	sdf    SDF3
+	tool   SDF3      // convex tool
+	sample []V3      // tool sample points
+	k      []float64 // depth of the sample points within the tool
+	eps    float64   // normal estimation step
+	bb     Box3
+}
+
+// Minkowski3D returns the Minkowski sum of an SDF3 and a convex SDF3 (the
+// tool). A sphere tool gives the OpenSCAD rounding idiom:
+// minkowski() { cube(); sphere(); }, and other tools (e.g. boxes) can be used
+// to expand an SDF3 by a tolerance zone. The result is close to exact when the
+// SDF3 and the tool have exact distance fields and the SDF3 is convex,
+// otherwise it is an approximation that is good for tools that are small
+// relative to the features of the SDF3.
+func Minkowski3D(sdf, tool SDF3) (SDF3, error) {
+	if sdf == nil || tool == nil {
+		return nil, errors.New("nil sdf")
+	}
+	if t, ok := tool.(*SphereSDF3); ok {
+		// an exact offset
+		return Offset3D(sdf, t.radius), nil
+	}
+	s := MinkowskiSDF3{}
+	s.sdf = sdf
+	s.tool = tool
+	bb := tool.BoundingBox()
+	sbb := sdf.BoundingBox()
+	s.eps = 1e-5 * sbb.Size().Add(bb.Size()).Length()
+	// Sample the tool at its center and axial extremes. The center is the
+	// start point for the search, and the extremes help in concave regions of
+	// the SDF3 where the normals are a poor guide.
+	c := bb.Center()
+	h := bb.Size().MulScalar(0.5)
+	for _, v := range []V3{{}, {h.X, 0, 0}, {-h.X, 0, 0}, {0, h.Y, 0}, {0, -h.Y, 0}, {0, 0, h.Z}, {0, 0, -h.Z}} {
+		b, k := project(tool, c.Add(v), s.eps)
+		s.sample = append(s.sample, b)
+		s.k = append(s.k, k)
+	}
+	s.bb = Box3{sbb.Min.Add(bb.Min), sbb.Max.Add(bb.Max)}
+	return &s, nil
+}
+
+// project returns the closest point on (or in) an SDF3 to p, and the distance
+// to the surface for points inside the SDF3.
+func project(s SDF3, p V3, eps float64) (V3, float64) {
+	d := s.Evaluate(p)
+	if d <= 0 {
+		return p, d
+	}
+	return p.Sub(Normal3(s, p, eps).MulScalar(d)), 0
+}
+
+// depth combines the distance to the SDF3 and the depth within the tool. The
+// depth is only used for points inside (or on) the SDF3, since outside the SDF3
+// the distance may be a bound that's less than the true distance.
+func (s *MinkowskiSDF3) depth(d, depth float64) float64 {
+	if d <= s.eps {
+		return d + depth
+	}
+	return d
+}
+
+// Evaluate returns the minimum distance to a Minkowski sum.
+func (s *MinkowskiSDF3) Evaluate(p V3) float64 {
+	// Point p is in the sum when there is a tool point b with p - b in the
+	// SDF3. The closest tool point is found by alternating projections onto
+	// the SDF3 and the tool, which converges for convex shapes. When p - b is
+	// inside the SDF3 the depth of b within the tool adds to the depth of p.
+	d := math.MaxFloat64
+	for i, v := range s.sample[1:] {
+		d = Min(d, s.depth(s.sdf.Evaluate(p.Sub(v)), s.k[i+1]))
+	}
+	// start the search at the tool center
+	b, k := s.sample[0], s.k[0]
+	for i := 0; i < minkowskiIterations; i++ {
+		q := p.Sub(b)
+		x := s.sdf.Evaluate(q)
+		d = Min(d, s.depth(x, k))
+		if x <= 0 {
+			break
+		}
+		q = q.Sub(Normal3(s.sdf, q, s.eps).MulScalar(x))
+		b, k = project(s.tool, p.Sub(q), s.eps)
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a Minkowski sum.
+func (s *MinkowskiSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,209 @@
+//-----------------------------------------------------------------------------
+/*
+
+Honeycomb, Grid and Voronoi Lattices
+
+Prismatic lattices (walls parallel to the z-axis) used as infill for 3d
+printed parts. The lattices are made by domain repetition: a point is mapped
+into its cell and the distance to the cell walls is found, so the cost of
+evaluation doesn't depend on the number of cells.
+
+The Voronoi lattice has walls on the boundaries of the Voronoi cells of a set
+of randomly placed (seeded) points, one per cube of a grid. Intersecting it with
+a shell gives an organic perforated surface (see VoronoiShell3D).
+
+As with the TPMS lattices the lattices are infinite, their bounding box is a
+single cell and they should be intersected with a solid (see Infill3D).
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import "math"
+
+//-----------------------------------------------------------------------------
+// Honeycomb
+
+// HoneycombSDF3 is a hexagonal honeycomb lattice.
+type HoneycombSDF3 struct {
+	r    float64 // cell inradius (half the cell size)
+	wall float64 // half the wall thickness
+	bb   Box3
+}
+
+// Honeycomb3D returns a lattice of hexagonal prisms along the z-axis. The cell
+// size is the distance between the parallel walls of a cell. A cell is centered
+// on the origin, with walls parallel to the x-axis. The bounding box is that
+// single cell, so use the lattice as the second argument of Intersect3D (which
+// takes its bounding box from the first) or with Infill3D.
+func Honeycomb3D(cellSize, wallThickness float64) SDF3 {
+	if cellSize <= 0 {
+		panic("cellSize <= 0")
+	}
+	if wallThickness <= 0 {
+		panic("wallThickness <= 0")
+	}
+	s := HoneycombSDF3{}
+	s.r = 0.5 * cellSize
+	s.wall = 0.5 * wallThickness
+	// the bounding box of the cell at the origin
+	d := V3{s.r * 2 / math.Sqrt(3), s.r, s.r}
+	s.bb = Box3{d.Neg(), d}
+	return &s
+}
+
+// hexCell returns a point relative to the center of its hexagonal cell.
+// The cell centers are the union of two rectangular grids.
+func hexCell(p V2, r float64) V2 {
+	k := V2{2 * math.Sqrt(3) * r, 2 * r}
+	a := V2{p.X - k.X*math.Round(p.X/k.X), p.Y - k.Y*math.Round(p.Y/k.Y)}
+	q := p.Sub(k.MulScalar(0.5))
+	b := V2{q.X - k.X*math.Round(q.X/k.X), q.Y - k.Y*math.Round(q.Y/k.Y)}
+	if a.Length2() < b.Length2() {
+		return a
+	}
+	return b
+}
+
+// Evaluate returns the minimum distance to a honeycomb lattice.
+func (s *HoneycombSDF3) Evaluate(p V3) float64 {
+	q := hexCell(V2{p.X, p.Y}, s.r).Abs()
+	// distance to the closest wall of the cell
+	h := Max(q.Y, 0.5*(math.Sqrt(3)*q.X+q.Y))
+	return s.r - h - s.wall
+}
+
+// BoundingBox returns the bounding box (a single cell) of a honeycomb lattice.
+func (s *HoneycombSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Grid
+
+// GridSDF3 is a rectangular grid lattice.
+type GridSDF3 struct {
+	size V2      // cell size
+	wall float64 // half the wall thickness
+	bb   Box3
+}
+
+// Grid3D returns a lattice of rectangular prisms along the z-axis. A cell is
+// centered on the origin. The bounding box is that single cell, so use the
+// lattice as the second argument of Intersect3D (which takes its bounding box
+// from the first) or with Infill3D.
+func Grid3D(cellSize V2, wallThickness float64) SDF3 {
+	if cellSize.X <= 0 || cellSize.Y <= 0 {
+		panic("cellSize <= 0")
+	}
+	if wallThickness <= 0 {
+		panic("wallThickness <= 0")
+	}
+	s := GridSDF3{}
+	s.size = cellSize
+	s.wall = 0.5 * wallThickness
+	d := V3{0.5 * cellSize.X, 0.5 * cellSize.Y, 0.5 * cellSize.MinComponent()}
+	s.bb = Box3{d.Neg(), d}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a grid lattice.
+func (s *GridSDF3) Evaluate(p V3) float64 {
+	// distance from the cell center
+	x := Abs(p.X - s.size.X*math.Round(p.X/s.size.X))
+	y := Abs(p.Y - s.size.Y*math.Round(p.Y/s.size.Y))
+	// distance to the closest wall of the cell
+	return Min(0.5*s.size.X-x, 0.5*s.size.Y-y) - s.wall
+}
+
+// BoundingBox returns the bounding box (a single cell) of a grid lattice.
+func (s *GridSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Voronoi
+
+// VoronoiSDF3 is a lattice with walls on the boundaries of Voronoi cells.
+type VoronoiSDF3 struct {
+	points *worleyNoise3 // cell points
+	size   float64       // cell size
+	wall   float64       // half the wall thickness
+	bb     Box3
+}
+
+// Voronoi3D returns a Voronoi lattice. There is one (seeded) random cell point
+// per cube of a grid with the given cell size. The bounding box is a single
+// cube, so use the lattice as the second argument of Intersect3D (which takes
+// its bounding box from the first) or with Infill3D.
+func Voronoi3D(cellSize, wallThickness float64, seed int64) SDF3 {
+	if cellSize <= 0 {
+		panic("cellSize <= 0")
+	}
+	if wallThickness <= 0 {
+		panic("wallThickness <= 0")
+	}
+	s := VoronoiSDF3{}
+	s.points = &worleyNoise3{noisePerm(seed)}
+	s.size = cellSize
+	s.wall = 0.5 * wallThickness
+	s.bb = Box3{V3{}, V3{cellSize, cellSize, cellSize}}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a Voronoi lattice.
+func (s *VoronoiSDF3) Evaluate(p V3) float64 {
+	p = p.DivScalar(s.size)
+	i, j, k := int(math.Floor(p.X)), int(math.Floor(p.Y)), int(math.Floor(p.Z))
+	// find the closest cell point
+	var a V3
+	d2 := math.MaxFloat64
+	for di := -1; di <= 1; di++ {
+		for dj := -1; dj <= 1; dj++ {
+			for dk := -1; dk <= 1; dk++ {
+				c := s.points.feature(i+di, j+dj, k+dk)
+				if x := c.Sub(p).Length2(); x < d2 {
+					a, d2 = c, x
+				}
+			}
+		}
+	}
+	// The cell boundaries are the bisector planes of the closest point and
+	// the other points. The distance to the closest plane is a lower bound on
+	// the distance to the boundary.
+	d := math.MaxFloat64
+	for di := -2; di <= 2; di++ {
+		for dj := -2; dj <= 2; dj++ {
+			for dk := -2; dk <= 2; dk++ {
+				c := s.points.feature(i+di, j+dj, k+dk)
+				n := c.Sub(a)
+				if l := n.Length(); l > 0 {
+					d = Min(d, p.Sub(a.Add(c).MulScalar(0.5)).Dot(n.DivScalar(-l)))
+				}
+			}
+		}
+	}
+	return d*s.size - s.wall
+}
+
+// BoundingBox returns the bounding box (a single cell) of a Voronoi lattice.
+func (s *VoronoiSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+// VoronoiShell3D returns a shell of an SDF3 perforated with a Voronoi pattern.
+// The shell (on the inside of the SDF3 surface) has the given thickness, and
+// the struts between the holes have a thickness of about strutThickness.
+func VoronoiShell3D(
+	sdf SDF3, // SDF3 to be perforated
+	shellThickness float64, // thickness of the shell
+	cellSize float64, // size of the voronoi cells
+	strutThickness float64, // thickness of the struts between the holes
+	seed int64, // random seed for the voronoi cells
+) SDF3 {
+	shell := Shell3D(sdf, shellThickness, ShellInside)
+	return Intersect3D(shell, Voronoi3D(cellSize, strutThickness, seed))
+}
+
+//-----------------------------------------------------------------------------
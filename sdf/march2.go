@@ -162,9 +162,10 @@ func msInterpolate(p1, p2 V2, v1, v2, x float64) V2 {
 		return p1
 	}
 	t := (x - v1) / (v2 - v1)
+	// Note: float64() conversions prevent fused multiply-add (see determinism.go)
 	return V2{
-		p1.X + t*(p2.X-p1.X),
-		p1.Y + t*(p2.Y-p1.Y),
+		p1.X + float64(t*(p2.X-p1.X)),
+		p1.Y + float64(t*(p2.Y-p1.Y)),
 	}
 }
 
@@ -23,6 +23,14 @@ type square struct {
 	n uint // level of square, size = 1 << n
 }
 
+// cells returns the number of smallest (level 1) squares within a square.
+func (c *square) cells() int64 {
+	if c.n == 0 {
+		return 1
+	}
+	return 1 << (2 * (c.n - 1))
+}
+
 //-----------------------------------------------------------------------------
 // Evaluate the SDF2 via a distance cache to avoid repeated evaluations.
 
@@ -33,6 +41,7 @@ type dcache2 struct {
 	s          SDF2            // the SDF2 to be rendered
 	cache      map[V2i]float64 // cache of distances
 	lock       sync.RWMutex    // lock the the cache during reads/writes
+	progress   *renderProgress // render progress and cancellation
 }
 
 func newDcache2(s SDF2, origin V2, resolution float64, n uint) *dcache2 {
@@ -92,7 +101,12 @@ func (dc *dcache2) isEmpty(c *square) bool {
 
 // Process a square. Generate line segments, or more squares.
 func (dc *dcache2) processSquare(c *square, output chan<- *Line) {
-	if !dc.isEmpty(c) {
+	if dc.progress.err != nil || (c.n > 1 && dc.progress.cancelled()) {
+		return
+	}
+	if dc.isEmpty(c) {
+		dc.progress.add(c.cells())
+	} else {
 		if c.n == 1 {
 			// this square is at the required resolution
 			c0, d0 := dc.evaluate(c.v.Add(V2i{0, 0}))
@@ -105,6 +119,7 @@ func (dc *dcache2) processSquare(c *square, output chan<- *Line) {
 			for _, l := range msToLines(corners, values, 0) {
 				output <- l
 			}
+			dc.progress.add(1)
 		} else {
 			// process the sub squares
 			n := c.n - 1
@@ -121,7 +136,8 @@ func (dc *dcache2) processSquare(c *square, output chan<- *Line) {
 //-----------------------------------------------------------------------------
 
 // marchingSquaresQuadtree generates line segments for an SDF2 using quadtree subdivision.
-func marchingSquaresQuadtree(s SDF2, resolution float64, output chan<- *Line) {
+// It returns an error if the render was cancelled.
+func marchingSquaresQuadtree(s SDF2, resolution float64, output chan<- *Line, progress *renderProgress) error {
 	// Scale the bounding box about the center to make sure the boundaries
 	// aren't on the object surface.
 	bb := s.BoundingBox()
@@ -134,8 +150,12 @@ func marchingSquaresQuadtree(s SDF2, resolution float64, output chan<- *Line) {
 	levels := uint(math.Ceil(math.Log2(longAxis/resolution))) + 1
 	// create the distance cache
 	dc := newDcache2(s, bb.Min, resolution, levels)
+	dc.progress = progress
 	// process the quadtree, start at the top level
-	dc.processSquare(&square{V2i{0, 0}, levels - 1}, output)
+	top := &square{V2i{0, 0}, levels - 1}
+	progress.start(top.cells())
+	dc.processSquare(top, output)
+	return progress.err
 }
 
 //-----------------------------------------------------------------------------
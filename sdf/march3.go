@@ -234,10 +234,11 @@ func mcInterpolate(p1, p2 V3, v1, v2, x float64) V3 {
 		return p1
 	}
 	t := (x - v1) / (v2 - v1)
+	// Note: float64() conversions prevent fused multiply-add (see determinism.go)
 	return V3{
-		p1.X + t*(p2.X-p1.X),
-		p1.Y + t*(p2.Y-p1.Y),
-		p1.Z + t*(p2.Z-p1.Z),
+		p1.X + float64(t*(p2.X-p1.X)),
+		p1.Y + float64(t*(p2.Y-p1.Y)),
+		p1.Z + float64(t*(p2.Z-p1.Z)),
 	}
 }
 
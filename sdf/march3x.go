@@ -23,6 +23,14 @@ type cube struct {
 	n uint // level of cube, size = 1 << n
 }
 
+// cells returns the number of smallest (level 1) cubes within a cube.
+func (c *cube) cells() int64 {
+	if c.n == 0 {
+		return 1
+	}
+	return 1 << (3 * (c.n - 1))
+}
+
 //-----------------------------------------------------------------------------
 // Evaluate the SDF3 via a distance cache to avoid repeated evaluations.
 // Experimentally about 2/3 of lookups get a hit, and the overall speedup
@@ -35,6 +43,7 @@ type dcache3 struct {
 	s          SDF3            // the SDF3 to be rendered
 	cache      map[V3i]float64 // cache of distances
 	lock       sync.RWMutex    // lock the the cache during reads/writes
+	progress   *renderProgress // render progress and cancellation
 }
 
 func newDcache3(s SDF3, origin V3, resolution float64, n uint) *dcache3 {
@@ -96,7 +105,12 @@ func (dc *dcache3) isEmpty(c *cube) bool {
 
 // Process a cube. Generate triangles, or more cubes.
 func (dc *dcache3) processCube(c *cube, output chan<- *Triangle3) {
-	if !dc.isEmpty(c) {
+	if dc.progress.err != nil || (c.n > 1 && dc.progress.cancelled()) {
+		return
+	}
+	if dc.isEmpty(c) {
+		dc.progress.add(c.cells())
+	} else {
 		if c.n == 1 {
 			// this cube is at the required resolution
 			c0, d0 := dc.evaluate(c.v.Add(V3i{0, 0, 0}))
@@ -113,6 +127,7 @@ func (dc *dcache3) processCube(c *cube, output chan<- *Triangle3) {
 			for _, t := range mcToTriangles(corners, values, 0) {
 				output <- t
 			}
+			dc.progress.add(1)
 		} else {
 			// process the sub cubes
 			n := c.n - 1
@@ -133,7 +148,8 @@ func (dc *dcache3) processCube(c *cube, output chan<- *Triangle3) {
 //-----------------------------------------------------------------------------
 
 // marchingCubesOctree generates a triangle mesh for an SDF3 using octree subdivision.
-func marchingCubesOctree(s SDF3, resolution float64, output chan<- *Triangle3) {
+// It returns an error if the render was cancelled.
+func marchingCubesOctree(s SDF3, resolution float64, output chan<- *Triangle3, progress *renderProgress) error {
 	// Scale the bounding box about the center to make sure the boundaries
 	// aren't on the object surface.
 	bb := s.BoundingBox()
@@ -146,8 +162,12 @@ func marchingCubesOctree(s SDF3, resolution float64, output chan<- *Triangle3) {
 	levels := uint(math.Ceil(math.Log2(longAxis/resolution))) + 1
 	// create the distance cache
 	dc := newDcache3(s, bb.Min, resolution, levels)
+	dc.progress = progress
 	// process the octree, start at the top level
-	dc.processCube(&cube{V3i{0, 0, 0}, levels - 1}, output)
+	top := &cube{V3i{0, 0, 0}, levels - 1}
+	progress.start(top.cells())
+	dc.processCube(top, output)
+	return progress.err
 }
 
 //-----------------------------------------------------------------------------
@@ -126,7 +126,7 @@ func Translate2d(v V2) M33 {
 }
 
 // Scale3d returns a 4x4 scaling matrix.
-// Scaling does not preserve distance. See: ScaleUniform3D()
+// Scaling does not preserve distance. See: ScaleUniform3D() and Scale3D()
 func Scale3d(v V3) M44 {
 	return M44{
 		v.X, 0, 0, 0,
@@ -136,7 +136,7 @@ func Scale3d(v V3) M44 {
 }
 
 // Scale2d returns a 3x3 scaling matrix.
-// Scaling does not preserve distance. See: ScaleUniform2D().
+// Scaling does not preserve distance. See: ScaleUniform2D() and Scale2D().
 func Scale2d(v V2) M33 {
 	return M33{
 		v.X, 0, 0,
@@ -282,21 +282,22 @@ func (a M22) Equals(b M22, tolerance float64) bool {
 
 // MulPosition multiplies a V3 position with a rotate/translate matrix.
 func (a M44) MulPosition(b V3) V3 {
-	return V3{a.x00*b.X + a.x01*b.Y + a.x02*b.Z + a.x03,
-		a.x10*b.X + a.x11*b.Y + a.x12*b.Z + a.x13,
-		a.x20*b.X + a.x21*b.Y + a.x22*b.Z + a.x23}
+	// Note: float64() conversions prevent fused multiply-add (see determinism.go)
+	return V3{float64(a.x00*b.X) + float64(a.x01*b.Y) + float64(a.x02*b.Z) + a.x03,
+		float64(a.x10*b.X) + float64(a.x11*b.Y) + float64(a.x12*b.Z) + a.x13,
+		float64(a.x20*b.X) + float64(a.x21*b.Y) + float64(a.x22*b.Z) + a.x23}
 }
 
 // MulPosition multiplies a V2 position with a rotate/translate matrix.
 func (a M33) MulPosition(b V2) V2 {
-	return V2{a.x00*b.X + a.x01*b.Y + a.x02,
-		a.x10*b.X + a.x11*b.Y + a.x12}
+	return V2{float64(a.x00*b.X) + float64(a.x01*b.Y) + a.x02,
+		float64(a.x10*b.X) + float64(a.x11*b.Y) + a.x12}
 }
 
 // MulPosition multiplies a V2 position with a rotate matrix.
 func (a M22) MulPosition(b V2) V2 {
-	return V2{a.x00*b.X + a.x01*b.Y,
-		a.x10*b.X + a.x11*b.Y}
+	return V2{float64(a.x00*b.X) + float64(a.x01*b.Y),
+		float64(a.x10*b.X) + float64(a.x11*b.Y)}
 }
 
 //-----------------------------------------------------------------------------
@@ -424,9 +425,10 @@ func (a M44) Determinant() float64 {
 
 // Determinant returns the determinant of a 3x3 matrix.
 func (a M33) Determinant() float64 {
-	return (a.x00*(a.x11*a.x22-a.x21*a.x12) -
-		a.x01*(a.x10*a.x22-a.x20*a.x12) +
-		a.x02*(a.x10*a.x21-a.x20*a.x11))
+	// Note: float64() conversions prevent fused multiply-add (see determinism.go)
+	return float64(a.x00*(float64(a.x11*a.x22)-float64(a.x21*a.x12))) -
+		float64(a.x01*(float64(a.x10*a.x22)-float64(a.x20*a.x12))) +
+		float64(a.x02*(float64(a.x10*a.x21)-float64(a.x20*a.x11)))
 }
 
 // Determinant returns the determinant of a 2x2 matrix.
@@ -436,6 +438,52 @@ func (a M22) Determinant() float64 {
 
 //-----------------------------------------------------------------------------
 
+// MinScale returns the minimum scaling (the smallest singular value) of the
+// linear part of a 4x4 transformation matrix. Distances are scaled by at least
+// this factor, so it is 1 for rotations and translations.
+func (a M44) MinScale() float64 {
+	// b = transpose(m) * m, where m is the linear part of a
+	// Note: V3 methods and float64() prevent fused multiply-add (see determinism.go)
+	b00 := V3{a.x00, a.x10, a.x20}.Length2()
+	b11 := V3{a.x01, a.x11, a.x21}.Length2()
+	b22 := V3{a.x02, a.x12, a.x22}.Length2()
+	b01 := V3{a.x00, a.x10, a.x20}.Dot(V3{a.x01, a.x11, a.x21})
+	b02 := V3{a.x00, a.x10, a.x20}.Dot(V3{a.x02, a.x12, a.x22})
+	b12 := V3{a.x01, a.x11, a.x21}.Dot(V3{a.x02, a.x12, a.x22})
+	// smallest eigenvalue of the symmetric matrix b
+	var e float64
+	p1 := V3{b01, b02, b12}.Length2()
+	if p1 == 0 {
+		e = Min(b00, Min(b11, b22))
+	} else {
+		q := (b00 + b11 + b22) / 3
+		p := math.Sqrt((V3{b00 - q, b11 - q, b22 - q}.Length2() + 2*p1) / 6)
+		c := M33{
+			b00 - q, b01, b02,
+			b01, b11 - q, b12,
+			b02, b12, b22 - q}
+		r := Clamp(0.5*c.Determinant()/(p*p*p), -1, 1)
+		e = q + float64(2*p*math.Cos(math.Acos(r)/3+Tau/3))
+	}
+	return math.Sqrt(Max(e, 0))
+}
+
+// MinScale returns the minimum scaling (the smallest singular value) of the
+// linear part of a 3x3 transformation matrix.
+func (a M33) MinScale() float64 {
+	// smallest eigenvalue of transpose(m) * m, where m is the linear part of a
+	// Note: V2 methods prevent fused multiply-add (see determinism.go)
+	b00 := V2{a.x00, a.x10}.Length2()
+	b11 := V2{a.x01, a.x11}.Length2()
+	b01 := V2{a.x00, a.x10}.Dot(V2{a.x01, a.x11})
+	t := 0.5 * (b00 + b11)
+	d := 0.5 * (b00 - b11)
+	e := t - math.Sqrt(V2{d, b01}.Length2())
+	return math.Sqrt(Max(e, 0))
+}
+
+//-----------------------------------------------------------------------------
+
 // Inverse returns the inverse of a 4x4 matrix.
 func (a M44) Inverse() M44 {
 	m := M44{}
@@ -0,0 +1,154 @@
+//-----------------------------------------------------------------------------
+/*
+
+Medial Axis
+
+The medial axis (or skeleton) of a 2D region is the set of points inside the
+region that have more than one closest point on the boundary. It is the ridge
+of the distance field. Uses include centerline engraving tool paths and wall
+thickness analysis, the distance from a point on the medial axis to the
+boundary is half the local wall thickness.
+
+The distance field is sampled on a grid. For each grid point the direction to
+the closest boundary point is found from the distance field gradient. Where
+these directions differ by more than a minimum angle between two neighbouring
+grid points the medial axis passes between them. The crossing point is where
+the two closest boundary points are equidistant. The crossing points in each
+grid cell are joined to make the line segments of the axis.
+
+The minimum angle prunes the axis. Small angles give the full medial axis,
+including the branches to every convex corner. Larger angles keep only the
+parts of the axis between boundaries that face each other.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import "math"
+
+//-----------------------------------------------------------------------------
+
+// gradient2 returns the gradient of an SDF2 at a point, and true if the
+// point is on a ridge (the gradient is discontinuous).
+func gradient2(s SDF2, p V2, h float64) (V2, bool) {
+	d := s.Evaluate(p)
+	x0 := d - s.Evaluate(V2{p.X - h, p.Y})
+	x1 := s.Evaluate(V2{p.X + h, p.Y}) - d
+	y0 := d - s.Evaluate(V2{p.X, p.Y - h})
+	y1 := s.Evaluate(V2{p.X, p.Y + h}) - d
+	// the backward and forward differences differ across a ridge
+	ridge := Max(Abs(x1-x0), Abs(y1-y0)) > 0.1*h
+	return V2{x0 + x1, y0 + y1}.DivScalar(2 * h), ridge
+}
+
+// medialSample is a distance field sample for the medial axis.
+type medialSample struct {
+	p      V2   // sample position
+	foot   V2   // closest boundary point
+	inside bool // is the sample inside the region?
+	ridge  bool // is the sample on the medial axis?
+}
+
+// medialCrossing returns the point on the edge between two samples where
+// the medial axis crosses it.
+func medialCrossing(a, b *medialSample, minAngle float64) (V2, bool) {
+	if !a.inside || !b.inside || (a.ridge && b.ridge) {
+		return V2{}, false
+	}
+	// a sample on the axis is the crossing point
+	if a.ridge {
+		return a.p, true
+	}
+	if b.ridge {
+		return b.p, true
+	}
+	va := a.foot.Sub(a.p)
+	vb := b.foot.Sub(b.p)
+	if math.Atan2(Abs(va.Cross(vb)), va.Dot(vb)) < minAngle {
+		return V2{}, false
+	}
+	// the point on the edge that is equidistant from the two boundary points
+	e := b.p.Sub(a.p)
+	t := 0.5
+	if den := 2 * e.Dot(b.foot.Sub(a.foot)); den != 0 {
+		t = Clamp((a.p.Sub(b.foot).Length2()-a.p.Sub(a.foot).Length2())/den, 0, 1)
+	}
+	return a.p.Add(e.MulScalar(t)), true
+}
+
+// MedialAxis returns the medial axis of an SDF2 as line segments. The
+// distance field is sampled on a grid with the given number of cells along
+// the longest side of the bounding box. Axis branches between boundary points
+// that are less than minAngle (radians) apart as seen from the axis are
+// pruned.
+func MedialAxis(s SDF2, cells int, minAngle float64) []*Line {
+	if cells <= 0 {
+		panic("cells <= 0")
+	}
+	bb := s.BoundingBox()
+	step := bb.Size().MaxComponent() / float64(cells)
+	n := bb.Size().DivScalar(step).Ceil().ToV2i().AddScalar(1)
+	h := 1e-3 * step
+	// sample the distance field
+	grid := make([]medialSample, n[0]*n[1])
+	for i := 0; i < n[0]; i++ {
+		for j := 0; j < n[1]; j++ {
+			p := bb.Min.Add(V2{float64(i), float64(j)}.MulScalar(step))
+			x := &grid[i*n[1]+j]
+			x.p = p
+			d := s.Evaluate(p)
+			if d < 0 {
+				x.inside = true
+				var g V2
+				g, x.ridge = gradient2(s, p, h)
+				x.foot = p.Sub(g.Normalize().MulScalar(d))
+			}
+		}
+	}
+	at := func(i, j int) *medialSample { return &grid[i*n[1]+j] }
+	// join the crossing points in each cell
+	var lines []*Line
+	// an axis along a grid line is found by the cells on both sides
+	type key [2]V2
+	seen := make(map[key]bool)
+	add := func(p0, p1 V2) {
+		if p0.Equals(p1, tolerance) || seen[key{p0, p1}] || seen[key{p1, p0}] {
+			return
+		}
+		seen[key{p0, p1}] = true
+		lines = append(lines, &Line{p0, p1})
+	}
+	for i := 0; i < n[0]-1; i++ {
+		for j := 0; j < n[1]-1; j++ {
+			corner := [4]*medialSample{at(i, j), at(i+1, j), at(i+1, j+1), at(i, j+1)}
+			var x []V2
+			for k := range corner {
+				p, ok := medialCrossing(corner[k], corner[(k+1)%4], minAngle)
+				// an axis sample is the crossing point for both its edges
+				if ok && (len(x) == 0 || !p.Equals(x[len(x)-1], tolerance)) && (len(x) < 3 || !p.Equals(x[0], tolerance)) {
+					x = append(x, p)
+				}
+			}
+			switch len(x) {
+			case 0, 1:
+				// no axis, or the end of an axis branch
+			case 2:
+				add(x[0], x[1])
+			default:
+				// a junction of axis branches
+				c := V2{}
+				for _, p := range x {
+					c = c.Add(p)
+				}
+				c = c.DivScalar(float64(len(x)))
+				for _, p := range x {
+					add(p, c)
+				}
+			}
+		}
+	}
+	return lines
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,287 @@
+//-----------------------------------------------------------------------------
+/*
+
+Mesh SDF3
+
+Wrap a triangle mesh (e.g. a downloaded STL file) as an SDF3 so it can be
+combined with generated geometry.
+
+The distance is the distance to the closest triangle. A bounding volume
+hierarchy (BVH) of the triangles makes the closest triangle search fast.
+
+The sign is found with the generalized winding number of the mesh. This is
+robust for meshes with small holes or self intersections. The winding number
+contribution of far away BVH nodes is approximated as a dipole (see "Fast
+Winding Numbers for Soups and Clouds", Barill et al 2018).
+
+The mesh triangles should be counter-clockwise when viewed from outside.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+const meshLeafSize = 4 // maximum number of triangles in a BVH leaf node
+const meshBeta = 2.0   // use the dipole approximation beyond this multiple of the node radius
+
+type meshTriangle struct {
+	a, b, c V3
+}
+
+type bvhNode struct {
+	bb           Box3    // bounding box of node triangles
+	left, right  int     // child nodes
+	start, count int     // triangles of a leaf node (count > 0)
+	center       V3      // area weighted centroid
+	normal       V3      // sum of triangle area vectors
+	radius       float64 // maximum distance from the centroid to the bounding box
+}
+
+// MeshSDF3 is an SDF3 made from a triangle mesh.
+type MeshSDF3 struct {
+	tri  []meshTriangle
+	node []bvhNode
+	bb   Box3
+}
+
+// Mesh3D returns an SDF3 for a closed triangle mesh.
+func Mesh3D(mesh []*Triangle3) (SDF3, error) {
+	if len(mesh) == 0 {
+		return nil, errors.New("mesh has no triangles")
+	}
+	s := MeshSDF3{}
+	s.tri = make([]meshTriangle, len(mesh))
+	for i, t := range mesh {
+		s.tri[i] = meshTriangle{t.V[0], t.V[1], t.V[2]}
+	}
+	s.build(0, len(s.tri))
+	s.bb = s.node[0].bb
+	return &s, nil
+}
+
+// LoadMesh3D returns an SDF3 for a mesh loaded from an STL or OBJ file.
+func LoadMesh3D(path string) (SDF3, error) {
+	var mesh []*Triangle3
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".stl":
+		mesh, err = LoadSTL(path)
+	case ".obj":
+		mesh, err = LoadOBJ(path)
+	default:
+		return nil, fmt.Errorf("%s: unknown mesh file type", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Mesh3D(mesh)
+}
+
+//-----------------------------------------------------------------------------
+
+func (t *meshTriangle) centroid() V3 {
+	return t.a.Add(t.b).Add(t.c).DivScalar(3)
+}
+
+// areaVector returns the triangle normal scaled by the triangle area.
+func (t *meshTriangle) areaVector() V3 {
+	return t.b.Sub(t.a).Cross(t.c.Sub(t.a)).MulScalar(0.5)
+}
+
+// build builds the BVH node for a range of triangles.
+func (s *MeshSDF3) build(start, end int) int {
+	idx := len(s.node)
+	s.node = append(s.node, bvhNode{})
+	n := bvhNode{}
+	tri := s.tri[start:end]
+	// bounding box and dipole
+	n.bb = Box3{tri[0].a, tri[0].a}
+	cmin := tri[0].centroid()
+	cmax := cmin
+	area := 0.0
+	for i := range tri {
+		t := &tri[i]
+		n.bb = n.bb.Include(t.a).Include(t.b).Include(t.c)
+		c := t.centroid()
+		cmin = cmin.Min(c)
+		cmax = cmax.Max(c)
+		av := t.areaVector()
+		a := av.Length()
+		n.normal = n.normal.Add(av)
+		n.center = n.center.Add(c.MulScalar(a))
+		area += a
+	}
+	if area > 0 {
+		n.center = n.center.DivScalar(area)
+	} else {
+		n.center = n.bb.Center()
+	}
+	for _, v := range n.bb.Vertices() {
+		n.radius = Max(n.radius, v.Sub(n.center).Length())
+	}
+	if len(tri) <= meshLeafSize {
+		n.start = start
+		n.count = len(tri)
+	} else {
+		// split at the median centroid of the longest axis
+		d := cmax.Sub(cmin)
+		axis := func(v V3) float64 { return v.X }
+		if d.Y > d.X && d.Y > d.Z {
+			axis = func(v V3) float64 { return v.Y }
+		} else if d.Z > d.X {
+			axis = func(v V3) float64 { return v.Z }
+		}
+		sort.Slice(tri, func(i, j int) bool {
+			return axis(tri[i].centroid()) < axis(tri[j].centroid())
+		})
+		mid := start + len(tri)/2
+		n.left = s.build(start, mid)
+		n.right = s.build(mid, end)
+	}
+	s.node[idx] = n
+	return idx
+}
+
+//-----------------------------------------------------------------------------
+
+// boxDist2 returns the squared distance from a point to a box.
+func boxDist2(bb Box3, p V3) float64 {
+	return bb.Min.Sub(p).Max(p.Sub(bb.Max)).Max(V3{}).Length2()
+}
+
+// triangleDist2 returns the squared distance from a point to a triangle.
+// See: "Real-Time Collision Detection", Christer Ericson, 5.1.5
+func triangleDist2(p V3, t *meshTriangle) float64 {
+	a, b, c := t.a, t.b, t.c
+	ab := b.Sub(a)
+	ac := c.Sub(a)
+	ap := p.Sub(a)
+	d1 := ab.Dot(ap)
+	d2 := ac.Dot(ap)
+	if d1 <= 0 && d2 <= 0 {
+		return ap.Length2()
+	}
+	bp := p.Sub(b)
+	d3 := ab.Dot(bp)
+	d4 := ac.Dot(bp)
+	if d3 >= 0 && d4 <= d3 {
+		return bp.Length2()
+	}
+	vc := d1*d4 - d3*d2
+	if vc <= 0 && d1 >= 0 && d3 <= 0 {
+		v := d1 / (d1 - d3)
+		return p.Sub(a.Add(ab.MulScalar(v))).Length2()
+	}
+	cp := p.Sub(c)
+	d5 := ab.Dot(cp)
+	d6 := ac.Dot(cp)
+	if d6 >= 0 && d5 <= d6 {
+		return cp.Length2()
+	}
+	vb := d5*d2 - d1*d6
+	if vb <= 0 && d2 >= 0 && d6 <= 0 {
+		w := d2 / (d2 - d6)
+		return p.Sub(a.Add(ac.MulScalar(w))).Length2()
+	}
+	va := d3*d6 - d5*d4
+	if va <= 0 && d4-d3 >= 0 && d5-d6 >= 0 {
+		w := (d4 - d3) / ((d4 - d3) + (d5 - d6))
+		return p.Sub(b.Add(c.Sub(b).MulScalar(w))).Length2()
+	}
+	denom := 1 / (va + vb + vc)
+	v := vb * denom
+	w := vc * denom
+	return p.Sub(a.Add(ab.MulScalar(v)).Add(ac.MulScalar(w))).Length2()
+}
+
+// solidAngle returns the solid angle subtended by a triangle at a point.
+// See: "The Solid Angle of a Plane Triangle", Van Oosterom and Strackee, 1983
+func solidAngle(p V3, t *meshTriangle) float64 {
+	a := t.a.Sub(p)
+	b := t.b.Sub(p)
+	c := t.c.Sub(p)
+	la, lb, lc := a.Length(), b.Length(), c.Length()
+	num := a.Dot(b.Cross(c))
+	den := la*lb*lc + a.Dot(b)*lc + b.Dot(c)*la + c.Dot(a)*lb
+	return 2 * math.Atan2(num, den)
+}
+
+// distance2 returns the squared distance from a point to the closest mesh triangle.
+func (s *MeshSDF3) distance2(p V3) float64 {
+	best := math.MaxFloat64
+	stack := make([]int, 1, 64)
+	for len(stack) > 0 {
+		n := &s.node[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if boxDist2(n.bb, p) >= best {
+			continue
+		}
+		if n.count > 0 {
+			for i := n.start; i < n.start+n.count; i++ {
+				best = Min(best, triangleDist2(p, &s.tri[i]))
+			}
+			continue
+		}
+		// visit the closest child first
+		dl := boxDist2(s.node[n.left].bb, p)
+		dr := boxDist2(s.node[n.right].bb, p)
+		if dl < dr {
+			stack = append(stack, n.right, n.left)
+		} else {
+			stack = append(stack, n.left, n.right)
+		}
+	}
+	return best
+}
+
+// winding returns the generalized winding number of the mesh at a point.
+func (s *MeshSDF3) winding(p V3) float64 {
+	w := 0.0
+	stack := make([]int, 1, 64)
+	for len(stack) > 0 {
+		n := &s.node[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		d := n.center.Sub(p)
+		l := d.Length()
+		if l > meshBeta*n.radius {
+			// far away: dipole approximation
+			w += n.normal.Dot(d) / (l * l * l)
+			continue
+		}
+		if n.count > 0 {
+			for i := n.start; i < n.start+n.count; i++ {
+				w += solidAngle(p, &s.tri[i])
+			}
+			continue
+		}
+		stack = append(stack, n.left, n.right)
+	}
+	return w / (4 * Pi)
+}
+
+// Evaluate returns the minimum distance to a triangle mesh.
+func (s *MeshSDF3) Evaluate(p V3) float64 {
+	d := math.Sqrt(s.distance2(p))
+	if s.winding(p) > 0.5 {
+		return -d
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a triangle mesh.
+func (s *MeshSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
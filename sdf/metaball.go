@@ -0,0 +1,186 @@
+//-----------------------------------------------------------------------------
+/*
+
+Metaballs
+
+A metaball object is the iso-surface of a field that is the sum of the fields
+of a set of skeletal sources. Each source is a point, line segment or polyline
+with a radius of influence and a weight. The field of a source is the weight
+times a falloff kernel of the distance to the skeleton, falling from 1 on the
+skeleton to 0 at the radius of influence. The surface is where the field equals
+the threshold. Sources blend smoothly where their regions of influence overlap,
+and sources with negative weights carve into the blob.
+
+The field is not a distance, so the distance is found by scaling the field by
+its maximum gradient. Away from the sources the distance to the nearest region
+of influence is used.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+// MetaballKernel selects the falloff of a metaball source.
+type MetaballKernel int
+
+// Metaball falloff kernels.
+const (
+	MetaballWyvill   MetaballKernel = iota // (1 - r^2)^3
+	MetaballQuintic                        // inverted quintic smoothstep (flatter near the skeleton)
+	MetaballGaussian                       // truncated gaussian (softer blends)
+)
+
+// falloff returns the kernel function of r in [0,1] and its maximum slope.
+func (k MetaballKernel) falloff() (func(r float64) float64, float64) {
+	switch k {
+	case MetaballWyvill:
+		return func(r float64) float64 {
+			x := 1 - r*r
+			return x * x * x
+		}, 96 / (25 * math.Sqrt(5))
+	case MetaballQuintic:
+		return func(r float64) float64 {
+			return 1 - r*r*r*(r*(r*6-15)+10)
+		}, 30.0 / 16.0
+	case MetaballGaussian:
+		e := math.Exp(-4)
+		return func(r float64) float64 {
+			return (math.Exp(-4*r*r) - e) / (1 - e)
+		}, 2 * math.Sqrt2 * math.Exp(-0.5) / (1 - e)
+	}
+	panic("unknown metaball kernel")
+}
+
+//-----------------------------------------------------------------------------
+
+// MetaballSource is a skeletal source for a metaball field.
+type MetaballSource struct {
+	Skeleton []V3    // a point, or the vertices of a line/polyline
+	Radius   float64 // radius of influence
+	Weight   float64 // field strength, negative values subtract from the field
+}
+
+// MetaPoint returns a point source.
+func MetaPoint(center V3, radius, weight float64) MetaballSource {
+	return MetaballSource{[]V3{center}, radius, weight}
+}
+
+// MetaLine returns a line segment source.
+func MetaLine(a, b V3, radius, weight float64) MetaballSource {
+	return MetaballSource{[]V3{a, b}, radius, weight}
+}
+
+// MetaCurve returns a polyline source. Sample a curve (E.g. a Bezier) to
+// make a curved source.
+func MetaCurve(points []V3, radius, weight float64) MetaballSource {
+	return MetaballSource{points, radius, weight}
+}
+
+// distance returns the distance from a point to the source skeleton.
+func (s *MetaballSource) distance(p V3) float64 {
+	if len(s.Skeleton) == 1 {
+		return p.Sub(s.Skeleton[0]).Length()
+	}
+	d2 := math.Inf(1)
+	for i := 0; i < len(s.Skeleton)-1; i++ {
+		a := s.Skeleton[i]
+		ab := s.Skeleton[i+1].Sub(a)
+		ap := p.Sub(a)
+		t := 0.0
+		if l2 := ab.Length2(); l2 > 0 {
+			t = Clamp(ap.Dot(ab)/l2, 0, 1)
+		}
+		d2 = Min(d2, ap.Sub(ab.MulScalar(t)).Length2())
+	}
+	return math.Sqrt(d2)
+}
+
+//-----------------------------------------------------------------------------
+
+// MetaballSDF3 is a metaball object.
+type MetaballSDF3 struct {
+	sources   []MetaballSource
+	kernel    func(r float64) float64
+	threshold float64 // field value at the surface
+	k         float64 // maximum field gradient
+	bb        Box3
+}
+
+// Metaball3D returns an SDF3 for the iso-surface of the blended fields of a
+// set of sources. The threshold is the field value at the surface, so a single
+// point source with unit weight and a threshold of 0.5 has a surface where the
+// kernel falls to 0.5.
+func Metaball3D(sources []MetaballSource, kernel MetaballKernel, threshold float64) (SDF3, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("no metaball sources")
+	}
+	if threshold <= 0 {
+		return nil, errors.New("threshold <= 0")
+	}
+	s := MetaballSDF3{}
+	f, slope := kernel.falloff()
+	s.kernel = f
+	s.threshold = threshold
+	first := true
+	for _, src := range sources {
+		if len(src.Skeleton) == 0 {
+			return nil, errors.New("metaball source has no skeleton")
+		}
+		if src.Radius <= 0 {
+			return nil, errors.New("metaball source radius <= 0")
+		}
+		s.k += Abs(src.Weight) * slope / src.Radius
+		s.sources = append(s.sources, src)
+		if src.Weight <= 0 {
+			continue
+		}
+		// the surface is within the influence of the positive sources
+		d := V3{src.Radius, src.Radius, src.Radius}
+		for _, v := range src.Skeleton {
+			bb := Box3{v.Sub(d), v.Add(d)}
+			if first {
+				s.bb = bb
+				first = false
+			} else {
+				s.bb = s.bb.Extend(bb)
+			}
+		}
+	}
+	if first {
+		return nil, errors.New("no metaball sources with positive weight")
+	}
+	return &s, nil
+}
+
+// Evaluate returns the minimum distance to a metaball object.
+func (s *MetaballSDF3) Evaluate(p V3) float64 {
+	field := 0.0
+	outside := math.Inf(1)
+	for i := range s.sources {
+		src := &s.sources[i]
+		d := src.distance(p)
+		if src.Weight > 0 {
+			outside = Min(outside, d-src.Radius)
+		}
+		if d < src.Radius {
+			field += src.Weight * s.kernel(d/src.Radius)
+		}
+	}
+	// Both terms are lower bounds on the distance. The first applies outside
+	// the regions of influence, the second everywhere.
+	return Max(outside, (s.threshold-field)/s.k)
+}
+
+// BoundingBox returns the bounding box for a metaball object.
+func (s *MetaballSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
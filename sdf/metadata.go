@@ -0,0 +1,209 @@
+//-----------------------------------------------------------------------------
+/*
+
+Render Metadata
+
+Optionally write a JSON sidecar file (<path>.json) next to each rendered
+output file. It records the bounding box, estimated volume (or area), the
+mesh size, the render resolution and the design parameters used, so a
+generated part can be traced back to how it was made.
+
+The volume is estimated from the output mesh. The area of an SDF2 is
+estimated by sampling it on a grid at the render resolution.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// metadataConfig is the metadata sidecar file configuration.
+type metadataConfig struct {
+	enable bool        // write metadata sidecar files
+	parms  interface{} // design parameters recorded in the sidecar files
+}
+
+// metadata is the current metadata configuration. Renders may be running
+// when it is changed, so it is protected by a lock and each render uses a
+// copy taken when it starts.
+var metadata struct {
+	sync.RWMutex
+	cfg metadataConfig
+}
+
+// SetMetadata enables/disables the writing of a JSON metadata sidecar file
+// (<path>.json) for each rendered output file. parms is the design parameter
+// struct to record in the sidecar file (may be nil). It is safe to call while
+// rendering, each output file uses a consistent copy of the settings.
+func SetMetadata(enable bool, parms interface{}) {
+	metadata.Lock()
+	metadata.cfg = metadataConfig{enable, parms}
+	metadata.Unlock()
+}
+
+// getMetadata returns a copy of the current metadata configuration.
+func getMetadata() metadataConfig {
+	metadata.RLock()
+	defer metadata.RUnlock()
+	return metadata.cfg
+}
+
+// Metadata is the content of a metadata sidecar file.
+type Metadata struct {
+	File        string      `json:"file"`
+	Created     string      `json:"created,omitempty"`
+	BoundingBox interface{} `json:"bounding_box"`
+	Volume      float64     `json:"volume,omitempty"`
+	Area        float64     `json:"area,omitempty"`
+	Triangles   int         `json:"triangles,omitempty"`
+	Quads       int         `json:"quads,omitempty"`
+	Lines       int         `json:"lines,omitempty"`
+	Solids      int         `json:"solids,omitempty"`
+	MeshCells   int         `json:"mesh_cells"`
+	Resolution  float64     `json:"resolution"`
+	Parameters  interface{} `json:"parameters,omitempty"`
+}
+
+// newMetadata returns the metadata for an output file.
+func (c metadataConfig) newMetadata(path string, bb interface{}, meshCells int, resolution float64) *Metadata {
+	m := &Metadata{
+		File:        filepath.Base(path),
+		BoundingBox: bb,
+		MeshCells:   meshCells,
+		Resolution:  resolution,
+		Parameters:  c.parms,
+	}
+	if !Deterministic() {
+		m.Created = time.Now().UTC().Format(time.RFC3339)
+	}
+	return m
+}
+
+// save writes the metadata sidecar file for an output file.
+func (m *Metadata) save(path string) error {
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+".json", append(buf, '\n'), 0644)
+}
+
+//-----------------------------------------------------------------------------
+
+// meshStats accumulates the statistics of an output mesh.
+type meshStats struct {
+	triangles int
+	lines     int
+	volume    float64 // signed volume of the triangle mesh
+}
+
+func (m *meshStats) addTriangle(t *Triangle3) {
+	m.triangles++
+	// signed volume of the tetrahedron with the origin
+	m.volume += t.V[0].Dot(t.V[1].Cross(t.V[2])) / 6
+}
+
+func (m *meshStats) addLine(l *Line) {
+	m.lines++
+}
+
+func (m *meshStats) addMesh(mesh []*Triangle3) {
+	for _, t := range mesh {
+		m.addTriangle(t)
+	}
+}
+
+func (m *meshStats) addLines(mesh []*Line) {
+	for _, l := range mesh {
+		m.addLine(l)
+	}
+}
+
+// tapTriangles returns a channel that accumulates statistics for triangles
+// before passing them to the output channel. Closing the returned channel
+// closes the output channel.
+func (m *meshStats) tapTriangles(wg *sync.WaitGroup, output chan<- *Triangle3) chan<- *Triangle3 {
+	c := make(chan *Triangle3)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for t := range c {
+			m.addTriangle(t)
+			output <- t
+		}
+		close(output)
+	}()
+	return c
+}
+
+// tapLines returns a channel that accumulates statistics for line segments
+// before passing them to the output channel. Closing the returned channel
+// closes the output channel.
+func (m *meshStats) tapLines(wg *sync.WaitGroup, output chan<- *Line) chan<- *Line {
+	c := make(chan *Line)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for l := range c {
+			m.addLine(l)
+			output <- l
+		}
+		close(output)
+	}()
+	return c
+}
+
+//-----------------------------------------------------------------------------
+
+// saveMetadata3 writes the metadata sidecar file for a rendered SDF3.
+func (c metadataConfig) saveMetadata3(path string, s SDF3, meshCells int, resolution float64, stats *meshStats) error {
+	if !c.enable {
+		return nil
+	}
+	bb := s.BoundingBox()
+	m := c.newMetadata(path, &bb, meshCells, resolution)
+	m.Triangles = stats.triangles
+	m.Volume = Abs(stats.volume)
+	return m.save(path)
+}
+
+// saveMetadata2 writes the metadata sidecar file for a rendered SDF2.
+func (c metadataConfig) saveMetadata2(path string, s SDF2, meshCells int, resolution float64, stats *meshStats) error {
+	if !c.enable {
+		return nil
+	}
+	bb := s.BoundingBox()
+	m := c.newMetadata(path, &bb, meshCells, resolution)
+	m.Lines = stats.lines
+	m.Area = estimateArea(s, resolution)
+	return m.save(path)
+}
+
+// estimateArea estimates the area of an SDF2 by sampling it on a grid.
+func estimateArea(s SDF2, resolution float64) float64 {
+	bb := s.BoundingBox()
+	n := bb.Size().DivScalar(resolution).Ceil().ToV2i()
+	dx := bb.Size().X / float64(n[0])
+	dy := bb.Size().Y / float64(n[1])
+	inside := 0
+	for i := 0; i < n[0]; i++ {
+		for j := 0; j < n[1]; j++ {
+			p := bb.Min.Add(V2{(float64(i) + 0.5) * dx, (float64(j) + 0.5) * dy})
+			if s.Evaluate(p) <= 0 {
+				inside++
+			}
+		}
+	}
+	return float64(inside) * dx * dy
+}
+
+//-----------------------------------------------------------------------------
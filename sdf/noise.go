@@ -0,0 +1,378 @@
+//-----------------------------------------------------------------------------
+/*
+
+Noise and Surface Displacement
+
+Procedural 3d noise functions (Perlin, Simplex, Worley) and a displacement
+operator that uses them to give surfaces an organic texture.
+
+Displacing a surface by a function f changes the distance function by f. If f
+has a maximum slope (gradient magnitude) of k then the displaced distance can
+change k times faster than a true distance, so it is scaled by 1/(1 + k) to keep
+it conservative. Without this scaling marching cubes can miss surface cells.
+
+Noise functions are seeded, so a given seed always gives the same texture.
+
+SurfaceTexture3D repeats a 2d motif over the surface of an SDF3 using triplanar
+projection: the motif is projected along the x, y and z axes, and the
+projections are blended using the surface normal.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"math"
+	"math/rand"
+)
+
+//-----------------------------------------------------------------------------
+
+// Noise3 is a 3d noise function.
+type Noise3 interface {
+	Evaluate(p V3) float64 // noise value (in [-1, 1]) at p
+	Slope() float64        // maximum gradient magnitude of the noise
+}
+
+// noisePerm returns a seeded permutation table (repeated for wrap around).
+func noisePerm(seed int64) []int {
+	perm := rand.New(rand.NewSource(seed)).Perm(256)
+	return append(perm, perm...)
+}
+
+//-----------------------------------------------------------------------------
+// Perlin Noise
+// See: "Improving Noise", Ken Perlin, 2002
+
+type perlinNoise3 struct {
+	perm []int
+}
+
+// NewPerlin3 returns a seeded 3d Perlin noise function with a unit cell size.
+func NewPerlin3(seed int64) Noise3 {
+	return &perlinNoise3{noisePerm(seed)}
+}
+
+func perlinFade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func perlinGrad(hash int, x, y, z float64) float64 {
+	h := hash & 15
+	u := y
+	if h < 8 {
+		u = x
+	}
+	v := z
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// Evaluate returns the Perlin noise value at p.
+func (n *perlinNoise3) Evaluate(p V3) float64 {
+	fx, fy, fz := math.Floor(p.X), math.Floor(p.Y), math.Floor(p.Z)
+	xi, yi, zi := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z := p.X-fx, p.Y-fy, p.Z-fz
+	u, v, w := perlinFade(x), perlinFade(y), perlinFade(z)
+	pm := n.perm
+	a := pm[xi] + yi
+	aa := pm[a] + zi
+	ab := pm[a+1] + zi
+	b := pm[xi+1] + yi
+	ba := pm[b] + zi
+	bb := pm[b+1] + zi
+	return lerp(w,
+		lerp(v,
+			lerp(u, perlinGrad(pm[aa], x, y, z), perlinGrad(pm[ba], x-1, y, z)),
+			lerp(u, perlinGrad(pm[ab], x, y-1, z), perlinGrad(pm[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, perlinGrad(pm[aa+1], x, y, z-1), perlinGrad(pm[ba+1], x-1, y, z-1)),
+			lerp(u, perlinGrad(pm[ab+1], x, y-1, z-1), perlinGrad(pm[bb+1], x-1, y-1, z-1))))
+}
+
+// Slope returns the maximum gradient magnitude of Perlin noise.
+func (n *perlinNoise3) Slope() float64 {
+	return 3.5
+}
+
+//-----------------------------------------------------------------------------
+// Simplex Noise
+// See: "Simplex noise demystified", Stefan Gustavson, 2005
+
+type simplexNoise3 struct {
+	perm []int
+}
+
+// NewSimplex3 returns a seeded 3d simplex noise function with a unit cell size.
+func NewSimplex3(seed int64) Noise3 {
+	return &simplexNoise3{noisePerm(seed)}
+}
+
+var simplexGrad = [12]V3{
+	{1, 1, 0}, {-1, 1, 0}, {1, -1, 0}, {-1, -1, 0},
+	{1, 0, 1}, {-1, 0, 1}, {1, 0, -1}, {-1, 0, -1},
+	{0, 1, 1}, {0, -1, 1}, {0, 1, -1}, {0, -1, -1},
+}
+
+// Evaluate returns the simplex noise value at p.
+func (n *simplexNoise3) Evaluate(p V3) float64 {
+	const f3 = 1.0 / 3.0
+	const g3 = 1.0 / 6.0
+	// skew the input space to find the simplex cell
+	s := (p.X + p.Y + p.Z) * f3
+	i := math.Floor(p.X + s)
+	j := math.Floor(p.Y + s)
+	k := math.Floor(p.Z + s)
+	t := (i + j + k) * g3
+	p0 := V3{p.X - (i - t), p.Y - (j - t), p.Z - (k - t)}
+	// find which simplex of the cell we are in
+	var o1, o2 V3i
+	if p0.X >= p0.Y {
+		if p0.Y >= p0.Z {
+			o1, o2 = V3i{1, 0, 0}, V3i{1, 1, 0}
+		} else if p0.X >= p0.Z {
+			o1, o2 = V3i{1, 0, 0}, V3i{1, 0, 1}
+		} else {
+			o1, o2 = V3i{0, 0, 1}, V3i{1, 0, 1}
+		}
+	} else {
+		if p0.Y < p0.Z {
+			o1, o2 = V3i{0, 0, 1}, V3i{0, 1, 1}
+		} else if p0.X < p0.Z {
+			o1, o2 = V3i{0, 1, 0}, V3i{0, 1, 1}
+		} else {
+			o1, o2 = V3i{0, 1, 0}, V3i{1, 1, 0}
+		}
+	}
+	corner := [4]V3i{{0, 0, 0}, o1, o2, {1, 1, 1}}
+	ii, jj, kk := int(i)&255, int(j)&255, int(k)&255
+	pm := n.perm
+	sum := 0.0
+	for c, o := range corner {
+		// position relative to the corner (unskewed)
+		x := p0.Sub(o.ToV3()).AddScalar(float64(c) * g3)
+		t := 0.5 - x.Length2()
+		if t < 0 {
+			continue
+		}
+		g := pm[ii+o[0]+pm[jj+o[1]+pm[kk+o[2]]]] % 12
+		t *= t
+		sum += t * t * simplexGrad[g].Dot(x)
+	}
+	return 76 * sum
+}
+
+// Slope returns the maximum gradient magnitude of simplex noise.
+func (n *simplexNoise3) Slope() float64 {
+	return 8
+}
+
+//-----------------------------------------------------------------------------
+// Worley Noise
+// See: "A Cellular Texture Basis Function", Steven Worley, 1996
+
+type worleyNoise3 struct {
+	perm []int
+}
+
+// NewWorley3 returns a seeded 3d Worley (cellular) noise function with a unit
+// cell size. The value is based on the distance to the closest feature point,
+// with one randomly placed feature point per cell.
+func NewWorley3(seed int64) Noise3 {
+	return &worleyNoise3{noisePerm(seed)}
+}
+
+// feature returns the feature point of a cell.
+func (n *worleyNoise3) feature(i, j, k int) V3 {
+	pm := n.perm
+	h := pm[(i&255)+pm[(j&255)+pm[k&255]]]
+	return V3{
+		float64(pm[h]) / 256,
+		float64(pm[h+1]) / 256,
+		float64(pm[h+2]) / 256,
+	}.Add(V3{float64(i), float64(j), float64(k)})
+}
+
+// Evaluate returns the Worley noise value at p.
+func (n *worleyNoise3) Evaluate(p V3) float64 {
+	i, j, k := int(math.Floor(p.X)), int(math.Floor(p.Y)), int(math.Floor(p.Z))
+	d2 := math.MaxFloat64
+	for di := -1; di <= 1; di++ {
+		for dj := -1; dj <= 1; dj++ {
+			for dk := -1; dk <= 1; dk++ {
+				d2 = Min(d2, n.feature(i+di, j+dj, k+dk).Sub(p).Length2())
+			}
+		}
+	}
+	return 2*Min(math.Sqrt(d2), 1) - 1
+}
+
+// Slope returns the maximum gradient magnitude of Worley noise.
+func (n *worleyNoise3) Slope() float64 {
+	return 2
+}
+
+//-----------------------------------------------------------------------------
+// Fractal Noise
+
+type fractalNoise3 struct {
+	noise   Noise3
+	octaves int
+	scale   float64 // normalizing scale
+}
+
+// Fractal3 returns fractal (fBm) noise made by summing octaves of a noise
+// function, with the frequency doubled and the amplitude halved for each octave.
+func Fractal3(noise Noise3, octaves int) Noise3 {
+	if octaves < 1 {
+		panic("octaves < 1")
+	}
+	return &fractalNoise3{noise, octaves, 1 / (2 - math.Pow(0.5, float64(octaves-1)))}
+}
+
+// Evaluate returns the fractal noise value at p.
+func (n *fractalNoise3) Evaluate(p V3) float64 {
+	sum := 0.0
+	k := 1.0
+	for i := 0; i < n.octaves; i++ {
+		sum += n.noise.Evaluate(p.MulScalar(1/k)) * k
+		k *= 0.5
+	}
+	return sum * n.scale
+}
+
+// Slope returns the maximum gradient magnitude of fractal noise.
+func (n *fractalNoise3) Slope() float64 {
+	// each octave contributes the same maximum slope
+	return float64(n.octaves) * n.noise.Slope() * n.scale
+}
+
+//-----------------------------------------------------------------------------
+// Displacement
+
+// DisplaceSDF3 is an SDF3 with a displaced surface.
+type DisplaceSDF3 struct {
+	sdf   SDF3
+	fn    func(p V3) float64 // displacement function
+	slope float64            // maximum gradient magnitude of the displacement function
+	bb    Box3
+}
+
+// Displace3D displaces the surface of an SDF3 by fn(p). A positive
+// displacement moves the surface outwards. fn must be bounded by
+// +/- amplitude and have a maximum gradient magnitude of slope.
+func Displace3D(sdf SDF3, fn func(p V3) float64, amplitude, slope float64) SDF3 {
+	s := DisplaceSDF3{}
+	s.sdf = sdf
+	s.fn = fn
+	s.slope = Abs(slope)
+	// work out the bounding box
+	bb := sdf.BoundingBox()
+	s.bb = NewBox3(bb.Center(), bb.Size().AddScalar(2*Abs(amplitude)))
+	return &s
+}
+
+// NoiseDisplace3D displaces the surface of an SDF3 with noise. The noise
+// features have the given size, and the surface moves by up to +/- amplitude.
+func NoiseDisplace3D(sdf SDF3, noise Noise3, size, amplitude float64) SDF3 {
+	if size <= 0 {
+		panic("size <= 0")
+	}
+	fn := func(p V3) float64 {
+		return amplitude * noise.Evaluate(p.DivScalar(size))
+	}
+	return Displace3D(sdf, fn, amplitude, amplitude*noise.Slope()/size)
+}
+
+// Evaluate returns the minimum distance to a displaced SDF3.
+func (s *DisplaceSDF3) Evaluate(p V3) float64 {
+	return (s.sdf.Evaluate(p) - s.fn(p)) / (1 + s.slope)
+}
+
+// BoundingBox returns the bounding box of a displaced SDF3.
+func (s *DisplaceSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Surface Texture
+
+// SurfaceTextureSDF3 is an SDF3 with a textured surface.
+type SurfaceTextureSDF3 struct {
+	sdf   SDF3
+	motif SDF2    // texture motif
+	size  V2      // texture tile size
+	depth float64 // texture depth (< 0 for a raised texture)
+	band  float64 // distance from the surface to texture
+	eps   float64 // normal estimation step
+	bb    Box3
+}
+
+// SurfaceTexture3D textures the surface of an SDF3 with a repeating 2d motif
+// (e.g. a small circle for stippling, a diamond for knurling). The motif is
+// centered on the origin of a tile of the given size. With depth > 0 the motif
+// is cut into the surface, with depth < 0 it is raised from the surface. The
+// sides of the texture are at 45 degrees, with a flat top/bottom at the depth.
+func SurfaceTexture3D(sdf SDF3, motif SDF2, size V2, depth float64) SDF3 {
+	if size.X <= 0 || size.Y <= 0 {
+		panic("size <= 0")
+	}
+	s := SurfaceTextureSDF3{}
+	s.sdf = sdf
+	s.motif = motif
+	s.size = size
+	s.depth = depth
+	s.band = 3 * Abs(depth)
+	s.eps = 1e-3 * size.MinComponent()
+	// work out the bounding box
+	bb := sdf.BoundingBox()
+	s.bb = NewBox3(bb.Center(), bb.Size().AddScalar(2*Max(-depth, 0)))
+	return &s
+}
+
+// texture returns the texture height for a tile position.
+func (s *SurfaceTextureSDF3) texture(p V2) float64 {
+	p = p.Sub(s.size.Mul(V2{math.Round(p.X / s.size.X), math.Round(p.Y / s.size.Y)}))
+	return Clamp(-s.motif.Evaluate(p), 0, Abs(s.depth))
+}
+
+// Evaluate returns the minimum distance to a textured SDF3.
+func (s *SurfaceTextureSDF3) Evaluate(p V3) float64 {
+	// The texture slope is up to 1, and the fade to the untextured
+	// surface adds up to 0.5, so scale the distance to keep it a bound.
+	const k = 1 / 2.5
+	d := s.sdf.Evaluate(p)
+	if Abs(d) >= s.band {
+		return d * k
+	}
+	// triplanar projection weights
+	n := Normal3(s.sdf, p, s.eps).Abs()
+	w := n.Mul(n).Mul(n).Mul(n)
+	w = w.DivScalar(w.X + w.Y + w.Z)
+	h := w.X*s.texture(V2{p.Y, p.Z}) + w.Y*s.texture(V2{p.X, p.Z}) + w.Z*s.texture(V2{p.X, p.Y})
+	// fade out the texture away from the surface
+	f := Clamp((s.band-Abs(d))/(2*Abs(s.depth)), 0, 1)
+	return (d + Sign(s.depth)*h*f) * k
+}
+
+// BoundingBox returns the bounding box of a textured SDF3.
+func (s *SurfaceTextureSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
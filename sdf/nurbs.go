@@ -0,0 +1,232 @@
+//-----------------------------------------------------------------------------
+/*
+
+Create curves using B-splines and NURBS.
+
+A B-spline is a piecewise polynomial curve defined by a set of control points
+and a knot vector. Unlike a Bezier curve, moving a control point only changes
+the curve locally, and the curve is smooth (C2 for a cubic) at the joins
+between the pieces. A NURBS (Non-Uniform Rational B-Spline) adds a weight to
+each control point, so conic sections (e.g. circular arcs) can be represented
+exactly.
+
+Curves are tessellated adaptively. A curve interval is subdivided until the
+length of the polyline differs from the arc length of the curve by less than
+the tolerance.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+// NURBS is a non-uniform rational B-spline curve.
+type NURBS struct {
+	degree    int       // polynomial degree
+	ctrl      []V2      // control points
+	weight    []float64 // control point weights
+	knots     []float64 // knot vector
+	tolerance float64   // tessellation tolerance (arc length error)
+}
+
+// NewNURBS returns a NURBS curve. There must be len(ctrl) + degree + 1 non
+// decreasing knots. The weights may be nil for a non-rational B-spline.
+func NewNURBS(degree int, ctrl []V2, weight, knots []float64) (*NURBS, error) {
+	if degree < 1 {
+		return nil, errors.New("degree < 1")
+	}
+	if len(ctrl) <= degree {
+		return nil, errors.New("not enough control points for the degree")
+	}
+	if len(knots) != len(ctrl)+degree+1 {
+		return nil, errors.New("bad number of knots")
+	}
+	for i := 1; i < len(knots); i++ {
+		if knots[i] < knots[i-1] {
+			return nil, errors.New("knots must be non-decreasing")
+		}
+	}
+	if knots[len(ctrl)] <= knots[degree] {
+		return nil, errors.New("empty knot domain")
+	}
+	if weight == nil {
+		weight = make([]float64, len(ctrl))
+		for i := range weight {
+			weight[i] = 1
+		}
+	}
+	if len(weight) != len(ctrl) {
+		return nil, errors.New("bad number of weights")
+	}
+	for _, w := range weight {
+		if w <= 0 {
+			return nil, errors.New("weight <= 0")
+		}
+	}
+	n := NURBS{
+		degree: degree,
+		ctrl:   ctrl,
+		weight: weight,
+		knots:  knots,
+	}
+	// default tolerance relative to the size of the control polygon
+	bb := Box2{ctrl[0], ctrl[0]}
+	for _, v := range ctrl {
+		bb = bb.Include(v)
+	}
+	n.tolerance = 1e-4 * bb.Size().Length()
+	return &n, nil
+}
+
+// NewBSpline returns a uniform B-spline curve that starts at the first control
+// point and ends at the last control point (clamped knots). Use degree 3 for a
+// cubic B-spline.
+func NewBSpline(degree int, ctrl []V2) (*NURBS, error) {
+	if degree < 1 || len(ctrl) <= degree {
+		return nil, errors.New("not enough control points for the degree")
+	}
+	n := len(ctrl)
+	knots := make([]float64, n+degree+1)
+	for i := range knots {
+		knots[i] = Clamp(float64(i-degree), 0, float64(n-degree))
+	}
+	return NewNURBS(degree, ctrl, nil, knots)
+}
+
+// NewClosedBSpline returns a closed (periodic) uniform B-spline curve. The
+// curve is smooth everywhere, including where it closes. The control points
+// should not repeat the first point.
+func NewClosedBSpline(degree int, ctrl []V2) (*NURBS, error) {
+	if degree < 1 || len(ctrl) <= degree {
+		return nil, errors.New("not enough control points for the degree")
+	}
+	// wrap the first control points around to the end
+	c := append(append([]V2{}, ctrl...), ctrl[:degree]...)
+	knots := make([]float64, len(c)+degree+1)
+	for i := range knots {
+		knots[i] = float64(i)
+	}
+	return NewNURBS(degree, c, nil, knots)
+}
+
+// NURBSCircle returns a NURBS representation of a circle. The circle is
+// exact, not an approximation.
+func NURBSCircle(center V2, radius float64) *NURBS {
+	w := math.Sqrt2 / 2
+	ctrl := []V2{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}, {1, 0}}
+	for i := range ctrl {
+		ctrl[i] = center.Add(ctrl[i].MulScalar(radius))
+	}
+	weight := []float64{1, w, 1, w, 1, w, 1, w, 1}
+	knots := []float64{0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+	n, err := NewNURBS(2, ctrl, weight, knots)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// SetTolerance sets the tessellation tolerance. Curve intervals are
+// subdivided until the polyline length is within tolerance of the arc length.
+func (n *NURBS) SetTolerance(tolerance float64) *NURBS {
+	if tolerance <= 0 {
+		panic("tolerance <= 0")
+	}
+	n.tolerance = tolerance
+	return n
+}
+
+// Domain returns the parameter range of the curve.
+func (n *NURBS) Domain() (float64, float64) {
+	return n.knots[n.degree], n.knots[len(n.ctrl)]
+}
+
+// Point returns the point on the curve for a parameter value.
+func (n *NURBS) Point(t float64) V2 {
+	p := n.degree
+	t0, t1 := n.Domain()
+	t = Clamp(t, t0, t1)
+	// find the knot span
+	k := p
+	for k < len(n.ctrl)-1 && t >= n.knots[k+1] {
+		k++
+	}
+	// de Boor's algorithm in homogeneous coordinates
+	d := make([]V3, p+1)
+	for j := range d {
+		i := j + k - p
+		w := n.weight[i]
+		d[j] = V3{n.ctrl[i].X * w, n.ctrl[i].Y * w, w}
+	}
+	for r := 1; r <= p; r++ {
+		for j := p; j >= r; j-- {
+			i := j + k - p
+			a := 0.0
+			if den := n.knots[i+p+1-r] - n.knots[i]; den != 0 {
+				a = (t - n.knots[i]) / den
+			}
+			d[j] = d[j-1].MulScalar(1 - a).Add(d[j].MulScalar(a))
+		}
+	}
+	return V2{d[p].X / d[p].Z, d[p].Y / d[p].Z}
+}
+
+// Length returns the arc length of the curve.
+func (n *NURBS) Length() float64 {
+	v := n.Vertices()
+	l := 0.0
+	for i := 1; i < len(v); i++ {
+		l += v[i].Sub(v[i-1]).Length()
+	}
+	return l
+}
+
+// sample adds points for a curve interval, excluding the start point.
+func (n *NURBS) sample(v []V2, t0, t1 float64, p0, p1 V2, depth int) []V2 {
+	tm := 0.5 * (t0 + t1)
+	pm := n.Point(tm)
+	// The polyline length converges on the arc length, so the difference
+	// between the chord and the two half chords estimates the error.
+	chord := p1.Sub(p0).Length()
+	half := pm.Sub(p0).Length() + p1.Sub(pm).Length()
+	if half-chord <= n.tolerance || depth >= 16 {
+		return append(v, p1)
+	}
+	v = n.sample(v, t0, tm, p0, pm, depth+1)
+	return n.sample(v, tm, t1, pm, p1, depth+1)
+}
+
+// Vertices returns the tessellated vertices of the curve.
+func (n *NURBS) Vertices() []V2 {
+	t0, t1 := n.Domain()
+	v := []V2{n.Point(t0)}
+	// start with a few samples per knot span, so features smaller than a
+	// span aren't missed
+	m := 4 * (len(n.ctrl) - n.degree)
+	for i := 0; i < m; i++ {
+		ta := t0 + (t1-t0)*float64(i)/float64(m)
+		tb := t0 + (t1-t0)*float64(i+1)/float64(m)
+		v = n.sample(v, ta, tb, v[len(v)-1], n.Point(tb), 0)
+	}
+	return v
+}
+
+// Polygon returns a polygon approximating the curve.
+func (n *NURBS) Polygon() *Polygon {
+	p := NewPolygon()
+	v := n.Vertices()
+	if v[0].Equals(v[len(v)-1], tolerance) {
+		// closed curve
+		v = v[:len(v)-1]
+	}
+	p.AddV2Set(v)
+	return p
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,151 @@
+//-----------------------------------------------------------------------------
+/*
+
+OBJ Load/Save
+
+Wavefront OBJ polygon meshes. Only vertex positions and faces are supported.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// LoadOBJ reads a triangle mesh from an OBJ file.
+func LoadOBJ(path string) ([]*Triangle3, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodeOBJ(file)
+}
+
+// DecodeOBJ reads a triangle mesh in OBJ format from an io.Reader.
+// Polygon faces are split into triangle fans.
+func DecodeOBJ(r io.Reader) ([]*Triangle3, error) {
+	var vertex []V3
+	var mesh []*Triangle3
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		f := strings.Fields(scanner.Text())
+		if len(f) == 0 {
+			continue
+		}
+		switch f[0] {
+		case "v":
+			if len(f) < 4 {
+				return nil, fmt.Errorf("line %d: bad vertex", n)
+			}
+			var p [3]float64
+			for i := range p {
+				x, err := strconv.ParseFloat(f[i+1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s", n, err)
+				}
+				p[i] = x
+			}
+			vertex = append(vertex, V3{p[0], p[1], p[2]})
+		case "f":
+			if len(f) < 4 {
+				return nil, fmt.Errorf("line %d: bad face", n)
+			}
+			idx := make([]int, len(f)-1)
+			for i, s := range f[1:] {
+				// vertex/texture/normal: only the vertex index is used
+				k, err := strconv.Atoi(strings.Split(s, "/")[0])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s", n, err)
+				}
+				if k < 0 {
+					// relative index
+					k += len(vertex) + 1
+				}
+				if k < 1 || k > len(vertex) {
+					return nil, fmt.Errorf("line %d: vertex index out of range", n)
+				}
+				idx[i] = k - 1
+			}
+			for i := 1; i < len(idx)-1; i++ {
+				mesh = append(mesh, NewTriangle3(vertex[idx[0]], vertex[idx[i]], vertex[idx[i+1]]))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mesh, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// EncodeOBJ writes a quad mesh in Wavefront OBJ format to an io.Writer.
+func (m *QuadMesh) EncodeOBJ(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	quantize := Deterministic()
+	for _, v := range m.Vertex {
+		if quantize {
+			v = v.Quantize()
+		}
+		if _, err := fmt.Fprintf(buf, "v %g %g %g\n", v.X, v.Y, v.Z); err != nil {
+			return err
+		}
+	}
+	for _, f := range m.Face {
+		fmt.Fprintf(buf, "f")
+		for _, i := range f {
+			fmt.Fprintf(buf, " %d", i+1)
+		}
+		if _, err := fmt.Fprintf(buf, "\n"); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
+
+// SaveOBJ writes a quad mesh to an OBJ file.
+func (m *QuadMesh) SaveOBJ(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return m.EncodeOBJ(f)
+}
+
+// EncodeTrianglesOBJ writes a 3d triangle set in Wavefront OBJ format to an
+// io.Writer. The vertices are shared by the triangles.
+func EncodeTrianglesOBJ(w io.Writer, vs []V3, ts TriangleISet) error {
+	if err := ts.check(len(vs)); err != nil {
+		return err
+	}
+	m := QuadMesh{Vertex: vs, Face: make([][]int, len(ts))}
+	for i := range ts {
+		m.Face[i] = ts[i][:]
+	}
+	return m.EncodeOBJ(w)
+}
+
+// SaveTrianglesOBJ writes a 3d triangle set to an OBJ file.
+func SaveTrianglesOBJ(path string, vs []V3, ts TriangleISet) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return EncodeTrianglesOBJ(f, vs, ts)
+}
+
+//-----------------------------------------------------------------------------
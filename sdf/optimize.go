@@ -0,0 +1,373 @@
+//-----------------------------------------------------------------------------
+/*
+
+CSG Tree Optimization
+
+Generated models often build deep or wide CSG trees, e.g. a union of many
+translated copies of a part. Every evaluation walks the whole tree, so the
+tree shape matters.
+
+Optimize3D rewrites a tree to evaluate faster:
+
+* nil children are pruned.
+* Nested (non-blended) unions are flattened into a single union.
+* Consecutive transforms and uniform scales are merged into one matrix.
+* Differences with a cutter that can't touch the object are dropped.
+* Intersections of objects with disjoint bounding boxes are empty (nil).
+* Unions with many children use a bounding volume hierarchy of the child
+  bounding boxes, so children that can't be the closest are not evaluated.
+
+The input tree is not modified, shared sub-trees stay shared in the input.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import "math"
+
+//-----------------------------------------------------------------------------
+
+const optimizeUnionBVH = 4 // minimum number of union children to use a BVH
+
+// Optimize3D returns an equivalent SDF3 that is faster to evaluate.
+func Optimize3D(s SDF3) SDF3 {
+	switch t := s.(type) {
+	case nil:
+		return nil
+	case *UnionSDF3:
+		children := make([]SDF3, 0, len(t.sdf))
+		for _, x := range t.sdf {
+			if x = Optimize3D(x); x != nil {
+				children = append(children, x)
+			}
+		}
+		if !sameFunc(t.min, Min) {
+			// blended: keep the union, but with optimized children
+			if len(children) == 0 {
+				return nil
+			}
+			u := *t
+			u.sdf = children
+			return &u
+		}
+		// flatten nested unions
+		var flat []SDF3
+		for _, x := range children {
+			switch c := x.(type) {
+			case *UnionSDF3:
+				if sameFunc(c.min, Min) {
+					flat = append(flat, c.sdf...)
+					continue
+				}
+			case *UnionBVHSDF3:
+				flat = append(flat, c.sdf...)
+				continue
+			}
+			flat = append(flat, x)
+		}
+		if len(flat) >= optimizeUnionBVH {
+			return UnionBVH3D(flat...)
+		}
+		return Union3D(flat...)
+	case *UnionBVHSDF3:
+		return Optimize3D(Union3D(t.sdf...))
+	case *TransformSDF3:
+		x := Optimize3D(t.sdf)
+		if x == nil {
+			return nil
+		}
+		m := t.matrix
+		switch c := x.(type) {
+		case *TransformSDF3:
+			x, m = c.sdf, m.Mul(c.matrix)
+		case *ScaleUniformSDF3:
+			x, m = c.sdf, m.Mul(Scale3d(V3{c.k, c.k, c.k}))
+		}
+		if m.Equals(Identity3d(), tolerance) {
+			return x
+		}
+		return Transform3D(x, m)
+	case *ScaleUniformSDF3:
+		x := Optimize3D(t.sdf)
+		if x == nil {
+			return nil
+		}
+		if c, ok := x.(*TransformSDF3); ok {
+			return Transform3D(c.sdf, Scale3d(V3{t.k, t.k, t.k}).Mul(c.matrix))
+		}
+		return ScaleUniform3D(x, t.k)
+	case *DifferenceSDF3:
+		s0 := Optimize3D(t.s0)
+		s1 := Optimize3D(t.s1)
+		if s0 == nil {
+			return nil
+		}
+		if s1 == nil || (sameFunc(t.max, Max) && !boxOverlap(s0.BoundingBox(), s1.BoundingBox())) {
+			// the cutter doesn't touch the object
+			return s0
+		}
+		d := *t
+		d.s0, d.s1 = s0, s1
+		return &d
+	case *IntersectionSDF3:
+		s0 := Optimize3D(t.s0)
+		s1 := Optimize3D(t.s1)
+		if s0 == nil || s1 == nil {
+			return nil
+		}
+		if sameFunc(t.max, Max) && !boxOverlap(s0.BoundingBox(), s1.BoundingBox()) {
+			// empty
+			return nil
+		}
+		i := *t
+		i.s0, i.s1 = s0, s1
+		return &i
+	}
+	return s
+}
+
+// boxOverlap returns true if two boxes overlap.
+func boxOverlap(a, b Box3) bool {
+	return a.Min.X <= b.Max.X && b.Min.X <= a.Max.X &&
+		a.Min.Y <= b.Max.Y && b.Min.Y <= a.Max.Y &&
+		a.Min.Z <= b.Max.Z && b.Min.Z <= a.Max.Z
+}
+
+//-----------------------------------------------------------------------------
+// Union with a Bounding Volume Hierarchy
+
+// UnionBVHSDF3 is a union of SDF3s with a bounding volume hierarchy.
+type UnionBVHSDF3 struct {
+	sdf  []SDF3
+	node []bvhNode
+	bb   Box3
+}
+
+// UnionBVH3D returns the union of multiple SDF3 objects. A bounding volume
+// hierarchy of the object bounding boxes is used to skip the objects that
+// can't be closest to the evaluation point, so this is faster than Union3D for
+// many objects. The distance is the same as Union3D near the surface, away
+// from the surface it may be larger (but it is still a lower bound).
+func UnionBVH3D(sdf ...SDF3) SDF3 {
+	s := UnionBVHSDF3{}
+	s.sdf = make([]SDF3, 0, len(sdf))
+	for _, x := range sdf {
+		if x != nil {
+			s.sdf = append(s.sdf, x)
+		}
+	}
+	if len(s.sdf) == 0 {
+		return nil
+	}
+	if len(s.sdf) == 1 {
+		return s.sdf[0]
+	}
+	s.build(0, len(s.sdf))
+	s.bb = s.node[0].bb
+	return &s
+}
+
+// build builds a BVH node for the objects [start, end) and returns its index.
+func (s *UnionBVHSDF3) build(start, end int) int {
+	idx := len(s.node)
+	s.node = append(s.node, bvhNode{})
+	bb := s.sdf[start].BoundingBox()
+	for _, x := range s.sdf[start+1 : end] {
+		bb = bb.Extend(x.BoundingBox())
+	}
+	s.node[idx].bb = bb
+	if end-start == 1 {
+		s.node[idx].start = start
+		s.node[idx].count = 1
+		return idx
+	}
+	// split on the longest axis at the median bounding box center
+	size := bb.Size()
+	axis := 0
+	if size.Y > size.X && size.Y >= size.Z {
+		axis = 1
+	} else if size.Z > size.X && size.Z > size.Y {
+		axis = 2
+	}
+	key := func(x SDF3) float64 {
+		c := x.BoundingBox().Center()
+		return [3]float64{c.X, c.Y, c.Z}[axis]
+	}
+	objs := s.sdf[start:end]
+	// insertion sort, the number of objects is usually modest
+	for i := 1; i < len(objs); i++ {
+		for j := i; j > 0 && key(objs[j]) < key(objs[j-1]); j-- {
+			objs[j], objs[j-1] = objs[j-1], objs[j]
+		}
+	}
+	mid := (start + end) / 2
+	left := s.build(start, mid)
+	right := s.build(mid, end)
+	s.node[idx].left = left
+	s.node[idx].right = right
+	return idx
+}
+
+// Evaluate returns the minimum distance to a BVH union.
+func (s *UnionBVHSDF3) Evaluate(p V3) float64 {
+	d := math.MaxFloat64
+	stack := make([]int, 1, 64)
+	for len(stack) > 0 {
+		n := &s.node[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		// An object is no closer than its bounding box. Inside an object
+		// only objects with the point in their bounding box can be closer.
+		d2 := boxDist2(n.bb, p)
+		if d2 > 0 && (d <= 0 || d2 >= d*d) {
+			continue
+		}
+		if n.count > 0 {
+			// Not all SDFs are exact. Using the box distance as a lower bound
+			// keeps the result continuous where pruned objects would be closer.
+			di := s.sdf[n.start].Evaluate(p)
+			if d2 > 0 {
+				di = Max(di, math.Sqrt(d2))
+			}
+			d = Min(d, di)
+			continue
+		}
+		// visit the closest child first
+		dl := boxDist2(s.node[n.left].bb, p)
+		dr := boxDist2(s.node[n.right].bb, p)
+		if dl < dr {
+			stack = append(stack, n.right, n.left)
+		} else {
+			stack = append(stack, n.left, n.right)
+		}
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a BVH union.
+func (s *UnionBVHSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+
+// bvhNode2 is a node of a 2D bounding volume hierarchy.
+type bvhNode2 struct {
+	bb           Box2 // bounding box of node objects
+	left, right  int  // child nodes
+	start, count int  // objects of a leaf node (count > 0)
+}
+
+// box2Dist2 returns the squared distance from a point to a 2D bounding box.
+func box2Dist2(bb Box2, p V2) float64 {
+	return bb.Min.Sub(p).Max(p.Sub(bb.Max)).Max(V2{}).Length2()
+}
+
+// UnionBVHSDF2 is a union of SDF2s with a bounding volume hierarchy.
+type UnionBVHSDF2 struct {
+	sdf  []SDF2
+	node []bvhNode2
+	bb   Box2
+}
+
+// UnionBVH2D returns the union of multiple SDF2 objects. As for UnionBVH3D,
+// a bounding volume hierarchy is used to skip the objects that can't be
+// closest to the evaluation point. E.g. the glyphs of a long line of text.
+func UnionBVH2D(sdf ...SDF2) SDF2 {
+	s := UnionBVHSDF2{}
+	s.sdf = make([]SDF2, 0, len(sdf))
+	for _, x := range sdf {
+		if x != nil {
+			s.sdf = append(s.sdf, x)
+		}
+	}
+	if len(s.sdf) == 0 {
+		return nil
+	}
+	if len(s.sdf) == 1 {
+		return s.sdf[0]
+	}
+	s.build(0, len(s.sdf))
+	s.bb = s.node[0].bb
+	return &s
+}
+
+// build builds a BVH node for the objects [start, end) and returns its index.
+func (s *UnionBVHSDF2) build(start, end int) int {
+	idx := len(s.node)
+	s.node = append(s.node, bvhNode2{})
+	bb := s.sdf[start].BoundingBox()
+	for _, x := range s.sdf[start+1 : end] {
+		bb = bb.Extend(x.BoundingBox())
+	}
+	s.node[idx].bb = bb
+	if end-start == 1 {
+		s.node[idx].start = start
+		s.node[idx].count = 1
+		return idx
+	}
+	// split on the longest axis at the median bounding box center
+	size := bb.Size()
+	key := func(x SDF2) float64 {
+		c := x.BoundingBox().Center()
+		if size.Y > size.X {
+			return c.Y
+		}
+		return c.X
+	}
+	objs := s.sdf[start:end]
+	// insertion sort, the number of objects is usually modest
+	for i := 1; i < len(objs); i++ {
+		for j := i; j > 0 && key(objs[j]) < key(objs[j-1]); j-- {
+			objs[j], objs[j-1] = objs[j-1], objs[j]
+		}
+	}
+	mid := (start + end) / 2
+	left := s.build(start, mid)
+	right := s.build(mid, end)
+	s.node[idx].left = left
+	s.node[idx].right = right
+	return idx
+}
+
+// Evaluate returns the minimum distance to a BVH union.
+func (s *UnionBVHSDF2) Evaluate(p V2) float64 {
+	d := math.MaxFloat64
+	stack := make([]int, 1, 64)
+	for len(stack) > 0 {
+		n := &s.node[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		// An object is no closer than its bounding box. Inside an object
+		// only objects with the point in their bounding box can be closer.
+		d2 := box2Dist2(n.bb, p)
+		if d2 > 0 && (d <= 0 || d2 >= d*d) {
+			continue
+		}
+		if n.count > 0 {
+			// Not all SDFs are exact. Using the box distance as a lower bound
+			// keeps the result continuous where pruned objects would be closer.
+			di := s.sdf[n.start].Evaluate(p)
+			if d2 > 0 {
+				di = Max(di, math.Sqrt(d2))
+			}
+			d = Min(d, di)
+			continue
+		}
+		// visit the closest child first
+		dl := box2Dist2(s.node[n.left].bb, p)
+		dr := box2Dist2(s.node[n.right].bb, p)
+		if dl < dr {
+			stack = append(stack, n.right, n.left)
+		} else {
+			stack = append(stack, n.left, n.right)
+		}
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a BVH union.
+func (s *UnionBVHSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,416 @@
+//-----------------------------------------------------------------------------
+/*
+
+Surface Patches
+
+Tensor product Bezier and B-spline surface patches. A patch is a surface, not a
+solid, so it is thickened to make an SDF3 that can be combined with other
+objects.
+
+The distance to the surface is found by numerically projecting the point onto
+the patch (Newton's method in the patch parameters). The patch is divided into
+a grid of cells with a bounding volume hierarchy of cell bounding boxes, so only
+the cells that may contain the closest point are searched.
+
+The cell bounding boxes are found by sampling the surface and are enlarged to
+allow for the surface curvature between samples.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+const patchCells = 16      // number of cells per side of the patch grid
+const patchSamples = 4     // number of samples per side of a cell
+const patchIterations = 12 // maximum number of Newton iterations
+
+// patchNode is a node in the bounding volume hierarchy of patch cells.
+type patchNode struct {
+	bb          Box3    // bounding box of the surface within the node
+	left, right int     // child nodes (leaf nodes have left == 0)
+	u, v        float64 // parameters at the cell center (leaf)
+}
+
+// PatchSDF3 is a thickened tensor product B-spline surface.
+type PatchSDF3 struct {
+	ctrl   [][]V3    // control points, ctrl[i][j] for u index i, v index j
+	pu, pv int       // degree in u and v
+	ku, kv []float64 // knot vectors in u and v
+	half   float64   // half the thickness
+	node   []patchNode
+	bb     Box3
+}
+
+// BezierPatch3D returns an SDF3 for a thickened tensor product Bezier
+// surface. ctrl[i][j] is the control point for index i in u and index j in v.
+// The degree in each direction is one less than the number of control points.
+func BezierPatch3D(ctrl [][]V3, thickness float64) (SDF3, error) {
+	if len(ctrl) < 2 || len(ctrl[0]) < 2 {
+		return nil, errors.New("need at least 2x2 control points")
+	}
+	return BSplinePatch3D(ctrl, len(ctrl)-1, len(ctrl[0])-1, thickness)
+}
+
+// BSplinePatch3D returns an SDF3 for a thickened tensor product B-spline
+// surface with clamped uniform knot vectors. The surface passes through the
+// corner control points. ctrl[i][j] is the control point for index i in u and
+// index j in v.
+func BSplinePatch3D(ctrl [][]V3, degreeU, degreeV int, thickness float64) (SDF3, error) {
+	if degreeU < 1 || degreeV < 1 {
+		return nil, errors.New("degree < 1")
+	}
+	if len(ctrl) <= degreeU {
+		return nil, errors.New("too few control points in u for the degree")
+	}
+	for i := range ctrl {
+		if len(ctrl[i]) != len(ctrl[0]) {
+			return nil, errors.New("control point rows have different lengths")
+		}
+	}
+	if len(ctrl[0]) <= degreeV {
+		return nil, errors.New("too few control points in v for the degree")
+	}
+	if thickness <= 0 {
+		return nil, errors.New("thickness <= 0")
+	}
+	s := PatchSDF3{}
+	s.ctrl = ctrl
+	s.pu = degreeU
+	s.pv = degreeV
+	s.ku = clampedKnots(len(ctrl), degreeU)
+	s.kv = clampedKnots(len(ctrl[0]), degreeV)
+	s.half = 0.5 * thickness
+	s.build()
+	d := V3{s.half, s.half, s.half}
+	s.bb = Box3{s.node[0].bb.Min.Sub(d), s.node[0].bb.Max.Add(d)}
+	return &s, nil
+}
+
+//-----------------------------------------------------------------------------
+// B-spline evaluation
+
+// clampedKnots returns a clamped uniform knot vector on [0,1].
+func clampedKnots(n, p int) []float64 {
+	k := make([]float64, n+p+1)
+	spans := n - p
+	for i := range k {
+		k[i] = Clamp(float64(i-p)/float64(spans), 0, 1)
+	}
+	return k
+}
+
+// knotSpan returns the index of the knot span containing t.
+func knotSpan(k []float64, p int, t float64) int {
+	n := len(k) - p - 1
+	if t >= k[n] {
+		return n - 1
+	}
+	if t <= k[p] {
+		return p
+	}
+	lo, hi := p, n
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if t < k[mid] {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return lo
+}
+
+// basisDerivs returns the knot span for t and the non-zero B-spline basis
+// functions with their 1st and 2nd derivatives.
+// See: "The NURBS Book", Piegl & Tiller, A2.3
+func basisDerivs(k []float64, p int, t float64) (int, [3][]float64) {
+	span := knotSpan(k, p, t)
+	ndu := make([][]float64, p+1)
+	for i := range ndu {
+		ndu[i] = make([]float64, p+1)
+	}
+	left := make([]float64, p+1)
+	right := make([]float64, p+1)
+	ndu[0][0] = 1
+	for j := 1; j <= p; j++ {
+		left[j] = t - k[span+1-j]
+		right[j] = k[span+j] - t
+		saved := 0.0
+		for r := 0; r < j; r++ {
+			// lower triangle: knot differences
+			ndu[j][r] = right[r+1] + left[j-r]
+			tmp := ndu[r][j-1] / ndu[j][r]
+			// upper triangle: basis functions
+			ndu[r][j] = saved + right[r+1]*tmp
+			saved = left[j-r] * tmp
+		}
+		ndu[j][j] = saved
+	}
+	var ders [3][]float64
+	for i := range ders {
+		ders[i] = make([]float64, p+1)
+	}
+	for j := 0; j <= p; j++ {
+		ders[0][j] = ndu[j][p]
+	}
+	a := [2][]float64{make([]float64, p+1), make([]float64, p+1)}
+	for r := 0; r <= p; r++ {
+		s1, s2 := 0, 1
+		a[0][0] = 1
+		for n := 1; n <= 2; n++ {
+			d := 0.0
+			rk := r - n
+			pk := p - n
+			if r >= n {
+				a[s2][0] = a[s1][0] / ndu[pk+1][rk]
+				d = a[s2][0] * ndu[rk][pk]
+			}
+			j1, j2 := 1, n-1
+			if rk < -1 {
+				j1 = -rk
+			}
+			if r-1 > pk {
+				j2 = p - r
+			}
+			for j := j1; j <= j2; j++ {
+				a[s2][j] = (a[s1][j] - a[s1][j-1]) / ndu[pk+1][rk+j]
+				d += a[s2][j] * ndu[rk+j][pk]
+			}
+			if r <= pk {
+				a[s2][n] = -a[s1][n-1] / ndu[pk+1][r]
+				d += a[s2][n] * ndu[r][pk]
+			}
+			if n <= p {
+				ders[n][r] = d
+			}
+			s1, s2 = s2, s1
+		}
+	}
+	// multiply through by the correct factors
+	f := float64(p)
+	for n := 1; n <= 2; n++ {
+		for j := range ders[n] {
+			ders[n][j] *= f
+		}
+		f *= float64(p - n)
+	}
+	return span, ders
+}
+
+// surface returns the surface point and its 1st and 2nd partial derivatives
+// at (u,v). The order is S, Su, Sv, Suu, Suv, Svv.
+func (s *PatchSDF3) surface(u, v float64) [6]V3 {
+	su, nu := basisDerivs(s.ku, s.pu, u)
+	sv, nv := basisDerivs(s.kv, s.pv, v)
+	var x [6]V3
+	for a := 0; a <= s.pu; a++ {
+		row := s.ctrl[su-s.pu+a]
+		for b := 0; b <= s.pv; b++ {
+			p := row[sv-s.pv+b]
+			x[0] = x[0].Add(p.MulScalar(nu[0][a] * nv[0][b]))
+			x[1] = x[1].Add(p.MulScalar(nu[1][a] * nv[0][b]))
+			x[2] = x[2].Add(p.MulScalar(nu[0][a] * nv[1][b]))
+			x[3] = x[3].Add(p.MulScalar(nu[2][a] * nv[0][b]))
+			x[4] = x[4].Add(p.MulScalar(nu[1][a] * nv[1][b]))
+			x[5] = x[5].Add(p.MulScalar(nu[0][a] * nv[2][b]))
+		}
+	}
+	return x
+}
+
+// point returns the surface point at (u,v).
+func (s *PatchSDF3) point(u, v float64) V3 {
+	su, nu := basisDerivs(s.ku, s.pu, u)
+	sv, nv := basisDerivs(s.kv, s.pv, v)
+	var x V3
+	for a := 0; a <= s.pu; a++ {
+		row := s.ctrl[su-s.pu+a]
+		for b := 0; b <= s.pv; b++ {
+			x = x.Add(row[sv-s.pv+b].MulScalar(nu[0][a] * nv[0][b]))
+		}
+	}
+	return x
+}
+
+//-----------------------------------------------------------------------------
+// Bounding volume hierarchy
+
+// cellBox returns the bounding box of the surface within a cell.
+func (s *PatchSDF3) cellBox(u0, u1, v0, v1 float64) Box3 {
+	const n = patchSamples
+	var pts [n + 1][n + 1]V3
+	for i := 0; i <= n; i++ {
+		for j := 0; j <= n; j++ {
+			u := u0 + (u1-u0)*float64(i)/n
+			v := v0 + (v1-v0)*float64(j)/n
+			pts[i][j] = s.point(u, v)
+		}
+	}
+	bb := Box3{pts[0][0], pts[0][0]}
+	// the surface deviates from the sample grid by about the deviation of
+	// the sub-cell centers from the average of the sub-cell corners
+	margin := 0.0
+	for i := 0; i <= n; i++ {
+		for j := 0; j <= n; j++ {
+			bb = bb.Include(pts[i][j])
+			if i < n && j < n {
+				u := u0 + (u1-u0)*(float64(i)+0.5)/n
+				v := v0 + (v1-v0)*(float64(j)+0.5)/n
+				c := pts[i][j].Add(pts[i+1][j]).Add(pts[i][j+1]).Add(pts[i+1][j+1]).MulScalar(0.25)
+				margin = Max(margin, s.point(u, v).Sub(c).Length())
+			}
+		}
+	}
+	d := V3{margin, margin, margin}.MulScalar(2)
+	return Box3{bb.Min.Sub(d), bb.Max.Add(d)}
+}
+
+// buildNode adds a node for the cells [i0,i1) x [j0,j1) and returns its index.
+func (s *PatchSDF3) buildNode(i0, i1, j0, j1 int) int {
+	idx := len(s.node)
+	s.node = append(s.node, patchNode{})
+	if i1-i0 == 1 && j1-j0 == 1 {
+		const k = 1.0 / patchCells
+		u0, u1 := float64(i0)*k, float64(i1)*k
+		v0, v1 := float64(j0)*k, float64(j1)*k
+		s.node[idx].bb = s.cellBox(u0, u1, v0, v1)
+		s.node[idx].u = 0.5 * (u0 + u1)
+		s.node[idx].v = 0.5 * (v0 + v1)
+		return idx
+	}
+	var left, right int
+	if i1-i0 >= j1-j0 {
+		m := (i0 + i1) / 2
+		left = s.buildNode(i0, m, j0, j1)
+		right = s.buildNode(m, i1, j0, j1)
+	} else {
+		m := (j0 + j1) / 2
+		left = s.buildNode(i0, i1, j0, m)
+		right = s.buildNode(i0, i1, m, j1)
+	}
+	s.node[idx].left = left
+	s.node[idx].right = right
+	s.node[idx].bb = s.node[left].bb.Extend(s.node[right].bb)
+	return idx
+}
+
+// build builds the bounding volume hierarchy of patch cells.
+func (s *PatchSDF3) build() {
+	s.node = make([]patchNode, 0, 2*patchCells*patchCells)
+	s.buildNode(0, patchCells, 0, patchCells)
+}
+
+//-----------------------------------------------------------------------------
+
+// project returns the squared distance from a point to the surface, starting
+// the search at (u,v).
+func (s *PatchSDF3) project(p V3, u, v float64) float64 {
+	x := s.surface(u, v)
+	r := x[0].Sub(p)
+	best := r.Length2()
+	for i := 0; i < patchIterations; i++ {
+		// gradient and hessian of |S(u,v) - p|^2 / 2
+		gu, gv := x[1].Dot(r), x[2].Dot(r)
+		huu := x[1].Dot(x[1]) + x[3].Dot(r)
+		huv := x[1].Dot(x[2]) + x[4].Dot(r)
+		hvv := x[2].Dot(x[2]) + x[5].Dot(r)
+		// parameters on the patch boundary stay there if the gradient
+		// points outwards
+		freeU := !(u == 0 && gu > 0) && !(u == 1 && gu < 0)
+		freeV := !(v == 0 && gv > 0) && !(v == 1 && gv < 0)
+		var du, dv float64
+		switch {
+		case freeU && freeV:
+			if huu <= 0 || hvv <= 0 || huu*hvv-huv*huv <= 0 {
+				// not convex, use the Gauss-Newton approximation
+				huu = x[1].Dot(x[1])
+				huv = x[1].Dot(x[2])
+				hvv = x[2].Dot(x[2])
+			}
+			det := huu*hvv - huv*huv
+			if det < epsilon {
+				return best
+			}
+			du = (hvv*gu - huv*gv) / det
+			dv = (huu*gv - huv*gu) / det
+		case freeU:
+			if huu <= 0 {
+				huu = x[1].Dot(x[1])
+			}
+			if huu < epsilon {
+				return best
+			}
+			du = gu / huu
+		case freeV:
+			if hvv <= 0 {
+				hvv = x[2].Dot(x[2])
+			}
+			if hvv < epsilon {
+				return best
+			}
+			dv = gv / hvv
+		default:
+			return best
+		}
+		// backtrack until the step gets closer to the point
+		improved := false
+		for j := 0; j < 10; j++ {
+			u1 := Clamp(u-du, 0, 1)
+			v1 := Clamp(v-dv, 0, 1)
+			x1 := s.surface(u1, v1)
+			r1 := x1[0].Sub(p)
+			if d := r1.Length2(); d < best {
+				best = d
+				improved = Abs(u1-u)+Abs(v1-v) > 1e-12
+				u, v, x, r = u1, v1, x1, r1
+				break
+			}
+			du *= 0.5
+			dv *= 0.5
+		}
+		if !improved {
+			break
+		}
+	}
+	return best
+}
+
+// Evaluate returns the minimum distance to a thickened surface patch.
+func (s *PatchSDF3) Evaluate(p V3) float64 {
+	best := math.MaxFloat64
+	stack := make([]int, 1, 64)
+	for len(stack) > 0 {
+		n := &s.node[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if boxDist2(n.bb, p) >= best {
+			continue
+		}
+		if n.left == 0 {
+			best = Min(best, s.project(p, n.u, n.v))
+			continue
+		}
+		// visit the closest child first
+		dl := boxDist2(s.node[n.left].bb, p)
+		dr := boxDist2(s.node[n.right].bb, p)
+		if dl < dr {
+			stack = append(stack, n.right, n.left)
+		} else {
+			stack = append(stack, n.left, n.right)
+		}
+	}
+	return math.Sqrt(best) - s.half
+}
+
+// BoundingBox returns the bounding box for a thickened surface patch.
+func (s *PatchSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
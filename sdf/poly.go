@@ -17,9 +17,10 @@ import (
 
 // Polygon stores a set of 2d polygon vertices.
 type Polygon struct {
-	closed  bool            // is the polygon closed or open?
-	reverse bool            // return the vertices in reverse order
-	vlist   []PolygonVertex // list of polygon vertices
+	closed    bool            // is the polygon closed or open?
+	reverse   bool            // return the vertices in reverse order
+	tolerance float64         // chord tolerance for arcs (0 == default)
+	vlist     []PolygonVertex // list of polygon vertices
 }
 
 // PolygonVertex is a polygon vertex.
@@ -29,18 +30,26 @@ type PolygonVertex struct {
 	vertex   V2      // vertex coordinates
 	facets   int     // number of polygon facets to create when smoothing
 	radius   float64 // radius of smoothing (0 == none)
+	center   V2      // arc center or through point
+	ccw      bool    // arc direction (arc with a center)
 }
 
 // pvType is the type of a polygon vertex.
 type pvType int
 
 const (
-	pvNormal pvType = iota // normal vertex
-	pvHide                 // hide the line segment in rendering
-	pvSmooth               // smooth the vertex
-	pvArc                  // replace the line segment with an arc
+	pvNormal     pvType = iota // normal vertex
+	pvHide                     // hide the line segment in rendering
+	pvSmooth                   // smooth the vertex
+	pvArc                      // replace the line segment with an arc
+	pvArcCenter                // arc about a center, tessellated to the chord tolerance
+	pvArcThrough               // arc through a point, tessellated to the chord tolerance
+	pvArcRadius                // arc with a radius, tessellated to the chord tolerance
 )
 
+// defaultArcTolerance is the chord tolerance for arcs relative to the radius.
+const defaultArcTolerance = 1e-3
+
 //-----------------------------------------------------------------------------
 // Operations on Polygon Vertices
 
@@ -95,6 +104,37 @@ func (v *PolygonVertex) Arc(radius float64, facets int) *PolygonVertex {
 	return v
 }
 
+// ArcCenter replaces the line segment from the previous vertex with a circular
+// arc about a center (absolute coordinates). The arc is counter-clockwise if
+// ccw is true. If the vertex is the same as the previous vertex the arc is a
+// full circle.
+func (v *PolygonVertex) ArcCenter(center V2, ccw bool) *PolygonVertex {
+	v.center = center
+	v.ccw = ccw
+	v.vtype = pvArcCenter
+	return v
+}
+
+// ArcThrough replaces the line segment from the previous vertex with the
+// circular arc that passes through a point (absolute coordinates).
+func (v *PolygonVertex) ArcThrough(p V2) *PolygonVertex {
+	v.center = p
+	v.vtype = pvArcThrough
+	return v
+}
+
+// ArcRadius replaces the line segment from the previous vertex with a
+// circular arc of the radius. As with Arc, the sign of the radius selects the
+// side of the chord for the arc. Unlike Arc the number of facets is set by
+// the chord tolerance of the polygon.
+func (v *PolygonVertex) ArcRadius(radius float64) *PolygonVertex {
+	if radius != 0 {
+		v.radius = radius
+		v.vtype = pvArcRadius
+	}
+	return v
+}
+
 //-----------------------------------------------------------------------------
 
 // nextVertex returns the next vertex in the polygon.
@@ -172,13 +212,107 @@ func (p *Polygon) arcVertex(i int) bool {
 	return true
 }
 
+// trueArcVertex replaces a line segment with a circular arc tessellated to
+// the chord tolerance.
+func (p *Polygon) trueArcVertex(i int) bool {
+	v := &p.vlist[i]
+	vtype := v.vtype
+	if vtype != pvArcCenter && vtype != pvArcThrough && vtype != pvArcRadius {
+		return false
+	}
+	// now it's a normal vertex
+	v.vtype = pvNormal
+	pv := p.prevVertex(i)
+	if pv == nil {
+		return false
+	}
+	a := pv.vertex
+	b := v.vertex
+	var c V2          // arc center
+	var sweep float64 // arc angle from a to b (positive == ccw)
+	switch vtype {
+	case pvArcCenter:
+		c = v.center
+		sweep = angleCCW(a.Sub(c), b.Sub(c))
+		if !v.ccw {
+			sweep -= Tau
+			if sweep == 0 {
+				// full circle
+				sweep = -Tau
+			}
+		}
+	case pvArcThrough:
+		t := v.center
+		cross := t.Sub(a).Cross(b.Sub(t))
+		if Abs(cross) < epsilon*a.Sub(b).Length2() {
+			// colinear points, it's a line
+			return false
+		}
+		// circumcenter
+		d := 2 * (a.X*(t.Y-b.Y) + t.X*(b.Y-a.Y) + b.X*(a.Y-t.Y))
+		a2, t2, b2 := a.Length2(), t.Length2(), b.Length2()
+		c = V2{
+			(a2*(t.Y-b.Y) + t2*(b.Y-a.Y) + b2*(a.Y-t.Y)) / d,
+			(a2*(b.X-t.X) + t2*(a.X-b.X) + b2*(t.X-a.X)) / d,
+		}
+		sweep = angleCCW(a.Sub(c), b.Sub(c))
+		if cross < 0 {
+			sweep -= Tau
+		}
+	case pvArcRadius:
+		// as for Arc, a positive radius is a clockwise minor arc
+		side := Sign(v.radius)
+		radius := Abs(v.radius)
+		ba := b.Sub(a).Normalize()
+		n := V2{ba.Y, -ba.X}.MulScalar(side)
+		mid := a.Add(b).MulScalar(0.5)
+		h2 := radius*radius - mid.Sub(a).Length2()
+		if h2 < -tolerance*radius {
+			panic("arc radius is too small for the chord")
+		}
+		c = mid.Add(n.MulScalar(math.Sqrt(Max(h2, 0))))
+		sweep = -side * math.Acos(Clamp(a.Sub(c).Normalize().Dot(b.Sub(c).Normalize()), -1, 1))
+	}
+	// the radius is interpolated if the end points are at different radii
+	r0 := a.Sub(c).Length()
+	r1 := b.Sub(c).Length()
+	tol := p.tolerance
+	if tol == 0 {
+		tol = defaultArcTolerance * Max(r0, r1)
+	}
+	// the facet angle for the chord tolerance
+	dtheta := 2 * math.Acos(Clamp(1-tol/Max(r0, r1), -1, 1))
+	n := int(math.Ceil(Abs(sweep) / dtheta))
+	if n < 1 {
+		n = 1
+	}
+	a0 := math.Atan2(a.Y-c.Y, a.X-c.X)
+	vlist := make([]PolygonVertex, n-1)
+	for j := range vlist {
+		k := float64(j+1) / float64(n)
+		vlist[j] = PolygonVertex{vertex: c.Add(PolarToXY(Mix(r0, r1, k), a0+sweep*k))}
+	}
+	// insert the new vertices between the arc endpoints
+	p.vlist = append(p.vlist[:i], append(vlist, p.vlist[i:]...)...)
+	return true
+}
+
+// angleCCW returns the counter-clockwise angle from a to b, in (0, Tau].
+func angleCCW(a, b V2) float64 {
+	theta := math.Atan2(a.Cross(b), a.Dot(b))
+	if theta <= 0 {
+		theta += Tau
+	}
+	return theta
+}
+
 // createArcs converts polygon line segments to arcs.
 func (p *Polygon) createArcs() {
 	done := false
 	for done == false {
 		done = true
 		for i := range p.vlist {
-			if p.arcVertex(i) {
+			if p.arcVertex(i) || p.trueArcVertex(i) {
 				done = false
 			}
 		}
@@ -283,6 +417,17 @@ func (p *Polygon) Close() {
 	p.closed = true
 }
 
+// SetTolerance sets the chord tolerance used to tessellate the arcs added with
+// ArcCenter, ArcThrough and ArcRadius. The default is relative to the arc
+// radius.
+func (p *Polygon) SetTolerance(tolerance float64) *Polygon {
+	if tolerance <= 0 {
+		panic("tolerance <= 0")
+	}
+	p.tolerance = tolerance
+	return p
+}
+
 // Reverse reverses the order the vertices are returned.
 func (p *Polygon) Reverse() {
 	p.reverse = true
@@ -319,6 +464,102 @@ func (p *Polygon) Drop() {
 	p.vlist = p.vlist[:len(p.vlist)-1]
 }
 
+//-----------------------------------------------------------------------------
+// Vertex selection, so vertices can be modified after they are added.
+
+// PolygonSelection is a set of selected polygon vertices.
+type PolygonSelection []*PolygonVertex
+
+// VertexFilter returns true if the i-th vertex of a polygon is selected.
+type VertexFilter func(p *Polygon, i int) bool
+
+// Len returns the number of vertices in the polygon.
+func (p *Polygon) Len() int {
+	return len(p.vlist)
+}
+
+// Vertex returns the i-th polygon vertex. Negative indices count back from
+// the last vertex.
+func (p *Polygon) Vertex(i int) *PolygonVertex {
+	if i < 0 {
+		i += len(p.vlist)
+	}
+	return &p.vlist[i]
+}
+
+// Range selects the polygon vertices with indices in [i0, i1).
+func (p *Polygon) Range(i0, i1 int) PolygonSelection {
+	var sel PolygonSelection
+	for i := i0; i < i1; i++ {
+		if i >= 0 && i < len(p.vlist) {
+			sel = append(sel, &p.vlist[i])
+		}
+	}
+	return sel
+}
+
+// Select selects the polygon vertices accepted by a filter.
+func (p *Polygon) Select(filter VertexFilter) PolygonSelection {
+	// the filters need absolute vertex positions
+	p.relToAbs()
+	var sel PolygonSelection
+	for i := range p.vlist {
+		if filter(p, i) {
+			sel = append(sel, &p.vlist[i])
+		}
+	}
+	return sel
+}
+
+// Smooth marks the selected vertices for smoothing.
+func (sel PolygonSelection) Smooth(radius float64, facets int) PolygonSelection {
+	for _, v := range sel {
+		v.Smooth(radius, facets)
+	}
+	return sel
+}
+
+// Chamfer marks the selected vertices for chamfering.
+func (sel PolygonSelection) Chamfer(size float64) PolygonSelection {
+	for _, v := range sel {
+		v.Chamfer(size)
+	}
+	return sel
+}
+
+// corner returns the turn (cross product) at the i-th vertex, signed so that
+// convex corners are positive. ok is false for the ends of an open polygon.
+func (p *Polygon) corner(i int) (float64, bool) {
+	vp := p.prevVertex(i)
+	vn := p.nextVertex(i)
+	if vp == nil || vn == nil {
+		return 0, false
+	}
+	v := p.vlist[i].vertex
+	turn := v.Sub(vp.vertex).Cross(vn.vertex.Sub(v))
+	// the polygon orientation
+	area := 0.0
+	n := len(p.vlist)
+	for j := range p.vlist {
+		area += p.vlist[j].vertex.Cross(p.vlist[(j+1)%n].vertex)
+	}
+	return turn * Sign(area), true
+}
+
+// ConvexVertices selects the convex corners of a polygon.
+func ConvexVertices(p *Polygon, i int) bool {
+	turn, ok := p.corner(i)
+	return ok && turn > 0
+}
+
+// ConcaveVertices selects the concave corners of a polygon.
+func ConcaveVertices(p *Polygon, i int) bool {
+	turn, ok := p.corner(i)
+	return ok && turn < 0
+}
+
+//-----------------------------------------------------------------------------
+
 // Vertices returns the vertices of the polygon.
 func (p *Polygon) Vertices() []V2 {
 	if p.vlist == nil {
@@ -387,3 +628,194 @@ func Nagon(n int, radius float64) V2Set {
 }
 
 //-----------------------------------------------------------------------------
+// Per-Vertex Corners
+
+// PolygonCorner defines how a polygon corner is finished. A zero value
+// leaves the corner sharp.
+type PolygonCorner struct {
+	Round   float64 // fillet radius
+	Chamfer float64 // chamfer size (distance from the corner along each edge)
+}
+
+// cornerArcStep is the maximum angle of an arc facet for a rounded corner.
+const cornerArcStep = Tau / 128
+
+// CornerVertices returns the vertices of a closed polygon with each corner
+// rounded or chamfered as given by the corresponding element of corner.
+// An error is returned if the corners on an edge don't fit on the edge.
+func CornerVertices(vertex []V2, corner []PolygonCorner) ([]V2, error) {
+	n := len(vertex)
+	if n < 3 {
+		return nil, fmt.Errorf("polygon has %d vertices", n)
+	}
+	if len(corner) != n {
+		return nil, fmt.Errorf("polygon has %d vertices and %d corners", n, len(corner))
+	}
+	// distance from each vertex to the start of the corner along the edges
+	d := make([]float64, n)
+	for i, k := range corner {
+		if k.Round < 0 || k.Chamfer < 0 {
+			return nil, fmt.Errorf("vertex %d: negative corner size", i)
+		}
+		if k.Round > 0 && k.Chamfer > 0 {
+			return nil, fmt.Errorf("vertex %d: corner is both rounded and chamfered", i)
+		}
+		if k.Chamfer > 0 {
+			d[i] = k.Chamfer
+		} else if k.Round > 0 {
+			v0 := vertex[(i+n-1)%n].Sub(vertex[i]).Normalize()
+			v1 := vertex[(i+1)%n].Sub(vertex[i]).Normalize()
+			theta := math.Acos(Clamp(v0.Dot(v1), -1, 1))
+			if theta < epsilon {
+				return nil, fmt.Errorf("vertex %d: can't round a cusp", i)
+			}
+			d[i] = k.Round / math.Tan(0.5*theta)
+		}
+	}
+	// the corners at each end of an edge must fit on the edge
+	for i := range vertex {
+		j := (i + 1) % n
+		if d[i]+d[j] > vertex[j].Sub(vertex[i]).Length()+tolerance {
+			return nil, fmt.Errorf("edge %d-%d: corners are too large for the edge", i, j)
+		}
+	}
+	var out []V2
+	for i, v := range vertex {
+		if d[i] < tolerance {
+			out = append(out, v)
+			continue
+		}
+		v0 := vertex[(i+n-1)%n].Sub(v).Normalize()
+		v1 := vertex[(i+1)%n].Sub(v).Normalize()
+		p0 := v.Add(v0.MulScalar(d[i]))
+		p1 := v.Add(v1.MulScalar(d[i]))
+		if corner[i].Chamfer > 0 {
+			out = append(out, p0, p1)
+			continue
+		}
+		// fillet arc from p0 to p1
+		r := corner[i].Round
+		c := v.Add(v0.Add(v1).Normalize().MulScalar(math.Sqrt(d[i]*d[i] + r*r)))
+		a0 := math.Atan2(p0.Y-c.Y, p0.X-c.X)
+		da := math.Atan2(p1.Sub(c).Cross(p0.Sub(c)), p0.Sub(c).Dot(p1.Sub(c)))
+		m := int(math.Ceil(Abs(da)/cornerArcStep)) + 1
+		for j := 0; j <= m; j++ {
+			out = append(out, c.Add(PolarToXY(r, a0-da*float64(j)/float64(m))))
+		}
+	}
+	return out, nil
+}
+
+// CornerPolygon2D returns a polygon with each corner rounded or chamfered as
+// given by the corresponding element of corner.
+func CornerPolygon2D(vertex []V2, corner []PolygonCorner) (SDF2, error) {
+	v, err := CornerVertices(vertex, corner)
+	if err != nil {
+		return nil, err
+	}
+	return Polygon2D(v), nil
+}
+
+// CornerNagon2D returns a N sided regular polygon with each corner rounded
+// or chamfered as given by the corresponding element of corner. The first
+// vertex is on the positive x axis.
+func CornerNagon2D(n int, radius float64, corner []PolygonCorner) (SDF2, error) {
+	if n < 3 {
+		return nil, fmt.Errorf("n-gon has %d sides", n)
+	}
+	return CornerPolygon2D(Nagon(n, radius), corner)
+}
+
+//-----------------------------------------------------------------------------
+// Polygon Offsets
+
+// OffsetJoin is the style of the corners of an offset polygon.
+type OffsetJoin int
+
+// Offset join styles.
+const (
+	JoinRound OffsetJoin = iota // corners are circular arcs
+	JoinMiter                   // corners are sharp (bevelled beyond the miter limit)
+	JoinBevel                   // corners are cut off
+)
+
+// offsetArcStep is the maximum angle of an arc facet for a round join.
+const offsetArcStep = Tau / 64
+
+// OffsetPolygon returns the vertices of a closed polygon offset by a distance
+// (positive is outwards, negative is inwards). The join style sets the shape
+// of the corners that are opened up by the offset. For miter joins the miter
+// limit is the maximum ratio of the miter length (vertex to miter tip) to the
+// offset, sharper corners are bevelled. Large inward offsets can make a self
+// intersecting polygon, the result is not trimmed.
+func OffsetPolygon(vertex []V2, offset float64, join OffsetJoin, miterLimit float64) V2Set {
+	// remove a closing vertex
+	n := len(vertex)
+	if n > 1 && vertex[0].Equals(vertex[n-1], tolerance) {
+		n--
+	}
+	if n < 3 {
+		return nil
+	}
+	v := vertex[:n]
+	// work out the orientation
+	area := 0.0
+	for i := range v {
+		area += v[i].Cross(v[(i+1)%n])
+	}
+	if area < 0 {
+		// clockwise polygon, the outward normals are on the other side
+		offset = -offset
+	}
+	// outward edge normals (for a counter-clockwise polygon)
+	normal := make([]V2, n)
+	for i := range v {
+		e := v[(i+1)%n].Sub(v[i]).Normalize()
+		normal[i] = V2{e.Y, -e.X}
+	}
+	var out V2Set
+	for i := range v {
+		out = offsetCorner(out, v[i], normal[(i+n-1)%n], normal[i], offset, join, miterLimit)
+	}
+	return out
+}
+
+// offsetCorner appends the vertices for an offset corner. The corner is at v
+// and the outward normals of the edges before and after it are n0 and n1.
+func offsetCorner(out V2Set, v, n0, n1 V2, offset float64, join OffsetJoin, miterLimit float64) V2Set {
+	p0 := v.Add(n0.MulScalar(offset))
+	p1 := v.Add(n1.MulScalar(offset))
+	cos := n0.Dot(n1)
+	// does the offset open a gap at the corner?
+	gap := n0.Cross(n1)*offset > 0
+	if cos > 1-epsilon || !gap {
+		if cos <= -1+epsilon {
+			// a hairpin turn, the offset lines don't intersect
+			return append(out, p0, p1)
+		}
+		// the offset lines intersect at the miter point
+		return append(out, v.Add(n0.Add(n1).MulScalar(offset/(1+cos))))
+	}
+	switch join {
+	case JoinMiter:
+		// miter length / offset = 1 / cos(half the turn angle)
+		if cos > -1+epsilon && math.Sqrt(2/(1+cos)) <= miterLimit {
+			return append(out, v.Add(n0.Add(n1).MulScalar(offset/(1+cos))))
+		}
+		return append(out, p0, p1)
+	case JoinBevel:
+		return append(out, p0, p1)
+	case JoinRound:
+		a0 := math.Atan2(n0.Y, n0.X)
+		turn := math.Atan2(n0.Cross(n1), cos)
+		k := int(math.Ceil(Abs(turn) / offsetArcStep))
+		for j := 0; j <= k; j++ {
+			a := a0 + turn*float64(j)/float64(k)
+			out = append(out, v.Add(V2{math.Cos(a), math.Sin(a)}.MulScalar(offset)))
+		}
+		return out
+	}
+	panic("unknown offset join")
+}
+
+//-----------------------------------------------------------------------------
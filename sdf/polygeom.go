@@ -0,0 +1,178 @@
+//-----------------------------------------------------------------------------
+/*
+
+Polygon Geometry
+
+Point in polygon, winding number, segment intersection and polygon
+area/orientation tests for 2D polygons.
+
+A polygon is a closed list of vertices, the last vertex joins the first (a
+repeated first vertex at the end is allowed). The tests that decide on which
+side of a line a point is use the robust orientation predicate, so points on
+or very close to the edges are classified consistently.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+//-----------------------------------------------------------------------------
+
+// PointLocation is the location of a point relative to a polygon.
+type PointLocation int
+
+// Point locations.
+const (
+	PointOutside    PointLocation = iota // outside the polygon
+	PointInside                          // inside the polygon
+	PointOnBoundary                      // on an edge or vertex of the polygon
+)
+
+// onSegment returns true if a point collinear with a segment is on it.
+func onSegment(a, b, p V2) bool {
+	return Min(a.X, b.X) <= p.X && p.X <= Max(a.X, b.X) &&
+		Min(a.Y, b.Y) <= p.Y && p.Y <= Max(a.Y, b.Y)
+}
+
+// sign returns the sign of a value as -1, 0 or 1.
+func sign(x float64) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
+
+//-----------------------------------------------------------------------------
+
+// WindingNumber returns the number of times a polygon winds around a point,
+// counter-clockwise is positive. A point on the boundary has an undefined
+// winding number (see PointInPolygon).
+func WindingNumber(vertex []V2, p V2) int {
+	// See: http://geomalgorithms.com/a03-_inclusion.html
+	wn := 0
+	n := len(vertex)
+	for i := 0; i < n; i++ {
+		a, b := vertex[i], vertex[(i+1)%n]
+		if a.Y <= p.Y {
+			if b.Y > p.Y && orient2d(a, b, p) > 0 {
+				// upward crossing, p is left of the edge
+				wn++
+			}
+		} else if b.Y <= p.Y && orient2d(a, b, p) < 0 {
+			// downward crossing, p is right of the edge
+			wn--
+		}
+	}
+	return wn
+}
+
+// PointInPolygon returns the location of a point relative to a polygon. The
+// inside of the polygon is the region with a non-zero winding number.
+func PointInPolygon(vertex []V2, p V2) PointLocation {
+	n := len(vertex)
+	for i := 0; i < n; i++ {
+		a, b := vertex[i], vertex[(i+1)%n]
+		if orient2d(a, b, p) == 0 && onSegment(a, b, p) {
+			return PointOnBoundary
+		}
+	}
+	if WindingNumber(vertex, p) != 0 {
+		return PointInside
+	}
+	return PointOutside
+}
+
+//-----------------------------------------------------------------------------
+
+// PolygonArea returns the signed area of a polygon. It is positive for a
+// counter-clockwise polygon and negative for a clockwise polygon.
+func PolygonArea(vertex []V2) float64 {
+	if len(vertex) < 3 {
+		return 0
+	}
+	// relative to the first vertex, for precision away from the origin
+	area := 0.0
+	o := vertex[0]
+	for i := 1; i < len(vertex)-1; i++ {
+		area += vertex[i].Sub(o).Cross(vertex[i+1].Sub(o))
+	}
+	return 0.5 * area
+}
+
+// PolygonIsCCW returns true if a simple polygon is counter-clockwise.
+func PolygonIsCCW(vertex []V2) bool {
+	n := len(vertex)
+	if n < 3 {
+		return false
+	}
+	// the lowest (then leftmost) vertex is convex, so the orientation of its
+	// corner is the orientation of the polygon
+	k := 0
+	for i, v := range vertex {
+		if v.Y < vertex[k].Y || (v.Y == vertex[k].Y && v.X < vertex[k].X) {
+			k = i
+		}
+	}
+	// the neighbors, skipping repeated vertices
+	prev := (k + n - 1) % n
+	for prev != k && vertex[prev] == vertex[k] {
+		prev = (prev + n - 1) % n
+	}
+	next := (k + 1) % n
+	for next != k && vertex[next] == vertex[k] {
+		next = (next + 1) % n
+	}
+	if d := orient2d(vertex[prev], vertex[k], vertex[next]); d != 0 {
+		return d > 0
+	}
+	return PolygonArea(vertex) > 0
+}
+
+//-----------------------------------------------------------------------------
+
+// SegmentsIntersect returns true if the line segments a0-a1 and b0-b1
+// intersect or touch.
+func SegmentsIntersect(a0, a1, b0, b1 V2) bool {
+	d0 := sign(orient2d(b0, b1, a0))
+	d1 := sign(orient2d(b0, b1, a1))
+	d2 := sign(orient2d(a0, a1, b0))
+	d3 := sign(orient2d(a0, a1, b1))
+	if d0*d1 < 0 && d2*d3 < 0 {
+		return true
+	}
+	// an end point on the other segment
+	return (d0 == 0 && onSegment(b0, b1, a0)) ||
+		(d1 == 0 && onSegment(b0, b1, a1)) ||
+		(d2 == 0 && onSegment(a0, a1, b0)) ||
+		(d3 == 0 && onSegment(a0, a1, b1))
+}
+
+// SegmentIntersection returns the intersection point of the line segments
+// a0-a1 and b0-b1, and true if they intersect. For overlapping collinear
+// segments the point of the overlap closest to a0 is returned.
+func SegmentIntersection(a0, a1, b0, b1 V2) (V2, bool) {
+	if !SegmentsIntersect(a0, a1, b0, b1) {
+		return V2{}, false
+	}
+	if orient2d(b0, b1, a0) == 0 && orient2d(b0, b1, a1) == 0 {
+		// collinear
+		if onSegment(b0, b1, a0) {
+			return a0, true
+		}
+		// b0 or b1 is on a, use the closest to a0
+		p := b1
+		if !onSegment(a0, a1, b1) || (onSegment(a0, a1, b0) && b0.Sub(a0).Length2() < b1.Sub(a0).Length2()) {
+			p = b0
+		}
+		return p, true
+	}
+	da := a1.Sub(a0)
+	db := b1.Sub(b0)
+	t := b0.Sub(a0).Cross(db) / da.Cross(db)
+	return a0.Add(da.MulScalar(Clamp(t, 0, 1))), true
+}
+
+//-----------------------------------------------------------------------------
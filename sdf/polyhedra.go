@@ -0,0 +1,233 @@
+//-----------------------------------------------------------------------------
+/*
+
+Polyhedra
+
+Convex polyhedra (the platonic solids) defined as the intersection of the
+planes containing their faces. Each solid is sized by its inradius (the
+distance from the center to each face) and is centered on the origin.
+
+The distance is exact. Inside the solid it is the distance to the nearest face
+plane. Outside the solid it is the distance to the nearest face, edge or
+vertex. Because the distance is exact the edges and vertices can be rounded by
+shrinking the solid and then offsetting the surface by the rounding radius.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import "math"
+
+//-----------------------------------------------------------------------------
+
+// PolyhedronSDF3 is a convex polyhedron.
+type PolyhedronSDF3 struct {
+	n     []V3    // unit face normals
+	r     float64 // inradius of the unrounded polyhedron
+	a, ab []V3    // edge segments: a + t*ab, t in [0,1]
+	round float64 // rounding radius
+	bb    Box3
+}
+
+// newPolyhedron returns a convex polyhedron with a set of face normals,
+// an inradius and a rounding radius for the edges and vertices.
+func newPolyhedron(normals []V3, radius, round float64) SDF3 {
+	if radius <= 0 {
+		panic("radius <= 0")
+	}
+	if round < 0 || round >= radius {
+		panic("round must be in [0, radius)")
+	}
+	s := PolyhedronSDF3{}
+	s.n = make([]V3, len(normals))
+	for i, n := range normals {
+		s.n[i] = n.Normalize()
+	}
+	s.r = radius - round
+	s.round = round
+
+	// The vertices are where 3 or more face planes meet.
+	type vertex struct {
+		v     V3
+		faces []int
+	}
+	tolerance := 1e-9 * s.r
+	var vs []vertex
+	n := len(s.n)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			for k := j + 1; k < n; k++ {
+				v, ok := s.planes3(i, j, k)
+				if !ok || s.planes(v) > tolerance {
+					continue
+				}
+				found := false
+				for x := range vs {
+					if vs[x].v.Equals(v, 100*tolerance) {
+						found = true
+						break
+					}
+				}
+				if found {
+					continue
+				}
+				var faces []int
+				for f := range s.n {
+					if Abs(s.n[f].Dot(v)-s.r) <= tolerance {
+						faces = append(faces, f)
+					}
+				}
+				vs = append(vs, vertex{v, faces})
+			}
+		}
+	}
+
+	// The edges join vertices that share 2 face planes.
+	for i := range vs {
+		for j := i + 1; j < len(vs); j++ {
+			shared := 0
+			for _, fi := range vs[i].faces {
+				for _, fj := range vs[j].faces {
+					if fi == fj {
+						shared++
+					}
+				}
+			}
+			if shared >= 2 {
+				s.a = append(s.a, vs[i].v)
+				s.ab = append(s.ab, vs[j].v.Sub(vs[i].v))
+			}
+		}
+	}
+
+	bb := Box3{vs[0].v, vs[0].v}
+	for _, v := range vs {
+		bb = bb.Include(v.v)
+	}
+	d := V3{round, round, round}
+	s.bb = Box3{bb.Min.Sub(d), bb.Max.Add(d)}
+	return &s
+}
+
+// planes3 returns the intersection point of 3 face planes.
+func (s *PolyhedronSDF3) planes3(i, j, k int) (V3, bool) {
+	a, b, c := s.n[i], s.n[j], s.n[k]
+	bc := b.Cross(c)
+	det := a.Dot(bc)
+	if Abs(det) < epsilon {
+		return V3{}, false
+	}
+	// all planes are at distance r from the origin
+	v := bc.Add(c.Cross(a)).Add(a.Cross(b))
+	return v.MulScalar(s.r / det), true
+}
+
+// planes returns the maximum distance from a point to the face planes.
+func (s *PolyhedronSDF3) planes(p V3) float64 {
+	d := math.Inf(-1)
+	for _, n := range s.n {
+		d = Max(d, n.Dot(p)-s.r)
+	}
+	return d
+}
+
+// Evaluate returns the minimum distance to a polyhedron.
+func (s *PolyhedronSDF3) Evaluate(p V3) float64 {
+	// find the face plane furthest from the point
+	d := math.Inf(-1)
+	face := 0
+	for i, n := range s.n {
+		x := n.Dot(p) - s.r
+		if x > d {
+			d = x
+			face = i
+		}
+	}
+	if d <= 0 {
+		// inside: the distance to the nearest face plane
+		return d - s.round
+	}
+	// outside: if the projection onto the furthest face plane is within the
+	// polyhedron then that face is closest, otherwise it is an edge.
+	if s.planes(p.Sub(s.n[face].MulScalar(d))) <= 1e-9*s.r {
+		return d - s.round
+	}
+	d2 := math.Inf(1)
+	for i, a := range s.a {
+		ab := s.ab[i]
+		ap := p.Sub(a)
+		t := Clamp(ap.Dot(ab)/ab.Length2(), 0, 1)
+		d2 = Min(d2, ap.Sub(ab.MulScalar(t)).Length2())
+	}
+	return math.Sqrt(d2) - s.round
+}
+
+// BoundingBox returns the bounding box for a polyhedron.
+func (s *PolyhedronSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Platonic Solids
+
+// Tetrahedron3D returns an SDF3 for a regular tetrahedron with a given
+// inradius. Edges and vertices are rounded with round > 0.
+func Tetrahedron3D(radius, round float64) SDF3 {
+	n := []V3{
+		{1, 1, 1}, {1, -1, -1}, {-1, 1, -1}, {-1, -1, 1},
+	}
+	return newPolyhedron(n, radius, round)
+}
+
+// Octahedron3D returns an SDF3 for a regular octahedron with a given
+// inradius. The vertices are on the axes. Edges and vertices are rounded with
+// round > 0.
+func Octahedron3D(radius, round float64) SDF3 {
+	var n []V3
+	for _, x := range []float64{-1, 1} {
+		for _, y := range []float64{-1, 1} {
+			for _, z := range []float64{-1, 1} {
+				n = append(n, V3{x, y, z})
+			}
+		}
+	}
+	return newPolyhedron(n, radius, round)
+}
+
+// Dodecahedron3D returns an SDF3 for a regular dodecahedron with a given
+// inradius. Edges and vertices are rounded with round > 0.
+func Dodecahedron3D(radius, round float64) SDF3 {
+	// the face normals are the vertices of an icosahedron
+	phi := 0.5 * (1 + math.Sqrt(5))
+	var n []V3
+	for _, a := range []float64{-1, 1} {
+		for _, b := range []float64{-phi, phi} {
+			n = append(n, V3{0, a, b}, V3{a, b, 0}, V3{b, 0, a})
+		}
+	}
+	return newPolyhedron(n, radius, round)
+}
+
+// Icosahedron3D returns an SDF3 for a regular icosahedron with a given
+// inradius. Edges and vertices are rounded with round > 0.
+func Icosahedron3D(radius, round float64) SDF3 {
+	// the face normals are the vertices of a dodecahedron
+	phi := 0.5 * (1 + math.Sqrt(5))
+	var n []V3
+	for _, x := range []float64{-1, 1} {
+		for _, y := range []float64{-1, 1} {
+			for _, z := range []float64{-1, 1} {
+				n = append(n, V3{x, y, z})
+			}
+		}
+	}
+	for _, a := range []float64{-1 / phi, 1 / phi} {
+		for _, b := range []float64{-phi, phi} {
+			n = append(n, V3{0, a, b}, V3{a, b, 0}, V3{b, 0, a})
+		}
+	}
+	return newPolyhedron(n, radius, round)
+}
+
+//-----------------------------------------------------------------------------
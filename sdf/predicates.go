@@ -0,0 +1,131 @@
+//-----------------------------------------------------------------------------
+/*
+
+Robust Geometric Predicates
+
+The orientation and incircle tests decide the topology of a triangulation.
+With plain floating point a nearly collinear or cocircular set of points
+(E.g. points on a grid) can get the wrong sign, giving slivers or a broken
+triangulation.
+
+The floating point result is used when it is larger than the bound on its
+rounding error. Otherwise the determinant is evaluated exactly with rational
+arithmetic. The error bounds are from:
+
+Adaptive Precision Floating-Point Arithmetic and Fast Robust Geometric
+Predicates, Jonathan Richard Shewchuk, 1997.
+https://www.cs.cmu.edu/~quake/robust.html
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"math"
+	"math/big"
+)
+
+//-----------------------------------------------------------------------------
+
+// machineEpsilon is half the distance from 1.0 to the next float64.
+const machineEpsilon = 1.0 / (1 << 53)
+
+// error bounds for the floating point determinants
+const (
+	orientErrBound   = (3 + 16*machineEpsilon) * machineEpsilon
+	inCircleErrBound = (10 + 96*machineEpsilon) * machineEpsilon
+)
+
+// ratDiff returns x - y exactly.
+func ratDiff(x, y float64) *big.Rat {
+	r := new(big.Rat).SetFloat64(x)
+	return r.Sub(r, new(big.Rat).SetFloat64(y))
+}
+
+// ratFloat64 returns a rational as the nearest float64, keeping the sign of a
+// tiny value.
+func ratFloat64(r *big.Rat) float64 {
+	f, _ := r.Float64()
+	if f == 0 && r.Sign() != 0 {
+		return float64(r.Sign()) * math.SmallestNonzeroFloat64
+	}
+	return f
+}
+
+//-----------------------------------------------------------------------------
+
+// orient2d returns a positive value if a, b, c are counter-clockwise, a
+// negative value if they are clockwise and zero if they are collinear. The
+// value is twice the signed area of the triangle, the sign is exact.
+func orient2d(a, b, c V2) float64 {
+	detLeft := (a.X - c.X) * (b.Y - c.Y)
+	detRight := (a.Y - c.Y) * (b.X - c.X)
+	det := detLeft - detRight
+	if Abs(det) >= orientErrBound*(Abs(detLeft)+Abs(detRight)) && det != 0 {
+		return det
+	}
+	if detLeft == 0 && detRight == 0 {
+		return 0
+	}
+	return orient2dExact(a, b, c)
+}
+
+// orient2dExact returns orient2d evaluated with exact arithmetic.
+func orient2dExact(a, b, c V2) float64 {
+	l := new(big.Rat).Mul(ratDiff(a.X, c.X), ratDiff(b.Y, c.Y))
+	r := new(big.Rat).Mul(ratDiff(a.Y, c.Y), ratDiff(b.X, c.X))
+	return ratFloat64(l.Sub(l, r))
+}
+
+// inCircle returns a positive value if d is inside the circumcircle of the
+// counter-clockwise triangle a, b, c, negative if it is outside and zero if
+// it is on the circle. The sign is exact.
+func inCircle(a, b, c, d V2) float64 {
+	adx, ady := a.X-d.X, a.Y-d.Y
+	bdx, bdy := b.X-d.X, b.Y-d.Y
+	cdx, cdy := c.X-d.X, c.Y-d.Y
+
+	bdxcdy, cdxbdy := bdx*cdy, cdx*bdy
+	cdxady, adxcdy := cdx*ady, adx*cdy
+	adxbdy, bdxady := adx*bdy, bdx*ady
+
+	aLift := adx*adx + ady*ady
+	bLift := bdx*bdx + bdy*bdy
+	cLift := cdx*cdx + cdy*cdy
+
+	det := aLift*(bdxcdy-cdxbdy) + bLift*(cdxady-adxcdy) + cLift*(adxbdy-bdxady)
+	permanent := (Abs(bdxcdy)+Abs(cdxbdy))*aLift +
+		(Abs(cdxady)+Abs(adxcdy))*bLift +
+		(Abs(adxbdy)+Abs(bdxady))*cLift
+	if Abs(det) > inCircleErrBound*permanent {
+		return det
+	}
+	if permanent == 0 {
+		return 0
+	}
+	return inCircleExact(a, b, c, d)
+}
+
+// inCircleExact returns inCircle evaluated with exact arithmetic.
+func inCircleExact(a, b, c, d V2) float64 {
+	adx, ady := ratDiff(a.X, d.X), ratDiff(a.Y, d.Y)
+	bdx, bdy := ratDiff(b.X, d.X), ratDiff(b.Y, d.Y)
+	cdx, cdy := ratDiff(c.X, d.X), ratDiff(c.Y, d.Y)
+	// lift returns x*x + y*y
+	lift := func(x, y *big.Rat) *big.Rat {
+		l := new(big.Rat).Mul(x, x)
+		return l.Add(l, new(big.Rat).Mul(y, y))
+	}
+	// cross returns x0*y1 - x1*y0
+	cross := func(x0, y0, x1, y1 *big.Rat) *big.Rat {
+		c := new(big.Rat).Mul(x0, y1)
+		return c.Sub(c, new(big.Rat).Mul(x1, y0))
+	}
+	det := new(big.Rat).Mul(lift(adx, ady), cross(bdx, bdy, cdx, cdy))
+	det.Add(det, new(big.Rat).Mul(lift(bdx, bdy), cross(cdx, cdy, adx, ady)))
+	det.Add(det, new(big.Rat).Mul(lift(cdx, cdy), cross(adx, ady, bdx, bdy)))
+	return ratFloat64(det)
+}
+
+//-----------------------------------------------------------------------------
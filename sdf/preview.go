@@ -0,0 +1,348 @@
+//-----------------------------------------------------------------------------
+/*
+
+Interactive Preview Server
+
+Mesh an SDF3 at preview resolution and serve it to a browser with a small
+WebGL viewer. The viewer polls the server for a new mesh version, so calling
+Update() with a modified SDF3 refreshes the browser view.
+
+Left drag rotates, the mouse wheel zooms.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+
+// PreviewServer serves a WebGL view of an SDF3 mesh.
+type PreviewServer struct {
+	meshCells int        // number of cells on the longest axis
+	mu        sync.Mutex // protects the mesh and version
+	stl       []byte     // binary STL of the current mesh
+	version   int        // incremented on each mesh update
+	mux       *http.ServeMux
+}
+
+// NewPreviewServer returns a preview server that meshes with meshCells on the longest axis.
+func NewPreviewServer(meshCells int) *PreviewServer {
+	ps := &PreviewServer{
+		meshCells: meshCells,
+		mux:       http.NewServeMux(),
+	}
+	ps.mux.HandleFunc("/", ps.serveIndex)
+	ps.mux.HandleFunc("/mesh.stl", ps.serveMesh)
+	ps.mux.HandleFunc("/version", ps.serveVersion)
+	return ps
+}
+
+// Update meshes an SDF3 and makes it the current preview mesh.
+func (ps *PreviewServer) Update(s SDF3) error {
+	mesh := RenderMesh(s, ps.meshCells)
+	var buf bytes.Buffer
+	if err := EncodeSTL(&buf, mesh); err != nil {
+		return err
+	}
+	ps.mu.Lock()
+	ps.stl = buf.Bytes()
+	ps.version++
+	ps.mu.Unlock()
+	return nil
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (ps *PreviewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ps.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe serves the preview on a network address (e.g. "localhost:8080").
+func (ps *PreviewServer) ListenAndServe(addr string) error {
+	fmt.Printf("serving preview on http://%s/\n", addr)
+	return http.ListenAndServe(addr, ps)
+}
+
+//-----------------------------------------------------------------------------
+
+func (ps *PreviewServer) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(previewHTML))
+}
+
+func (ps *PreviewServer) serveMesh(w http.ResponseWriter, r *http.Request) {
+	ps.mu.Lock()
+	stl := ps.stl
+	version := ps.version
+	ps.mu.Unlock()
+	if stl == nil {
+		http.Error(w, "no mesh", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "model/stl")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Mesh-Version", fmt.Sprintf("%d", version))
+	w.Write(stl)
+}
+
+func (ps *PreviewServer) serveVersion(w http.ResponseWriter, r *http.Request) {
+	ps.mu.Lock()
+	version := ps.version
+	ps.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "%d", version)
+}
+
+//-----------------------------------------------------------------------------
+
+// Preview meshes an SDF3 and serves it for viewing in a browser.
+func Preview(
+	s SDF3, // sdf3 to preview
+	meshCells int, // number of cells on the longest axis. e.g 100
+	addr string, // network address to serve on. e.g "localhost:8080"
+) error {
+	ps := NewPreviewServer(meshCells)
+	if err := ps.Update(s); err != nil {
+		return err
+	}
+	return ps.ListenAndServe(addr)
+}
+
+//-----------------------------------------------------------------------------
+
+const previewHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>sdfx preview</title>
+<style>
+html, body { margin: 0; height: 100%; overflow: hidden; background: #303030; }
+canvas { display: block; width: 100%; height: 100%; }
+#info { position: absolute; top: 8px; left: 8px; color: #c0c0c0; font: 12px monospace; }
+</style>
+</head>
+<body>
+<canvas id="view"></canvas>
+<div id="info">loading...</div>
+<script>
+"use strict";
+
+const canvas = document.getElementById("view");
+const info = document.getElementById("info");
+const gl = canvas.getContext("webgl");
+
+const vsSource = ` + "`" + `
+attribute vec3 aPosition;
+attribute vec3 aNormal;
+uniform mat4 uModel;
+uniform mat4 uProjection;
+varying vec3 vNormal;
+void main() {
+	vNormal = (uModel * vec4(aNormal, 0.0)).xyz;
+	gl_Position = uProjection * uModel * vec4(aPosition, 1.0);
+}
+` + "`" + `;
+
+const fsSource = ` + "`" + `
+precision mediump float;
+varying vec3 vNormal;
+void main() {
+	vec3 n = normalize(vNormal);
+	vec3 light = normalize(vec3(0.4, 0.6, 1.0));
+	float diffuse = abs(dot(n, light));
+	vec3 color = vec3(0.35, 0.55, 0.85) * (0.25 + 0.75 * diffuse);
+	gl_FragColor = vec4(color, 1.0);
+}
+` + "`" + `;
+
+function compile(type, source) {
+	const s = gl.createShader(type);
+	gl.shaderSource(s, source);
+	gl.compileShader(s);
+	if (!gl.getShaderParameter(s, gl.COMPILE_STATUS)) {
+		throw new Error(gl.getShaderInfoLog(s));
+	}
+	return s;
+}
+
+const program = gl.createProgram();
+gl.attachShader(program, compile(gl.VERTEX_SHADER, vsSource));
+gl.attachShader(program, compile(gl.FRAGMENT_SHADER, fsSource));
+gl.linkProgram(program);
+gl.useProgram(program);
+
+const aPosition = gl.getAttribLocation(program, "aPosition");
+const aNormal = gl.getAttribLocation(program, "aNormal");
+const uModel = gl.getUniformLocation(program, "uModel");
+const uProjection = gl.getUniformLocation(program, "uProjection");
+
+const positionBuffer = gl.createBuffer();
+const normalBuffer = gl.createBuffer();
+
+let nVertices = 0;
+let center = [0, 0, 0];
+let radius = 1;
+let yaw = 0.6, pitch = -0.9, zoom = 1;
+let version = -1;
+
+// column-major 4x4 matrix helpers
+function multiply(a, b) {
+	const m = new Float32Array(16);
+	for (let i = 0; i < 4; i++) {
+		for (let j = 0; j < 4; j++) {
+			let s = 0;
+			for (let k = 0; k < 4; k++) {
+				s += a[k * 4 + j] * b[i * 4 + k];
+			}
+			m[i * 4 + j] = s;
+		}
+	}
+	return m;
+}
+
+function rotateX(a) {
+	const c = Math.cos(a), s = Math.sin(a);
+	return new Float32Array([1, 0, 0, 0, 0, c, s, 0, 0, -s, c, 0, 0, 0, 0, 1]);
+}
+
+function rotateZ(a) {
+	const c = Math.cos(a), s = Math.sin(a);
+	return new Float32Array([c, s, 0, 0, -s, c, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1]);
+}
+
+function translate(x, y, z) {
+	return new Float32Array([1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, x, y, z, 1]);
+}
+
+function perspective(fovy, aspect, near, far) {
+	const f = 1.0 / Math.tan(fovy / 2);
+	const nf = 1 / (near - far);
+	return new Float32Array([
+		f / aspect, 0, 0, 0,
+		0, f, 0, 0,
+		0, 0, (far + near) * nf, -1,
+		0, 0, 2 * far * near * nf, 0]);
+}
+
+function loadMesh(buffer) {
+	const dv = new DataView(buffer);
+	const n = dv.getUint32(80, true);
+	const positions = new Float32Array(n * 9);
+	const normals = new Float32Array(n * 9);
+	const min = [Infinity, Infinity, Infinity];
+	const max = [-Infinity, -Infinity, -Infinity];
+	let ofs = 84;
+	for (let i = 0; i < n; i++) {
+		const nx = dv.getFloat32(ofs, true);
+		const ny = dv.getFloat32(ofs + 4, true);
+		const nz = dv.getFloat32(ofs + 8, true);
+		for (let j = 0; j < 3; j++) {
+			for (let k = 0; k < 3; k++) {
+				const v = dv.getFloat32(ofs + 12 + j * 12 + k * 4, true);
+				positions[i * 9 + j * 3 + k] = v;
+				min[k] = Math.min(min[k], v);
+				max[k] = Math.max(max[k], v);
+			}
+			normals[i * 9 + j * 3 + 0] = nx;
+			normals[i * 9 + j * 3 + 1] = ny;
+			normals[i * 9 + j * 3 + 2] = nz;
+		}
+		ofs += 50;
+	}
+	gl.bindBuffer(gl.ARRAY_BUFFER, positionBuffer);
+	gl.bufferData(gl.ARRAY_BUFFER, positions, gl.STATIC_DRAW);
+	gl.bindBuffer(gl.ARRAY_BUFFER, normalBuffer);
+	gl.bufferData(gl.ARRAY_BUFFER, normals, gl.STATIC_DRAW);
+	nVertices = n * 3;
+	if (n > 0) {
+		center = [0, 1, 2].map(k => (min[k] + max[k]) / 2);
+		radius = Math.max(1e-6, 0.5 * Math.hypot(max[0] - min[0], max[1] - min[1], max[2] - min[2]));
+	}
+	info.textContent = "version " + version + ", " + n + " triangles";
+	draw();
+}
+
+function draw() {
+	const w = canvas.clientWidth, h = canvas.clientHeight;
+	if (canvas.width !== w || canvas.height !== h) {
+		canvas.width = w;
+		canvas.height = h;
+	}
+	gl.viewport(0, 0, w, h);
+	gl.clearColor(0.19, 0.19, 0.19, 1);
+	gl.enable(gl.DEPTH_TEST);
+	gl.clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT);
+	if (nVertices === 0) {
+		return;
+	}
+	const distance = 2.5 * radius * zoom;
+	let model = translate(-center[0], -center[1], -center[2]);
+	model = multiply(rotateZ(yaw), model);
+	model = multiply(rotateX(pitch), model);
+	model = multiply(translate(0, 0, -distance), model);
+	const projection = perspective(Math.PI / 4, w / h, distance * 0.01, distance + 4 * radius);
+	gl.uniformMatrix4fv(uModel, false, model);
+	gl.uniformMatrix4fv(uProjection, false, projection);
+	gl.bindBuffer(gl.ARRAY_BUFFER, positionBuffer);
+	gl.enableVertexAttribArray(aPosition);
+	gl.vertexAttribPointer(aPosition, 3, gl.FLOAT, false, 0, 0);
+	gl.bindBuffer(gl.ARRAY_BUFFER, normalBuffer);
+	gl.enableVertexAttribArray(aNormal);
+	gl.vertexAttribPointer(aNormal, 3, gl.FLOAT, false, 0, 0);
+	gl.drawArrays(gl.TRIANGLES, 0, nVertices);
+}
+
+function poll() {
+	fetch("/version", {cache: "no-store"})
+		.then(r => r.text())
+		.then(t => {
+			const v = parseInt(t, 10);
+			if (v !== version && v > 0) {
+				version = v;
+				return fetch("/mesh.stl", {cache: "no-store"})
+					.then(r => r.arrayBuffer())
+					.then(loadMesh);
+			}
+		})
+		.catch(e => { info.textContent = "disconnected"; })
+		.finally(() => setTimeout(poll, 1000));
+}
+
+let dragging = false, lastX = 0, lastY = 0;
+canvas.addEventListener("mousedown", e => { dragging = true; lastX = e.clientX; lastY = e.clientY; });
+window.addEventListener("mouseup", () => { dragging = false; });
+window.addEventListener("mousemove", e => {
+	if (!dragging) {
+		return;
+	}
+	yaw += 0.01 * (e.clientX - lastX);
+	pitch += 0.01 * (e.clientY - lastY);
+	lastX = e.clientX;
+	lastY = e.clientY;
+	draw();
+});
+canvas.addEventListener("wheel", e => {
+	e.preventDefault();
+	zoom *= Math.exp(0.001 * e.deltaY);
+	draw();
+}, {passive: false});
+window.addEventListener("resize", draw);
+
+poll();
+</script>
+</body>
+</html>
+`
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,74 @@
+//-----------------------------------------------------------------------------
+/*
+
+Render Progress and Cancellation
+
+The octree/quadtree renderers report progress as the number of smallest
+cells that have been processed (either meshed or skipped as empty) out of the
+total number of cells in the bounding cube/square. A context can be used to
+cancel long running renders.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import "context"
+
+//-----------------------------------------------------------------------------
+
+// ProgressFunc is called by a renderer to report progress (cells done / total cells).
+type ProgressFunc func(done, total int64)
+
+// progressSteps is the maximum number of progress reports per render.
+const progressSteps = 1000
+
+// renderProgress tracks the progress and cancellation of a render.
+type renderProgress struct {
+	ctx      context.Context // render context
+	fn       ProgressFunc    // progress callback (may be nil)
+	total    int64           // total number of cells
+	done     int64           // number of cells done
+	reported int64           // number of cells done at the last report
+	err      error           // the render has been cancelled
+}
+
+// newRenderProgress returns a progress tracker for a render.
+func newRenderProgress(ctx context.Context, fn ProgressFunc) *renderProgress {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &renderProgress{ctx: ctx, fn: fn}
+}
+
+// start sets the total number of cells and reports zero progress.
+func (p *renderProgress) start(total int64) {
+	p.total = total
+	p.done = 0
+	p.reported = 0
+	if p.fn != nil {
+		p.fn(0, total)
+	}
+}
+
+// add marks some cells as done, reporting progress if required.
+func (p *renderProgress) add(n int64) {
+	p.done += n
+	if p.fn == nil {
+		return
+	}
+	if p.done == p.total || (p.done-p.reported)*progressSteps >= p.total {
+		p.reported = p.done
+		p.fn(p.done, p.total)
+	}
+}
+
+// cancelled returns true if the render should stop.
+func (p *renderProgress) cancelled() bool {
+	if p.err == nil {
+		p.err = p.ctx.Err()
+	}
+	return p.err != nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,300 @@
+//-----------------------------------------------------------------------------
+/*
+
+Quad Dominant Remeshing
+
+Convert a marching cubes triangle mesh into a mostly-quad mesh.
+
+Pairs of adjacent triangles are merged into quads. Candidate quads must be
+convex and nearly planar, and are scored on their shape (corner angles close
+to 90 degrees) and on how well their edges follow the principal curvature
+directions of the surface. The curvature directions are estimated at each
+vertex from the variation of the vertex normals. Quads are then picked greedily
+in score order. Triangles that can't be paired are left as triangles.
+
+The result can be written as an OBJ file, which is useful for sculpting and
+subdivision surface tools that work best with quads.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+//-----------------------------------------------------------------------------
+
+// quadMinPlanarity is the minimum cosine of the angle between the triangle normals of a quad.
+var quadMinPlanarity = math.Cos(DtoR(30))
+
+// quadMinScore is the minimum score for a pair of triangles to be merged into a quad.
+const quadMinScore = 0.5
+
+// QuadMesh is a polygon mesh of quads and triangles.
+type QuadMesh struct {
+	Vertex []V3    // vertex positions
+	Face   [][]int // faces (3 or 4 vertex indices, counter-clockwise)
+}
+
+// Quads returns the number of quads in the mesh.
+func (m *QuadMesh) Quads() int {
+	n := 0
+	for _, f := range m.Face {
+		if len(f) == 4 {
+			n++
+		}
+	}
+	return n
+}
+
+//-----------------------------------------------------------------------------
+
+// indexMesh converts a triangle mesh to indexed form with shared vertices.
+// Degenerate triangles are removed.
+func indexMesh(mesh []*Triangle3) ([]V3, [][3]int) {
+	index := make(map[V3]int)
+	var vertex []V3
+	var face [][3]int
+	for _, t := range mesh {
+		var f [3]int
+		for i, v := range t.V {
+			k := v.Quantize()
+			j, ok := index[k]
+			if !ok {
+				j = len(vertex)
+				index[k] = j
+				vertex = append(vertex, v)
+			}
+			f[i] = j
+		}
+		if f[0] == f[1] || f[1] == f[2] || f[2] == f[0] {
+			continue
+		}
+		face = append(face, f)
+	}
+	return vertex, face
+}
+
+// principalDirections returns the direction of maximum curvature at each
+// vertex and the anisotropy of the curvature (0 = umbilic/flat, 1 = cylindrical).
+func principalDirections(vertex []V3, face [][3]int) ([]V3, []float64) {
+	n := len(vertex)
+	// area weighted vertex normals
+	normal := make([]V3, n)
+	neighbors := make([][]int, n)
+	for _, f := range face {
+		fn := vertex[f[1]].Sub(vertex[f[0]]).Cross(vertex[f[2]].Sub(vertex[f[0]]))
+		for i := 0; i < 3; i++ {
+			a, b := f[i], f[(i+1)%3]
+			normal[a] = normal[a].Add(fn)
+			neighbors[a] = append(neighbors[a], b)
+		}
+	}
+	for i := range normal {
+		if normal[i].Length() > 0 {
+			normal[i] = normal[i].Normalize()
+		}
+	}
+	dir := make([]V3, n)
+	aniso := make([]float64, n)
+	for i := range vertex {
+		ni := normal[i]
+		// tangent plane basis
+		u := V3{1, 0, 0}
+		if Abs(ni.X) > 0.9 {
+			u = V3{0, 1, 0}
+		}
+		u = u.Sub(ni.MulScalar(u.Dot(ni))).Normalize()
+		v := ni.Cross(u)
+		// least squares fit of the shape operator: S * e = dn
+		var ee [3]float64     // sum of e e^T (symmetric)
+		var dne [2][2]float64 // sum of dn e^T
+		for _, j := range neighbors[i] {
+			e := vertex[j].Sub(vertex[i])
+			dn := normal[j].Sub(ni)
+			ea, eb := e.Dot(u), e.Dot(v)
+			da, db := dn.Dot(u), dn.Dot(v)
+			ee[0] += ea * ea
+			ee[1] += ea * eb
+			ee[2] += eb * eb
+			dne[0][0] += da * ea
+			dne[0][1] += da * eb
+			dne[1][0] += db * ea
+			dne[1][1] += db * eb
+		}
+		det := ee[0]*ee[2] - ee[1]*ee[1]
+		if Abs(det) < epsilon {
+			dir[i] = u
+			continue
+		}
+		// S = dne * inverse(ee), symmetrized
+		i00, i01, i11 := ee[2]/det, -ee[1]/det, ee[0]/det
+		s00 := dne[0][0]*i00 + dne[0][1]*i01
+		s01 := dne[0][0]*i01 + dne[0][1]*i11
+		s10 := dne[1][0]*i00 + dne[1][1]*i01
+		s11 := dne[1][0]*i01 + dne[1][1]*i11
+		s01 = 0.5 * (s01 + s10)
+		// eigen decomposition of the symmetric 2x2 shape operator
+		theta := 0.5 * math.Atan2(2*s01, s00-s11)
+		d := math.Sqrt(0.25*(s00-s11)*(s00-s11) + s01*s01)
+		m := 0.5 * (s00 + s11)
+		k1, k2 := m+d, m-d
+		dir[i] = u.MulScalar(math.Cos(theta)).Add(v.MulScalar(math.Sin(theta)))
+		if Abs(k1)+Abs(k2) > epsilon {
+			aniso[i] = Abs(k1-k2) / (Abs(k1) + Abs(k2))
+		}
+	}
+	return dir, aniso
+}
+
+//-----------------------------------------------------------------------------
+
+type quadCandidate struct {
+	f0, f1 int    // triangle indices
+	quad   [4]int // quad vertices
+	score  float64
+}
+
+// quadScore returns the score for a quad, or false if the quad isn't acceptable.
+func quadScore(vertex []V3, q [4]int, n0, n1 V3, dir []V3, aniso []float64) (float64, bool) {
+	// planarity
+	if n0.Dot(n1) < quadMinPlanarity {
+		return 0, false
+	}
+	n := n0.Add(n1).Normalize()
+	// local frame in the plane of the quad
+	p := [4]V3{vertex[q[0]], vertex[q[1]], vertex[q[2]], vertex[q[3]]}
+	u := p[1].Sub(p[0])
+	u = u.Sub(n.MulScalar(u.Dot(n))).Normalize()
+	v := n.Cross(u)
+	angle := func(d V3) float64 {
+		return math.Atan2(d.Dot(v), d.Dot(u))
+	}
+	// convexity and corner angles
+	shape := 1.0
+	for i := 0; i < 4; i++ {
+		e0 := p[(i+1)%4].Sub(p[i])
+		e1 := p[(i+3)%4].Sub(p[i])
+		if e0.Cross(e1).Dot(n) <= 0 {
+			return 0, false
+		}
+		a := math.Acos(Clamp(e0.Normalize().Dot(e1.Normalize()), -1, 1))
+		shape = Min(shape, 1-Abs(a-0.5*Pi)/(0.5*Pi))
+	}
+	// average the 4-fold symmetric curvature direction field over the quad
+	var c, s, w float64
+	for i := 0; i < 4; i++ {
+		a := 4 * angle(dir[q[i]])
+		c += aniso[q[i]] * math.Cos(a)
+		s += aniso[q[i]] * math.Sin(a)
+		w += 0.25 * aniso[q[i]]
+	}
+	if w < epsilon {
+		return shape, true
+	}
+	theta := 0.25 * math.Atan2(s, c)
+	// alignment of the quad edges with the direction field
+	align := 0.0
+	for i := 0; i < 4; i++ {
+		phi := angle(p[(i+1)%4].Sub(p[i]))
+		align += 0.125 * (1 + math.Cos(4*(phi-theta)))
+	}
+	return (1-w)*shape + w*Min(shape, align), true
+}
+
+// QuadRemesh converts a triangle mesh into a quad dominant mesh.
+func QuadRemesh(mesh []*Triangle3) *QuadMesh {
+	vertex, face := indexMesh(mesh)
+	dir, aniso := principalDirections(vertex, face)
+
+	normal := make([]V3, len(face))
+	for i, f := range face {
+		normal[i] = vertex[f[1]].Sub(vertex[f[0]]).Cross(vertex[f[2]].Sub(vertex[f[0]])).Normalize()
+	}
+
+	// find the triangles on each side of each edge
+	type edge struct{ a, b int }
+	edges := make(map[edge]int)
+	var candidates []quadCandidate
+	for i, f := range face {
+		for j := 0; j < 3; j++ {
+			edges[edge{f[j], f[(j+1)%3]}] = i
+		}
+	}
+	for i, f := range face {
+		for j := 0; j < 3; j++ {
+			a, b, c := f[j], f[(j+1)%3], f[(j+2)%3]
+			k, ok := edges[edge{b, a}]
+			if !ok || k <= i {
+				// boundary edge, or the pair has already been considered
+				continue
+			}
+			// third vertex of the adjacent triangle
+			g := face[k]
+			d := g[0] + g[1] + g[2] - a - b
+			if d == c {
+				continue
+			}
+			q := [4]int{a, d, b, c}
+			if score, ok := quadScore(vertex, q, normal[i], normal[k], dir, aniso); ok && score >= quadMinScore {
+				candidates = append(candidates, quadCandidate{i, k, q, score})
+			}
+		}
+	}
+
+	// greedily pick the best quads
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	used := make([]bool, len(face))
+	m := &QuadMesh{Vertex: vertex}
+	for _, c := range candidates {
+		if used[c.f0] || used[c.f1] {
+			continue
+		}
+		used[c.f0] = true
+		used[c.f1] = true
+		q := c.quad
+		m.Face = append(m.Face, q[:])
+	}
+	for i, f := range face {
+		if !used[i] {
+			m.Face = append(m.Face, []int{f[0], f[1], f[2]})
+		}
+	}
+	return m
+}
+
+//-----------------------------------------------------------------------------
+
+// RenderQuadOBJ renders an SDF3 as a quad dominant mesh OBJ file (uses octree sampling).
+func RenderQuadOBJ(
+	s SDF3, //sdf3 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	path string, //path to filename
+) error {
+	mesh := RenderMesh(s, meshCells)
+	m := QuadRemesh(mesh)
+	fmt.Printf("rendering %s (%d quads, %d triangles)\n", path, m.Quads(), len(m.Face)-m.Quads())
+	if err := m.SaveOBJ(path); err != nil {
+		return err
+	}
+	cfg := getMetadata()
+	if !cfg.enable {
+		return nil
+	}
+	var stats meshStats
+	stats.addMesh(mesh)
+	bb := s.BoundingBox()
+	md := cfg.newMetadata(path, &bb, meshCells, bb.Size().MaxComponent()/float64(meshCells))
+	md.Volume = Abs(stats.volume)
+	md.Quads = m.Quads()
+	md.Triangles = len(m.Face) - md.Quads
+	return md.save(path)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,263 @@
+//-----------------------------------------------------------------------------
+/*
+
+3D Convex Hull
+
+ConvexHull3D finds the exact convex hull of a set of points with the
+quickhull algorithm:
+
+1) Start with a tetrahedron of extreme points.
+2) Each remaining point is assigned to a face it is in front of. Points that
+are behind all faces are inside the hull and are dropped.
+3) For a face with points in front of it, the point furthest from the face
+(the eye point) is on the hull. The faces visible from the eye point are
+removed, and their boundary (the horizon) is joined to the eye point with new
+faces. The points of the removed faces are assigned to the new faces.
+4) Repeat until no points are in front of any face.
+
+See: The Quickhull Algorithm for Convex Hulls, C. Bradford Barber, David P.
+Dobkin, Hannu Huhdanpaa, 1996.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+// qhFace is a face of a quickhull.
+type qhFace struct {
+	v       TriangleI // counter-clockwise viewed from outside
+	n       V3        // unit normal
+	d       float64   // plane offset
+	outside []int     // points in front of the face
+	dead    bool
+}
+
+// quickHull is the state of a quickhull.
+type quickHull struct {
+	vs    V3Set
+	eps   float64
+	face  []*qhFace
+	owner map[EdgeI]int // face of each directed edge
+}
+
+// addFace adds a face and returns its index.
+func (h *quickHull) addFace(a, b, c int) int {
+	n := h.vs[b].Sub(h.vs[a]).Cross(h.vs[c].Sub(h.vs[a])).Normalize()
+	f := &qhFace{v: TriangleI{a, b, c}, n: n, d: n.Dot(h.vs[a])}
+	k := len(h.face)
+	h.face = append(h.face, f)
+	for j := 0; j < 3; j++ {
+		h.owner[EdgeI{f.v[j], f.v[(j+1)%3]}] = k
+	}
+	return k
+}
+
+// distance returns the signed distance of a point in front of a face.
+func (h *quickHull) distance(f *qhFace, i int) float64 {
+	return f.n.Dot(h.vs[i]) - f.d
+}
+
+// assign assigns points to the first of a set of faces they are in front of.
+func (h *quickHull) assign(points []int, faces []int) {
+	for _, i := range points {
+		for _, k := range faces {
+			if f := h.face[k]; h.distance(f, i) > h.eps {
+				f.outside = append(f.outside, i)
+				break
+			}
+		}
+	}
+}
+
+// initial returns a tetrahedron of extreme points, or false if the points
+// are all (nearly) in a plane.
+func (h *quickHull) initial() ([4]int, bool) {
+	var t [4]int
+	// the extreme points on each axis
+	var ext [6]int
+	axis := func(v V3) [3]float64 {
+		return [3]float64{v.X, v.Y, v.Z}
+	}
+	for i, v := range h.vs {
+		x := axis(v)
+		for j := 0; j < 3; j++ {
+			if x[j] < axis(h.vs[ext[2*j]])[j] {
+				ext[2*j] = i
+			}
+			if x[j] > axis(h.vs[ext[2*j+1]])[j] {
+				ext[2*j+1] = i
+			}
+		}
+	}
+	// the most distant pair of extreme points
+	best := -1.0
+	for _, i := range ext {
+		for _, j := range ext {
+			if d := h.vs[i].Sub(h.vs[j]).Length2(); d > best {
+				best, t[0], t[1] = d, i, j
+			}
+		}
+	}
+	if math.Sqrt(best) <= h.eps {
+		return t, false
+	}
+	// the furthest point from the line
+	a := h.vs[t[0]]
+	u := h.vs[t[1]].Sub(a).Normalize()
+	best = -1.0
+	for i, v := range h.vs {
+		if d := v.Sub(a).Cross(u).Length(); d > best {
+			best, t[2] = d, i
+		}
+	}
+	if best <= h.eps {
+		return t, false
+	}
+	// the furthest point from the plane
+	n := h.vs[t[1]].Sub(a).Cross(h.vs[t[2]].Sub(a)).Normalize()
+	best = -1.0
+	for i, v := range h.vs {
+		if d := Abs(v.Sub(a).Dot(n)); d > best {
+			best, t[3] = d, i
+		}
+	}
+	if best <= h.eps {
+		return t, false
+	}
+	if h.vs[t[3]].Sub(a).Dot(n) > 0 {
+		// the 4th point is in front of the first face
+		t[1], t[2] = t[2], t[1]
+	}
+	return t, true
+}
+
+// add adds the eye point to the hull, replacing the faces it can see.
+func (h *quickHull) add(eye, start int) {
+	// find the visible faces
+	visible := []int{start}
+	h.face[start].dead = true
+	var horizon []EdgeI
+	for i := 0; i < len(visible); i++ {
+		f := h.face[visible[i]]
+		for j := 0; j < 3; j++ {
+			e := EdgeI{f.v[j], f.v[(j+1)%3]}
+			k := h.owner[EdgeI{e[1], e[0]}]
+			g := h.face[k]
+			if g.dead {
+				continue
+			}
+			if h.distance(g, eye) > h.eps {
+				g.dead = true
+				visible = append(visible, k)
+			} else {
+				horizon = append(horizon, e)
+			}
+		}
+	}
+	// the points of the visible faces
+	var points []int
+	for _, k := range visible {
+		f := h.face[k]
+		for _, i := range f.outside {
+			if i != eye {
+				points = append(points, i)
+			}
+		}
+		f.outside = nil
+		for j := 0; j < 3; j++ {
+			delete(h.owner, EdgeI{f.v[j], f.v[(j+1)%3]})
+		}
+	}
+	// join the horizon to the eye point
+	faces := make([]int, len(horizon))
+	for i, e := range horizon {
+		faces[i] = h.addFace(e[0], e[1], eye)
+	}
+	h.assign(points, faces)
+}
+
+// ConvexHull3D returns the convex hull of a set of points as a triangle mesh
+// (with outward facing triangles) and an SDF3. The SDF3 is the intersection of
+// the face planes, the distance is exact inside the hull and a lower bound
+// outside it.
+func ConvexHull3D(vs V3Set) ([]*Triangle3, SDF3, error) {
+	if len(vs) < 4 {
+		return nil, nil, errors.New("less than 4 points")
+	}
+	h := &quickHull{
+		vs:    vs,
+		owner: make(map[EdgeI]int),
+	}
+	// rounding tolerance for the plane distances
+	var m V3
+	for _, v := range vs {
+		m = m.Max(v.Abs())
+	}
+	h.eps = 3 * machineEpsilon * 2 * (m.X + m.Y + m.Z)
+
+	t, ok := h.initial()
+	if !ok {
+		return nil, nil, errors.New("the points are coplanar")
+	}
+	faces := []int{
+		h.addFace(t[0], t[1], t[2]),
+		h.addFace(t[0], t[3], t[1]),
+		h.addFace(t[1], t[3], t[2]),
+		h.addFace(t[2], t[3], t[0]),
+	}
+	points := make([]int, 0, len(vs))
+	for i := range vs {
+		if i != t[0] && i != t[1] && i != t[2] && i != t[3] {
+			points = append(points, i)
+		}
+	}
+	h.assign(points, faces)
+
+	for k := 0; k < len(h.face); k++ {
+		f := h.face[k]
+		if f.dead || len(f.outside) == 0 {
+			continue
+		}
+		// the furthest point in front of the face
+		eye, best := -1, 0.0
+		for _, i := range f.outside {
+			if d := h.distance(f, i); d > best {
+				eye, best = i, d
+			}
+		}
+		h.add(eye, k)
+	}
+
+	var mesh []*Triangle3
+	var vertex []V3
+	var normal []V3
+	seen := make(map[int]bool)
+	planes := make(map[V3]bool)
+	for _, f := range h.face {
+		if f.dead {
+			continue
+		}
+		mesh = append(mesh, NewTriangle3(vs[f.v[0]], vs[f.v[1]], vs[f.v[2]]))
+		for _, i := range f.v {
+			if !seen[i] {
+				seen[i] = true
+				vertex = append(vertex, vs[i])
+			}
+		}
+		// coplanar faces have one plane
+		if k := f.n.Quantize(); !planes[k] {
+			planes[k] = true
+			normal = append(normal, f.n)
+		}
+	}
+	return mesh, hullPlanes(vertex, normal), nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,354 @@
+//-----------------------------------------------------------------------------
+/*
+
+Ray Marching
+
+Render an image of an SDF3 directly by sphere tracing rays from a camera.
+No meshing is required.
+
+Turntable animations rotate the camera about the vertical axis of the target
+and can be output as an animated GIF or as a PNG frame sequence (for use with
+video encoders, e.g. ffmpeg -i frame%03d.png turntable.mp4).
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"math"
+	"os"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+
+const rmMaxSteps = 256   // maximum number of ray marching steps
+const rmStepFactor = 0.9 // step fraction (< 1 handles non-exact distance fields)
+
+//-----------------------------------------------------------------------------
+
+// Camera3 is a pinhole camera for ray marching.
+type Camera3 struct {
+	Eye    V3      // camera position
+	Target V3      // point the camera looks at
+	Up     V3      // up direction
+	Fov    float64 // vertical field of view (radians)
+}
+
+// NewCamera3 returns a camera looking at a bounding box from a direction.
+// The distance from the box is chosen so the whole box is in view.
+func NewCamera3(bb Box3, dir V3, fov float64) Camera3 {
+	r := 0.5 * bb.Size().Length()
+	d := r / math.Sin(0.5*fov)
+	c := bb.Center()
+	up := V3{0, 0, 1}
+	if Abs(dir.Normalize().Z) > 0.99 {
+		up = V3{0, 1, 0}
+	}
+	return Camera3{
+		Eye:    c.Add(dir.Normalize().MulScalar(d)),
+		Target: c,
+		Up:     up,
+		Fov:    fov,
+	}
+}
+
+// DefaultCamera3 returns a camera with an isometric style view of a bounding box.
+func DefaultCamera3(bb Box3) Camera3 {
+	return NewCamera3(bb, V3{1, -1.5, 1}, DtoR(30))
+}
+
+// ray returns the origin and direction of the ray through normalized image coordinates.
+// u and v are in [-1,1], with v = 1 at the top of the image.
+func (c *Camera3) ray(u, v, aspect float64) (V3, V3) {
+	w := c.Target.Sub(c.Eye).Normalize()
+	x := w.Cross(c.Up).Normalize()
+	y := x.Cross(w)
+	k := math.Tan(0.5 * c.Fov)
+	d := w.Add(x.MulScalar(u * k * aspect)).Add(y.MulScalar(v * k))
+	return c.Eye, d.Normalize()
+}
+
+//-----------------------------------------------------------------------------
+
+// rayBox returns the near/far intersection distances of a ray with a box.
+func rayBox(ro, rd V3, bb Box3) (float64, float64, bool) {
+	t0 := 0.0
+	t1 := math.MaxFloat64
+	o := [3]float64{ro.X, ro.Y, ro.Z}
+	d := [3]float64{rd.X, rd.Y, rd.Z}
+	bmin := [3]float64{bb.Min.X, bb.Min.Y, bb.Min.Z}
+	bmax := [3]float64{bb.Max.X, bb.Max.Y, bb.Max.Z}
+	for i := 0; i < 3; i++ {
+		if Abs(d[i]) < epsilon {
+			if o[i] < bmin[i] || o[i] > bmax[i] {
+				return 0, 0, false
+			}
+			continue
+		}
+		inv := 1 / d[i]
+		tn := (bmin[i] - o[i]) * inv
+		tf := (bmax[i] - o[i]) * inv
+		if tn > tf {
+			tn, tf = tf, tn
+		}
+		t0 = Max(t0, tn)
+		t1 = Min(t1, tf)
+		if t0 > t1 {
+			return 0, 0, false
+		}
+	}
+	return t0, t1, true
+}
+
+// RayMarch sphere traces a ray against an SDF3.
+// It returns the distance along the ray to the surface and true if the surface was hit.
+func RayMarch(s SDF3, ro, rd V3, eps float64) (float64, bool) {
+	// only march within the bounding box
+	bb := s.BoundingBox().ScaleAboutCenter(1.01)
+	t, tmax, ok := rayBox(ro, rd, bb)
+	if !ok {
+		return 0, false
+	}
+	for i := 0; i < rmMaxSteps && t <= tmax; i++ {
+		d := s.Evaluate(ro.Add(rd.MulScalar(t)))
+		if d < eps {
+			return t, true
+		}
+		t += Max(d*rmStepFactor, eps)
+	}
+	return 0, false
+}
+
+// Normal3 returns the surface normal of an SDF3 at a point (central differences).
+func Normal3(s SDF3, p V3, eps float64) V3 {
+	dx := s.Evaluate(p.Add(V3{eps, 0, 0})) - s.Evaluate(p.Sub(V3{eps, 0, 0}))
+	dy := s.Evaluate(p.Add(V3{0, eps, 0})) - s.Evaluate(p.Sub(V3{0, eps, 0}))
+	dz := s.Evaluate(p.Add(V3{0, 0, eps})) - s.Evaluate(p.Sub(V3{0, 0, eps}))
+	n := V3{dx, dy, dz}
+	if n.Length2() == 0 {
+		return V3{0, 0, 1}
+	}
+	return n.Normalize()
+}
+
+//-----------------------------------------------------------------------------
+
+// RayMarchImage renders a gray scale image of an SDF3 with a headlight.
+func RayMarchImage(s SDF3, cam Camera3, pixels V2i) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, pixels[0], pixels[1]))
+	aspect := float64(pixels[0]) / float64(pixels[1])
+	eps := s.BoundingBox().Size().MaxComponent() * 1e-4
+	var wg sync.WaitGroup
+	for y := 0; y < pixels[1]; y++ {
+		wg.Add(1)
+		go func(y int) {
+			defer wg.Done()
+			v := 1 - 2*(float64(y)+0.5)/float64(pixels[1])
+			for x := 0; x < pixels[0]; x++ {
+				u := 2*(float64(x)+0.5)/float64(pixels[0]) - 1
+				ro, rd := cam.ray(u, v, aspect)
+				t, hit := RayMarch(s, ro, rd, eps)
+				if !hit {
+					continue
+				}
+				n := Normal3(s, ro.Add(rd.MulScalar(t)), eps)
+				k := 0.15 + 0.85*Max(-n.Dot(rd), 0)
+				img.SetGray(x, y, color.Gray{uint8(255 * Clamp(k, 0, 1))})
+			}
+		}(y)
+	}
+	wg.Wait()
+	return img
+}
+
+//-----------------------------------------------------------------------------
+
+// RayMarchParms defines the parameters for a ray marched image.
+type RayMarchParms struct {
+	Camera     Camera3    // camera view
+	Pixels     V2i        // image size
+	Lights     []V3       // directional lights (vectors towards the light)
+	Ambient    float64    // ambient light level [0,1]
+	Specular   float64    // specular highlight level [0,1]
+	Shadows    bool       // cast soft shadows
+	Color      color.RGBA // surface color
+	Background color.RGBA // background color
+}
+
+// DefaultRayMarchParms returns the ray march parameters for a default view of an SDF3.
+func DefaultRayMarchParms(s SDF3, pixels V2i) *RayMarchParms {
+	return &RayMarchParms{
+		Camera:     DefaultCamera3(s.BoundingBox()),
+		Pixels:     pixels,
+		Lights:     []V3{{1, -2, 3}, {-2, 1, 1}},
+		Ambient:    0.15,
+		Specular:   0.3,
+		Shadows:    true,
+		Color:      color.RGBA{90, 140, 210, 255},
+		Background: color.RGBA{48, 48, 48, 255},
+	}
+}
+
+// softShadow returns the light visibility [0,1] from a surface point towards a light.
+func softShadow(s SDF3, p, l V3, tmin, tmax float64) float64 {
+	const k = 16.0 // penumbra sharpness
+	res := 1.0
+	t := tmin
+	for i := 0; i < rmMaxSteps && t < tmax; i++ {
+		d := s.Evaluate(p.Add(l.MulScalar(t)))
+		if d < tmin*0.1 {
+			return 0
+		}
+		res = Min(res, k*d/t)
+		t += Max(d*rmStepFactor, tmin*0.1)
+	}
+	return Clamp(res, 0, 1)
+}
+
+// shade returns the color of a surface point.
+func (k *RayMarchParms) shade(s SDF3, p, n, rd V3, eps float64) color.RGBA {
+	tmax := s.BoundingBox().Size().Length()
+	light := k.Ambient
+	for _, l := range k.Lights {
+		l = l.Normalize()
+		diffuse := Max(n.Dot(l), 0)
+		if diffuse == 0 {
+			continue
+		}
+		if k.Shadows {
+			// offset the start point to avoid self shadowing
+			q := p.Add(n.MulScalar(4 * eps))
+			diffuse *= softShadow(s, q, l, 4*eps, tmax)
+		}
+		// blinn-phong specular
+		h := l.Sub(rd).Normalize()
+		specular := k.Specular * math.Pow(Max(n.Dot(h), 0), 32) * Sign(diffuse)
+		light += diffuse + specular
+	}
+	light /= Max(float64(len(k.Lights)), 1)*0.75 + k.Ambient
+	c := func(x uint8) uint8 {
+		return uint8(Clamp(float64(x)*light, 0, 255))
+	}
+	return color.RGBA{c(k.Color.R), c(k.Color.G), c(k.Color.B), 255}
+}
+
+// RayMarchRGBA renders a lit color image of an SDF3.
+func RayMarchRGBA(s SDF3, k *RayMarchParms) *image.RGBA {
+	nx, ny := k.Pixels[0], k.Pixels[1]
+	img := image.NewRGBA(image.Rect(0, 0, nx, ny))
+	draw.Draw(img, img.Bounds(), &image.Uniform{k.Background}, image.ZP, draw.Src)
+	aspect := float64(nx) / float64(ny)
+	eps := s.BoundingBox().Size().MaxComponent() * 1e-4
+	var wg sync.WaitGroup
+	for y := 0; y < ny; y++ {
+		wg.Add(1)
+		go func(y int) {
+			defer wg.Done()
+			v := 1 - 2*(float64(y)+0.5)/float64(ny)
+			for x := 0; x < nx; x++ {
+				u := 2*(float64(x)+0.5)/float64(nx) - 1
+				ro, rd := k.Camera.ray(u, v, aspect)
+				t, hit := RayMarch(s, ro, rd, eps)
+				if !hit {
+					continue
+				}
+				p := ro.Add(rd.MulScalar(t))
+				n := Normal3(s, p, eps)
+				img.SetRGBA(x, y, k.shade(s, p, n, rd, eps))
+			}
+		}(y)
+	}
+	wg.Wait()
+	return img
+}
+
+//-----------------------------------------------------------------------------
+
+// savePNG saves an image as a PNG file.
+func savePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+// RenderRayMarchPNG renders an SDF3 to a PNG file using ray marching.
+func RenderRayMarchPNG(s SDF3, k *RayMarchParms, path string) error {
+	fmt.Printf("rendering %s (%dx%d)\n", path, k.Pixels[0], k.Pixels[1])
+	return savePNG(path, RayMarchRGBA(s, k))
+}
+
+//-----------------------------------------------------------------------------
+// Turntable Animation
+
+// turntableCamera returns the camera rotated by theta about the vertical axis of the target.
+func turntableCamera(c Camera3, theta float64) Camera3 {
+	m := Translate3d(c.Target).Mul(RotateZ(theta)).Mul(Translate3d(c.Target.Neg()))
+	c.Eye = m.MulPosition(c.Eye)
+	return c
+}
+
+// TurntableFrames renders the frames of a full rotation of an SDF3.
+func TurntableFrames(s SDF3, k *RayMarchParms, frames int) []*image.RGBA {
+	imgs := make([]*image.RGBA, frames)
+	kf := *k
+	for i := range imgs {
+		kf.Camera = turntableCamera(k.Camera, Tau*float64(i)/float64(frames))
+		imgs[i] = RayMarchRGBA(s, &kf)
+	}
+	return imgs
+}
+
+// RenderTurntableGIF renders a rotating view of an SDF3 to an animated GIF file.
+func RenderTurntableGIF(
+	s SDF3, // sdf3 to render
+	k *RayMarchParms, // ray march parameters
+	frames int, // number of frames for a full rotation
+	delay int, // delay between frames (100ths of a second)
+	path string, // path to filename
+) error {
+	fmt.Printf("rendering %s (%dx%d, %d frames)\n", path, k.Pixels[0], k.Pixels[1], frames)
+	anim := gif.GIF{}
+	for _, img := range TurntableFrames(s, k, frames) {
+		p := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(p, img.Bounds(), img, image.ZP)
+		anim.Image = append(anim.Image, p)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return gif.EncodeAll(f, &anim)
+}
+
+// RenderTurntablePNG renders a rotating view of an SDF3 to a sequence of PNG files.
+// The path is a format string for the frame number, e.g. "frame%03d.png".
+func RenderTurntablePNG(
+	s SDF3, // sdf3 to render
+	k *RayMarchParms, // ray march parameters
+	frames int, // number of frames for a full rotation
+	path string, // format string for the frame filenames
+) error {
+	fmt.Printf("rendering %s (%dx%d, %d frames)\n", path, k.Pixels[0], k.Pixels[1], frames)
+	for i, img := range TurntableFrames(s, k, frames) {
+		if err := savePNG(fmt.Sprintf(path, i), img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
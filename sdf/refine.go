@@ -0,0 +1,447 @@
+//-----------------------------------------------------------------------------
+/*
+
+Delaunay Refinement
+
+Quality triangle meshes of 2D regions, with Ruppert's algorithm:
+
+1) The constrained delaunay triangulation of the region outlines is the
+starting mesh.
+2) A segment (an outline edge) with a vertex inside its diametral circle is
+encroached, and is split at its middle.
+3) A triangle with an angle less than the minimum angle, or an area greater
+than the maximum area, is split by inserting its circumcenter. If the
+circumcenter encroaches on segments they are split instead.
+
+A segment joined to another segment at its end is split on circular shells
+about the shared vertex, so segments meeting at small angles don't split each
+other forever.
+
+See:
+Jim Ruppert, A Delaunay Refinement Algorithm for Quality 2-Dimensional Mesh
+Generation, Journal of Algorithms, 1995.
+Jonathan Richard Shewchuk, Delaunay Refinement Algorithms for Triangular Mesh
+Generation, Computational Geometry: Theory and Applications, 2002.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+// maxRefineVertices limits the size of a refined mesh.
+const maxRefineVertices = 1 << 18
+
+// mesher refines a constrained delaunay triangulation.
+type mesher struct {
+	*cdt
+	in      []bool // the triangle is inside the region
+	nInput  int    // number of input vertices
+	cosMin  float64
+	maxArea float64
+	segs    []EdgeI         // segments to check for encroachment
+	bad     []TriangleI     // triangles to check for quality
+	orig    map[EdgeI]EdgeI // input segment for each segment
+	on      map[int]EdgeI   // input segment for each split vertex
+}
+
+// addTriangle adds a triangle and returns its index.
+func (m *mesher) addTriangle(t TriangleI, in bool) int {
+	k := len(m.ts)
+	m.ts = append(m.ts, t)
+	m.in = append(m.in, in)
+	for j := 0; j < 3; j++ {
+		m.tri[EdgeI{t[j], t[(j+1)%3]}] = k
+	}
+	m.changed = append(m.changed, k)
+	return k
+}
+
+// addVertex adds a vertex and returns its index.
+func (m *mesher) addVertex(p V2) int {
+	m.vs = append(m.vs, p)
+	return len(m.vs) - 1
+}
+
+// splitTriangle splits the k-th triangle at the vertex p.
+func (m *mesher) splitTriangle(k, p int) {
+	t := m.ts[k]
+	m.setTriangle(k, TriangleI{t[0], t[1], p})
+	m.addTriangle(TriangleI{t[1], t[2], p}, m.in[k])
+	m.addTriangle(TriangleI{t[2], t[0], p}, m.in[k])
+	m.legalize([]EdgeI{{t[0], t[1]}, {t[1], t[2]}, {t[2], t[0]}})
+}
+
+// splitEdge splits the edge u-v, and the triangles on each side of it, at
+// the vertex p.
+func (m *mesher) splitEdge(u, v, p int) {
+	if s := (EdgeI{u, v}).undirected(); m.fixed[s] {
+		o := m.orig[s]
+		delete(m.fixed, s)
+		delete(m.orig, s)
+		for _, e := range []EdgeI{{u, p}, {p, v}} {
+			m.fixed[e.undirected()] = true
+			m.orig[e.undirected()] = o
+		}
+		m.on[p] = o
+	}
+	var edges []EdgeI
+	if k, ok := m.tri[EdgeI{u, v}]; ok {
+		w := m.ts[k].third(u, v)
+		m.setTriangle(k, TriangleI{u, p, w})
+		m.addTriangle(TriangleI{p, v, w}, m.in[k])
+		edges = append(edges, EdgeI{v, w}, EdgeI{w, u})
+	}
+	if k, ok := m.tri[EdgeI{v, u}]; ok {
+		x := m.ts[k].third(u, v)
+		m.setTriangle(k, TriangleI{v, p, x})
+		m.addTriangle(TriangleI{p, u, x}, m.in[k])
+		edges = append(edges, EdgeI{u, x}, EdgeI{x, v})
+	}
+	m.legalize(edges)
+}
+
+// locate walks from the k-th triangle to the triangle containing a point.
+// It returns false if the point is outside the triangulation.
+func (m *mesher) locate(p V2, k int) (int, bool) {
+	for n := 0; n < len(m.ts); n++ {
+		t := m.ts[k]
+		next := -1
+		for j := 0; j < 3; j++ {
+			i := (j + n) % 3
+			u, v := t[i], t[(i+1)%3]
+			if orient2d(m.vs[u], m.vs[v], p) < 0 {
+				x, ok := m.tri[EdgeI{v, u}]
+				if !ok {
+					return -1, false
+				}
+				next = x
+				break
+			}
+		}
+		if next < 0 {
+			return k, true
+		}
+		k = next
+	}
+	return -1, false
+}
+
+// encroached returns true if a point is inside the diametral circle of a
+// segment.
+func (m *mesher) encroached(s EdgeI, p V2) bool {
+	return m.vs[s[0]].Sub(p).Dot(m.vs[s[1]].Sub(p)) < 0
+}
+
+// isEncroached returns true if a segment has the apex of a triangle on it
+// inside its diametral circle.
+func (m *mesher) isEncroached(s EdgeI) bool {
+	for _, e := range []EdgeI{s, {s[1], s[0]}} {
+		if k, ok := m.tri[e]; ok && m.encroached(s, m.vs[m.ts[k].third(s[0], s[1])]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBad returns true if a triangle has a small angle or a large area.
+func (m *mesher) isBad(t TriangleI) bool {
+	a, b, c := m.vs[t[0]], m.vs[t[1]], m.vs[t[2]]
+	if m.maxArea > 0 && 0.5*orient2d(a, b, c) > m.maxArea {
+		return true
+	}
+	// the smallest angle is opposite the shortest edge
+	l := [3]float64{b.Sub(c).Length2(), c.Sub(a).Length2(), a.Sub(b).Length2()}
+	i := 0
+	for j := 1; j < 3; j++ {
+		if l[j] < l[i] {
+			i = j
+		}
+	}
+	l0, l1, l2 := l[i], l[(i+1)%3], l[(i+2)%3]
+	// law of cosines, a triangle at the minimum angle is not split, that
+	// would be repeated forever (E.g. 30-60-90 triangles)
+	cos := (l1 + l2 - l0) / (2 * math.Sqrt(l1*l2))
+	if cos <= m.cosMin+tolerance {
+		return false
+	}
+	return !m.smallInputAngle(t[(i+1)%3], t[(i+2)%3])
+}
+
+// smallInputAngle returns true if the vertices of an edge are on two input
+// segments that meet at less than 60 degrees. The small angle of a triangle
+// with the edge comes from the input and can't be removed by splitting.
+func (m *mesher) smallInputAngle(b, c int) bool {
+	sb, okb := m.on[b]
+	sc, okc := m.on[c]
+	if !okb || !okc || sb == sc {
+		return false
+	}
+	var q, qb, qc int
+	switch {
+	case sb[0] == sc[0]:
+		q, qb, qc = sb[0], sb[1], sc[1]
+	case sb[0] == sc[1]:
+		q, qb, qc = sb[0], sb[1], sc[0]
+	case sb[1] == sc[0]:
+		q, qb, qc = sb[1], sb[0], sc[1]
+	case sb[1] == sc[1]:
+		q, qb, qc = sb[1], sb[0], sc[0]
+	default:
+		return false
+	}
+	x := m.vs[qb].Sub(m.vs[q])
+	y := m.vs[qc].Sub(m.vs[q])
+	return x.Dot(y) > 0.5*x.Length()*y.Length()
+}
+
+// splitSegment splits a segment. A segment with one input vertex that is
+// shared with another segment is split where a circle about the vertex, with
+// a power of 2 radius, crosses it. Otherwise it is split in the middle.
+func (m *mesher) splitSegment(s EdgeI) {
+	u, v := s[0], s[1]
+	if v < m.nInput && u >= m.nInput {
+		u, v = v, u
+	}
+	t := 0.5
+	if u < m.nInput && v >= m.nInput {
+		l := m.vs[v].Sub(m.vs[u]).Length()
+		r := math.Pow(2, math.Round(math.Log2(0.5*l)))
+		t = Clamp(r/l, 0.25, 0.75)
+	}
+	p := m.addVertex(m.vs[u].Add(m.vs[v].Sub(m.vs[u]).MulScalar(t)))
+	m.splitEdge(u, v, p)
+}
+
+// splitBad splits a bad triangle at its circumcenter. It returns false if
+// the circumcenter encroaches on segments, they are split instead.
+func (m *mesher) splitBad(k int) bool {
+	t := m.ts[k]
+	a := m.vs[t[0]]
+	b := m.vs[t[1]].Sub(a)
+	c := m.vs[t[2]].Sub(a)
+	d := 2 * orient2d(m.vs[t[0]], m.vs[t[1]], m.vs[t[2]])
+	if d <= 0 {
+		return true
+	}
+	cc := a.Add(V2{c.Y*b.Length2() - b.Y*c.Length2(), b.X*c.Length2() - c.X*b.Length2()}.DivScalar(d))
+	// split the segments encroached by the circumcenter
+	var segs []EdgeI
+	for s := range m.fixed {
+		if m.encroached(s, cc) {
+			segs = append(segs, s)
+		}
+	}
+	if len(segs) != 0 {
+		for _, s := range segs {
+			m.splitSegment(s)
+		}
+		return false
+	}
+	j, ok := m.locate(cc, k)
+	if !ok || !m.in[j] {
+		// outside the region (only with rounding errors)
+		return true
+	}
+	x := m.ts[j]
+	for i := 0; i < 3; i++ {
+		u, v := x[i], x[(i+1)%3]
+		if m.vs[u] == cc {
+			return true
+		}
+		if orient2d(m.vs[u], m.vs[v], cc) == 0 {
+			m.splitEdge(u, v, m.addVertex(cc))
+			return true
+		}
+	}
+	m.splitTriangle(j, m.addVertex(cc))
+	return true
+}
+
+// update queues the changed triangles, and their segments, for checking.
+func (m *mesher) update() {
+	for _, k := range m.changed {
+		t := m.ts[k]
+		if m.in[k] {
+			m.bad = append(m.bad, t)
+		}
+		for j := 0; j < 3; j++ {
+			if e := (EdgeI{t[j], t[(j+1)%3]}); m.fixed[e.undirected()] {
+				m.segs = append(m.segs, e.undirected())
+			}
+		}
+	}
+	m.changed = m.changed[:0]
+}
+
+// refine splits the encroached segments and the bad triangles until there
+// are none left.
+func (m *mesher) refine() error {
+	for len(m.segs) != 0 || len(m.bad) != 0 {
+		if len(m.vs) > maxRefineVertices {
+			return errors.New("too many vertices, the mesh can't be refined")
+		}
+		if n := len(m.segs); n != 0 {
+			s := m.segs[n-1]
+			m.segs = m.segs[:n-1]
+			if m.fixed[s] && m.isEncroached(s) {
+				m.splitSegment(s)
+				m.update()
+			}
+			continue
+		}
+		n := len(m.bad)
+		t := m.bad[n-1]
+		m.bad = m.bad[:n-1]
+		// is the triangle still in the mesh?
+		k, ok := m.tri[EdgeI{t[0], t[1]}]
+		if !ok || m.ts[k] != t || !m.isBad(t) {
+			continue
+		}
+		if !m.splitBad(k) {
+			// try again once the segments are split
+			m.bad = append([]TriangleI{t}, m.bad...)
+		}
+		m.update()
+	}
+	return nil
+}
+
+// RefineTriangulate2d returns a quality triangle mesh of the regions
+// enclosed by closed outlines (as for Triangulate2d). No triangle has an
+// angle less than minAngle (radians, up to 30 degrees, except where the
+// outlines meet at a smaller angle) or an area greater than maxArea (0 for
+// no limit). The input vertices come first in the vertex set.
+func RefineTriangulate2d(minAngle, maxArea float64, outlines ...[]V2) (V2Set, TriangleISet, error) {
+	if minAngle < 0 || minAngle > DtoR(30) {
+		return nil, nil, errors.New("minAngle must be between 0 and 30 degrees")
+	}
+	if maxArea < 0 {
+		return nil, nil, errors.New("maxArea < 0")
+	}
+	vs, edges, err := outlineEdges(outlines)
+	if err != nil {
+		return nil, nil, err
+	}
+	ts, constrained, err := constrainedDelaunay(vs, edges)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := &mesher{
+		cdt:     newCDT(vs, ts),
+		in:      interiorTriangles(ts, constrained),
+		nInput:  len(vs),
+		cosMin:  math.Cos(minAngle),
+		maxArea: maxArea,
+		orig:    make(map[EdgeI]EdgeI),
+		on:      make(map[int]EdgeI),
+	}
+	m.track = true
+	for _, e := range constrained {
+		m.fixed[e.undirected()] = true
+		m.orig[e.undirected()] = e.undirected()
+		m.segs = append(m.segs, e.undirected())
+	}
+	for k, t := range m.ts {
+		if m.in[k] {
+			m.bad = append(m.bad, t)
+		}
+	}
+	if err := m.refine(); err != nil {
+		return nil, nil, err
+	}
+	var inside []TriangleI
+	for k, t := range m.ts {
+		if m.in[k] {
+			inside = append(inside, t)
+		}
+	}
+	return m.vs, inside, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// sdfContours returns the closed contours of an SDF2 found with marching
+// squares, with the given number of cells along the longest side of the
+// bounding box.
+func sdfContours(s SDF2, cells int) [][]V2 {
+	bb := s.BoundingBox()
+	step := bb.Size().MaxComponent() / float64(cells)
+	bb = NewBox2(bb.Center(), bb.Size().AddScalar(2*step))
+	lines := marchingSquares(s, bb, step)
+	// join the line segments at their end points, the lines don't have a
+	// consistent direction
+	key := func(p V2) V2i {
+		q := p.DivScalar(1e-6 * step)
+		return V2i{int(math.Round(q.X)), int(math.Round(q.Y))}
+	}
+	vertex := make(map[V2i]V2)
+	nb := make(map[V2i][]V2i)
+	for _, l := range lines {
+		k0, k1 := key(l[0]), key(l[1])
+		if k0 != k1 {
+			vertex[k0], vertex[k1] = l[0], l[1]
+			nb[k0] = append(nb[k0], k1)
+			nb[k1] = append(nb[k1], k0)
+		}
+	}
+	// remove removes the k0-k1 edge
+	remove := func(k0, k1 V2i) {
+		for i, k := range nb[k0] {
+			if k == k1 {
+				nb[k0] = append(nb[k0][:i], nb[k0][i+1:]...)
+				break
+			}
+		}
+		if len(nb[k0]) == 0 {
+			delete(nb, k0)
+		}
+	}
+	var contours [][]V2
+	for len(nb) != 0 {
+		// any remaining start point
+		var k V2i
+		for k = range nb {
+			break
+		}
+		var c []V2
+		for {
+			x, ok := nb[k]
+			if !ok {
+				break
+			}
+			next := x[0]
+			remove(k, next)
+			remove(next, k)
+			c = append(c, vertex[next])
+			k = next
+		}
+		if len(c) >= 3 {
+			contours = append(contours, c)
+		}
+	}
+	return contours
+}
+
+// Mesh2D returns a quality triangle mesh of the inside of an SDF2. The
+// outline is found on a grid with the given number of cells along the
+// longest side of the bounding box. No triangle has an angle less than
+// minAngle (radians) or an area greater than maxArea (0 for no limit).
+func Mesh2D(s SDF2, cells int, minAngle, maxArea float64) (V2Set, TriangleISet, error) {
+	if cells <= 0 {
+		return nil, nil, errors.New("cells <= 0")
+	}
+	contours := sdfContours(s, cells)
+	if len(contours) == 0 {
+		return nil, nil, errors.New("the SDF2 has no outline")
+	}
+	return RefineTriangulate2d(minAngle, maxArea, contours...)
+}
+
+//-----------------------------------------------------------------------------
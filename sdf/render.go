@@ -13,7 +13,9 @@ SDF2 -> SVG file
 package sdf
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"sync"
 )
 
@@ -25,6 +27,22 @@ func RenderSTL(
 	meshCells int, //number of cells on the longest axis. e.g 200
 	path string, //path to filename
 ) {
+	err := RenderSTLContext(context.Background(), s, meshCells, path, nil)
+	if err != nil {
+		fmt.Printf("%s", err)
+	}
+}
+
+// RenderSTLContext renders an SDF3 as an STL file (uses octree sampling).
+// The render can be cancelled with the context, in which case the partial
+// file is removed. Progress is reported to the progress function (may be nil).
+func RenderSTLContext(
+	ctx context.Context, // render context
+	s SDF3, //sdf3 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	path string, //path to filename
+	progress ProgressFunc, // progress callback
+) error {
 
 	// work out the sampling resolution to use
 	bbSize := s.BoundingBox().Size()
@@ -37,17 +55,74 @@ func RenderSTL(
 	var wg sync.WaitGroup
 	output, err := WriteSTL(&wg, path)
 	if err != nil {
-		fmt.Printf("%s", err)
-		return
+		return err
+	}
+
+	// accumulate the mesh statistics for the metadata file
+	md := getMetadata()
+	var stats meshStats
+	if md.enable {
+		output = stats.tapTriangles(&wg, output)
 	}
 
 	// run marching cubes to generate the triangle mesh
-	marchingCubesOctree(s, resolution, output)
+	err = marchingCubesOctree(s, resolution, output, newRenderProgress(ctx, progress))
 
 	// stop the STL writer reading on the channel
 	close(output)
 	// wait for the file write to complete
 	wg.Wait()
+
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return md.saveMetadata3(path, s, meshCells, resolution, &stats)
+}
+
+// RenderMesh renders an SDF3 as a triangle mesh (uses octree sampling).
+func RenderMesh(
+	s SDF3, //sdf3 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+) []*Triangle3 {
+	mesh, _ := RenderMeshContext(context.Background(), s, meshCells, nil)
+	return mesh
+}
+
+// RenderMeshContext renders an SDF3 as a triangle mesh (uses octree sampling).
+// The render can be cancelled with the context.
+// Progress is reported to the progress function (may be nil).
+func RenderMeshContext(
+	ctx context.Context, // render context
+	s SDF3, //sdf3 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	progress ProgressFunc, // progress callback
+) ([]*Triangle3, error) {
+	// work out the sampling resolution to use
+	bbSize := s.BoundingBox().Size()
+	resolution := bbSize.MaxComponent() / float64(meshCells)
+
+	// collect the triangles from the output channel
+	var mesh []*Triangle3
+	var wg sync.WaitGroup
+	output := make(chan *Triangle3)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for t := range output {
+			mesh = append(mesh, t)
+		}
+	}()
+
+	// run marching cubes to generate the triangle mesh
+	err := marchingCubesOctree(s, resolution, output, newRenderProgress(ctx, progress))
+
+	close(output)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return mesh, nil
 }
 
 // RenderSTLSlow renders an SDF3 as an STL file (uses uniform grid sampling).
@@ -71,6 +146,11 @@ func RenderSTLSlow(
 	// run marching cubes to generate the triangle mesh
 	m := marchingCubes(s, bb, meshInc)
 	err := SaveSTL(path, m)
+	if err == nil {
+		var stats meshStats
+		stats.addMesh(m)
+		err = getMetadata().saveMetadata3(path, s, meshCells, meshInc, &stats)
+	}
 	if err != nil {
 		fmt.Printf("%s", err)
 	}
@@ -84,6 +164,22 @@ func RenderDXF(
 	meshCells int, //number of cells on the longest axis. e.g 200
 	path string, //path to filename
 ) {
+	err := RenderDXFContext(context.Background(), s, meshCells, path, nil)
+	if err != nil {
+		fmt.Printf("%s", err)
+	}
+}
+
+// RenderDXFContext renders an SDF2 as a DXF file. (uses quadtree sampling)
+// The render can be cancelled with the context, in which case the partial
+// file is removed. Progress is reported to the progress function (may be nil).
+func RenderDXFContext(
+	ctx context.Context, // render context
+	s SDF2, //sdf2 to render
+	meshCells int, //number of cells on the longest axis. e.g 200
+	path string, //path to filename
+	progress ProgressFunc, // progress callback
+) error {
 
 	// work out the sampling resolution to use
 	bbSize := s.BoundingBox().Size()
@@ -96,17 +192,29 @@ func RenderDXF(
 	var wg sync.WaitGroup
 	output, err := WriteDXF(&wg, path)
 	if err != nil {
-		fmt.Printf("%s", err)
-		return
+		return err
+	}
+
+	// accumulate the mesh statistics for the metadata file
+	md := getMetadata()
+	var stats meshStats
+	if md.enable {
+		output = stats.tapLines(&wg, output)
 	}
 
 	// run marching squares to generate the line segments
-	marchingSquaresQuadtree(s, resolution, output)
+	err = marchingSquaresQuadtree(s, resolution, output, newRenderProgress(ctx, progress))
 
 	// stop the DXF writer reading on the channel
 	close(output)
 	// wait for the file write to complete
 	wg.Wait()
+
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return md.saveMetadata2(path, s, meshCells, resolution, &stats)
 }
 
 // RenderDXFSlow renders an SDF2 as a DXF file. (uses uniform grid sampling)
@@ -130,6 +238,11 @@ func RenderDXFSlow(
 	// run marching squares to generate the line segments
 	m := marchingSquares(s, bb, meshInc)
 	err := SaveDXF(path, m)
+	if err == nil {
+		var stats meshStats
+		stats.addLines(m)
+		err = getMetadata().saveMetadata2(path, s, meshCells, meshInc, &stats)
+	}
 	if err != nil {
 		fmt.Printf("%s", err)
 	}
@@ -143,6 +256,20 @@ func RenderSVG(
 	meshCells int, // number of cells on the longest axis. e.g 200
 	path string, // path to filename
 	lineStyle string, // SVG line style
+) error {
+	return RenderSVGContext(context.Background(), s, meshCells, path, lineStyle, nil)
+}
+
+// RenderSVGContext renders an SDF2 as an SVG file. (uses quadtree sampling)
+// The render can be cancelled with the context, in which case the partial
+// file is removed. Progress is reported to the progress function (may be nil).
+func RenderSVGContext(
+	ctx context.Context, // render context
+	s SDF2, // sdf2 to render
+	meshCells int, // number of cells on the longest axis. e.g 200
+	path string, // path to filename
+	lineStyle string, // SVG line style
+	progress ProgressFunc, // progress callback
 ) error {
 	// work out the sampling resolution to use
 	bbSize := s.BoundingBox().Size()
@@ -158,14 +285,26 @@ func RenderSVG(
 		return err
 	}
 
+	// accumulate the mesh statistics for the metadata file
+	md := getMetadata()
+	var stats meshStats
+	if md.enable {
+		output = stats.tapLines(&wg, output)
+	}
+
 	// run marching squares to generate the line segments
-	marchingSquaresQuadtree(s, resolution, output)
+	err = marchingSquaresQuadtree(s, resolution, output, newRenderProgress(ctx, progress))
 
 	// stop the SVG writer reading on the channel
 	close(output)
 	// wait for the file write to complete
 	wg.Wait()
-	return nil
+
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return md.saveMetadata2(path, s, meshCells, resolution, &stats)
 }
 
 // RenderSVGSlow renders an SDF2 as an SVG file. (uses uniform grid sampling)
@@ -189,7 +328,64 @@ func RenderSVGSlow(
 
 	// run marching squares to generate the line segments
 	m := marchingSquares(s, bb, meshInc)
-	return SaveSVG(path, lineStyle, m)
+	if err := SaveSVG(path, lineStyle, m); err != nil {
+		return err
+	}
+	var stats meshStats
+	stats.addLines(m)
+	return getMetadata().saveMetadata2(path, s, meshCells, meshInc, &stats)
+}
+
+//-----------------------------------------------------------------------------
+
+// renderGrid returns the uniform sampling grid used to render an SDF2.
+func renderGrid(s SDF2, meshCells int) (Box2, float64) {
+	bb0 := s.BoundingBox()
+	bb0Size := bb0.Size()
+	meshInc := bb0Size.MaxComponent() / float64(meshCells)
+	bb1Size := bb0Size.DivScalar(meshInc)
+	bb1Size = bb1Size.Ceil().AddScalar(1)
+	bb1Size = bb1Size.MulScalar(meshInc)
+	return NewBox2(bb0.Center(), bb1Size), meshInc
+}
+
+// RenderDXFAnnotated renders an SDF2 with dimensions and labels as a DXF file.
+// The annotations are on their own layer. (uses uniform grid sampling)
+func RenderDXFAnnotated(
+	s SDF2, // sdf2 to render
+	meshCells int, // number of cells on the longest axis. e.g 200
+	path string, // path to filename
+	a *Annotations, // drawing annotations
+) error {
+	bb, meshInc := renderGrid(s, meshCells)
+	fmt.Printf("rendering %s (resolution %.2f)\n", path, meshInc)
+	m := marchingSquares(s, bb, meshInc)
+	if err := SaveAnnotatedDXF(path, m, a); err != nil {
+		return err
+	}
+	var stats meshStats
+	stats.addLines(m)
+	return getMetadata().saveMetadata2(path, s, meshCells, meshInc, &stats)
+}
+
+// RenderSVGAnnotated renders an SDF2 with dimensions and labels as an SVG
+// file. (uses uniform grid sampling)
+func RenderSVGAnnotated(
+	s SDF2, // sdf2 to render
+	meshCells int, // number of cells on the longest axis. e.g 200
+	path string, // path to filename
+	lineStyle string, // SVG line style
+	a *Annotations, // drawing annotations
+) error {
+	bb, meshInc := renderGrid(s, meshCells)
+	fmt.Printf("rendering %s (resolution %.2f)\n", path, meshInc)
+	m := marchingSquares(s, bb, meshInc)
+	if err := SaveAnnotatedSVG(path, lineStyle, m, a); err != nil {
+		return err
+	}
+	var stats meshStats
+	stats.addLines(m)
+	return getMetadata().saveMetadata2(path, s, meshCells, meshInc, &stats)
 }
 
 //-----------------------------------------------------------------------------
@@ -0,0 +1,291 @@
+//-----------------------------------------------------------------------------
+/*
+
+Poisson Disk Sampling
+
+Evenly distributed random points within an SDF2/SDF3 region. No two points
+are closer than a minimum distance r, and no more points can be added, so the
+points have a blue noise distribution: random, but without clumps or gaps.
+They are useful for Voronoi patterns, stochastic perforations and the
+placement of support pillars.
+
+The points are generated with Bridson's algorithm: new points are tried in
+the annulus (or spherical shell) between r and 2r about an active point. A
+background grid with a cell diagonal of r holds at most one point per cell,
+so the neighbors of a point are found quickly. Each empty grid cell within the
+region starts a new set of active points, so disconnected parts of the region
+are all filled.
+
+Sampling is seeded, so a given seed always gives the same points.
+
+See: Fast Poisson Disk Sampling in Arbitrary Dimensions, Robert Bridson, 2007.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+//-----------------------------------------------------------------------------
+
+// poissonTries is the number of new points tried about an active point.
+const poissonTries = 30
+
+// maxPoissonCells limits the size of the background grid.
+const maxPoissonCells = 1 << 24
+
+// poissonCells returns the number of grid cells of size k along a length.
+func poissonCells(l, k float64) int {
+	return int(math.Max(math.Ceil(l/k), 1))
+}
+
+// poissonRange returns the range of grid cells within 2 cells of cell i.
+func poissonRange(i, n int) (int, int) {
+	lo, hi := i-2, i+2
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > n-1 {
+		hi = n - 1
+	}
+	return lo, hi
+}
+
+// poissonIndex returns the grid cell of x, clamped to the grid.
+func poissonIndex(x, k float64, n int) int {
+	i := int(math.Floor(x / k))
+	if i < 0 {
+		return 0
+	}
+	if i > n-1 {
+		return n - 1
+	}
+	return i
+}
+
+//-----------------------------------------------------------------------------
+
+// poissonGrid2 is the background grid for 2d sampling.
+type poissonGrid2 struct {
+	s    SDF2
+	r    float64
+	bb   Box2
+	k    float64 // cell size
+	n    V2i     // number of cells
+	cell []int   // index of the point in each cell, or -1
+	vs   V2Set
+}
+
+// index returns the grid cell of a point.
+func (g *poissonGrid2) index(p V2) V2i {
+	x := p.Sub(g.bb.Min)
+	return V2i{poissonIndex(x.X, g.k, g.n[0]), poissonIndex(x.Y, g.k, g.n[1])}
+}
+
+// add adds a point if it is within the region and no other point is within
+// r of it.
+func (g *poissonGrid2) add(p V2) bool {
+	if !g.bb.Contains(p) || g.s.Evaluate(p) > 0 {
+		return false
+	}
+	c := g.index(p)
+	i0, i1 := poissonRange(c[0], g.n[0])
+	j0, j1 := poissonRange(c[1], g.n[1])
+	for j := j0; j <= j1; j++ {
+		for i := i0; i <= i1; i++ {
+			k := g.cell[j*g.n[0]+i]
+			if k >= 0 && g.vs[k].Sub(p).Length2() < g.r*g.r {
+				return false
+			}
+		}
+	}
+	g.cell[c[1]*g.n[0]+c[0]] = len(g.vs)
+	g.vs = append(g.vs, p)
+	return true
+}
+
+// grow adds points about the active points until none can be added.
+func (g *poissonGrid2) grow(rng *rand.Rand, active []int) {
+	for len(active) != 0 {
+		i := rng.Intn(len(active))
+		p := g.vs[active[i]]
+		added := false
+		for n := 0; n < poissonTries; n++ {
+			// uniform in the annulus between r and 2r
+			d := g.r * math.Sqrt(1+3*rng.Float64())
+			if g.add(p.Add(PolarToXY(d, Tau*rng.Float64()))) {
+				active = append(active, len(g.vs)-1)
+				added = true
+				break
+			}
+		}
+		if !added {
+			active[i] = active[len(active)-1]
+			active = active[:len(active)-1]
+		}
+	}
+}
+
+// PoissonDisk2D returns seeded random points within an SDF2. The points are
+// at least r apart, and there is no space for more points.
+func PoissonDisk2D(s SDF2, r float64, seed int64) (V2Set, error) {
+	if r <= 0 {
+		return nil, errors.New("r <= 0")
+	}
+	bb := s.BoundingBox()
+	k := r / math.Sqrt2
+	n := V2i{poissonCells(bb.Size().X, k), poissonCells(bb.Size().Y, k)}
+	if float64(n[0])*float64(n[1]) > maxPoissonCells {
+		return nil, errors.New("r is too small for the size of the region")
+	}
+	g := &poissonGrid2{s: s, r: r, bb: bb, k: k, n: n, cell: make([]int, n[0]*n[1])}
+	for i := range g.cell {
+		g.cell[i] = -1
+	}
+	rng := rand.New(rand.NewSource(seed))
+	for _, c := range rng.Perm(len(g.cell)) {
+		if g.cell[c] >= 0 {
+			continue
+		}
+		min := g.bb.Min.Add(V2{float64(c % n[0]), float64(c / n[0])}.MulScalar(k))
+		cell := Box2{min, min.AddScalar(k)}
+		if s.Evaluate(cell.Center()) > 0.5*r {
+			// the cell is outside the region
+			continue
+		}
+		for j := 0; j < poissonTries; j++ {
+			p := V2{
+				cell.Min.X + k*rng.Float64(),
+				cell.Min.Y + k*rng.Float64(),
+			}
+			if g.add(p) {
+				g.grow(rng, []int{len(g.vs) - 1})
+				break
+			}
+		}
+	}
+	return g.vs, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// poissonGrid3 is the background grid for 3d sampling.
+type poissonGrid3 struct {
+	s    SDF3
+	r    float64
+	bb   Box3
+	k    float64 // cell size
+	n    V3i     // number of cells
+	cell []int   // index of the point in each cell, or -1
+	vs   V3Set
+}
+
+// index returns the grid cell of a point.
+func (g *poissonGrid3) index(p V3) V3i {
+	x := p.Sub(g.bb.Min)
+	return V3i{
+		poissonIndex(x.X, g.k, g.n[0]),
+		poissonIndex(x.Y, g.k, g.n[1]),
+		poissonIndex(x.Z, g.k, g.n[2]),
+	}
+}
+
+// add adds a point if it is within the region and no other point is within
+// r of it.
+func (g *poissonGrid3) add(p V3) bool {
+	if !g.bb.Contains(p) || g.s.Evaluate(p) > 0 {
+		return false
+	}
+	c := g.index(p)
+	i0, i1 := poissonRange(c[0], g.n[0])
+	j0, j1 := poissonRange(c[1], g.n[1])
+	k0, k1 := poissonRange(c[2], g.n[2])
+	for k := k0; k <= k1; k++ {
+		for j := j0; j <= j1; j++ {
+			for i := i0; i <= i1; i++ {
+				x := g.cell[(k*g.n[1]+j)*g.n[0]+i]
+				if x >= 0 && g.vs[x].Sub(p).Length2() < g.r*g.r {
+					return false
+				}
+			}
+		}
+	}
+	g.cell[(c[2]*g.n[1]+c[1])*g.n[0]+c[0]] = len(g.vs)
+	g.vs = append(g.vs, p)
+	return true
+}
+
+// grow adds points about the active points until none can be added.
+func (g *poissonGrid3) grow(rng *rand.Rand, active []int) {
+	for len(active) != 0 {
+		i := rng.Intn(len(active))
+		p := g.vs[active[i]]
+		added := false
+		for n := 0; n < poissonTries; n++ {
+			// uniform in the spherical shell between r and 2r
+			d := g.r * math.Cbrt(1+7*rng.Float64())
+			z := 2*rng.Float64() - 1
+			xy := PolarToXY(math.Sqrt(1-z*z), Tau*rng.Float64())
+			if g.add(p.Add(V3{xy.X, xy.Y, z}.MulScalar(d))) {
+				active = append(active, len(g.vs)-1)
+				added = true
+				break
+			}
+		}
+		if !added {
+			active[i] = active[len(active)-1]
+			active = active[:len(active)-1]
+		}
+	}
+}
+
+// PoissonDisk3D returns seeded random points within an SDF3. The points are
+// at least r apart, and there is no space for more points.
+func PoissonDisk3D(s SDF3, r float64, seed int64) (V3Set, error) {
+	if r <= 0 {
+		return nil, errors.New("r <= 0")
+	}
+	bb := s.BoundingBox()
+	k := r / math.Sqrt(3)
+	size := bb.Size()
+	n := V3i{poissonCells(size.X, k), poissonCells(size.Y, k), poissonCells(size.Z, k)}
+	if float64(n[0])*float64(n[1])*float64(n[2]) > maxPoissonCells {
+		return nil, errors.New("r is too small for the size of the region")
+	}
+	g := &poissonGrid3{s: s, r: r, bb: bb, k: k, n: n, cell: make([]int, n[0]*n[1]*n[2])}
+	for i := range g.cell {
+		g.cell[i] = -1
+	}
+	rng := rand.New(rand.NewSource(seed))
+	for _, c := range rng.Perm(len(g.cell)) {
+		if g.cell[c] >= 0 {
+			continue
+		}
+		x := V3{float64(c % n[0]), float64((c / n[0]) % n[1]), float64(c / (n[0] * n[1]))}
+		min := g.bb.Min.Add(x.MulScalar(k))
+		cell := Box3{min, min.AddScalar(k)}
+		if s.Evaluate(cell.Center()) > 0.5*r {
+			// the cell is outside the region
+			continue
+		}
+		for j := 0; j < poissonTries; j++ {
+			p := V3{
+				cell.Min.X + k*rng.Float64(),
+				cell.Min.Y + k*rng.Float64(),
+				cell.Min.Z + k*rng.Float64(),
+			}
+			if g.add(p) {
+				g.grow(rng, []int{len(g.vs) - 1})
+				break
+			}
+		}
+	}
+	return g.vs, nil
+}
+
+//-----------------------------------------------------------------------------
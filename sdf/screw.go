@@ -21,25 +21,33 @@ clearance.
 package sdf
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"sync"
 )
 
 //-----------------------------------------------------------------------------
 // Thread Database - lookup standard screw threads by name
 
 // ThreadParameters stores the values that define a thread.
+// Populate it and use RegisterThread to add a non-standard thread.
 type ThreadParameters struct {
 	Name         string  // name of screw thread
-	Radius       float64 // nominal major radius of screw
+	Radius       float64 // nominal major radius of screw (at the gauge plane for tapered threads)
 	Pitch        float64 // thread to thread distance of screw
-	HexFlat2Flat float64 // hex head flat to flat distance
+	HexFlat2Flat float64 // hex head flat to flat distance (< 0 for none)
 	Units        string  // "inch" or "mm"
+	Form         string  // thread form: "" or "iso" (ISO/UTS), "npt", "bspt", "acme", "tr" (trapezoidal) or "buttress"
+	Taper        float64 // taper half angle (radians) for tapered threads, 0 for parallel threads
+	Starts       int     // number of thread starts (0 is a single start thread)
+	LeftHand     bool    // left hand thread
 }
 
 type threadDatabase map[string]*ThreadParameters
 
 var threadDB = initThreadLookup()
+var threadLock sync.RWMutex
 
 // UTSAdd adds a Unified Thread Standard to the thread database.
 func (m threadDatabase) UTSAdd(
@@ -73,6 +81,90 @@ func (m threadDatabase) ISOAdd(
 	m[name] = &t
 }
 
+// NPTAdd adds an American National Standard Taper Pipe Thread to the thread database.
+func (m threadDatabase) NPTAdd(
+	name string, // thread name
+	e1 float64, // pitch diameter at the gauge plane
+	tpi float64, // threads per inch
+) {
+	t := ThreadParameters{}
+	t.Name = name
+	t.Pitch = 1.0 / tpi
+	t.Radius = (e1 + 0.8*t.Pitch) / 2.0
+	t.HexFlat2Flat = -1
+	t.Units = "inch"
+	t.Form = "npt"
+	t.Taper = math.Atan(1.0 / 32.0)
+	m[name] = &t
+}
+
+// BSPTAdd adds a British Standard Pipe Taper thread to the thread database.
+func (m threadDatabase) BSPTAdd(
+	name string, // thread name
+	diameter float64, // major diameter at the gauge plane
+	tpi float64, // threads per inch
+) {
+	t := ThreadParameters{}
+	t.Name = name
+	t.Radius = diameter / 2.0
+	t.Pitch = 25.4 / tpi
+	t.HexFlat2Flat = -1
+	t.Units = "mm"
+	t.Form = "bspt"
+	t.Taper = math.Atan(1.0 / 32.0)
+	m[name] = &t
+}
+
+// AcmeAdd adds a General Purpose ACME thread to the thread database.
+func (m threadDatabase) AcmeAdd(
+	name string, // thread name
+	diameter float64, // screw major diameter
+	tpi float64, // threads per inch
+) {
+	t := ThreadParameters{}
+	t.Name = name
+	t.Radius = diameter / 2.0
+	t.Pitch = 1.0 / tpi
+	t.HexFlat2Flat = -1
+	t.Units = "inch"
+	t.Form = "acme"
+	m[name] = &t
+}
+
+// TrAdd adds an ISO metric trapezoidal thread to the thread database.
+func (m threadDatabase) TrAdd(
+	name string, // thread name
+	diameter float64, // screw major diameter
+	lead float64, // distance per turn
+	pitch float64, // thread pitch
+) {
+	t := ThreadParameters{}
+	t.Name = name
+	t.Radius = diameter / 2.0
+	t.Pitch = pitch
+	t.HexFlat2Flat = -1
+	t.Units = "mm"
+	t.Form = "tr"
+	t.Starts = int(math.Round(lead / pitch))
+	m[name] = &t
+}
+
+// ButtressAdd adds a DIN 513 metric buttress thread to the thread database.
+func (m threadDatabase) ButtressAdd(
+	name string, // thread name
+	diameter float64, // screw major diameter
+	pitch float64, // thread pitch
+) {
+	t := ThreadParameters{}
+	t.Name = name
+	t.Radius = diameter / 2.0
+	t.Pitch = pitch
+	t.HexFlat2Flat = -1
+	t.Units = "mm"
+	t.Form = "buttress"
+	m[name] = &t
+}
+
 // initThreadLookup adds a collection of standard threads to the thread database.
 func initThreadLookup() threadDatabase {
 	m := make(threadDatabase)
@@ -142,17 +234,125 @@ func initThreadLookup() threadDatabase {
 	m.ISOAdd("M48x3", 48, 3, 75)
 	m.ISOAdd("M56x4", 56, 4, 85)
 	m.ISOAdd("M64x4", 64, 4, 95)
+	// NPT (ANSI/ASME B1.20.1)
+	m.NPTAdd("npt_1/16", 0.28118, 27)
+	m.NPTAdd("npt_1/8", 0.37360, 27)
+	m.NPTAdd("npt_1/4", 0.49163, 18)
+	m.NPTAdd("npt_3/8", 0.62701, 18)
+	m.NPTAdd("npt_1/2", 0.77843, 14)
+	m.NPTAdd("npt_3/4", 0.98887, 14)
+	m.NPTAdd("npt_1", 1.23863, 11.5)
+	m.NPTAdd("npt_1-1/4", 1.58338, 11.5)
+	m.NPTAdd("npt_1-1/2", 1.82234, 11.5)
+	m.NPTAdd("npt_2", 2.29627, 11.5)
+	// BSPT (ISO 7-1)
+	m.BSPTAdd("R1/16", 7.723, 28)
+	m.BSPTAdd("R1/8", 9.728, 28)
+	m.BSPTAdd("R1/4", 13.157, 19)
+	m.BSPTAdd("R3/8", 16.662, 19)
+	m.BSPTAdd("R1/2", 20.955, 14)
+	m.BSPTAdd("R3/4", 26.441, 14)
+	m.BSPTAdd("R1", 33.249, 11)
+	m.BSPTAdd("R1-1/4", 41.910, 11)
+	m.BSPTAdd("R1-1/2", 47.803, 11)
+	m.BSPTAdd("R2", 59.614, 11)
+	// ACME General Purpose (ASME/ANSI B1.5)
+	m.AcmeAdd("acme_1/4", 1.0/4.0, 16)
+	m.AcmeAdd("acme_5/16", 5.0/16.0, 14)
+	m.AcmeAdd("acme_3/8", 3.0/8.0, 12)
+	m.AcmeAdd("acme_7/16", 7.0/16.0, 12)
+	m.AcmeAdd("acme_1/2", 1.0/2.0, 10)
+	m.AcmeAdd("acme_5/8", 5.0/8.0, 8)
+	m.AcmeAdd("acme_3/4", 3.0/4.0, 6)
+	m.AcmeAdd("acme_7/8", 7.0/8.0, 6)
+	m.AcmeAdd("acme_1", 1.0, 5)
+	// ISO Trapezoidal (ISO 2904)
+	m.TrAdd("Tr8x1.5", 8, 1.5, 1.5)
+	m.TrAdd("Tr8x2", 8, 2, 2)
+	m.TrAdd("Tr8x4(P2)", 8, 4, 2)
+	m.TrAdd("Tr8x8(P2)", 8, 8, 2)
+	m.TrAdd("Tr10x2", 10, 2, 2)
+	m.TrAdd("Tr10x4(P2)", 10, 4, 2)
+	m.TrAdd("Tr12x3", 12, 3, 3)
+	m.TrAdd("Tr12x6(P3)", 12, 6, 3)
+	m.TrAdd("Tr14x4", 14, 4, 4)
+	m.TrAdd("Tr16x4", 16, 4, 4)
+	m.TrAdd("Tr16x8(P4)", 16, 8, 4)
+	m.TrAdd("Tr20x4", 20, 4, 4)
+	m.TrAdd("Tr24x5", 24, 5, 5)
+	m.TrAdd("Tr28x5", 28, 5, 5)
+	m.TrAdd("Tr32x6", 32, 6, 6)
+	m.TrAdd("Tr36x6", 36, 6, 6)
+	m.TrAdd("Tr40x7", 40, 7, 7)
+	// Metric Buttress (DIN 513)
+	m.ButtressAdd("S10x2", 10, 2)
+	m.ButtressAdd("S12x3", 12, 3)
+	m.ButtressAdd("S16x4", 16, 4)
+	m.ButtressAdd("S20x4", 20, 4)
+	m.ButtressAdd("S24x5", 24, 5)
+	m.ButtressAdd("S28x5", 28, 5)
+	m.ButtressAdd("S32x6", 32, 6)
+	m.ButtressAdd("S36x6", 36, 6)
+	m.ButtressAdd("S40x7", 40, 7)
 	return m
 }
 
 // ThreadLookup lookups the parameters for a thread by name.
 func ThreadLookup(name string) (*ThreadParameters, error) {
+	threadLock.RLock()
+	defer threadLock.RUnlock()
 	if t, ok := threadDB[name]; ok {
 		return t, nil
 	}
 	return nil, fmt.Errorf("thread \"%s\" not found", name)
 }
 
+// RegisterThread adds a thread to the thread database, so it can be used by
+// name (E.g. with Bolt and Nut). The parameters are copied. It is an error to
+// register a name that is already in the database.
+func RegisterThread(t *ThreadParameters) error {
+	if t.Name == "" {
+		return errors.New("no thread name")
+	}
+	if t.Radius <= 0 {
+		return errors.New("radius <= 0")
+	}
+	if t.Pitch <= 0 {
+		return errors.New("pitch <= 0")
+	}
+	if t.Units != "inch" && t.Units != "mm" {
+		return fmt.Errorf("unknown units \"%s\"", t.Units)
+	}
+	if _, err := t.Profile(t.Radius, "external"); err != nil {
+		return err
+	}
+	if Abs(t.Taper) >= DtoR(45) {
+		return errors.New("taper >= 45 degrees")
+	}
+	if t.Starts < 0 {
+		return errors.New("starts < 0")
+	}
+	threadLock.Lock()
+	defer threadLock.Unlock()
+	if _, ok := threadDB[t.Name]; ok {
+		return fmt.Errorf("thread \"%s\" already exists", t.Name)
+	}
+	x := *t
+	threadDB[t.Name] = &x
+	return nil
+}
+
+// UnregisterThread removes a thread from the thread database.
+func UnregisterThread(name string) error {
+	threadLock.Lock()
+	defer threadLock.Unlock()
+	if _, ok := threadDB[name]; !ok {
+		return fmt.Errorf("thread \"%s\" not found", name)
+	}
+	delete(threadDB, name)
+	return nil
+}
+
 // HexRadius returns the hex head radius.
 func (t *ThreadParameters) HexRadius() float64 {
 	if t.HexFlat2Flat < 0 {
@@ -166,33 +366,108 @@ func (t *ThreadParameters) HexHeight() float64 {
 	return 2.0 * t.HexRadius() * (5.0 / 12.0)
 }
 
+// Profile returns the 2d thread profile of the thread form with a given radius.
+func (t *ThreadParameters) Profile(
+	radius float64, // radius of thread
+	mode string, // internal/external thread
+) (SDF2, error) {
+	if mode != "internal" && mode != "external" {
+		return nil, fmt.Errorf("unknown mode \"%s\"", mode)
+	}
+	switch t.Form {
+	case "", "iso":
+		return ISOThread(radius, t.Pitch, mode), nil
+	case "npt":
+		return NPTThread(radius, t.Pitch), nil
+	case "bspt":
+		return WhitworthThread(radius, t.Pitch), nil
+	case "acme":
+		return AcmeThread(radius, t.Pitch), nil
+	case "tr":
+		return TrapezoidalThread(radius, t.Pitch), nil
+	case "buttress":
+		return ButtressThread(radius, t.Pitch, 0.86777*t.Pitch, DtoR(3), DtoR(30)), nil
+	}
+	return nil, fmt.Errorf("unknown thread form \"%s\"", t.Form)
+}
+
+// Thread3D returns a screw thread for the thread parameters. The thread
+// radius is reduced (external) or increased (internal) by the tolerance.
+// The thread profile is the same for left and right hand threads.
+// Tapered threads have the gauge plane at z = 0, with the radius increasing
+// with z.
+func (t *ThreadParameters) Thread3D(
+	length float64, // length of thread
+	tolerance float64, // thread radius tolerance
+	mode string, // internal/external thread
+) (SDF3, error) {
+	r := t.Radius + tolerance
+	if mode == "external" {
+		r = t.Radius - tolerance
+	}
+	profile, err := t.Profile(r, mode)
+	if err != nil {
+		return nil, err
+	}
+	starts := t.Starts
+	if starts == 0 {
+		starts = 1
+	}
+	if t.LeftHand {
+		starts = -starts
+	}
+	if t.Taper != 0 {
+		return TaperedScrew3D(profile, length, t.Taper, t.Pitch, starts)
+	}
+	return Screw3D(profile, length, t.Pitch, starts), nil
+}
+
 //-----------------------------------------------------------------------------
 // Thread Profiles
 
-// AcmeThread returns the 2d profile for an acme thread.
-func AcmeThread(
+// trapezoidThread returns the 2d profile for a trapezoidal thread with a
+// depth of half the pitch and a given flank angle.
+func trapezoidThread(
 	radius float64, // radius of thread
 	pitch float64, // thread to thread distance
+	theta float64, // flank angle (half the thread angle)
 ) SDF2 {
 
 	h := radius - 0.5*pitch
-	theta := DtoR(29.0 / 2.0)
 	delta := 0.25 * pitch * math.Tan(theta)
 	xOfs0 := 0.25*pitch - delta
 	xOfs1 := 0.25*pitch + delta
 
-	acme := NewPolygon()
-	acme.Add(radius, 0)
-	acme.Add(radius, h)
-	acme.Add(xOfs1, h)
-	acme.Add(xOfs0, radius)
-	acme.Add(-xOfs0, radius)
-	acme.Add(-xOfs1, h)
-	acme.Add(-radius, h)
-	acme.Add(-radius, 0)
+	tp := NewPolygon()
+	tp.Add(radius, 0)
+	tp.Add(radius, h)
+	tp.Add(xOfs1, h)
+	tp.Add(xOfs0, radius)
+	tp.Add(-xOfs0, radius)
+	tp.Add(-xOfs1, h)
+	tp.Add(-radius, h)
+	tp.Add(-radius, 0)
+
+	return Polygon2D(tp.Vertices())
+}
+
+// AcmeThread returns the 2d profile for an acme thread.
+// https://en.wikipedia.org/wiki/Trapezoidal_thread_form
+func AcmeThread(
+	radius float64, // radius of thread
+	pitch float64, // thread to thread distance
+) SDF2 {
+	return trapezoidThread(radius, pitch, DtoR(29.0/2.0))
+}
 
-	//acme.Render("acme.dxf")
-	return Polygon2D(acme.Vertices())
+// TrapezoidalThread returns the 2d profile for an ISO metric trapezoidal
+// thread (30 degree thread angle).
+// https://en.wikipedia.org/wiki/Trapezoidal_thread_form
+func TrapezoidalThread(
+	radius float64, // radius of thread
+	pitch float64, // thread to thread distance
+) SDF2 {
+	return trapezoidThread(radius, pitch, DtoR(30.0/2.0))
 }
 
 // ISOThread returns the 2d profile for an ISO/UTS thread.
@@ -267,6 +542,52 @@ func ANSIButtressThread(
 	return Polygon2D(tp.Vertices())
 }
 
+// ButtressThread returns the 2d profile for a buttress thread with a given
+// depth and flank angles. The load flank faces +x (up the screw). The crest
+// and root flats are of equal width. E.g. DIN 513 is a 3/30 degree buttress
+// thread with a depth of 0.86777 * pitch.
+// https://en.wikipedia.org/wiki/Buttress_thread
+func ButtressThread(
+	radius float64, // radius of thread
+	pitch float64, // thread to thread distance
+	depth float64, // thread depth
+	theta0 float64, // load flank angle (radians)
+	theta1 float64, // clearance flank angle (radians)
+) SDF2 {
+	if depth <= 0 || depth >= radius {
+		panic("bad thread depth")
+	}
+	if theta0 < 0 || theta0 >= DtoR(90) || theta1 < 0 || theta1 >= DtoR(90) {
+		panic("bad flank angle")
+	}
+	x0 := depth * math.Tan(theta0)
+	x1 := depth * math.Tan(theta1)
+	// the crest and root flats
+	w := 0.5 * (pitch - x0 - x1)
+	if w <= 0 {
+		panic("thread is too deep for the flank angles")
+	}
+	c := 0.5 * w
+	rRoot := radius - depth
+
+	tp := NewPolygon()
+	tp.Add(pitch, 0)
+	tp.Add(pitch, radius)
+	tp.Add(pitch-c, radius)
+	tp.Add(c+x0+w, rRoot)
+	tp.Add(c+x0, rRoot)
+	tp.Add(c, radius)
+	tp.Add(-c, radius)
+	tp.Add(-c-x1, rRoot)
+	tp.Add(-c-x1-w, rRoot)
+	tp.Add(-pitch+c, radius)
+	tp.Add(-pitch, radius)
+	tp.Add(-pitch, 0)
+
+	//tp.Render("buttress.dxf")
+	return Polygon2D(tp.Vertices())
+}
+
 // PlasticButtressThread returns the 2d profile for a screw top style plastic buttress thread.
 // Similar to ANSI 45/7 - but with more corner rounding
 func PlasticButtressThread(
@@ -294,6 +615,65 @@ func PlasticButtressThread(
 	return Polygon2D(tp.Vertices())
 }
 
+// NPTThread returns the 2d profile for an NPT (American National Standard
+// Taper Pipe) thread. This is a 60 degree thread with flat crests and roots.
+// https://en.wikipedia.org/wiki/National_pipe_thread
+func NPTThread(
+	radius float64, // radius of thread
+	pitch float64, // thread to thread distance
+) SDF2 {
+	theta := DtoR(30.0)
+	h := pitch / (2.0 * math.Tan(theta))
+	// truncation of the sharp V at the crest and root
+	f := 0.033 * pitch
+	xOfs := f * math.Tan(theta)
+	rRoot := radius + 2*f - h
+
+	npt := NewPolygon()
+	npt.Add(pitch, 0)
+	npt.Add(pitch, radius)
+	npt.Add(pitch-xOfs, radius)
+	npt.Add(pitch/2.0+xOfs, rRoot)
+	npt.Add(pitch/2.0-xOfs, rRoot)
+	npt.Add(xOfs, radius)
+	npt.Add(-xOfs, radius)
+	npt.Add(-pitch/2.0+xOfs, rRoot)
+	npt.Add(-pitch/2.0-xOfs, rRoot)
+	npt.Add(-pitch+xOfs, radius)
+	npt.Add(-pitch, radius)
+	npt.Add(-pitch, 0)
+
+	//npt.Render("npt.dxf")
+	return Polygon2D(npt.Vertices())
+}
+
+// WhitworthThread returns the 2d profile for a Whitworth (BSW/BSP/BSPT) thread.
+// This is a 55 degree thread with rounded crests and roots.
+// https://en.wikipedia.org/wiki/British_Standard_Whitworth
+func WhitworthThread(
+	radius float64, // radius of thread
+	pitch float64, // thread to thread distance
+) SDF2 {
+	theta := DtoR(27.5)
+	h := pitch / (2.0 * math.Tan(theta))
+	// the sharp V is rounded off by h/6 at the crest and root
+	rTop := radius + h/6.0
+	rRoot := rTop - h
+	rRound := 0.137329 * pitch
+
+	bsw := NewPolygon()
+	bsw.Add(0.75*pitch, 0)
+	bsw.Add(0.75*pitch, rRoot+h/2.0)
+	bsw.Add(pitch/2.0, rRoot).Smooth(rRound, 5)
+	bsw.Add(0, rTop).Smooth(rRound, 5)
+	bsw.Add(-pitch/2.0, rRoot).Smooth(rRound, 5)
+	bsw.Add(-0.75*pitch, rRoot+h/2.0)
+	bsw.Add(-0.75*pitch, 0)
+
+	//bsw.Render("whitworth.dxf")
+	return Polygon2D(bsw.Vertices())
+}
+
 //-----------------------------------------------------------------------------
 
 // ScrewSDF3 is a 3d screw form.
@@ -350,4 +730,319 @@ func (s *ScrewSDF3) BoundingBox() Box3 {
 	return s.bb
 }
 
+// RightHandScrew3D returns a right hand screw SDF3.
+func RightHandScrew3D(
+	thread SDF2, // 2D thread profile
+	length float64, // length of screw
+	pitch float64, // thread to thread distance
+	starts int, // number of thread starts
+) SDF3 {
+	if starts <= 0 {
+		panic("starts <= 0")
+	}
+	return Screw3D(thread, length, pitch, starts)
+}
+
+// LeftHandScrew3D returns a left hand screw SDF3. Unlike mirroring a right
+// hand screw, the thread profile is not mirrored, so asymmetric (E.g. buttress)
+// threads keep the load flank facing +z.
+func LeftHandScrew3D(
+	thread SDF2, // 2D thread profile
+	length float64, // length of screw
+	pitch float64, // thread to thread distance
+	starts int, // number of thread starts
+) SDF3 {
+	if starts <= 0 {
+		panic("starts <= 0")
+	}
+	return Screw3D(thread, length, pitch, -starts)
+}
+
+//-----------------------------------------------------------------------------
+// Helical sweeps with variable pitch and radius.
+
+// helixSamples is the number of samples used to integrate the helix pitch.
+const helixSamples = 1024
+
+// HelixFunc returns a helix parameter (pitch or radius) at height z.
+type HelixFunc func(z float64) float64
+
+// HelixSDF3 is a 2d profile swept along a helix with variable pitch and radius.
+type HelixSDF3 struct {
+	profile SDF2      // 2D profile
+	pitch   HelixFunc // pitch at height z
+	radius  HelixFunc // radius at height z
+	length  float64   // half length of the helix
+	starts  float64   // number of starts
+	phase   []float64 // number of pitches from the bottom of the helix (sampled)
+	dz      float64   // phase sample spacing
+	k       float64   // distance scaling factor
+	bb      Box3      // bounding box
+}
+
+// Helix3D returns an SDF3 made by sweeping a 2d profile along a helix
+// (about the z-axis) where the pitch and radius are functions of z.
+// z ranges from -length/2 to length/2.
+// The profile x-axis is along the helix axis (as for screw threads) and the
+// profile y-axis is the radial distance from the helix radius. Thread profiles
+// have y as the absolute radius, so for these the radius function is the change
+// in thread radius, e.g. a tapered thread has a linear radius function with
+// radius(0) = 0. For springs the profile is centered on the origin.
+func Helix3D(
+	profile SDF2, // 2D profile
+	length float64, // length of helix
+	pitch HelixFunc, // pitch as a function of z
+	radius HelixFunc, // radius as a function of z
+	starts int, // number of starts (< 0 for left hand)
+) (SDF3, error) {
+	if length <= 0 {
+		return nil, errors.New("length <= 0")
+	}
+	if starts == 0 {
+		return nil, errors.New("starts == 0")
+	}
+	s := HelixSDF3{}
+	s.profile = profile
+	s.pitch = pitch
+	s.radius = radius
+	s.length = length / 2
+	s.starts = float64(starts)
+	// integrate the pitch to get the phase
+	s.dz = length / helixSamples
+	s.phase = make([]float64, helixSamples+1)
+	rmax := radius(-s.length)
+	dr := 0.0 // maximum radius slope
+	dp := 0.0 // maximum pitch slope
+	for i := 0; i <= helixSamples; i++ {
+		z := -s.length + float64(i)*s.dz
+		p := pitch(z)
+		if p <= 0 {
+			return nil, fmt.Errorf("pitch %f <= 0 at z = %f", p, z)
+		}
+		r := radius(z)
+		rmax = Max(rmax, r)
+		if i > 0 {
+			z0 := z - s.dz
+			s.phase[i] = s.phase[i-1] + 0.5*s.dz*(1/pitch(z0)+1/p)
+			dr = Max(dr, Abs(r-radius(z0))/s.dz)
+			dp = Max(dp, Abs(p-pitch(z0))/s.dz)
+		}
+	}
+	// The profile x-distance is stretched along z by up to 1 + dp/2 and the
+	// radius slope tilts the profile, so scale the distance to keep it conservative.
+	s.k = math.Sqrt((1+0.5*dp)*(1+0.5*dp) + dr*dr)
+	// work out the bounding box
+	bb := profile.BoundingBox()
+	r := rmax + bb.Max.Y
+	s.bb = Box3{V3{-r, -r, -s.length}, V3{r, r, s.length}}
+	return &s, nil
+}
+
+// turns returns the number of pitches from the bottom of the helix at height z.
+func (s *HelixSDF3) turns(z float64) float64 {
+	x := (z + s.length) / s.dz
+	if x <= 0 {
+		return x * s.dz / s.pitch(-s.length)
+	}
+	if x >= helixSamples {
+		return s.phase[helixSamples] + (x-helixSamples)*s.dz/s.pitch(s.length)
+	}
+	i := int(x)
+	t := x - float64(i)
+	return s.phase[i] + t*(s.phase[i+1]-s.phase[i])
+}
+
+// Evaluate returns the minimum distance to a helical sweep.
+func (s *HelixSDF3) Evaluate(p V3) float64 {
+	pitch := s.pitch(p.Z)
+	// the distance from the helix radius maps to the 2d y-axis
+	p0 := V2{}
+	p0.Y = math.Sqrt(p.X*p.X+p.Y*p.Y) - s.radius(p.Z)
+	// the x/y angle and the number of turns map to the 2d x-axis
+	theta := math.Atan2(p.Y, p.X)
+	u := s.turns(p.Z) - s.starts*theta/Tau
+	p0.X = (u - math.Floor(u+0.5)) * pitch
+	// get the profile distance
+	d0 := s.profile.Evaluate(p0) / s.k
+	// create a region for the helix length
+	d1 := Abs(p.Z) - s.length
+	// return the intersection
+	return Max(d0, d1)
+}
+
+// BoundingBox returns the bounding box for a helical sweep.
+func (s *HelixSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Tapered screws (E.g. pipe threads)
+
+// threadCoreSDF2 fills in the core of a thread profile below y = 0.
+type threadCoreSDF2 struct {
+	thread SDF2
+	core   float64 // y-level of the core surface
+}
+
+// Evaluate returns the minimum distance to a thread profile with a solid core.
+func (s *threadCoreSDF2) Evaluate(p V2) float64 {
+	return Min(s.thread.Evaluate(p), p.Y-s.core)
+}
+
+// BoundingBox returns the bounding box of the thread profile.
+func (s *threadCoreSDF2) BoundingBox() Box2 {
+	return s.thread.BoundingBox()
+}
+
+// TaperedScrew3D returns a tapered screw SDF3. The thread profile is swept
+// along a cone with the profile radius at z = 0, and the radius increasing
+// with z. The screw has a solid core, so the thread root should be more
+// than half of the thread radius.
+func TaperedScrew3D(
+	thread SDF2, // 2D thread profile
+	length float64, // length of screw
+	taper float64, // taper half angle (radians)
+	pitch float64, // thread to thread distance
+	starts int, // number of thread starts (< 0 for left hand threads)
+) (SDF3, error) {
+	if pitch <= 0 {
+		return nil, errors.New("pitch <= 0")
+	}
+	if Abs(taper) >= DtoR(45) {
+		return nil, errors.New("taper >= 45 degrees")
+	}
+	k := math.Tan(taper)
+	// The helix moves the profile away from the axis as the radius increases,
+	// so fill in the core of the cone.
+	core := &threadCoreSDF2{thread, 0.5 * thread.BoundingBox().Max.Y}
+	return Helix3D(
+		core,
+		length,
+		func(z float64) float64 { return pitch },
+		func(z float64) float64 { return z * k },
+		starts,
+	)
+}
+
+//-----------------------------------------------------------------------------
+// Coils (springs and spiral channels)
+
+// CoilSDF3 is a 2d profile swept along a helix with a given number of turns.
+type CoilSDF3 struct {
+	profile SDF2    // 2D profile
+	radius  float64 // coil radius
+	pitch   float64 // distance between turns
+	turns   float64 // number of turns
+	length  float64 // half length of the coil (between the ends of the profile center)
+	r0      float64 // inner radius of the coil
+	ground  bool    // the ends are ground flat
+	k       float64 // distance scaling factor
+	bb      Box3    // bounding box
+}
+
+// Coil3D returns an SDF3 made by sweeping a 2d profile (e.g. the cross section
+// of a spring wire) along a helix about the z-axis. Unlike a screw the coil has a
+// number of turns with ends, rather than being cut to length. The profile x-axis
+// is along the coil axis and the y-axis is the radial distance from the coil
+// radius, so the profile is centered on the origin. The coil is centered on the
+// origin, and it starts on the x-axis. Ground ends are cut flat at the height of
+// the profile center at the start and end of the coil, as for compression springs.
+func Coil3D(
+	profile SDF2, // 2D profile
+	radius float64, // coil radius (to the profile center)
+	pitch float64, // distance between turns
+	turns float64, // number of turns
+	ground bool, // grind the ends flat
+) (SDF3, error) {
+	if pitch <= 0 {
+		return nil, errors.New("pitch <= 0")
+	}
+	if turns <= 0 {
+		return nil, errors.New("turns <= 0")
+	}
+	bb := profile.BoundingBox()
+	// the inner radius of the coil
+	r0 := radius + bb.Min.Y
+	if r0 <= 0 {
+		return nil, errors.New("the profile crosses the coil axis")
+	}
+	s := CoilSDF3{}
+	s.profile = profile
+	s.radius = radius
+	s.pitch = pitch
+	s.turns = turns
+	s.length = 0.5 * pitch * turns
+	s.ground = ground
+	// The profile x-distance changes with the angle as well as z, more so
+	// nearer the axis, so scale the distance to keep it conservative. Near the
+	// axis (within half the inner radius) the distance to the inner radius is
+	// used instead.
+	s.r0 = r0
+	x := pitch / (Pi * r0)
+	s.k = math.Sqrt(1 + x*x)
+	// work out the bounding box
+	r := radius + bb.Max.Y
+	z0, z1 := -s.length+bb.Min.X, s.length+bb.Max.X
+	if ground {
+		z0, z1 = -s.length, s.length
+	}
+	s.bb = Box3{V3{-r, -r, z0}, V3{r, r, z1}}
+	return &s, nil
+}
+
+// Evaluate returns the minimum distance to a coil.
+func (s *CoilSDF3) Evaluate(p V3) float64 {
+	r := math.Sqrt(p.X*p.X + p.Y*p.Y)
+	theta := math.Atan2(p.Y, p.X) / Tau
+	if theta < 0 {
+		theta++
+	}
+	// the number of turns from the start of the coil at height z
+	u := Clamp((p.Z+s.length)/s.pitch, 0, s.turns) - theta
+	d := math.MaxFloat64
+	// check the nearest turns of the coil
+	for i := math.Round(u) - 1; i <= math.Round(u)+1; i++ {
+		t := theta + i
+		if t >= 0 && t <= s.turns {
+			q := V2{p.Z + s.length - t*s.pitch, r - s.radius}
+			x := s.profile.Evaluate(q) / s.k
+			// cut off at the end faces (within a quarter turn of them)
+			w := -math.MaxFloat64
+			if t > s.turns-0.25 {
+				w = Max(w, s.endDistance(p, s.turns))
+			}
+			if t < 0.25 {
+				w = Max(w, -s.endDistance(p, 0))
+			}
+			d = Min(d, Max(x, w))
+			continue
+		}
+		// beyond an end of the coil, find the distance to the end face
+		t = Clamp(t, 0, s.turns)
+		sin, cos := math.Sincos(t * Tau)
+		q := V2{p.Z + s.length - t*s.pitch, p.X*cos + p.Y*sin - s.radius}
+		x := Max(s.profile.Evaluate(q)/s.k, 0)
+		w := s.endDistance(p, t)
+		d = Min(d, math.Sqrt(x*x+w*w))
+	}
+	if s.ground {
+		d = Max(d, Abs(p.Z)-s.length)
+	}
+	// distance inside the inner radius
+	h := s.r0 - r
+	return Max(h, Min(d, s.r0-h))
+}
+
+// endDistance returns the distance of a point from the plane of the profile
+// at t turns (positive in the direction of the coil).
+func (s *CoilSDF3) endDistance(p V3, t float64) float64 {
+	sin, cos := math.Sincos(t * Tau)
+	return p.Y*cos - p.X*sin
+}
+
+// BoundingBox returns the bounding box for a coil.
+func (s *CoilSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
 //-----------------------------------------------------------------------------
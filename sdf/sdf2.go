@@ -189,6 +189,133 @@ func (s *LineSDF2) BoundingBox() Box2 {
 	return s.bb
 }
 
+//-----------------------------------------------------------------------------
+// 2D Slot
+
+// SlotCap is the shape of the end of a slot.
+type SlotCap int
+
+// Slot end shapes.
+const (
+	SlotRound SlotCap = iota // semicircular end
+	SlotFlat                 // square end
+)
+
+// SlotSDF2 is the 2d signed distance object for a slot.
+type SlotSDF2 struct {
+	l      float64    // half length
+	r      float64    // radius (half width)
+	capEnd [2]SlotCap // end shapes (-x, +x)
+	bb     Box2       // bounding box
+}
+
+// SlotCaps2D returns a slot along the x axis with an overall length and a
+// radius (half the width). Each end of the slot (-x, +x) may be round or flat.
+func SlotCaps2D(length, radius float64, cap0, cap1 SlotCap) SDF2 {
+	if radius <= 0 {
+		panic("radius <= 0")
+	}
+	if length < 2*radius {
+		panic("length < 2 * radius")
+	}
+	s := SlotSDF2{}
+	s.l = 0.5 * length
+	s.r = radius
+	s.capEnd = [2]SlotCap{cap0, cap1}
+	s.bb = Box2{V2{-s.l, -radius}, V2{s.l, radius}}
+	return &s
+}
+
+// Slot2D returns a slot (a stadium) along the x axis with round ends. The
+// length is the overall length of the slot, the radius is half the width.
+func Slot2D(length, radius float64) SDF2 {
+	return SlotCaps2D(length, radius, SlotRound, SlotRound)
+}
+
+// Obround2D returns an obround that fits a box of the given size. The ends
+// are round on the shorter sides of the box.
+func Obround2D(size V2) SDF2 {
+	if size.X >= size.Y {
+		return Slot2D(size.X, 0.5*size.Y)
+	}
+	return Transform2D(Slot2D(size.Y, 0.5*size.X), Rotate2d(0.5*Pi))
+}
+
+// Evaluate returns the minimum distance to a 2d slot.
+func (s *SlotSDF2) Evaluate(p V2) float64 {
+	c := s.capEnd[0]
+	if p.X > 0 {
+		c = s.capEnd[1]
+	}
+	p = p.Abs()
+	if c == SlotFlat {
+		return sdfBox2d(p, V2{s.l, s.r})
+	}
+	x := s.l - s.r
+	if p.X <= x {
+		return p.Y - s.r
+	}
+	return p.Sub(V2{x, 0}).Length() - s.r
+}
+
+// BoundingBox returns the bounding box for a 2d slot.
+func (s *SlotSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// 2D Arc Slot
+
+// ArcSlotSDF2 is the 2d signed distance object for a curved slot.
+type ArcSlotSDF2 struct {
+	radius float64 // radius of the slot centerline
+	r      float64 // half width
+	end    V2      // center of the +y end
+	angle  float64 // half angle
+	bb     Box2    // bounding box
+}
+
+// ArcSlot2D returns a curved slot with round ends, E.g. for an adjustable
+// mount. The slot centerline is an arc of the radius about the origin that
+// subtends the angle and is centered on the positive x axis.
+func ArcSlot2D(radius, width, angle float64) SDF2 {
+	if width <= 0 || width >= 2*radius {
+		panic("bad slot width")
+	}
+	if angle <= 0 || angle >= Tau {
+		panic("bad slot angle")
+	}
+	s := ArcSlotSDF2{}
+	s.radius = radius
+	s.r = 0.5 * width
+	s.angle = 0.5 * angle
+	s.end = PolarToXY(radius, s.angle)
+	// bounding box of the centerline arc, extended by the half width
+	bb := Box2{s.end, s.end}.Include(V2{s.end.X, -s.end.Y}).Include(V2{radius, 0})
+	for _, a := range []float64{0.5 * Pi, Pi} {
+		if a <= s.angle {
+			v := PolarToXY(radius, a)
+			bb = bb.Include(v).Include(V2{v.X, -v.Y})
+		}
+	}
+	s.bb = Box2{bb.Min.SubScalar(s.r), bb.Max.AddScalar(s.r)}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a 2d arc slot.
+func (s *ArcSlotSDF2) Evaluate(p V2) float64 {
+	p.Y = Abs(p.Y)
+	if math.Atan2(p.Y, p.X) <= s.angle {
+		return Abs(p.Length()-s.radius) - s.r
+	}
+	return p.Sub(s.end).Length() - s.r
+}
+
+// BoundingBox returns the bounding box for a 2d arc slot.
+func (s *ArcSlotSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
 //-----------------------------------------------------------------------------
 
 // OffsetSDF2 offsets the distance function of an existing SDF2.
@@ -219,6 +346,34 @@ func (s *OffsetSDF2) BoundingBox() Box2 {
 	return s.bb
 }
 
+// OffsetJoin2D returns an SDF2 offset with a given corner join style. Round
+// joins are the same as Offset2D and work for any SDF2. Miter and bevel joins
+// keep or cut sharp corners (E.g. for laser kerf compensation) and need a
+// polygon (Polygon2D) or a box (Box2D) without rounding.
+func OffsetJoin2D(sdf SDF2, offset float64, join OffsetJoin, miterLimit float64) (SDF2, error) {
+	if join == JoinRound {
+		return Offset2D(sdf, offset), nil
+	}
+	var vertex []V2
+	switch s := sdf.(type) {
+	case *PolySDF2:
+		vertex = s.vertex
+	case *BoxSDF2:
+		if s.round != 0 {
+			return nil, errors.New("sharp offset joins need a box without rounding")
+		}
+		x, y := s.size.X, s.size.Y
+		vertex = []V2{{-x, -y}, {x, -y}, {x, y}, {-x, y}}
+	default:
+		return nil, errors.New("sharp offset joins need a polygon or box")
+	}
+	v := OffsetPolygon(vertex, offset, join, miterLimit)
+	if v == nil {
+		return nil, errors.New("bad polygon")
+	}
+	return Polygon2D(v), nil
+}
+
 //-----------------------------------------------------------------------------
 // Cut an SDF2 along a line
 
@@ -370,24 +525,27 @@ func (s *PolySDF2) Vertices() []V2 {
 type TransformSDF2 struct {
 	sdf  SDF2
 	mInv M33
+	k    float64 // distance scaling factor
 	bb   Box2
 }
 
 // Transform2D applies a transformation matrix to an SDF2.
-// Distance is *not* preserved with scaling.
+// With scaling the distance is scaled by the minimum scale factor of the
+// matrix, so it is a lower bound on the distance.
 func Transform2D(sdf SDF2, m M33) SDF2 {
 	s := TransformSDF2{}
 	s.sdf = sdf
 	s.mInv = m.Inverse()
+	s.k = transformScale(m.MinScale())
 	s.bb = m.MulBox(sdf.BoundingBox())
 	return &s
 }
 
 // Evaluate returns the minimum distance to a transformed SDF2.
-// Distance is *not* preserved with scaling.
 func (s *TransformSDF2) Evaluate(p V2) float64 {
 	q := s.mInv.MulPosition(p)
-	return s.sdf.Evaluate(q)
+	// Note: float64() prevents fused multiply-add (see determinism.go)
+	return float64(s.sdf.Evaluate(q) * s.k)
 }
 
 // BoundingBox returns the bounding box of a transformed SDF2.
@@ -411,7 +569,7 @@ func ScaleUniform2D(sdf SDF2, k float64) SDF2 {
 	m := Scale2d(V2{k, k})
 	return &ScaleUniformSDF2{
 		sdf:  sdf,
-		k:    k,
+		k:    Abs(k),
 		invk: 1.0 / k,
 		bb:   m.MulBox(sdf.BoundingBox()),
 	}
@@ -428,6 +586,15 @@ func (s *ScaleUniformSDF2) BoundingBox() Box2 {
 	return s.bb
 }
 
+// Scale2D scales an SDF2 by different amounts on each axis. The distance is
+// scaled by the minimum scale factor, so it is a lower bound on the distance.
+func Scale2D(sdf SDF2, k V2) SDF2 {
+	if k.X == k.Y {
+		return ScaleUniform2D(sdf, k.X)
+	}
+	return Transform2D(sdf, Scale2d(k))
+}
+
 //-----------------------------------------------------------------------------
 
 // Center2D centers the origin of an SDF2 on it's bounding box.
@@ -597,6 +764,138 @@ func (s *RotateCopySDF2) BoundingBox() Box2 {
 	return s.bb
 }
 
+//-----------------------------------------------------------------------------
+// 2D Domain Repetition
+
+// RepeatSDF2 repeats an SDF2 on an XY grid by folding the evaluation point
+// into a grid cell.
+type RepeatSDF2 struct {
+	sdf  SDF2
+	num  V2i
+	step V2
+	min  MinFunc
+	bb   Box2
+}
+
+// Repeat2D returns an XY grid of copies of an SDF2, the same as Array2D.
+// Rather than evaluating every copy, the point is folded into the nearest grid
+// cell and only that cell and its neighbours are evaluated. The SDF2 should
+// not extend past the neighbouring cells.
+func Repeat2D(sdf SDF2, num V2i, step V2) SDF2 {
+	if num[0] <= 0 || num[1] <= 0 {
+		return nil
+	}
+	s := RepeatSDF2{}
+	s.sdf = sdf
+	s.num = num
+	s.step = step
+	s.min = Min
+	bb0 := sdf.BoundingBox()
+	bb1 := bb0.Translate(step.Mul(num.SubScalar(1).ToV2()))
+	s.bb = bb0.Extend(bb1)
+	return &s
+}
+
+// SetMin sets the minimum function to control blending.
+func (s *RepeatSDF2) SetMin(min MinFunc) {
+	s.min = min
+}
+
+// repeatCell returns the index of the nearest cell in a row of n cells.
+func repeatCell(x, step float64, n int) int {
+	if step == 0 {
+		return 0
+	}
+	i := int(math.Floor(x/step + 0.5))
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+// Evaluate returns the minimum distance to a grid of repeated SDF2s.
+func (s *RepeatSDF2) Evaluate(p V2) float64 {
+	i0 := repeatCell(p.X, s.step.X, s.num[0])
+	j0 := repeatCell(p.Y, s.step.Y, s.num[1])
+	d := math.MaxFloat64
+	for i := i0 - 1; i <= i0+1; i++ {
+		if i < 0 || i >= s.num[0] {
+			continue
+		}
+		for j := j0 - 1; j <= j0+1; j++ {
+			if j < 0 || j >= s.num[1] {
+				continue
+			}
+			x := p.Sub(V2{float64(i) * s.step.X, float64(j) * s.step.Y})
+			d = s.min(d, s.sdf.Evaluate(x))
+		}
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a grid of repeated SDF2s.
+func (s *RepeatSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
+// PolarRepeatSDF2 repeats an SDF2 n times in a full circle by folding the
+// evaluation point into a sector.
+type PolarRepeatSDF2 struct {
+	sdf   SDF2
+	num   int
+	theta float64
+	min   MinFunc
+	bb    Box2
+}
+
+// PolarRepeat2D returns n copies of an SDF2 rotated about the origin. Only
+// the nearest copy and its neighbours are evaluated, so unlike RotateCopy2D
+// the copies may cross the sector boundaries.
+func PolarRepeat2D(sdf SDF2, n int) SDF2 {
+	if n <= 0 {
+		return nil
+	}
+	s := PolarRepeatSDF2{}
+	s.sdf = sdf
+	s.num = n
+	s.theta = Tau / float64(n)
+	s.min = Min
+	rmax := 0.0
+	for _, v := range sdf.BoundingBox().Vertices() {
+		rmax = Max(rmax, v.Length())
+	}
+	s.bb = Box2{V2{-rmax, -rmax}, V2{rmax, rmax}}
+	return &s
+}
+
+// SetMin sets the minimum function to control blending.
+func (s *PolarRepeatSDF2) SetMin(min MinFunc) {
+	s.min = min
+}
+
+// Evaluate returns the minimum distance to the polar repeated SDF2s.
+func (s *PolarRepeatSDF2) Evaluate(p V2) float64 {
+	k := math.Floor(math.Atan2(p.Y, p.X)/s.theta + 0.5)
+	d := math.MaxFloat64
+	for i := -1; i <= 1; i++ {
+		if s.num < 3 && i == 1 {
+			// the neighbours are the same copy
+			break
+		}
+		x := Rotate(-(k + float64(i)) * s.theta).MulPosition(p)
+		d = s.min(d, s.sdf.Evaluate(x))
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of the polar repeated SDF2s.
+func (s *PolarRepeatSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
 //-----------------------------------------------------------------------------
 
 // SliceSDF2 creates an SDF2 from a planar slice through an SDF3.
@@ -614,31 +913,59 @@ func Slice2D(
 	a V3, // point on slicing plane
 	n V3, // normal to slicing plane
 ) SDF2 {
-	s := SliceSDF2{}
-	s.sdf = sdf
-	s.a = a
 	// work out the x/y vectors on the plane.
+	var u V3
 	if n.X == 0 {
-		s.u = V3{1, 0, 0}
+		u = V3{1, 0, 0}
 	} else if n.Y == 0 {
-		s.u = V3{0, 1, 0}
+		u = V3{0, 1, 0}
 	} else if n.Z == 0 {
-		s.u = V3{0, 0, 1}
+		u = V3{0, 0, 1}
 	} else {
-		s.u = V3{n.Y, -n.X, 0}
+		u = V3{n.Y, -n.X, 0}
+	}
+	return newSlice2D(sdf, a, n, u)
+}
+
+// SliceAxis2D returns an SDF2 created from a planar slice through an SDF3,
+// with a given direction for the 2d x-axis. The direction is projected onto
+// the slicing plane. An error is returned if the normal is zero or the x-axis
+// direction is parallel to the normal.
+func SliceAxis2D(
+	sdf SDF3, // SDF3 to be sliced
+	a V3, // point on slicing plane
+	n V3, // normal to slicing plane
+	x V3, // direction of the 2d x-axis
+) (SDF2, error) {
+	if n.Length() == 0 {
+		return nil, errors.New("slice plane normal is zero")
 	}
-	s.v = n.Cross(s.u)
-	s.u = s.u.Normalize()
+	n = n.Normalize()
+	u := x.Sub(n.MulScalar(n.Dot(x)))
+	if l := u.Length(); l == 0 || l < tolerance*x.Length() {
+		return nil, errors.New("x-axis is parallel to the slice plane normal")
+	}
+	return newSlice2D(sdf, a, n, u), nil
+}
+
+// newSlice2D returns an SDF2 for a planar slice with 2d x-axis u (on the plane).
+func newSlice2D(sdf SDF3, a, n, u V3) *SliceSDF2 {
+	s := SliceSDF2{}
+	s.sdf = sdf
+	s.a = a
+	s.v = n.Cross(u)
+	s.u = u.Normalize()
 	s.v = s.v.Normalize()
 	// work out the bounding box
-	// TODO: This is bigger than it needs to be. We could consider intersection
-	// between the plane and the edges of the 3d bounding box for a smaller 2d
-	// bounding box in some circumstances.
-	v3 := sdf.BoundingBox().Vertices()
-	v2 := make(V2Set, len(v3))
 	n = n.Normalize()
+	v3 := slicePoints(sdf.BoundingBox(), a, n)
+	if len(v3) == 0 {
+		// the plane doesn't intersect the bounding box
+		v3 = sdf.BoundingBox().Vertices()
+	}
+	v2 := make(V2Set, len(v3))
 	for i, v := range v3 {
-		// project the 3d bounding box vertex onto the plane
+		// project the 3d point onto the plane
 		va := v.Sub(s.a)
 		pa := va.Sub(n.MulScalar(n.Dot(va)))
 		// work out the 3d point in terms of the 2d unit vectors
@@ -648,6 +975,46 @@ func Slice2D(
 	return &s
 }
 
+// Placement returns the matrix that maps the 2d xy-plane (z = 0) onto the
+// slicing plane. E.g. an extrusion of the slice can be placed back onto the
+// SDF3 with Transform3D(Extrude3D(slice, h), slice.Placement()).
+func (s *SliceSDF2) Placement() M44 {
+	n := s.u.Cross(s.v)
+	return M44{
+		s.u.X, s.v.X, n.X, s.a.X,
+		s.u.Y, s.v.Y, n.Y, s.a.Y,
+		s.u.Z, s.v.Z, n.Z, s.a.Z,
+		0, 0, 0, 1}
+}
+
+// slicePoints returns the intersection points of a plane with the edges of a 3d box.
+func slicePoints(bb Box3, a, n V3) []V3 {
+	v := bb.Vertices()
+	var p []V3
+	for i := range v {
+		for j := i + 1; j < len(v); j++ {
+			d := v[j].Sub(v[i])
+			// box edges differ in one coordinate only
+			zeros := 0
+			for _, x := range []float64{d.X, d.Y, d.Z} {
+				if x == 0 {
+					zeros++
+				}
+			}
+			if zeros != 2 {
+				continue
+			}
+			d0 := n.Dot(v[i].Sub(a))
+			d1 := n.Dot(v[j].Sub(a))
+			if d0*d1 > 0 || d0 == d1 {
+				continue
+			}
+			p = append(p, v[i].Add(d.MulScalar(d0/(d0-d1))))
+		}
+	}
+	return p
+}
+
 // Evaluate returns the minimum distance to the sliced SDF2.
 func (s *SliceSDF2) Evaluate(p V2) float64 {
 	pnew := s.a.Add(s.u.MulScalar(p.X)).Add(s.v.MulScalar(p.Y))
@@ -799,6 +1166,74 @@ func (s *DifferenceSDF2) BoundingBox() Box2 {
 
 //-----------------------------------------------------------------------------
 
+// MorphSDF2 is a linear interpolation between two SDF2s.
+type MorphSDF2 struct {
+	s0, s1 SDF2
+	t      float64 // interpolation factor
+	bb     Box2
+}
+
+// Morph2D returns an SDF2 that interpolates between s0 (t = 0) and s1 (t = 1).
+func Morph2D(s0, s1 SDF2, t float64) SDF2 {
+	s := MorphSDF2{}
+	s.s0 = s0
+	s.s1 = s1
+	s.t = t
+	// work out the bounding box
+	switch {
+	case t <= 0:
+		s.bb = s0.BoundingBox()
+	case t >= 1:
+		s.bb = s1.BoundingBox()
+	default:
+		// a point outside both SDF2s is outside the morph
+		s.bb = s0.BoundingBox().Extend(s1.BoundingBox())
+	}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a morphed SDF2.
+func (s *MorphSDF2) Evaluate(p V2) float64 {
+	return Mix(s.s0.Evaluate(p), s.s1.Evaluate(p), s.t)
+}
+
+// BoundingBox returns the bounding box of a morphed SDF2.
+func (s *MorphSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
+// MorphKey2 is a keyframe for a keyframed 2D morph.
+type MorphKey2 struct {
+	T   float64 // keyframe position
+	SDF SDF2    // keyframe SDF2
+}
+
+// Keyframe2D returns the morph of a sequence of keyframes at position t.
+// The keyframes must be in increasing order of position. The morph is a linear
+// interpolation between the two keyframes on either side of t.
+func Keyframe2D(keys []MorphKey2, t float64) (SDF2, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keyframes")
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i].T <= keys[i-1].T {
+			return nil, errors.New("keyframes are not in increasing order")
+		}
+	}
+	if t <= keys[0].T {
+		return keys[0].SDF, nil
+	}
+	for i := 1; i < len(keys); i++ {
+		if t <= keys[i].T {
+			k0, k1 := keys[i-1], keys[i]
+			return Morph2D(k0.SDF, k1.SDF, (t-k0.T)/(k1.T-k0.T)), nil
+		}
+	}
+	return keys[len(keys)-1].SDF, nil
+}
+
+//-----------------------------------------------------------------------------
+
 // ElongateSDF2 is the elongation of an SDF2.
 type ElongateSDF2 struct {
 	sdf    SDF2 // the sdf being elongated
@@ -833,6 +1268,44 @@ func (s *ElongateSDF2) BoundingBox() Box2 {
 	return s.bb
 }
 
+// ElongateDirSDF2 is the elongation of an SDF2 along a direction.
+type ElongateDirSDF2 struct {
+	sdf SDF2    // the sdf being elongated
+	u   V2      // unit elongation direction
+	h   float64 // half elongation length
+	bb  Box2    // bounding box
+}
+
+// ElongateDir2D returns the elongation of an SDF2 along a direction.
+// The SDF2 is split at the plane through the origin normal to the direction,
+// and the halves are moved apart by length.
+func ElongateDir2D(sdf SDF2, dir V2, length float64) SDF2 {
+	if dir.Length() == 0 {
+		panic("dir length is zero")
+	}
+	s := ElongateDirSDF2{}
+	s.sdf = sdf
+	s.u = dir.Normalize()
+	s.h = 0.5 * Abs(length)
+	// bounding box
+	bb := sdf.BoundingBox()
+	bb0 := bb.Translate(s.u.MulScalar(s.h))
+	bb1 := bb.Translate(s.u.MulScalar(-s.h))
+	s.bb = bb0.Extend(bb1)
+	return &s
+}
+
+// Evaluate returns the minimum distance to an elongated SDF2.
+func (s *ElongateDirSDF2) Evaluate(p V2) float64 {
+	q := p.Sub(s.u.MulScalar(Clamp(p.Dot(s.u), -s.h, s.h)))
+	return s.sdf.Evaluate(q)
+}
+
+// BoundingBox returns the bounding box of an elongated SDF2.
+func (s *ElongateDirSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
 //-----------------------------------------------------------------------------
 
 // GenerateMesh2D generates a set of internal mesh points for an SDF2.
@@ -9,6 +9,7 @@
 package sdf
 
 import (
+	"errors"
 	"math"
 )
 
@@ -60,16 +61,19 @@ func sdfBox3d(p, s V3) float64 {
 
 // SorSDF3 solid of revolution, SDF2 to SDF3.
 type SorSDF3 struct {
-	sdf   SDF2
-	theta float64 // angle for partial revolutions
-	norm  V2      // pre-calculated normal to theta line
-	bb    Box3
+	sdf     SDF2
+	theta   float64 // angle for partial revolutions
+	norm    V2      // pre-calculated normal to theta line
+	inverse M44     // world to revolve frame transform
+	axis    bool    // revolve about an arbitrary axis (apply the inverse transform)
+	bb      Box3
 }
 
 // RevolveTheta3D returns an SDF3 for a solid of revolution.
 func RevolveTheta3D(sdf SDF2, theta float64) SDF3 {
 	s := SorSDF3{}
 	s.sdf = sdf
+	s.inverse = Identity3d()
 	// normalize theta
 	s.theta = math.Mod(Abs(theta), Tau)
 	sin := math.Sin(s.theta)
@@ -105,8 +109,62 @@ func Revolve3D(sdf SDF2) SDF3 {
 	return RevolveTheta3D(sdf, 0)
 }
 
+// RevolveAxis3D returns an SDF3 for a solid of revolution about an arbitrary
+// axis. The SDF2 x-axis is the radius and the y-axis is the position along the
+// revolve axis. A partial revolution goes from the start angle through theta.
+// Angles are measured from the x-axis, as rotated by the minimum rotation that
+// takes the z-axis onto the revolve axis.
+func RevolveAxis3D(
+	sdf SDF2, // SDF2 to be revolved
+	origin V3, // point on the revolve axis
+	axis V3, // direction of the revolve axis
+	start float64, // start angle for partial revolutions (radians)
+	theta float64, // angle for partial revolutions (radians, 0 = full revolution)
+) SDF3 {
+	if axis.Length() == 0 {
+		panic("axis length is zero")
+	}
+	s := RevolveTheta3D(sdf, theta).(*SorSDF3)
+	// rotate the z-axis onto the revolve axis
+	a := axis.Normalize()
+	z := V3{0, 0, 1}
+	var r M44
+	if k := z.Cross(a); k.Length() > tolerance {
+		r = Rotate3d(k, math.Acos(Clamp(a.Z, -1, 1)))
+	} else if a.Z > 0 {
+		r = Identity3d()
+	} else {
+		r = RotateX(Pi)
+	}
+	m := Translate3d(origin).Mul(r)
+	s.inverse = m.Mul(RotateZ(start)).Inverse()
+	s.axis = true
+	// work out the bounding box of the revolution from the start angle
+	bb := s.sdf.BoundingBox()
+	l := Max(Abs(bb.Min.X), Abs(bb.Max.X))
+	vset := V2Set{{1, 1}, {-1, -1}}
+	if s.theta != 0 {
+		vset = V2Set{{0, 0}}
+		t0 := start
+		t1 := start + s.theta
+		vset = append(vset, V2{math.Cos(t0), math.Sin(t0)}, V2{math.Cos(t1), math.Sin(t1)})
+		// the axis directions crossed by the revolution
+		axes := []V2{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+		for k := math.Ceil(t0 / (0.5 * Pi)); k*0.5*Pi < t1; k++ {
+			vset = append(vset, axes[int(math.Mod(math.Mod(k, 4)+4, 4))])
+		}
+	}
+	vmin := vset.Min().MulScalar(l)
+	vmax := vset.Max().MulScalar(l)
+	s.bb = m.MulBox(Box3{V3{vmin.X, vmin.Y, bb.Min.Y}, V3{vmax.X, vmax.Y, bb.Max.Y}})
+	return s
+}
+
 // Evaluate returns the minimum distance to a solid of revolution.
 func (s *SorSDF3) Evaluate(p V3) float64 {
+	if s.axis {
+		p = s.inverse.MulPosition(p)
+	}
 	x := math.Sqrt(p.X*p.X + p.Y*p.Y)
 	a := s.sdf.Evaluate(V2{x, p.Z})
 	b := a
@@ -210,6 +268,98 @@ func (s *ExtrudeSDF3) BoundingBox() Box3 {
 	return s.bb
 }
 
+//-----------------------------------------------------------------------------
+
+// variableExtrudeSamples is the number of z samples used to bound a variable extrusion.
+const variableExtrudeSamples = 256
+
+// VariableExtrudeSDF3 extrudes an SDF2 with a twist and scale that are functions of z.
+type VariableExtrudeSDF3 struct {
+	sdf    SDF2
+	height float64                 // half height
+	twist  func(z float64) float64 // twist angle at height z
+	scale  func(z float64) V2      // xy scale at height z
+	k      float64                 // distance scaling factor
+	bb     Box3
+}
+
+// VariableExtrude3D extrudes an SDF2 while twisting and scaling it.
+// The twist angle (radians, counter-clockwise) and xy scale are functions of
+// z, where z ranges from -height/2 to height/2. A nil twist or scale function
+// means no twist or scaling. The profile is scaled and then twisted.
+func VariableExtrude3D(
+	sdf SDF2, // profile to extrude
+	height float64, // height of extrusion
+	twist func(z float64) float64, // twist angle as a function of z
+	scale func(z float64) V2, // xy scale as a function of z
+) SDF3 {
+	s := VariableExtrudeSDF3{}
+	s.sdf = sdf
+	s.height = height / 2
+	s.twist = twist
+	if s.twist == nil {
+		s.twist = func(z float64) float64 { return 0 }
+	}
+	s.scale = scale
+	if s.scale == nil {
+		s.scale = func(z float64) V2 { return V2{1, 1} }
+	}
+	// Sample z to find the minimum scale and the maximum rates of change.
+	// The profile is evaluated at a point that moves with z, so the distance
+	// is scaled down to keep it conservative.
+	bb := sdf.BoundingBox()
+	l := Max(bb.Min.Length(), bb.Max.Length())
+	dz := height / variableExtrudeSamples
+	smin := math.MaxFloat64
+	smax := 0.0
+	rate := 0.0
+	var bbXY Box2
+	for i := 0; i <= variableExtrudeSamples; i++ {
+		z := -s.height + float64(i)*dz
+		k := s.scale(z)
+		if k.X <= 0 || k.Y <= 0 {
+			panic("scale <= 0")
+		}
+		smin = Min(smin, Min(k.X, k.Y))
+		smax = Max(smax, Max(k.X, k.Y))
+		scaled := Box2{bb.Min.Mul(k), bb.Max.Mul(k)}
+		if i == 0 {
+			bbXY = scaled
+		} else {
+			bbXY = bbXY.Extend(scaled)
+			k0 := s.scale(z - dz)
+			ds := k.Sub(k0).Abs().Div(k0.Min(k)).MaxComponent() / dz
+			dt := Abs(s.twist(z)-s.twist(z-dz)) / dz
+			rate = Max(rate, ds+dt)
+		}
+	}
+	s.k = math.Sqrt(1/(smin*smin) + (l*rate)*(l*rate))
+	// work out the bounding box
+	if twist != nil {
+		l *= smax
+		bbXY = Box2{V2{-l, -l}, V2{l, l}}
+	}
+	s.bb = Box3{V3{bbXY.Min.X, bbXY.Min.Y, -s.height}, V3{bbXY.Max.X, bbXY.Max.Y, s.height}}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a variable extrusion.
+func (s *VariableExtrudeSDF3) Evaluate(p V3) float64 {
+	z := Clamp(p.Z, -s.height, s.height)
+	q := Rotate(-s.twist(z)).MulPosition(V2{p.X, p.Y}).Div(s.scale(z))
+	// sdf for the projected 2d surface
+	a := s.sdf.Evaluate(q) / s.k
+	// sdf for the extrusion region: z = [-height, height]
+	b := Abs(p.Z) - s.height
+	// return the intersection
+	return Max(a, b)
+}
+
+// BoundingBox returns the bounding box for a variable extrusion.
+func (s *VariableExtrudeSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
 //-----------------------------------------------------------------------------
 // Linear extrude an SDF2 with rounded edges.
 // Note: The height of the extrusion is adjusted for the rounding.
@@ -344,6 +494,90 @@ func (s *LoftSDF3) BoundingBox() Box3 {
 	return s.bb
 }
 
+// keyframeLoftSamples is the number of x and y samples used to bound the rate
+// of change of a keyframed loft.
+const keyframeLoftSamples = 32
+
+// KeyframeLoftSDF3 is an extrusion through a sequence of SDF2 sections.
+type KeyframeLoftSDF3 struct {
+	keys []MorphKey2
+	k    float64 // distance scaling for the rate of change with z
+	bb   Box3
+}
+
+// KeyframeLoft3D extrudes an SDF3 that transitions through a sequence of SDF2
+// sections. The keyframe position is the z height of each section. Between the
+// sections the shape is the morph of the sections on either side.
+func KeyframeLoft3D(keys []MorphKey2) (SDF3, error) {
+	if len(keys) < 2 {
+		return nil, errors.New("less than 2 keyframes")
+	}
+	// check the keys
+	if _, err := Keyframe2D(keys, 0); err != nil {
+		return nil, err
+	}
+	s := KeyframeLoftSDF3{}
+	s.keys = keys
+	bb := keys[0].SDF.BoundingBox()
+	for _, k := range keys {
+		bb = bb.Extend(k.SDF.BoundingBox())
+	}
+	z0, z1 := keys[0].T, keys[len(keys)-1].T
+	s.bb = Box3{V3{bb.Min.X, bb.Min.Y, z0}, V3{bb.Max.X, bb.Max.Y, z1}}
+	// Sample the sections to find the maximum rate of change of the morphed
+	// distance with z. The difference of two distances changes by at most 2
+	// per unit of x/y distance and every point is within half a sample
+	// diagonal of a sample, so allow for that.
+	bb = bb.ScaleAboutCenter(1.5)
+	step := bb.Size().DivScalar(keyframeLoftSamples)
+	margin := step.Length()
+	rate := 0.0
+	for i := 0; i <= keyframeLoftSamples; i++ {
+		for j := 0; j <= keyframeLoftSamples; j++ {
+			q := bb.Min.Add(V2{float64(i), float64(j)}.Mul(step))
+			for n := 1; n < len(keys); n++ {
+				k0, k1 := keys[n-1], keys[n]
+				dd := Abs(k1.SDF.Evaluate(q)-k0.SDF.Evaluate(q)) + margin
+				rate = Max(rate, dd/(k1.T-k0.T))
+			}
+		}
+	}
+	s.k = math.Sqrt(1 + rate*rate)
+	return &s, nil
+}
+
+// Evaluate returns the minimum distance to a keyframed loft extrusion.
+func (s *KeyframeLoftSDF3) Evaluate(p V3) float64 {
+	z0, z1 := s.keys[0].T, s.keys[len(s.keys)-1].T
+	// morph the sections on either side of z
+	q := V2{p.X, p.Y}
+	z := Clamp(p.Z, z0, z1)
+	i := 1
+	for i < len(s.keys)-1 && z > s.keys[i].T {
+		i++
+	}
+	k0, k1 := s.keys[i-1], s.keys[i]
+	a := Mix(k0.SDF.Evaluate(q), k1.SDF.Evaluate(q), (z-k0.T)/(k1.T-k0.T)) / s.k
+	b := Max(z0-p.Z, p.Z-z1)
+	if b > 0 {
+		// outside the object Z extent
+		if a < 0 {
+			return b
+		}
+		return math.Sqrt((a * a) + (b * b))
+	}
+	// within the object Z extent
+	if a < 0 {
+		return Max(a, b)
+	}
+	return a
+}
+
+// BoundingBox returns the bounding box for a keyframed loft extrusion.
+func (s *KeyframeLoftSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
 //-----------------------------------------------------------------------------
 // Box (exact distance field)
 
@@ -424,9 +658,10 @@ func Cylinder3D(height, radius, round float64) SDF3 {
 	return &s
 }
 
-// Capsule3D return an SDF3 for a capsule.
+// Capsule3D return an SDF3 for a capsule (a cylinder with hemispherical ends).
+// The height is the overall height of the capsule.
 func Capsule3D(radius, height float64) SDF3 {
-	return Cylinder3D(radius, height, radius)
+	return Cylinder3D(height, radius, radius)
 }
 
 // Evaluate returns the minimum distance to a cylinder.
@@ -552,6 +787,253 @@ func (s *ConeSDF3) BoundingBox() Box3 {
 	return s.bb
 }
 
+//-----------------------------------------------------------------------------
+// Torus (exact distance field)
+
+// TorusSDF3 is a torus.
+type TorusSDF3 struct {
+	major float64 // radius of the tube center
+	minor float64 // radius of the tube
+	bb    Box3
+}
+
+// Torus3D returns an SDF3 for a torus about the z-axis.
+func Torus3D(major, minor float64) SDF3 {
+	if major < 0 {
+		panic("major < 0")
+	}
+	if minor <= 0 {
+		panic("minor <= 0")
+	}
+	s := TorusSDF3{}
+	s.major = major
+	s.minor = minor
+	r := major + minor
+	s.bb = Box3{V3{-r, -r, -minor}, V3{r, r, minor}}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a torus.
+func (s *TorusSDF3) Evaluate(p V3) float64 {
+	q := V2{V2{p.X, p.Y}.Length() - s.major, p.Z}
+	return q.Length() - s.minor
+}
+
+// BoundingBox returns the bounding box for a torus.
+func (s *TorusSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Ellipsoid (bounded distance field)
+
+// EllipsoidSDF3 is an ellipsoid.
+type EllipsoidSDF3 struct {
+	radii V3      // radii on each axis
+	k     float64 // minimum radius
+	bb    Box3
+}
+
+// Ellipsoid3D returns an SDF3 for an ellipsoid with the given radii on each
+// axis. The distance is exact for a sphere, otherwise it is a lower bound.
+func Ellipsoid3D(radii V3) SDF3 {
+	if radii.X <= 0 || radii.Y <= 0 || radii.Z <= 0 {
+		panic("radii <= 0")
+	}
+	s := EllipsoidSDF3{}
+	s.radii = radii
+	s.k = radii.MinComponent()
+	s.bb = Box3{radii.Neg(), radii}
+	return &s
+}
+
+// Evaluate returns the minimum distance to an ellipsoid.
+func (s *EllipsoidSDF3) Evaluate(p V3) float64 {
+	// a unit sphere scaled by the radii, the distance is scaled by the minimum radius
+	return (p.Div(s.radii).Length() - 1) * s.k
+}
+
+// BoundingBox returns the bounding box for an ellipsoid.
+func (s *EllipsoidSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Rounded Cone (exact distance field)
+// See: https://iquilezles.org/articles/distfunctions/
+
+// RoundedConeSDF3 is a cone with spherical ends.
+type RoundedConeSDF3 struct {
+	r0, r1 float64 // radius of the bottom and top spheres
+	height float64 // distance between the sphere centers
+	a, b   float64 // cone slope
+	bb     Box3
+}
+
+// RoundedCone3D returns an SDF3 for the convex hull of two spheres on the
+// z-axis: a sphere of radius r0 centered at z = -height/2, and a sphere of
+// radius r1 centered at z = height/2.
+func RoundedCone3D(height, r0, r1 float64) SDF3 {
+	if r0 < 0 || r1 < 0 {
+		panic("radius < 0")
+	}
+	if height <= Abs(r0-r1) {
+		panic("height <= |r0 - r1|")
+	}
+	s := RoundedConeSDF3{}
+	s.r0 = r0
+	s.r1 = r1
+	s.height = height
+	s.b = (r0 - r1) / height
+	s.a = math.Sqrt(1 - s.b*s.b)
+	r := Max(r0, r1)
+	s.bb = Box3{V3{-r, -r, -0.5*height - r0}, V3{r, r, 0.5*height + r1}}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a rounded cone.
+func (s *RoundedConeSDF3) Evaluate(p V3) float64 {
+	// 2d coordinates relative to the bottom sphere center
+	q := V2{V2{p.X, p.Y}.Length(), p.Z + 0.5*s.height}
+	k := q.Dot(V2{-s.b, s.a})
+	if k < 0 {
+		return q.Length() - s.r0
+	}
+	if k > s.a*s.height {
+		return q.Sub(V2{0, s.height}).Length() - s.r1
+	}
+	return q.Dot(V2{s.a, s.b}) - s.r0
+}
+
+// BoundingBox returns the bounding box for a rounded cone.
+func (s *RoundedConeSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Pyramid (exact distance field)
+// See: https://iquilezles.org/articles/distfunctions/
+
+// PyramidSDF3 is a square based pyramid.
+type PyramidSDF3 struct {
+	size   float64 // size of the base
+	height float64 // height (relative to the base size)
+	m2     float64
+	bb     Box3
+}
+
+// Pyramid3D returns an SDF3 for a square based pyramid. The base (with sides
+// of the given size) is at z = -height/2 and the apex is at z = height/2.
+func Pyramid3D(size, height float64) SDF3 {
+	if size <= 0 {
+		panic("size <= 0")
+	}
+	if height <= 0 {
+		panic("height <= 0")
+	}
+	s := PyramidSDF3{}
+	s.size = size
+	s.height = height / size
+	s.m2 = s.height*s.height + 0.25
+	d := V3{0.5 * size, 0.5 * size, 0.5 * height}
+	s.bb = Box3{d.Neg(), d}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a pyramid.
+func (s *PyramidSDF3) Evaluate(p V3) float64 {
+	// work with a unit base, and the base at z = 0
+	p = p.DivScalar(s.size)
+	x, y, z := Abs(p.X), Abs(p.Y), p.Z+0.5*s.height
+	if z <= 0 {
+		// below the base
+		return V3{Max(x-0.5, 0), Max(y-0.5, 0), z}.Length() * s.size
+	}
+	if y > x {
+		x, y = y, x
+	}
+	x -= 0.5
+	y -= 0.5
+	h, m2 := s.height, s.m2
+	q := V3{y, h*z - 0.5*x, h*x + 0.5*z}
+	a0 := Max(-q.X, 0)
+	t := Clamp((q.Y-0.5*y)/(m2+0.25), 0, 1)
+	a := m2*(q.X+a0)*(q.X+a0) + q.Y*q.Y
+	b := m2*(q.X+0.5*t)*(q.X+0.5*t) + (q.Y-m2*t)*(q.Y-m2*t)
+	d2 := Min(a, b)
+	if Min(q.Y, -q.X*m2-q.Y*0.5) > 0 {
+		d2 = 0
+	}
+	d := math.Sqrt((d2 + q.Z*q.Z) / m2)
+	if Max(q.Z, -z) < 0 {
+		// inside, the base may be closer than the sides
+		d = Max(-d, -z)
+	}
+	return d * s.size
+}
+
+// BoundingBox returns the bounding box for a pyramid.
+func (s *PyramidSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Super Quadric (bounded distance field)
+
+// SuperQuadricSDF3 is a superellipsoid.
+type SuperQuadricSDF3 struct {
+	radii  V3      // radii on each axis
+	e1, e2 float64 // north-south and east-west shape exponents
+	k      float64 // inradius
+	bb     Box3
+}
+
+// SuperQuadric3D returns an SDF3 for a superellipsoid of a given size.
+// The exponents control the shape of the vertical (e1) and horizontal (e2)
+// cross sections: 1 is elliptical, values towards 0 are increasingly square,
+// and 2 is diamond shaped. The distance is a lower bound.
+func SuperQuadric3D(e1, e2 float64, size V3) SDF3 {
+	if e1 <= 0 || e1 > 2 || e2 <= 0 || e2 > 2 {
+		panic("exponents must be in (0, 2]")
+	}
+	if size.X <= 0 || size.Y <= 0 || size.Z <= 0 {
+		panic("size <= 0")
+	}
+	s := SuperQuadricSDF3{}
+	s.radii = size.MulScalar(0.5)
+	s.e1 = e1
+	s.e2 = e2
+	// The inradius of the unit superellipsoid is 1 for exponents <= 1, and for
+	// larger exponents is no less than that of the unit superellipsoid with
+	// both exponents at the maximum, where the minimum is on the diagonal.
+	s.k = s.radii.MinComponent() * Min(1, math.Pow(3, 0.5*(1-Max(e1, e2))))
+	s.bb = Box3{s.radii.Neg(), s.radii}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a superellipsoid.
+func (s *SuperQuadricSDF3) Evaluate(p V3) float64 {
+	q := p.Div(s.radii).Abs()
+	m := q.MaxComponent()
+	if m == 0 {
+		return -s.k
+	}
+	// The shape function is homogeneous (degree 1) so normalize to avoid
+	// overflow with small exponents.
+	q = q.DivScalar(m)
+	xy := math.Pow(math.Pow(q.X, 2/s.e2)+math.Pow(q.Y, 2/s.e2), s.e2/s.e1)
+	g := m * math.Pow(xy+math.Pow(q.Z, 2/s.e1), 0.5*s.e1)
+	// The shape is convex and contains a sphere of radius k, so the shape
+	// function has a Lipschitz constant of 1/k. The bounding box
+	// distance is also a lower bound and is better for elongated shapes.
+	return Max((g-1)*s.k, sdfBox3d(p, s.radii))
+}
+
+// BoundingBox returns the bounding box for a superellipsoid.
+func (s *SuperQuadricSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
 //-----------------------------------------------------------------------------
 // Transform SDF3 (rotation, translation - distance preserving)
 
@@ -560,23 +1042,37 @@ type TransformSDF3 struct {
 	sdf     SDF3
 	matrix  M44
 	inverse M44
+	k       float64 // distance scaling factor
 	bb      Box3
 }
 
 // Transform3D applies a transformation matrix to an SDF3.
+// With scaling the distance is scaled by the minimum scale factor of the
+// matrix, so it is a lower bound on the distance.
 func Transform3D(sdf SDF3, matrix M44) SDF3 {
 	s := TransformSDF3{}
 	s.sdf = sdf
 	s.matrix = matrix
 	s.inverse = matrix.Inverse()
+	s.k = transformScale(matrix.MinScale())
 	s.bb = matrix.MulBox(sdf.BoundingBox())
 	return &s
 }
 
+// transformScale returns the distance scaling factor for a transform with a
+// given minimum scale. Rotations and translations don't scale the distance,
+// so ignore rounding errors.
+func transformScale(k float64) float64 {
+	if EqualFloat64(k, 1, tolerance) {
+		return 1
+	}
+	return k
+}
+
 // Evaluate returns the minimum distance to a transformed SDF3.
-// Distance is *not* preserved with scaling.
 func (s *TransformSDF3) Evaluate(p V3) float64 {
-	return s.sdf.Evaluate(s.inverse.MulPosition(p))
+	// Note: float64() prevents fused multiply-add (see determinism.go)
+	return float64(s.sdf.Evaluate(s.inverse.MulPosition(p)) * s.k)
 }
 
 // BoundingBox returns the bounding box of a transformed SDF3.
@@ -599,7 +1095,7 @@ func ScaleUniform3D(sdf SDF3, k float64) SDF3 {
 	m := Scale3d(V3{k, k, k})
 	return &ScaleUniformSDF3{
 		sdf:  sdf,
-		k:    k,
+		k:    Abs(k),
 		invK: 1.0 / k,
 		bb:   m.MulBox(sdf.BoundingBox()),
 	}
@@ -617,6 +1113,15 @@ func (s *ScaleUniformSDF3) BoundingBox() Box3 {
 	return s.bb
 }
 
+// Scale3D scales an SDF3 by different amounts on each axis. The distance is
+// scaled by the minimum scale factor, so it is a lower bound on the distance.
+func Scale3D(sdf SDF3, k V3) SDF3 {
+	if k.X == k.Y && k.Y == k.Z {
+		return ScaleUniform3D(sdf, k.X)
+	}
+	return Transform3D(sdf, Scale3d(k))
+}
+
 //-----------------------------------------------------------------------------
 
 // UnionSDF3 is a union of SDF3s.
@@ -756,6 +1261,137 @@ func (s *ElongateSDF3) BoundingBox() Box3 {
 	return s.bb
 }
 
+// ElongateDirSDF3 is the elongation of an SDF3 along a direction.
+type ElongateDirSDF3 struct {
+	sdf SDF3    // the sdf being elongated
+	u   V3      // unit elongation direction
+	h   float64 // half elongation length
+	bb  Box3    // bounding box
+}
+
+// ElongateDir3D returns the elongation of an SDF3 along a direction.
+// The SDF3 is split at the plane through the origin normal to the direction,
+// and the halves are moved apart by length.
+func ElongateDir3D(sdf SDF3, dir V3, length float64) SDF3 {
+	if dir.Length() == 0 {
+		panic("dir length is zero")
+	}
+	s := ElongateDirSDF3{}
+	s.sdf = sdf
+	s.u = dir.Normalize()
+	s.h = 0.5 * Abs(length)
+	// bounding box
+	bb := sdf.BoundingBox()
+	bb0 := bb.Translate(s.u.MulScalar(s.h))
+	bb1 := bb.Translate(s.u.MulScalar(-s.h))
+	s.bb = bb0.Extend(bb1)
+	return &s
+}
+
+// Evaluate returns the minimum distance to an elongated SDF3.
+func (s *ElongateDirSDF3) Evaluate(p V3) float64 {
+	q := p.Sub(s.u.MulScalar(Clamp(p.Dot(s.u), -s.h, s.h)))
+	return s.sdf.Evaluate(q)
+}
+
+// BoundingBox returns the bounding box of an elongated SDF3.
+func (s *ElongateDirSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+
+// OffsetSDF3 offsets the distance function of an existing SDF3.
+type OffsetSDF3 struct {
+	sdf    SDF3
+	offset float64
+	bb     Box3
+}
+
+// Offset3D returns an SDF3 that offsets the distance function of another SDF3.
+// A positive offset grows the SDF3 and a negative offset shrinks it, e.g. to
+// compensate for printer tolerances.
+//
+// The result is only an exact offset surface when the distance function of
+// the SDF3 is exact. Many operations (e.g. scaling, blended unions, twists,
+// intersections) give bounds rather than exact distances. For these the offset
+// will be uneven, and large offsets will distort the shape. Sharp concave
+// edges are not rounded by a negative offset.
+func Offset3D(sdf SDF3, offset float64) SDF3 {
+	s := OffsetSDF3{}
+	s.sdf = sdf
+	s.offset = offset
+	// work out the bounding box
+	// a large negative offset can remove the whole object, don't let the size go negative
+	bb := sdf.BoundingBox()
+	s.bb = NewBox3(bb.Center(), bb.Size().AddScalar(2*offset).Max(V3{}))
+	return &s
+}
+
+// Evaluate returns the minimum distance to an offset SDF3.
+func (s *OffsetSDF3) Evaluate(p V3) float64 {
+	return s.sdf.Evaluate(p) - s.offset
+}
+
+// BoundingBox returns the bounding box of an offset SDF3.
+func (s *OffsetSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+
+// ShellSide selects where a shell is placed relative to the surface of an SDF3.
+type ShellSide int
+
+// Shell sides.
+const (
+	ShellInside   ShellSide = iota // the shell is inside the surface (the outer dimensions are kept)
+	ShellOutside                   // the shell is outside the surface (the inner dimensions are kept)
+	ShellCentered                  // the shell is centered on the surface
+)
+
+// ShellSDF3 is a hollow shell of an SDF3.
+type ShellSDF3 struct {
+	sdf    SDF3
+	offset float64 // offset of the shell center from the surface
+	half   float64 // half the shell thickness
+	bb     Box3
+}
+
+// Shell3D returns a shell of an SDF3 with a given wall thickness.
+func Shell3D(sdf SDF3, thickness float64, side ShellSide) SDF3 {
+	if thickness <= 0 {
+		panic("thickness <= 0")
+	}
+	s := ShellSDF3{}
+	s.sdf = sdf
+	s.half = 0.5 * thickness
+	switch side {
+	case ShellInside:
+		s.offset = -s.half
+	case ShellOutside:
+		s.offset = s.half
+	case ShellCentered:
+		s.offset = 0
+	default:
+		panic("unknown shell side")
+	}
+	// work out the bounding box
+	bb := sdf.BoundingBox()
+	s.bb = NewBox3(bb.Center(), bb.Size().AddScalar(2*(s.offset+s.half)))
+	return &s
+}
+
+// Evaluate returns the minimum distance to a shell.
+func (s *ShellSDF3) Evaluate(p V3) float64 {
+	return Abs(s.sdf.Evaluate(p)-s.offset) - s.half
+}
+
+// BoundingBox returns the bounding box of a shell.
+func (s *ShellSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
 //-----------------------------------------------------------------------------
 
 // IntersectionSDF3 is the intersection of two SDF3s.
@@ -797,6 +1433,246 @@ func (s *IntersectionSDF3) BoundingBox() Box3 {
 
 //-----------------------------------------------------------------------------
 
+// SmoothUnion3D returns the union of multiple SDF3s, smoothly blended with a blend kernel.
+func SmoothUnion3D(kernel Blend, k float64, sdf ...SDF3) SDF3 {
+	s, ok := Union3D(sdf...).(*UnionSDF3)
+	if !ok {
+		// not really a union
+		return Union3D(sdf...)
+	}
+	s.SetMin(BlendMin(kernel, k))
+	// the blend adds material near the joins
+	s.bb = NewBox3(s.bb.Center(), s.bb.Size().AddScalar(2*k))
+	return s
+}
+
+// SmoothDifference3D returns the difference of two SDF3s (s0 - s1), smoothly blended with a blend kernel.
+func SmoothDifference3D(kernel Blend, k float64, s0, s1 SDF3) SDF3 {
+	s, ok := Difference3D(s0, s1).(*DifferenceSDF3)
+	if !ok {
+		return Difference3D(s0, s1)
+	}
+	s.SetMax(BlendMax(kernel, k))
+	return s
+}
+
+// SmoothIntersect3D returns the intersection of two SDF3s, smoothly blended with a blend kernel.
+func SmoothIntersect3D(kernel Blend, k float64, s0, s1 SDF3) SDF3 {
+	s, ok := Intersect3D(s0, s1).(*IntersectionSDF3)
+	if !ok {
+		return Intersect3D(s0, s1)
+	}
+	s.SetMax(BlendMax(kernel, k))
+	return s
+}
+
+//-----------------------------------------------------------------------------
+
+// ChamferUnion3D returns the union of multiple SDF3s with a 45 degree chamfer
+// where they join. k is the chamfer leg length (for perpendicular surfaces).
+func ChamferUnion3D(k float64, sdf ...SDF3) SDF3 {
+	s, ok := Union3D(sdf...).(*UnionSDF3)
+	if !ok {
+		// not really a union
+		return Union3D(sdf...)
+	}
+	s.SetMin(ChamferMin(k))
+	// the chamfer adds material near the joins
+	s.bb = NewBox3(s.bb.Center(), s.bb.Size().AddScalar(2*k))
+	return s
+}
+
+// ChamferDifference3D returns the difference of two SDF3s (s0 - s1) with a 45
+// degree chamfer on the edges of the cut. k is the chamfer leg length (for
+// perpendicular surfaces).
+func ChamferDifference3D(k float64, s0, s1 SDF3) SDF3 {
+	s, ok := Difference3D(s0, s1).(*DifferenceSDF3)
+	if !ok {
+		return Difference3D(s0, s1)
+	}
+	s.SetMax(ChamferMax(k))
+	return s
+}
+
+//-----------------------------------------------------------------------------
+// Edge Fillets
+
+// FilletEdgeSDF3 is a CSG operation with the edges filleted within a region.
+type FilletEdgeSDF3 struct {
+	hard SDF3    // unblended CSG operation
+	soft SDF3    // blended CSG operation
+	mask SDF3    // fillet region
+	band float64 // width of the fade out band around the mask
+	bb   Box3
+}
+
+// FilletEdge3D fillets the edges of a CSG operation (Union3D, Difference3D or
+// Intersect3D) with a blend of size k, but only for the edges within a mask
+// SDF3 (e.g. a box or cylinder around the edges). The fillet fades out over a
+// band of 4k around the mask. Union fillets add material to concave edges,
+// difference and intersection fillets remove material from convex edges.
+func FilletEdge3D(sdf, mask SDF3, k float64) (SDF3, error) {
+	if k <= 0 {
+		return nil, errors.New("k <= 0")
+	}
+	s := FilletEdgeSDF3{}
+	s.hard = sdf
+	s.mask = mask
+	s.band = 4 * k
+	s.bb = sdf.BoundingBox()
+	switch t := sdf.(type) {
+	case *UnionSDF3:
+		u := *t
+		u.SetMin(BlendMin(BlendPoly, k))
+		s.soft = &u
+		// the blend adds material near the joins
+		s.bb = NewBox3(s.bb.Center(), s.bb.Size().AddScalar(2*k))
+	case *DifferenceSDF3:
+		d := *t
+		d.SetMax(BlendMax(BlendPoly, k))
+		s.soft = &d
+	case *IntersectionSDF3:
+		i := *t
+		i.SetMax(BlendMax(BlendPoly, k))
+		s.soft = &i
+	default:
+		return nil, errors.New("sdf is not a union, difference or intersection")
+	}
+	return &s, nil
+}
+
+// Evaluate returns the minimum distance to a filleted CSG operation.
+func (s *FilletEdgeSDF3) Evaluate(p V3) float64 {
+	// The fade weight changes by up to 1.5/band per unit distance, and the
+	// blend changes the distance by up to band/4, so fading from the blended
+	// to the unblended distance adds up to 0.375 to the distance gradient.
+	// Scale the distance (everywhere, so it is continuous) to keep it a bound.
+	const k = 1 / 1.375
+	m := s.mask.Evaluate(p)
+	if m <= 0 {
+		return s.soft.Evaluate(p) * k
+	}
+	if m >= s.band {
+		return s.hard.Evaluate(p) * k
+	}
+	x := m / s.band
+	w := 1 - x*x*(3-2*x)
+	return Mix(s.hard.Evaluate(p), s.soft.Evaluate(p), w) * k
+}
+
+// BoundingBox returns the bounding box of a filleted CSG operation.
+func (s *FilletEdgeSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+
+// MorphSDF3 is a linear interpolation between two SDF3s.
+type MorphSDF3 struct {
+	s0, s1 SDF3
+	t      float64 // interpolation factor
+	bb     Box3
+}
+
+// Morph3D returns an SDF3 that interpolates between s0 (t = 0) and s1 (t = 1).
+func Morph3D(s0, s1 SDF3, t float64) SDF3 {
+	s := MorphSDF3{}
+	s.s0 = s0
+	s.s1 = s1
+	s.t = t
+	// work out the bounding box
+	switch {
+	case t <= 0:
+		s.bb = s0.BoundingBox()
+	case t >= 1:
+		s.bb = s1.BoundingBox()
+	default:
+		// a point outside both SDF3s is outside the morph
+		s.bb = s0.BoundingBox().Extend(s1.BoundingBox())
+	}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a morphed SDF3.
+func (s *MorphSDF3) Evaluate(p V3) float64 {
+	return Mix(s.s0.Evaluate(p), s.s1.Evaluate(p), s.t)
+}
+
+// BoundingBox returns the bounding box of a morphed SDF3.
+func (s *MorphSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+// MorphKey is a keyframe for a keyframed morph.
+type MorphKey struct {
+	T   float64 // keyframe position
+	SDF SDF3    // keyframe SDF3
+}
+
+// Keyframe3D returns the morph of a sequence of keyframes at position t.
+// The keyframes must be in increasing order of position. The morph is a linear
+// interpolation between the two keyframes on either side of t.
+func Keyframe3D(keys []MorphKey, t float64) (SDF3, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keyframes")
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i].T <= keys[i-1].T {
+			return nil, errors.New("keyframes are not in increasing order")
+		}
+	}
+	if t <= keys[0].T {
+		return keys[0].SDF, nil
+	}
+	for i := 1; i < len(keys); i++ {
+		if t <= keys[i].T {
+			k0, k1 := keys[i-1], keys[i]
+			return Morph3D(k0.SDF, k1.SDF, (t-k0.T)/(k1.T-k0.T)), nil
+		}
+	}
+	return keys[len(keys)-1].SDF, nil
+}
+
+//-----------------------------------------------------------------------------
+// Region Select
+
+// SelectSDF3 selects between two SDF3s depending on a region.
+type SelectSDF3 struct {
+	region SDF3
+	a, b   SDF3 // inside and outside the region
+	min    MinFunc
+	max    MaxFunc
+	bb     Box3
+}
+
+// Select3D returns an SDF3 that is sdf a inside a region and sdf b outside
+// it, e.g. different knurl patterns on different sections of a handle. This is
+// the union of a intersected with the region and b minus the region, blended
+// with a smooth transition of size k (k = 0 gives a sharp transition).
+func Select3D(region, a, b SDF3, k float64) SDF3 {
+	s := SelectSDF3{}
+	s.region = region
+	s.a = a
+	s.b = b
+	s.min = BlendMin(BlendPoly, k)
+	s.max = BlendMax(BlendPoly, k)
+	s.bb = a.BoundingBox().Extend(b.BoundingBox())
+	return &s
+}
+
+// Evaluate returns the minimum distance to a region select.
+func (s *SelectSDF3) Evaluate(p V3) float64 {
+	r := s.region.Evaluate(p)
+	return s.min(s.max(s.a.Evaluate(p), r), s.max(s.b.Evaluate(p), -r))
+}
+
+// BoundingBox returns the bounding box of a region select.
+func (s *SelectSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+
 // CutSDF3 makes a planar cut through an SDF3.
 type CutSDF3 struct {
 	sdf SDF3
@@ -827,6 +1703,60 @@ func (s *CutSDF3) BoundingBox() Box3 {
 	return s.bb
 }
 
+//-----------------------------------------------------------------------------
+// Emboss/Engrave
+
+// Emboss3D extrudes 2d artwork (e.g. from TextSDF2) perpendicular to a planar
+// face of an SDF3. The artwork origin is placed at point a on the face, with
+// the artwork x-axis in the direction of x (projected onto the face). With
+// depth > 0 the artwork is raised from the face (embossed), with depth < 0 it
+// is cut into the face (engraved). An error is returned if the normal is zero
+// or the x-axis direction is parallel to the normal.
+func Emboss3D(
+	sdf SDF3, // SDF3 to be embossed
+	art SDF2, // 2d artwork
+	a V3, // point on the face
+	n V3, // outward normal of the face
+	x V3, // direction of the artwork x-axis
+	depth float64, // height of the artwork (> 0 emboss, < 0 engrave)
+) (SDF3, error) {
+	if n.Length() == 0 {
+		return nil, errors.New("face normal is zero")
+	}
+	n = n.Normalize()
+	u := x.Sub(n.MulScalar(n.Dot(x)))
+	if l := u.Length(); l == 0 || l < tolerance*x.Length() {
+		return nil, errors.New("x-axis is parallel to the face normal")
+	}
+	u = u.Normalize()
+	v := n.Cross(u)
+	// map the xy-plane onto the face
+	m := M44{
+		u.X, v.X, n.X, a.X,
+		u.Y, v.Y, n.Y, a.Y,
+		u.Z, v.Z, n.Z, a.Z,
+		0, 0, 0, 1}
+	return emboss(sdf, Transform3D(embossLayer(art, depth), m), depth), nil
+}
+
+// embossLayer returns the extrusion of 2d artwork for embossing the xy-plane.
+// With depth > 0 it extrudes from the plane upwards, with depth < 0 it extrudes
+// through the plane (so the cut is clean).
+func embossLayer(art SDF2, depth float64) SDF3 {
+	if depth > 0 {
+		return Transform3D(Extrude3D(art, depth), Translate3d(V3{0, 0, 0.5 * depth}))
+	}
+	return Extrude3D(art, -2*depth)
+}
+
+// emboss adds (depth > 0) or subtracts (depth < 0) an emboss layer.
+func emboss(sdf, layer SDF3, depth float64) SDF3 {
+	if depth > 0 {
+		return Union3D(sdf, layer)
+	}
+	return Difference3D(sdf, layer)
+}
+
 //-----------------------------------------------------------------------------
 
 // ArraySDF3 stores an XYZ array of a given SDF3
@@ -834,11 +1764,15 @@ type ArraySDF3 struct {
 	sdf  SDF3
 	num  V3i
 	step V3
+	r    V3i  // number of neighbouring copies to evaluate
+	bb0  Box3 // bounding box of the first copy
 	min  MinFunc
 	bb   Box3
 }
 
-// Array3D returns an XYZ array of a given SDF3
+// Array3D returns an XYZ array of a given SDF3.
+// The array is evaluated with domain repetition, so only the copies near the
+// evaluation point are evaluated. The cost is independent of the number of copies.
 func Array3D(sdf SDF3, num V3i, step V3) SDF3 {
 	// check the number of steps
 	if num[0] <= 0 || num[1] <= 0 || num[2] <= 0 {
@@ -853,6 +1787,16 @@ func Array3D(sdf SDF3, num V3i, step V3) SDF3 {
 	bb0 := sdf.BoundingBox()
 	bb1 := bb0.Translate(step.Mul(num.SubScalar(1).ToV3()))
 	s.bb = bb0.Extend(bb1)
+	s.bb0 = bb0
+	// Work out how many neighbouring copies can contain a point in the cell of
+	// the closest copy. These copies are evaluated, the others are bounded.
+	e := bb0.Min.Abs().Max(bb0.Max.Abs())
+	extent := []float64{e.X, e.Y, e.Z}
+	for i, k := range []float64{step.X, step.Y, step.Z} {
+		if k != 0 {
+			s.r[i] = int(math.Floor(Abs(extent[i]/k) + 0.5))
+		}
+	}
 	return &s
 }
 
@@ -861,18 +1805,56 @@ func (s *ArraySDF3) SetMin(min MinFunc) {
 	s.min = min
 }
 
+// arrayRange returns the range of array indices to evaluate along an axis and
+// a lower bound on the distance to the copies outside of the range.
+func arrayRange(
+	x float64, // point position on the axis
+	step float64, // array step
+	num int, // number of copies
+	r int, // number of neighbouring copies to evaluate
+	bmin, bmax float64, // bounding range of the first copy
+) (int, int, float64) {
+	if step == 0 {
+		return 0, num - 1, math.MaxFloat64
+	}
+	// closest copy
+	c := int(Clamp(math.Round(x/step), 0, float64(num-1)))
+	i0 := c - r
+	i1 := c + r
+	d := math.MaxFloat64
+	// distance to the bounding range of the copies outside the range
+	dist := func(i int) float64 {
+		return Max(bmin+float64(i)*step-x, x-bmax-float64(i)*step)
+	}
+	if i0 > 0 {
+		d = Min(d, Max(dist(i0-1), 0))
+	} else {
+		i0 = 0
+	}
+	if i1 < num-1 {
+		d = Min(d, Max(dist(i1+1), 0))
+	} else {
+		i1 = num - 1
+	}
+	return i0, i1, d
+}
+
 // Evaluate returns the minimum distance to an XYZ SDF3 array.
 func (s *ArraySDF3) Evaluate(p V3) float64 {
+	j0, j1, dx := arrayRange(p.X, s.step.X, s.num[0], s.r[0], s.bb0.Min.X, s.bb0.Max.X)
+	k0, k1, dy := arrayRange(p.Y, s.step.Y, s.num[1], s.r[1], s.bb0.Min.Y, s.bb0.Max.Y)
+	l0, l1, dz := arrayRange(p.Z, s.step.Z, s.num[2], s.r[2], s.bb0.Min.Z, s.bb0.Max.Z)
 	d := math.MaxFloat64
-	for j := 0; j < s.num[0]; j++ {
-		for k := 0; k < s.num[1]; k++ {
-			for l := 0; l < s.num[2]; l++ {
+	for j := j0; j <= j1; j++ {
+		for k := k0; k <= k1; k++ {
+			for l := l0; l <= l1; l++ {
 				x := p.Sub(V3{float64(j) * s.step.X, float64(k) * s.step.Y, float64(l) * s.step.Z})
 				d = s.min(d, s.sdf.Evaluate(x))
 			}
 		}
 	}
-	return d
+	// the copies that were not evaluated are at least this far away
+	return Min(d, Min(dx, Min(dy, dz)))
 }
 
 // BoundingBox returns the bounding box of an XYZ SDF3 array.
@@ -990,6 +1972,222 @@ func (s *RotateCopySDF3) BoundingBox() Box3 {
 
 //-----------------------------------------------------------------------------
 
+// RotateRepeatSDF3 repeats an SDF3 about the z-axis using angular domain folding.
+type RotateRepeatSDF3 struct {
+	sdf   SDF3
+	num   int     // number of copies in a full circle
+	count int     // number of copies
+	step  float64 // angle between copies
+	alpha float64 // angular half width of the SDF3 about the z-axis
+	r     int     // number of neighbouring copies to evaluate
+	bb    Box3
+}
+
+// RotateRepeat3D repeats an SDF3 about the z-axis. There are num copies
+// evenly spaced around a full circle, and count (<= num) copies are made
+// counter-clockwise starting from the original SDF3, so count = num gives a
+// full circular pattern. The SDF3 should be centered on the +x axis. Only the
+// copies near the evaluation point are evaluated, so the cost is independent
+// of the number of copies (unless the SDF3 surrounds the z-axis).
+func RotateRepeat3D(
+	sdf SDF3, // SDF3 to repeat
+	num int, // number of copies in a full circle
+	count int, // number of copies to make
+) SDF3 {
+	if num <= 0 || count <= 0 {
+		return nil
+	}
+	if count > num {
+		count = num
+	}
+	s := RotateRepeatSDF3{}
+	s.sdf = sdf
+	s.num = num
+	s.count = count
+	s.step = Tau / float64(num)
+	// work out the angular extent of the SDF3
+	bb := sdf.BoundingBox()
+	if bb.Min.X <= 0 && bb.Max.X >= 0 && bb.Min.Y <= 0 && bb.Max.Y >= 0 {
+		// the SDF3 surrounds the z-axis
+		s.alpha = Pi
+	} else {
+		for _, v := range bb.Vertices() {
+			s.alpha = Max(s.alpha, Abs(math.Atan2(v.Y, v.X)))
+		}
+	}
+	s.r = int(math.Floor(s.alpha/s.step + 0.5))
+	// work out the bounding box
+	if count == num {
+		rmax := 0.0
+		for _, v := range bb.Vertices() {
+			rmax = Max(rmax, V2{v.X, v.Y}.Length())
+		}
+		s.bb = Box3{V3{-rmax, -rmax, bb.Min.Z}, V3{rmax, rmax, bb.Max.Z}}
+	} else {
+		v := bb.Vertices()
+		s.bb = bb
+		for i := 1; i < count; i++ {
+			v.MulVertices(RotateZ(s.step))
+			s.bb = s.bb.Extend(Box3{v.Min(), v.Max()})
+		}
+	}
+	return &s
+}
+
+// Evaluate returns the minimum distance to a rotate/repeat SDF3.
+func (s *RotateRepeatSDF3) Evaluate(p V3) float64 {
+	theta := math.Atan2(p.Y, p.X)
+	// closest copy
+	c := int(math.Round(theta / s.step))
+	full := s.count == s.num
+	if full {
+		c = ((c % s.num) + s.num) % s.num
+	} else {
+		// measure angles counter-clockwise from the first copy
+		if theta < -0.5*s.step {
+			theta += Tau
+			c = int(math.Round(theta / s.step))
+		}
+		if c > s.count-1 {
+			// between the last and first copies: pick the closest
+			a := theta / s.step
+			if a-float64(s.count-1) < float64(s.num)-a {
+				c = s.count - 1
+			} else {
+				c = 0
+			}
+		}
+	}
+	i0, i1 := c-s.r, c+s.r
+	if full && i1-i0+1 >= s.num {
+		i0, i1 = 0, s.num-1
+	} else if !full {
+		if i0 < 0 {
+			i0 = 0
+		}
+		if i1 > s.count-1 {
+			i1 = s.count - 1
+		}
+	}
+	d := math.MaxFloat64
+	for i := i0; i <= i1; i++ {
+		x := RotateZ(-float64(i) * s.step).MulPosition(p)
+		d = Min(d, s.sdf.Evaluate(x))
+	}
+	// The copies that were not evaluated lie within wedges about the z-axis
+	// that don't contain p. The distance to a wedge is a lower bound for the
+	// distance to the copy.
+	rho := V2{p.X, p.Y}.Length()
+	wedge := func(i int) float64 {
+		gap := Abs(SawTooth(theta-float64(i)*s.step, Tau)) - s.alpha
+		if gap <= 0 {
+			return 0
+		}
+		return rho * math.Sin(Min(gap, 0.5*Pi))
+	}
+	if full {
+		if i1-i0+1 < s.num {
+			d = Min(d, Min(wedge(i0-1), wedge(i1+1)))
+		}
+	} else {
+		if i0 > 0 {
+			d = Min(d, wedge(i0-1))
+		}
+		if i1 < s.count-1 {
+			d = Min(d, wedge(i1+1))
+		}
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a rotate/repeat SDF3.
+func (s *RotateRepeatSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+
+// Symmetry planes for Symmetry3D.
+const (
+	SymmetryX = 1 << iota // mirror across the yz plane (x = 0)
+	SymmetryY             // mirror across the xz plane (y = 0)
+	SymmetryZ             // mirror across the xy plane (z = 0)
+)
+
+// SymmetrySDF3 is an SDF3 made symmetric by mirroring it across axis planes.
+type SymmetrySDF3 struct {
+	sdf    SDF3
+	planes int // symmetry planes
+	bb     Box3
+}
+
+// Symmetry3D makes an SDF3 symmetric across one or more axis planes (a
+// combination of SymmetryX, SymmetryY and SymmetryZ). The part of the SDF3 on
+// the positive side of each plane is kept and mirrored onto the negative side,
+// so the SDF3 need only model one half (or quadrant, octant) of a symmetric part.
+// The result is exactly symmetric and only one half of the SDF3 is evaluated.
+func Symmetry3D(sdf SDF3, planes int) SDF3 {
+	s := SymmetrySDF3{}
+	s.sdf = sdf
+	s.planes = planes
+	// work out the bounding box
+	bb := sdf.BoundingBox()
+	if planes&SymmetryX != 0 {
+		x := Max(bb.Max.X, 0)
+		bb.Min.X, bb.Max.X = -x, x
+	}
+	if planes&SymmetryY != 0 {
+		y := Max(bb.Max.Y, 0)
+		bb.Min.Y, bb.Max.Y = -y, y
+	}
+	if planes&SymmetryZ != 0 {
+		z := Max(bb.Max.Z, 0)
+		bb.Min.Z, bb.Max.Z = -z, z
+	}
+	s.bb = bb
+	return &s
+}
+
+// Evaluate returns the minimum distance to a symmetric SDF3.
+func (s *SymmetrySDF3) Evaluate(p V3) float64 {
+	// fold p into the positive side of the symmetry planes
+	if s.planes&SymmetryX != 0 {
+		p.X = Abs(p.X)
+	}
+	if s.planes&SymmetryY != 0 {
+		p.Y = Abs(p.Y)
+	}
+	if s.planes&SymmetryZ != 0 {
+		p.Z = Abs(p.Z)
+	}
+	return s.sdf.Evaluate(p)
+}
+
+// BoundingBox returns the bounding box of a symmetric SDF3.
+func (s *SymmetrySDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+// MirrorX3D makes an SDF3 symmetric across the yz plane (x = 0).
+// The positive x half of the SDF3 is mirrored onto the negative x half.
+func MirrorX3D(sdf SDF3) SDF3 {
+	return Symmetry3D(sdf, SymmetryX)
+}
+
+// MirrorY3D makes an SDF3 symmetric across the xz plane (y = 0).
+// The positive y half of the SDF3 is mirrored onto the negative y half.
+func MirrorY3D(sdf SDF3) SDF3 {
+	return Symmetry3D(sdf, SymmetryY)
+}
+
+// MirrorZ3D makes an SDF3 symmetric across the xy plane (z = 0).
+// The positive z half of the SDF3 is mirrored onto the negative z half.
+func MirrorZ3D(sdf SDF3) SDF3 {
+	return Symmetry3D(sdf, SymmetryZ)
+}
+
+//-----------------------------------------------------------------------------
+
 // Connector3 defines a 3d connection point.
 type Connector3 struct {
 	Name     string
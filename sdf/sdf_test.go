@@ -5,9 +5,35 @@
 package sdf
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
 	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
 )
 
 //-----------------------------------------------------------------------------
@@ -722,3 +748,5499 @@ func Test_Box2_Distances(t *testing.T) {
 }
 
 //-----------------------------------------------------------------------------
+
+func Test_VoxelGrid(t *testing.T) {
+	s := Sphere3D(10)
+	v, err := NewVoxelGrid(s, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Size != (V3i{20, 20, 20}) {
+		t.Logf("expected %v, actual %v\n", V3i{20, 20, 20}, v.Size)
+		t.Error("FAIL")
+	}
+	// the center voxels are inside, the corner voxels are outside
+	if v.Get(10, 10, 10) >= 0 || v.Get(0, 0, 0) <= 0 {
+		t.Error("FAIL")
+	}
+	// the samples should match direct evaluation
+	p := v.Origin.Add(V3{3, 7, 12}.MulScalar(v.Spacing))
+	if Abs(v.Get(3, 7, 12)-s.Evaluate(p)) > tolerance {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Deterministic(t *testing.T) {
+	// ulp level differences should vanish after quantization
+	for i := 0; i < 1000; i++ {
+		b := NewBox3(V3{0, 0, 0}, V3{200, 200, 200})
+		v0 := b.Random()
+		v1 := V3{math.Nextafter(v0.X, 1000), math.Nextafter(v0.Y, -1000), v0.Z}
+		if v0.Quantize() != v1.Quantize() {
+			t.Logf("%v %v\n", v0.Quantize(), v1.Quantize())
+			t.Error("FAIL")
+		}
+	}
+	// negative zero should be zero
+	if math.Signbit(quantize(-1e-9)) {
+		t.Error("FAIL")
+	}
+	// triangle order and vertex rotation should not change the output
+	SetDeterministic(true)
+	defer SetDeterministic(false)
+	mesh := RenderMesh(Box3D(V3{10, 20, 30}, 2), 20)
+	shuffled := make([]*Triangle3, len(mesh))
+	for i, tri := range mesh {
+		j := len(mesh) - 1 - i
+		shuffled[j] = NewTriangle3(tri.V[1], tri.V[2], tri.V[0])
+	}
+	var b0, b1 bytes.Buffer
+	if EncodeSTL(&b0, mesh) != nil || EncodeSTL(&b1, shuffled) != nil {
+		t.Error("FAIL")
+	}
+	if !bytes.Equal(b0.Bytes(), b1.Bytes()) {
+		t.Error("FAIL")
+	}
+	// changing the modes while rendering gives output in one mode or the other
+	SetDeterministic(false)
+	var b2 bytes.Buffer
+	if EncodeSTL(&b2, mesh) != nil {
+		t.Error("FAIL")
+	}
+	var wg sync.WaitGroup
+	out := make([][]byte, 8)
+	for i := range out {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				var b bytes.Buffer
+				if EncodeSTL(&b, mesh) != nil {
+					t.Error("FAIL")
+				}
+				out[i] = b.Bytes()
+			}
+		}(i)
+	}
+	for i := 0; i < 100; i++ {
+		SetDeterministic(i&1 == 0)
+		SetMetadata(i&1 == 0, nil)
+	}
+	wg.Wait()
+	SetMetadata(false, nil)
+	for _, b := range out {
+		if !bytes.Equal(b, b0.Bytes()) && !bytes.Equal(b, b2.Bytes()) {
+			t.Error("FAIL")
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// Golden image tests: ray march a fixed view of each shape generator and
+// compare it with a stored image. Regenerate the golden images with:
+// go test -run Test_Golden -update
+//
+// The shape generators are the exported functions that make an SDF3 without
+// an SDF3 argument (they may take an SDF2 profile). Operators on SDF3s (CSG,
+// transforms, deformations) depend on the shapes they are given, so they are
+// left to their unit tests. Test_GoldenCoverage checks that each generator is
+// used by goldenShapes.
+
+var updateGolden = flag.Bool("update", false, "update the golden image files")
+
+const goldenPixels = 96            // golden image size
+const goldenPixelThreshold = 32    // per pixel gray level difference that counts as a change
+const goldenChangeThreshold = 0.01 // fraction of changed pixels that fails the test
+
+func goldenShapes() map[string]SDF3 {
+	bolt, _ := Bolt(&BoltParms{
+		Thread:      "M8x1.25",
+		Style:       "hex",
+		TotalLength: 20,
+		ShankLength: 5,
+	})
+	nut, _ := Nut(&NutParms{
+		Thread: "M8x1.25",
+		Style:  "hex",
+	})
+	must := func(s SDF3, err error) SDF3 {
+		if err != nil {
+			panic(err)
+		}
+		return s
+	}
+	// lattices are infinite, so look at a block of them
+	block := func(s SDF3) SDF3 {
+		return Intersect3D(Box3D(V3{30, 30, 30}, 0), s)
+	}
+	// a height map image
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetGray(x, y, color.Gray{uint8(255 * (0.5 + 0.5*math.Sin(float64(x)/3)*math.Cos(float64(y)/4)))})
+		}
+	}
+	// a convex hull and its mesh
+	hullMesh, hull, err := ConvexHull3D(V3Set{
+		{-10, -8, -6}, {10, -8, -6}, {0, 10, -6}, {0, 0, 10}, {-6, 6, 4}, {8, 2, 6},
+	})
+	if err != nil {
+		panic(err)
+	}
+	fonts, err := parseFont(goregular.TTF)
+	if err != nil {
+		panic(err)
+	}
+	standoff := &StandoffParms{
+		PillarHeight:   15,
+		PillarDiameter: 6,
+		HoleDepth:      10,
+		HoleDiameter:   2.4,
+		NumberWebs:     4,
+		WebHeight:      8,
+		WebDiameter:    14,
+		WebWidth:       2,
+	}
+	return map[string]SDF3{
+		"box":               Box3D(V3{10, 20, 30}, 2),
+		"sphere":            Sphere3D(10),
+		"cylinder":          Cylinder3D(20, 5, 1),
+		"capsule":           Capsule3D(3, 20),
+		"cone":              Cone3D(20, 8, 3, 1),
+		"torus":             Torus3D(10, 3),
+		"ellipsoid":         Ellipsoid3D(V3{10, 6, 4}),
+		"rounded_cone":      RoundedCone3D(15, 6, 3),
+		"pyramid":           Pyramid3D(15, 12),
+		"dodecahedron":      Dodecahedron3D(10, 1),
+		"icosahedron":       Icosahedron3D(10, 1),
+		"superquadric":      SuperQuadric3D(0.3, 0.3, V3{20, 16, 10}),
+		"counterbored_hole": CounterBoredHole3D(20, 3, 5, 4),
+		"chamfered_hole":    ChamferedHole3D(20, 3, 2),
+		"countersunk_hole":  CounterSunkHole3D(20, 3),
+		"hex_head":          HexHead3D(10, 6, "tb"),
+		"knurled_head":      KnurledHead3D(10, 8, 2),
+		"washer": Washer3D(&WasherParms{
+			Thickness:   2,
+			InnerRadius: 4,
+			OuterRadius: 8,
+			Remove:      0.3,
+		}),
+		"standoff": Standoff3D(standoff),
+		"trunc_rect_pyramid": TruncRectPyramid3D(&TruncRectPyramidParms{
+			Size:        V3{30, 20, 10},
+			BaseAngle:   DtoR(60),
+			BaseRadius:  4,
+			RoundRadius: 1,
+		}),
+		"bolt":    bolt,
+		"nut":     nut,
+		"extrude": Extrude3D(Polygon2D(Nagon(5, 10)), 5),
+		"revolve": RevolveTheta3D(Transform2D(Box2D(V2{4, 10}, 1), Translate2d(V2{8, 0})), DtoR(270)),
+		"loft":    Loft3D(Box2D(V2{20, 20}, 2), Circle2D(6), 15, 1),
+		// primitives
+		"multi_cylinder": MultiCylinder3D(10, 3, V2Set{{-8, 0}, {8, 0}, {0, 8}}),
+		"octahedron":     Octahedron3D(10, 1),
+		"tetrahedron":    Tetrahedron3D(10, 1),
+		"knurl":          Knurl3D(20, 8, 2, 0.5, DtoR(45)),
+		"standoffs":      Standoffs3D(standoff, V3Set{{-10, 0, 0}, {10, 0, 0}}),
+		"bolt_circle":    MakeBoltCircle3D(5, 2, 10, 6),
+		"panel_box": Union3D(PanelBox3D(&PanelBoxParms{
+			Size:       V3{50, 30, 60},
+			Wall:       2.5,
+			Panel:      3,
+			Rounding:   5,
+			FrontInset: 5,
+			BackInset:  5,
+			Clearance:  0.05,
+			Hole:       2,
+			SideTabs:   "TbtbT",
+		})...),
+		"text": must(Text3D(fonts[0], "sdf", 10, 3, false)),
+		// meshes, surfaces and fields
+		"heightmap":   must(Heightmap3D(img, V3{30, 30, 5})),
+		"mesh":        must(Mesh3D(hullMesh)),
+		"convex_hull": hull,
+		"metaball": must(Metaball3D([]MetaballSource{
+			MetaPoint(V3{-4, 0, 0}, 8, 1),
+			MetaLine(V3{2, -5, 0}, V3{2, 5, 4}, 5, 1),
+		}, MetaballWyvill, 0.5)),
+		"bezier_patch": must(BezierPatch3D([][]V3{
+			{{-10, -10, 0}, {0, -10, 4}, {10, -10, 0}},
+			{{-10, 0, 4}, {0, 0, 10}, {10, 0, 4}},
+			{{-10, 10, 0}, {0, 10, 4}, {10, 10, 0}},
+		}, 1)),
+		"bspline_patch": must(BSplinePatch3D([][]V3{
+			{{-15, -15, 0}, {-5, -15, 4}, {5, -15, 4}, {15, -15, 0}},
+			{{-15, -5, 4}, {-5, -5, 10}, {5, -5, 0}, {15, -5, 4}},
+			{{-15, 5, 4}, {-5, 5, 0}, {5, 5, 10}, {15, 5, 4}},
+			{{-15, 15, 0}, {-5, 15, 4}, {5, 15, 4}, {15, 15, 0}},
+		}, 3, 3, 1)),
+		// lattices
+		"honeycomb": block(Honeycomb3D(10, 1)),
+		"grid":      block(Grid3D(V2{10, 8}, 1)),
+		"voronoi":   block(Voronoi3D(10, 1, 1)),
+		"gyroid":    block(Gyroid3D(10, 1)),
+		"schwarz_p": block(SchwarzP3D(10, 1)),
+		"diamond":   block(Diamond3D(10, 1)),
+		// threads and helices
+		"screw":            Screw3D(ISOThread(5, 1, "external"), 20, 1, 1),
+		"right_hand_screw": RightHandScrew3D(AcmeThread(5, 1.5), 20, 1.5, 2),
+		"left_hand_screw":  LeftHandScrew3D(ButtressThread(5, 1.5, 0.8, DtoR(3), DtoR(30)), 20, 1.5, 1),
+		"tapered_screw":    must(TaperedScrew3D(NPTThread(8, 1.5), 20, DtoR(1.79), 1.5, 1)),
+		"helix": must(Helix3D(Circle2D(1), 20,
+			func(z float64) float64 { return 4 },
+			func(z float64) float64 { return 6 + 0.2*z },
+			1)),
+		"coil": must(Coil3D(Circle2D(1), 8, 4, 4, true)),
+		// profile extrusions, revolutions and sweeps
+		"extrude_rounded":     ExtrudeRounded3D(Box2D(V2{20, 10}, 2), 8, 2),
+		"revolve_full":        Revolve3D(Transform2D(Circle2D(3), Translate2d(V2{8, 0}))),
+		"revolve_axis":        RevolveAxis3D(Transform2D(Box2D(V2{4, 10}, 1), Translate2d(V2{8, 0})), V3{}, V3{1, 0, 0}, 0, DtoR(180)),
+		"scale_extrude":       ScaleExtrude3D(Box2D(V2{20, 20}, 2), 15, V2{0.5, 0.5}),
+		"twist_extrude":       TwistExtrude3D(Box2D(V2{20, 10}, 1), 20, DtoR(90)),
+		"scale_twist_extrude": ScaleTwistExtrude3D(Box2D(V2{20, 10}, 1), 20, DtoR(90), V2{0.5, 0.5}),
+		"variable_extrude": VariableExtrude3D(Polygon2D(Nagon(5, 10)), 20,
+			func(z float64) float64 { return 0.05 * z },
+			func(z float64) V2 { return V2{1 - 0.02*z, 1 - 0.02*z} }),
+		"sweep": must(Sweep3D(Circle2D(2), []V3{{-10, 0, 0}, {0, 0, 5}, {10, 5, 0}})),
+		"keyframe_loft": must(KeyframeLoft3D([]MorphKey2{
+			{0, Box2D(V2{20, 20}, 2)},
+			{10, Circle2D(6)},
+			{20, Box2D(V2{10, 20}, 1)},
+		})),
+	}
+}
+
+// goldenExclusions are the shape generators that aren't in goldenShapes.
+var goldenExclusions = map[string]string{
+	"LoadHeightmap3D": "loads a file for Heightmap3D",
+	"LoadMesh3D":      "loads a file for Mesh3D",
+	"Keyframe3D":      "the keyframes are SDF3s, so this is a morph operator",
+}
+
+// Test_GoldenCoverage checks that goldenShapes uses every shape generator.
+func Test_GoldenCoverage(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	isSDF := func(e ast.Expr, name string) bool {
+		found := false
+		ast.Inspect(e, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && id.Name == name {
+				found = true
+			}
+			return !found
+		})
+		return found
+	}
+	generators := make(map[string]bool)
+	used := make(map[string]bool)
+	for name, f := range pkgs["sdf"].Files {
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || fd.Recv != nil {
+				continue
+			}
+			if strings.HasSuffix(name, "_test.go") {
+				if fd.Name.Name == "goldenShapes" {
+					ast.Inspect(fd.Body, func(n ast.Node) bool {
+						if c, ok := n.(*ast.CallExpr); ok {
+							if id, ok := c.Fun.(*ast.Ident); ok {
+								used[id.Name] = true
+							}
+						}
+						return true
+					})
+				}
+				continue
+			}
+			if !fd.Name.IsExported() || fd.Type.Results == nil {
+				continue
+			}
+			returnsSDF3 := false
+			for _, r := range fd.Type.Results.List {
+				if isSDF(r.Type, "SDF3") {
+					returnsSDF3 = true
+				}
+			}
+			takesSDF3 := false
+			for _, p := range fd.Type.Params.List {
+				if isSDF(p.Type, "SDF3") {
+					takesSDF3 = true
+				}
+			}
+			if returnsSDF3 && !takesSDF3 {
+				generators[fd.Name.Name] = true
+			}
+		}
+	}
+	if len(generators) == 0 {
+		t.Fatal("FAIL no shape generators found")
+	}
+	for name := range generators {
+		if !used[name] && goldenExclusions[name] == "" {
+			t.Errorf("FAIL %s is not in goldenShapes", name)
+		}
+	}
+	for name := range goldenExclusions {
+		if !generators[name] {
+			t.Errorf("FAIL %s is excluded but is not a shape generator", name)
+		}
+	}
+}
+
+// imageDifference returns the fraction of pixels that differ by more than a threshold.
+func imageDifference(a, b *image.Gray) float64 {
+	if a.Bounds() != b.Bounds() {
+		return 1
+	}
+	n := 0
+	for i := range a.Pix {
+		d := int(a.Pix[i]) - int(b.Pix[i])
+		if d < 0 {
+			d = -d
+		}
+		if d > goldenPixelThreshold {
+			n++
+		}
+	}
+	return float64(n) / float64(len(a.Pix))
+}
+
+func loadGolden(path string) (*image.Gray, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	g := image.NewGray(img.Bounds())
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			g.Set(x, y, img.At(x, y))
+		}
+	}
+	return g, nil
+}
+
+func saveGolden(path string, img *image.Gray) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+func Test_Golden(t *testing.T) {
+	for name, s := range goldenShapes() {
+		cam := DefaultCamera3(s.BoundingBox())
+		img := RayMarchImage(s, cam, V2i{goldenPixels, goldenPixels})
+		path := filepath.Join("testdata", "golden", name+".png")
+		if *updateGolden {
+			if err := saveGolden(path, img); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		golden, err := loadGolden(path)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if k := imageDifference(img, golden); k > goldenChangeThreshold {
+			t.Errorf("%s: %.2f%% of pixels changed", name, 100*k)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_RenderProgress(t *testing.T) {
+	s := Sphere3D(10)
+	var done, total int64
+	progress := func(d, n int64) {
+		done, total = d, n
+	}
+	mesh, err := RenderMeshContext(context.Background(), s, 50, progress)
+	if err != nil || len(mesh) == 0 {
+		t.Error("FAIL")
+	}
+	if total == 0 || done != total {
+		t.Error("FAIL")
+	}
+	// a cancelled render returns an error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = RenderMeshContext(ctx, s, 50, nil)
+	if err != context.Canceled {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// stepClosed returns true if the faces of a STEP solid form a closed shell.
+func stepClosed(s *stepSolid) bool {
+	use := make(map[*stepEdge][2]int)
+	for _, f := range s.faces {
+		n := len(f.loop)
+		for i, oe := range f.loop {
+			u := use[oe.e]
+			if oe.fwd {
+				u[0]++
+			} else {
+				u[1]++
+			}
+			use[oe.e] = u
+			// the loop edges must be connected
+			end := oe.e.v1
+			if !oe.fwd {
+				end = oe.e.v0
+			}
+			next := f.loop[(i+1)%n]
+			start := next.e.v0
+			if !next.fwd {
+				start = next.e.v1
+			}
+			if end != start {
+				return false
+			}
+		}
+	}
+	// each edge is used once in each direction
+	for _, u := range use {
+		if u != [2]int{1, 1} {
+			return false
+		}
+	}
+	return len(s.faces) > 0
+}
+
+func Test_STEP(t *testing.T) {
+	test := []struct {
+		s        SDF3
+		analytic bool
+	}{
+		{Box3D(V3{1, 2, 3}, 0), true},
+		{Cylinder3D(3, 1, 0), true},
+		{Cone3D(2, 1, 0.5, 0), true},
+		{Cone3D(2, 1, 0, 0), true},
+		{Sphere3D(2), true},
+		{Extrude3D(Box2D(V2{3, 2}, 0.5), 1), true},
+		{Revolve3D(Transform2D(Circle2D(1), Translate2d(V2{3, 0}))), true},
+		{Transform3D(Box3D(V3{1, 1, 1}, 0), RotateX(0.3).Mul(Translate3d(V3{1, 2, 3}))), true},
+		{Box3D(V3{1, 1, 1}, 0.1), false},
+		{Difference3D(Sphere3D(1), Box3D(V3{1, 1, 1}, 0)), false},
+	}
+	for i, v := range test {
+		b := &stepBuilder{meshCells: 20}
+		b.add(v.s, Identity3d())
+		if len(b.solids) != 1 || (b.analytic == 1) != v.analytic {
+			t.Errorf("test %d: wrong solid type", i)
+			continue
+		}
+		if !stepClosed(b.solids[0]) {
+			t.Errorf("test %d: shell is not closed", i)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_HeightMap(t *testing.T) {
+	// a 10 high cylinder on a 10 high base
+	s := Union3D(
+		Box3D(V3{40, 20, 10}, 0),
+		Transform3D(Cylinder3D(10, 5, 0), Translate3d(V3{0, 0, 10})),
+	)
+	img := HeightMap(s, 80)
+	r := img.Bounds().Size()
+	if r.X != 80 || r.Y != 40 {
+		t.Error("FAIL")
+	}
+	check := func(x, y int, h float64) {
+		v := float64(img.Gray16At(x, y).Y) / 65535
+		if Abs(v-h) > 0.01 {
+			t.Errorf("height at %d,%d is %f, expected %f", x, y, v, h)
+		}
+	}
+	check(40, 20, 1)
+	check(5, 5, 0.5)
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Section(t *testing.T) {
+	// section through the middle of a 10x20x30 box along the y-axis
+	s := Box3D(V3{10, 20, 30}, 0)
+	s2, err := Section2D(s, V3{0, 0, 0}, V3{0, 1, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb := s2.BoundingBox().Size()
+	if !bb.Equals(V2{10, 30}, tolerance) && !bb.Equals(V2{30, 10}, tolerance) {
+		t.Errorf("section size %v", bb)
+	}
+	if s2.Evaluate(V2{0, 0}) >= 0 {
+		t.Error("FAIL")
+	}
+	// the plane misses the box
+	if _, err := Section2D(s, V3{0, 50, 0}, V3{0, 1, 0}); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Mesh3D(t *testing.T) {
+	// binary STL round trip
+	s0 := Sphere3D(10)
+	var buf bytes.Buffer
+	if err := EncodeSTL(&buf, RenderMesh(s0, 50)); err != nil {
+		t.Fatal(err)
+	}
+	mesh, err := DecodeSTL(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, err := Mesh3D(mesh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the mesh distance should be close to the sphere distance
+	bb := s0.BoundingBox().ScaleAboutCenter(1.5)
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		d0 := s0.Evaluate(p)
+		d1 := s1.Evaluate(p)
+		if Abs(d0-d1) > 0.1 {
+			t.Errorf("%v: sphere %f mesh %f", p, d0, d1)
+		}
+	}
+	// ASCII STL
+	ascii := "solid x\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nvertex 0 1 0\nendloop\nendfacet\nendsolid x\n"
+	mesh, err = DecodeSTL(bytes.NewBufferString(ascii))
+	if err != nil || len(mesh) != 1 || mesh[0].V[1] != (V3{1, 0, 0}) {
+		t.Error("FAIL")
+	}
+	// OBJ with a quad face
+	obj := "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nf 1 2 3 4\n"
+	mesh, err = DecodeOBJ(bytes.NewBufferString(obj))
+	if err != nil || len(mesh) != 2 {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Shell3D(t *testing.T) {
+	sphere := Sphere3D(10)
+	for _, tt := range []struct {
+		side   ShellSide
+		r0, r1 float64
+	}{
+		{ShellInside, 8, 10},
+		{ShellOutside, 10, 12},
+		{ShellCentered, 9, 11},
+	} {
+		s := Shell3D(sphere, 2, tt.side)
+		if !EqualFloat64(s.Evaluate(V3{tt.r0, 0, 0}), 0, tolerance) ||
+			!EqualFloat64(s.Evaluate(V3{0, tt.r1, 0}), 0, tolerance) {
+			t.Error("FAIL")
+		}
+		if s.Evaluate(V3{0, 0, 0.5 * (tt.r0 + tt.r1)}) >= 0 || s.Evaluate(V3{}) <= 0 {
+			t.Error("FAIL")
+		}
+		if !s.BoundingBox().Equals(NewBox3(V3{}, V3{2 * tt.r1, 2 * tt.r1, 2 * tt.r1}), tolerance) {
+			t.Error("FAIL")
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Offset3D(t *testing.T) {
+	box := Box3D(V3{10, 20, 30}, 0)
+	s := Offset3D(box, 0.5)
+	if !EqualFloat64(s.Evaluate(V3{5.5, 0, 0}), 0, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{0, 0, 0}), -5.5, tolerance) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(NewBox3(V3{}, V3{11, 21, 31}), tolerance) {
+		t.Error("FAIL")
+	}
+	s = Offset3D(box, -0.5)
+	if !EqualFloat64(s.Evaluate(V3{4.5, 0, 0}), 0, tolerance) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(NewBox3(V3{}, V3{9, 19, 29}), tolerance) {
+		t.Error("FAIL")
+	}
+	// the offset removes the thin dimension
+	s = Offset3D(box, -6)
+	if !s.BoundingBox().Equals(NewBox3(V3{}, V3{0, 8, 18}), tolerance) {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{}) <= 0 {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Sweep3D(t *testing.T) {
+	circle := Circle2D(1)
+	// straight path: a cylinder along x
+	s, err := Sweep3D(circle, []V3{{0, 0, 0}, {5, 0, 0}, {10, 0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualFloat64(s.Evaluate(V3{3, 0, 2}), 1, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{7, -1, 0}), 0, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{11, 0, 0}), 1, tolerance) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{0, -1, -1}, V3{10, 1, 1}}, tolerance) {
+		t.Error("FAIL")
+	}
+	// right angle bend: the joint is mitred
+	s, err = Sweep3D(circle, []V3{{0, 0, 0}, {10, 0, 0}, {10, 10, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualFloat64(s.Evaluate(V3{11, -1, 0}), 0, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{10, 5, 1}), 0, tolerance) ||
+		s.Evaluate(V3{10.2, -0.5, 0}) >= 0 || s.Evaluate(V3{10.5, 0.2, 0}) >= 0 {
+		t.Error("FAIL")
+	}
+	// bezier path
+	path := Bezier3([]V3{{0, 0, 0}, {0, 0, 5}, {10, 0, 5}, {10, 0, 0}}, 16)
+	if len(path) != 17 || !path[16].Equals(V3{10, 0, 0}, tolerance) || !EqualFloat64(path[8].Z, 3.75, tolerance) {
+		t.Error("FAIL")
+	}
+	if _, err = Sweep3D(circle, []V3{{1, 1, 1}, {1, 1, 1}}); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Helix3D(t *testing.T) {
+	constant := func(k float64) HelixFunc {
+		return func(z float64) float64 { return k }
+	}
+	// a constant helix is a screw
+	thread := ISOThread(5, 1, "external")
+	s0 := Screw3D(thread, 10, 1, 1)
+	s1, err := Helix3D(thread, 10, constant(1), constant(0), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb := s0.BoundingBox().ScaleAboutCenter(1.2)
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		if !EqualFloat64(s0.Evaluate(p), s1.Evaluate(p), 1e-6) {
+			t.Errorf("%v: screw %f helix %f", p, s0.Evaluate(p), s1.Evaluate(p))
+			break
+		}
+	}
+	// conical spring
+	s2, err := Helix3D(Circle2D(0.5), 12, constant(3), func(z float64) float64 { return 10 + 0.5*z }, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualFloat64(s2.Evaluate(V3{10, 0, 0}), -0.5/math.Sqrt(1.25), 1e-6) || s2.Evaluate(V3{11.5, 0, 3}) >= 0 {
+		t.Error("FAIL")
+	}
+	if !s2.BoundingBox().Equals(Box3{V3{-13.5, -13.5, -6}, V3{13.5, 13.5, 6}}, tolerance) {
+		t.Error("FAIL")
+	}
+	if _, err = Helix3D(Circle2D(0.5), 12, constant(0), constant(10), 1); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_VariableExtrude3D(t *testing.T) {
+	// constant scale
+	s := VariableExtrude3D(Circle2D(1), 10, nil, func(z float64) V2 { return V2{2, 2} })
+	if !EqualFloat64(s.Evaluate(V3{3, 0, 0}), 1, tolerance) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-2, -2, -5}, V3{2, 2, 5}}, tolerance) {
+		t.Error("FAIL")
+	}
+	// quarter twist
+	twist := func(z float64) float64 { return 0.5 * Pi * (z + 5) / 10 }
+	s = VariableExtrude3D(Box2D(V2{2, 4}, 0), 10, twist, nil)
+	if s.Evaluate(V3{0, 1.9, -4.9}) >= 0 || s.Evaluate(V3{1.9, 0, -4.9}) <= 0 {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{1.9, 0, 4.9}) >= 0 || s.Evaluate(V3{0, 1.9, 4.9}) <= 0 {
+		t.Error("FAIL")
+	}
+	// the distance must be conservative
+	exact := Box3D(V3{2, 4, 10}, 0)
+	s = VariableExtrude3D(Box2D(V2{2, 4}, 0), 10, nil, func(z float64) V2 { return V2{1, 1} })
+	bb := s.BoundingBox().ScaleAboutCenter(1.5)
+	for i := 0; i < 100; i++ {
+		p := bb.Random()
+		if s.Evaluate(p) > exact.Evaluate(p)+tolerance {
+			t.Error("FAIL")
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Bend3D(t *testing.T) {
+	// wrap a plaque around a cylinder below it
+	plaque := Box3D(V3{20, 4, 2}, 0)
+	s := Bend3D(plaque, -10, V3{0, 1, 0})
+	if !EqualFloat64(s.Evaluate(V3{11 * math.Sin(0.5), 0, -10 + 11*math.Cos(0.5)}), 0, 1e-9) {
+		t.Error("FAIL")
+	}
+	if !EqualFloat64(s.Evaluate(V3{12 * math.Sin(0.5), 0, -10 + 12*math.Cos(0.5)}), 0.9, 1e-9) {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{10 * math.Sin(0.9), 0, -10 + 10*math.Cos(0.9)}) >= 0 {
+		t.Error("FAIL")
+	}
+	bb := Box3{V3{-11 * math.Sin(1), -2, -10 + 9*math.Cos(1)}, V3{11 * math.Sin(1), 2, 1}}
+	if !s.BoundingBox().Equals(bb, 1e-9) {
+		t.Error("FAIL")
+	}
+	// a half circle bend
+	s = BendAngle3D(plaque, Pi, V3{0, 0, 1})
+	bb = s.BoundingBox()
+	if !EqualFloat64(bb.Size().X, 2*(20/Pi+2), 1e-9) || !EqualFloat64(bb.Size().Y, 20/Pi+2, 1e-9) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// lipschitz returns false if the SDF3 changes faster than k times the distance between sample points.
+func lipschitz(s SDF3, n int, k float64) bool {
+	bb := s.BoundingBox().ScaleAboutCenter(1.5)
+	for i := 0; i < n; i++ {
+		p0 := bb.Random()
+		p1 := p0.Add(bb.Random().Sub(bb.Center()).MulScalar(0.05))
+		if Abs(s.Evaluate(p0)-s.Evaluate(p1)) > k*p0.Sub(p1).Length()+tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func Test_TaperShear3D(t *testing.T) {
+	box := Box3D(V3{10, 10, 20}, 1)
+	// taper to a point at half size
+	s := Taper3D(box, 0.5)
+	if !s.BoundingBox().Equals(Box3{V3{-5, -5, -10}, V3{5, 5, 10}}, tolerance) {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{2, 2, 9.5}) >= 0 || s.Evaluate(V3{3, 0, 9.5}) <= 0 || s.Evaluate(V3{4.5, 0, -9.5}) >= 0 {
+		t.Error("FAIL")
+	}
+	// the taper distance scaling varies with the point, so allow some slack
+	if !lipschitz(s, 10000, 1.1) || !lipschitz(Taper3D(box, 3), 10000, 1.1) {
+		t.Error("FAIL")
+	}
+	// lean by 45 degrees
+	s = Shear3D(box, V2{1, 0})
+	if !s.BoundingBox().Equals(Box3{V3{-15, -5, -10}, V3{15, 5, 10}}, tolerance) {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{9, 0, 8}) >= 0 || s.Evaluate(V3{-9, 0, -8}) >= 0 || s.Evaluate(V3{0, 0, 8}) <= 0 {
+		t.Error("FAIL")
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Noise3(t *testing.T) {
+	noise := []Noise3{NewPerlin3(1), NewSimplex3(1), NewWorley3(1), Fractal3(NewPerlin3(1), 3)}
+	for _, n := range noise {
+		// values are in range and the slope bounds the gradient
+		for i := 0; i < 10000; i++ {
+			p := V3{randomRange(-20, 20), randomRange(-20, 20), randomRange(-20, 20)}
+			d := V3{randomRange(-1, 1), randomRange(-1, 1), randomRange(-1, 1)}.MulScalar(0.01)
+			v0 := n.Evaluate(p)
+			v1 := n.Evaluate(p.Add(d))
+			if v0 < -1 || v0 > 1 || Abs(v1-v0) > n.Slope()*d.Length() {
+				t.Error("FAIL")
+				break
+			}
+		}
+		// noise is repeatable for a seed
+		p := V3{1.3, 2.7, -4.1}
+		if n.Evaluate(p) != n.Evaluate(p) {
+			t.Error("FAIL")
+		}
+	}
+	if NewPerlin3(1).Evaluate(V3{0.5, 0.5, 0.5}) == NewPerlin3(2).Evaluate(V3{0.5, 0.5, 0.5}) {
+		t.Error("FAIL")
+	}
+	// displaced sphere
+	s := NoiseDisplace3D(Sphere3D(10), NewSimplex3(1), 3, 1)
+	if !s.BoundingBox().Equals(NewBox3(V3{}, V3{22, 22, 22}), tolerance) {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{8.9, 0, 0}) >= 0 || s.Evaluate(V3{0, -11.1, 0}) <= 0 {
+		t.Error("FAIL")
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Array3D(t *testing.T) {
+	for _, tt := range []struct {
+		sdf  SDF3
+		num  V3i
+		step V3
+	}{
+		{Cylinder3D(4, 1, 0), V3i{20, 10, 1}, V3{5, 5, 0}},
+		{Sphere3D(3), V3i{4, 5, 6}, V3{2, 2.5, 4}},
+		{Transform3D(Box3D(V3{3, 1, 1}, 0), Translate3d(V3{2, 1, 0})), V3i{5, 5, 2}, V3{-2, 1.5, 3}},
+	} {
+		s := Array3D(tt.sdf, tt.num, tt.step)
+		// explicit union of the copies
+		var copies []SDF3
+		for i := 0; i < tt.num[0]; i++ {
+			for j := 0; j < tt.num[1]; j++ {
+				for k := 0; k < tt.num[2]; k++ {
+					v := tt.step.Mul(V3{float64(i), float64(j), float64(k)})
+					copies = append(copies, Transform3D(tt.sdf, Translate3d(v)))
+				}
+			}
+		}
+		u := Union3D(copies...)
+		if !s.BoundingBox().Equals(u.BoundingBox(), tolerance) {
+			t.Error("FAIL")
+		}
+		bb := u.BoundingBox().ScaleAboutCenter(1.2)
+		for i := 0; i < 2000; i++ {
+			p := bb.Random()
+			d0 := u.Evaluate(p)
+			d1 := s.Evaluate(p)
+			if Sign(d0) != Sign(d1) || d1 > d0+tolerance {
+				t.Errorf("%v: union %f array %f", p, d0, d1)
+				break
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_RotateRepeat3D(t *testing.T) {
+	for _, tt := range []struct {
+		sdf        SDF3
+		num, count int
+	}{
+		{Transform3D(Box3D(V3{2, 1, 1}, 0), Translate3d(V3{10, 0, 0})), 100, 100},
+		{Transform3D(Box3D(V3{4, 3, 1}, 0), Translate3d(V3{5, 0, 0})), 12, 12},
+		{Transform3D(Box3D(V3{4, 3, 1}, 0), Translate3d(V3{5, 0, 0})), 12, 5},
+		{Transform3D(Sphere3D(2), Translate3d(V3{1, 0, 0})), 7, 3},
+	} {
+		s := RotateRepeat3D(tt.sdf, tt.num, tt.count)
+		// explicit union of the copies
+		var copies []SDF3
+		for i := 0; i < tt.count; i++ {
+			copies = append(copies, Transform3D(tt.sdf, RotateZ(float64(i)*Tau/float64(tt.num))))
+		}
+		u := Union3D(copies...)
+		if tt.count < tt.num && !s.BoundingBox().Equals(u.BoundingBox(), tolerance) {
+			t.Error("FAIL")
+		}
+		bb := u.BoundingBox().ScaleAboutCenter(1.2)
+		for i := 0; i < 2000; i++ {
+			p := bb.Random()
+			d0 := u.Evaluate(p)
+			d1 := s.Evaluate(p)
+			if Sign(d0) != Sign(d1) || d1 > d0+tolerance {
+				t.Errorf("%v: union %f repeat %f", p, d0, d1)
+				break
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Symmetry3D(t *testing.T) {
+	// an off center sphere mirrored into 4 quadrants
+	sphere := Transform3D(Sphere3D(2), Translate3d(V3{5, 4, 1}))
+	s := Symmetry3D(sphere, SymmetryX|SymmetryY)
+	if !s.BoundingBox().Equals(Box3{V3{-7, -6, -1}, V3{7, 6, 3}}, tolerance) {
+		t.Error("FAIL")
+	}
+	for _, p := range []V3{{5, 4, 1}, {-5, 4, 1}, {5, -4, 1}, {-5, -4, 1}} {
+		if !EqualFloat64(s.Evaluate(p), -2, tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	if s.Evaluate(V3{5, 4, -1.5}) <= 0 {
+		t.Error("FAIL")
+	}
+	// the negative half of the sdf is removed
+	s = Symmetry3D(Transform3D(Box3D(V3{2, 2, 2}, 0), Translate3d(V3{0, 0, 1})), SymmetryZ)
+	if !s.BoundingBox().Equals(Box3{V3{-1, -1, -2}, V3{1, 1, 2}}, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{0, 0, -1.5}), -0.5, tolerance) {
+		t.Error("FAIL")
+	}
+	// single plane mirrors
+	box := Transform3D(Box3D(V3{4, 6, 8}, 1), Translate3d(V3{3, 4, 5}))
+	for _, tt := range []struct {
+		s      SDF3
+		mirror V3
+		bb     Box3
+	}{
+		{MirrorX3D(box), V3{-1, 1, 1}, Box3{V3{-5, 1, 1}, V3{5, 7, 9}}},
+		{MirrorY3D(box), V3{1, -1, 1}, Box3{V3{1, -7, 1}, V3{5, 7, 9}}},
+		{MirrorZ3D(box), V3{1, 1, -1}, Box3{V3{1, 1, -9}, V3{5, 7, 9}}},
+	} {
+		if !tt.s.BoundingBox().Equals(tt.bb, tolerance) {
+			t.Error("FAIL")
+		}
+		for i := 0; i < 100; i++ {
+			p := tt.bb.Random()
+			// the mirrored point has the same distance
+			if tt.s.Evaluate(p) != tt.s.Evaluate(p.Mul(tt.mirror)) {
+				t.Error("FAIL")
+				break
+			}
+			// the positive side is unchanged
+			q := p.Abs()
+			if tt.s.Evaluate(q) != box.Evaluate(q) {
+				t.Error("FAIL")
+				break
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Blend(t *testing.T) {
+	for _, kernel := range []Blend{BlendPoly, BlendCubic, BlendExp, BlendRoot} {
+		min := BlendMin(kernel, 0.5)
+		max := BlendMax(kernel, 0.5)
+		// the blend pulls the join out by k
+		if !EqualFloat64(min(1, 1), 0.5, tolerance) || !EqualFloat64(max(1, 1), 1.5, tolerance) {
+			t.Error("FAIL")
+		}
+		// the blend is close to min/max away from the join
+		if Abs(min(0, 100)) > 1e-2 || Abs(max(0, -100)) > 1e-2 {
+			t.Error("FAIL")
+		}
+		// the blend is symmetric
+		if !EqualFloat64(min(0.3, 1.2), min(1.2, 0.3), tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	// compact kernels are exact away from the join
+	if BlendMin(BlendPoly, 0.5)(0, 2) != 0 || BlendMin(BlendCubic, 0.5)(0, 3) != 0 {
+		t.Error("FAIL")
+	}
+	s0 := Box3D(V3{10, 10, 10}, 0)
+	s1 := Transform3D(Box3D(V3{10, 10, 10}, 0), Translate3d(V3{5, 0, 0}))
+	// a blended cylinder on top of a box
+	u := SmoothUnion3D(BlendPoly, 1, s0, Transform3D(Cylinder3D(10, 2, 0), Translate3d(V3{0, 0, 7})))
+	if u.Evaluate(V3{4, 4, 0}) != s0.Evaluate(V3{4, 4, 0}) || u.Evaluate(V3{2.3, 0, 5.3}) >= 0 {
+		t.Error("FAIL")
+	}
+	i := SmoothIntersect3D(BlendCubic, 1, s0, s1)
+	if i.Evaluate(V3{2.5, 0, 0}) >= 0 || i.Evaluate(V3{4.9, 4.9, 0}) <= 0 {
+		t.Error("FAIL")
+	}
+	d := SmoothDifference3D(BlendRoot, 1, s0, s1)
+	if d.Evaluate(V3{-2, 0, 0}) >= 0 || d.Evaluate(V3{-0.1, 4.9, 0}) <= 0 {
+		t.Error("FAIL")
+	}
+	// degenerate arguments fall back to the unblended operation
+	if SmoothIntersect3D(BlendCubic, 1, s0, nil) != Intersect3D(s0, nil) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Chamfer3D(t *testing.T) {
+	// a pocket with chamfered edges
+	block := Box3D(V3{20, 20, 10}, 0)
+	pocket := Transform3D(Box3D(V3{10, 10, 10}, 0), Translate3d(V3{0, 0, 5}))
+	s := ChamferDifference3D(1, block, pocket)
+	// the chamfer removes the corner of the pocket edge
+	if s.Evaluate(V3{5.4, 0, 4.6}) <= 0 || s.Evaluate(V3{5.7, 0, 4.6}) >= 0 {
+		t.Error("FAIL")
+	}
+	// away from the edge the pocket is unchanged
+	if !EqualFloat64(s.Evaluate(V3{7, 0, 2}), -2, tolerance) || s.Evaluate(V3{0, 0, 2}) <= 0 {
+		t.Error("FAIL")
+	}
+	// a bolt head on a plate with a chamfered join
+	plate := Box3D(V3{20, 20, 2}, 0)
+	head := Transform3D(Cylinder3D(4, 3, 0), Translate3d(V3{0, 0, 3}))
+	s = ChamferUnion3D(1, plate, head)
+	if s.Evaluate(V3{3.4, 0, 1.4}) >= 0 || s.Evaluate(V3{3.6, 0, 1.6}) <= 0 {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-11, -11, -2}, V3{11, 11, 6}}, tolerance) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Morph3D(t *testing.T) {
+	s0 := Sphere3D(1)
+	s1 := Sphere3D(3)
+	// morphing between spheres gives a sphere
+	s := Morph3D(s0, s1, 0.25)
+	if !EqualFloat64(s.Evaluate(V3{1.5, 0, 0}), 0, tolerance) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(s1.BoundingBox(), tolerance) {
+		t.Error("FAIL")
+	}
+	keys := []MorphKey{{0, s0}, {1, s1}, {3, Sphere3D(2)}}
+	for _, tt := range []struct {
+		t, r float64
+	}{
+		{-1, 1}, {0, 1}, {0.5, 2}, {1, 3}, {2, 2.5}, {3, 2}, {4, 2},
+	} {
+		s, err := Keyframe3D(keys, tt.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !EqualFloat64(s.Evaluate(V3{0, tt.r, 0}), 0, tolerance) {
+			t.Errorf("t %f: expected radius %f", tt.t, tt.r)
+		}
+	}
+	if _, err := Keyframe3D([]MorphKey{{1, s0}, {0, s1}}, 0.5); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+//-----------------------------------------------------------------------------
+
+func Test_Hull3D(t *testing.T) {
+	s0 := Sphere3D(2)
+	s1 := Transform3D(s0, Translate3d(V3{10, 0, 0}))
+	s, err := Hull3D(50, s0, s1)
+	if err != nil {
+		t.Error("FAIL")
+	}
+	// the hull of two spheres is a capsule
+	bb := s.BoundingBox()
+	if !bb.Equals(Box3{V3{-2, -2, -2}, V3{12, 2, 2}}, 0.1) {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{5, 0, 0}) >= 0 || s.Evaluate(V3{5, 0, 1.8}) >= 0 {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{5, 0, 2.2}) <= 0 || s.Evaluate(V3{5, 1.6, 1.6}) <= 0 {
+		t.Error("FAIL")
+	}
+	if !EqualFloat64(s.Evaluate(V3{5, 0, 3}), 1, 0.1) {
+		t.Error("FAIL")
+	}
+	// the hull of a box is the box
+	b := Box3D(V3{4, 6, 8}, 0)
+	s, err = Hull3D(50, b)
+	if err != nil {
+		t.Error("FAIL")
+	}
+	for _, p := range []V3{{0, 0, 0}, {1, 2, 3}, {3, 0, 0}, {0, 4, 1}, {-1, 1, -5}} {
+		if !EqualFloat64(s.Evaluate(p), b.Evaluate(p), 0.05) {
+			t.Error("FAIL")
+		}
+	}
+	// no surfaces
+	_, err = Hull3D(50)
+	if err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Minkowski3D(t *testing.T) {
+	// cube + sphere = rounded box
+	s, err := Minkowski3D(Box3D(V3{2, 2, 2}, 0), Sphere3D(1))
+	if err != nil {
+		t.Error("FAIL")
+	}
+	r := Box3D(V3{4, 4, 4}, 1)
+	bb := s.BoundingBox()
+	if !bb.Equals(r.BoundingBox(), tolerance) {
+		t.Error("FAIL")
+	}
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		if !EqualFloat64(s.Evaluate(p), r.Evaluate(p), tolerance) {
+			t.Error("FAIL")
+			break
+		}
+	}
+	// cube + box = box
+	s, err = Minkowski3D(Box3D(V3{2, 2, 2}, 0), Box3D(V3{2, 4, 6}, 0.5))
+	if err != nil {
+		t.Error("FAIL")
+	}
+	r = Box3D(V3{4, 6, 8}, 0.5)
+	bb = s.BoundingBox()
+	if !bb.Equals(r.BoundingBox(), tolerance) {
+		t.Error("FAIL")
+	}
+	bb = bb.ScaleAboutCenter(2)
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		d0, d1 := s.Evaluate(p), r.Evaluate(p)
+		if (d0 < 0) != (d1 < 0) || (d1 > 0 && !EqualFloat64(d0, d1, 1e-6)) {
+			t.Error("FAIL")
+			break
+		}
+	}
+	// an L shape (concave) + an offset sphere
+	l := Difference3D(Box3D(V3{10, 10, 4}, 0), Transform3D(Box3D(V3{10, 10, 6}, 0), Translate3d(V3{5, 5, 0})))
+	s, err = Minkowski3D(l, Transform3D(Sphere3D(1), Translate3d(V3{0, 0, 1})))
+	if err != nil {
+		t.Error("FAIL")
+	}
+	test := []struct {
+		p V3
+		d float64
+	}{
+		{V3{1.5, 3, 1}, 0.5},
+		{V3{0.5, 3, 0}, -0.5},
+		{V3{0.8, 0.8, 0}, -0.2},
+		{V3{-6.5, 0, 0}, 0.5},
+		{V3{-3, -3, 3.5}, -0.5},
+		{V3{-3, -3, -2.5}, 0.5},
+	}
+	for _, v := range test {
+		if !EqualFloat64(s.Evaluate(v.p), v.d, 1e-6) {
+			t.Error("FAIL")
+		}
+	}
+	_, err = Minkowski3D(l, nil)
+	if err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_SliceAxis2D(t *testing.T) {
+	s3 := Box3D(V3{10, 20, 30}, 1)
+	a := V3{1, 2, 3}
+	n := V3{1, 1, 1}
+	s2, err := SliceAxis2D(s3, a, n, V3{0, 0, 1})
+	if err != nil {
+		t.Error("FAIL")
+	}
+	m := s2.(*SliceSDF2).Placement()
+	// the origin of the slice is the point on the plane
+	if !m.MulPosition(V3{}).Equals(a, tolerance) {
+		t.Error("FAIL")
+	}
+	// the x-axis is the projection of +z onto the plane
+	x := m.MulPosition(V3{1, 0, 0}).Sub(a)
+	if !x.Equals(V3{-1, -1, 2}.Normalize(), tolerance) {
+		t.Error("FAIL")
+	}
+	// the slice plane maps to the xy plane
+	z := m.MulPosition(V3{0, 0, 1}).Sub(a)
+	if !z.Equals(n.Normalize(), tolerance) {
+		t.Error("FAIL")
+	}
+	bb := s2.BoundingBox()
+	for i := 0; i < 100; i++ {
+		p := bb.Random()
+		if !EqualFloat64(s2.Evaluate(p), s3.Evaluate(m.MulPosition(V3{p.X, p.Y, 0})), tolerance) {
+			t.Error("FAIL")
+			break
+		}
+	}
+	// Slice2D placement
+	s2 = Slice2D(s3, a, V3{0, 0, 1})
+	m = s2.(*SliceSDF2).Placement()
+	if !m.Equals(Translate3d(a), tolerance) {
+		t.Error("FAIL")
+	}
+	// bad axes
+	if _, err := SliceAxis2D(s3, a, V3{}, V3{1, 0, 0}); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := SliceAxis2D(s3, a, n, V3{2, 2, 2}); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_RevolveAxis3D(t *testing.T) {
+	b := Transform2D(Box2D(V2{2, 4}, 0), Translate2d(V2{5, 0}))
+	// a z-axis revolution with a start angle is a rotated revolution
+	s0 := RevolveAxis3D(b, V3{}, V3{0, 0, 1}, DtoR(30), DtoR(100))
+	s1 := Transform3D(RevolveTheta3D(b, DtoR(100)), RotateZ(DtoR(30)))
+	bb := s1.BoundingBox()
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		if !EqualFloat64(s0.Evaluate(p), s1.Evaluate(p), 1e-9) {
+			t.Error("FAIL")
+			break
+		}
+	}
+	// the bounding box is the box of the wedge
+	bb0 := Box3{V3{6 * math.Cos(DtoR(130)), 0, -2}, V3{6 * math.Cos(DtoR(30)), 6, 2}}
+	if !s0.BoundingBox().Equals(bb0, tolerance) {
+		t.Error("FAIL")
+	}
+	// revolve about the x-axis, offset from the origin
+	s0 = RevolveAxis3D(b, V3{0, 10, 0}, V3{1, 0, 0}, 0, 0)
+	s1 = Transform3D(Revolve3D(b), Translate3d(V3{0, 10, 0}).Mul(RotateY(DtoR(90))))
+	bb = s1.BoundingBox()
+	if !s0.BoundingBox().Equals(bb, tolerance) {
+		t.Error("FAIL")
+	}
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		if !EqualFloat64(s0.Evaluate(p), s1.Evaluate(p), 1e-9) {
+			t.Error("FAIL")
+			break
+		}
+	}
+	// revolve about -z
+	s0 = RevolveAxis3D(b, V3{}, V3{0, 0, -1}, 0, 0)
+	if !EqualFloat64(s0.Evaluate(V3{5, 0, 1.5}), -0.5, tolerance) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_ElongateDir(t *testing.T) {
+	// a stadium along a diagonal
+	s2 := ElongateDir2D(Circle2D(1), V2{1, 1}, 4)
+	k := math.Sqrt(2)
+	a, b := V2{-k, -k}, V2{k, k}
+	bb2 := s2.BoundingBox()
+	if !bb2.Equals(Box2{V2{-k - 1, -k - 1}, V2{k + 1, k + 1}}, tolerance) {
+		t.Error("FAIL")
+	}
+	for i := 0; i < 1000; i++ {
+		p := bb2.Random()
+		// distance to the line segment
+		ab := b.Sub(a)
+		x := Clamp(p.Sub(a).Dot(ab)/ab.Dot(ab), 0, 1)
+		d := p.Sub(a.Add(ab.MulScalar(x))).Length() - 1
+		if !EqualFloat64(s2.Evaluate(p), d, tolerance) {
+			t.Error("FAIL")
+			break
+		}
+	}
+	// an axis aligned direction matches Elongate3D
+	box := Box3D(V3{1, 2, 3}, 0.2)
+	s0 := ElongateDir3D(box, V3{0, -2, 0}, 5)
+	s1 := Elongate3D(box, V3{0, 5, 0})
+	bb := s1.BoundingBox()
+	if !s0.BoundingBox().Equals(bb, tolerance) {
+		t.Error("FAIL")
+	}
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		if !EqualFloat64(s0.Evaluate(p), s1.Evaluate(p), tolerance) {
+			t.Error("FAIL")
+			break
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_FilletEdge3D(t *testing.T) {
+	// a plate with a wall, the wall/plate join is a concave edge along y
+	plate := Box3D(V3{20, 20, 2}, 0)
+	wall := Transform3D(Box3D(V3{2, 20, 10}, 0), Translate3d(V3{0, 0, 5}))
+	u := Union3D(plate, wall)
+	// fillet the join for y > 0
+	mask := Transform3D(Box3D(V3{20, 10, 20}, 0), Translate3d(V3{0, 5, 0}))
+	s, err := FilletEdge3D(u, mask, 0.5)
+	if err != nil {
+		t.Error("FAIL")
+	}
+	// the join point is filled in within the mask
+	if s.Evaluate(V3{1.2, 5, 1.2}) >= 0 || u.Evaluate(V3{1.2, 5, 1.2}) <= 0 {
+		t.Error("FAIL")
+	}
+	// but not outside the mask (the distance is scaled to keep it a bound)
+	if !EqualFloat64(s.Evaluate(V3{1.2, -5, 1.2})*1.375, u.Evaluate(V3{1.2, -5, 1.2}), tolerance) {
+		t.Error("FAIL")
+	}
+	// away from the join the surfaces are unchanged
+	for _, p := range []V3{{5, 5, 1}, {5, -5, 1}, {1, 5, 8}, {-5, 5, -1}} {
+		if !EqualFloat64(s.Evaluate(p)*1.375, u.Evaluate(p), tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// round a convex edge of a difference
+	d := Difference3D(Box3D(V3{10, 10, 10}, 0), Transform3D(Box3D(V3{10, 10, 10}, 0), Translate3d(V3{5, 5, 5})))
+	s, err = FilletEdge3D(d, Box3D(V3{20, 20, 20}, 0), 0.5)
+	if err != nil {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{-0.1, 2, 4.9}) <= 0 || d.Evaluate(V3{-0.1, 2, 4.9}) >= 0 {
+		t.Error("FAIL")
+	}
+	// not a CSG operation
+	if _, err := FilletEdge3D(plate, mask, 0.5); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_TPMS(t *testing.T) {
+	for _, s := range []SDF3{
+		Gyroid3D(10, 1),
+		SchwarzP3D(10, 1),
+		Diamond3D(10, 1),
+	} {
+		if !lipschitz(s, 10000, 1) {
+			t.Error("FAIL")
+		}
+		// measure the mean wall thickness along random lines
+		bb := s.BoundingBox()
+		sum, n := 0.0, 0
+		for i := 0; i < 200; i++ {
+			p := bb.Random()
+			dir := bb.Random().Sub(bb.Center()).Normalize()
+			inside := false
+			x0 := 0.0
+			for x := 0.0; x < 20; x += 0.01 {
+				in := s.Evaluate(p.Add(dir.MulScalar(x))) < 0
+				if in && !inside {
+					x0 = x
+				}
+				if !in && inside && x0 > 0 {
+					// wall crossings along the line are at least as thick as the wall
+					sum += x - x0
+					n++
+				}
+				inside = in
+			}
+		}
+		if n == 0 || sum/float64(n) < 1 || sum/float64(n) > 3 {
+			t.Error("FAIL")
+		}
+	}
+	// the gyroid and diamond surfaces pass through the origin
+	if Gyroid3D(10, 1).Evaluate(V3{}) >= 0 || Diamond3D(10, 1).Evaluate(V3{}) >= 0 {
+		t.Error("FAIL")
+	}
+	// infill a box
+	box := Box3D(V3{40, 40, 40}, 0)
+	s := Infill3D(box, Gyroid3D(10, 1), 2)
+	if !s.BoundingBox().Equals(box.BoundingBox(), tolerance) {
+		t.Error("FAIL")
+	}
+	// outside
+	if s.Evaluate(V3{21, 0, 0}) <= 0 {
+		t.Error("FAIL")
+	}
+	// in the shell
+	if s.Evaluate(V3{19, 2.5, 2.5}) >= 0 {
+		t.Error("FAIL")
+	}
+	// in the interior, on and off the lattice
+	if s.Evaluate(V3{0, 0, 0}) >= 0 || s.Evaluate(V3{2.5, 0, 0}) <= 0 {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Honeycomb3D(t *testing.T) {
+	s := Honeycomb3D(10, 1)
+	k := math.Sqrt(3)
+	test := []struct {
+		p V3
+		d float64
+	}{
+		{V3{0, 0, 0}, 4.5},
+		{V3{0, 5, 7}, -0.5},
+		{V3{0, 4, 0}, 0.5},
+		{V3{0, 10, 0}, 4.5},
+		{V3{5 * k, 5, 3}, 4.5},
+		{V3{10 * k, 0, 0}, 4.5},
+		{V3{10 / k, 0, -1}, -0.5},
+		{V3{10*k + 10/k, 0, 0}, -0.5},
+	}
+	for _, v := range test {
+		if !EqualFloat64(s.Evaluate(v.p), v.d, tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+}
+
+func Test_Grid3D(t *testing.T) {
+	s := Grid3D(V2{10, 20}, 2)
+	test := []struct {
+		p V3
+		d float64
+	}{
+		{V3{0, 0, 0}, 4},
+		{V3{5, 0, 0}, -1},
+		{V3{25, 40, 0}, -1},
+		{V3{22, 47, 5}, 2},
+		{V3{-18, 1, 5}, 2},
+	}
+	for _, v := range test {
+		if !EqualFloat64(s.Evaluate(v.p), v.d, tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Voronoi3D(t *testing.T) {
+	s := Voronoi3D(10, 1, 1)
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// the cell points are the furthest from the walls
+	v := s.(*VoronoiSDF3)
+	for i := 0; i < 10; i++ {
+		p := v.points.feature(i, 2*i, -i).MulScalar(10)
+		if s.Evaluate(p) <= 0 {
+			t.Error("FAIL")
+		}
+	}
+	// the midpoint of neighboring cell points is on a wall
+	p := v.points.feature(0, 0, 0).Add(v.points.feature(1, 0, 0)).MulScalar(5)
+	if s.Evaluate(p) > 0 {
+		t.Error("FAIL")
+	}
+	// the pattern depends on the seed
+	s0 := Voronoi3D(10, 1, 1)
+	s1 := Voronoi3D(10, 1, 2)
+	same, differ := true, false
+	for i := 0; i < 100; i++ {
+		p := V3{randomRange(-50, 50), randomRange(-50, 50), randomRange(-50, 50)}
+		same = same && s.Evaluate(p) == s0.Evaluate(p)
+		differ = differ || s.Evaluate(p) != s1.Evaluate(p)
+	}
+	if !same || !differ {
+		t.Error("FAIL")
+	}
+	// a perforated spherical shell
+	vs := VoronoiShell3D(Sphere3D(20), 2, 8, 1.5, 1)
+	if !vs.BoundingBox().Equals(Sphere3D(20).BoundingBox(), tolerance) {
+		t.Error("FAIL")
+	}
+	if vs.Evaluate(V3{}) <= 0 || vs.Evaluate(V3{21, 0, 0}) <= 0 {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_SurfaceTexture3D(t *testing.T) {
+	box := Box3D(V3{20, 20, 20}, 0)
+	// a cut in texture
+	s := SurfaceTexture3D(box, Circle2D(1), V2{4, 4}, 0.5)
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(box.BoundingBox(), tolerance) {
+		t.Error("FAIL")
+	}
+	// away from the surface the distance is scaled
+	if !EqualFloat64(s.Evaluate(V3{}), 0.4*box.Evaluate(V3{}), tolerance) {
+		t.Error("FAIL")
+	}
+	// the surface is cut away at the center of a motif, but not between motifs
+	if s.Evaluate(V3{0, 0, 9.8}) <= 0 || s.Evaluate(V3{2, 2, 9.8}) >= 0 {
+		t.Error("FAIL")
+	}
+	// a raised texture
+	s = SurfaceTexture3D(box, Circle2D(1), V2{4, 4}, -0.5)
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-10.5, -10.5, -10.5}, V3{10.5, 10.5, 10.5}}, tolerance) {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{4, 0, 10.2}) >= 0 || s.Evaluate(V3{2, 2, 10.2}) <= 0 {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Emboss3D(t *testing.T) {
+	box := Box3D(V3{20, 20, 20}, 0)
+	art := Box2D(V2{6, 2}, 0)
+	// emboss the top face, artwork x-axis along y
+	s, err := Emboss3D(box, art, V3{0, 0, 10}, V3{0, 0, 1}, V3{0, 1, 0}, 1)
+	if err != nil {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{0, 2.5, 10.5}) >= 0 || s.Evaluate(V3{2.5, 0, 10.5}) <= 0 {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-10, -10, -10}, V3{10, 10, 11}}, tolerance) {
+		t.Error("FAIL")
+	}
+	// engrave the +x face
+	s, err = Emboss3D(box, art, V3{10, 0, 0}, V3{1, 0, 0}, V3{0, 1, 0}, -1)
+	if err != nil {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{9.5, 2.5, 0}) <= 0 || s.Evaluate(V3{9.5, 0, 2.5}) >= 0 || s.Evaluate(V3{8.5, 0, 0}) >= 0 {
+		t.Error("FAIL")
+	}
+	// bad faces
+	_, err = Emboss3D(box, art, V3{}, V3{}, V3{1, 0, 0}, 1)
+	if err == nil {
+		t.Error("FAIL")
+	}
+	_, err = Emboss3D(box, art, V3{}, V3{1, 0, 0}, V3{2, 0, 0}, 1)
+	if err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_CylinderWrap3D(t *testing.T) {
+	c := Cylinder3D(20, 10, 0)
+	art := Box2D(V2{6, 2}, 0)
+	s, err := CylinderWrap3D(c, art, 10, 1)
+	if err != nil {
+		t.Error("FAIL")
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// the artwork x-axis goes around the cylinder
+	sin, cos := math.Sincos(2.5 / 10)
+	if s.Evaluate(V3{10.5 * cos, 10.5 * sin, 0}) >= 0 || s.Evaluate(V3{10.5, 0, 2}) <= 0 {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-11, -11, -10}, V3{11, 11, 10}}, tolerance) {
+		t.Error("FAIL")
+	}
+	// engraved
+	s, err = CylinderWrap3D(c, art, 10, -1)
+	if err != nil {
+		t.Error("FAIL")
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{9.5 * cos, 9.5 * sin, 0}) <= 0 || s.Evaluate(V3{-9.5, 0, 0}) >= 0 {
+		t.Error("FAIL")
+	}
+	// bad parameters
+	_, err = CylinderWrap3D(c, art, 0, 1)
+	if err == nil {
+		t.Error("FAIL")
+	}
+	_, err = CylinderWrap3D(c, Box2D(V2{100, 2}, 0), 10, 1)
+	if err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_SphereConeWrap3D(t *testing.T) {
+	art := Box2D(V2{6, 2}, 0)
+	// sphere
+	s, err := SphereWrap3D(Sphere3D(10), art, 10, 1)
+	if err != nil {
+		t.Error("FAIL")
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// distances from the top of the sphere are preserved
+	sin, cos := math.Sincos(2.5 / 10)
+	if s.Evaluate(V3{10.5 * sin, 0, 10.5 * cos}) >= 0 || s.Evaluate(V3{0, 10.5 * sin, 10.5 * cos}) <= 0 {
+		t.Error("FAIL")
+	}
+	if s.BoundingBox().Max.Z != 11 {
+		t.Error("FAIL")
+	}
+	_, err = SphereWrap3D(Sphere3D(10), Box2D(V2{40, 2}, 0), 10, 1)
+	if err == nil {
+		t.Error("FAIL")
+	}
+	// cone with the apex above
+	c := Cone3D(20, 10, 5, 0)
+	s, err = ConeWrap3D(c, art, 20, 10, 5, -0.5)
+	if err != nil {
+		t.Error("FAIL")
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// up the slope at the artwork origin
+	u := V3{-5, 0, 20}.Normalize()
+	n := V3{20, 0, 5}.Normalize()
+	p := V3{7.5, 0, 0}.Add(n.MulScalar(-0.25))
+	if s.Evaluate(p.Add(u.MulScalar(0.5))) <= 0 || s.Evaluate(p.Add(u.MulScalar(1.5))) >= 0 {
+		t.Error("FAIL")
+	}
+	// cone with the apex below, a cylinder
+	for _, r := range []float64{5, 10} {
+		s, err = ConeWrap3D(Cone3D(20, 5, r, 0), art, 20, 5, r, 0.5)
+		if err != nil {
+			t.Error("FAIL")
+		}
+		if !lipschitz(s, 10000, 1) {
+			t.Error("FAIL")
+		}
+	}
+	// the artwork reaches the apex
+	_, err = ConeWrap3D(c, Box2D(V2{2, 80}, 0), 20, 10, 5, 1)
+	if err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Select3D(t *testing.T) {
+	// a thick cylinder for z > 0, a thin cylinder for z < 0
+	region := Box3D(V3{40, 40, 20}, 0)
+	region = Transform3D(region, Translate3d(V3{0, 0, 10}))
+	a := Cylinder3D(20, 5, 0)
+	b := Cylinder3D(20, 3, 0)
+	for _, k := range []float64{0, 0.5} {
+		s := Select3D(region, a, b, k)
+		if !lipschitz(s, 10000, 1) {
+			t.Error("FAIL")
+		}
+		if s.Evaluate(V3{4, 0, 5}) >= 0 || s.Evaluate(V3{4, 0, -5}) <= 0 {
+			t.Error("FAIL")
+		}
+		if !EqualFloat64(s.Evaluate(V3{10, 0, 5}), 5, tolerance) || !EqualFloat64(s.Evaluate(V3{5, 0, -5}), 2, tolerance) {
+			t.Error("FAIL")
+		}
+		// no seam inside with a smooth transition
+		if k > 0 && s.Evaluate(V3{0, 0, 0}) >= 0 {
+			t.Error("FAIL")
+		}
+	}
+	s := Select3D(region, a, b, 0)
+	if !s.BoundingBox().Equals(a.BoundingBox(), tolerance) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_MinScale(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		// rotations and translations don't scale
+		m := Rotate3d(V3{randomRange(-1, 1), randomRange(-1, 1), randomRange(-1, 1)}, randomRange(-Pi, Pi))
+		m = Translate3d(V3{randomRange(-10, 10), 0, 0}).Mul(m)
+		if !EqualFloat64(m.MinScale(), 1, tolerance) {
+			t.Error("FAIL")
+		}
+		// the minimum scale is found after a rotation
+		k := V3{randomRange(0.1, 5), randomRange(0.1, 5), randomRange(0.1, 5)}
+		m = m.Mul(Scale3d(k))
+		if !EqualFloat64(m.MinScale(), k.MinComponent(), tolerance) {
+			t.Error("FAIL")
+		}
+		m2 := Rotate2d(randomRange(-Pi, Pi)).Mul(Scale2d(V2{k.X, k.Y}))
+		if !EqualFloat64(m2.MinScale(), Min(k.X, k.Y), tolerance) {
+			t.Error("FAIL")
+		}
+	}
+}
+
+func Test_Scale3D(t *testing.T) {
+	// a thin box stretched a lot on one axis
+	box := Box3D(V3{2, 2, 2}, 0.2)
+	s := Scale3D(box, V3{10, 1, 0.5})
+	if !s.BoundingBox().Equals(Box3{V3{-10, -1, -0.5}, V3{10, 1, 0.5}}, tolerance) {
+		t.Error("FAIL")
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// the distance is a lower bound
+	if s.Evaluate(V3{0, 0, 1.5}) > 1 || s.Evaluate(V3{0, 0, 1.5}) <= 0 {
+		t.Error("FAIL")
+	}
+	// a negative uniform scale is a reflection
+	s = ScaleUniform3D(box, -2)
+	if s.Evaluate(V3{}) >= 0 || !EqualFloat64(s.Evaluate(V3{4, 0, 0}), 2, tolerance) {
+		t.Error("FAIL")
+	}
+	// 2d
+	s2 := Scale2D(Circle2D(1), V2{5, 0.5})
+	if !EqualFloat64(s2.Evaluate(V2{0, 1.5}), 1, tolerance) {
+		t.Error("FAIL")
+	}
+	if s2.Evaluate(V2{4.9, 0}) >= 0 || s2.Evaluate(V2{5.1, 0}) <= 0 {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Coil3D(t *testing.T) {
+	for _, ground := range []bool{false, true} {
+		for _, turns := range []float64{0.3, 2, 5.5} {
+			s, err := Coil3D(Circle2D(1), 8, 4, turns, ground)
+			if err != nil {
+				t.Error("FAIL")
+			}
+			if !lipschitz(s, 10000, 1) {
+				t.Error("FAIL")
+			}
+		}
+	}
+	s, _ := Coil3D(Circle2D(1), 8, 4, 2.5, false)
+	if !s.BoundingBox().Equals(Box3{V3{-9, -9, -6}, V3{9, 9, 6}}, tolerance) {
+		t.Error("FAIL")
+	}
+	// the coil starts on the x-axis at the bottom
+	if !EqualFloat64(s.Evaluate(V3{8, 0, -5}), 0, tolerance) || !EqualFloat64(s.Evaluate(V3{8, 0.5, -5}), -0.5, tolerance) {
+		t.Error("FAIL")
+	}
+	// a turn later, and between the turns
+	if s.Evaluate(V3{8, 0, -1}) > -0.95 || s.Evaluate(V3{8, 0, -3}) <= 0 {
+		t.Error("FAIL")
+	}
+	// and ends on the negative x-axis at the top
+	if s.Evaluate(V3{-8, 0.5, 5}) >= 0 || s.Evaluate(V3{-8, -0.5, 5}) <= 0 {
+		t.Error("FAIL")
+	}
+	// beyond the ends
+	if !EqualFloat64(s.Evaluate(V3{8, -2, -5}), 2, tolerance) {
+		t.Error("FAIL")
+	}
+	// ground ends
+	s, _ = Coil3D(Circle2D(1), 8, 4, 2.5, true)
+	if s.Evaluate(V3{8, 0.5, -5.5}) <= 0 || s.Evaluate(V3{8, 0.5, -4.5}) >= 0 {
+		t.Error("FAIL")
+	}
+	// bad parameters
+	_, err := Coil3D(Circle2D(1), 0.5, 4, 2, false)
+	if err == nil {
+		t.Error("FAIL")
+	}
+	_, err = Coil3D(Circle2D(1), 8, 0, 2, false)
+	if err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Primitives3D(t *testing.T) {
+	// torus
+	s := Torus3D(10, 2)
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	if !EqualFloat64(s.Evaluate(V3{10, 0, 0}), -2, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{0, 0, 0}), 8, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{0, 10, 5}), 3, tolerance) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-12, -12, -2}, V3{12, 12, 2}}, tolerance) {
+		t.Error("FAIL")
+	}
+	// capsule
+	s = Capsule3D(3, 20)
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	if !EqualFloat64(s.Evaluate(V3{5, 0, 0}), 2, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{0, 0, 12}), 2, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{3, 0, 7}), 0, tolerance) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-3, -3, -10}, V3{3, 3, 10}}, tolerance) {
+		t.Error("FAIL")
+	}
+	// ellipsoid
+	s = Ellipsoid3D(V3{10, 5, 2})
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	if !EqualFloat64(s.Evaluate(V3{10, 0, 0}), 0, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{0, 5, 0}), 0, tolerance) ||
+		s.Evaluate(V3{0, 0, 2.5}) <= 0 || s.Evaluate(V3{9, 0, 0}) >= 0 {
+		t.Error("FAIL")
+	}
+	if !EqualFloat64(Ellipsoid3D(V3{3, 3, 3}).Evaluate(V3{1, 2, 9}), Sphere3D(3).Evaluate(V3{1, 2, 9}), tolerance) {
+		t.Error("FAIL")
+	}
+	// rounded cone
+	s = RoundedCone3D(10, 4, 2)
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	if !EqualFloat64(s.Evaluate(V3{0, 0, -12}), 3, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{0, 0, 8}), 1, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{0, 0, 0}), -3, tolerance) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-4, -4, -9}, V3{4, 4, 7}}, tolerance) {
+		t.Error("FAIL")
+	}
+	// equal radii is a capsule
+	s = RoundedCone3D(10, 3, 3)
+	for i := 0; i < 100; i++ {
+		p := V3{randomRange(-10, 10), randomRange(-10, 10), randomRange(-10, 10)}
+		if !EqualFloat64(s.Evaluate(p), Capsule3D(3, 16).Evaluate(p), tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	// pyramid
+	s = Pyramid3D(10, 20)
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	if !EqualFloat64(s.Evaluate(V3{0, 0, 15}), 5, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{0, 0, -12}), 2, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{5, 5, -10}), 0, tolerance) ||
+		s.Evaluate(V3{0, 0, 0}) >= 0 || s.Evaluate(V3{4, 4, 0}) <= 0 {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-5, -5, -10}, V3{5, 5, 10}}, tolerance) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Polyhedra(t *testing.T) {
+	for _, test := range []struct {
+		s     SDF3
+		edges int
+	}{
+		{Tetrahedron3D(5, 0), 6},
+		{Octahedron3D(5, 0), 12},
+		{Dodecahedron3D(5, 0), 30},
+		{Icosahedron3D(5, 0), 30},
+		{Icosahedron3D(5, 1), 30},
+	} {
+		if len(test.s.(*PolyhedronSDF3).a) != test.edges {
+			t.Error("FAIL")
+		}
+		if !EqualFloat64(test.s.Evaluate(V3{}), -5, tolerance) {
+			t.Error("FAIL")
+		}
+		if !lipschitz(test.s, 10000, 1) {
+			t.Error("FAIL")
+		}
+	}
+	// the tetrahedron circumradius is 3 times the inradius
+	s := Tetrahedron3D(5, 0)
+	if !EqualFloat64(s.Evaluate(V3{-1, -1, -1}.Normalize().MulScalar(17)), 2, tolerance) {
+		t.Error("FAIL")
+	}
+	// octahedron vertices are on the axes
+	s = Octahedron3D(1, 0)
+	if !EqualFloat64(s.Evaluate(V3{0, 0, 2 * math.Sqrt(3)}), math.Sqrt(3), tolerance) {
+		t.Error("FAIL")
+	}
+	// distance to an edge
+	if !EqualFloat64(s.Evaluate(V3{3, 3, 0}), math.Sqrt(2)*3-math.Sqrt(1.5), tolerance) {
+		t.Error("FAIL")
+	}
+	// rounding keeps the faces and pulls in the vertices
+	s = Octahedron3D(1, 0.25)
+	r := math.Sqrt(3)
+	if Abs(s.Evaluate(V3{1, 1, 1}.MulScalar(1/r))) > tolerance ||
+		!EqualFloat64(s.Evaluate(V3{0, 0, r}), r-0.75*r-0.25, tolerance) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-0.75*r - 0.25, -0.75*r - 0.25, -0.75*r - 0.25}, V3{0.75*r + 0.25, 0.75*r + 0.25, 0.75*r + 0.25}}, tolerance) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_SuperQuadric3D(t *testing.T) {
+	for _, e := range []V2{{1, 1}, {0.2, 0.2}, {0.5, 1.5}, {2, 2}, {2, 0.3}} {
+		s := SuperQuadric3D(e.X, e.Y, V3{20, 12, 8})
+		if !lipschitz(s, 10000, 1) {
+			t.Error("FAIL")
+		}
+		for _, p := range []V3{{10, 0, 0}, {0, -6, 0}, {0, 0, 4}} {
+			if Abs(s.Evaluate(p)) > tolerance {
+				t.Error("FAIL")
+			}
+		}
+		if s.Evaluate(V3{}) >= 0 || s.Evaluate(V3{10, 6, 4}) <= 0 {
+			t.Error("FAIL")
+		}
+		if !s.BoundingBox().Equals(Box3{V3{-10, -6, -4}, V3{10, 6, 4}}, tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	// unit exponents with equal sizes is a sphere
+	s := SuperQuadric3D(1, 1, V3{6, 6, 6})
+	for i := 0; i < 100; i++ {
+		p := V3{randomRange(-10, 10), randomRange(-10, 10), randomRange(-10, 10)}
+		if !EqualFloat64(s.Evaluate(p), Sphere3D(3).Evaluate(p), tolerance) {
+			t.Error("FAIL")
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Metaball3D(t *testing.T) {
+	// a single point source is a sphere
+	for _, kernel := range []MetaballKernel{MetaballWyvill, MetaballQuintic, MetaballGaussian} {
+		s, err := Metaball3D([]MetaballSource{MetaPoint(V3{1, 2, 3}, 10, 1)}, kernel, 0.5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !lipschitz(s, 10000, 1) {
+			t.Error("FAIL")
+		}
+		// find the surface radius
+		f, _ := kernel.falloff()
+		r0, r1 := 0.0, 1.0
+		for i := 0; i < 60; i++ {
+			r := 0.5 * (r0 + r1)
+			if f(r) > 0.5 {
+				r0 = r
+			} else {
+				r1 = r
+			}
+		}
+		if Abs(s.Evaluate(V3{1, 2, 3 + 10*r0})) > tolerance ||
+			s.Evaluate(V3{1, 2, 3}) >= 0 || s.Evaluate(V3{1, 2, 3 + 10*r0 + 0.1}) <= 0 {
+			t.Error("FAIL")
+		}
+		if !EqualFloat64(s.Evaluate(V3{1, 2, 20}), 7, tolerance) {
+			t.Error("FAIL")
+		}
+		if !s.BoundingBox().Equals(Box3{V3{-9, -8, -7}, V3{11, 12, 13}}, tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	// two point sources blend together between them
+	a := MetaPoint(V3{-4, 0, 0}, 8, 1)
+	b := MetaPoint(V3{4, 0, 0}, 8, 1)
+	s, _ := Metaball3D([]MetaballSource{a}, MetaballWyvill, 0.5)
+	if s.Evaluate(V3{}) <= 0 {
+		t.Error("FAIL")
+	}
+	s, _ = Metaball3D([]MetaballSource{a, b}, MetaballWyvill, 0.5)
+	if s.Evaluate(V3{}) >= 0 || !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// a negative source carves into the blob
+	s, _ = Metaball3D([]MetaballSource{MetaLine(V3{-10, 0, 0}, V3{10, 0, 0}, 5, 1), MetaPoint(V3{}, 5, -1)}, MetaballWyvill, 0.5)
+	if s.Evaluate(V3{}) <= 0 || s.Evaluate(V3{8, 0, 0}) >= 0 || !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// a polyline source
+	s, _ = Metaball3D([]MetaballSource{MetaCurve([]V3{{0, 0, 0}, {10, 0, 0}, {10, 10, 0}}, 4, 1)}, MetaballQuintic, 0.5)
+	if s.Evaluate(V3{10, 5, 0}) >= 0 || s.Evaluate(V3{5, 5, 0}) <= 0 || !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// errors
+	if _, err := Metaball3D(nil, MetaballWyvill, 0.5); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := Metaball3D([]MetaballSource{MetaPoint(V3{}, 0, 1)}, MetaballWyvill, 0.5); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := Metaball3D([]MetaballSource{MetaPoint(V3{}, 5, -1)}, MetaballWyvill, 0.5); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := Metaball3D([]MetaballSource{a}, MetaballWyvill, 0); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Patch3D(t *testing.T) {
+	// a bilinear patch on the z = 0 plane
+	s, err := BezierPatch3D([][]V3{{{-10, -10, 0}, {-10, 10, 0}}, {{10, -10, 0}, {10, 10, 0}}}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !EqualFloat64(s.Evaluate(V3{3, 4, 5}), 4, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{3, 4, -5}), 4, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{15, 0, 2}), math.Sqrt(29)-1, tolerance) ||
+		!EqualFloat64(s.Evaluate(V3{1, 2, 0}), -1, tolerance) {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-11, -11, -1}, V3{11, 11, 1}}, tolerance) {
+		t.Error("FAIL")
+	}
+	// a bicubic bezier patch matches de Casteljau evaluation
+	ctrl := make([][]V3, 4)
+	for i := range ctrl {
+		ctrl[i] = make([]V3, 4)
+		for j := range ctrl[i] {
+			ctrl[i][j] = V3{float64(i) * 10, float64(j) * 10, randomRange(-5, 5)}
+		}
+	}
+	s, _ = BezierPatch3D(ctrl, 1)
+	casteljau := func(p []V3, t float64) V3 {
+		q := append([]V3{}, p...)
+		for n := len(q) - 1; n > 0; n-- {
+			for i := 0; i < n; i++ {
+				q[i] = q[i].MulScalar(1 - t).Add(q[i+1].MulScalar(t))
+			}
+		}
+		return q[0]
+	}
+	for k := 0; k < 10; k++ {
+		u, v := randomRange(0, 1), randomRange(0, 1)
+		var col []V3
+		for i := range ctrl {
+			col = append(col, casteljau(ctrl[i], v))
+		}
+		if !s.(*PatchSDF3).point(u, v).Equals(casteljau(col, u), tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// a B-spline patch is within the hull of the control points and has the
+	// closest point on the surface
+	ctrl = make([][]V3, 6)
+	for i := range ctrl {
+		ctrl[i] = make([]V3, 5)
+		for j := range ctrl[i] {
+			ctrl[i][j] = V3{float64(i) * 8, float64(j) * 8, 6 * math.Sin(float64(i+2*j))}
+		}
+	}
+	s, _ = BSplinePatch3D(ctrl, 3, 2, 1)
+	ps := s.(*PatchSDF3)
+	for k := 0; k < 20; k++ {
+		p := V3{randomRange(-10, 50), randomRange(-10, 40), randomRange(-10, 10)}
+		d := math.MaxFloat64
+		var bi, bj int
+		for i := 0; i <= 100; i++ {
+			for j := 0; j <= 100; j++ {
+				if x := ps.point(float64(i)/100, float64(j)/100).Sub(p).Length(); x < d {
+					d, bi, bj = x, i, j
+				}
+			}
+		}
+		// refine the sampling around the closest sample
+		for i := 0; i <= 100; i++ {
+			for j := 0; j <= 100; j++ {
+				u := Clamp((float64(bi)+float64(i)/50-1)/100, 0, 1)
+				v := Clamp((float64(bj)+float64(j)/50-1)/100, 0, 1)
+				d = Min(d, ps.point(u, v).Sub(p).Length())
+			}
+		}
+		// the sampled distance is a little larger than the true distance
+		if e := s.Evaluate(p) + 0.5; e > d+tolerance || e < d-0.01 {
+			t.Error("FAIL")
+		}
+	}
+	if !ps.point(0, 0).Equals(ctrl[0][0], tolerance) || !ps.point(1, 1).Equals(ctrl[5][4], tolerance) {
+		t.Error("FAIL")
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// errors
+	if _, err := BSplinePatch3D(ctrl, 6, 2, 1); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := BSplinePatch3D(ctrl, 3, 2, 0); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := BezierPatch3D([][]V3{{{0, 0, 0}}}, 1); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Heightmap3D(t *testing.T) {
+	// 2x2 pixels: black on the left, white on the right
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	img.SetGray(1, 0, color.Gray{255})
+	img.SetGray(1, 1, color.Gray{255})
+	s, err := Heightmap3D(img, V3{20, 10, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := s.(*HeightmapSDF3)
+	// pixel centers are at x = -5 and 5, heights are clamped beyond them
+	if !EqualFloat64(h.Height(V2{0, 0}), 2.5, tolerance) ||
+		!EqualFloat64(h.Height(V2{2.5, 3}), 3.75, tolerance) ||
+		!EqualFloat64(h.Height(V2{9, -4}), 5, tolerance) ||
+		h.Height(V2{-8, 2}) != 0 {
+		t.Error("FAIL")
+	}
+	if !s.BoundingBox().Equals(Box3{V3{-10, -5, 0}, V3{10, 5, 5}}, tolerance) {
+		t.Error("FAIL")
+	}
+	if s.Evaluate(V3{0, 0, 2}) >= 0 || s.Evaluate(V3{0, 0, 3}) <= 0 ||
+		!EqualFloat64(s.Evaluate(V3{8, 0, 7}), 2, tolerance) {
+		t.Error("FAIL")
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// a rendered height map converts back to the same shape
+	s0 := Sphere3D(10)
+	img16 := HeightMap(s0, 64)
+	s, _ = Heightmap3D(img16, V3{20, 20, 20})
+	for i := 0; i < 100; i++ {
+		p := V2{randomRange(-6, 6), randomRange(-6, 6)}
+		z := math.Sqrt(100-p.Length2()) + 10
+		if Abs(s.(*HeightmapSDF3).Height(p)-z) > 0.5 {
+			t.Error("FAIL")
+		}
+	}
+	if !lipschitz(s, 10000, 1) {
+		t.Error("FAIL")
+	}
+	if _, err := Heightmap3D(image.NewGray(image.Rect(0, 0, 1, 5)), V3{1, 1, 1}); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Optimize3D(t *testing.T) {
+	// many translated copies become a BVH union of transforms
+	k := &StandoffParms{
+		PillarHeight:   10,
+		PillarDiameter: 6,
+		HoleDepth:      8,
+		HoleDiameter:   2.4,
+		NumberWebs:     4,
+		WebHeight:      6,
+		WebDiameter:    12,
+		WebWidth:       2,
+	}
+	var pos V3Set
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 4; j++ {
+			pos = append(pos, V3{float64(i) * 20, float64(j) * 20, 0})
+		}
+	}
+	s0 := Standoffs3D(k, pos)
+	s1 := Optimize3D(s0)
+	u, ok := s1.(*UnionBVHSDF3)
+	if !ok || len(u.sdf) != len(pos) {
+		t.Fatal("FAIL")
+	}
+	bb := s0.BoundingBox()
+	if !s1.BoundingBox().Equals(bb, tolerance) {
+		t.Error("FAIL")
+	}
+	for i := 0; i < 10000; i++ {
+		p := bb.Random()
+		a, b := s0.Evaluate(p), s1.Evaluate(p)
+		// never less than the union, the same near the surface
+		if b < a-tolerance || (a < 0) != (b < 0) || (Abs(a) < 1 && Abs(a-b) > tolerance) {
+			t.Error("FAIL")
+			break
+		}
+	}
+	if !lipschitz(s1, 10000, 1) {
+		t.Error("FAIL")
+	}
+	// nested unions are flattened
+	a, b, c := Sphere3D(1), Box3D(V3{1, 2, 3}, 0), Cylinder3D(2, 1, 0)
+	s := Optimize3D(Union3D(Union3D(a, b), Union3D(c, nil)))
+	if u, ok := s.(*UnionSDF3); !ok || len(u.sdf) != 3 {
+		t.Error("FAIL")
+	}
+	// blended unions are kept
+	s = SmoothUnion3D(BlendPoly, 1, Union3D(a, b), c)
+	if u, ok := Optimize3D(s).(*UnionSDF3); !ok || len(u.sdf) != 2 {
+		t.Error("FAIL")
+	}
+	// consecutive transforms are merged
+	m0 := Translate3d(V3{1, 2, 3}).Mul(RotateX(DtoR(30)))
+	m1 := RotateZ(DtoR(45)).Mul(Scale3d(V3{2, 2, 2}))
+	s0 = Transform3D(ScaleUniform3D(Transform3D(b, m0), 0.5), m1)
+	s1 = Optimize3D(s0)
+	if x, ok := s1.(*TransformSDF3); !ok || x.sdf != b {
+		t.Error("FAIL")
+	}
+	for i := 0; i < 100; i++ {
+		p := V3{randomRange(-5, 5), randomRange(-5, 5), randomRange(-5, 5)}
+		if !EqualFloat64(s0.Evaluate(p), s1.Evaluate(p), tolerance) {
+			t.Error("FAIL")
+			break
+		}
+	}
+	if Optimize3D(Transform3D(Transform3D(a, m0), m0.Inverse())) != a {
+		t.Error("FAIL")
+	}
+	// cutters that don't touch the object are dropped
+	far := Transform3D(b, Translate3d(V3{10, 0, 0}))
+	if Optimize3D(Difference3D(a, far)) != a {
+		t.Error("FAIL")
+	}
+	if _, ok := Optimize3D(Difference3D(a, b)).(*DifferenceSDF3); !ok {
+		t.Error("FAIL")
+	}
+	// disjoint intersections are empty
+	if Optimize3D(Intersect3D(a, far)) != nil {
+		t.Error("FAIL")
+	}
+	if Optimize3D(nil) != nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_OffsetJoin2D(t *testing.T) {
+	// kerf compensation keeps a box square
+	s, err := OffsetJoin2D(Box2D(V2{10, 6}, 0), 0.5, JoinMiter, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box := Box2D(V2{11, 7}, 0)
+	for i := 0; i < 1000; i++ {
+		p := V2{randomRange(-10, 10), randomRange(-10, 10)}
+		if (s.Evaluate(p) < 0) != (box.Evaluate(p) < 0) || Abs(s.Evaluate(p)-box.Evaluate(p)) > 0.6 {
+			t.Error("FAIL")
+			break
+		}
+	}
+	if Abs(s.Evaluate(V2{5.5, 3.5})) > tolerance {
+		t.Error("FAIL")
+	}
+	// an L shape, counter-clockwise and clockwise
+	v := []V2{{0, 0}, {10, 0}, {10, 5}, {5, 5}, {5, 10}, {0, 10}}
+	r := []V2{{0, 10}, {5, 10}, {5, 5}, {10, 5}, {10, 0}, {0, 0}}
+	for _, x := range [][]V2{v, r} {
+		m := OffsetPolygon(x, 1, JoinMiter, 2)
+		b := OffsetPolygon(x, 1, JoinBevel, 2)
+		if len(m) != 6 || len(b) != 11 {
+			t.Error("FAIL")
+		}
+		in := OffsetPolygon(x, -1, JoinMiter, 2)
+		if len(in) != 6 || !Polygon2D(in).BoundingBox().Equals(Box2{V2{1, 1}, V2{9, 9}}, tolerance) {
+			t.Error("FAIL")
+		}
+		if !Polygon2D(m).BoundingBox().Equals(Box2{V2{-1, -1}, V2{11, 11}}, tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	// the round join vertices are on the offset arcs
+	s0 := Offset2D(Polygon2D(v), 1)
+	for _, p := range OffsetPolygon(v, 1, JoinRound, 0) {
+		if Abs(s0.Evaluate(p)) > tolerance {
+			t.Error("FAIL")
+		}
+	}
+	// a sharp corner is bevelled beyond the miter limit
+	tri := []V2{{0, 0}, {10, 0}, {0, 1}}
+	if len(OffsetPolygon(tri, 0.5, JoinMiter, 2)) != 4 || len(OffsetPolygon(tri, 0.5, JoinMiter, 100)) != 3 {
+		t.Error("FAIL")
+	}
+	// round joins are a plain offset
+	if s, _ := OffsetJoin2D(Circle2D(3), 1, JoinRound, 0); s == nil {
+		t.Error("FAIL")
+	} else if _, ok := s.(*OffsetSDF2); !ok {
+		t.Error("FAIL")
+	}
+	if _, err := OffsetJoin2D(Circle2D(3), 1, JoinMiter, 2); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := OffsetJoin2D(Box2D(V2{3, 3}, 1), 1, JoinBevel, 2); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_PolygonSelect(t *testing.T) {
+	l := []V2{{0, 0}, {10, 0}, {10, 5}, {5, 5}, {5, 10}, {0, 10}}
+	for _, v := range [][]V2{l, {l[5], l[4], l[3], l[2], l[1], l[0]}} {
+		p := NewPolygon()
+		p.AddV2Set(v)
+		p.Close()
+		if len(p.Select(ConvexVertices)) != 5 || len(p.Select(ConcaveVertices)) != 1 {
+			t.Error("FAIL")
+		}
+		// fillet the convex corners and chamfer the concave corner
+		p.Select(ConvexVertices).Smooth(1, 4)
+		p.Select(ConcaveVertices).Chamfer(1)
+		vs := p.Vertices()
+		if len(vs) != 5*5+2 {
+			t.Error("FAIL")
+		}
+		s := Polygon2D(vs)
+		if !s.BoundingBox().Equals(Box2{V2{0, 0}, V2{10, 10}}, tolerance) ||
+			!EqualFloat64(s.Evaluate(V2{0, 0}), math.Sqrt2-1, tolerance) {
+			t.Error("FAIL")
+		}
+	}
+	// the ends of an open polygon are not corners
+	p := NewPolygon()
+	p.AddV2Set(l)
+	if len(p.Select(ConvexVertices)) != 3 || len(p.Select(ConcaveVertices)) != 1 {
+		t.Error("FAIL")
+	}
+	// index ranges
+	p = NewPolygon()
+	p.AddV2Set(l)
+	p.Close()
+	p.Range(1, 3).Chamfer(1)
+	p.Vertex(-1).Smooth(2, 3)
+	if len(p.Range(-5, 100)) != 6 || p.Len() != 6 || len(p.Vertices()) != 6+2+3 {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func clipArea(contours [][]V2) float64 {
+	a := 0.0
+	for _, c := range contours {
+		for i := range c {
+			a += c[i].Cross(c[(i+1)%len(c)])
+		}
+	}
+	return 0.5 * a
+}
+
+func Test_ClipPolygons(t *testing.T) {
+	square := func(x, y, s float64) []V2 {
+		return []V2{{x, y}, {x + s, y}, {x + s, y + s}, {x, y + s}}
+	}
+	a := [][]V2{square(0, 0, 2)}
+	b := [][]V2{square(1, 1, 2)}
+	test := []struct {
+		a, b [][]V2
+		op   ClipOp
+		area float64
+		n    int // number of contours
+	}{
+		// overlapping squares
+		{a, b, ClipUnion, 7, 1},
+		{a, b, ClipIntersection, 1, 1},
+		{a, b, ClipDifference, 3, 1},
+		{a, b, ClipXor, 6, 2},
+		// a square with a hole
+		{[][]V2{square(0, 0, 4)}, [][]V2{square(1, 1, 2)}, ClipDifference, 12, 2},
+		{[][]V2{square(0, 0, 4), square(1, 1, 2)}, [][]V2{square(0, 0, 2)}, ClipUnion, 13, 2},
+		// shared edge
+		{a, [][]V2{square(2, 0, 2)}, ClipUnion, 8, 1},
+		{a, [][]V2{square(2, 0, 2)}, ClipIntersection, 0, 0},
+		// touching corners
+		{a, [][]V2{square(2, 2, 2)}, ClipUnion, 8, 2},
+		// disjoint
+		{a, [][]V2{square(5, 5, 1)}, ClipDifference, 4, 1},
+	}
+	for i, v := range test {
+		c := ClipPolygons(v.a, v.b, v.op)
+		if Abs(clipArea(c)-v.area) > tolerance || len(c) != v.n {
+			t.Errorf("FAIL test %d: area %f contours %d", i, clipArea(c), len(c))
+		}
+		// outer contours are ccw, holes are cw
+		for _, x := range c {
+			inside := clipInside(c, x[0].Add(x[1]).MulScalar(0.5).Add(V2{x[0].Y - x[1].Y, x[1].X - x[0].X}.Normalize().MulScalar(1e-6)))
+			if !inside {
+				t.Errorf("FAIL test %d: orientation", i)
+			}
+		}
+	}
+	// crossing edges
+	c := PolygonIntersection([][]V2{{{0, 0}, {2, 0}, {1, 2}}}, [][]V2{{{0, 1}, {2, 1}, {1, -1}}})
+	if len(c) != 1 || len(c[0]) != 6 {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_DecodeDXF(t *testing.T) {
+	dxf := ""
+	group := func(code int, value interface{}) {
+		dxf += fmt.Sprintf("%d\n%v\n", code, value)
+	}
+	group(0, "SECTION")
+	group(2, "ENTITIES")
+	// square with a semicircular right side and a round hole
+	group(0, "LWPOLYLINE")
+	group(90, 4)
+	group(70, 1)
+	for _, v := range [][3]float64{{0, 0, 0}, {10, 0, 1}, {10, 10, 0}, {0, 10, 0}} {
+		group(10, v[0])
+		group(20, v[1])
+		if v[2] != 0 {
+			group(42, v[2])
+		}
+	}
+	group(0, "CIRCLE")
+	group(10, 5)
+	group(20, 5)
+	group(40, 2)
+	// half disk from a line and an arc
+	group(0, "ARC")
+	group(10, 25)
+	group(20, 0)
+	group(40, 5)
+	group(50, 0)
+	group(51, 180)
+	group(0, "LINE")
+	group(10, 30)
+	group(20, 0)
+	group(11, 20)
+	group(21, 0)
+	// degree 1 spline square
+	group(0, "SPLINE")
+	group(70, 1)
+	group(71, 1)
+	for _, k := range []float64{0, 0, 1, 2, 3, 4, 4} {
+		group(40, k)
+	}
+	for _, v := range []V2{{40, 0}, {44, 0}, {44, 4}, {40, 4}, {40, 0}} {
+		group(10, v.X)
+		group(20, v.Y)
+	}
+	// ignored
+	group(0, "TEXT")
+	group(1, "hello")
+	group(0, "ENDSEC")
+	group(0, "EOF")
+
+	contours, err := DecodeDXF(bytes.NewBufferString(dxf), 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contours) != 4 {
+		t.Errorf("FAIL %d contours", len(contours))
+	}
+	s := Contours2D(contours)
+	test := []struct {
+		p V2
+		d float64
+	}{
+		{V2{1, 5}, -1},
+		{V2{14, 5}, -1},
+		{V2{5, 5}, 2},
+		{V2{25, 1}, -1},
+		{V2{25, 6}, 1},
+		{V2{42, 3}, -1},
+		{V2{46, 2}, 2},
+	}
+	for _, v := range test {
+		if d := s.Evaluate(v.p); Abs(d-v.d) > 0.01 {
+			t.Errorf("FAIL %v: %f expected %f", v.p, d, v.d)
+		}
+	}
+
+	// open contours are an error
+	_, err = DecodeDXF(bytes.NewBufferString("0\nSECTION\n2\nENTITIES\n0\nLINE\n10\n0\n20\n0\n11\n1\n21\n0\n0\nENDSEC\n"), 0.001)
+	if err == nil {
+		t.Error("FAIL")
+	}
+
+	// round trip through the DXF writer
+	path := filepath.Join(os.TempDir(), "sdfx_polyline.dxf")
+	defer os.Remove(path)
+	d := NewDXF(path)
+	if err := d.Polyline(Nagon(6, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Save(); err != nil {
+		t.Fatal(err)
+	}
+	contours, err = LoadDXF(path, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contours) != 1 || len(contours[0]) != 6 || Abs(clipArea(contours)-Abs(clipArea([][]V2{Nagon(6, 3)}))) > tolerance {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_NURBS(t *testing.T) {
+	// the nurbs circle is exact
+	c := NURBSCircle(V2{1, 2}, 3)
+	for i := 0; i <= 100; i++ {
+		t0, t1 := c.Domain()
+		p := c.Point(t0 + (t1-t0)*float64(i)/100)
+		if Abs(p.Sub(V2{1, 2}).Length()-3) > tolerance {
+			t.Error("FAIL")
+		}
+	}
+	// the tessellated length converges with the tolerance
+	l0 := c.SetTolerance(1e-2).Length()
+	n0 := len(c.Vertices())
+	l1 := c.SetTolerance(1e-6).Length()
+	n1 := len(c.Vertices())
+	if n1 <= n0 || Abs(l1-Tau*3) > 1e-3 || Abs(l1-Tau*3) > Abs(l0-Tau*3) {
+		t.Errorf("FAIL %f %f", l0, l1)
+	}
+	// a closed circle polygon
+	s := Polygon2D(c.Polygon().Vertices())
+	if Abs(s.Evaluate(V2{1, 2})+3) > 1e-3 {
+		t.Error("FAIL")
+	}
+
+	// clamped b-splines go through the end points
+	ctrl := []V2{{0, 0}, {1, 2}, {3, 2}, {4, 0}, {6, 1}}
+	b, err := NewBSpline(3, ctrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := b.Vertices()
+	if !v[0].Equals(ctrl[0], tolerance) || !v[len(v)-1].Equals(ctrl[4], tolerance) {
+		t.Error("FAIL")
+	}
+	// moving a control point only changes the curve locally
+	ctrl2 := append([]V2{}, ctrl...)
+	ctrl2[4] = V2{6, 5}
+	b2, _ := NewBSpline(3, ctrl2)
+	if !b.Point(0.5).Equals(b2.Point(0.5), tolerance) || b.Point(1.5).Equals(b2.Point(1.5), tolerance) {
+		t.Error("FAIL")
+	}
+	// closed b-splines are closed
+	b, err = NewClosedBSpline(3, []V2{{0, 0}, {2, 0}, {2, 2}, {0, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0, t1 := b.Domain()
+	if !b.Point(t0).Equals(b.Point(t1), tolerance) || len(b.Polygon().Vertices()) < 8 {
+		t.Error("FAIL")
+	}
+	// bad curves
+	if _, err := NewNURBS(2, ctrl, nil, []float64{0, 1}); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := NewBSpline(3, ctrl[:3]); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_StarLobed2D(t *testing.T) {
+	star, err := Star2D(&StarParms{Points: 5, OuterRadius: 10, InnerRadius: 4, TipRadius: 1, RootRadius: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the rounded tip is inside the sharp tip
+	if star.Evaluate(V2{10, 0}) <= 0 || star.Evaluate(V2{7.5, 0}) >= 0 {
+		t.Error("FAIL")
+	}
+	if _, err := Star2D(&StarParms{Points: 5, OuterRadius: 10, InnerRadius: 4, TipRadius: 5, RootRadius: 5}); err == nil {
+		t.Error("FAIL")
+	}
+
+	lobed, err := Lobed2D(5, 10, 6, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the tips and roots are on the surface
+	for i := 0; i < 5; i++ {
+		theta := Tau * float64(i) / 5
+		if Abs(lobed.Evaluate(PolarToXY(10, theta))) > tolerance ||
+			Abs(lobed.Evaluate(PolarToXY(6, theta+Pi/5))) > tolerance {
+			t.Error("FAIL")
+		}
+	}
+	if lobed.Evaluate(V2{0, 0}) >= 0 || lobed.Evaluate(V2{11, 0}) <= 0 {
+		t.Error("FAIL")
+	}
+	if _, err := Lobed2D(6, 10, 6, 9); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := Torx2D(3.86, 2.75); err != nil {
+		t.Error("FAIL")
+	}
+
+	// cam lobes with separate tip and root rounding, and sharp tips and roots
+	for _, k := range []CamLobeParms{
+		{Lobes: 5, OuterRadius: 10, InnerRadius: 6, TipRadius: 2, RootRadius: 1},
+		{Lobes: 3, OuterRadius: 10, InnerRadius: 4, TipRadius: 3, RootRadius: 0},
+		{Lobes: 4, OuterRadius: 10, InnerRadius: 6, TipRadius: 0, RootRadius: 2},
+	} {
+		cam, err := CamLobe2D(&k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		theta := Pi / float64(k.Lobes)
+		for i := 0; i < k.Lobes; i++ {
+			if Abs(cam.Evaluate(PolarToXY(k.OuterRadius, 2*theta*float64(i)))) > tolerance ||
+				Abs(cam.Evaluate(PolarToXY(k.InnerRadius, 2*theta*float64(i)+theta))) > tolerance {
+				t.Errorf("FAIL %v", k)
+			}
+		}
+		// the tip and root are the extremes of the profile
+		for i := 0; i < 100; i++ {
+			a := Tau * float64(i) / 100
+			if cam.Evaluate(PolarToXY(k.OuterRadius+0.01, a)) <= 0 || cam.Evaluate(PolarToXY(k.InnerRadius-0.01, a)) >= 0 {
+				t.Errorf("FAIL %v", k)
+				break
+			}
+		}
+	}
+	// with the root radius of Lobed2D the profiles are the same
+	a, b, c := 8.0, 6.0, math.Cos(Pi/5)
+	rr := (4 - a*a - b*b + 2*a*b*c) / (2 * (b - a*c - 2))
+	cam, err := CamLobe2D(&CamLobeParms{Lobes: 5, OuterRadius: 10, InnerRadius: 6, TipRadius: 2, RootRadius: rr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		p := V2{randomRange(-11, 11), randomRange(-11, 11)}
+		if d := lobed.Evaluate(p); Abs(d) > 1e-3 && (d < 0) != (cam.Evaluate(p) < 0) {
+			t.Errorf("FAIL %v", p)
+			break
+		}
+	}
+	if _, err := CamLobe2D(&CamLobeParms{Lobes: 5, OuterRadius: 10, InnerRadius: 6, TipRadius: 2, RootRadius: 4}); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := CamLobe2D(&CamLobeParms{Lobes: 8, OuterRadius: 10, InnerRadius: 9, TipRadius: 4, RootRadius: 0}); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_InvoluteGear2D(t *testing.T) {
+	m := 2.0
+	z := 20
+	pa := DtoR(20)
+	for _, x := range []float64{-0.2, 0, 0.4} {
+		s, err := InvoluteGear2D(m, z, pa, x, 0, 0.4*m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rp := m * float64(z) / 2
+		ra := rp + m*(1+x)
+		rf := rp - m*(1.25-x)
+		// tooth thickness at the pitch circle
+		thickness := m * (Pi/2 + 2*x*math.Tan(pa))
+		if Abs(s.Evaluate(PolarToXY(rp, thickness/(2*rp)))) > 1e-3 ||
+			Abs(s.Evaluate(PolarToXY(rp, Tau/float64(z)-thickness/(2*rp)))) > 1e-3 {
+			t.Errorf("FAIL tooth thickness (shift %f)", x)
+		}
+		// tip and root circles
+		if Abs(s.Evaluate(V2{ra, 0})) > 1e-6 || Abs(s.Evaluate(PolarToXY(rf, Pi/float64(z)))) > 1e-6 {
+			t.Errorf("FAIL tip/root (shift %f)", x)
+		}
+		if s.Evaluate(V2{0, 0}) >= 0 || s.Evaluate(V2{ra + 1, 0}) <= 0 {
+			t.Error("FAIL")
+		}
+		// distance is 1-lipschitz
+		bb := s.BoundingBox().ScaleAboutCenter(1.2)
+		for i := 0; i < 1000; i++ {
+			a, b := bb.Random(), bb.Random()
+			if Abs(s.Evaluate(a)-s.Evaluate(b)) > a.Sub(b).Length()+tolerance {
+				t.Fatal("FAIL lipschitz")
+			}
+		}
+	}
+	// backlash thins the tooth
+	s, _ := InvoluteGear2D(m, z, pa, 0, 0.2, 0)
+	rp := m * float64(z) / 2
+	if Abs(s.Evaluate(PolarToXY(rp, (m*Pi/2-0.2)/(2*rp)))) > 1e-3 {
+		t.Error("FAIL backlash")
+	}
+	// bad parameters
+	if _, err := InvoluteGear2D(m, 2, pa, 0, 0, 0); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := InvoluteGear2D(m, z, pa, 0, 0, 5*m); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Cycloidal2D(t *testing.T) {
+	k := &CycloidalParms{Lobes: 9, PinCircleRadius: 20, PinRadius: 2, Eccentricity: 1}
+	disk, err := CycloidalDisk2D(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := CycloidalPinRing2D(k, 26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// with the disk offset by the eccentricity every pin touches the disk
+	for _, theta := range []float64{0, 0.1, 0.3} {
+		// the disk turns by -theta/lobes as the eccentric turns by theta
+		m := Translate2d(PolarToXY(k.Eccentricity, theta)).Mul(Rotate2d(-theta / float64(k.Lobes)))
+		s := Transform2D(disk, m)
+		for i := 0; i <= k.Lobes; i++ {
+			pin := PolarToXY(k.PinCircleRadius, Tau*float64(i)/float64(k.Lobes+1))
+			if Abs(s.Evaluate(pin)-k.PinRadius) > 0.01 {
+				t.Errorf("FAIL pin %d theta %f: %f", i, theta, s.Evaluate(pin)-k.PinRadius)
+			}
+		}
+	}
+	if ring.Evaluate(V2{23, 0}) >= 0 || ring.Evaluate(V2{19, 0}) >= 0 || ring.Evaluate(PolarToXY(19, Pi/10)) <= 0 {
+		t.Error("FAIL")
+	}
+	// bad parameters
+	if _, err := CycloidalDisk2D(&CycloidalParms{Lobes: 9, PinCircleRadius: 20, PinRadius: 2, Eccentricity: 3}); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := CycloidalDisk2D(&CycloidalParms{Lobes: 9, PinCircleRadius: 20, PinRadius: 8, Eccentricity: 1}); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Sprocket2D(t *testing.T) {
+	for _, chain := range []string{"25", "#35", "40", "420"} {
+		for _, z := range []int{9, 15, 40} {
+			s, err := Sprocket2D(chain, z)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := rollerChains[strings.TrimPrefix(chain, "#")]
+			gap := Pi / float64(z)
+			rp := c.pitch / (2 * math.Sin(gap))
+			ra := 0.5 * c.pitch * (0.6 + 1/math.Tan(gap))
+			// the rollers sit in the tooth gaps
+			for i := 0; i < z; i++ {
+				roller := PolarToXY(rp, float64(2*i+1)*gap)
+				if d := s.Evaluate(roller); d < 0.5*c.roller || d > 0.5*c.roller+0.2 {
+					t.Fatalf("FAIL %s %d: roller %f", chain, z, d)
+				}
+			}
+			if d := s.Evaluate(V2{ra, 0}); d > 0 || d < -0.2*c.pitch {
+				t.Errorf("FAIL %s %d: tip %f", chain, z, d)
+			}
+			if s.Evaluate(V2{ra + 0.1, 0}) <= 0 || s.Evaluate(V2{0, 0}) >= 0 {
+				t.Error("FAIL")
+			}
+		}
+	}
+	if _, err := Sprocket2D("99", 20); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_TimingPulley2D(t *testing.T) {
+	for belt, k := range timingBelts {
+		for _, z := range []int{12, 20, 60} {
+			s, err := TimingPulley2D(belt, z)
+			if err != nil {
+				t.Fatalf("FAIL %s %d: %s", belt, z, err)
+			}
+			gap := Pi / float64(z)
+			ro := k.pitch*float64(z)/Tau - k.pld
+			for i := 0; i < z; i++ {
+				// the land between the grooves is on the outside radius
+				if d := s.Evaluate(PolarToXY(ro, float64(2*i)*gap)); Abs(d) > 1e-3 {
+					t.Fatalf("FAIL %s %d: land %f", belt, z, d)
+				}
+				// the groove bottom is at the groove depth
+				if d := s.Evaluate(PolarToXY(ro-k.depth, float64(2*i+1)*gap)); Abs(d) > 1e-3 {
+					t.Fatalf("FAIL %s %d: groove %f", belt, z, d)
+				}
+			}
+			if s.Evaluate(V2{}) >= 0 || s.Evaluate(V2{ro + 0.1, 0}) <= 0 {
+				t.Error("FAIL")
+			}
+		}
+	}
+	if _, err := TimingPulley2D("XL", 20); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := TimingPulley2D("GT2", 4); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Slot2D(t *testing.T) {
+	test := []struct {
+		s SDF2
+		p V2
+		d float64
+	}{
+		{Slot2D(10, 2), V2{0, 0}, -2},
+		{Slot2D(10, 2), V2{0, 3}, 1},
+		{Slot2D(10, 2), V2{6, 0}, 1},
+		{Slot2D(10, 2), V2{5, 2}, math.Sqrt(8) - 2},
+		{SlotCaps2D(10, 2, SlotRound, SlotFlat), V2{5, 2}, 0},
+		{SlotCaps2D(10, 2, SlotRound, SlotFlat), V2{-5, 2}, math.Sqrt(8) - 2},
+		{SlotCaps2D(10, 2, SlotFlat, SlotRound), V2{-6, 3}, math.Sqrt(2)},
+		{Obround2D(V2{4, 10}), V2{0, 5}, 0},
+		{Obround2D(V2{4, 10}), V2{2, 2}, 0},
+		{ArcSlot2D(10, 2, Pi), V2{10, 0}, -1},
+		{ArcSlot2D(10, 2, Pi), V2{0, 12}, 1},
+		{ArcSlot2D(10, 2, Pi), V2{-10, 0}, math.Sqrt(200) - 1},
+	}
+	for i, x := range test {
+		if d := x.s.Evaluate(x.p); Abs(d-x.d) > tolerance {
+			t.Errorf("FAIL %d: %f != %f", i, d, x.d)
+		}
+	}
+	bb := ArcSlot2D(10, 2, Pi).BoundingBox()
+	if !bb.Min.Equals(V2{-1, -11}, tolerance) || !bb.Max.Equals(V2{11, 11}, tolerance) {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_CornerPolygon2D(t *testing.T) {
+	square := []V2{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	corner := []PolygonCorner{{Round: 2}, {Chamfer: 2}, {}, {Round: 5}}
+	s, err := CornerPolygon2D(square, corner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := []struct {
+		p V2
+		d float64
+	}{
+		{V2{0, 0}, math.Sqrt(8) - 2},
+		{V2{10, 0}, math.Sqrt(2)},
+		{V2{10, 10}, 0},
+		{V2{0, 10}, math.Sqrt(50) - 5},
+		{V2{5, 5}, -5},
+	}
+	for i, x := range test {
+		if d := s.Evaluate(x.p); Abs(d-x.d) > 2e-3 {
+			t.Errorf("FAIL %d: %f != %f", i, d, x.d)
+		}
+	}
+	// a rounded triangle: the fillet is tangent to both edges
+	s, err = CornerNagon2D(3, 10, []PolygonCorner{{Round: 2}, {Round: 2}, {Round: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fillet center is 2 / sin(30) = 4 from the corner
+	if d := s.Evaluate(V2{10, 0}); Abs(d-2) > 1e-3 {
+		t.Errorf("FAIL %f", d)
+	}
+	// corners that don't fit on an edge
+	if _, err := CornerPolygon2D(square, []PolygonCorner{{Chamfer: 6}, {Chamfer: 6}, {}, {}}); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := CornerPolygon2D(square, []PolygonCorner{{Chamfer: 1, Round: 1}, {}, {}, {}}); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := CornerPolygon2D(square, corner[:3]); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Repeat2D(t *testing.T) {
+	hole := Transform2D(Slot2D(4, 1), Translate2d(V2{1, 2}))
+	num := V2i{7, 5}
+	step := V2{5, 3}
+	s0 := Array2D(hole, num, step)
+	s1 := Repeat2D(hole, num, step)
+	if s0.BoundingBox() != s1.BoundingBox() {
+		t.Error("FAIL")
+	}
+	bb := s0.BoundingBox().ScaleAboutCenter(1.5)
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		if d0, d1 := s0.Evaluate(p), s1.Evaluate(p); Abs(d0-d1) > tolerance {
+			t.Fatalf("FAIL %v: %f != %f", p, d0, d1)
+		}
+	}
+	for _, n := range []int{1, 2, 3, 7, 12} {
+		spoke := Transform2D(Box2D(V2{6, 1}, 0), Translate2d(V2{4, 0.5}))
+		s0 := RotateUnion2D(spoke, n, Rotate2d(Tau/float64(n)))
+		s1 := PolarRepeat2D(spoke, n)
+		bb := s1.BoundingBox().ScaleAboutCenter(1.5)
+		for i := 0; i < 1000; i++ {
+			p := bb.Random()
+			if d0, d1 := s0.Evaluate(p), s1.Evaluate(p); Abs(d0-d1) > tolerance {
+				t.Fatalf("FAIL %d %v: %f != %f", n, p, d0, d1)
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Morph2D(t *testing.T) {
+	s0 := Circle2D(1)
+	s1 := Circle2D(3)
+	// morphing between circles gives a circle
+	s := Morph2D(s0, s1, 0.25)
+	if !EqualFloat64(s.Evaluate(V2{1.5, 0}), 0, tolerance) {
+		t.Error("FAIL")
+	}
+	if s.BoundingBox() != s1.BoundingBox() {
+		t.Error("FAIL")
+	}
+	keys := []MorphKey2{{0, s0}, {1, s1}, {3, Circle2D(2)}}
+	for _, tt := range []struct {
+		t, r float64
+	}{
+		{-1, 1}, {0, 1}, {0.5, 2}, {1, 3}, {2, 2.5}, {3, 2}, {4, 2},
+	} {
+		s, err := Keyframe2D(keys, tt.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !EqualFloat64(s.Evaluate(V2{0, tt.r}), 0, tolerance) {
+			t.Errorf("t %f: expected radius %f", tt.t, tt.r)
+		}
+	}
+	if _, err := Keyframe2D([]MorphKey2{{1, s0}, {0, s1}}, 0.5); err == nil {
+		t.Error("FAIL")
+	}
+	// the loft through the keyframes has the same sections
+	loft, err := KeyframeLoft3D(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		z, r float64
+	}{
+		{0, 1}, {0.5, 2}, {1, 3}, {2, 2.5}, {3, 2},
+	} {
+		if !EqualFloat64(loft.Evaluate(V3{0, tt.r, tt.z}), 0, tolerance) {
+			t.Errorf("z %f: expected radius %f", tt.z, tt.r)
+		}
+	}
+	if !EqualFloat64(loft.Evaluate(V3{0, 0, 4}), 1, tolerance) {
+		t.Error("FAIL")
+	}
+	// the distance allows for the change of the sections with z
+	if !lipschitz(loft, 10000, 1) {
+		t.Error("FAIL")
+	}
+	loft, _ = KeyframeLoft3D([]MorphKey2{{0, Box2D(V2{20, 10}, 1)}, {5, Circle2D(4)}, {8, Box2D(V2{6, 12}, 0)}})
+	if !lipschitz(loft, 10000, 1) {
+		t.Error("FAIL")
+	}
+	if _, err := KeyframeLoft3D(keys[:1]); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_PolygonTrueArcs(t *testing.T) {
+	tol := 0.01
+	for i := 0; i < 3; i++ {
+		// half disk, radius 10
+		p := NewPolygon().SetTolerance(tol)
+		p.Add(10, 0)
+		switch i {
+		case 0:
+			p.Add(-10, 0).ArcCenter(V2{0, 0}, true)
+		case 1:
+			p.Add(-10, 0).ArcThrough(V2{6, 8})
+		case 2:
+			p.Add(-10, 0).ArcRadius(-10)
+		}
+		v := p.Vertices()
+		for j, x := range v {
+			if Abs(x.Length()-10) > tolerance {
+				t.Fatalf("FAIL %d: vertex %d not on the arc", i, j)
+			}
+			if x.Y < 0 {
+				t.Fatalf("FAIL %d: arc on the wrong side", i)
+			}
+			// the chord error is within tolerance
+			if j > 0 {
+				mid := x.Add(v[j-1]).MulScalar(0.5)
+				if 10-mid.Length() > tol {
+					t.Fatalf("FAIL %d: chord error %f", i, 10-mid.Length())
+				}
+			}
+		}
+		// the minimum number of facets for the tolerance
+		n := int(math.Ceil(Pi / (2 * math.Acos(1-tol/10))))
+		if len(v) != n+1 {
+			t.Errorf("FAIL %d: %d vertices", i, len(v))
+		}
+	}
+	// clockwise arc with a center
+	p := NewPolygon()
+	p.Add(0, 10)
+	p.Add(10, 0).ArcCenter(V2{0, 0}, false)
+	for _, x := range p.Vertices() {
+		if x.X < -tolerance || x.Y < -tolerance {
+			t.Fatal("FAIL")
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Stroke2D(t *testing.T) {
+	// an L shaped path
+	path := []V2{{0, 0}, {10, 0}, {10, 0}, {10, 10}}
+	test := []struct {
+		cap  StrokeCap
+		join OffsetJoin
+		p    V2
+		d    float64
+	}{
+		{CapRound, JoinRound, V2{5, 0}, -1},
+		{CapRound, JoinRound, V2{-2, 0}, 1},
+		{CapRound, JoinRound, V2{12, -2}, math.Sqrt(8) - 1},
+		{CapRound, JoinRound, V2{10, 13}, 2},
+		{CapButt, JoinMiter, V2{-2, 0}, 2},
+		{CapButt, JoinMiter, V2{11, -1}, 0},
+		{CapButt, JoinMiter, V2{10, 12}, 2},
+		{CapSquare, JoinBevel, V2{-2, 0}, 1},
+		{CapSquare, JoinBevel, V2{10, 12}, 1},
+		{CapSquare, JoinBevel, V2{11, -1}, 0.5 * math.Sqrt2},
+		{CapButt, JoinRound, V2{12, -2}, math.Sqrt(8) - 1},
+	}
+	for i, x := range test {
+		s, err := Stroke2D(path, 2, x.cap, x.join)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := s.Evaluate(x.p); Abs(d-x.d) > 1e-2 {
+			t.Errorf("FAIL %d: %f != %f", i, d, x.d)
+		}
+		if s.Evaluate(V2{5, 0}) >= 0 || s.Evaluate(V2{10, 5}) >= 0 || s.Evaluate(V2{5, 5}) <= 0 {
+			t.Errorf("FAIL %d", i)
+		}
+	}
+	if _, err := Stroke2D([]V2{{1, 1}, {1, 1}}, 2, CapRound, JoinRound); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := Stroke2D(path, 0, CapRound, JoinRound); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_HatchFill(t *testing.T) {
+	length := func(lines []*Line) float64 {
+		l := 0.0
+		for _, x := range lines {
+			l += x[1].Sub(x[0]).Length()
+		}
+		return l
+	}
+	box := Box2D(V2{10, 10}, 0)
+	lines := HatchFill(box, 1, 0)
+	if len(lines) != 10 || Abs(length(lines)-100) > 1e-6 {
+		t.Errorf("FAIL %d %f", len(lines), length(lines))
+	}
+	// zig-zag
+	if lines[0][1].X < lines[0][0].X || lines[1][1].X > lines[1][0].X {
+		t.Error("FAIL")
+	}
+	// a box with a hole, hatched at 45 degrees
+	s := Difference2D(box, Circle2D(3))
+	lines = CrossHatchFill(s, 0.5, DtoR(45))
+	for _, x := range lines {
+		for _, p := range []V2{x[0], x[1], x[0].Add(x[1]).MulScalar(0.5)} {
+			if s.Evaluate(p) > 1e-6 {
+				t.Fatal("FAIL")
+			}
+		}
+	}
+	// concentric rings of a circle
+	lines = ConcentricFill(Circle2D(5), 1)
+	if l := length(lines); Abs(l-Tau*12.5) > 0.5 {
+		t.Errorf("FAIL %f", l)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_MedialAxis(t *testing.T) {
+	// the medial axis of a rectangle
+	axis := []*Line{
+		{{-5, 0}, {5, 0}},
+		{{5, 0}, {10, 5}}, {{5, 0}, {10, -5}},
+		{{-5, 0}, {-10, 5}}, {{-5, 0}, {-10, -5}},
+	}
+	lines := MedialAxis(Box2D(V2{20, 10}, 0), 100, DtoR(30))
+	length := 0.0
+	for _, l := range lines {
+		length += l[1].Sub(l[0]).Length()
+		for _, p := range l {
+			d2 := math.MaxFloat64
+			for _, x := range axis {
+				d2 = Min(d2, segmentDist2(p, x[0], x[1]))
+			}
+			if math.Sqrt(d2) > 0.2 {
+				t.Fatalf("FAIL %v is not on the axis", p)
+			}
+		}
+	}
+	if Abs(length-(10+20*math.Sqrt2))/length > 0.05 {
+		t.Errorf("FAIL length %f", length)
+	}
+	// the medial axis of an annulus is a circle
+	lines = MedialAxis(Difference2D(Circle2D(10), Circle2D(6)), 100, DtoR(30))
+	if len(lines) == 0 {
+		t.Fatal("FAIL")
+	}
+	for _, l := range lines {
+		for _, p := range l {
+			if Abs(p.Length()-8) > 0.1 {
+				t.Fatalf("FAIL %v is not on the axis", p)
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_ImageSDF2(t *testing.T) {
+	// a 10x4 black rectangle on a 20x10 image, the rest is white or transparent
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for j := 0; j < 10; j++ {
+		for i := 0; i < 20; i++ {
+			c := color.NRGBA{255, 255, 255, 255}
+			if i >= 5 && i < 15 && j >= 3 && j < 7 {
+				c = color.NRGBA{0, 0, 0, 255}
+			} else if i < 10 {
+				c = color.NRGBA{0, 0, 0, 0}
+			}
+			img.Set(i, j, c)
+		}
+	}
+	s, err := ImageSDF2(img, 0.5, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := []struct {
+		p V2
+		d float64
+	}{
+		{V2{0, 0.25}, -0.75},
+		{V2{0, 1}, 0},
+		{V2{0, 1.5}, 0.5},
+		{V2{-2.5, 0}, 0},
+		{V2{3.5, 0}, 1},
+		{V2{10, 0}, 7.5},
+	}
+	for i, x := range test {
+		if d := s.Evaluate(x.p); Abs(d-x.d) > 0.05 {
+			t.Errorf("FAIL %d: %f != %f", i, d, x.d)
+		}
+	}
+	if !s.BoundingBox().Equals(Box2{V2{-5, -2.5}, V2{5, 2.5}}, tolerance) {
+		t.Error("FAIL")
+	}
+	if _, err := ImageSDF2(image.NewGray(image.Rect(0, 0, 4, 4)), 0, 1); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Annotations(t *testing.T) {
+	a := NewAnnotations(2).SetPrecision(1)
+	a.Horizontal(V2{-10, -5}, V2{10, 5}, 4)
+	a.Vertical(V2{-10, -5}, V2{10, 5}, 4)
+	a.Aligned(V2{0, 0}, V2{3, -4}, 1)
+	a.Diameter(V2{0, 0}, 2.5, 0)
+	a.Radius(V2{0, 0}, 3, Pi)
+	a.Label(V2{1, 1}, "A")
+
+	test := []struct {
+		s     string
+		p     V2
+		angle float64
+	}{
+		{"20.0", V2{0, 9.5}, 0},
+		{"10.0", V2{13.5, 0}, 0.5 * Pi},
+		{"5.0", V2{2.7, -1.1}, math.Atan2(-4, 3)},
+		{"Ø5.0", V2{0, 0.5}, 0},
+		{"R3.0", V2{-1.5, 0.5}, 0},
+		{"A", V2{1, 1}, 0},
+	}
+	if len(a.texts) != len(test) {
+		t.Fatalf("expected %d texts, got %d", len(test), len(a.texts))
+	}
+	for i, v := range test {
+		x := a.texts[i]
+		if x.s != v.s || !x.p.Equals(v.p, tolerance) || Abs(x.angle-v.angle) > tolerance {
+			t.Errorf("FAIL text %d: got %q %v %f", i, x.s, x.p, x.angle)
+		}
+	}
+
+	bb := a.BoundingBox()
+	if bb.Max.Y < 10 || bb.Max.X < 14 {
+		t.Errorf("FAIL bounding box %v", bb)
+	}
+
+	// write the annotations with a profile
+	s := Box2D(V2{20, 10}, 0)
+	dir := t.TempDir()
+	if err := RenderDXFAnnotated(s, 50, filepath.Join(dir, "annotated.dxf"), a); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenderSVGAnnotated(s, 50, filepath.Join(dir, "annotated.svg"), "fill:none;stroke:black;stroke-width:0.1", a); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "annotated.svg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), ">20.0<") {
+		t.Error("FAIL svg text")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Sketch(t *testing.T) {
+	// a 20x10 rectangle with a semicircular right end, from rough positions
+	s := NewSketch()
+	p0 := s.Point(0, 0)
+	p1 := s.Point(19, 1)
+	p2 := s.Point(21, 9)
+	p3 := s.Point(1, 11)
+	c := s.Point(22, 4)
+	s.Fix(p0)
+	l0 := s.Line(p0, p1)
+	arc := s.Arc(c, p1, p2, true)
+	l2 := s.Line(p2, p3)
+	l3 := s.Line(p3, p0)
+	s.Horizontal(l0)
+	s.Length(l0, 20)
+	s.Radius(arc, 5)
+	s.Tangent(l0, arc)
+	s.Tangent(l2, arc)
+	s.Parallel(l0, l2)
+	s.Angle(l3, l0, 0.5*Pi)
+	p, err := s.Polygon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	test := []struct {
+		p *SketchPoint
+		v V2
+	}{
+		{p1, V2{20, 0}},
+		{p2, V2{20, 10}},
+		{p3, V2{0, 10}},
+		{c, V2{20, 5}},
+	}
+	for _, v := range test {
+		if !v.p.V2().Equals(v.v, 1e-6) {
+			t.Errorf("FAIL expected %v, got %v", v.v, v.p.V2())
+		}
+	}
+	area := Abs(clipArea([][]V2{p.Vertices()}))
+	if Abs(area-(200+0.5*Pi*25)) > 0.1 {
+		t.Errorf("FAIL area %f", area)
+	}
+	sdf, err := s.Polygon2D()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := sdf.Evaluate(V2{10, 5}); Abs(d+5) > 1e-3 {
+		t.Errorf("FAIL distance %f", d)
+	}
+	if d := sdf.Evaluate(V2{27, 5}); Abs(d-2) > 1e-2 {
+		t.Errorf("FAIL distance %f", d)
+	}
+
+	// inconsistent constraints
+	s = NewSketch()
+	p0 = s.Point(0, 0)
+	p1 = s.Point(1, 0)
+	p2 = s.Point(0, 1)
+	s.Line(p0, p1)
+	s.Line(p1, p2)
+	s.Line(p2, p0)
+	s.Distance(p0, p1, 1)
+	s.Distance(p1, p2, 1)
+	s.Distance(p2, p0, 3)
+	if _, err := s.Polygon(); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Grid2D(t *testing.T) {
+	s0 := Circle2D(5)
+	s1, err := Grid2D(s0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1.BoundingBox() != s0.BoundingBox() {
+		t.Error("FAIL bounding box")
+	}
+	// the interpolated distance is close to the sampled distance, the error
+	// is largest at the center of the circle where the gradient is undefined
+	bb := s0.BoundingBox()
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		d0, d1 := s0.Evaluate(p), s1.Evaluate(p)
+		if Abs(d0-d1) > 0.05 || (Abs(d0) < 2 && Abs(d0-d1) > 1e-3) {
+			t.Fatalf("FAIL at %v: %f %f", p, d0, d1)
+		}
+	}
+	// outside the grid the distance is positive
+	if d := s1.Evaluate(V2{20, 20}); d < 15 {
+		t.Errorf("FAIL %f", d)
+	}
+	// the grid points are exact
+	if d := s1.Evaluate(V2{0, 0}); Abs(d+5) > tolerance {
+		t.Errorf("FAIL %f", d)
+	}
+	if _, err := Grid2D(s0, 0); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_TextMetrics(t *testing.T) {
+	fonts, err := parseFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fonts[0]
+	txt := NewText("Hello\nWorld!")
+	s, err := TextSDF2(f, txt, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := TextMetrics(f, txt, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the extents match the rendered text
+	if !m.BoundingBox.Equals(s.BoundingBox(), tolerance) {
+		t.Errorf("FAIL bounding box %v %v", m.BoundingBox, s.BoundingBox())
+	}
+	if len(m.Advance) != 2 || len(m.Origin) != 2 || m.LineHeight != 10 {
+		t.Fatal("FAIL")
+	}
+	if Abs(m.Origin[0].Y-m.Origin[1].Y-10) > tolerance {
+		t.Errorf("FAIL baselines %v", m.Origin)
+	}
+	for i := range m.Advance {
+		// the lines are centered
+		if Abs(m.Origin[i].X+0.5*m.Advance[i]-m.Origin[0].X-0.5*m.Advance[0]) > tolerance {
+			t.Errorf("FAIL line %d is not centered", i)
+		}
+		// the glyphs are on the lines
+		if m.Advance[i] <= 0 || m.Advance[i] > m.BoundingBox.Size().X+1 {
+			t.Errorf("FAIL advance %f", m.Advance[i])
+		}
+	}
+	// the baseline of the first line is inside the text
+	if m.Origin[0].Y >= m.BoundingBox.Max.Y || m.Origin[0].Y <= 0 {
+		t.Errorf("FAIL baseline %f", m.Origin[0].Y)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// fontCollection returns a font collection (*.ttc) of TrueType fonts.
+func fontCollection(fonts ...[]byte) []byte {
+	// header: tag, version, number of fonts, font offsets
+	n := 12 + 4*len(fonts)
+	hdr := make([]byte, n)
+	copy(hdr, "ttcf")
+	binary.BigEndian.PutUint32(hdr[4:], 0x00010000)
+	binary.BigEndian.PutUint32(hdr[8:], uint32(len(fonts)))
+	var data []byte
+	for i, f := range fonts {
+		ofs := n + len(data)
+		binary.BigEndian.PutUint32(hdr[12+4*i:], uint32(ofs))
+		f = append([]byte{}, f...)
+		// the table offsets are from the start of the file
+		for j := 0; j < int(binary.BigEndian.Uint16(f[4:])); j++ {
+			k := 12 + 16*j + 8
+			binary.BigEndian.PutUint32(f[k:], binary.BigEndian.Uint32(f[k:])+uint32(ofs))
+		}
+		data = append(data, f...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	return append(hdr, data...)
+}
+
+func Test_LoadFont(t *testing.T) {
+	// OpenType with CFF outlines
+	f, err := LoadFont("testdata/CFFTest.otf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := TextSDF2(f, NewText("0"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the zero has a hole in the middle
+	if d := s.Evaluate(V2{0, 0}); d <= 1 {
+		t.Errorf("FAIL hole %f", d)
+	}
+	bb0 := s.BoundingBox()
+	dmin := 0.0
+	for x := bb0.Min.X; x < bb0.Max.X; x += 0.01 {
+		dmin = Min(dmin, s.Evaluate(V2{x, 0}))
+	}
+	if dmin > -0.1 {
+		t.Errorf("FAIL outline %f", dmin)
+	}
+
+	// an outline with a filled island inside a hole (E.g. ®), with the
+	// contours in both directions
+	square := func(r float64, ccw bool) []sfnt.Segment {
+		v := []V2{{-r, -r}, {r, -r}, {r, r}, {-r, r}}
+		if !ccw {
+			v[1], v[3] = v[3], v[1]
+		}
+		var segs []sfnt.Segment
+		for i, p := range v {
+			op := sfnt.SegmentOpLineTo
+			if i == 0 {
+				op = sfnt.SegmentOpMoveTo
+			}
+			x := fixed.Point26_6{X: fixed.Int26_6(p.X), Y: fixed.Int26_6(p.Y)}
+			segs = append(segs, sfnt.Segment{Op: op, Args: [3]fixed.Point26_6{x}})
+		}
+		return segs
+	}
+	for _, ccw := range []bool{true, false} {
+		var segs []sfnt.Segment
+		segs = append(segs, square(10, ccw)...)
+		segs = append(segs, square(2, ccw)...)
+		segs = append(segs, square(6, !ccw)...)
+		s := sfntConvert(segs)
+		if s.Evaluate(V2{0, 0}) >= 0 || s.Evaluate(V2{4, 0}) <= 0 || s.Evaluate(V2{8, 0}) >= 0 {
+			t.Errorf("FAIL island (ccw %v)", ccw)
+		}
+	}
+
+	// font collection
+	path := filepath.Join(t.TempDir(), "go.ttc")
+	if err := os.WriteFile(path, fontCollection(goregular.TTF, gobold.TTF), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fonts, err := LoadFontCollection(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fonts) != 2 {
+		t.Fatalf("FAIL %d fonts", len(fonts))
+	}
+	var bb [2]Box2
+	for i, f := range fonts {
+		s, err := TextSDF2(f, NewText("Wo"), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bb[i] = s.BoundingBox()
+	}
+	// the regular font is the same as the truetype font
+	ttf, err := parseFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = TextSDF2(ttf[0], NewText("Wo"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bb[0].Equals(s.BoundingBox(), 1e-6) {
+		t.Errorf("FAIL %v %v", bb[0], s.BoundingBox())
+	}
+	// the bold font is wider
+	if bb[1].Size().X <= bb[0].Size().X {
+		t.Error("FAIL")
+	}
+	// LoadFont returns the first font of a collection
+	if _, err := LoadFont(path); err != nil {
+		t.Error(err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Text3D(t *testing.T) {
+	fonts, err := parseFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fonts[0]
+	s2d, err := TextSDF2(f, NewText("Hi"), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb2 := s2d.BoundingBox()
+	// embossed text is on top of the surface
+	s, err := Text3D(f, "Hi", 8, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb := s.BoundingBox()
+	if !bb.Equals(Box3{V3{bb2.Min.X, bb2.Min.Y, 0}, V3{bb2.Max.X, bb2.Max.Y, 2}}, tolerance) {
+		t.Errorf("FAIL %v", bb)
+	}
+	// engraved text is below the surface
+	s, err = Text3D(f, "Hi", 8, 2, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb = s.BoundingBox()
+	if !bb.Equals(Box3{V3{bb2.Min.X, bb2.Min.Y, -2}, V3{bb2.Max.X, bb2.Max.Y, 0}}, tolerance) {
+		t.Errorf("FAIL %v", bb)
+	}
+	for _, p := range []V2{{0, 0}, {1, 1}, {-2, 0.5}} {
+		if d0, d1 := s2d.Evaluate(p), s.Evaluate(V3{p.X, p.Y, -1}); Abs(Max(d0, -1)-d1) > tolerance {
+			t.Errorf("FAIL at %v: %f %f", p, d0, d1)
+		}
+	}
+	// errors
+	if _, err := Text3D(f, " ", 8, 2, false); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := Text3D(f, "Hi", 8, 0, false); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_TextOnPath2D(t *testing.T) {
+	fonts, err := parseFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fonts[0]
+	txt := NewText("Hello")
+
+	// a straight path is the same as the plain text (to within the random
+	// bezier sampling of the glyph curves)
+	s0, err := TextSDF2(f, txt, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, err := TextOnPath2D(f, txt, 5, []V2{{-50, 0}, {0, 0}, {50, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ofs := s1.BoundingBox().Center().Sub(s0.BoundingBox().Center())
+	if Abs(ofs.X) > 0.5 || s1.BoundingBox().Min.Y > 0 {
+		t.Errorf("FAIL offset %v", ofs)
+	}
+	bb := s0.BoundingBox()
+	for i := 0; i < 500; i++ {
+		p := bb.Random()
+		if d0, d1 := s0.Evaluate(p), s1.Evaluate(p.Add(ofs)); Abs(d0-d1) > 0.01 {
+			t.Fatalf("FAIL at %v: %f %f", p, d0, d1)
+		}
+	}
+
+	// the text on a dial is in an annulus about the baseline circle
+	for _, cw := range []bool{true, false} {
+		s, err := TextOnArc2D(f, txt, 5, 20, 0.5*Pi, cw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bb := s.BoundingBox()
+		if bb.Center().X > 1 || bb.Center().X < -1 {
+			t.Errorf("FAIL text is not centered %v", bb)
+		}
+		for i := 0; i < 2000; i++ {
+			p := bb.Random()
+			if s.Evaluate(p) > 0 {
+				continue
+			}
+			r := p.Length()
+			if (cw && (r < 18.5 || r > 25)) || (!cw && (r < 15 || r > 21.5)) {
+				t.Fatalf("FAIL radius %f", r)
+			}
+		}
+	}
+
+	if _, err := TextOnArc2D(f, NewText("a\nb"), 5, 20, 0, true); err == nil {
+		t.Error("FAIL")
+	}
+	if _, err := TextOnPath2D(f, txt, 5, []V2{{1, 1}}); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_TextSpacing(t *testing.T) {
+	fonts, err := parseFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fonts[0]
+	advance := func(txt *Text) float64 {
+		m, err := TextMetrics(f, txt, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.Advance[0]
+	}
+	a0 := advance(NewText("AVA V"))
+	// tracking is added between each of the 5 glyphs
+	if a := advance(NewText("AVA V").SetTracking(0.1)); Abs(a-a0-4) > tolerance {
+		t.Errorf("FAIL tracking %f %f", a0, a)
+	}
+	// word spacing is added to the space
+	if a := advance(NewText("AVA V").SetWordSpacing(-0.2)); Abs(a-a0+2) > tolerance {
+		t.Errorf("FAIL word spacing %f %f", a0, a)
+	}
+	// kerning overrides replace the font kerning
+	a1 := advance(NewText("AVA V").SetKerning('A', 'V', 0).SetKerning('V', 'A', 0))
+	a2 := advance(NewText("AVA V").SetKerning('A', 'V', 0.1).SetKerning('V', 'A', 0))
+	if Abs(a2-a1-1) > tolerance {
+		t.Errorf("FAIL kerning %f %f", a1, a2)
+	}
+	// the rendered text matches the metrics
+	txt := NewText("AVA V").SetTracking(0.2)
+	s, err := TextSDF2(f, txt, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := TextMetrics(f, txt, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.BoundingBox().Equals(m.BoundingBox, tolerance) || m.BoundingBox.Size().X < a0 {
+		t.Errorf("FAIL %v %v", s.BoundingBox(), m.BoundingBox)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_FontFallback(t *testing.T) {
+	fonts, err := parseFont(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fonts[0]
+	cff, err := LoadFont("testdata/CFFTest.otf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aspect := func(f *Font, s string) float64 {
+		s2d, err := TextSDF2(f, NewText(s), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := s2d.BoundingBox().Size()
+		return size.X / size.Y
+	}
+	// the CJK character is missing from the primary font
+	a0 := aspect(cff, "中")
+	a1 := aspect(f, "中")
+	if Abs(a0-a1) < 0.01 {
+		t.Fatal("FAIL the glyph should be missing")
+	}
+	// the fallback font has the glyph
+	f.SetFallback(cff)
+	if a := aspect(f, "中"); Abs(a-a0) > 1e-3 {
+		t.Errorf("FAIL fallback glyph %f %f", a, a0)
+	}
+	// characters in the primary font still use it
+	g, _ := f.lookup('0')
+	if g != f {
+		t.Error("FAIL primary glyph")
+	}
+	// the fallback glyph is scaled to the primary font
+	m, err := TextMetrics(f, NewText("中"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, i := f.lookup('中')
+	if g != cff {
+		t.Fatal("FAIL fallback font")
+	}
+	k := 10 / f.lineHeight() * float64(f.ppem()) / float64(cff.ppem())
+	if Abs(m.Advance[0]-cff.advance(i)*k) > tolerance {
+		t.Errorf("FAIL advance %f", m.Advance[0])
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_LoadFontFromBytes(t *testing.T) {
+	b, err := os.ReadFile("testdata/CFFTest.otf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fonts []*Font
+	f, err := LoadFontFromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fonts = append(fonts, f)
+	f, err = LoadFontFromReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fonts = append(fonts, f)
+	for _, f := range fonts {
+		if f.sf == nil || f.index('0') == 0 {
+			t.Error("FAIL")
+		}
+	}
+	if _, err := LoadFontFromBytes([]byte("not a font")); err == nil {
+		t.Error("FAIL")
+	}
+
+	// embedded fonts
+	names := EmbeddedFontNames()
+	if len(names) == 0 {
+		t.Fatal("FAIL")
+	}
+	for _, name := range names {
+		f, err := EmbeddedFont(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := TextSDF2(f, NewText("Abc"), 10); err != nil {
+			t.Errorf("FAIL %s: %s", name, err)
+		}
+	}
+	if _, err := EmbeddedFont("Comic-Sans"); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_GlyphCache(t *testing.T) {
+	f, err := LoadFontFromBytes(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := f.index('A')
+	g0, err := f.glyph(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g1, err := f.glyph(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g0 != g1 {
+		t.Error("FAIL glyph not cached")
+	}
+
+	// the same text renders the same
+	text := NewText("The quick brown fox\njumps over the lazy dog")
+	s0, err := TextSDF2(f, text, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s1, err := TextSDF2(f, text, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb := s0.BoundingBox()
+	if !bb.Equals(s1.BoundingBox(), tolerance) {
+		t.Error("FAIL bounding box")
+	}
+	for i := 0; i < 100; i++ {
+		p := bb.Random()
+		if s0.Evaluate(p) != s1.Evaluate(p) {
+			t.Errorf("FAIL %v", p)
+		}
+	}
+
+	// the BVH union matches the plain union
+	var ss []SDF2
+	for j := 0; j < 20; j++ {
+		s := Circle2D(0.3 + 0.1*float64(j%3))
+		ss = append(ss, Transform2D(s, Translate2d(V2{float64(j), float64(j % 4)})))
+	}
+	u0 := Union2D(ss...)
+	u1 := UnionBVH2D(ss...)
+	bb = u0.BoundingBox().ScaleAboutCenter(1.5)
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		if Abs(u0.Evaluate(p)-u1.Evaluate(p)) > tolerance {
+			t.Errorf("FAIL %v", p)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// gsubTestTable returns a GSUB table with a "liga" feature for the ligature
+// a+b -> l, and an "init" feature for the single substitution a -> x.
+func gsubTestTable(a, b, l, x int) []byte {
+	var d []byte
+	u16 := func(v ...int) {
+		for _, x := range v {
+			d = append(d, byte(x>>8), byte(x))
+		}
+	}
+	// header: version, script list, feature list, lookup list
+	u16(1, 0, 0, 10, 36)
+	// feature list
+	u16(2)
+	d = append(d, "liga"...)
+	u16(14)
+	d = append(d, "init"...)
+	u16(20)
+	u16(0, 1, 0)
+	u16(0, 1, 1)
+	// lookup list
+	u16(2, 6, 38)
+	// ligature lookup: subtable, coverage, ligature set, ligature
+	u16(4, 0, 1, 8)
+	u16(1, 8, 1, 14)
+	u16(1, 1, a)
+	u16(1, 4)
+	u16(l, 2, b)
+	// single substitution lookup: subtable, coverage
+	u16(1, 0, 1, 8)
+	u16(2, 8, 1, x)
+	u16(1, 1, a)
+	return d
+}
+
+func Test_TextShaping(t *testing.T) {
+	// bidi display order
+	display := func(s string, dir direction) string {
+		rs := []rune(s)
+		var out []rune
+		for _, i := range bidiReorder(bidiLevels(rs, dir)) {
+			out = append(out, rs[i])
+		}
+		return string(out)
+	}
+	tests := []struct {
+		s   string
+		dir direction
+		out string
+	}{
+		{"abc def", autoDirection, "abc def"},
+		{"abc אבג def", autoDirection, "abc גבא def"},
+		{"אבג 123", autoDirection, "123 גבא"},
+		{"אבג def", autoDirection, "def גבא"},
+		{"abc", rtlDirection, "abc"},
+		{"abc אבג", rtlDirection, "גבא abc"},
+	}
+	for _, x := range tests {
+		if out := display(x.s, x.dir); out != x.out {
+			t.Errorf("FAIL %q: got %q, expected %q", x.s, out, x.out)
+		}
+	}
+
+	// arabic joining forms
+	forms := joiningForms([]rune("بيت دار"))
+	expected := []int{formInitial, formMedial, formFinal, formNone, formIsolated, formIsolated, formIsolated}
+	for i := range forms {
+		if forms[i] != expected[i] {
+			t.Errorf("FAIL form %d: %d", i, forms[i])
+		}
+	}
+	if r, n := arabicPresentation([]rune("لا"), formInitial); r != 0xfefb || n != 2 {
+		t.Errorf("FAIL lam-alef %x %d", r, n)
+	}
+
+	// pre-base vowel signs
+	rs := []rune("कि क्षि")
+	reorderMatras(rs)
+	if string(rs) != "िक िक्ष" {
+		t.Errorf("FAIL matras %q", string(rs))
+	}
+
+	// glyph substitutions
+	f := &Font{}
+	g := parseGSUB(gsubTestTable(5, 6, 9, 7))
+	gs := []shapedGlyph{{f: f, i: 5}, {f: f, i: 6}, {f: f, i: 5, form: formInitial}}
+	gs = g.apply("liga", gs, f, formNone)
+	gs = g.apply("init", gs, f, formInitial)
+	if len(gs) != 2 || gs[0].i != 9 || gs[1].i != 7 {
+		t.Errorf("FAIL gsub %v", gs)
+	}
+
+	// mirrored characters in right to left text
+	f, err := LoadFontFromBytes(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs = shapeLine(f, NewText("").SetRightToLeft(true), "(ab)")
+	expectedIndex := []fontIndex{f.index('('), f.index('a'), f.index('b'), f.index(')')}
+	for i := range gs {
+		if gs[i].i != expectedIndex[i] {
+			t.Errorf("FAIL mirror %d", i)
+		}
+	}
+
+	// a combining mark doesn't advance
+	_, l0, err := lineGlyphs(f, NewText(""), "e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tgs, l1, err := lineGlyphs(f, NewText(""), "e\u0301")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tgs) != 2 || l1 != l0 {
+		t.Errorf("FAIL mark %d %f %f", len(tgs), l0, l1)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_TextSize(t *testing.T) {
+	f, err := LoadFontFromBytes(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := f.capHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the em square of 72 point text is an inch
+	upem := float64(f.ppem())
+	h := FontSizeToHeight(f, 72, MillimetresPerInch)
+	if Abs(h*upem/f.lineHeight()-MillimetresPerInch) > tolerance {
+		t.Errorf("FAIL %f", h)
+	}
+	s, err := TextSDF2Points(f, NewText("H"), 36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y := s.BoundingBox().Size().Y; Abs(y-0.5*ch*MillimetresPerInch/upem) > 0.05 {
+		t.Errorf("FAIL %f", y)
+	}
+	// cap height
+	s, err = TextSDF2CapHeight(f, NewText("HELL"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y := s.BoundingBox().Size().Y; Abs(y-5) > 0.05 {
+		t.Errorf("FAIL %f", y)
+	}
+	if _, err := TextSDF2Points(f, NewText("H"), 0); err == nil {
+		t.Error("FAIL")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// triangulationArea returns the area of a triangulation, and false if a
+// triangle doesn't have a counter-clockwise winding.
+func triangulationArea(vs V2Set, ts TriangleISet) (float64, bool) {
+	area := 0.0
+	for _, t := range ts {
+		a := orient2d(vs[t[0]], vs[t[1]], vs[t[2]])
+		if a <= 0 {
+			return 0, false
+		}
+		area += 0.5 * a
+	}
+	return area, true
+}
+
+// hasEdge returns true if a triangle set has an edge.
+func hasEdge(ts TriangleISet, a, b int) bool {
+	for _, t := range ts {
+		for j := 0; j < 3; j++ {
+			u, v := t[j], t[(j+1)%3]
+			if u == a && v == b || u == b && v == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func Test_ConstrainedDelaunay(t *testing.T) {
+	// square with a square hole
+	outer := []V2{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	hole := []V2{{3, 3}, {3, 7}, {7, 7}, {7, 3}}
+	vs, ts, err := Triangulate2d(outer, hole)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if area, ok := triangulationArea(vs, ts); !ok || Abs(area-84) > tolerance {
+		t.Errorf("FAIL area %f", area)
+	}
+	for i := 0; i < 4; i++ {
+		if !hasEdge(ts, i, (i+1)%4) || !hasEdge(ts, 4+i, 4+(i+1)%4) {
+			t.Errorf("FAIL edge %d", i)
+		}
+	}
+
+	// concave polygon
+	c := []V2{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 8}, {8, 8}, {8, 2}, {0, 2}}
+	vs, ts, err = Triangulate2d(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if area, ok := triangulationArea(vs, ts); !ok || Abs(area-52) > tolerance {
+		t.Errorf("FAIL area %f", area)
+	}
+
+	// an edge through a vertex is split
+	vs = V2Set{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {5, 5}, {2, 7}, {7, 2}}
+	ts, err = vs.ConstrainedDelaunay2d([]EdgeI{{0, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasEdge(ts, 0, 4) || !hasEdge(ts, 4, 2) {
+		t.Error("FAIL split edge")
+	}
+
+	// random points with an edge across them
+	vs = V2Set{{-1, 0.1}, {11, -0.2}}
+	for i := 0; i < 200; i++ {
+		vs = append(vs, V2{randomRange(0, 10), randomRange(-5, 5)})
+	}
+	ts0, err := append(V2Set(nil), vs...).Delaunay2d()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err = vs.ConstrainedDelaunay2d([]EdgeI{{0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasEdge(ts, 0, 1) {
+		t.Error("FAIL no edge")
+	}
+	if len(ts) != len(ts0) {
+		t.Errorf("FAIL %d triangles, expected %d", len(ts), len(ts0))
+	}
+	if _, ok := triangulationArea(vs, ts); !ok {
+		t.Error("FAIL winding")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Voronoi2d(t *testing.T) {
+	// area of a ccw polygon
+	area := func(p []V2) float64 {
+		a := 0.0
+		for i := range p {
+			a += p[i].Cross(p[(i+1)%len(p)])
+		}
+		return 0.5 * a
+	}
+
+	// grid of points
+	var vs V2Set
+	for x := -1; x <= 1; x++ {
+		for y := -1; y <= 1; y++ {
+			vs = append(vs, V2{float64(x), float64(y) + 0.01*float64(x)})
+		}
+	}
+	bb := Box2{V2{-1.5, -1.5}, V2{1.5, 1.5}}
+	cells, err := vs.Voronoi2d(bb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, cell := range cells {
+		if Abs(area(cell)-1) > 0.05 {
+			t.Errorf("FAIL cell %d area %f", i, area(cell))
+		}
+	}
+
+	// random points, the cells tile the box
+	vs = nil
+	for i := 0; i < 50; i++ {
+		vs = append(vs, V2{randomRange(0, 10), randomRange(0, 5)})
+	}
+	bb = Box2{V2{0, 0}, V2{10, 5}}
+	cells, err = vs.Voronoi2d(bb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0.0
+	for _, cell := range cells {
+		total += area(cell)
+	}
+	if Abs(total-50) > 1e-6 {
+		t.Errorf("FAIL total area %f", total)
+	}
+	for i := 0; i < 100; i++ {
+		p := bb.Random()
+		// the nearest point
+		k := 0
+		for j := range vs {
+			if p.Sub(vs[j]).Length2() < p.Sub(vs[k]).Length2() {
+				k = j
+			}
+		}
+		if Polygon2D(cells[k]).Evaluate(p) > tolerance {
+			t.Errorf("FAIL %v not in the cell", p)
+		}
+	}
+
+	// a pair of points
+	cells, err = V2Set{{-1, 0}, {1, 0}}.Voronoi2d(Box2{V2{-2, -1}, V2{2, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Abs(area(cells[0])-4) > tolerance || Abs(area(cells[1])-4) > tolerance {
+		t.Error("FAIL pair")
+	}
+
+	// wall pattern
+	s, err := VoronoiPattern2D(V2Set{{-1, 0}, {1, 0}}, Box2{V2{-2, -1}, V2{2, 1}}, 0.2, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Evaluate(V2{-1, 0}) <= 0 || s.Evaluate(V2{0, 0}) >= 0 || Abs(s.Evaluate(V2{0, 0})+0.1) > tolerance {
+		t.Errorf("FAIL pattern %f %f", s.Evaluate(V2{-1, 0}), s.Evaluate(V2{0, 0}))
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Predicates(t *testing.T) {
+	// nearly collinear points, the sign matches exact arithmetic
+	q, r := V2{12, 12}, V2{24, 24}
+	for i := 0; i < 64; i++ {
+		for j := 0; j < 64; j++ {
+			p := V2{0.5 + float64(i)*machineEpsilon, 0.5 + float64(j)*machineEpsilon}
+			o := orient2d(p, q, r)
+			if math.Signbit(o) != math.Signbit(orient2dExact(p, q, r)) || (o == 0) != (orient2dExact(p, q, r) == 0) {
+				t.Fatalf("FAIL orient2d %v", p)
+			}
+		}
+	}
+	if orient2d(V2{0.1, 0.1}, V2{0.2, 0.2}, V2{0.3, 0.3}) != 0 {
+		t.Error("FAIL collinear")
+	}
+	// cocircular points
+	if inCircle(V2{1, 0}, V2{0, 1}, V2{-1, 0}, V2{0, -1}) != 0 {
+		t.Error("FAIL cocircular")
+	}
+	if inCircle(V2{1, 0}, V2{0, 1}, V2{-1, 0}, V2{0, -1 + 1e-15}) <= 0 {
+		t.Error("FAIL inside")
+	}
+
+	// delaunay triangulation of an offset grid
+	var vs V2Set
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			vs = append(vs, V2{1e6 + 0.1*float64(x), 1e6 + 0.1*float64(y)})
+		}
+	}
+	ts, err := vs.ConstrainedDelaunay2d(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 162 {
+		t.Errorf("FAIL %d triangles", len(ts))
+	}
+	for _, x := range ts {
+		if orient2d(vs[x[0]], vs[x[1]], vs[x[2]]) <= 0 {
+			t.Errorf("FAIL triangle %v", x)
+		}
+		for i, v := range vs {
+			if i != x[0] && i != x[1] && i != x[2] && inCircle(vs[x[0]], vs[x[1]], vs[x[2]], v) > 0 {
+				t.Errorf("FAIL not delaunay %v", x)
+			}
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Delaunay2d(t *testing.T) {
+	// triangle as a sorted vertex triple
+	key := func(vs V2Set, x TriangleI) [3]V2 {
+		k := [3]V2{vs[x[0]], vs[x[1]], vs[x[2]]}
+		sort.Slice(k[:], func(i, j int) bool {
+			return k[i].X < k[j].X || (k[i].X == k[j].X && k[i].Y < k[j].Y)
+		})
+		return k
+	}
+
+	// compare with the reference implementation
+	for n := 0; n < 10; n++ {
+		var vs V2Set
+		for i := 0; i < 30; i++ {
+			vs = append(vs, V2{randomRange(-10, 10), randomRange(-10, 10)})
+		}
+		ts0, err := vs.Delaunay2dSlow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref := make(map[[3]V2]bool)
+		for _, x := range ts0 {
+			ref[key(vs, x)] = true
+		}
+		ts1, err := vs.Delaunay2d()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ts1) != len(ts0) {
+			t.Errorf("FAIL %d triangles, expected %d", len(ts1), len(ts0))
+		}
+		for _, x := range ts1 {
+			if !ref[key(vs, x)] {
+				t.Errorf("FAIL triangle %v", x)
+			}
+		}
+	}
+
+	// a large point set is locally delaunay
+	var vs V2Set
+	for i := 0; i < 20000; i++ {
+		vs = append(vs, V2{randomRange(0, 100), randomRange(0, 100)})
+	}
+	ts, err := vs.Delaunay2d()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tri := make(map[EdgeI]int)
+	for i, x := range ts {
+		if orient2d(vs[x[0]], vs[x[1]], vs[x[2]]) <= 0 {
+			t.Fatalf("FAIL winding %v", x)
+		}
+		for j := 0; j < 3; j++ {
+			tri[EdgeI{x[j], x[(j+1)%3]}] = i
+		}
+	}
+	hull := 0
+	for e, i := range tri {
+		k, ok := tri[EdgeI{e[1], e[0]}]
+		if !ok {
+			hull++
+			continue
+		}
+		x := ts[i]
+		if inCircle(vs[x[0]], vs[x[1]], vs[x[2]], vs[ts[k].third(e[0], e[1])]) > 0 {
+			t.Fatalf("FAIL edge %v is not delaunay", e)
+		}
+	}
+	if len(ts) != 2*len(vs)-2-hull {
+		t.Errorf("FAIL %d triangles, %d hull edges", len(ts), hull)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// minTriangleAngle returns the smallest angle of a set of triangles.
+func minTriangleAngle(vs V2Set, ts TriangleISet) float64 {
+	min := math.Pi
+	for _, t := range ts {
+		for j := 0; j < 3; j++ {
+			a := vs[t[j]]
+			b := vs[t[(j+1)%3]].Sub(a)
+			c := vs[t[(j+2)%3]].Sub(a)
+			min = math.Min(min, math.Acos(Clamp(b.Dot(c)/(b.Length()*c.Length()), -1, 1)))
+		}
+	}
+	return min
+}
+
+func Test_Refine2d(t *testing.T) {
+	// square with a square hole
+	outer := []V2{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	hole := []V2{{3, 3}, {3, 7}, {7, 7}, {7, 3}}
+	vs, ts, err := RefineTriangulate2d(DtoR(30), 0.5, outer, hole)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if area, ok := triangulationArea(vs, ts); !ok || Abs(area-84) > 1e-6 {
+		t.Errorf("FAIL area %f", area)
+	}
+	if a := minTriangleAngle(vs, ts); a < DtoR(30)-1e-9 {
+		t.Errorf("FAIL min angle %f", RtoD(a))
+	}
+	for _, x := range ts {
+		if 0.5*orient2d(vs[x[0]], vs[x[1]], vs[x[2]]) > 0.5 {
+			t.Errorf("FAIL large triangle")
+			break
+		}
+	}
+	for i := 0; i < 8; i++ {
+		if vs[i] != append(outer, hole...)[i] {
+			t.Errorf("FAIL input vertex %d", i)
+		}
+	}
+
+	// a thin wedge keeps its small angle but terminates
+	wedge := []V2{{0, 0}, {10, 0}, {10, 1}}
+	vs, ts, err = RefineTriangulate2d(DtoR(25), 0, wedge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if area, ok := triangulationArea(vs, ts); !ok || Abs(area-5) > 1e-6 {
+		t.Errorf("FAIL area %f", area)
+	}
+
+	// bad parameters
+	if _, _, err := RefineTriangulate2d(DtoR(40), 0, outer); err == nil {
+		t.Errorf("FAIL expected an error")
+	}
+
+	// circle
+	r := 5.0
+	vs, ts, err = Mesh2D(Circle2D(r), 100, DtoR(25), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if area, ok := triangulationArea(vs, ts); !ok || Abs(area-math.Pi*r*r) > 0.01*math.Pi*r*r {
+		t.Errorf("FAIL area %f", area)
+	}
+	if a := minTriangleAngle(vs, ts); a < DtoR(25)-1e-9 {
+		t.Errorf("FAIL min angle %f", RtoD(a))
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_PoissonDisk(t *testing.T) {
+	r := 0.5
+	// two separate circles
+	s := Union2D(Transform2D(Circle2D(3), Translate2d(V2{-5, 0})), Transform2D(Circle2D(2), Translate2d(V2{5, 0})))
+	vs, err := PoissonDisk2D(s, r, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := 0
+	for i, p := range vs {
+		if s.Evaluate(p) > 0 {
+			t.Errorf("FAIL point %v outside", p)
+		}
+		if p.X < 0 {
+			left++
+		}
+		for _, q := range vs[i+1:] {
+			if p.Sub(q).Length() < r {
+				t.Errorf("FAIL points %v %v too close", p, q)
+			}
+		}
+	}
+	if left == 0 || left == len(vs) {
+		t.Errorf("FAIL a circle has no points")
+	}
+	// no gaps: every point of the region is within 2r of a point
+	bb := s.BoundingBox()
+	for i := 0; i < 1000; i++ {
+		p := bb.Random()
+		if s.Evaluate(p) > 0 {
+			continue
+		}
+		d := math.Inf(1)
+		for _, q := range vs {
+			d = math.Min(d, p.Sub(q).Length())
+		}
+		if d > 2*r {
+			t.Errorf("FAIL gap at %v", p)
+			break
+		}
+	}
+	// seeded
+	vs2, _ := PoissonDisk2D(s, r, 1)
+	if len(vs2) != len(vs) || vs2[len(vs2)-1] != vs[len(vs)-1] {
+		t.Errorf("FAIL not repeatable")
+	}
+	if _, err := PoissonDisk2D(s, 0, 1); err == nil {
+		t.Errorf("FAIL expected an error")
+	}
+
+	// sphere
+	s3 := Sphere3D(2)
+	ps, err := PoissonDisk3D(s3, r, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range ps {
+		if s3.Evaluate(p) > 0 {
+			t.Errorf("FAIL point %v outside", p)
+		}
+		for _, q := range ps[i+1:] {
+			if p.Sub(q).Length() < r {
+				t.Errorf("FAIL points %v %v too close", p, q)
+			}
+		}
+	}
+	// the packing density of poisson disk samples is about 0.3 or more
+	density := float64(len(ps)) * (math.Pi / 6 * r * r * r) / (4.0 / 3.0 * math.Pi * 8)
+	if density < 0.2 || density > 0.74 {
+		t.Errorf("FAIL density %f", density)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// gridMesh returns an n x n grid of triangles on the xy plane.
+func gridMesh(n int) ([]V3, [][]int) {
+	var vertex []V3
+	var face [][]int
+	for j := 0; j <= n; j++ {
+		for i := 0; i <= n; i++ {
+			vertex = append(vertex, V3{float64(i), float64(j), 0})
+		}
+	}
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			a := j*(n+1) + i
+			face = append(face, []int{a, a + 1, a + n + 2}, []int{a, a + n + 2, a + n + 1})
+		}
+	}
+	return vertex, face
+}
+
+// liveFaces returns the number of faces in a half edge mesh.
+func liveFaces(m *HalfEdgeMesh) int {
+	n := 0
+	for f := 0; f < m.Faces(); f++ {
+		if !m.FaceDeleted(f) {
+			n++
+		}
+	}
+	return n
+}
+
+func Test_HalfEdgeMesh(t *testing.T) {
+	vertex, face := gridMesh(3)
+	m, err := NewHalfEdgeMeshIndexed(vertex, face)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.check(); err != nil {
+		t.Fatal(err)
+	}
+	if m.IsClosed() {
+		t.Errorf("FAIL grid is closed")
+	}
+	b := m.Boundaries()
+	if len(b) != 1 || len(b[0]) != 12 {
+		t.Errorf("FAIL boundaries %v", b)
+	}
+	// interior vertex (1,1) and corner vertex (0,0)
+	if n := m.VertexNeighbors(5); len(n) != 6 {
+		t.Errorf("FAIL neighbors %v", n)
+	}
+	if n := m.VertexNeighbors(0); len(n) != 3 || !m.IsBoundaryVertex(0) || m.IsBoundaryVertex(5) {
+		t.Errorf("FAIL corner neighbors %v", n)
+	}
+	if f := m.VertexFaces(5); len(f) != 6 {
+		t.Errorf("FAIL faces %v", f)
+	}
+	if n := m.VertexNormal(5); !n.Equals(V3{0, 0, 1}, tolerance) {
+		t.Errorf("FAIL normal %v", n)
+	}
+	if n := m.FaceNeighbors(0); len(n) != 2 {
+		t.Errorf("FAIL face neighbors %v", n)
+	}
+
+	// flip the diagonal of the (1,1) square
+	e := m.HalfEdge(5, 10)
+	if e < 0 || !m.FlipEdge(e) {
+		t.Fatal("FAIL flip")
+	}
+	if m.HalfEdge(5, 10) >= 0 || (m.HalfEdge(6, 9) < 0 && m.HalfEdge(9, 6) < 0) {
+		t.Errorf("FAIL flipped edge")
+	}
+	if err := m.check(); err != nil {
+		t.Fatal(err)
+	}
+	// split an interior and a boundary edge
+	if v := m.SplitEdge(m.HalfEdge(5, 6), V3{1.5, 1, 0}); v < 0 {
+		t.Fatal("FAIL split")
+	}
+	if v := m.SplitEdge(m.HalfEdge(0, 1), V3{0.5, 0, 0}); v < 0 || !m.IsBoundaryVertex(v) {
+		t.Fatal("FAIL boundary split")
+	}
+	if err := m.check(); err != nil {
+		t.Fatal(err)
+	}
+	if n := liveFaces(m); n != 18+3 {
+		t.Errorf("FAIL %d faces", n)
+	}
+	// collapse edges until no more can be collapsed
+	for n := 0; ; n++ {
+		collapsed := false
+		for e := range m.edge {
+			if m.edge[e].from >= 0 && m.CollapseEdge(e, m.Vertex(m.edge[e].from)) {
+				collapsed = true
+				break
+			}
+		}
+		if err := m.check(); err != nil {
+			t.Fatalf("collapse %d: %s", n, err)
+		}
+		if !collapsed {
+			break
+		}
+	}
+	if n := liveFaces(m); n != 1 {
+		t.Errorf("FAIL %d faces after collapse", n)
+	}
+
+	// closed mesh
+	s := Sphere3D(1)
+	m, err = NewHalfEdgeMesh(marchingCubes(s, s.BoundingBox().ScaleAboutCenter(1.1), 0.2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsClosed() || m.check() != nil {
+		t.Errorf("FAIL sphere mesh")
+	}
+	vs, fs := m.Indexed()
+	edges := 0
+	for _, f := range fs {
+		edges += len(f)
+	}
+	if euler := len(vs) - edges/2 + len(fs); euler != 2 {
+		t.Errorf("FAIL euler characteristic %d", euler)
+	}
+	for i := 0; i < 200; i++ {
+		e := int(randomRange(0, float64(len(m.edge))))
+		if m.edge[e].from >= 0 {
+			a, b := m.EdgeVertices(e)
+			m.CollapseEdge(e, m.Vertex(a).Add(m.Vertex(b)).MulScalar(0.5))
+		}
+	}
+	if !m.IsClosed() || m.check() != nil || liveFaces(m) >= len(fs) {
+		t.Errorf("FAIL collapsed sphere mesh")
+	}
+
+	// non-manifold
+	if _, err := NewHalfEdgeMeshIndexed(vertex, [][]int{{0, 1, 2}, {0, 1, 3}}); err == nil {
+		t.Errorf("FAIL expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_ConvexHull3D(t *testing.T) {
+	// a cube with points inside it
+	bb := Box3{V3{-1, -2, -3}, V3{1, 2, 3}}
+	vs := append(bb.RandomSet(1000), bb.Vertices()...)
+	mesh, s, err := ConvexHull3D(vs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mesh) != 12 {
+		t.Errorf("FAIL %d triangles", len(mesh))
+	}
+	if !s.BoundingBox().Equals(bb, tolerance) {
+		t.Errorf("FAIL bounding box %v", s.BoundingBox())
+	}
+	for _, p := range []V3{{0, 0, 0}, {2, 0, 0}, {0, 0, -4}, {0.5, 1, 2}} {
+		if d := s.Evaluate(p); Abs(d-Box3D(bb.Size(), 0).Evaluate(p)) > tolerance {
+			t.Errorf("FAIL distance %v %f", p, d)
+		}
+	}
+
+	// points on a sphere are all on the hull
+	var sphere V3Set
+	for _, d := range fibonacciSphere(200) {
+		sphere = append(sphere, d.MulScalar(2))
+	}
+	mesh, s, err = ConvexHull3D(sphere)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mesh) != 2*len(sphere)-4 {
+		t.Errorf("FAIL %d triangles", len(mesh))
+	}
+	m, err := NewHalfEdgeMesh(mesh)
+	if err != nil || !m.IsClosed() {
+		t.Errorf("FAIL hull is not closed")
+	}
+	for _, tr := range mesh {
+		// outward facing
+		if tr.Normal().Dot(tr.V[0]) <= 0 {
+			t.Errorf("FAIL inward triangle")
+			break
+		}
+	}
+	for _, p := range sphere {
+		if Abs(s.Evaluate(p)) > 1e-9 {
+			t.Errorf("FAIL point %v not on the hull", p)
+		}
+	}
+
+	// degenerate point sets
+	if _, _, err := ConvexHull3D(V3Set{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {1, 1, 0}}); err == nil {
+		t.Errorf("FAIL expected an error")
+	}
+	if _, _, err := ConvexHull3D(V3Set{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}); err == nil {
+		t.Errorf("FAIL expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_PolygonGeometry(t *testing.T) {
+	// a concave polygon (counter-clockwise)
+	c := []V2{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 8}, {8, 8}, {8, 2}, {0, 2}}
+	if a := PolygonArea(c); a != 52 {
+		t.Errorf("FAIL area %f", a)
+	}
+	if !PolygonIsCCW(c) || PolygonIsCCW(reverseV2(c)) || PolygonArea(reverseV2(c)) != -52 {
+		t.Errorf("FAIL orientation")
+	}
+	// closed with a repeated vertex, away from the origin
+	d := []V2{{1e6, 1e6}, {1e6 + 1, 1e6}, {1e6 + 1, 1e6 + 1}, {1e6, 1e6}}
+	if a := PolygonArea(d); a != 0.5 {
+		t.Errorf("FAIL area %f", a)
+	}
+	tests := []struct {
+		p   V2
+		loc PointLocation
+	}{
+		{V2{5, 1}, PointInside},
+		{V2{5, 5}, PointOutside},
+		{V2{9, 5}, PointInside},
+		{V2{-1, 5}, PointOutside},
+		{V2{5, 0}, PointOnBoundary},
+		{V2{8, 5}, PointOnBoundary},
+		{V2{10, 10}, PointOnBoundary},
+		{V2{0, 5}, PointOutside},
+		{V2{4, 2}, PointOnBoundary},
+	}
+	for _, x := range tests {
+		if loc := PointInPolygon(c, x.p); loc != x.loc {
+			t.Errorf("FAIL %v: %d != %d", x.p, loc, x.loc)
+		}
+	}
+	if WindingNumber(c, V2{9, 5}) != 1 || WindingNumber(reverseV2(c), V2{9, 5}) != -1 {
+		t.Errorf("FAIL winding number")
+	}
+	// twice wound
+	twice := []V2{{0, 0}, {2, 0}, {2, 2}, {0, 2}, {0, 0}, {2, 0}, {2, 2}, {0, 2}}
+	if WindingNumber(twice, V2{1, 1}) != 2 {
+		t.Errorf("FAIL winding number")
+	}
+
+	// segment intersection
+	if p, ok := SegmentIntersection(V2{0, 0}, V2{2, 2}, V2{0, 2}, V2{2, 0}); !ok || !p.Equals(V2{1, 1}, tolerance) {
+		t.Errorf("FAIL crossing %v", p)
+	}
+	if _, ok := SegmentIntersection(V2{0, 0}, V2{1, 1}, V2{0, 2}, V2{2, 4}); ok {
+		t.Errorf("FAIL no crossing")
+	}
+	if p, ok := SegmentIntersection(V2{0, 0}, V2{2, 0}, V2{1, 0}, V2{1, 5}); !ok || p != (V2{1, 0}) {
+		t.Errorf("FAIL touching %v", p)
+	}
+	if p, ok := SegmentIntersection(V2{0, 0}, V2{4, 0}, V2{5, 0}, V2{2, 0}); !ok || p != (V2{2, 0}) {
+		t.Errorf("FAIL overlapping %v", p)
+	}
+	if _, ok := SegmentIntersection(V2{0, 0}, V2{1, 0}, V2{2, 0}, V2{3, 0}); ok {
+		t.Errorf("FAIL collinear disjoint")
+	}
+	if !SegmentsIntersect(V2{0, 0}, V2{1, 0}, V2{1, 0}, V2{2, 3}) {
+		t.Errorf("FAIL shared end point")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_TriangleExport(t *testing.T) {
+	vs, ts, err := Triangulate2d([]V2{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, []V2{{3, 3}, {3, 7}, {7, 7}, {7, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each edge once: 8 boundary edges, 2 per triangle inside
+	edges := ts.Edges()
+	if len(edges) != (3*len(ts)+8)/2 {
+		t.Errorf("FAIL %d edges", len(edges))
+	}
+	dir := t.TempDir()
+	if err := SaveTrianglesDXF(filepath.Join(dir, "t.dxf"), vs, ts); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "t.dxf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "\nLINE\n"); n != len(edges) {
+		t.Errorf("FAIL %d dxf lines", n)
+	}
+
+	// lifted to 3d
+	vs3 := vs.Lift(func(p V2) float64 { return p.X + p.Y })
+	if vs3[2] != (V3{10, 10, 20}) {
+		t.Errorf("FAIL lift %v", vs3[2])
+	}
+	path := filepath.Join(dir, "t.stl")
+	if err := SaveTrianglesSTL(path, vs3, ts); err != nil {
+		t.Fatal(err)
+	}
+	mesh, err := LoadSTL(path)
+	if err != nil || len(mesh) != len(ts) {
+		t.Errorf("FAIL stl %d triangles", len(mesh))
+	}
+	var buf bytes.Buffer
+	if err := EncodeTrianglesOBJ(&buf, vs3, ts); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "v "); n != len(vs3) {
+		t.Errorf("FAIL %d obj vertices", n)
+	}
+	mesh, err = DecodeOBJ(&buf)
+	if err != nil || len(mesh) != len(ts) {
+		t.Errorf("FAIL obj %d triangles", len(mesh))
+	}
+
+	// bad vertex index
+	if err := SaveTrianglesSTL(path, vs3, TriangleISet{{0, 1, 99}}); err == nil {
+		t.Errorf("FAIL expected an error")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_DelaunayDegenerate(t *testing.T) {
+	// super triangles enclose the points
+	for _, vs := range []V2Set{
+		{{-5, -5}},
+		{{100, 3}},
+		{{0, 0}},
+		{{2, 2}, {2, 2}, {2, 2}},
+		{{0, 0}, {1, 0}, {2, 0}},
+	} {
+		st, err := vs.SuperTriangle()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if orient2d(st[0], st[1], st[2]) == 0 {
+			t.Errorf("FAIL degenerate super triangle %v", st)
+		}
+		for _, p := range vs {
+			if PointInPolygon(st[:], p) != PointInside {
+				t.Errorf("FAIL %v not in super triangle %v", p, st)
+			}
+		}
+	}
+	if _, err := (V2Set{}).SuperTriangle(); err == nil {
+		t.Errorf("FAIL expected an error")
+	}
+
+	// no triangles
+	for _, vs := range []V2Set{
+		{{1, 1}},
+		{{0, 0}, {1, 0}},
+		{{2, 2}, {2, 2}, {2, 2}},
+		{{0, 0}, {1, 1}, {2, 2}, {3, 3}, {1, 1}},
+	} {
+		ts, err := vs.Delaunay2d()
+		if err != nil || len(ts) != 0 {
+			t.Errorf("FAIL %v: %v %v", vs, ts, err)
+		}
+		ts, err = vs.Delaunay2dSlow()
+		if err != nil || len(ts) != 0 {
+			t.Errorf("FAIL slow %v: %v %v", vs, ts, err)
+		}
+	}
+	if _, err := (V2Set{}).Delaunay2d(); err == nil {
+		t.Errorf("FAIL expected an error")
+	}
+
+	// duplicates are ignored
+	vs := V2Set{{0, 0}, {1, 0}, {0, 1}, {0, 0}, {1, 0}, {0, 1}, {1, 1}}
+	ts, err := vs.Delaunay2d()
+	if area, ok := triangulationArea(vs, ts); err != nil || len(ts) != 2 || !ok || area != 1 {
+		t.Errorf("FAIL duplicates %v %v", ts, err)
+	}
+
+	// nearly collinear points, all on the hull
+	for _, vs := range []V2Set{
+		{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {1.5, 1e-9}},
+		{{0, 0}, {1e6, 0}, {2e6, 1}, {3e6, 0}, {1.5e6, -1}},
+		{{0, 0}, {1, 1e-12}, {2, 0}, {3, 1e-12}, {4, 0}, {5, 1e-12}},
+	} {
+		ts, err := vs.Delaunay2d()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a hull edge has one triangle
+		count := make(map[EdgeI]int)
+		for _, x := range ts {
+			for j := 0; j < 3; j++ {
+				count[EdgeI{x[j], x[(j+1)%3]}.undirected()]++
+			}
+		}
+		hull := 0
+		for _, n := range count {
+			if n == 1 {
+				hull++
+			}
+		}
+		if len(ts) != 2*len(vs)-2-hull {
+			t.Errorf("FAIL %v: %d triangles, %d hull edges", vs, len(ts), hull)
+		}
+		if _, ok := triangulationArea(vs, ts); !ok {
+			t.Errorf("FAIL %v: bad winding", vs)
+		}
+	}
+
+	// points on a grid (many cocircular points)
+	vs = nil
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			vs = append(vs, V2{float64(i), float64(j)})
+		}
+	}
+	ts, err = vs.Delaunay2d()
+	if area, ok := triangulationArea(vs, ts); err != nil || len(ts) != 162 || !ok || area != 81 {
+		t.Errorf("FAIL grid %d triangles, area %f", len(ts), area)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_ThreadRegistry(t *testing.T) {
+	custom := &ThreadParameters{
+		Name:         "custom_7x0.9",
+		Radius:       3.5,
+		Pitch:        0.9,
+		HexFlat2Flat: 11,
+		Units:        "mm",
+	}
+	if err := RegisterThread(custom); err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	// the parameters are copied
+	custom.Pitch = 2
+	x, err := ThreadLookup("custom_7x0.9")
+	if err != nil || x.Pitch != 0.9 {
+		t.Errorf("FAIL lookup %v %s", x, err)
+	}
+	if _, err := Bolt(&BoltParms{Thread: "custom_7x0.9", Style: "hex", TotalLength: 20}); err != nil {
+		t.Errorf("FAIL bolt %s", err)
+	}
+	if _, err := Nut(&NutParms{Thread: "custom_7x0.9", Style: "hex"}); err != nil {
+		t.Errorf("FAIL nut %s", err)
+	}
+	// duplicate names
+	if RegisterThread(custom) == nil || RegisterThread(&ThreadParameters{Name: "M8x1.25", Radius: 4, Pitch: 1.25, Units: "mm"}) == nil {
+		t.Error("FAIL registered a duplicate name")
+	}
+	// bad parameters
+	bad := []ThreadParameters{
+		{Name: "", Radius: 1, Pitch: 1, Units: "mm"},
+		{Name: "a", Radius: 0, Pitch: 1, Units: "mm"},
+		{Name: "a", Radius: 1, Pitch: -1, Units: "mm"},
+		{Name: "a", Radius: 1, Pitch: 1, Units: "furlong"},
+	}
+	for i := range bad {
+		if RegisterThread(&bad[i]) == nil {
+			t.Errorf("FAIL registered %v", bad[i])
+		}
+	}
+	if err := UnregisterThread("custom_7x0.9"); err != nil {
+		t.Errorf("FAIL %s", err)
+	}
+	if _, err := ThreadLookup("custom_7x0.9"); err == nil {
+		t.Error("FAIL found an unregistered thread")
+	}
+	if UnregisterThread("custom_7x0.9") == nil {
+		t.Error("FAIL unregistered a missing thread")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_TaperedThread(t *testing.T) {
+	for _, name := range []string{"npt_1/2", "R1/2"} {
+		k, err := ThreadLookup(name)
+		if err != nil {
+			t.Fatalf("FAIL %s", err)
+		}
+		if !EqualFloat64(k.Taper, math.Atan(1.0/32.0), tolerance) {
+			t.Errorf("FAIL %s taper %f", name, k.Taper)
+		}
+		l := 10 * k.Pitch
+		s, err := k.Thread3D(l, 0, "external")
+		if err != nil {
+			t.Fatalf("FAIL %s", err)
+		}
+		depth := 0.8 * k.Pitch
+		margin := 0.1 * l * math.Tan(k.Taper)
+		for _, z := range []float64{-0.4 * l, 0, 0.4 * l} {
+			r := k.Radius + z*math.Tan(k.Taper)
+			// the core is solid
+			if s.Evaluate(V3{0, 0, z}) >= 0 {
+				t.Errorf("FAIL %s core at z = %f", name, z)
+			}
+			for i := 0; i < 8; i++ {
+				a := float64(i) * Tau / 8
+				if d := s.Evaluate(PolarToXY(r+margin, a).ToV3(z)); d <= 0 {
+					t.Errorf("FAIL %s outside the crest at z = %f", name, z)
+				}
+				if d := s.Evaluate(PolarToXY(r-depth-margin, a).ToV3(z)); d >= 0 {
+					t.Errorf("FAIL %s inside the root at z = %f", name, z)
+				}
+			}
+		}
+		if _, err := k.Thread3D(l, 0.1*k.Pitch, "internal"); err != nil {
+			t.Errorf("FAIL %s", err)
+		}
+	}
+	// registered tapered threads
+	if err := RegisterThread(&ThreadParameters{Name: "taper_test", Radius: 5, Pitch: 1, Units: "mm", Form: "npt", Taper: DtoR(3)}); err != nil {
+		t.Errorf("FAIL %s", err)
+	}
+	UnregisterThread("taper_test")
+	if RegisterThread(&ThreadParameters{Name: "taper_test", Radius: 5, Pitch: 1, Units: "mm", Form: "square"}) == nil {
+		t.Error("FAIL registered an unknown thread form")
+	}
+	if _, err := TaperedScrew3D(NPTThread(5, 1), 10, DtoR(60), 1, 1); err == nil {
+		t.Error("FAIL accepted a bad taper")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_TrapezoidalThread(t *testing.T) {
+	k, err := ThreadLookup("Tr8x8(P2)")
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	if k.Radius != 4 || k.Pitch != 2 || k.Starts != 4 {
+		t.Errorf("FAIL %v", k)
+	}
+	// a 4 start thread is symmetric for a quarter turn, a single start thread isn't
+	multi, err := k.Thread3D(20, 0, "external")
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	k, _ = ThreadLookup("Tr8x2")
+	single, err := k.Thread3D(20, 0, "external")
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	m := RotateZ(DtoR(90))
+	asymmetric := false
+	for i := 0; i < 100; i++ {
+		p := V3{randomRange(-5, 5), randomRange(-5, 5), randomRange(-5, 5)}
+		if !EqualFloat64(multi.Evaluate(p), multi.Evaluate(m.MulPosition(p)), tolerance) {
+			t.Errorf("FAIL 4 start thread is not symmetric at %v", p)
+		}
+		if !EqualFloat64(single.Evaluate(p), single.Evaluate(m.MulPosition(p)), 1e-3) {
+			asymmetric = true
+		}
+	}
+	if !asymmetric {
+		t.Error("FAIL single start thread is symmetric")
+	}
+	// the thread depth is half the pitch
+	for _, s := range []SDF2{AcmeThread(5, 1), TrapezoidalThread(5, 1)} {
+		if d := s.Evaluate(V2{0, 4.9}); !EqualFloat64(d, -0.1, tolerance) {
+			t.Errorf("FAIL crest distance %f", d)
+		}
+		if d := s.Evaluate(V2{0.5, 4.5}); !EqualFloat64(d, 0, tolerance) {
+			t.Errorf("FAIL root distance %f", d)
+		}
+	}
+	// no hex head
+	if _, err := Nut(&NutParms{Thread: "Tr8x8(P2)", Style: "hex"}); err == nil {
+		t.Error("FAIL made a nut without a hex head size")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_ButtressThread(t *testing.T) {
+	r, pitch, depth := 10.0, 2.0, 1.5
+	t0, t1 := DtoR(5), DtoR(40)
+	s := ButtressThread(r, pitch, depth, t0, t1)
+	x0 := depth * math.Tan(t0)
+	x1 := depth * math.Tan(t1)
+	w := 0.5 * (pitch - x0 - x1)
+	// points on the profile
+	on := []V2{
+		{0, r},                                   // crest
+		{0.5*w + 0.5*x0, r - 0.5*depth},          // load flank
+		{-0.5*w - 0.5*x1, r - 0.5*depth},         // clearance flank
+		{0.5*pitch - 0.5*x1 + 0.5*x0, r - depth}, // root
+	}
+	for _, p := range on {
+		if d := s.Evaluate(p); Abs(d) > tolerance {
+			t.Errorf("FAIL %v distance %f", p, d)
+		}
+	}
+	if d := s.Evaluate(V2{0, r - 0.5*depth}); d >= 0 {
+		t.Errorf("FAIL tooth distance %f", d)
+	}
+	// DIN 513
+	k, err := ThreadLookup("S20x4")
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	if _, err := k.Thread3D(20, 0.1, "internal"); err != nil {
+		t.Errorf("FAIL %s", err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_LeftHandThread(t *testing.T) {
+	// a left hand screw is a right hand screw mirrored in y (for symmetric profiles)
+	profile := ISOThread(5, 1, "external")
+	rh := RightHandScrew3D(profile, 10, 1, 2)
+	lh := LeftHandScrew3D(profile, 10, 1, 2)
+	k, _ := ThreadLookup("M10x1.5")
+	x := *k
+	x.LeftHand = true
+	lhThread, err := x.Thread3D(10, 0, "external")
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	rhThread, _ := k.Thread3D(10, 0, "external")
+	differ := false
+	for i := 0; i < 100; i++ {
+		p := V3{randomRange(-6, 6), randomRange(-6, 6), randomRange(-6, 6)}
+		q := V3{p.X, -p.Y, p.Z}
+		if !EqualFloat64(lh.Evaluate(p), rh.Evaluate(q), tolerance) {
+			t.Errorf("FAIL screw at %v", p)
+		}
+		if !EqualFloat64(lhThread.Evaluate(p), rhThread.Evaluate(q), tolerance) {
+			t.Errorf("FAIL thread at %v", p)
+		}
+		if !EqualFloat64(lh.Evaluate(p), rh.Evaluate(p), 1e-3) {
+			differ = true
+		}
+	}
+	if !differ {
+		t.Error("FAIL left and right hand screws are the same")
+	}
+	// the database entry is not changed by a left hand bolt
+	if _, err := Bolt(&BoltParms{Thread: "M10x1.5", Style: "hex", TotalLength: 20, LeftHand: true}); err != nil {
+		t.Errorf("FAIL %s", err)
+	}
+	if _, err := Nut(&NutParms{Thread: "M10x1.5", Style: "hex", LeftHand: true}); err != nil {
+		t.Errorf("FAIL %s", err)
+	}
+	if k.LeftHand {
+		t.Error("FAIL the thread database was changed")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Watcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "params.json")
+	write := func(s string) {
+		if err := os.WriteFile(path, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	type parms struct {
+		Radius float64
+		Height float64
+	}
+	p := parms{Radius: 1, Height: 2}
+	builds := make(chan parms, 10)
+	build := JSONBuild(&p, func() (SDF3, error) {
+		builds <- p
+		return Cylinder3D(p.Height, p.Radius, 0), nil
+	})
+	wait := func(want parms) {
+		select {
+		case got := <-builds:
+			if got != want {
+				t.Errorf("FAIL built %v, want %v", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("FAIL no rebuild for %v", want)
+		}
+	}
+
+	write(`{"Radius": 3}`)
+	w := NewWatcher(path, build, NewPreviewServer(8))
+	w.SetInterval(5 * time.Millisecond)
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(stop)
+	}()
+	wait(parms{Radius: 3, Height: 2})
+
+	// rewrite, a deleted parameter goes back to its initial value
+	write(`{"Height": 5.5}`)
+	wait(parms{Radius: 1, Height: 5.5})
+
+	// delete and recreate (as for an editor's atomic save)
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("FAIL Run returned %v", err)
+	default:
+	}
+	write(`{"Radius": 4, "Height": 7}`)
+	wait(parms{Radius: 4, Height: 7})
+
+	close(stop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("FAIL Run returned %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("FAIL Run did not stop")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_QuadRemesh(t *testing.T) {
+	for _, s := range []SDF3{Sphere3D(10), Box3D(V3{10, 15, 20}, 2)} {
+		mesh := RenderMesh(s, 30)
+		vertex, face := indexMesh(mesh)
+		m := QuadRemesh(mesh)
+		if len(m.Vertex) != len(vertex) {
+			t.Errorf("FAIL %d vertices, want %d", len(m.Vertex), len(vertex))
+		}
+		// each input triangle is in exactly one quad or triangle
+		key := func(a, b, c int) [3]int {
+			k := []int{a, b, c}
+			sort.Ints(k)
+			return [3]int{k[0], k[1], k[2]}
+		}
+		count := make(map[[3]int]int)
+		for _, f := range face {
+			count[key(f[0], f[1], f[2])]++
+		}
+		normal := func(a, b, c int) V3 {
+			v := m.Vertex
+			return v[b].Sub(v[a]).Cross(v[c].Sub(v[a])).Normalize()
+		}
+		edges := make(map[EdgeI]int)
+		for _, f := range m.Face {
+			switch len(f) {
+			case 3:
+				count[key(f[0], f[1], f[2])]--
+			case 4:
+				// the quad a, d, b, c is made from triangles a, b, c and b, a, d
+				a, d, b, c := f[0], f[1], f[2], f[3]
+				count[key(a, b, c)]--
+				count[key(b, a, d)]--
+				if normal(a, b, c).Dot(normal(b, a, d)) < quadMinPlanarity-tolerance {
+					t.Errorf("FAIL non-planar quad %v", f)
+				}
+			default:
+				t.Fatalf("FAIL face with %d vertices", len(f))
+			}
+			// outward facing
+			var n, center V3
+			for i := range f {
+				p0, p1 := m.Vertex[f[i]], m.Vertex[f[(i+1)%len(f)]]
+				n = n.Add(p0.Cross(p1))
+				center = center.Add(p0)
+				edges[EdgeI{f[i], f[(i+1)%len(f)]}]++
+			}
+			if n.Dot(center) <= 0 {
+				t.Errorf("FAIL inward facing face %v", f)
+			}
+		}
+		for k, n := range count {
+			if n != 0 {
+				t.Errorf("FAIL triangle %v used %d more times than in the input", k, -n)
+			}
+		}
+		// consistent winding, each edge is used once in each direction
+		for e, n := range edges {
+			if n != 1 || edges[EdgeI{e[1], e[0]}] != 1 {
+				t.Errorf("FAIL edge %v used %d/%d times", e, n, edges[EdgeI{e[1], e[0]}])
+			}
+		}
+		if m.Quads() < len(m.Face)/4 {
+			t.Errorf("FAIL %d quads of %d faces", m.Quads(), len(m.Face))
+		}
+		// OBJ output
+		path := filepath.Join(t.TempDir(), "quad.obj")
+		if err := RenderQuadOBJ(s, 30, path); err != nil {
+			t.Fatalf("FAIL %s", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var nv, nf, nq int
+		for _, l := range strings.Split(string(data), "\n") {
+			x := strings.Fields(l)
+			if len(x) == 0 {
+				continue
+			}
+			switch x[0] {
+			case "v":
+				nv++
+			case "f":
+				nf++
+				if len(x) == 5 {
+					nq++
+				}
+			}
+		}
+		if nv != len(m.Vertex) || nf != len(m.Face) || nq != m.Quads() {
+			t.Errorf("FAIL OBJ has %d vertices, %d faces, %d quads, want %d, %d, %d", nv, nf, nq, len(m.Vertex), len(m.Face), m.Quads())
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Metadata(t *testing.T) {
+	type parms struct {
+		Name string
+		Size V3
+	}
+	p := parms{"block", V3{10, 20, 30}}
+	SetMetadata(true, &p)
+	defer SetMetadata(false, nil)
+
+	s := Box3D(p.Size, 0)
+	path := filepath.Join(t.TempDir(), "block.stl")
+	if err := RenderSTLContext(context.Background(), s, 20, path, nil); err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	mesh, err := LoadSTL(path)
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	data, err := os.ReadFile(path + ".json")
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	var md struct {
+		File        string
+		BoundingBox Box3 `json:"bounding_box"`
+		Volume      float64
+		Triangles   int
+		MeshCells   int `json:"mesh_cells"`
+		Parameters  parms
+	}
+	if err := json.Unmarshal(data, &md); err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	if md.File != "block.stl" || md.MeshCells != 20 {
+		t.Errorf("FAIL file %s, mesh cells %d", md.File, md.MeshCells)
+	}
+	if !md.BoundingBox.Equals(s.BoundingBox(), tolerance) {
+		t.Errorf("FAIL bounding box %v", md.BoundingBox)
+	}
+	if md.Triangles != len(mesh) || md.Triangles == 0 {
+		t.Errorf("FAIL %d triangles, %d in the STL file", md.Triangles, len(mesh))
+	}
+	if !EqualFloat64(md.Volume, 6000, 0.02) {
+		t.Errorf("FAIL volume %f", md.Volume)
+	}
+	if md.Parameters != p {
+		t.Errorf("FAIL parameters %v", md.Parameters)
+	}
+
+	// no sidecar file when disabled
+	SetMetadata(false, nil)
+	path = filepath.Join(t.TempDir(), "block.stl")
+	if err := RenderSTLContext(context.Background(), s, 20, path, nil); err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	if _, err := os.Stat(path + ".json"); !os.IsNotExist(err) {
+		t.Error("FAIL wrote a sidecar file when disabled")
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_PreviewServer(t *testing.T) {
+	ps := NewPreviewServer(20)
+	srv := httptest.NewServer(ps)
+	defer srv.Close()
+
+	get := func(path string) (*http.Response, []byte) {
+		rsp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("FAIL %s", err)
+		}
+		defer rsp.Body.Close()
+		body, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatalf("FAIL %s", err)
+		}
+		return rsp, body
+	}
+
+	// no mesh yet
+	if rsp, body := get("/version"); rsp.StatusCode != http.StatusOK || string(body) != "0" {
+		t.Errorf("FAIL /version %d %q", rsp.StatusCode, body)
+	}
+	if rsp, _ := get("/mesh.stl"); rsp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("FAIL /mesh.stl %d", rsp.StatusCode)
+	}
+
+	if err := ps.Update(Sphere3D(10)); err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	rsp, body := get("/")
+	if rsp.StatusCode != http.StatusOK || !strings.HasPrefix(rsp.Header.Get("Content-Type"), "text/html") || !strings.Contains(string(body), "<html>") {
+		t.Errorf("FAIL / %d %s", rsp.StatusCode, rsp.Header.Get("Content-Type"))
+	}
+	if rsp, _ := get("/nothing"); rsp.StatusCode != http.StatusNotFound {
+		t.Errorf("FAIL /nothing %d", rsp.StatusCode)
+	}
+	rsp, body = get("/version")
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "text/plain" || string(body) != "1" {
+		t.Errorf("FAIL /version %d %s %q", rsp.StatusCode, rsp.Header.Get("Content-Type"), body)
+	}
+	rsp, body = get("/mesh.stl")
+	if rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "model/stl" || rsp.Header.Get("X-Mesh-Version") != "1" {
+		t.Errorf("FAIL /mesh.stl %d %s %s", rsp.StatusCode, rsp.Header.Get("Content-Type"), rsp.Header.Get("X-Mesh-Version"))
+	}
+	// the STL round trips
+	mesh, err := DecodeSTL(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	if len(mesh) != len(RenderMesh(Sphere3D(10), 20)) {
+		t.Errorf("FAIL %d triangles", len(mesh))
+	}
+	var buf bytes.Buffer
+	if err := EncodeSTL(&buf, mesh); err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	mesh2, err := DecodeSTL(&buf)
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	if len(mesh2) != len(mesh) {
+		t.Fatalf("FAIL %d triangles after the round trip, want %d", len(mesh2), len(mesh))
+	}
+	for i := range mesh {
+		if mesh[i].V != mesh2[i].V {
+			t.Fatalf("FAIL triangle %d %v, want %v", i, mesh2[i].V, mesh[i].V)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Turntable(t *testing.T) {
+	// not symmetric about the z-axis
+	s := Union3D(Box3D(V3{10, 10, 10}, 1), Transform3D(Sphere3D(4), Translate3d(V3{8, 0, 0})))
+	k := DefaultRayMarchParms(s, V2i{48, 32})
+	k.Shadows = false
+	const frames = 4
+
+	img := RayMarchRGBA(s, k)
+	if img.Bounds() != image.Rect(0, 0, 48, 32) {
+		t.Fatalf("FAIL image bounds %v", img.Bounds())
+	}
+	hits := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != k.Background.R || img.Pix[i+1] != k.Background.G || img.Pix[i+2] != k.Background.B {
+			hits++
+		}
+	}
+	if hits == 0 {
+		t.Error("FAIL the shape is not in the image")
+	}
+
+	imgs := TurntableFrames(s, k, frames)
+	if len(imgs) != frames {
+		t.Fatalf("FAIL %d frames", len(imgs))
+	}
+	if !bytes.Equal(imgs[0].Pix, img.Pix) {
+		t.Error("FAIL the first frame is not the camera view")
+	}
+	for i := 1; i < frames; i++ {
+		if imgs[i].Bounds() != img.Bounds() {
+			t.Errorf("FAIL frame %d bounds %v", i, imgs[i].Bounds())
+		}
+		if bytes.Equal(imgs[i].Pix, imgs[i-1].Pix) {
+			t.Errorf("FAIL frames %d and %d are the same", i-1, i)
+		}
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "turntable.gif")
+	if err := RenderTurntableGIF(s, k, frames, 10, path); err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	if len(anim.Image) != frames || anim.Config.Width != 48 || anim.Config.Height != 32 {
+		t.Errorf("FAIL gif has %d %dx%d frames", len(anim.Image), anim.Config.Width, anim.Config.Height)
+	}
+
+	if err := RenderTurntablePNG(s, k, frames, filepath.Join(dir, "frame%02d.png")); err != nil {
+		t.Fatalf("FAIL %s", err)
+	}
+	for i := 0; i < frames; i++ {
+		f, err := os.Open(filepath.Join(dir, fmt.Sprintf("frame%02d.png", i)))
+		if err != nil {
+			t.Fatalf("FAIL %s", err)
+		}
+		cfg, err := png.DecodeConfig(f)
+		f.Close()
+		if err != nil || cfg.Width != 48 || cfg.Height != 32 {
+			t.Errorf("FAIL frame %d %v %s", i, cfg, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+//-----------------------------------------------------------------------------
+/*
+
+Cross Sections
+
+Intersect an SDF3 with a plane and export the profile as a 2D drawing.
+This is useful for documenting internal features (e.g. counterbored holes).
+
+The 2D axes of the section are chosen as for Slice2D. E.g. a section with a
+normal along the z-axis has the same x/y axes as the SDF3.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"context"
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+
+// Section2D returns the SDF2 for a planar cross section of an SDF3.
+// An error is returned if the plane doesn't intersect the SDF3 bounding box.
+func Section2D(
+	s SDF3, // SDF3 to be sectioned
+	a V3, // point on the section plane
+	n V3, // normal to the section plane
+) (SDF2, error) {
+	if n.Length() == 0 {
+		return nil, errors.New("section plane normal is zero")
+	}
+	if len(slicePoints(s.BoundingBox(), a, n.Normalize())) == 0 {
+		return nil, errors.New("section plane doesn't intersect the object")
+	}
+	return Slice2D(s, a, n), nil
+}
+
+// RenderSectionDXF renders a planar cross section of an SDF3 as a DXF file.
+func RenderSectionDXF(
+	s SDF3, // SDF3 to be sectioned
+	a V3, // point on the section plane
+	n V3, // normal to the section plane
+	meshCells int, // number of cells on the longest axis. e.g 200
+	path string, // path to filename
+) error {
+	s2, err := Section2D(s, a, n)
+	if err != nil {
+		return err
+	}
+	return RenderDXFContext(context.Background(), s2, meshCells, path, nil)
+}
+
+// RenderSectionSVG renders a planar cross section of an SDF3 as an SVG file.
+func RenderSectionSVG(
+	s SDF3, // SDF3 to be sectioned
+	a V3, // point on the section plane
+	n V3, // normal to the section plane
+	meshCells int, // number of cells on the longest axis. e.g 200
+	path string, // path to filename
+	lineStyle string, // SVG line style
+) error {
+	s2, err := Section2D(s, a, n)
+	if err != nil {
+		return err
+	}
+	return RenderSVG(s2, meshCells, path, lineStyle)
+}
+
+//-----------------------------------------------------------------------------
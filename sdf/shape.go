@@ -0,0 +1,658 @@
+//-----------------------------------------------------------------------------
+/*
+
+Text Shaping
+
+Convert a line of text into the glyphs to draw, in display order.
+
+* A character and the combining marks that follow it are a cluster. The
+  marks are drawn on the character.
+* The pre-base vowel signs of Indic scripts are moved in front of the
+  consonant (or conjunct) they follow.
+* Arabic letters take their isolated, initial, medial or final forms.
+* The OpenType glyph substitutions (GSUB) of a font are applied for the
+  contextual forms, conjuncts and ligatures. Single and ligature
+  substitutions are supported, contextual substitutions are not.
+* Fonts without GSUB forms for Arabic use the Unicode presentation forms.
+* Right to left text (E.g. Arabic and Hebrew) is reordered for display with
+  a simplified Unicode bidirectional algorithm (no explicit embeddings).
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"encoding/binary"
+	"sort"
+	"unicode"
+)
+
+//-----------------------------------------------------------------------------
+
+// shapedGlyph is a glyph of a line of text after shaping.
+type shapedGlyph struct {
+	f     *Font     // font with the glyph (E.g. a fallback font)
+	i     fontIndex // glyph index
+	r     rune      // first character of the glyph
+	level int       // bidi level, odd levels are right to left
+	form  int       // arabic joining form
+	mark  bool      // combining mark, drawn on the preceding glyph
+}
+
+// isMark returns true for a combining mark with no width of its own.
+func isMark(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me)
+}
+
+//-----------------------------------------------------------------------------
+// Bidirectional Text
+
+type direction int
+
+const (
+	autoDirection direction = iota // set by the first strong character
+	ltrDirection                   // left to right
+	rtlDirection                   // right to left
+)
+
+type bidiClass int
+
+const (
+	bidiL   bidiClass = iota // left to right
+	bidiR                    // right to left
+	bidiEN                   // number
+	bidiN                    // neutral (whitespace, punctuation)
+	bidiNSM                  // non-spacing mark
+)
+
+// bidiMirror are the characters that are mirrored in right to left text.
+var bidiMirror = map[rune]rune{
+	'(': ')', ')': '(',
+	'<': '>', '>': '<',
+	'[': ']', ']': '[',
+	'{': '}', '}': '{',
+	'«': '»', '»': '«',
+}
+
+// bidiClassOf returns the bidi class of a character.
+func bidiClassOf(r rune) bidiClass {
+	switch {
+	case isMark(r):
+		return bidiNSM
+	case unicode.IsDigit(r):
+		return bidiEN
+	case unicode.In(r, unicode.Hebrew, unicode.Arabic, unicode.Syriac, unicode.Thaana, unicode.Nko):
+		if unicode.IsLetter(r) {
+			return bidiR
+		}
+		return bidiN
+	case unicode.IsLetter(r):
+		return bidiL
+	}
+	return bidiN
+}
+
+// bidiStrong returns the direction of a resolved class, numbers count as
+// right to left when resolving the neutral characters.
+func bidiStrong(c bidiClass) bidiClass {
+	if c == bidiEN {
+		return bidiR
+	}
+	return c
+}
+
+// bidiLevels returns the bidi level of each character in a line of text.
+func bidiLevels(rs []rune, dir direction) []int {
+	cls := make([]bidiClass, len(rs))
+	for i, r := range rs {
+		cls[i] = bidiClassOf(r)
+	}
+	// paragraph level
+	base := 0
+	switch dir {
+	case rtlDirection:
+		base = 1
+	case autoDirection:
+		for _, c := range cls {
+			if c == bidiL {
+				break
+			}
+			if c == bidiR {
+				base = 1
+				break
+			}
+		}
+	}
+	e := bidiL
+	if base == 1 {
+		e = bidiR
+	}
+	// marks take the class of the preceding character
+	prev := e
+	for i, c := range cls {
+		if c == bidiNSM {
+			cls[i] = prev
+		}
+		prev = cls[i]
+	}
+	// numbers in left to right text are left to right
+	strong := e
+	for i, c := range cls {
+		switch c {
+		case bidiL, bidiR:
+			strong = c
+		case bidiEN:
+			if strong == bidiL {
+				cls[i] = bidiL
+			}
+		}
+	}
+	// neutrals between characters with the same direction take that
+	// direction, otherwise the paragraph direction
+	for i := 0; i < len(cls); {
+		if cls[i] != bidiN {
+			i++
+			continue
+		}
+		j := i
+		for j < len(cls) && cls[j] == bidiN {
+			j++
+		}
+		before, after := e, e
+		if i > 0 {
+			before = bidiStrong(cls[i-1])
+		}
+		if j < len(cls) {
+			after = bidiStrong(cls[j])
+		}
+		d := e
+		if before == after {
+			d = before
+		}
+		for k := i; k < j; k++ {
+			cls[k] = d
+		}
+		i = j
+	}
+	// levels
+	levels := make([]int, len(cls))
+	for i, c := range cls {
+		switch {
+		case base == 0 && c == bidiR:
+			levels[i] = 1
+		case base == 0 && c == bidiEN:
+			levels[i] = 2
+		case base == 1 && c != bidiR:
+			levels[i] = 2
+		default:
+			levels[i] = base
+		}
+	}
+	return levels
+}
+
+// bidiReorder returns the display order for items with bidi levels. From the
+// highest level to the lowest odd level, each run of items at that level or
+// higher is reversed.
+func bidiReorder(levels []int) []int {
+	order := make([]int, len(levels))
+	hi, lo := 0, -1
+	for i, l := range levels {
+		order[i] = i
+		if l > hi {
+			hi = l
+		}
+		if lo < 0 || l < lo {
+			lo = l
+		}
+	}
+	if lo%2 == 0 {
+		lo++
+	}
+	for l := hi; l >= lo; l-- {
+		for i := 0; i < len(order); {
+			if levels[order[i]] < l {
+				i++
+				continue
+			}
+			j := i
+			for j < len(order) && levels[order[j]] >= l {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+			}
+			i = j
+		}
+	}
+	return order
+}
+
+//-----------------------------------------------------------------------------
+// Arabic
+
+const (
+	formNone = iota
+	formIsolated
+	formFinal
+	formInitial
+	formMedial
+)
+
+// formFeatures are the GSUB features for the arabic joining forms.
+var formFeatures = map[string]int{
+	"isol": formIsolated,
+	"fina": formFinal,
+	"init": formInitial,
+	"medi": formMedial,
+}
+
+// arabicForms are the presentation forms (isolated, final, initial, medial)
+// of the arabic letters. Right joining letters have no initial or medial
+// forms.
+var arabicForms = map[rune][4]rune{
+	0x0621: {0xfe80, 0, 0, 0},
+	0x0622: {0xfe81, 0xfe82, 0, 0},
+	0x0623: {0xfe83, 0xfe84, 0, 0},
+	0x0624: {0xfe85, 0xfe86, 0, 0},
+	0x0625: {0xfe87, 0xfe88, 0, 0},
+	0x0626: {0xfe89, 0xfe8a, 0xfe8b, 0xfe8c},
+	0x0627: {0xfe8d, 0xfe8e, 0, 0},
+	0x0628: {0xfe8f, 0xfe90, 0xfe91, 0xfe92},
+	0x0629: {0xfe93, 0xfe94, 0, 0},
+	0x062a: {0xfe95, 0xfe96, 0xfe97, 0xfe98},
+	0x062b: {0xfe99, 0xfe9a, 0xfe9b, 0xfe9c},
+	0x062c: {0xfe9d, 0xfe9e, 0xfe9f, 0xfea0},
+	0x062d: {0xfea1, 0xfea2, 0xfea3, 0xfea4},
+	0x062e: {0xfea5, 0xfea6, 0xfea7, 0xfea8},
+	0x062f: {0xfea9, 0xfeaa, 0, 0},
+	0x0630: {0xfeab, 0xfeac, 0, 0},
+	0x0631: {0xfead, 0xfeae, 0, 0},
+	0x0632: {0xfeaf, 0xfeb0, 0, 0},
+	0x0633: {0xfeb1, 0xfeb2, 0xfeb3, 0xfeb4},
+	0x0634: {0xfeb5, 0xfeb6, 0xfeb7, 0xfeb8},
+	0x0635: {0xfeb9, 0xfeba, 0xfebb, 0xfebc},
+	0x0636: {0xfebd, 0xfebe, 0xfebf, 0xfec0},
+	0x0637: {0xfec1, 0xfec2, 0xfec3, 0xfec4},
+	0x0638: {0xfec5, 0xfec6, 0xfec7, 0xfec8},
+	0x0639: {0xfec9, 0xfeca, 0xfecb, 0xfecc},
+	0x063a: {0xfecd, 0xfece, 0xfecf, 0xfed0},
+	0x0641: {0xfed1, 0xfed2, 0xfed3, 0xfed4},
+	0x0642: {0xfed5, 0xfed6, 0xfed7, 0xfed8},
+	0x0643: {0xfed9, 0xfeda, 0xfedb, 0xfedc},
+	0x0644: {0xfedd, 0xfede, 0xfedf, 0xfee0},
+	0x0645: {0xfee1, 0xfee2, 0xfee3, 0xfee4},
+	0x0646: {0xfee5, 0xfee6, 0xfee7, 0xfee8},
+	0x0647: {0xfee9, 0xfeea, 0xfeeb, 0xfeec},
+	0x0648: {0xfeed, 0xfeee, 0, 0},
+	0x0649: {0xfeef, 0xfef0, 0, 0},
+	0x064a: {0xfef1, 0xfef2, 0xfef3, 0xfef4},
+}
+
+// lamAlef are the lam-alef ligatures (isolated, final) for each alef.
+var lamAlef = map[rune][2]rune{
+	0x0622: {0xfef5, 0xfef6},
+	0x0623: {0xfef7, 0xfef8},
+	0x0625: {0xfef9, 0xfefa},
+	0x0627: {0xfefb, 0xfefc},
+}
+
+// arabicJoining returns if a character joins to the preceding and to the
+// following character.
+func arabicJoining(r rune) (bool, bool) {
+	if r == 0x0640 || r == 0x200d {
+		// tatweel, zero width joiner
+		return true, true
+	}
+	f, ok := arabicForms[r]
+	if !ok {
+		return false, false
+	}
+	return f[1] != 0, f[2] != 0
+}
+
+// joiningForms returns the arabic joining form of each character.
+func joiningForms(rs []rune) []int {
+	forms := make([]int, len(rs))
+	prev := -1
+	for i, r := range rs {
+		if isMark(r) {
+			// marks don't break the joining
+			continue
+		}
+		if _, ok := arabicForms[r]; ok {
+			forms[i] = formIsolated
+		}
+		if prev >= 0 {
+			_, next := arabicJoining(rs[prev])
+			back, _ := arabicJoining(r)
+			if next && back {
+				switch forms[prev] {
+				case formIsolated:
+					forms[prev] = formInitial
+				case formFinal:
+					forms[prev] = formMedial
+				}
+				if forms[i] == formIsolated {
+					forms[i] = formFinal
+				}
+			}
+		}
+		prev = i
+	}
+	return forms
+}
+
+// arabicPresentation returns the presentation form for the arabic letter at
+// the start of rs, and the number of letters it replaces.
+func arabicPresentation(rs []rune, form int) (rune, int) {
+	if rs[0] == 0x0644 && len(rs) > 1 {
+		if l, ok := lamAlef[rs[1]]; ok {
+			if form == formIsolated || form == formInitial {
+				return l[0], 2
+			}
+			return l[1], 2
+		}
+	}
+	return arabicForms[rs[0]][form-1], 1
+}
+
+//-----------------------------------------------------------------------------
+// Indic Scripts
+
+// preBaseMatras are the vowel signs of Indic scripts that are written before
+// the consonant they follow.
+var preBaseMatras = map[rune]bool{
+	0x093f: true,                             // devanagari
+	0x09bf: true, 0x09c7: true, 0x09c8: true, // bengali
+	0x0a3f: true,                             // gurmukhi
+	0x0abf: true,                             // gujarati
+	0x0b47: true,                             // oriya
+	0x0bc6: true, 0x0bc7: true, 0x0bc8: true, // tamil
+	0x0d46: true, 0x0d47: true, 0x0d48: true, // malayalam
+}
+
+// viramas join the consonants of Indic scripts into conjuncts.
+var viramas = map[rune]bool{
+	0x094d: true, 0x09cd: true, 0x0a4d: true, 0x0acd: true,
+	0x0b4d: true, 0x0bcd: true, 0x0d4d: true,
+}
+
+// reorderMatras moves the pre-base vowel signs in front of the consonant,
+// or the consonants joined by viramas, that they follow.
+func reorderMatras(rs []rune) {
+	isNukta := func(r rune) bool { return isMark(r) && !viramas[r] }
+	for i := 1; i < len(rs); i++ {
+		if !preBaseMatras[rs[i]] {
+			continue
+		}
+		j := i - 1
+		for j > 0 && isNukta(rs[j]) {
+			j--
+		}
+		for j >= 2 && viramas[rs[j-1]] {
+			j -= 2
+			for j > 0 && isNukta(rs[j]) {
+				j--
+			}
+		}
+		if !unicode.IsLetter(rs[j]) {
+			continue
+		}
+		m := rs[i]
+		copy(rs[j+1:i+1], rs[j:i])
+		rs[j] = m
+	}
+}
+
+//-----------------------------------------------------------------------------
+// OpenType Glyph Substitution
+
+// gsubFeatures are the GSUB features that are applied, in order. The reph
+// form (rphf) is not used, it needs the reph to be moved within a syllable.
+var gsubFeatures = []string{
+	"ccmp",
+	"isol", "fina", "medi", "init", "rlig",
+	"nukt", "akhn", "blwf", "half", "pstf", "vatu", "cjct",
+	"pres", "abvs", "blws", "psts",
+	"liga", "clig",
+}
+
+// gsubTable is the glyph substitution table of an OpenType font.
+type gsubTable struct {
+	b        []byte
+	features map[string][]int // lookup indices for each feature
+	lookups  int              // offset of the lookup list
+}
+
+// parseGSUB returns the GSUB table for the table data, nil if there is no
+// table. The features of all scripts and languages are used.
+func parseGSUB(b []byte) *gsubTable {
+	if len(b) < 10 {
+		return nil
+	}
+	g := &gsubTable{b: b, features: make(map[string][]int)}
+	fl := g.u16(6)
+	g.lookups = g.u16(8)
+	for k := 0; k < g.u16(fl); k++ {
+		r := fl + 2 + 6*k
+		if r+6 > len(b) {
+			break
+		}
+		tag := string(b[r : r+4])
+		ft := fl + g.u16(r+4)
+		for j := 0; j < g.u16(ft+2); j++ {
+			l := g.u16(ft + 4 + 2*j)
+			if !containsInt(g.features[tag], l) {
+				g.features[tag] = append(g.features[tag], l)
+			}
+		}
+	}
+	for _, l := range g.features {
+		sort.Ints(l)
+	}
+	return g
+}
+
+// containsInt returns true if x is in the slice.
+func containsInt(s []int, x int) bool {
+	for _, v := range s {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+// u16 returns the uint16 at an offset in the table, 0 if out of range.
+func (g *gsubTable) u16(ofs int) int {
+	if ofs < 0 || ofs+2 > len(g.b) {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(g.b[ofs:]))
+}
+
+// has returns true if the table has lookups for a feature.
+func (g *gsubTable) has(tag string) bool {
+	return g != nil && len(g.features[tag]) > 0
+}
+
+// coverage returns the coverage index of a glyph, -1 if it is not covered.
+func (g *gsubTable) coverage(ofs int, i fontIndex) int {
+	switch g.u16(ofs) {
+	case 1:
+		for k := 0; k < g.u16(ofs+2); k++ {
+			if g.u16(ofs+4+2*k) == int(i) {
+				return k
+			}
+		}
+	case 2:
+		for k := 0; k < g.u16(ofs+2); k++ {
+			r := ofs + 4 + 6*k
+			if int(i) >= g.u16(r) && int(i) <= g.u16(r+2) {
+				return g.u16(r+4) + int(i) - g.u16(r)
+			}
+		}
+	}
+	return -1
+}
+
+// apply applies the lookups of a feature to the glyphs of font f. A form
+// other than formNone limits the feature to the glyphs with that form.
+func (g *gsubTable) apply(tag string, gs []shapedGlyph, f *Font, form int) []shapedGlyph {
+	for _, l := range g.features[tag] {
+		lo := g.lookups + g.u16(g.lookups+2+2*l)
+		typ := g.u16(lo)
+		ignoreMarks := g.u16(lo+2)&8 != 0
+		for k := 0; k < len(gs); k++ {
+			if gs[k].f != f || (form != formNone && gs[k].form != form) || (ignoreMarks && gs[k].mark) {
+				continue
+			}
+			for s := 0; s < g.u16(lo+4); s++ {
+				st := lo + g.u16(lo+6+2*s)
+				t := typ
+				if t == 7 {
+					// extension
+					t = g.u16(st + 2)
+					st += g.u16(st+4)<<16 | g.u16(st+6)
+				}
+				var ok bool
+				if gs, ok = g.substitute(t, st, gs, k, ignoreMarks); ok {
+					break
+				}
+			}
+		}
+	}
+	return gs
+}
+
+// substitute applies a single (type 1) or ligature (type 4) substitution
+// subtable to the glyph k. It returns true if the glyph was substituted.
+func (g *gsubTable) substitute(t, st int, gs []shapedGlyph, k int, ignoreMarks bool) ([]shapedGlyph, bool) {
+	ci := g.coverage(st+g.u16(st+2), gs[k].i)
+	if ci < 0 {
+		return gs, false
+	}
+	switch t {
+	case 1:
+		switch g.u16(st) {
+		case 1:
+			gs[k].i = fontIndex(uint16(int(gs[k].i) + int(int16(g.u16(st+4)))))
+			return gs, true
+		case 2:
+			if ci < g.u16(st+4) {
+				gs[k].i = fontIndex(g.u16(st + 6 + 2*ci))
+				return gs, true
+			}
+		}
+	case 4:
+		if g.u16(st) != 1 || ci >= g.u16(st+4) {
+			break
+		}
+		ls := st + g.u16(st+6+2*ci)
+		for j := 0; j < g.u16(ls); j++ {
+			lig := ls + g.u16(ls+2+2*j)
+			// match the components
+			pos := []int{k}
+			m := k
+			for c := 1; c < g.u16(lig+2); c++ {
+				m++
+				for m < len(gs) && ignoreMarks && gs[m].mark {
+					m++
+				}
+				if m >= len(gs) || gs[m].f != gs[k].f || int(gs[m].i) != g.u16(lig+4+2*(c-1)) {
+					pos = nil
+					break
+				}
+				pos = append(pos, m)
+			}
+			if pos == nil {
+				continue
+			}
+			gs[k].i = fontIndex(g.u16(lig))
+			// remove the other components, skipped marks are kept
+			for n := len(pos) - 1; n > 0; n-- {
+				gs = append(gs[:pos[n]], gs[pos[n]+1:]...)
+			}
+			return gs, true
+		}
+	}
+	return gs, false
+}
+
+//-----------------------------------------------------------------------------
+
+// shapeLine returns the glyphs for a line of text in display order.
+func shapeLine(f *Font, t *Text, l string) []shapedGlyph {
+	rs := []rune(l)
+	reorderMatras(rs)
+	levels := bidiLevels(rs, t.dir)
+	forms := joiningForms(rs)
+
+	// map the characters to glyphs
+	gs := make([]shapedGlyph, 0, len(rs))
+	for k := 0; k < len(rs); k++ {
+		r := rs[k]
+		if levels[k]%2 == 1 {
+			if m, ok := bidiMirror[r]; ok {
+				r = m
+			}
+		}
+		g := shapedGlyph{r: rs[k], level: levels[k], form: forms[k], mark: isMark(r)}
+		g.f, g.i = f.lookup(r)
+		if g.form != formNone && !g.f.gsub.has("init") {
+			// the font has no arabic GSUB forms, use the presentation forms
+			if p, n := arabicPresentation(rs[k:], g.form); p != 0 {
+				if pf, pi := f.lookup(p); pi != 0 {
+					g.f, g.i, g.form = pf, pi, formNone
+					k += n - 1
+				}
+			}
+		}
+		gs = append(gs, g)
+	}
+
+	// glyph substitutions
+	var fonts []*Font
+	for _, g := range gs {
+		if g.f.gsub != nil && !containsFont(fonts, g.f) {
+			fonts = append(fonts, g.f)
+		}
+	}
+	for _, x := range fonts {
+		for _, tag := range gsubFeatures {
+			gs = x.gsub.apply(tag, gs, x, formFeatures[tag])
+		}
+	}
+
+	// display order, marks stay after their base glyph
+	var clusters [][]shapedGlyph
+	var cl []int
+	for _, g := range gs {
+		if g.mark && len(clusters) > 0 {
+			n := len(clusters) - 1
+			clusters[n] = append(clusters[n], g)
+			continue
+		}
+		clusters = append(clusters, []shapedGlyph{g})
+		cl = append(cl, g.level)
+	}
+	out := make([]shapedGlyph, 0, len(gs))
+	for _, i := range bidiReorder(cl) {
+		out = append(out, clusters[i]...)
+	}
+	return out
+}
+
+// containsFont returns true if f is in the slice.
+func containsFont(fonts []*Font, f *Font) bool {
+	for _, x := range fonts {
+		if x == f {
+			return true
+		}
+	}
+	return false
+}
+
+//-----------------------------------------------------------------------------
@@ -8,6 +8,11 @@ Common 2D shapes.
 
 package sdf
 
+import (
+	"errors"
+	"math"
+)
+
 //-----------------------------------------------------------------------------
 
 // PanelParms defines the parameters for a 2D panel.
@@ -67,3 +72,189 @@ func FingerButton2D(k *FingerButtonParms) SDF2 {
 }
 
 //-----------------------------------------------------------------------------
+// Stars
+
+// starFacets is the number of facets for a rounded star tip or root.
+const starFacets = 8
+
+// StarParms defines the parameters for a 2D star.
+type StarParms struct {
+	Points      int     // number of points
+	OuterRadius float64 // radius of the (unrounded) tips
+	InnerRadius float64 // radius of the (unrounded) roots
+	TipRadius   float64 // rounding radius for the tips
+	RootRadius  float64 // rounding radius for the roots
+}
+
+// Star2D returns a 2D star with rounded tips and roots.
+// The first tip is on the positive x axis.
+func Star2D(k *StarParms) (SDF2, error) {
+	if k.Points < 2 {
+		return nil, errors.New("star points < 2")
+	}
+	if k.InnerRadius <= 0 || k.OuterRadius <= k.InnerRadius {
+		return nil, errors.New("bad star radii")
+	}
+	if k.TipRadius < 0 || k.RootRadius < 0 {
+		return nil, errors.New("star rounding radius < 0")
+	}
+	theta := Pi / float64(k.Points)
+	tip := V2{k.OuterRadius, 0}
+	root := PolarToXY(k.InnerRadius, theta)
+	// The rounding takes some of the flank at each end.
+	flank := root.Sub(tip)
+	a0 := math.Acos(flank.Normalize().Dot(tip.Normalize().Neg()))
+	a1 := math.Acos(flank.Normalize().Neg().Dot(root.Normalize().Neg()))
+	d := k.TipRadius/math.Tan(a0) + k.RootRadius/math.Tan(Pi-a1)
+	if d > flank.Length() {
+		return nil, errors.New("star rounding radius is too large")
+	}
+	p := NewPolygon()
+	m := Rotate(2 * theta)
+	for i := 0; i < k.Points; i++ {
+		p.AddV2(tip).Smooth(k.TipRadius, starFacets)
+		p.AddV2(root).Smooth(k.RootRadius, starFacets)
+		tip = m.MulPosition(tip)
+		root = m.MulPosition(root)
+	}
+	p.Close()
+	return Polygon2D(p.Vertices()), nil
+}
+
+//-----------------------------------------------------------------------------
+// Lobed Profiles
+
+// Lobed2D returns a 2D profile with circular lobes, E.g. a knob or a
+// torx-style drive. The convex tips have radius tipRadius and the concave
+// roots are circular arcs tangent to the tips. The first lobe is on the
+// positive x axis.
+func Lobed2D(lobes int, outerRadius, innerRadius, tipRadius float64) (SDF2, error) {
+	if lobes < 2 {
+		return nil, errors.New("lobes < 2")
+	}
+	if innerRadius <= 0 || outerRadius <= innerRadius {
+		return nil, errors.New("bad lobe radii")
+	}
+	if tipRadius <= 0 || tipRadius >= outerRadius {
+		return nil, errors.New("bad lobe tip radius")
+	}
+	// The tip circles are centered at (outerRadius - tipRadius), the root
+	// circles at (innerRadius + rootRadius) half way between the tips. Solve
+	// for the root radius so the tip and root circles are tangent.
+	a := outerRadius - tipRadius
+	b := innerRadius
+	c := math.Cos(Pi / float64(lobes))
+	den := 2 * (b - a*c - tipRadius)
+	if den == 0 {
+		return nil, errors.New("lobe tip radius is too large")
+	}
+	rootRadius := (tipRadius*tipRadius - a*a - b*b + 2*a*b*c) / den
+	if rootRadius <= 0 {
+		return nil, errors.New("lobe tip radius is too large")
+	}
+	tips := make(V2Set, lobes)
+	roots := make(V2Set, lobes)
+	for i := range tips {
+		theta := Tau * float64(i) / float64(lobes)
+		tips[i] = PolarToXY(a, theta)
+		roots[i] = PolarToXY(b+rootRadius, theta+Pi/float64(lobes))
+	}
+	// The tip and root circles touch on this circle.
+	t := tips[0].Add(roots[0].Sub(tips[0]).Normalize().MulScalar(tipRadius))
+	s := Difference2D(Union2D(Circle2D(t.Length()), MultiCircle2D(tipRadius, tips)), MultiCircle2D(rootRadius, roots))
+	return s, nil
+}
+
+// Torx2D returns a 2D torx-style (hexalobular) drive profile for the point to
+// point diameter a and the root diameter b. The tip radius is 0.08 * a, close
+// to the proportions of the standard sizes.
+func Torx2D(a, b float64) (SDF2, error) {
+	return Lobed2D(6, 0.5*a, 0.5*b, 0.08*a)
+}
+
+// CamLobeParms defines the parameters for a 2D cam lobe profile.
+type CamLobeParms struct {
+	Lobes       int     // number of lobes
+	OuterRadius float64 // radius of the lobe tips
+	InnerRadius float64 // radius of the roots between the lobes
+	TipRadius   float64 // rounding radius for the tips (0 for sharp tips)
+	RootRadius  float64 // rounding radius for the roots (0 for sharp roots)
+}
+
+// CamLobe2D returns a 2D cam lobe profile. Each lobe has a tip arc and a root
+// arc, with separate radii, joined by straight flanks tangent to both arcs.
+// With the root radius of Lobed2D the flanks have zero length and the profiles
+// are the same. The first lobe is on the positive x axis.
+func CamLobe2D(k *CamLobeParms) (SDF2, error) {
+	if k.Lobes < 2 {
+		return nil, errors.New("lobes < 2")
+	}
+	if k.InnerRadius <= 0 || k.OuterRadius <= k.InnerRadius {
+		return nil, errors.New("bad lobe radii")
+	}
+	if k.TipRadius < 0 || k.RootRadius < 0 {
+		return nil, errors.New("lobe rounding radius < 0")
+	}
+	if k.TipRadius >= k.OuterRadius {
+		return nil, errors.New("bad lobe tip radius")
+	}
+	theta := Pi / float64(k.Lobes)
+	rt, rr := k.TipRadius, k.RootRadius
+	// the first tip circle and the following root circle
+	tip := V2{k.OuterRadius - rt, 0}
+	root := PolarToXY(k.InnerRadius+rr, theta)
+	// The flank is the internal tangent of the tip and root circles. n is the
+	// flank normal pointing into the material, the root tangent point is on
+	// the origin side of the line between the circle centers.
+	d := root.Sub(tip)
+	l := d.Length()
+	if l < rt+rr-tolerance {
+		return nil, errors.New("lobe rounding radius is too large")
+	}
+	u := d.DivScalar(l)
+	w := V2{-u.Y, u.X}
+	if w.Dot(tip) > 0 {
+		w = w.Neg()
+	}
+	c := Min((rt+rr)/l, 1)
+	n := u.MulScalar(-c).Add(w.MulScalar(math.Sqrt(1 - c*c)))
+	p0 := tip.Sub(n.MulScalar(rt))
+	p1 := root.Add(n.MulScalar(rr))
+	// the tangent points must be within the half lobe
+	if p0.Y < 0 || math.Atan2(p1.Y, p1.X) > theta {
+		return nil, errors.New("lobe rounding radius is too large")
+	}
+	// The polygon through the tangent points has the flanks as edges.
+	// Add the tip circles and remove the root circles to give the arcs.
+	m := Rotate(2 * theta)
+	q0 := V2{p0.X, -p0.Y}
+	q1 := m.MulPosition(V2{p1.X, -p1.Y})
+	poly := NewPolygon()
+	tips := make(V2Set, k.Lobes)
+	roots := make(V2Set, k.Lobes)
+	for i := 0; i < k.Lobes; i++ {
+		if rt > 0 {
+			poly.AddV2(q0)
+		}
+		poly.AddV2(p0)
+		if !p1.Equals(p0, tolerance) {
+			poly.AddV2(p1)
+		}
+		if rr > 0 {
+			poly.AddV2(q1)
+		}
+		tips[i], roots[i] = tip, root
+		tip, root = m.MulPosition(tip), m.MulPosition(root)
+		p0, p1, q0, q1 = m.MulPosition(p0), m.MulPosition(p1), m.MulPosition(q0), m.MulPosition(q1)
+	}
+	s := Polygon2D(poly.Vertices())
+	if rt > 0 {
+		s = Union2D(s, MultiCircle2D(rt, tips))
+	}
+	if rr > 0 {
+		s = Difference2D(s, MultiCircle2D(rr, roots))
+	}
+	return s, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -165,12 +165,9 @@ func Washer3D(k *WasherParms) SDF3 {
 		xofs := 0.5 * (k.InnerRadius + k.OuterRadius)
 		b := Box2D(V2{dx, dy}, 0)
 		b = Transform2D(b, Translate2d(V2{xofs, 0}))
-		// rotate about the z-axis
+		// rotate about the z-axis, with the removed portion centered on the x-axis
 		theta := Tau * (1.0 - k.Remove)
-		s = RevolveTheta3D(b, theta)
-		// center the removed portion on the x-axis
-		dtheta := 0.5 * (Tau - theta)
-		s = Transform3D(s, RotateZ(dtheta))
+		s = RevolveAxis3D(b, V3{}, V3{0, 0, 1}, 0.5*(Tau-theta), theta)
 	}
 	return s
 }
@@ -327,6 +324,7 @@ type BoltParms struct {
 	Tolerance   float64 // subtract from external thread radius
 	TotalLength float64 // threaded length + shank length
 	ShankLength float64 // non threaded length
+	LeftHand    bool    // left hand thread
 }
 
 // Bolt returns a simple bolt suitable for 3d printing.
@@ -336,6 +334,14 @@ func Bolt(k *BoltParms) (SDF3, error) {
 	if err != nil {
 		return nil, err
 	}
+	if t.HexFlat2Flat < 0 {
+		return nil, fmt.Errorf("no hex head size for thread \"%s\"", k.Thread)
+	}
+	if k.LeftHand {
+		lh := *t
+		lh.LeftHand = true
+		t = &lh
+	}
 	if k.TotalLength < 0 {
 		return nil, errors.New("total length < 0")
 	}
@@ -372,9 +378,11 @@ func Bolt(k *BoltParms) (SDF3, error) {
 	}
 	var thread SDF3
 	if threadLength != 0 {
-		r := t.Radius - k.Tolerance
 		threadOffset := threadLength/2 + shankLength
-		thread = Screw3D(ISOThread(r, t.Pitch, "external"), threadLength, t.Pitch, 1)
+		thread, err = t.Thread3D(threadLength, k.Tolerance, "external")
+		if err != nil {
+			return nil, err
+		}
 		// chamfer the thread
 		thread = ChamferedCylinder(thread, 0, 0.5)
 		thread = Transform3D(thread, Translate3d(V3{0, 0, threadOffset}))
@@ -391,6 +399,7 @@ type NutParms struct {
 	Thread    string  // name of thread
 	Style     string  // head style "hex" or "knurl"
 	Tolerance float64 // add to internal thread radius
+	LeftHand  bool    // left hand thread
 }
 
 // Nut returns a simple nut suitable for 3d printing.
@@ -400,6 +409,14 @@ func Nut(k *NutParms) (SDF3, error) {
 	if err != nil {
 		return nil, err
 	}
+	if t.HexFlat2Flat < 0 {
+		return nil, fmt.Errorf("no hex head size for thread \"%s\"", k.Thread)
+	}
+	if k.LeftHand {
+		lh := *t
+		lh.LeftHand = true
+		t = &lh
+	}
 	if k.Tolerance < 0 {
 		return nil, errors.New("tolerance < 0")
 	}
@@ -418,7 +435,10 @@ func Nut(k *NutParms) (SDF3, error) {
 	}
 
 	// internal thread
-	thread := Screw3D(ISOThread(t.Radius+k.Tolerance, t.Pitch, "internal"), nh, t.Pitch, 1)
+	thread, err := t.Thread3D(nh, k.Tolerance, "internal")
+	if err != nil {
+		return nil, err
+	}
 
 	return Difference3D(nut, thread), nil
 }
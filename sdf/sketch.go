@@ -0,0 +1,474 @@
+//-----------------------------------------------------------------------------
+/*
+
+2D Constraint Sketches
+
+Build a 2D profile the way a CAD sketch does: declare the points, lines and
+arcs of the profile with rough positions, add dimensional and geometric
+constraints (coincident, distance, angle, tangent, ...) and solve for the
+positions that satisfy the constraints. The solved profile is a polygon.
+
+The constraints are equations in the point coordinates. They are solved
+numerically with the Levenberg-Marquardt method, starting from the given
+point positions. A sketch that is not fully constrained is solved with the
+smallest changes to the starting positions, so the starting positions should
+be roughly right. They also pick the solution when there is more than one
+(E.g. which side of a line an arc is tangent to).
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+// sketchTolerance is the maximum constraint error of a solved sketch.
+const sketchTolerance = 1e-9
+
+// sketchIterations is the maximum number of solver iterations.
+const sketchIterations = 200
+
+//-----------------------------------------------------------------------------
+
+// SketchPoint is a point in a sketch.
+type SketchPoint struct {
+	s *Sketch
+	i int // index of the x coordinate in the sketch parameters
+}
+
+// SketchLine is a line segment in a sketch.
+type SketchLine struct {
+	p0, p1 *SketchPoint
+}
+
+// SketchArc is a circular arc in a sketch.
+type SketchArc struct {
+	center *SketchPoint
+	p0, p1 *SketchPoint // start and end points
+	ccw    bool         // is the arc counter-clockwise from p0 to p1?
+}
+
+// sketchConstraint is a constraint equation, it returns 0 when the
+// constraint is met.
+type sketchConstraint func(x []float64) float64
+
+// Sketch is a set of constrained 2D points, lines and arcs.
+type Sketch struct {
+	x           []float64          // point coordinates
+	fixed       []bool             // is the coordinate fixed?
+	entities    []interface{}      // lines and arcs of the profile, in order
+	constraints []sketchConstraint // constraint equations
+}
+
+// NewSketch returns an empty sketch.
+func NewSketch() *Sketch {
+	return &Sketch{}
+}
+
+//-----------------------------------------------------------------------------
+// Sketch Entities
+
+// Point adds a point to a sketch. The position is the starting point for the
+// solver.
+func (s *Sketch) Point(x, y float64) *SketchPoint {
+	p := &SketchPoint{s, len(s.x)}
+	s.x = append(s.x, x, y)
+	s.fixed = append(s.fixed, false, false)
+	return p
+}
+
+// V2 returns the position of a sketch point.
+func (p *SketchPoint) V2() V2 {
+	return V2{p.s.x[p.i], p.s.x[p.i+1]}
+}
+
+// pos returns the position of a point for the parameters x.
+func (p *SketchPoint) pos(x []float64) V2 {
+	return V2{x[p.i], x[p.i+1]}
+}
+
+// Line adds a line segment to the profile of a sketch.
+func (s *Sketch) Line(p0, p1 *SketchPoint) *SketchLine {
+	l := &SketchLine{p0, p1}
+	s.entities = append(s.entities, l)
+	return l
+}
+
+// Arc adds a circular arc about a center point to the profile of a sketch.
+// The arc runs counter-clockwise from p0 to p1 if ccw is true. The end points
+// are constrained to be the same distance from the center.
+func (s *Sketch) Arc(center, p0, p1 *SketchPoint, ccw bool) *SketchArc {
+	a := &SketchArc{center, p0, p1, ccw}
+	s.entities = append(s.entities, a)
+	s.add(func(x []float64) float64 {
+		c := center.pos(x)
+		return p1.pos(x).Sub(c).Length() - p0.pos(x).Sub(c).Length()
+	})
+	return a
+}
+
+// dir returns the direction vector of a line for the parameters x.
+func (l *SketchLine) dir(x []float64) V2 {
+	return l.p1.pos(x).Sub(l.p0.pos(x))
+}
+
+// radius returns the radius of an arc for the parameters x.
+func (a *SketchArc) radius(x []float64) float64 {
+	return a.p0.pos(x).Sub(a.center.pos(x)).Length()
+}
+
+//-----------------------------------------------------------------------------
+// Sketch Constraints
+
+// add adds a constraint equation.
+func (s *Sketch) add(c sketchConstraint) {
+	s.constraints = append(s.constraints, c)
+}
+
+// Fix fixes a point at its current position.
+func (s *Sketch) Fix(p *SketchPoint) {
+	s.fixed[p.i] = true
+	s.fixed[p.i+1] = true
+}
+
+// Coincident constrains two points to be at the same position.
+func (s *Sketch) Coincident(a, b *SketchPoint) {
+	s.add(func(x []float64) float64 { return x[b.i] - x[a.i] })
+	s.add(func(x []float64) float64 { return x[b.i+1] - x[a.i+1] })
+}
+
+// Distance constrains the distance between two points.
+func (s *Sketch) Distance(a, b *SketchPoint, d float64) {
+	s.add(func(x []float64) float64 { return b.pos(x).Sub(a.pos(x)).Length() - d })
+}
+
+// HorizontalDistance constrains the x distance from point a to point b.
+func (s *Sketch) HorizontalDistance(a, b *SketchPoint, d float64) {
+	s.add(func(x []float64) float64 { return x[b.i] - x[a.i] - d })
+}
+
+// VerticalDistance constrains the y distance from point a to point b.
+func (s *Sketch) VerticalDistance(a, b *SketchPoint, d float64) {
+	s.add(func(x []float64) float64 { return x[b.i+1] - x[a.i+1] - d })
+}
+
+// Length constrains the length of a line.
+func (s *Sketch) Length(l *SketchLine, d float64) {
+	s.Distance(l.p0, l.p1, d)
+}
+
+// Horizontal constrains a line to be horizontal.
+func (s *Sketch) Horizontal(l *SketchLine) {
+	s.add(func(x []float64) float64 { return x[l.p1.i+1] - x[l.p0.i+1] })
+}
+
+// Vertical constrains a line to be vertical.
+func (s *Sketch) Vertical(l *SketchLine) {
+	s.add(func(x []float64) float64 { return x[l.p1.i] - x[l.p0.i] })
+}
+
+// Angle constrains the counter-clockwise angle (radians) from the direction
+// of line l0 to the direction of line l1.
+func (s *Sketch) Angle(l0, l1 *SketchLine, angle float64) {
+	sin, cos := math.Sincos(angle)
+	s.add(func(x []float64) float64 {
+		u0 := l0.dir(x)
+		u1 := l1.dir(x)
+		c := u0.Cross(u1)
+		d := u0.Dot(u1)
+		// the angle error, in (-pi, pi]
+		return math.Atan2(c*cos-d*sin, d*cos+c*sin)
+	})
+}
+
+// Parallel constrains two lines to be parallel.
+func (s *Sketch) Parallel(l0, l1 *SketchLine) {
+	s.add(func(x []float64) float64 {
+		return l0.dir(x).Normalize().Cross(l1.dir(x).Normalize())
+	})
+}
+
+// Perpendicular constrains two lines to be perpendicular.
+func (s *Sketch) Perpendicular(l0, l1 *SketchLine) {
+	s.add(func(x []float64) float64 {
+		return l0.dir(x).Normalize().Dot(l1.dir(x).Normalize())
+	})
+}
+
+// Radius constrains the radius of an arc.
+func (s *Sketch) Radius(a *SketchArc, r float64) {
+	s.add(func(x []float64) float64 { return a.radius(x) - r })
+}
+
+// Tangent constrains a line to be tangent to an arc. The line is extended, so
+// the tangent point need not be on the line segment.
+func (s *Sketch) Tangent(l *SketchLine, a *SketchArc) {
+	// a shared end point is the tangent point, so the radius to it is
+	// perpendicular to the line
+	for _, p := range []*SketchPoint{l.p0, l.p1} {
+		if p == a.p0 || p == a.p1 {
+			s.add(func(x []float64) float64 {
+				return l.dir(x).Normalize().Dot(p.pos(x).Sub(a.center.pos(x)))
+			})
+			return
+		}
+	}
+	s.add(func(x []float64) float64 {
+		u := l.dir(x).Normalize()
+		v := a.center.pos(x).Sub(l.p0.pos(x))
+		return Abs(u.Cross(v)) - a.radius(x)
+	})
+}
+
+// TangentArcs constrains two arcs to be tangent. The arcs touch on the
+// outside if external is true, otherwise one is inside the other.
+func (s *Sketch) TangentArcs(a0, a1 *SketchArc, external bool) {
+	s.add(func(x []float64) float64 {
+		d := a1.center.pos(x).Sub(a0.center.pos(x)).Length()
+		r0 := a0.radius(x)
+		r1 := a1.radius(x)
+		if external {
+			return d - (r0 + r1)
+		}
+		return d - Abs(r0-r1)
+	})
+}
+
+//-----------------------------------------------------------------------------
+// Sketch Solver
+
+// residuals returns the constraint errors for the parameters x.
+func (s *Sketch) residuals(x []float64) []float64 {
+	r := make([]float64, len(s.constraints))
+	for i, c := range s.constraints {
+		r[i] = c(x)
+	}
+	return r
+}
+
+// sumSquares returns the sum of the squares of a vector.
+func sumSquares(r []float64) float64 {
+	sum := 0.0
+	for _, v := range r {
+		sum += v * v
+	}
+	return sum
+}
+
+// solveSPD solves a x = b for a symmetric positive definite n x n matrix
+// (row major) using the Cholesky decomposition. The solution overwrites b.
+func solveSPD(a, b []float64, n int) bool {
+	l := make([]float64, n*n)
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			sum := a[i*n+j]
+			for k := 0; k < j; k++ {
+				sum -= l[i*n+k] * l[j*n+k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return false
+				}
+				l[i*n+i] = math.Sqrt(sum)
+			} else {
+				l[i*n+j] = sum / l[j*n+j]
+			}
+		}
+	}
+	// forward substitution
+	for i := 0; i < n; i++ {
+		for k := 0; k < i; k++ {
+			b[i] -= l[i*n+k] * b[k]
+		}
+		b[i] /= l[i*n+i]
+	}
+	// back substitution
+	for i := n - 1; i >= 0; i-- {
+		for k := i + 1; k < n; k++ {
+			b[i] -= l[k*n+i] * b[k]
+		}
+		b[i] /= l[i*n+i]
+	}
+	return true
+}
+
+// Solve moves the sketch points to satisfy the constraints.
+func (s *Sketch) Solve() error {
+	// the free parameters
+	var free []int
+	for i, f := range s.fixed {
+		if !f {
+			free = append(free, i)
+		}
+	}
+	n := len(free)
+	m := len(s.constraints)
+	r := s.residuals(s.x)
+	cost := sumSquares(r)
+	// step size for the numeric derivatives
+	scale := 1.0
+	for _, v := range s.x {
+		scale = Max(scale, Abs(v))
+	}
+	h := 1e-7 * scale
+	lambda := 1e-3
+	jac := make([]float64, m*n)
+	a := make([]float64, n*n)
+	g := make([]float64, n)
+	xn := make([]float64, len(s.x))
+	for iter := 0; iter < sketchIterations; iter++ {
+		if m == 0 || math.Sqrt(cost/float64(m)) < sketchTolerance {
+			return nil
+		}
+		// jacobian of the constraints, by central differences
+		for k, i := range free {
+			x0 := s.x[i]
+			s.x[i] = x0 + h
+			r1 := s.residuals(s.x)
+			s.x[i] = x0 - h
+			r0 := s.residuals(s.x)
+			s.x[i] = x0
+			for j := 0; j < m; j++ {
+				jac[j*n+k] = (r1[j] - r0[j]) / (2 * h)
+			}
+		}
+		// the normal equations
+		for i := 0; i < n; i++ {
+			for j := 0; j <= i; j++ {
+				sum := 0.0
+				for k := 0; k < m; k++ {
+					sum += jac[k*n+i] * jac[k*n+j]
+				}
+				a[i*n+j] = sum
+				a[j*n+i] = sum
+			}
+			sum := 0.0
+			for k := 0; k < m; k++ {
+				sum += jac[k*n+i] * r[k]
+			}
+			g[i] = sum
+		}
+		// find a damped step that reduces the error
+		improved := false
+		for !improved && lambda < 1e12 {
+			ad := make([]float64, n*n)
+			copy(ad, a)
+			dx := make([]float64, n)
+			for i := 0; i < n; i++ {
+				ad[i*n+i] += lambda
+				dx[i] = -g[i]
+			}
+			if solveSPD(ad, dx, n) {
+				copy(xn, s.x)
+				for k, i := range free {
+					xn[i] += dx[k]
+				}
+				rn := s.residuals(xn)
+				if c := sumSquares(rn); c < cost {
+					copy(s.x, xn)
+					r, cost = rn, c
+					improved = true
+					lambda = Max(lambda/10, 1e-12)
+					continue
+				}
+			}
+			lambda *= 10
+		}
+		if !improved {
+			break
+		}
+	}
+	if math.Sqrt(cost/float64(m)) < sketchTolerance {
+		return nil
+	}
+	return fmt.Errorf("sketch constraints can't be solved (rms error %g)", math.Sqrt(cost/float64(m)))
+}
+
+//-----------------------------------------------------------------------------
+// Sketch Profile
+
+// sketchEnds returns the start and end points of a profile entity.
+func sketchEnds(e interface{}) (*SketchPoint, *SketchPoint) {
+	switch e := e.(type) {
+	case *SketchLine:
+		return e.p0, e.p1
+	case *SketchArc:
+		return e.p0, e.p1
+	}
+	panic("unknown sketch entity")
+}
+
+// Polygon solves a sketch and returns the profile as a closed polygon. The
+// lines and arcs of the profile are joined end to end in the order they were
+// added, and each may run in either direction.
+func (s *Sketch) Polygon() (*Polygon, error) {
+	n := len(s.entities)
+	if n < 2 {
+		return nil, errors.New("the sketch profile needs at least 2 lines or arcs")
+	}
+	if err := s.Solve(); err != nil {
+		return nil, err
+	}
+	// work out the direction of the entities
+	reverse := make([]bool, n)
+	a0, a1 := sketchEnds(s.entities[0])
+	b0, b1 := sketchEnds(s.entities[1])
+	if a0.V2().Equals(b0.V2(), tolerance) || a0.V2().Equals(b1.V2(), tolerance) {
+		reverse[0] = true
+	}
+	end := a1
+	if reverse[0] {
+		end = a0
+	}
+	for i := 1; i < n; i++ {
+		p0, p1 := sketchEnds(s.entities[i])
+		switch {
+		case p0.V2().Equals(end.V2(), tolerance):
+			end = p1
+		case p1.V2().Equals(end.V2(), tolerance):
+			reverse[i] = true
+			end = p0
+		default:
+			return nil, fmt.Errorf("sketch entity %d is not connected to the previous entity", i)
+		}
+	}
+	start := a0
+	if reverse[0] {
+		start = a1
+	}
+	if !end.V2().Equals(start.V2(), tolerance) {
+		return nil, errors.New("the sketch profile is not closed")
+	}
+	// each polygon vertex is the end of an entity, the closed polygon wraps
+	// around so the first entity runs from the last vertex.
+	p := NewPolygon()
+	for i, e := range s.entities {
+		p0, p1 := sketchEnds(e)
+		if reverse[i] {
+			p1 = p0
+		}
+		v := p.AddV2(p1.V2())
+		if a, ok := e.(*SketchArc); ok {
+			v.ArcCenter(a.center.V2(), a.ccw != reverse[i])
+		}
+	}
+	p.Close()
+	return p, nil
+}
+
+// Polygon2D solves a sketch and returns the profile as an SDF2.
+func (s *Sketch) Polygon2D() (SDF2, error) {
+	p, err := s.Polygon()
+	if err != nil {
+		return nil, err
+	}
+	return Polygon2D(p.Vertices()), nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,782 @@
+//-----------------------------------------------------------------------------
+/*
+
+STEP Export
+
+Write an SDF3 to a STEP (ISO 10303-21, AP214) file.
+
+The CSG tree is walked and supported primitives are converted to analytic
+B-rep solids with real planar, cylindrical, conical, spherical and toroidal
+faces. The supported primitives are:
+
+Box3D, Cylinder3D, Cone3D (not rounded)
+Sphere3D
+Extrude3D of a polygon, box or circle
+Revolve3D (full revolution) of a polygon, box or circle
+
+Transforms (rotation, translation, uniform scaling) and plain unions of these
+are supported. Each union member is output as a separate solid body, so
+overlapping members should be merged in the CAD tool.
+
+Anything else (differences, intersections, blended unions, etc.) is meshed
+with marching cubes and output as a faceted solid.
+
+Model units are millimetres.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// B-rep model
+
+type stepVertex struct {
+	p  V3
+	id int
+}
+
+type stepCurve struct {
+	circle bool    // circle or line
+	p      V3      // line point or circle center
+	axis   V3      // line direction or circle normal
+	ref    V3      // circle reference direction
+	radius float64 // circle radius
+}
+
+type stepEdge struct {
+	v0, v1 *stepVertex
+	curve  stepCurve
+	sense  bool // edge direction agrees with curve direction
+	id     int
+}
+
+type stepOrientedEdge struct {
+	e   *stepEdge
+	fwd bool
+}
+
+type stepSurfaceType int
+
+const (
+	stepPlane stepSurfaceType = iota
+	stepCylinder
+	stepCone
+	stepSphere
+	stepTorus
+)
+
+type stepSurface struct {
+	kind   stepSurfaceType
+	p      V3      // placement location
+	axis   V3      // placement axis
+	ref    V3      // placement reference direction
+	r0, r1 float64 // radius (major/minor for a torus, radius/semi-angle for a cone)
+}
+
+type stepFace struct {
+	surface stepSurface
+	sense   bool // face normal agrees with surface normal
+	loop    []stepOrientedEdge
+}
+
+type stepSolid struct {
+	faces []*stepFace
+}
+
+//-----------------------------------------------------------------------------
+// 2D profiles (closed loops of lines and arcs)
+
+type stepSeg struct {
+	p0, p1 V2
+	arc    bool
+	c      V2      // arc center
+	r      float64 // arc radius
+	ccw    bool    // arc direction
+}
+
+// sweep returns the swept angle of an arc.
+func (s *stepSeg) sweep() float64 {
+	a0 := math.Atan2(s.p0.Y-s.c.Y, s.p0.X-s.c.X)
+	a1 := math.Atan2(s.p1.Y-s.c.Y, s.p1.X-s.c.X)
+	d := a1 - a0
+	if !s.ccw {
+		d = -d
+	}
+	d = math.Mod(d, Tau)
+	if d <= 0 {
+		d += Tau
+	}
+	return d
+}
+
+// stepProfileArea returns the signed area of a profile (> 0 for counter clockwise).
+func stepProfileArea(segs []stepSeg) float64 {
+	area := 0.0
+	for i := range segs {
+		s := &segs[i]
+		area += 0.5 * s.p0.Cross(s.p1)
+		if s.arc {
+			phi := s.sweep()
+			a := 0.5 * s.r * s.r * (phi - math.Sin(phi))
+			if !s.ccw {
+				a = -a
+			}
+			area += a
+		}
+	}
+	return area
+}
+
+// stepReverse reverses the direction of a profile.
+func stepReverse(segs []stepSeg) []stepSeg {
+	n := len(segs)
+	out := make([]stepSeg, n)
+	for i, s := range segs {
+		s.p0, s.p1 = s.p1, s.p0
+		s.ccw = !s.ccw
+		out[n-1-i] = s
+	}
+	return out
+}
+
+// stepPolygon returns the profile for a polygon.
+func stepPolygon(vertex []V2) []stepSeg {
+	var segs []stepSeg
+	n := len(vertex)
+	for i := 0; i < n; i++ {
+		p0 := vertex[i]
+		p1 := vertex[(i+1)%n]
+		if p0.Equals(p1, tolerance) {
+			continue
+		}
+		segs = append(segs, stepSeg{p0: p0, p1: p1})
+	}
+	return segs
+}
+
+// stepProfile returns a counter clockwise profile for an SDF2 (if it is supported).
+func stepProfile(s SDF2) ([]stepSeg, bool) {
+	var segs []stepSeg
+	switch t := s.(type) {
+	case *PolySDF2:
+		segs = stepPolygon(t.vertex)
+	case *BoxSDF2:
+		x, y, r := t.size.X, t.size.Y, t.round
+		if r == 0 {
+			segs = stepPolygon([]V2{{-x, -y}, {x, -y}, {x, y}, {-x, y}})
+			break
+		}
+		corners := []V2{{x, -y}, {x, y}, {-x, y}, {-x, -y}}
+		offsets := []V2{{0, -r}, {r, 0}, {0, r}, {-r, 0}}
+		for i, c := range corners {
+			p0 := c.Add(offsets[i])
+			p1 := c.Add(offsets[(i+1)%4])
+			segs = append(segs, stepSeg{p0: p0, p1: p1, arc: true, c: c, r: r, ccw: true})
+			next := corners[(i+1)%4].Add(offsets[(i+1)%4])
+			if !p1.Equals(next, tolerance) {
+				segs = append(segs, stepSeg{p0: p1, p1: next})
+			}
+		}
+	case *CircleSDF2:
+		r := t.radius
+		segs = []stepSeg{
+			{p0: V2{r, 0}, p1: V2{-r, 0}, arc: true, r: r, ccw: true},
+			{p0: V2{-r, 0}, p1: V2{r, 0}, arc: true, r: r, ccw: true},
+		}
+	case *TransformSDF2:
+		var ok bool
+		segs, ok = stepProfile(t.sdf)
+		if !ok {
+			return nil, false
+		}
+		m := t.mInv.Inverse()
+		x := V2{m.x00, m.x10}
+		y := V2{m.x01, m.x11}
+		k := x.Length()
+		if Abs(y.Length()-k) > tolerance*k || Abs(x.Dot(y)) > tolerance*k*k {
+			// not a similarity transform
+			return nil, false
+		}
+		mirror := m.Determinant() < 0
+		for i := range segs {
+			segs[i].p0 = m.MulPosition(segs[i].p0)
+			segs[i].p1 = m.MulPosition(segs[i].p1)
+			segs[i].c = m.MulPosition(segs[i].c)
+			segs[i].r *= k
+			if mirror {
+				segs[i].ccw = !segs[i].ccw
+			}
+		}
+	default:
+		return nil, false
+	}
+	if len(segs) < 2 {
+		return nil, false
+	}
+	if stepProfileArea(segs) < 0 {
+		segs = stepReverse(segs)
+	}
+	return segs, true
+}
+
+//-----------------------------------------------------------------------------
+// B-rep builder
+
+type stepBuilder struct {
+	meshCells   int          // mesh cells for tessellated solids
+	solids      []*stepSolid // output solids
+	analytic    int          // number of analytic solids
+	tessellated int          // number of tessellated solids
+	m           M44          // current transform
+	k           float64      // current scale
+}
+
+func (b *stepBuilder) point(p V3) V3 {
+	return b.m.MulPosition(p)
+}
+
+func (b *stepBuilder) dir(d V3) V3 {
+	return b.m.MulPosition(d).Sub(b.m.MulPosition(V3{})).Normalize()
+}
+
+func (b *stepBuilder) vertex(p V3) *stepVertex {
+	return &stepVertex{p: b.point(p)}
+}
+
+func (b *stepBuilder) line(v0, v1 *stepVertex) *stepEdge {
+	c := stepCurve{p: v0.p, axis: v1.p.Sub(v0.p).Normalize()}
+	return &stepEdge{v0: v0, v1: v1, curve: c, sense: true}
+}
+
+func (b *stepBuilder) circle(v0, v1 *stepVertex, center, axis, ref V3, radius float64, sense bool) *stepEdge {
+	c := stepCurve{
+		circle: true,
+		p:      b.point(center),
+		axis:   b.dir(axis),
+		ref:    b.dir(ref),
+		radius: radius * b.k,
+	}
+	return &stepEdge{v0: v0, v1: v1, curve: c, sense: sense}
+}
+
+func (b *stepBuilder) surface(kind stepSurfaceType, p, axis, ref V3, r0, r1 float64) stepSurface {
+	if kind != stepCone {
+		r1 *= b.k
+	}
+	return stepSurface{kind: kind, p: b.point(p), axis: b.dir(axis), ref: b.dir(ref), r0: r0 * b.k, r1: r1}
+}
+
+// extrude builds the solid for a profile extruded along the z-axis.
+func (b *stepBuilder) extrude(segs []stepSeg, h float64) *stepSolid {
+	n := len(segs)
+	vb := make([]*stepVertex, n)
+	vt := make([]*stepVertex, n)
+	for i, s := range segs {
+		vb[i] = b.vertex(s.p0.ToV3(-h))
+		vt[i] = b.vertex(s.p0.ToV3(h))
+	}
+	profileEdge := func(i int, v []*stepVertex, z float64) *stepEdge {
+		s := &segs[i]
+		if s.arc {
+			return b.circle(v[i], v[(i+1)%n], s.c.ToV3(z), V3{0, 0, 1}, V3{1, 0, 0}, s.r, s.ccw)
+		}
+		return b.line(v[i], v[(i+1)%n])
+	}
+	eb := make([]*stepEdge, n)
+	et := make([]*stepEdge, n)
+	ev := make([]*stepEdge, n)
+	for i := range segs {
+		eb[i] = profileEdge(i, vb, -h)
+		et[i] = profileEdge(i, vt, h)
+		ev[i] = b.line(vb[i], vt[i])
+	}
+	solid := &stepSolid{}
+	// side faces
+	for i, s := range segs {
+		f := &stepFace{sense: true}
+		if s.arc {
+			f.surface = b.surface(stepCylinder, s.c.ToV3(-h), V3{0, 0, 1}, V3{1, 0, 0}, s.r, 0)
+			f.sense = s.ccw
+		} else {
+			d := s.p1.Sub(s.p0).Normalize()
+			f.surface = b.surface(stepPlane, s.p0.ToV3(-h), V3{d.Y, -d.X, 0}, d.ToV3(0), 0, 0)
+		}
+		f.loop = []stepOrientedEdge{{eb[i], true}, {ev[(i+1)%n], true}, {et[i], false}, {ev[i], false}}
+		solid.faces = append(solid.faces, f)
+	}
+	// bottom face
+	f := &stepFace{sense: true}
+	f.surface = b.surface(stepPlane, V3{0, 0, -h}, V3{0, 0, -1}, V3{1, 0, 0}, 0, 0)
+	for i := n - 1; i >= 0; i-- {
+		f.loop = append(f.loop, stepOrientedEdge{eb[i], false})
+	}
+	solid.faces = append(solid.faces, f)
+	// top face
+	f = &stepFace{sense: true}
+	f.surface = b.surface(stepPlane, V3{0, 0, h}, V3{0, 0, 1}, V3{1, 0, 0}, 0, 0)
+	for i := 0; i < n; i++ {
+		f.loop = append(f.loop, stepOrientedEdge{et[i], true})
+	}
+	solid.faces = append(solid.faces, f)
+	return solid
+}
+
+// revolve builds the solid for a profile (x = radius, y = z) revolved about the z-axis.
+// Each face of revolution is split into two halves (0..pi, pi..2pi).
+func (b *stepBuilder) revolve(segs []stepSeg) *stepSolid {
+	n := len(segs)
+	// snap on-axis points to the axis
+	for i := range segs {
+		if Abs(segs[i].p0.X) < tolerance {
+			segs[i].p0.X = 0
+		}
+		if Abs(segs[i].p1.X) < tolerance {
+			segs[i].p1.X = 0
+		}
+	}
+	// vertices at theta = 0 and theta = pi
+	vert := make([][2]*stepVertex, n)
+	for i, s := range segs {
+		r, z := s.p0.X, s.p0.Y
+		vert[i][0] = b.vertex(V3{r, 0, z})
+		vert[i][1] = vert[i][0]
+		if r > 0 {
+			vert[i][1] = b.vertex(V3{-r, 0, z})
+		}
+	}
+	// half circles at each vertex
+	circ := make([][2]*stepEdge, n)
+	for i, s := range segs {
+		r, z := s.p0.X, s.p0.Y
+		if r > 0 {
+			for h := 0; h < 2; h++ {
+				circ[i][h] = b.circle(vert[i][h], vert[i][1-h], V3{0, 0, z}, V3{0, 0, 1}, V3{1, 0, 0}, r, true)
+			}
+		}
+	}
+	// generator edges at theta = 0 and theta = pi
+	gen := make([][2]*stepEdge, n)
+	for j, s := range segs {
+		if !s.arc && s.p0.X == 0 && s.p1.X == 0 {
+			// on the axis
+			continue
+		}
+		for h := 0; h < 2; h++ {
+			v0, v1 := vert[j][h], vert[(j+1)%n][h]
+			if s.arc {
+				cos := 1.0 - 2.0*float64(h)
+				center := V3{s.c.X * cos, 0, s.c.Y}
+				gen[j][h] = b.circle(v0, v1, center, V3{0, -cos, 0}, V3{cos, 0, 0}, s.r, s.ccw)
+			} else {
+				gen[j][h] = b.line(v0, v1)
+			}
+		}
+	}
+	solid := &stepSolid{}
+	for j, s := range segs {
+		if gen[j][0] == nil {
+			continue
+		}
+		d := s.p1.Sub(s.p0)
+		normal := V2{d.Y, -d.X} // outward normal of the profile segment
+		var surface stepSurface
+		sense := true
+		switch {
+		case s.arc && s.c.X == 0:
+			surface = b.surface(stepSphere, V3{0, 0, s.c.Y}, V3{0, 0, 1}, V3{1, 0, 0}, s.r, 0)
+			sense = s.ccw
+		case s.arc:
+			surface = b.surface(stepTorus, V3{0, 0, s.c.Y}, V3{0, 0, 1}, V3{1, 0, 0}, s.c.X, s.r)
+			sense = s.ccw
+		case d.X == 0:
+			surface = b.surface(stepCylinder, V3{}, V3{0, 0, 1}, V3{1, 0, 0}, s.p0.X, 0)
+			sense = normal.X > 0
+		case d.Y == 0:
+			surface = b.surface(stepPlane, V3{0, 0, s.p0.Y}, V3{0, 0, Sign(normal.Y)}, V3{1, 0, 0}, 0, 0)
+		default:
+			// the cone radius increases in the axis direction
+			axis := V3{0, 0, Sign(d.X / d.Y)}
+			semiAngle := math.Atan(Abs(d.X / d.Y))
+			surface = b.surface(stepCone, V3{0, 0, s.p0.Y}, axis, V3{1, 0, 0}, s.p0.X, semiAngle)
+			sense = normal.X > 0
+		}
+		for h := 0; h < 2; h++ {
+			f := &stepFace{surface: surface, sense: sense}
+			if c := circ[j][h]; c != nil {
+				f.loop = append(f.loop, stepOrientedEdge{c, true})
+			}
+			f.loop = append(f.loop, stepOrientedEdge{gen[j][1-h], true})
+			if c := circ[(j+1)%n][h]; c != nil {
+				f.loop = append(f.loop, stepOrientedEdge{c, false})
+			}
+			f.loop = append(f.loop, stepOrientedEdge{gen[j][h], false})
+			solid.faces = append(solid.faces, f)
+		}
+	}
+	return solid
+}
+
+// tessellate builds a faceted solid for an SDF3 using marching cubes.
+func (b *stepBuilder) tessellate(s SDF3) *stepSolid {
+	vertices := make(map[V3]*stepVertex)
+	edges := make(map[[2]*stepVertex]*stepEdge)
+	vertex := func(p V3) *stepVertex {
+		p = b.point(p)
+		key := p.Quantize()
+		v, ok := vertices[key]
+		if !ok {
+			v = &stepVertex{p: p}
+			vertices[key] = v
+		}
+		return v
+	}
+	edge := func(v0, v1 *stepVertex) stepOrientedEdge {
+		if e, ok := edges[[2]*stepVertex{v1, v0}]; ok {
+			return stepOrientedEdge{e, false}
+		}
+		e := b.line(v0, v1)
+		edges[[2]*stepVertex{v0, v1}] = e
+		return stepOrientedEdge{e, true}
+	}
+	mirror := b.m.Determinant() < 0
+	solid := &stepSolid{}
+	for _, t := range RenderMesh(s, b.meshCells) {
+		v := [3]*stepVertex{vertex(t.V[0]), vertex(t.V[1]), vertex(t.V[2])}
+		if mirror {
+			v[1], v[2] = v[2], v[1]
+		}
+		if v[0] == v[1] || v[1] == v[2] || v[2] == v[0] {
+			continue
+		}
+		e0 := v[1].p.Sub(v[0].p)
+		e1 := v[2].p.Sub(v[0].p)
+		n := e0.Cross(e1)
+		if n.Length() == 0 {
+			continue
+		}
+		f := &stepFace{sense: true}
+		f.surface = stepSurface{kind: stepPlane, p: v[0].p, axis: n.Normalize(), ref: e0.Normalize()}
+		f.loop = []stepOrientedEdge{edge(v[0], v[1]), edge(v[1], v[2]), edge(v[2], v[0])}
+		solid.faces = append(solid.faces, f)
+	}
+	return solid
+}
+
+// similarity returns the scale of a similarity transform (rotation, translation, uniform scaling).
+func similarity(m M44) (float64, bool) {
+	x := V3{m.x00, m.x10, m.x20}
+	y := V3{m.x01, m.x11, m.x21}
+	z := V3{m.x02, m.x12, m.x22}
+	k := x.Length()
+	if Abs(y.Length()-k) > tolerance*k || Abs(z.Length()-k) > tolerance*k {
+		return 0, false
+	}
+	if Abs(x.Dot(y)) > tolerance*k*k || Abs(y.Dot(z)) > tolerance*k*k || Abs(z.Dot(x)) > tolerance*k*k {
+		return 0, false
+	}
+	if x.Cross(y).Dot(z) < 0 {
+		// mirrored
+		return 0, false
+	}
+	return k, true
+}
+
+// sameFunc returns true if two functions are the same function.
+func sameFunc(a, b interface{}) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// add adds the solids for an SDF3 with transform m.
+func (b *stepBuilder) add(s SDF3, m M44) {
+	b.m = m
+	k, rigid := similarity(m)
+	b.k = k
+	var solid *stepSolid
+	switch t := s.(type) {
+	case *UnionSDF3:
+		if sameFunc(t.min, Min) {
+			for _, x := range t.sdf {
+				b.add(x, m)
+			}
+			return
+		}
+	case *TransformSDF3:
+		if _, ok := similarity(m.Mul(t.matrix)); ok {
+			b.add(t.sdf, m.Mul(t.matrix))
+			return
+		}
+	case *ScaleUniformSDF3:
+		b.add(t.sdf, m.Mul(Scale3d(V3{t.k, t.k, t.k})))
+		return
+	case *ConnectedSDF3:
+		b.add(t.sdf, m)
+		return
+	}
+	if rigid {
+		switch t := s.(type) {
+		case *BoxSDF3:
+			if t.round == 0 {
+				x, y := t.size.X, t.size.Y
+				solid = b.extrude(stepPolygon([]V2{{-x, -y}, {x, -y}, {x, y}, {-x, y}}), t.size.Z)
+			}
+		case *CylinderSDF3:
+			if t.round == 0 {
+				segs, _ := stepProfile(Circle2D(t.radius))
+				solid = b.extrude(segs, t.height)
+			}
+		case *ConeSDF3:
+			if t.round == 0 {
+				h := t.height
+				solid = b.revolve(stepPolygon([]V2{{0, -h}, {t.r0, -h}, {t.r1, h}, {0, h}}))
+			}
+		case *SphereSDF3:
+			r := t.radius
+			solid = b.revolve([]stepSeg{
+				{p0: V2{0, -r}, p1: V2{0, r}, arc: true, r: r, ccw: true},
+				{p0: V2{0, r}, p1: V2{0, -r}},
+			})
+		case *ExtrudeSDF3:
+			if sameFunc(t.extrude, NormalExtrude) {
+				if segs, ok := stepProfile(t.sdf); ok {
+					solid = b.extrude(segs, t.height)
+				}
+			}
+		case *SorSDF3:
+			if t.theta == 0 && t.sdf.BoundingBox().Min.X > -tolerance {
+				if segs, ok := stepProfile(t.sdf); ok {
+					solid = b.revolve(segs)
+				}
+			}
+		}
+	}
+	if solid != nil {
+		b.analytic++
+	} else {
+		solid = b.tessellate(s)
+		b.tessellated++
+	}
+	b.solids = append(b.solids, solid)
+}
+
+//-----------------------------------------------------------------------------
+// STEP file writer
+
+type stepWriter struct {
+	w   *bufio.Writer
+	n   int // entity number
+	err error
+}
+
+// stepReal formats a real number for a STEP file.
+func stepReal(x float64) string {
+	if Abs(x) < epsilon {
+		x = 0
+	}
+	s := strconv.FormatFloat(x, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += "."
+	}
+	return s
+}
+
+func stepBool(x bool) string {
+	if x {
+		return ".T."
+	}
+	return ".F."
+}
+
+func stepRefs(ids []int) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = fmt.Sprintf("#%d", id)
+	}
+	return "(" + strings.Join(s, ",") + ")"
+}
+
+// entity writes an entity instance and returns its number.
+func (sw *stepWriter) entity(format string, args ...interface{}) int {
+	sw.n++
+	if sw.err == nil {
+		_, sw.err = fmt.Fprintf(sw.w, "#%d=%s;\n", sw.n, fmt.Sprintf(format, args...))
+	}
+	return sw.n
+}
+
+func (sw *stepWriter) point(p V3) int {
+	return sw.entity("CARTESIAN_POINT('',(%s,%s,%s))", stepReal(p.X), stepReal(p.Y), stepReal(p.Z))
+}
+
+func (sw *stepWriter) direction(d V3) int {
+	return sw.entity("DIRECTION('',(%s,%s,%s))", stepReal(d.X), stepReal(d.Y), stepReal(d.Z))
+}
+
+func (sw *stepWriter) placement(p, axis, ref V3) int {
+	// make the reference direction orthogonal to the axis
+	ref = ref.Sub(axis.MulScalar(ref.Dot(axis))).Normalize()
+	return sw.entity("AXIS2_PLACEMENT_3D('',#%d,#%d,#%d)", sw.point(p), sw.direction(axis), sw.direction(ref))
+}
+
+func (sw *stepWriter) vertex(v *stepVertex) int {
+	if v.id == 0 {
+		v.id = sw.entity("VERTEX_POINT('',#%d)", sw.point(v.p))
+	}
+	return v.id
+}
+
+func (sw *stepWriter) edge(e *stepEdge) int {
+	if e.id == 0 {
+		v0 := sw.vertex(e.v0)
+		v1 := sw.vertex(e.v1)
+		var curve int
+		c := &e.curve
+		if c.circle {
+			curve = sw.entity("CIRCLE('',#%d,%s)", sw.placement(c.p, c.axis, c.ref), stepReal(c.radius))
+		} else {
+			l := e.v1.p.Sub(e.v0.p).Length()
+			vector := sw.entity("VECTOR('',#%d,%s)", sw.direction(c.axis), stepReal(l))
+			curve = sw.entity("LINE('',#%d,#%d)", sw.point(c.p), vector)
+		}
+		e.id = sw.entity("EDGE_CURVE('',#%d,#%d,#%d,%s)", v0, v1, curve, stepBool(e.sense))
+	}
+	return e.id
+}
+
+func (sw *stepWriter) surface(s *stepSurface) int {
+	p := sw.placement(s.p, s.axis, s.ref)
+	switch s.kind {
+	case stepCylinder:
+		return sw.entity("CYLINDRICAL_SURFACE('',#%d,%s)", p, stepReal(s.r0))
+	case stepCone:
+		return sw.entity("CONICAL_SURFACE('',#%d,%s,%s)", p, stepReal(s.r0), stepReal(s.r1))
+	case stepSphere:
+		return sw.entity("SPHERICAL_SURFACE('',#%d,%s)", p, stepReal(s.r0))
+	case stepTorus:
+		return sw.entity("TOROIDAL_SURFACE('',#%d,%s,%s)", p, stepReal(s.r0), stepReal(s.r1))
+	}
+	return sw.entity("PLANE('',#%d)", p)
+}
+
+func (sw *stepWriter) face(f *stepFace) int {
+	surface := sw.surface(&f.surface)
+	edges := make([]int, len(f.loop))
+	for i, oe := range f.loop {
+		edges[i] = sw.entity("ORIENTED_EDGE('',*,*,#%d,%s)", sw.edge(oe.e), stepBool(oe.fwd))
+	}
+	loop := sw.entity("EDGE_LOOP('',%s)", stepRefs(edges))
+	bound := sw.entity("FACE_OUTER_BOUND('',#%d,.T.)", loop)
+	return sw.entity("ADVANCED_FACE('',(#%d),#%d,%s)", bound, surface, stepBool(f.sense))
+}
+
+func (sw *stepWriter) solid(s *stepSolid) int {
+	faces := make([]int, len(s.faces))
+	for i, f := range s.faces {
+		faces[i] = sw.face(f)
+	}
+	shell := sw.entity("CLOSED_SHELL('',%s)", stepRefs(faces))
+	return sw.entity("MANIFOLD_SOLID_BREP('',#%d)", shell)
+}
+
+//-----------------------------------------------------------------------------
+
+// EncodeSTEP writes an SDF3 in STEP format to an io.Writer.
+func EncodeSTEP(
+	w io.Writer, // output writer
+	s SDF3, // sdf3 to write
+	name string, // product name
+	meshCells int, // number of cells on the longest axis for tessellated solids. e.g 200
+) error {
+	b := &stepBuilder{meshCells: meshCells}
+	b.add(s, Identity3d())
+	return b.write(w, name)
+}
+
+func (b *stepBuilder) write(w io.Writer, name string) error {
+	sw := &stepWriter{w: bufio.NewWriter(w)}
+	name = strings.Replace(name, "'", "''", -1)
+
+	// header
+	fmt.Fprintf(sw.w, "ISO-10303-21;\nHEADER;\n")
+	fmt.Fprintf(sw.w, "FILE_DESCRIPTION(('%s'),'2;1');\n", name)
+	fmt.Fprintf(sw.w, "FILE_NAME('%s','%s',(''),(''),'sdfx','sdfx','');\n", name, time.Now().UTC().Format("2006-01-02T15:04:05"))
+	fmt.Fprintf(sw.w, "FILE_SCHEMA(('AUTOMOTIVE_DESIGN { 1 0 10303 214 1 1 1 1 }'));\nENDSEC;\nDATA;\n")
+
+	// geometry
+	items := []int{sw.placement(V3{}, V3{0, 0, 1}, V3{1, 0, 0})}
+	for _, s := range b.solids {
+		items = append(items, sw.solid(s))
+	}
+
+	// units and context
+	mm := sw.entity("(LENGTH_UNIT()NAMED_UNIT(*)SI_UNIT(.MILLI.,.METRE.))")
+	rad := sw.entity("(NAMED_UNIT(*)PLANE_ANGLE_UNIT()SI_UNIT($,.RADIAN.))")
+	sr := sw.entity("(NAMED_UNIT(*)SI_UNIT($,.STERADIAN.)SOLID_ANGLE_UNIT())")
+	unc := sw.entity("UNCERTAINTY_MEASURE_WITH_UNIT(LENGTH_MEASURE(1.E-06),#%d,'distance_accuracy_value','confusion accuracy')", mm)
+	ctx := sw.entity("(GEOMETRIC_REPRESENTATION_CONTEXT(3)GLOBAL_UNCERTAINTY_ASSIGNED_CONTEXT((#%d))"+
+		"GLOBAL_UNIT_ASSIGNED_CONTEXT((#%d,#%d,#%d))REPRESENTATION_CONTEXT('',''))", unc, mm, rad, sr)
+	rep := sw.entity("ADVANCED_BREP_SHAPE_REPRESENTATION('%s',%s,#%d)", name, stepRefs(items), ctx)
+
+	// product structure
+	app := sw.entity("APPLICATION_CONTEXT('core data for automotive mechanical design processes')")
+	sw.entity("APPLICATION_PROTOCOL_DEFINITION('international standard','automotive_design',2000,#%d)", app)
+	pc := sw.entity("PRODUCT_CONTEXT('',#%d,'mechanical')", app)
+	pdc := sw.entity("PRODUCT_DEFINITION_CONTEXT('part definition',#%d,'design')", app)
+	product := sw.entity("PRODUCT('%s','%s','',(#%d))", name, name, pc)
+	sw.entity("PRODUCT_RELATED_PRODUCT_CATEGORY('part',$,(#%d))", product)
+	pdf := sw.entity("PRODUCT_DEFINITION_FORMATION('','',#%d)", product)
+	pd := sw.entity("PRODUCT_DEFINITION('design','',#%d,#%d)", pdf, pdc)
+	pds := sw.entity("PRODUCT_DEFINITION_SHAPE('','',#%d)", pd)
+	sw.entity("SHAPE_DEFINITION_REPRESENTATION(#%d,#%d)", pds, rep)
+
+	if sw.err != nil {
+		return sw.err
+	}
+	fmt.Fprintf(sw.w, "ENDSEC;\nEND-ISO-10303-21;\n")
+	return sw.w.Flush()
+}
+
+// RenderSTEP writes an SDF3 to a STEP file.
+// Supported primitives are output as analytic solids, anything else is
+// meshed (meshCells on the longest axis) and output as a faceted solid.
+func RenderSTEP(
+	s SDF3, // sdf3 to render
+	meshCells int, // number of cells on the longest axis for tessellated solids. e.g 200
+	path string, // path to filename
+) error {
+	b := &stepBuilder{meshCells: meshCells}
+	b.add(s, Identity3d())
+	fmt.Printf("rendering %s (%d analytic, %d tessellated solids)\n", path, b.analytic, b.tessellated)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if err := b.write(f, name); err != nil {
+		return err
+	}
+	cfg := getMetadata()
+	if !cfg.enable {
+		return nil
+	}
+	bb := s.BoundingBox()
+	md := cfg.newMetadata(path, &bb, meshCells, bb.Size().MaxComponent()/float64(meshCells))
+	md.Solids = len(b.solids)
+	return md.save(path)
+}
+
+//-----------------------------------------------------------------------------
@@ -10,9 +10,15 @@ package sdf
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 )
 
@@ -32,6 +38,89 @@ type STLTriangle struct {
 
 //-----------------------------------------------------------------------------
 
+// LoadSTL reads a triangle mesh from an STL file (binary or ASCII).
+func LoadSTL(path string) ([]*Triangle3, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodeSTL(file)
+}
+
+// DecodeSTL reads a triangle mesh in STL format (binary or ASCII) from an io.Reader.
+func DecodeSTL(r io.Reader) ([]*Triangle3, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// A binary file has a size consistent with the triangle count.
+	// Some binary files start with "solid", so check this first.
+	hdrSize := binary.Size(STLHeader{})
+	triSize := binary.Size(STLTriangle{})
+	if len(data) >= hdrSize {
+		count := int(binary.LittleEndian.Uint32(data[hdrSize-4 : hdrSize]))
+		if len(data) == hdrSize+count*triSize {
+			return decodeBinarySTL(data[hdrSize:], count)
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return decodeASCIISTL(data)
+	}
+	return nil, errors.New("unrecognised STL format")
+}
+
+func decodeBinarySTL(data []byte, count int) ([]*Triangle3, error) {
+	mesh := make([]*Triangle3, 0, count)
+	r := bytes.NewReader(data)
+	var d STLTriangle
+	toV3 := func(v [3]float32) V3 {
+		return V3{float64(v[0]), float64(v[1]), float64(v[2])}
+	}
+	for i := 0; i < count; i++ {
+		if err := binary.Read(r, binary.LittleEndian, &d); err != nil {
+			return nil, err
+		}
+		mesh = append(mesh, NewTriangle3(toV3(d.Vertex1), toV3(d.Vertex2), toV3(d.Vertex3)))
+	}
+	return mesh, nil
+}
+
+func decodeASCIISTL(data []byte) ([]*Triangle3, error) {
+	var mesh []*Triangle3
+	var v []V3
+	for n, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 0 {
+			continue
+		}
+		switch f[0] {
+		case "vertex":
+			if len(f) != 4 {
+				return nil, fmt.Errorf("line %d: bad vertex", n+1)
+			}
+			var p [3]float64
+			for i := range p {
+				x, err := strconv.ParseFloat(f[i+1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s", n+1, err)
+				}
+				p[i] = x
+			}
+			v = append(v, V3{p[0], p[1], p[2]})
+		case "endfacet":
+			if len(v) != 3 {
+				return nil, fmt.Errorf("line %d: facet has %d vertices", n+1, len(v))
+			}
+			mesh = append(mesh, NewTriangle3(v[0], v[1], v[2]))
+			v = v[:0]
+		}
+	}
+	return mesh, nil
+}
+
+//-----------------------------------------------------------------------------
+
 // SaveSTL writes a triangle mesh to an STL file.
 func SaveSTL(path string, mesh []*Triangle3) error {
 	file, err := os.Create(path)
@@ -39,9 +128,23 @@ func SaveSTL(path string, mesh []*Triangle3) error {
 		return err
 	}
 	defer file.Close()
+	return EncodeSTL(file, mesh)
+}
+
+// SaveTrianglesSTL writes a 3d triangle set to an STL file.
+func SaveTrianglesSTL(path string, vs []V3, ts TriangleISet) error {
+	mesh, err := ts.Mesh(vs)
+	if err != nil {
+		return err
+	}
+	return SaveSTL(path, mesh)
+}
 
-	buf := bufio.NewWriter(file)
+// EncodeSTL writes a triangle mesh in binary STL format to an io.Writer.
+func EncodeSTL(w io.Writer, mesh []*Triangle3) error {
+	buf := bufio.NewWriter(w)
 	header := STLHeader{}
+	mesh = outputTriangles(mesh)
 	header.Count = uint32(len(mesh))
 	if err := binary.Write(buf, binary.LittleEndian, &header); err != nil {
 		return err
@@ -102,7 +205,7 @@ func WriteSTL(wg *sync.WaitGroup, path string) (chan<- *Triangle3, error) {
 		var count uint32
 		var d STLTriangle
 		// read triangles from the channel and write them to the file
-		for t := range c {
+		for t := range outputTriangleStream(c) {
 			n := t.Normal()
 			d.Normal[0] = float32(n.X)
 			d.Normal[1] = float32(n.Y)
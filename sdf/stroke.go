@@ -0,0 +1,137 @@
+//-----------------------------------------------------------------------------
+/*
+
+Polyline Stroking
+
+Thicken an open path (a polyline, or the vertices of a spline) into a closed
+2D region, E.g. for channels, traces and single stroke engraving fonts.
+
+A stroke with round caps and round joins is the set of points within half
+the width of the path, so the distance is evaluated directly from the path
+segments. Other cap and join styles build an outline polygon.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+// StrokeCap is the style of the ends of a stroke.
+type StrokeCap int
+
+// Stroke cap styles.
+const (
+	CapRound  StrokeCap = iota // semicircular end
+	CapButt                    // square end at the end point
+	CapSquare                  // square end extended by half the width
+)
+
+// strokeMiterLimit is the miter limit for mitered stroke joins.
+const strokeMiterLimit = 4
+
+//-----------------------------------------------------------------------------
+
+// StrokeSDF2 is a path stroked with round caps and round joins.
+type StrokeSDF2 struct {
+	path []V2
+	r    float64 // half width
+	bb   Box2
+}
+
+// Evaluate returns the minimum distance to a stroked path.
+func (s *StrokeSDF2) Evaluate(p V2) float64 {
+	return math.Sqrt(polylineDist2(p, s.path)) - s.r
+}
+
+// BoundingBox returns the bounding box of a stroked path.
+func (s *StrokeSDF2) BoundingBox() Box2 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+
+// strokeCap appends the vertices for the cap at the end point v of a path
+// with the direction d at the end.
+func strokeCap(out V2Set, v, d V2, r float64, cap StrokeCap) V2Set {
+	n := V2{d.Y, -d.X} // right normal
+	switch cap {
+	case CapButt:
+		return append(out, v.Add(n.MulScalar(r)), v.Sub(n.MulScalar(r)))
+	case CapSquare:
+		e := v.Add(d.MulScalar(r))
+		return append(out, e.Add(n.MulScalar(r)), e.Sub(n.MulScalar(r)))
+	case CapRound:
+		a0 := math.Atan2(n.Y, n.X)
+		k := int(math.Ceil(Pi / offsetArcStep))
+		for j := 0; j <= k; j++ {
+			out = append(out, v.Add(PolarToXY(r, a0+Pi*float64(j)/float64(k))))
+		}
+		return out
+	}
+	panic("unknown stroke cap")
+}
+
+// strokeSide appends the vertices for the right hand side of a path.
+func strokeSide(out V2Set, path []V2, r float64, join OffsetJoin) V2Set {
+	n := len(path)
+	normal := make([]V2, n-1)
+	for i := range normal {
+		e := path[i+1].Sub(path[i]).Normalize()
+		normal[i] = V2{e.Y, -e.X}
+	}
+	for i := 1; i < n-1; i++ {
+		out = offsetCorner(out, path[i], normal[i-1], normal[i], r, join, strokeMiterLimit)
+	}
+	return out
+}
+
+// Stroke2D returns the 2D region covered by a pen of a given width moving
+// along an open path. The cap style sets the shape of the path ends and the
+// join style sets the shape of the outside of the path corners.
+func Stroke2D(path []V2, width float64, cap StrokeCap, join OffsetJoin) (SDF2, error) {
+	if width <= 0 {
+		return nil, errors.New("width <= 0")
+	}
+	// remove repeated points
+	var v []V2
+	for i, p := range path {
+		if i == 0 || !p.Equals(v[len(v)-1], tolerance) {
+			v = append(v, p)
+		}
+	}
+	if len(v) < 2 {
+		return nil, errors.New("path has less than 2 distinct points")
+	}
+	r := 0.5 * width
+	if cap == CapRound && join == JoinRound {
+		s := StrokeSDF2{}
+		s.path = v
+		s.r = r
+		bb := Box2{v[0], v[0]}
+		for _, p := range v {
+			bb = bb.Include(p)
+		}
+		s.bb = Box2{bb.Min.SubScalar(r), bb.Max.AddScalar(r)}
+		return &s, nil
+	}
+	n := len(v)
+	// reverse path
+	rv := make([]V2, n)
+	for i := range v {
+		rv[n-1-i] = v[i]
+	}
+	var out V2Set
+	out = strokeCap(out, v[0], v[0].Sub(v[1]).Normalize(), r, cap)
+	out = strokeSide(out, v, r, join)
+	out = strokeCap(out, v[n-1], v[n-1].Sub(v[n-2]).Normalize(), r, cap)
+	out = strokeSide(out, rv, r, join)
+	return Polygon2D(out), nil
+}
+
+//-----------------------------------------------------------------------------
@@ -23,6 +23,7 @@ type SVG struct {
 	filename  string
 	lineStyle string
 	p0s, p1s  []V2
+	texts     []annotationText
 	min, max  V2
 }
 
@@ -49,6 +50,24 @@ func (s *SVG) Line(p0, p1 V2) {
 	s.p1s = append(s.p1s, p1)
 }
 
+// Annotations adds dimensions and labels to the SVG file.
+func (s *SVG) Annotations(a *Annotations) {
+	for _, l := range a.lines {
+		s.Line(l[0], l[1])
+	}
+	if len(a.texts) == 0 {
+		return
+	}
+	bb := a.BoundingBox()
+	if len(s.p0s) == 0 {
+		s.min, s.max = bb.Min, bb.Max
+	} else {
+		s.min = s.min.Min(bb.Min)
+		s.max = s.max.Max(bb.Max)
+	}
+	s.texts = append(s.texts, a.texts...)
+}
+
 // Save closes the SVG file.
 func (s *SVG) Save() error {
 	f, err := os.Create(s.filename)
@@ -64,6 +83,13 @@ func (s *SVG) Save() error {
 		p1 := s.p1s[i]
 		canvas.Line(p0.X-s.min.X, s.max.Y-p0.Y, p1.X-s.min.X, s.max.Y-p1.Y, s.lineStyle)
 	}
+	for _, t := range s.texts {
+		x, y := t.p.X-s.min.X, s.max.Y-t.p.Y
+		// the svg y-axis is down, so angles are clockwise
+		canvas.Gtransform(fmt.Sprintf("rotate(%g,%g,%g)", -RtoD(t.angle), x, y))
+		canvas.Text(x, y, t.s, fmt.Sprintf("font-size:%gpx;font-family:sans-serif;text-anchor:middle", t.h))
+		canvas.Gend()
+	}
 	canvas.End()
 	return f.Close()
 }
@@ -73,7 +99,7 @@ func (s *SVG) Save() error {
 // SaveSVG writes line segments to an SVG file.
 func SaveSVG(path, lineStyle string, mesh []*Line) error {
 	s := NewSVG(path, lineStyle)
-	for _, v := range mesh {
+	for _, v := range outputLines(mesh) {
 		s.Line(v[0], v[1])
 	}
 	if err := s.Save(); err != nil {
@@ -82,6 +108,16 @@ func SaveSVG(path, lineStyle string, mesh []*Line) error {
 	return nil
 }
 
+// SaveAnnotatedSVG writes line segments and annotations to an SVG file.
+func SaveAnnotatedSVG(path, lineStyle string, mesh []*Line, a *Annotations) error {
+	s := NewSVG(path, lineStyle)
+	for _, v := range outputLines(mesh) {
+		s.Line(v[0], v[1])
+	}
+	s.Annotations(a)
+	return s.Save()
+}
+
 //-----------------------------------------------------------------------------
 
 // WriteSVG writes a stream of line segments to an SVG file.
@@ -96,7 +132,7 @@ func WriteSVG(wg *sync.WaitGroup, path, lineStyle string) (chan<- *Line, error)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for v := range c {
+		for v := range outputLineStream(c) {
 			s.Line(v[0], v[1])
 		}
 		if err := s.Save(); err != nil {
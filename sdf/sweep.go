@@ -0,0 +1,174 @@
+//-----------------------------------------------------------------------------
+/*
+
+Path Sweeps
+
+Sweep a 2D profile along a 3D path to make tubes, handles, cable channels, etc.
+
+The path is a polyline (use Bezier3 to sample a smooth path). Each segment of
+the path is an extrusion of the profile, cut by the mitre planes that bisect
+the joints with the neighbouring segments. The profile frame is carried along
+the path with a rotation minimizing frame (double reflection, see "Computation
+of Rotation Minimizing Frames", Wang et al 2008), so the profile doesn't twist
+about the path and the cross sections of neighbouring segments match on the
+mitre planes.
+
+The profile x/y axes are mapped to the u/v axes of the frame. At the start of
+the path v is as close as possible to +z (or +y if the path starts along z).
+
+Very sharp bends in the path will give long mitres, and the inside of a bend
+with a radius smaller than the profile will self intersect.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"errors"
+	"math"
+)
+
+//-----------------------------------------------------------------------------
+
+type sweepSegment struct {
+	a       V3   // start of segment
+	b       V3   // end of segment
+	u, v, t V3   // segment frame (t is the segment direction)
+	m0, m1  V3   // start/end mitre plane normals (pointing along the path)
+	bb      Box3 // bounding box of the segment
+}
+
+// SweepSDF3 is a 2d profile swept along a 3d path.
+type SweepSDF3 struct {
+	sdf SDF2
+	seg []sweepSegment
+	bb  Box3
+}
+
+// Sweep3D returns an SDF3 made by sweeping a 2d profile along a 3d polyline path.
+func Sweep3D(sdf SDF2, path []V3) (SDF3, error) {
+	// remove repeated points
+	var p []V3
+	for i, x := range path {
+		if i == 0 || !x.Equals(p[len(p)-1], tolerance) {
+			p = append(p, x)
+		}
+	}
+	if len(p) < 2 {
+		return nil, errors.New("sweep path needs at least 2 distinct points")
+	}
+
+	s := SweepSDF3{}
+	s.sdf = sdf
+	s.seg = make([]sweepSegment, len(p)-1)
+	for i := range s.seg {
+		s.seg[i].a = p[i]
+		s.seg[i].b = p[i+1]
+		s.seg[i].t = p[i+1].Sub(p[i]).Normalize()
+	}
+
+	// initial frame
+	t := s.seg[0].t
+	up := V3{0, 0, 1}
+	if Abs(t.Z) > 1-tolerance {
+		up = V3{0, 1, 0}
+	}
+	s.seg[0].v = up.Sub(t.MulScalar(up.Dot(t))).Normalize()
+	s.seg[0].u = s.seg[0].v.Cross(t)
+
+	// carry the frame along the path and work out the mitre planes
+	s.seg[0].m0 = t
+	for i := 1; i < len(s.seg); i++ {
+		s0 := &s.seg[i-1]
+		s1 := &s.seg[i]
+		m := s0.t.Add(s1.t)
+		if m.Length() < tolerance {
+			return nil, errors.New("sweep path reverses direction")
+		}
+		m = m.Normalize()
+		s0.m1 = m
+		s1.m0 = m
+		// reflect the frame in the mitre plane
+		reflect := func(x V3) V3 {
+			return x.Sub(m.MulScalar(2 * x.Dot(m)))
+		}
+		s1.u = reflect(s0.u)
+		s1.v = reflect(s0.v)
+	}
+	s.seg[len(s.seg)-1].m1 = s.seg[len(s.seg)-1].t
+
+	// bounding boxes: the profile bounding box projected onto the mitre planes
+	pbb := sdf.BoundingBox()
+	for i := range s.seg {
+		sg := &s.seg[i]
+		bb := Box3{sg.a, sg.a}
+		for _, c := range pbb.Vertices() {
+			x := sg.u.MulScalar(c.X).Add(sg.v.MulScalar(c.Y))
+			for _, e := range []struct{ p, m V3 }{{sg.a, sg.m0}, {sg.b, sg.m1}} {
+				k := -e.m.Dot(x) / e.m.Dot(sg.t)
+				bb = bb.Include(e.p.Add(x).Add(sg.t.MulScalar(k)))
+			}
+		}
+		sg.bb = bb
+		if i == 0 {
+			s.bb = bb
+		} else {
+			s.bb = s.bb.Extend(bb)
+		}
+	}
+	return &s, nil
+}
+
+// Evaluate returns the minimum distance to a swept profile.
+func (s *SweepSDF3) Evaluate(p V3) float64 {
+	d := math.MaxFloat64
+	for i := range s.seg {
+		sg := &s.seg[i]
+		// skip segments that can't be closer
+		if d > 0 && boxDist2(sg.bb, p) >= d*d {
+			continue
+		}
+		x := p.Sub(sg.a)
+		a := s.sdf.Evaluate(V2{x.Dot(sg.u), x.Dot(sg.v)})
+		// cut by the mitre planes
+		b := Max(-x.Dot(sg.m0), p.Sub(sg.b).Dot(sg.m1))
+		d = Min(d, Max(a, b))
+	}
+	return d
+}
+
+// BoundingBox returns the bounding box of a swept profile.
+func (s *SweepSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+
+// Bezier3 samples a 3d path made from cubic bezier curves.
+// The control points are p0, c0, c1, p1, c0, c1, p2, ... (3n+1 points for n curves).
+// Each curve is sampled with the given number of steps.
+func Bezier3(ctrl []V3, steps int) []V3 {
+	if len(ctrl) < 4 || (len(ctrl)-1)%3 != 0 {
+		panic("bad number of control points")
+	}
+	if steps < 1 {
+		panic("steps < 1")
+	}
+	path := []V3{ctrl[0]}
+	for i := 0; i+3 < len(ctrl); i += 3 {
+		p0, p1, p2, p3 := ctrl[i], ctrl[i+1], ctrl[i+2], ctrl[i+3]
+		for j := 1; j <= steps; j++ {
+			t := float64(j) / float64(steps)
+			k := 1 - t
+			x := p0.MulScalar(k * k * k)
+			x = x.Add(p1.MulScalar(3 * k * k * t))
+			x = x.Add(p2.MulScalar(3 * k * t * t))
+			x = x.Add(p3.MulScalar(t * t * t))
+			path = append(path, x)
+		}
+	}
+	return path
+}
+
+//-----------------------------------------------------------------------------
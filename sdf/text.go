@@ -11,12 +11,10 @@ Convert a string and font specification into an SDF2
 package sdf
 
 import (
-	"io/ioutil"
+	"errors"
+	"math"
+	"sort"
 	"strings"
-
-	"github.com/golang/freetype/truetype"
-	"golang.org/x/image/font"
-	"golang.org/x/image/math/fixed"
 )
 
 //-----------------------------------------------------------------------------
@@ -31,112 +29,97 @@ const (
 
 // Text stores a UTF8 string and it's rendering parameters.
 type Text struct {
-	s      string
-	halign align
+	s           string
+	halign      align
+	tracking    float64             // extra space between glyphs
+	wordSpacing float64             // extra space after a space character
+	kerning     map[[2]rune]float64 // kerning overrides for rune pairs
+	dir         direction           // paragraph direction
 }
 
 //-----------------------------------------------------------------------------
 
-// pToV2 converts a truetype point to a V2
-func pToV2(p truetype.Point) V2 {
-	return V2{float64(p.X), float64(p.Y)}
-}
-
-//-----------------------------------------------------------------------------
-
-// glyphCurve returns the SDF2 for the n-th curve of the glyph
-func glyphCurve(g *truetype.GlyphBuf, n int) (SDF2, bool) {
-	// get the start and end point
-	start := 0
-	if n != 0 {
-		start = g.Ends[n-1]
-	}
-	end := g.Ends[n] - 1
-
-	// build a bezier curve from the points
-	// work out the cw/ccw direction
-	b := NewBezier()
-	sum := 0.0
-	offPrev := false
-	vPrev := pToV2(g.Points[end])
-
-	for i := start; i <= end; i++ {
-		p := g.Points[i]
-		v := pToV2(p)
-		// is the point off/on the curve?
-		off := p.Flags&1 == 0
-		// do we have an implicit on point?
-		if off && offPrev {
-			// implicit on point at the midpoint of the 2 off points
-			b.AddV2(v.Add(vPrev).MulScalar(0.5))
-		}
-		// add the point
-		x := b.AddV2(v)
-		if off {
-			x.Mid()
-		}
-		// accumulate the cw/ccw direction
-		sum += (v.X - vPrev.X) * (v.Y + vPrev.Y)
-		// next point...
-		vPrev = v
-		offPrev = off
-	}
-	b.Close()
-
-	return Polygon2D(b.Polygon().Vertices()), sum > 0
-}
-
-// glyphConvert returns the SDF2 for a glyph
-func glyphConvert(g *truetype.GlyphBuf) SDF2 {
-	var s0 SDF2
-	for n := 0; n < len(g.Ends); n++ {
-		s1, cw := glyphCurve(g, n)
-		if cw {
-			s0 = Union2D(s0, s1)
-		} else {
-			s0 = Difference2D(s0, s1)
-		}
-	}
-	return s0
+// textGlyph is a glyph on a line of text.
+type textGlyph struct {
+	s SDF2    // glyph outline (font units, with the origin on the baseline)
+	x float64 // position of the glyph origin along the line
+	w float64 // advance width
 }
 
-//-----------------------------------------------------------------------------
-
-// lineSDF2 returns an SDF2 slice for a line of text
-func lineSDF2(f *truetype.Font, l string) ([]SDF2, float64, error) {
-	iPrev := truetype.Index(0)
-	scale := fixed.Int26_6(f.FUnitsPerEm())
+// lineGlyphs returns the glyphs for a line of text, and the line length.
+func lineGlyphs(f *Font, t *Text, l string) ([]textGlyph, float64, error) {
+	var fPrev *Font
+	iPrev := fontIndex(0)
+	rPrev := rune(0)
 	xOfs := 0.0
+	base := -1
+	// the spacing is relative to the line height
+	ah := f.lineHeight()
 
-	var ss []SDF2
-
-	for _, r := range l {
-		i := f.Index(r)
+	var gs []textGlyph
 
-		// get the glyph metrics
-		hm := f.HMetric(scale, i)
-
-		// apply kerning
-		k := f.Kern(scale, iPrev, i)
-		xOfs += float64(k)
-		iPrev = i
+	for _, sg := range shapeLine(f, t, l) {
+		g, i, r := sg.f, sg.i, sg.r
+		// scale a fallback glyph to the font units of this font
+		scale := float64(f.ppem()) / float64(g.ppem())
 
 		// load the glyph
-		g := &truetype.GlyphBuf{}
-		err := g.Load(f, scale, i, font.HintingNone)
+		s, err := g.glyph(i)
 		if err != nil {
 			return nil, 0, err
 		}
+		if s != nil && scale != 1 {
+			s = ScaleUniform2D(s, scale)
+		}
+		w := g.advance(i) * scale
+
+		if sg.mark && base >= 0 {
+			// a combining mark doesn't advance, a mark with a width is
+			// centered on the base glyph
+			x := xOfs
+			if w > 0 {
+				x = gs[base].x + 0.5*(gs[base].w-w)
+			}
+			gs = append(gs, textGlyph{s, x, w})
+			continue
+		}
 
-		s := glyphConvert(g)
-		if s != nil {
-			s = Transform2D(s, Translate2d(V2{xOfs, 0}))
-			ss = append(ss, s)
+		if base >= 0 {
+			// apply kerning
+			if k, ok := t.kerning[[2]rune{rPrev, r}]; ok {
+				xOfs += k * ah
+			} else if g == fPrev {
+				xOfs += g.kern(iPrev, i) * scale
+			}
+			xOfs += t.tracking * ah
 		}
+		fPrev = g
+		iPrev = i
+		rPrev = r
 
-		xOfs += float64(hm.AdvanceWidth)
+		if r == ' ' {
+			w += t.wordSpacing * ah
+		}
+		base = len(gs)
+		gs = append(gs, textGlyph{s, xOfs, w})
+		xOfs += w
 	}
 
+	return gs, xOfs, nil
+}
+
+// lineSDF2 returns an SDF2 slice for a line of text
+func lineSDF2(f *Font, t *Text, l string) ([]SDF2, float64, error) {
+	gs, xOfs, err := lineGlyphs(f, t, l)
+	if err != nil {
+		return nil, 0, err
+	}
+	var ss []SDF2
+	for _, g := range gs {
+		if g.s != nil {
+			ss = append(ss, Transform2D(g.s, Translate2d(V2{g.x, 0})))
+		}
+	}
 	return ss, xOfs, nil
 }
 
@@ -151,30 +134,57 @@ func NewText(s string) *Text {
 	}
 }
 
-// LoadFont loads a truetype (*.ttf) font file.
-func LoadFont(fname string) (*truetype.Font, error) {
-	// read the font file
-	b, err := ioutil.ReadFile(fname)
-	if err != nil {
-		return nil, err
+// SetTracking sets the extra space between all glyphs (letter spacing) as a
+// fraction of the line height. Negative values tighten the text.
+func (t *Text) SetTracking(x float64) *Text {
+	t.tracking = x
+	return t
+}
+
+// SetWordSpacing sets the extra space added to each space character as a
+// fraction of the line height.
+func (t *Text) SetWordSpacing(x float64) *Text {
+	t.wordSpacing = x
+	return t
+}
+
+// SetKerning replaces the font kerning between a pair of characters with an
+// adjustment that is a fraction of the line height. A positive value moves
+// the characters further apart.
+func (t *Text) SetKerning(left, right rune, x float64) *Text {
+	if t.kerning == nil {
+		t.kerning = make(map[[2]rune]float64)
 	}
-	return truetype.Parse(b)
+	t.kerning[[2]rune{left, right}] = x
+	return t
 }
 
-// TextSDF2 returns a sized SDF2 for a text object.
-func TextSDF2(f *truetype.Font, t *Text, h float64) (SDF2, error) {
-	scale := fixed.Int26_6(f.FUnitsPerEm())
+// SetRightToLeft sets the direction of the text. By default the direction
+// of each line is set by its first letter, E.g. a line starting with an
+// Arabic or Hebrew letter is right to left.
+func (t *Text) SetRightToLeft(rtl bool) *Text {
+	t.dir = ltrDirection
+	if rtl {
+		t.dir = rtlDirection
+	}
+	return t
+}
+
+// textLayout returns the glyph SDF2s for a text object in font units, the
+// advance width and baseline origin of each line, and the line height.
+func textLayout(f *Font, t *Text) ([]SDF2, []float64, []V2, float64, error) {
 	lines := strings.Split(t.s, "\n")
 	yOfs := 0.0
-	vm := f.VMetric(scale, f.Index('\n'))
-	ah := float64(vm.AdvanceHeight)
+	ah := f.lineHeight()
 
 	var ss []SDF2
+	advance := make([]float64, len(lines))
+	origin := make([]V2, len(lines))
 
 	for i := range lines {
-		ssLine, hlen, err := lineSDF2(f, lines[i])
+		ssLine, hlen, err := lineSDF2(f, t, lines[i])
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, 0, err
 		}
 		xOfs := 0.0
 		if t.halign == rAlign {
@@ -186,10 +196,239 @@ func TextSDF2(f *truetype.Font, t *Text, h float64) (SDF2, error) {
 			ssLine[i] = Transform2D(ssLine[i], Translate2d(V2{xOfs, yOfs}))
 		}
 		ss = append(ss, ssLine...)
+		advance[i] = hlen
+		origin[i] = V2{xOfs, yOfs}
 		yOfs -= ah
 	}
 
-	return CenterAndScale2D(Union2D(ss...), h/ah), nil
+	return ss, advance, origin, ah, nil
+}
+
+// TextSDF2 returns a sized SDF2 for a text object.
+func TextSDF2(f *Font, t *Text, h float64) (SDF2, error) {
+	ss, _, _, ah, err := textLayout(f, t)
+	if err != nil {
+		return nil, err
+	}
+	u := UnionBVH2D(ss...)
+	if u == nil {
+		return nil, errors.New("no glyphs in the text")
+	}
+	return CenterAndScale2D(u, h/ah), nil
+}
+
+// FontSizeToHeight returns the line height, as used by TextSDF2, for text of
+// a font size in points. The size of the em square of the font is the font
+// size, as for a design tool or word processor. dpi is the number of SDF2
+// units per inch, E.g. MillimetresPerInch for a part in mm, or 96 to match a
+// screen design in pixels.
+func FontSizeToHeight(f *Font, points, dpi float64) float64 {
+	em := points * dpi / PointsPerInch
+	return em * f.lineHeight() / float64(f.ppem())
+}
+
+// CapHeightToHeight returns the line height, as used by TextSDF2, for text
+// with capital letters of the given height (E.g. 5 mm lettering on a part).
+func CapHeightToHeight(f *Font, capHeight float64) (float64, error) {
+	ch, err := f.capHeight()
+	if err != nil {
+		return 0, err
+	}
+	return capHeight * f.lineHeight() / ch, nil
+}
+
+// TextSDF2Points returns an SDF2 for a text object with a font size in
+// points, for a part in mm.
+func TextSDF2Points(f *Font, t *Text, points float64) (SDF2, error) {
+	if points <= 0 {
+		return nil, errors.New("points <= 0")
+	}
+	return TextSDF2(f, t, FontSizeToHeight(f, points, MillimetresPerInch))
+}
+
+// TextSDF2CapHeight returns an SDF2 for a text object with capital letters
+// of the given height.
+func TextSDF2CapHeight(f *Font, t *Text, capHeight float64) (SDF2, error) {
+	if capHeight <= 0 {
+		return nil, errors.New("capHeight <= 0")
+	}
+	h, err := CapHeightToHeight(f, capHeight)
+	if err != nil {
+		return nil, err
+	}
+	return TextSDF2(f, t, h)
+}
+
+// Text3D returns a string of text extruded to a depth, ready to be added to
+// or cut from a part with a top surface at z = 0. The height is the line
+// height of the text (as for TextSDF2). Embossed text is on top of the
+// surface (0 <= z <= depth), engraved text is below the surface
+// (-depth <= z <= 0) and should be subtracted from the part.
+func Text3D(f *Font, s string, height, depth float64, engrave bool) (SDF3, error) {
+	if height <= 0 {
+		return nil, errors.New("height <= 0")
+	}
+	if depth <= 0 {
+		return nil, errors.New("depth <= 0")
+	}
+	s2d, err := TextSDF2(f, NewText(s), height)
+	if err != nil {
+		return nil, err
+	}
+	z := 0.5 * depth
+	if engrave {
+		z = -z
+	}
+	return Transform3D(Extrude3D(s2d, depth), Translate3d(V3{0, 0, z})), nil
+}
+
+// TextExtents are the dimensions of a text object as rendered by TextSDF2.
+// The values are in the units of the SDF2, with the text centered on the
+// origin.
+type TextExtents struct {
+	Advance     []float64 // advance width of each line
+	Origin      []V2      // start of the baseline of each line
+	LineHeight  float64   // distance between the baselines
+	BoundingBox Box2      // bounding box of the glyph outlines
+}
+
+// TextMetrics returns the dimensions of a text object rendered by TextSDF2
+// with the line height h.
+func TextMetrics(f *Font, t *Text, h float64) (*TextExtents, error) {
+	ss, advance, origin, ah, err := textLayout(f, t)
+	if err != nil {
+		return nil, err
+	}
+	k := h / ah
+	// TextSDF2 centers the glyph outlines on the origin
+	var c V2
+	bb := Box2{}
+	if u := Union2D(ss...); u != nil {
+		bb = u.BoundingBox()
+		c = bb.Center()
+		bb = Box2{bb.Min.Sub(c).MulScalar(k), bb.Max.Sub(c).MulScalar(k)}
+	}
+	e := TextExtents{
+		Advance:     make([]float64, len(advance)),
+		Origin:      make([]V2, len(origin)),
+		LineHeight:  h,
+		BoundingBox: bb,
+	}
+	for i := range advance {
+		e.Advance[i] = advance[i] * k
+		e.Origin[i] = origin[i].Sub(c).MulScalar(k)
+	}
+	return &e, nil
+}
+
+//-----------------------------------------------------------------------------
+// Text on a Curve
+
+// textAlong returns a line of text with the glyphs placed along a curve. The
+// place function returns the position and the unit tangent of the curve at
+// a distance along it, measured from the center of the text. The baseline of
+// each glyph is tangent to the curve at the center of the glyph.
+func textAlong(f *Font, t *Text, h float64, place func(d float64) (V2, V2)) (SDF2, error) {
+	if h <= 0 {
+		return nil, errors.New("h <= 0")
+	}
+	if strings.Contains(t.s, "\n") {
+		return nil, errors.New("text on a curve must be a single line")
+	}
+	gs, length, err := lineGlyphs(f, t, t.s)
+	if err != nil {
+		return nil, err
+	}
+	k := h / f.lineHeight()
+	var ss []SDF2
+	for _, g := range gs {
+		if g.s == nil {
+			continue
+		}
+		// the glyph outline is relative to the glyph origin
+		xc := 0.5 * g.w
+		p, u := place((g.x + xc - 0.5*length) * k)
+		m := Translate2d(p).Mul(Rotate2d(math.Atan2(u.Y, u.X)))
+		m = m.Mul(Scale2d(V2{k, k})).Mul(Translate2d(V2{-xc, 0}))
+		ss = append(ss, Transform2D(g.s, m))
+	}
+	s := UnionBVH2D(ss...)
+	if s == nil {
+		return nil, errors.New("no glyphs in the text")
+	}
+	return s, nil
+}
+
+// TextOnArc2D returns a line of text along a circle of the given radius
+// about the origin, centered on the angle (radians). Clockwise text reads
+// clockwise with the tops of the glyphs away from the center (E.g. the top
+// of a dial), otherwise the text reads counter-clockwise with the tops of the
+// glyphs towards the center (E.g. the bottom of a dial). The baseline of the
+// text is on the circle and h is the line height.
+func TextOnArc2D(f *Font, t *Text, h, radius, angle float64, clockwise bool) (SDF2, error) {
+	if radius <= 0 {
+		return nil, errors.New("radius <= 0")
+	}
+	return textAlong(f, t, h, func(d float64) (V2, V2) {
+		if clockwise {
+			theta := angle - d/radius
+			return PolarToXY(radius, theta), PolarToXY(1, theta-0.5*Pi)
+		}
+		theta := angle + d/radius
+		return PolarToXY(radius, theta), PolarToXY(1, theta+0.5*Pi)
+	})
+}
+
+// TextOnPath2D returns a line of text along a path (E.g. the vertices of a
+// Bezier curve). The text alignment sets where the text is on the path: the
+// start, the middle or the end. The glyphs are on the left of the path and h
+// is the line height. The path is extended in a straight line at each end if
+// the text is longer than the path.
+func TextOnPath2D(f *Font, t *Text, h float64, path []V2) (SDF2, error) {
+	// remove repeated points
+	var v []V2
+	for i, p := range path {
+		if i == 0 || !p.Equals(v[len(v)-1], tolerance) {
+			v = append(v, p)
+		}
+	}
+	if len(v) < 2 {
+		return nil, errors.New("path has less than 2 distinct points")
+	}
+	// distance along the path to each vertex
+	dist := make([]float64, len(v))
+	for i := 1; i < len(v); i++ {
+		dist[i] = dist[i-1] + v[i].Sub(v[i-1]).Length()
+	}
+	pathLength := dist[len(v)-1]
+	// the text length
+	_, length, err := lineGlyphs(f, t, t.s)
+	if err != nil {
+		return nil, err
+	}
+	length *= h / f.lineHeight()
+	// the distance along the path to the center of the text
+	var center float64
+	switch t.halign {
+	case lAlign:
+		center = 0.5 * length
+	case rAlign:
+		center = pathLength - 0.5*length
+	default:
+		center = 0.5 * pathLength
+	}
+	return textAlong(f, t, h, func(d float64) (V2, V2) {
+		d += center
+		// find the path segment
+		i := sort.SearchFloat64s(dist, d) - 1
+		if i < 0 {
+			i = 0
+		} else if i > len(v)-2 {
+			i = len(v) - 2
+		}
+		u := v[i+1].Sub(v[i]).Normalize()
+		return v[i].Add(u.MulScalar(d - dist[i])), u
+	})
 }
 
 //-----------------------------------------------------------------------------
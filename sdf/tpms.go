@@ -0,0 +1,137 @@
+//-----------------------------------------------------------------------------
+/*
+
+Triply Periodic Minimal Surfaces
+
+Lattices based on the gyroid, Schwarz P and diamond surfaces. These are used
+as light weight infill for 3d printed parts.
+
+Each surface is the zero set of a periodic function f. The lattice is a wall
+of a given thickness centered on the surface: |f| <= c. f is not a distance
+function, so the distance is found by scaling f by its maximum gradient (sqrt(3)
+for all of these surfaces). The wall thickness is set using the mean gradient
+of f on the surface, so the actual wall thickness varies a little.
+
+The lattices are infinite. Their bounding box is a single cell, so they should
+be intersected with a solid before rendering: either with Infill3D, or as the
+second argument of Intersect3D, which takes its bounding box from the first.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import "math"
+
+//-----------------------------------------------------------------------------
+
+// TPMSSDF3 is a triply periodic minimal surface lattice.
+type TPMSSDF3 struct {
+	f  func(p V3) float64 // surface function (period 2 pi)
+	k  float64            // scale from world to surface coordinates
+	c  float64            // surface function value at the wall boundary
+	bb Box3
+}
+
+// newTPMS returns a lattice for a surface function with a given mean gradient
+// magnitude on the surface.
+func newTPMS(f func(p V3) float64, grad, cellSize, wallThickness float64) SDF3 {
+	if cellSize <= 0 {
+		panic("cellSize <= 0")
+	}
+	if wallThickness <= 0 {
+		panic("wallThickness <= 0")
+	}
+	s := TPMSSDF3{}
+	s.f = f
+	s.k = Tau / cellSize
+	s.c = 0.5 * wallThickness * s.k * grad
+	d := 0.5 * cellSize
+	s.bb = Box3{V3{-d, -d, -d}, V3{d, d, d}}
+	return &s
+}
+
+// Gyroid3D returns a gyroid lattice. The bounding box is a single cell, so use
+// the lattice as the second argument of Intersect3D (which takes its bounding
+// box from the first) or with Infill3D.
+func Gyroid3D(cellSize, wallThickness float64) SDF3 {
+	f := func(p V3) float64 {
+		sx, cx := math.Sincos(p.X)
+		sy, cy := math.Sincos(p.Y)
+		sz, cz := math.Sincos(p.Z)
+		return sx*cy + sy*cz + sz*cx
+	}
+	return newTPMS(f, 1.53, cellSize, wallThickness)
+}
+
+// SchwarzP3D returns a Schwarz P (primitive) lattice. The bounding box is a
+// single cell, so use the lattice as the second argument of Intersect3D (which
+// takes its bounding box from the first) or with Infill3D.
+func SchwarzP3D(cellSize, wallThickness float64) SDF3 {
+	f := func(p V3) float64 {
+		return math.Cos(p.X) + math.Cos(p.Y) + math.Cos(p.Z)
+	}
+	return newTPMS(f, 1.31, cellSize, wallThickness)
+}
+
+// Diamond3D returns a Schwarz D (diamond) lattice. The bounding box is a single
+// cell, so use the lattice as the second argument of Intersect3D (which takes
+// its bounding box from the first) or with Infill3D.
+func Diamond3D(cellSize, wallThickness float64) SDF3 {
+	f := func(p V3) float64 {
+		sx, cx := math.Sincos(p.X)
+		sy, cy := math.Sincos(p.Y)
+		sz, cz := math.Sincos(p.Z)
+		return sx*sy*sz + sx*cy*cz + cx*sy*cz + cx*cy*sz
+	}
+	return newTPMS(f, 1.49, cellSize, wallThickness)
+}
+
+// Evaluate returns the minimum distance to a lattice.
+func (s *TPMSSDF3) Evaluate(p V3) float64 {
+	return (Abs(s.f(p.MulScalar(s.k))) - s.c) / (s.k * math.Sqrt(3))
+}
+
+// BoundingBox returns the bounding box (a single cell) of a lattice.
+func (s *TPMSSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
+// Infill
+
+// InfillSDF3 is a solid with a shell and a lattice infill.
+type InfillSDF3 struct {
+	solid   SDF3
+	lattice SDF3
+	shell   float64 // shell thickness
+	bb      Box3
+}
+
+// Infill3D returns a solid with an outer shell of a given thickness, and the
+// interior filled with a lattice (e.g. Gyroid3D).
+func Infill3D(solid, lattice SDF3, shellThickness float64) SDF3 {
+	if shellThickness < 0 {
+		panic("shellThickness < 0")
+	}
+	s := InfillSDF3{}
+	s.solid = solid
+	s.lattice = lattice
+	s.shell = shellThickness
+	s.bb = solid.BoundingBox()
+	return &s
+}
+
+// Evaluate returns the minimum distance to an infilled solid.
+func (s *InfillSDF3) Evaluate(p V3) float64 {
+	d := s.solid.Evaluate(p)
+	// the solid intersected with the union of the shell and the lattice
+	return Max(d, Min(-d-s.shell, s.lattice.Evaluate(p)))
+}
+
+// BoundingBox returns the bounding box of an infilled solid.
+func (s *InfillSDF3) BoundingBox() Box3 {
+	return s.bb
+}
+
+//-----------------------------------------------------------------------------
@@ -21,6 +21,9 @@ const Tau = 2 * math.Pi
 // MillimetresPerInch is millimetres per inch (25.4)
 const MillimetresPerInch = 25.4
 
+// PointsPerInch is typographic (PostScript) points per inch (72)
+const PointsPerInch = 72.0
+
 // Mil is millimetres per 1/1000 of an inch
 const Mil = MillimetresPerInch / 1000.0
 
@@ -166,6 +169,13 @@ func PolyMin(k float64) MinFunc {
 // MaxFunc is a maximum function for SDF blending.
 type MaxFunc func(a, b float64) float64
 
+// ChamferMax returns a maximum function that makes a 45-degree chamfered edge (the diagonal of a square of size <k>).
+func ChamferMax(k float64) MaxFunc {
+	return func(a, b float64) float64 {
+		return Max(Max(a, b), (a+k+b)*sqrtHalf)
+	}
+}
+
 // PolyMax returns a maximum function (Try k = 0.1, a bigger k gives a bigger fillet).
 func PolyMax(k float64) MaxFunc {
 	return func(a, b float64) float64 {
@@ -175,6 +185,61 @@ func PolyMax(k float64) MaxFunc {
 
 //-----------------------------------------------------------------------------
 
+// Blend selects the kernel used for a smooth blend.
+type Blend int
+
+// Blend kernels.
+const (
+	BlendPoly  Blend = iota // quadratic polynomial
+	BlendCubic              // cubic polynomial (smoother curvature)
+	BlendExp                // exponential (blends everywhere, not just near the join)
+	BlendRoot               // square root (blends everywhere, not just near the join)
+)
+
+// BlendMin returns a smooth minimum function for a blend kernel.
+// k is the distance the blend pulls the surface out at the join of two
+// surfaces, the blend region is a few times wider than this.
+func BlendMin(kernel Blend, k float64) MinFunc {
+	if k <= 0 {
+		return Min
+	}
+	switch kernel {
+	case BlendPoly:
+		return func(a, b float64) float64 {
+			h := Max(4*k-Abs(a-b), 0) / (4 * k)
+			return Min(a, b) - h*h*k
+		}
+	case BlendCubic:
+		return func(a, b float64) float64 {
+			h := Max(6*k-Abs(a-b), 0) / (6 * k)
+			return Min(a, b) - h*h*h*k
+		}
+	case BlendExp:
+		return func(a, b float64) float64 {
+			// offset the exponents to avoid overflow
+			m := Min(a, b)
+			r := math.Exp2(-(a-m)/k) + math.Exp2(-(b-m)/k)
+			return m - k*math.Log2(r)
+		}
+	case BlendRoot:
+		return func(a, b float64) float64 {
+			x := a - b
+			return 0.5 * (a + b - math.Sqrt(x*x+4*k*k))
+		}
+	}
+	panic("unknown blend kernel")
+}
+
+// BlendMax returns a smooth maximum function for a blend kernel.
+func BlendMax(kernel Blend, k float64) MaxFunc {
+	min := BlendMin(kernel, k)
+	return func(a, b float64) float64 {
+		return -min(-a, -b)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
 // ExtrudeFunc maps V3 to V2 - the point used to evaluate the SDF2.
 type ExtrudeFunc func(p V3) V2
 
@@ -97,25 +97,26 @@ func (b *Box3) RandomSet(n int) V3Set {
 
 // Dot returns the dot product of a and b.
 func (a V3) Dot(b V3) float64 {
-	return a.X*b.X + a.Y*b.Y + a.Z*b.Z
+	// Note: float64() conversions prevent fused multiply-add (see determinism.go)
+	return float64(a.X*b.X) + float64(a.Y*b.Y) + float64(a.Z*b.Z)
 }
 
 // Dot returns the dot product of a and b.
 func (a V2) Dot(b V2) float64 {
-	return a.X*b.X + a.Y*b.Y
+	return float64(a.X*b.X) + float64(a.Y*b.Y)
 }
 
 // Cross returns the cross product of a and b.
 func (a V3) Cross(b V3) V3 {
-	x := a.Y*b.Z - a.Z*b.Y
-	y := a.Z*b.X - a.X*b.Z
-	z := a.X*b.Y - a.Y*b.X
+	x := float64(a.Y*b.Z) - float64(a.Z*b.Y)
+	y := float64(a.Z*b.X) - float64(a.X*b.Z)
+	z := float64(a.X*b.Y) - float64(a.Y*b.X)
 	return V3{x, y, z}
 }
 
 // Cross returns the cross product of a and b.
 func (a V2) Cross(b V2) float64 {
-	return (a.X * b.Y) - (a.Y * b.X)
+	return float64(a.X*b.Y) - float64(a.Y*b.X)
 }
 
 // colinearSlow return true if 3 points are colinear (slow test).
@@ -324,22 +325,22 @@ func (a V2Set) Max() V2 {
 
 // Length returns the vector length.
 func (a V3) Length() float64 {
-	return math.Sqrt(a.X*a.X + a.Y*a.Y + a.Z*a.Z)
+	return math.Sqrt(a.Length2())
 }
 
 // Length2 returns the vector length * length.
 func (a V3) Length2() float64 {
-	return a.X*a.X + a.Y*a.Y + a.Z*a.Z
+	return float64(a.X*a.X) + float64(a.Y*a.Y) + float64(a.Z*a.Z)
 }
 
 // Length returns the vector length.
 func (a V2) Length() float64 {
-	return math.Sqrt(a.X*a.X + a.Y*a.Y)
+	return math.Sqrt(a.Length2())
 }
 
 // Length2 returns the vector length * length.
 func (a V2) Length2() float64 {
-	return a.X*a.X + a.Y*a.Y
+	return float64(a.X*a.X) + float64(a.Y*a.Y)
 }
 
 // MinComponent returns the minimum component of the vector.
@@ -0,0 +1,129 @@
+//-----------------------------------------------------------------------------
+/*
+
+Voronoi Diagrams
+
+The voronoi cell of a point is the region closer to that point than to any
+other point. The cells are the duals of the delaunay triangulation: a cell is
+bounded by the perpendicular bisectors of the delaunay edges to the point.
+The cells on the convex hull are unbounded, so all cells are clipped to a
+bounding box.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import "errors"
+
+//-----------------------------------------------------------------------------
+
+// clipHalfPlane returns the part of a convex polygon with (p - m).d <= 0.
+func clipHalfPlane(poly []V2, m, d V2) []V2 {
+	var out []V2
+	for i, p0 := range poly {
+		p1 := poly[(i+1)%len(poly)]
+		d0 := p0.Sub(m).Dot(d)
+		d1 := p1.Sub(m).Dot(d)
+		if d0 <= 0 {
+			out = append(out, p0)
+		}
+		if (d0 < 0 && d1 > 0) || (d0 > 0 && d1 < 0) {
+			// the edge crosses the line
+			out = append(out, p0.Add(p1.Sub(p0).MulScalar(d0/(d0-d1))))
+		}
+	}
+	return out
+}
+
+// delaunayNeighbors returns the neighbors of each vertex in the delaunay
+// triangulation. If there are no triangles (E.g. collinear points) all the
+// other vertices are neighbors.
+func (vs V2Set) delaunayNeighbors() ([][]int, error) {
+	nb := make([][]int, len(vs))
+	if len(vs) >= 3 {
+		ts, err := vs.ConstrainedDelaunay2d(nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(ts) != 0 {
+			for _, e := range ts.Edges() {
+				nb[e[0]] = append(nb[e[0]], e[1])
+				nb[e[1]] = append(nb[e[1]], e[0])
+			}
+			return nb, nil
+		}
+	}
+	for i := range vs {
+		for j := range vs {
+			if i != j {
+				nb[i] = append(nb[i], j)
+			}
+		}
+	}
+	return nb, nil
+}
+
+// Voronoi2d returns the voronoi cell of each point, clipped to a bounding
+// box. The cells are convex polygons with counter-clockwise vertices, the
+// cell of a point outside the bounding box may be empty.
+func (vs V2Set) Voronoi2d(bb Box2) ([][]V2, error) {
+	if len(vs) == 0 {
+		return nil, errors.New("no vertices")
+	}
+	nb, err := vs.delaunayNeighbors()
+	if err != nil {
+		return nil, err
+	}
+	box := []V2{bb.Min, {bb.Max.X, bb.Min.Y}, bb.Max, {bb.Min.X, bb.Max.Y}}
+	cells := make([][]V2, len(vs))
+	for i, p := range vs {
+		cell := box
+		for _, j := range nb[i] {
+			// the half plane closer to p than to the neighbor
+			cell = clipHalfPlane(cell, p.Add(vs[j]).MulScalar(0.5), vs[j].Sub(p))
+			if len(cell) < 3 {
+				cell = nil
+				break
+			}
+		}
+		cells[i] = cell
+	}
+	return cells, nil
+}
+
+// VoronoiPattern2D returns the walls between the voronoi cells of a point
+// set, within a bounding box, E.g. for a decorative or lightweighting panel.
+// The cells are inset by half the wall thickness and the corners of the
+// openings have the given radius.
+func VoronoiPattern2D(vs V2Set, bb Box2, wall, round float64) (SDF2, error) {
+	if wall <= 0 {
+		return nil, errors.New("wall <= 0")
+	}
+	if round < 0 {
+		return nil, errors.New("round < 0")
+	}
+	cells, err := vs.Voronoi2d(bb)
+	if err != nil {
+		return nil, err
+	}
+	var holes []SDF2
+	for _, cell := range cells {
+		if cell == nil {
+			continue
+		}
+		s := Offset2D(Polygon2D(cell), -0.5*wall-round)
+		if size := s.BoundingBox().Size(); size.X <= 0 || size.Y <= 0 {
+			// the cell is smaller than the wall
+			continue
+		}
+		if round > 0 {
+			s = Offset2D(s, round)
+		}
+		holes = append(holes, s)
+	}
+	s := Transform2D(Box2D(bb.Size(), 0), Translate2d(bb.Center()))
+	return Difference2D(s, UnionBVH2D(holes...)), nil
+}
+
+//-----------------------------------------------------------------------------
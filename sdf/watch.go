@@ -0,0 +1,174 @@
+//-----------------------------------------------------------------------------
+/*
+
+Live Reload
+
+Watch a parameter file, rebuild the design whenever it changes and push the
+new mesh to the preview server. Keep a browser open on the preview page, edit
+the parameter file and the view updates (similar to the OpenSCAD F5 workflow).
+
+The file is polled for changes, so no OS specific notification is needed.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sdf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// BuildFunc builds an SDF3 from the contents of a parameter file.
+type BuildFunc func(data []byte) (SDF3, error)
+
+// Watcher rebuilds a design whenever its parameter file changes.
+type Watcher struct {
+	path     string         // parameter file
+	interval time.Duration  // polling interval
+	build    BuildFunc      // design build function
+	preview  *PreviewServer // preview server to update
+	modTime  time.Time      // modification time of last build
+	size     int64          // file size of last build
+	statErr  string         // last reported file status error
+}
+
+// NewWatcher returns a watcher for a parameter file.
+func NewWatcher(path string, build BuildFunc, preview *PreviewServer) *Watcher {
+	return &Watcher{
+		path:     path,
+		interval: 500 * time.Millisecond,
+		build:    build,
+		preview:  preview,
+	}
+}
+
+// SetInterval sets the file polling interval.
+func (w *Watcher) SetInterval(interval time.Duration) {
+	w.interval = interval
+}
+
+// changed returns true if the parameter file has changed since the last build.
+func (w *Watcher) changed() (bool, error) {
+	fi, err := os.Stat(w.path)
+	if err != nil {
+		return false, err
+	}
+	if fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+		return false, nil
+	}
+	w.modTime = fi.ModTime()
+	w.size = fi.Size()
+	return true, nil
+}
+
+// Rebuild reads the parameter file, builds the design and updates the preview.
+func (w *Watcher) Rebuild() error {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	s, err := w.build(data)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return errors.New("build returned a nil SDF3")
+	}
+	if err := w.preview.Update(s); err != nil {
+		return err
+	}
+	fmt.Printf("rebuilt %s (%s)\n", w.path, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// Run polls the parameter file and rebuilds on change until stop is closed.
+// Build errors are reported and the previous mesh stays in the preview.
+// A missing file (E.g. while an editor saves it by renaming a new file over
+// the old one) is reported and polling continues.
+func (w *Watcher) Run(stop <-chan struct{}) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		changed, err := w.changed()
+		if err != nil {
+			// report the error once, not on every poll
+			if err.Error() != w.statErr {
+				fmt.Printf("%s\n", err)
+				w.statErr = err.Error()
+			}
+		} else {
+			w.statErr = ""
+		}
+		if changed {
+			if err := w.Rebuild(); err != nil {
+				fmt.Printf("%s: %s\n", w.path, err)
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// JSONBuild returns a build function that decodes a JSON parameter file
+// into params (a pointer to a parameter struct) and then calls build.
+// Parameters that are not in the file keep their initial values, so
+// deleting a parameter from the file restores its initial value.
+func JSONBuild(params interface{}, build func() (SDF3, error)) BuildFunc {
+	v := reflect.ValueOf(params)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return func(data []byte) (SDF3, error) {
+			return nil, errors.New("params is not a pointer")
+		}
+	}
+	// Keep the initial values as JSON, a shallow copy would share maps
+	// with the decoded values.
+	initial, err := json.Marshal(params)
+	return func(data []byte) (SDF3, error) {
+		if err != nil {
+			return nil, err
+		}
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+		if err := json.Unmarshal(initial, params); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, params); err != nil {
+			return nil, err
+		}
+		return build()
+	}
+}
+
+// LivePreview serves a preview of a design and rebuilds it whenever the parameter file changes.
+func LivePreview(
+	path string, // parameter file to watch
+	build BuildFunc, // design build function
+	meshCells int, // number of cells on the longest axis. e.g 100
+	addr string, // network address to serve on. e.g "localhost:8080"
+) error {
+	ps := NewPreviewServer(meshCells)
+	w := NewWatcher(path, build, ps)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- ps.ListenAndServe(addr)
+	}()
+	go func() {
+		errc <- w.Run(nil)
+	}()
+	return <-errc
+}
+
+//-----------------------------------------------------------------------------